@@ -9,6 +9,7 @@ package apiserver
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -21,8 +22,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pivotal-cf/brokerapi"
 	apiauth "github.com/pivotal-cf/brokerapi/auth"
+	"github.com/pivotal-cf/brokerapi/middlewares/originating_identity_header"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
 	"github.com/urfave/negroni"
 )
@@ -33,16 +36,43 @@ type CombinedBroker interface {
 	brokerapi.ServiceBroker
 }
 
+// ServiceOfferingBroker is the broker for one of the service offerings the
+// broker serves.
+type ServiceOfferingBroker struct {
+	ServiceOffering config.ServiceOffering
+	Broker          CombinedBroker
+}
+
+// New serves the service broker API of broker and, when broker is also a
+// mgmtapi.ManageableBroker, its management API. A broker serving several
+// offerings cannot tell which offering a management request is for, so those
+// requests are rejected. Each of the serviceOfferingBrokers has both APIs
+// served under /service_offerings/<service ID>, which errands are pointed at
+// to manage a single offering.
 func New(
 	conf config.Config,
-	broker CombinedBroker,
+	broker brokerapi.ServiceBroker,
+	serviceOfferingBrokers []ServiceOfferingBroker,
 	componentName string,
 	mgmtapiLoggerFactory *loggerfactory.LoggerFactory,
+	brokerMetrics *metrics.Metrics,
 	serverLogger *log.Logger,
 ) *http.Server {
 
 	brokerRouter := mux.NewRouter()
-	mgmtapi.AttachRoutes(brokerRouter, broker, conf.ServiceCatalog, mgmtapiLoggerFactory)
+	brokerRouter.Use(originating_identity_header.AddToContext)
+	brokerRouter.Use(brokerMetrics.Middleware)
+	brokerRouter.Handle("/metrics", brokerMetrics.Handler(serverLogger)).Methods("GET")
+	for _, offeringBroker := range serviceOfferingBrokers {
+		offeringRouter := brokerRouter.PathPrefix("/service_offerings/" + offeringBroker.ServiceOffering.ID).Subrouter()
+		mgmtapi.AttachRoutes(offeringRouter, offeringBroker.Broker, offeringBroker.ServiceOffering, mgmtapiLoggerFactory)
+		brokerapi.AttachRoutes(offeringRouter, offeringBroker.Broker, lager.NewLogger(componentName))
+	}
+	if manageableBroker, ok := broker.(mgmtapi.ManageableBroker); ok {
+		mgmtapi.AttachRoutes(brokerRouter, manageableBroker, conf.ServiceCatalog, mgmtapiLoggerFactory)
+	} else {
+		brokerRouter.PathPrefix("/mgmt/").HandlerFunc(unroutableManagementRequest)
+	}
 	brokerapi.AttachRoutes(brokerRouter, broker, lager.NewLogger(componentName))
 	authProtectedBrokerAPI := apiauth.
 		NewWrapper(conf.Broker.Username, conf.Broker.Password).
@@ -68,6 +98,14 @@ func New(
 	}
 }
 
+func unroutableManagementRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(brokerapi.ErrorResponse{
+		Description: "this broker serves several service offerings, so management requests must be sent to /service_offerings/<service ID>/mgmt",
+	})
+}
+
 func StartAndWait(conf config.Config, server *http.Server, logger *log.Logger, stopServer chan os.Signal) {
 	stopped := make(chan struct{})
 	signal.Notify(stopServer, os.Interrupt, syscall.SIGTERM)
@@ -84,7 +122,7 @@ func StartAndWait(conf config.Config, server *http.Server, logger *log.Logger, s
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			logger.Printf("Error gracefully shutting down server: %v\n", err)
+			loggerfactory.Errorf(logger, "Error gracefully shutting down server: %v\n", err)
 		} else {
 			logger.Println("Server gracefully shut down")
 		}
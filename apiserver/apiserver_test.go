@@ -0,0 +1,111 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package apiserver_test
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/multioffering"
+)
+
+var _ = Describe("API server", func() {
+	const (
+		username = "username"
+		password = "password"
+	)
+
+	var (
+		redisBroker *fakes.FakeCombinedBroker
+		kafkaBroker *fakes.FakeCombinedBroker
+		handler     http.Handler
+	)
+
+	BeforeEach(func() {
+		redisBroker = new(fakes.FakeCombinedBroker)
+		kafkaBroker = new(fakes.FakeCombinedBroker)
+
+		redisOffering := config.ServiceOffering{ID: "redis-id", Name: "redis", Plans: config.Plans{{ID: "redis-plan-id"}}}
+		kafkaOffering := config.ServiceOffering{ID: "kafka-id", Name: "kafka", Plans: config.Plans{{ID: "kafka-plan-id"}}}
+		offeringBrokers := []apiserver.ServiceOfferingBroker{
+			{ServiceOffering: redisOffering, Broker: redisBroker},
+			{ServiceOffering: kafkaOffering, Broker: kafkaBroker},
+		}
+
+		conf := config.Config{
+			Broker:         config.Broker{Username: username, Password: password},
+			ServiceCatalog: redisOffering,
+		}
+		logger := log.New(GinkgoWriter, "", log.LstdFlags)
+
+		handler = apiserver.New(
+			conf,
+			multioffering.New(offeringBrokers),
+			offeringBrokers,
+			"api-server-tests",
+			loggerfactory.New(GinkgoWriter, "api-server-tests", log.LstdFlags),
+			metrics.New(redisOffering),
+			logger,
+		).Handler
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.SetBasicAuth(username, password)
+		request.Header.Set("X-Broker-API-Version", "2.14")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	When("the broker serves several service offerings", func() {
+		It("rejects management requests that do not say which offering they are for", func() {
+			response := get("/mgmt/service_instances")
+
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			var errorResponse brokerapi.ErrorResponse
+			Expect(json.NewDecoder(response.Body).Decode(&errorResponse)).To(Succeed())
+			Expect(errorResponse.Description).To(ContainSubstring("/service_offerings/<service ID>/mgmt"))
+			Expect(redisBroker.InstancesCallCount()).To(BeZero())
+			Expect(kafkaBroker.InstancesCallCount()).To(BeZero())
+		})
+
+		It("serves the management API of each offering under its own routes", func() {
+			response := get("/service_offerings/kafka-id/mgmt/service_instances")
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(kafkaBroker.InstancesCallCount()).To(Equal(1))
+			Expect(redisBroker.InstancesCallCount()).To(BeZero())
+		})
+
+		It("rejects service broker API requests that it cannot route to an offering", func() {
+			response := get("/v2/service_instances/some-instance/last_operation")
+
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(redisBroker.LastOperationCallCount()).To(BeZero())
+			Expect(kafkaBroker.LastOperationCallCount()).To(BeZero())
+		})
+
+		It("routes service broker API requests by their plan", func() {
+			response := get("/v2/service_instances/some-instance/last_operation?plan_id=kafka-plan-id")
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(kafkaBroker.LastOperationCallCount()).To(Equal(1))
+		})
+	})
+})
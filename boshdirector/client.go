@@ -16,6 +16,7 @@ import (
 	"github.com/cloudfoundry/bosh-cli/director"
 	boshuaa "github.com/cloudfoundry/bosh-cli/uaa"
 	"github.com/coreos/go-semver/semver"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 type Client struct {
@@ -41,6 +42,11 @@ type BOSHDeployment interface {
 	director.Deployment
 }
 
+//go:generate counterfeiter -o fakes/fake_release.go . BOSHRelease
+type BOSHRelease interface {
+	director.Release
+}
+
 //go:generate counterfeiter -o fakes/fake_task.go . Task
 type Task interface {
 	director.Task
@@ -191,8 +197,11 @@ type AuthenticationOptions struct {
 	URL string
 }
 
+// Deployment is a deployment as listed by the director, with the releases its
+// current manifest uses.
 type Deployment struct {
-	Name string
+	Name     string
+	Releases []bosh.Release
 }
 
 const (
@@ -10,6 +10,7 @@ import (
 	"log"
 
 	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 	"github.com/pkg/errors"
 )
 
@@ -26,6 +27,19 @@ func (c *Client) GetDeployments(logger *log.Logger) ([]Deployment, error) {
 	deployments := make([]Deployment, len(rawDeployments))
 	for i, d := range rawDeployments {
 		deployments[i] = Deployment{Name: d.Name()}
+
+		// The director lists the releases along with the deployments, so
+		// this does not make a request per deployment.
+		releases, err := d.Releases()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot get the releases of deployment %s", d.Name())
+		}
+		for _, release := range releases {
+			deployments[i].Releases = append(deployments[i].Releases, bosh.Release{
+				Name:    release.Name(),
+				Version: release.Version().String(),
+			})
+		}
 	}
 	return deployments, nil
 }
@@ -10,10 +10,12 @@ import (
 	"errors"
 
 	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cppforlife/go-semi-semantic/version"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector/fakes"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 var _ = Describe("deployments", func() {
@@ -39,6 +41,25 @@ var _ = Describe("deployments", func() {
 		Expect(deployments).To(Equal(expectedDeployments))
 	})
 
+	It("fetches the releases each deployment uses", func() {
+		fakeRelease := new(fakes.FakeBOSHRelease)
+		fakeRelease.NameReturns("redis")
+		fakeRelease.VersionReturns(version.MustNewVersionFromString("1.2.3"))
+		fakeDeployment.ReleasesReturns([]director.Release{fakeRelease}, nil)
+
+		deployments, err := c.GetDeployments(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deployments).To(Equal([]boshdirector.Deployment{
+			{Name: "some-deployment", Releases: []bosh.Release{{Name: "redis", Version: "1.2.3"}}},
+		}))
+	})
+
+	It("returns an error if cannot fetch the releases of a deployment", func() {
+		fakeDeployment.ReleasesReturns(nil, errors.New("oops"))
+		_, err := c.GetDeployments(logger)
+		Expect(err).To(MatchError(ContainSubstring("Cannot get the releases of deployment some-deployment")))
+	})
+
 	It("returns an error if cannot fetch the deployments", func() {
 		fakeDirector.DeploymentsReturns(nil, errors.New("oops"))
 		_, err := c.GetDeployments(logger)
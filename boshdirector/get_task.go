@@ -24,11 +24,12 @@ func (c *Client) GetTask(taskID int, logger *log.Logger) (BoshTask, error) {
 		return BoshTask{}, errors.Wrapf(err, "Cannot find task with ID: %d", taskID)
 	}
 	return BoshTask{
-		ID:          task.ID(),
-		State:       task.State(),
-		Description: task.Description(),
-		Result:      task.Result(),
-		ContextID:   task.ContextID(),
+		ID:             task.ID(),
+		State:          task.State(),
+		Description:    task.Description(),
+		Result:         task.Result(),
+		ContextID:      task.ContextID(),
+		LastActivityAt: task.LastActivityAt(),
 	}, nil
 }
 
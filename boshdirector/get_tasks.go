@@ -52,11 +52,12 @@ func (c *Client) toBoshTasks(tasks []director.Task, deploymentName string, logge
 				return nil, errors.Wrap(err, "Could not retrieve task output")
 			}
 			boshTasks = append(boshTasks, BoshTask{
-				ID:          task.ID(),
-				State:       taskState,
-				Description: task.Description(),
-				Result:      task.Result(),
-				ContextID:   task.ContextID(),
+				ID:             task.ID(),
+				State:          taskState,
+				Description:    task.Description(),
+				Result:         task.Result(),
+				ContextID:      task.ContextID(),
+				LastActivityAt: task.LastActivityAt(),
 			})
 		}
 	}
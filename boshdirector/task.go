@@ -6,7 +6,10 @@
 
 package boshdirector
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type BoshTask struct {
 	ID          int
@@ -14,6 +17,10 @@ type BoshTask struct {
 	Description string
 	Result      string
 	ContextID   string `json:"context_id,omitempty"`
+
+	// LastActivityAt is when the director last recorded progress on the task,
+	// which for a finished task is when it finished.
+	LastActivityAt time.Time `json:"-"`
 }
 
 type TaskStateType int
@@ -0,0 +1,263 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+)
+
+// Backup is a run of a plan's backup errand against a service instance. Its
+// ID is that of the operation in the operation journal.
+type Backup struct {
+	ID         string    `json:"backup_id"`
+	PlanID     string    `json:"plan_id,omitempty"`
+	BoshTaskID int       `json:"bosh_task_id"`
+	State      string    `json:"state"`
+	Artefact   string    `json:"artefact,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Backup runs the backup errand of the plan the instance is deployed with.
+// Backups are recorded in the operation journal, so it must be enabled.
+func (b *Broker) Backup(ctx context.Context, instanceID string, logger *log.Logger) (OperationData, error) {
+	defer b.instanceLocks.acquire(instanceID)()
+
+	logger.Printf("backing up instance %s", instanceID)
+
+	planID, backupErrands, err := b.backupErrands(instanceID, logger)
+	if err != nil {
+		return OperationData{}, err
+	}
+
+	operationData, err := b.runBackupErrand(ctx, instanceID, planID, OperationTypeBackup, backupErrands.Backup, logger)
+	if err != nil {
+		return OperationData{}, err
+	}
+	b.recordStartedOperation(ctx, instanceID, planID, operationData, logger)
+
+	return operationData, nil
+}
+
+// Restore runs the restore errand of the plan the instance is deployed with
+// to restore one of its successful backups, the most recent one when no
+// backup ID is given. BOSH cannot pass arguments to an errand, so the artefact
+// of the backup is kept in the parameter store under restoreArtefactKey for
+// the errand to read, and restores require one. The artefact is also recorded
+// against the restore in the operation journal.
+func (b *Broker) Restore(ctx context.Context, instanceID, backupID string, logger *log.Logger) (OperationData, error) {
+	defer b.instanceLocks.acquire(instanceID)()
+
+	logger.Printf("restoring instance %s", instanceID)
+
+	planID, backupErrands, err := b.backupErrands(instanceID, logger)
+	if err != nil {
+		return OperationData{}, err
+	}
+
+	if b.parameterStore == nil {
+		return OperationData{}, b.processError(NewBackupNotRestorableError(
+			errors.New("restores require credhub, where the artefact to restore is kept for the restore errand"),
+		), logger)
+	}
+
+	backups, err := b.Backups(ctx, instanceID, logger)
+	if err != nil {
+		return OperationData{}, b.processError(err, logger)
+	}
+
+	backup, found := latestSuccessfulBackup(backups)
+	if backupID != "" {
+		backup, found = successfulBackup(backups, backupID)
+	}
+	if !found {
+		if backupID != "" {
+			return OperationData{}, b.processError(NewBackupNotFoundError(fmt.Errorf("instance %s has no successful backup %s", instanceID, backupID)), logger)
+		}
+		return OperationData{}, b.processError(NewBackupNotFoundError(fmt.Errorf("instance %s has no successful backup to restore", instanceID)), logger)
+	}
+
+	key := restoreArtefactKey(b.serviceOffering.ID, instanceID)
+	if err := b.parameterStore.Set(key, backup.Artefact); err != nil {
+		loggerfactory.Errorf(logger, "error storing the artefact of backup %s for the restore errand of instance %s: %s\n", backup.ID, instanceID, err)
+		return OperationData{}, b.processError(fmt.Errorf("error storing the artefact of backup %s to restore: %s", backup.ID, err), logger)
+	}
+
+	operationData, err := b.runBackupErrand(ctx, instanceID, planID, OperationTypeRestore, backupErrands.Restore, logger)
+	if err != nil {
+		return OperationData{}, err
+	}
+
+	operation := startedOperation(instanceID, planID, operationData)
+	operation.Artefact = backup.Artefact
+	b.recordStarted(ctx, operation, logger)
+
+	return operationData, nil
+}
+
+// Backups lists the backups of an instance, oldest first. Backups that were
+// in progress when last recorded are looked up in BOSH, so that they can be
+// listed without polling LastOperation.
+func (b *Broker) Backups(ctx context.Context, instanceID string, logger *log.Logger) ([]Backup, error) {
+	operations, err := b.Operations(instanceID, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed := false
+	for _, operation := range operations {
+		if operation.Type == string(OperationTypeBackup) && operation.State == string(brokerapi.InProgress) && len(operation.BoshTaskIDs) > 0 {
+			refreshed = b.refreshBackup(ctx, operation, logger) || refreshed
+		}
+	}
+	if refreshed {
+		if operations, err = b.Operations(instanceID, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	backups := []Backup{}
+	for _, operation := range operations {
+		if operation.Type != string(OperationTypeBackup) {
+			continue
+		}
+		backup := Backup{
+			ID:        operation.ID,
+			PlanID:    operation.PlanID,
+			State:     operation.State,
+			Artefact:  operation.Artefact,
+			StartedAt: operation.StartedAt,
+			UpdatedAt: operation.UpdatedAt,
+		}
+		if len(operation.BoshTaskIDs) > 0 {
+			backup.BoshTaskID = operation.BoshTaskIDs[0]
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// backupErrands returns the plan the instance is deployed with and its backup
+// errands. The plan comes from the operation journal, which backups require,
+// rather than from the request, so that the errands match the deployment.
+func (b *Broker) backupErrands(instanceID string, logger *log.Logger) (string, *config.BackupErrands, error) {
+	if b.operationJournal == nil {
+		return "", nil, b.processError(NewOperationJournalDisabledError(errors.New("backups require the operation journal to be enabled for this broker")), logger)
+	}
+
+	planID, err := b.deployedPlanID(instanceID, logger)
+	if err != nil {
+		return "", nil, b.processError(fmt.Errorf("error finding the plan of instance %s: %s", instanceID, err), logger)
+	}
+	if planID == "" {
+		return "", nil, b.processError(NewDeploymentNotFoundError(fmt.Errorf("the plan of instance %s could not be found", instanceID)), logger)
+	}
+
+	plan, found := b.serviceOffering.FindPlanByID(planID)
+	if !found {
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", planID)
+		return "", nil, b.processError(fmt.Errorf("plan %s not found", planID), logger)
+	}
+
+	if plan.BackupErrands == nil {
+		return "", nil, b.processError(NewBackupNotConfiguredError(fmt.Errorf("plan %s has no backup errands configured", planID)), logger)
+	}
+
+	return planID, plan.BackupErrands, nil
+}
+
+func (b *Broker) runBackupErrand(ctx context.Context, instanceID, planID string, operationType OperationType, errand config.Errand, logger *log.Logger) (OperationData, error) {
+	boshContextID := uuid.New()
+	taskID, err := b.deployer.RunErrand(ctx, b.deploymentName(instanceID), errand.Name, errand.Instances, boshContextID, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error running %s errand of instance %s: %s", operationType, instanceID, err)
+
+		switch err := err.(type) {
+		case TaskInProgressError:
+			return OperationData{}, b.processError(NewOperationInProgressError(err), logger)
+		default:
+			return OperationData{}, b.processError(err, logger)
+		}
+	}
+
+	return OperationData{
+		BoshTaskID:    taskID,
+		BoshContextID: boshContextID,
+		OperationType: operationType,
+		PlanID:        planID,
+	}, nil
+}
+
+// refreshBackup records the outcome of a backup whose errand has finished,
+// returning whether it did.
+func (b *Broker) refreshBackup(ctx context.Context, operation operationjournal.Operation, logger *log.Logger) bool {
+	task, err := b.boshClient.GetTask(operation.BoshTaskIDs[0], logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error getting BOSH task %d of backup %s: %s\n", operation.BoshTaskIDs[0], operation.ID, err)
+		return false
+	}
+	if task.StateType() == boshdirector.TaskIncomplete {
+		return false
+	}
+
+	operationData := OperationData{
+		BoshTaskID:    operation.BoshTaskIDs[0],
+		OperationType: OperationTypeBackup,
+		PlanID:        operation.PlanID,
+	}
+	lastOperation := constructLastOperation(ctx, lastOperationState(task, logger), task, ErrandAttempt{}, operationData, b.ExposeOperationalErrors)
+	b.recordFinishedOperation(ctx, operation.InstanceID, operationData, task, lastOperation, logger)
+	return true
+}
+
+// backupArtefact is the last line the backup errand wrote to its standard
+// output, which references the artefact it produced.
+func (b *Broker) backupArtefact(taskID int, logger *log.Logger) string {
+	output, err := b.boshClient.GetTaskOutput(taskID, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error getting the output of backup errand task %d: %s\n", taskID, err)
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.StdOut), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func restoreArtefactKey(serviceID, instanceID string) string {
+	return fmt.Sprintf("/c/%s/%s/restore_artefact", serviceID, instanceID)
+}
+
+func successfulBackup(backups []Backup, backupID string) (Backup, bool) {
+	for _, backup := range backups {
+		if backup.ID == backupID && backup.State == string(brokerapi.Succeeded) {
+			return backup, true
+		}
+	}
+	return Backup{}, false
+}
+
+func latestSuccessfulBackup(backups []Backup) (Backup, bool) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		if backups[i].State == string(brokerapi.Succeeded) {
+			return backups[i], true
+		}
+	}
+	return Backup{}, false
+}
@@ -0,0 +1,283 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+var _ = Describe("Backups", func() {
+	const (
+		instanceID   = "some-instance"
+		backupPlanID = "backup-plan"
+	)
+
+	var (
+		created operationjournal.Operation
+		logger  *log.Logger
+	)
+
+	BeforeEach(func() {
+		serviceCatalog.Plans = append(serviceCatalog.Plans, config.Plan{
+			ID: backupPlanID,
+			BackupErrands: &config.BackupErrands{
+				Backup:  config.Errand{Name: "backup", Instances: []string{"redis-server/0"}},
+				Restore: config.Errand{Name: "restore"},
+			},
+		})
+		b = createDefaultBroker()
+		created = operationjournal.Operation{ID: "1", InstanceID: instanceID, Type: "create", PlanID: backupPlanID, BoshTaskIDs: []int{1}, State: string(brokerapi.Succeeded)}
+		fakeOperationJournal.OperationsReturns([]operationjournal.Operation{created}, nil)
+		logger = loggerFactory.NewWithRequestID()
+		fakeDeployer.RunErrandReturns(42, nil)
+	})
+
+	Describe("Backup", func() {
+		It("runs the backup errand of the instance's plan and records the backup", func() {
+			operationData, err := b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operationData.BoshTaskID).To(Equal(42))
+			Expect(operationData.OperationType).To(Equal(broker.OperationTypeBackup))
+			Expect(operationData.PlanID).To(Equal(backupPlanID))
+
+			Expect(fakeDeployer.RunErrandCallCount()).To(Equal(1))
+			_, deployment, errandName, errandInstances, contextID, _ := fakeDeployer.RunErrandArgsForCall(0)
+			Expect(deployment).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(errandName).To(Equal("backup"))
+			Expect(errandInstances).To(Equal([]string{"redis-server/0"}))
+			Expect(contextID).NotTo(BeEmpty())
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			operation := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(operation.ID).To(Equal("42"))
+			Expect(operation.Type).To(Equal("backup"))
+			Expect(operation.State).To(Equal(string(brokerapi.InProgress)))
+			Expect(operation.PlanID).To(Equal(backupPlanID))
+		})
+
+		It("uses the plan the instance was last updated to", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				created,
+				{ID: "2", InstanceID: instanceID, Type: "update", PlanID: existingPlanID, BoshTaskIDs: []int{2}, State: string(brokerapi.Succeeded)},
+			}, nil)
+
+			_, err := b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.BackupNotConfiguredError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("falls back to the instance lister for instances the journal has no plan for", func() {
+			fakeOperationJournal.OperationsReturns(nil, nil)
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: instanceID, PlanUniqueID: backupPlanID}}, nil)
+
+			operationData, err := b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operationData.PlanID).To(Equal(backupPlanID))
+		})
+
+		It("fails when the plan of the instance cannot be found", func() {
+			fakeOperationJournal.OperationsReturns(nil, nil)
+			fakeInstanceLister.InstancesReturns(nil, nil)
+
+			_, err := b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("returns an OperationInProgressError when there is a task in progress on the instance", func() {
+			fakeDeployer.RunErrandReturns(0, broker.TaskInProgressError{})
+
+			_, err := b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.OperationInProgressError{}))
+			Expect(fakeOperationJournal.RecordCallCount()).To(BeZero())
+		})
+
+		It("fails when the operation journal is not enabled", func() {
+			b, err := broker.New(boshClient, cfClient, serviceCatalog, brokerConfig, nil, serviceAdapter, fakeDeployer, fakeSecretManager, fakeInstanceLister, fakeMapHasher, nil, loggerFactory)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = b.Backup(context.Background(), instanceID, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.OperationJournalDisabledError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("listing backups", func() {
+		It("lists the backups recorded for the instance", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "40", InstanceID: instanceID, Type: "upgrade", BoshTaskIDs: []int{40}, State: string(brokerapi.Succeeded)},
+				{ID: "41", InstanceID: instanceID, Type: "backup", PlanID: backupPlanID, BoshTaskIDs: []int{41}, State: string(brokerapi.Succeeded), Artefact: "s3://backups/41"},
+			}, nil)
+
+			backups, err := b.Backups(context.Background(), instanceID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(Equal([]broker.Backup{
+				{ID: "41", PlanID: backupPlanID, BoshTaskID: 41, State: string(brokerapi.Succeeded), Artefact: "s3://backups/41"},
+			}))
+			Expect(boshClient.GetTaskCallCount()).To(BeZero())
+		})
+
+		It("records the artefact of a backup whose errand has finished", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "41", InstanceID: instanceID, Type: "backup", PlanID: backupPlanID, BoshTaskIDs: []int{41}, State: string(brokerapi.InProgress)},
+			}, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 41, State: boshdirector.TaskDone}, nil)
+			boshClient.GetTaskOutputReturns(boshdirector.BoshTaskOutput{StdOut: "backing up\ns3://backups/41\n"}, nil)
+
+			_, err := b.Backups(context.Background(), instanceID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(boshClient.GetTaskOutputCallCount()).To(Equal(1))
+			taskID, _ := boshClient.GetTaskOutputArgsForCall(0)
+			Expect(taskID).To(Equal(41))
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			operation := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(operation.ID).To(Equal("41"))
+			Expect(operation.State).To(Equal(string(brokerapi.Succeeded)))
+			Expect(operation.Artefact).To(Equal("s3://backups/41"))
+			Expect(fakeOperationJournal.OperationsCallCount()).To(Equal(2))
+		})
+
+		It("leaves backups that are still running in progress", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "41", InstanceID: instanceID, Type: "backup", BoshTaskIDs: []int{41}, State: string(brokerapi.InProgress)},
+			}, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 41, State: boshdirector.TaskProcessing}, nil)
+
+			backups, err := b.Backups(context.Background(), instanceID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(1))
+			Expect(backups[0].State).To(Equal(string(brokerapi.InProgress)))
+			Expect(fakeOperationJournal.RecordCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("Restore", func() {
+		var parameterStore *fakes.FakeParameterStore
+
+		BeforeEach(func() {
+			parameterStore = new(fakes.FakeParameterStore)
+			b.UseParameterStore(parameterStore)
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				created,
+				{ID: "40", InstanceID: instanceID, Type: "backup", BoshTaskIDs: []int{40}, State: string(brokerapi.Succeeded), Artefact: "s3://backups/40"},
+				{ID: "41", InstanceID: instanceID, Type: "backup", BoshTaskIDs: []int{41}, State: string(brokerapi.Succeeded), Artefact: "s3://backups/41"},
+				{ID: "42", InstanceID: instanceID, Type: "backup", BoshTaskIDs: []int{42}, State: string(brokerapi.Failed)},
+			}, nil)
+			fakeDeployer.RunErrandReturns(43, nil)
+		})
+
+		It("runs the restore errand with the most recent successful backup and records it", func() {
+			operationData, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operationData.BoshTaskID).To(Equal(43))
+			Expect(operationData.OperationType).To(Equal(broker.OperationTypeRestore))
+			Expect(operationData.PlanID).To(Equal(backupPlanID))
+
+			Expect(parameterStore.SetCallCount()).To(Equal(1))
+			key, value := parameterStore.SetArgsForCall(0)
+			Expect(key).To(Equal("/c/" + serviceOfferingID + "/" + instanceID + "/restore_artefact"))
+			Expect(value).To(Equal("s3://backups/41"))
+
+			_, _, errandName, _, contextID, _ := fakeDeployer.RunErrandArgsForCall(0)
+			Expect(errandName).To(Equal("restore"))
+			Expect(contextID).To(Equal(operationData.BoshContextID))
+			Expect(contextID).NotTo(BeEmpty())
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			operation := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(operation.Type).To(Equal("restore"))
+			Expect(operation.Artefact).To(Equal("s3://backups/41"))
+		})
+
+		It("restores an older backup when it is named", func() {
+			_, err := b.Restore(context.Background(), instanceID, "40", logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			_, value := parameterStore.SetArgsForCall(0)
+			Expect(value).To(Equal("s3://backups/40"))
+			Expect(fakeDeployer.RunErrandCallCount()).To(Equal(1))
+			Expect(fakeOperationJournal.RecordArgsForCall(0).Artefact).To(Equal("s3://backups/40"))
+		})
+
+		It("does not run the restore errand when the artefact cannot be stored", func() {
+			parameterStore.SetReturns(errors.New("credhub unavailable"))
+
+			_, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).To(MatchError(ContainSubstring("credhub unavailable")))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("fails when the broker has no credential store", func() {
+			b = createDefaultBroker()
+
+			_, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.BackupNotRestorableError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("fails when the named backup is not a successful backup of the instance", func() {
+			_, err := b.Restore(context.Background(), instanceID, "42", logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.BackupNotFoundError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("fails when the instance has no successful backup", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				created,
+				{ID: "42", InstanceID: instanceID, Type: "backup", BoshTaskIDs: []int{42}, State: string(brokerapi.Failed)},
+			}, nil)
+
+			_, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.BackupNotFoundError{}))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("returns an OperationInProgressError when there is a task in progress on the instance", func() {
+			fakeDeployer.RunErrandReturns(0, broker.TaskInProgressError{})
+
+			_, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.OperationInProgressError{}))
+		})
+
+		It("fails when the backups cannot be listed", func() {
+			fakeOperationJournal.OperationsReturns(nil, errors.New("journal unreadable"))
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: instanceID, PlanUniqueID: backupPlanID}}, nil)
+
+			_, err := b.Restore(context.Background(), instanceID, "", logger)
+
+			Expect(err).To(MatchError("journal unreadable"))
+		})
+	})
+})
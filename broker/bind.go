@@ -8,13 +8,19 @@ package broker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 func (b *Broker) Bind(
@@ -31,23 +37,14 @@ func (b *Broker) Bind(
 	}
 
 	ctx = brokercontext.New(ctx, string(OperationTypeBind), requestID, b.serviceOffering.Name, instanceID)
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
 	logger := b.loggerFactory.NewWithContext(ctx)
 
-	manifest, vms, deploymentErr := b.getDeploymentInfo(instanceID, ctx, "bind", logger)
+	manifest, vms, secretsMap, deploymentErr := b.bindingDeploymentInfo(ctx, instanceID, logger)
 	if deploymentErr != nil {
 		return brokerapi.Binding{}, b.processError(deploymentErr, logger)
 	}
 
-	deploymentVariables, err := b.boshClient.Variables(deploymentName(instanceID), logger)
-	if err != nil {
-		logger.Printf("failed to retrieve deployment variables for deployment '%s': %s", deploymentName(instanceID), err)
-	}
-
-	secretsMap, err := b.secretManager.ResolveManifestSecrets(manifest, deploymentVariables, logger)
-	if err != nil {
-		logger.Printf("failed to resolve manifest secrets: %s", err.Error())
-	}
-
 	logger.Printf("service adapter will create binding with ID %s for instance %s\n", bindingID, instanceID)
 	detailsWithRawParameters := brokerapi.DetailsWithRawParameters(details)
 	mappedParams, err := convertDetailsToMap(detailsWithRawParameters)
@@ -63,7 +60,7 @@ func (b *Broker) Bind(
 				fmt.Errorf("finding plan ID %s", details.PlanID),
 			), logger)
 		}
-		schemas, err := b.adapterClient.GeneratePlanSchema(plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+		schemas, err := b.planSchemas(ctx, plan, logger)
 		if err != nil {
 			if _, ok := err.(serviceadapter.NotImplementedError); !ok {
 				return brokerapi.Binding{}, b.processError(err, logger)
@@ -86,12 +83,70 @@ func (b *Broker) Bind(
 		}
 	}
 
-	dnsAddresses, err := b.boshClient.GetDNSAddresses(deploymentName(instanceID), plan.BindingWithDNS)
+	sharing, err := b.bindingSharing(ctx, instanceID, details, plan, logger)
+	if err != nil {
+		return brokerapi.Binding{}, b.processError(err, logger)
+	}
+	if sharing != nil {
+		if sharing.Access == config.SharedBindingAccessDenied {
+			return brokerapi.Binding{}, b.processError(NewDisplayableError(
+				fmt.Errorf("plan %s does not allow bindings from spaces the instance has been shared with", plan.Name),
+				fmt.Errorf("binding from space %s to instance %s of space %s is denied by plan %s", sharing.ConsumerSpaceGUID, instanceID, sharing.OwnerSpaceGUID, plan.ID),
+			), logger)
+		}
+		if sharing.Shared {
+			logger.Printf("binding %s is made from space %s, which instance %s has been shared with, granting %s access\n", bindingID, sharing.ConsumerSpaceGUID, instanceID, sharing.Access)
+		}
+		mappedParams[sharingRequestParam] = sharing
+	}
+
+	dnsAddresses, err := b.boshClient.GetDNSAddresses(b.deploymentName(instanceID), plan.BindingWithDNS)
 	if err != nil {
 		return brokerapi.Binding{}, b.processError(NewGenericError(ctx, fmt.Errorf("failed to get required DNS info: %s", err)), logger)
 	}
 
-	binding, createBindingErr := b.adapterClient.CreateBinding(bindingID, vms, manifest, mappedParams, secretsMap, dnsAddresses, logger)
+	if asyncAllowed && b.EnableAsyncBindings {
+		if !b.bindingOperations.start(instanceID, bindingID, OperationTypeBind) {
+			return brokerapi.Binding{}, b.processError(brokerapi.ErrConcurrentInstanceAccess.Build(), logger)
+		}
+
+		operationData, err := json.Marshal(OperationData{OperationType: OperationTypeBind, StartedAt: time.Now().Unix()})
+		if err != nil {
+			b.bindingOperations.forget(instanceID, bindingID)
+			return brokerapi.Binding{}, b.processError(NewGenericError(ctx, err), logger)
+		}
+
+		b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeBind, brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: descriptions[brokerapi.InProgress][OperationTypeBind],
+		}, logger)
+
+		// The operation outlives the request, so it is only stopped by the
+		// adapter command's timeout.
+		go func() {
+			binding, err := b.adapterClient.CreateBinding(context.Background(), bindingID, vms, manifest, mappedParams, secretsMap, dnsAddresses, logger)
+			if err != nil {
+				logger.Printf("creating binding: %v\n", err)
+			}
+			created := brokerapi.Binding{
+				Credentials:     binding.Credentials,
+				SyslogDrainURL:  binding.SyslogDrainURL,
+				RouteServiceURL: binding.RouteServiceURL,
+			}
+			if err == nil && b.bindingStore != nil {
+				if err = b.bindingStore.StoreBinding(ctx, instanceID, bindingID, details, created); err != nil {
+					logger.Printf("storing binding: %v\n", err)
+				}
+			}
+			b.bindingOperations.finish(instanceID, bindingID, created, err)
+			b.recordFinishedBindingOperation(ctx, instanceID, bindingID, OperationTypeBind, err, logger)
+		}()
+
+		logger.Printf("binding %s for instance %s is being created asynchronously\n", bindingID, instanceID)
+		return brokerapi.Binding{IsAsync: true, OperationData: string(operationData)}, nil
+	}
+
+	binding, createBindingErr := b.adapterClient.CreateBinding(ctx, bindingID, vms, manifest, mappedParams, secretsMap, dnsAddresses, logger)
 	if createBindingErr != nil {
 		if !b.EnableSecureManifests {
 			logger.Printf("broker.resolve_secrets_at_bind was: false ")
@@ -100,12 +155,43 @@ func (b *Broker) Bind(
 	}
 
 	if err := adapterToAPIError(ctx, createBindingErr); err != nil {
+		b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeBind, brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: fmt.Sprintf("%s: %s", descriptions[brokerapi.Failed][OperationTypeBind], err),
+		}, logger)
 		return brokerapi.Binding{}, b.processError(err, logger)
 	}
 
+	b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeBind, brokerapi.LastOperation{
+		State:       brokerapi.Succeeded,
+		Description: descriptions[brokerapi.Succeeded][OperationTypeBind],
+	}, logger)
+
 	return brokerapi.Binding{
 		Credentials:     binding.Credentials,
 		SyslogDrainURL:  binding.SyslogDrainURL,
 		RouteServiceURL: binding.RouteServiceURL,
 	}, nil
 }
+
+// bindingDeploymentInfo gathers the manifest, VMs and resolved manifest
+// secrets of an instance's deployment that the service adapter is given to
+// create a binding.
+func (b *Broker) bindingDeploymentInfo(ctx context.Context, instanceID string, logger *log.Logger) ([]byte, bosh.BoshVMs, map[string]string, BrokerError) {
+	manifest, vms, deploymentErr := b.getDeploymentInfo(instanceID, ctx, "bind", logger)
+	if deploymentErr != nil {
+		return nil, nil, nil, deploymentErr
+	}
+
+	deploymentVariables, err := b.boshClient.Variables(b.deploymentName(instanceID), logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "failed to retrieve deployment variables for deployment '%s': %s", b.deploymentName(instanceID), err)
+	}
+
+	secretsMap, err := b.secretManager.ResolveManifestSecrets(manifest, deploymentVariables, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "failed to resolve manifest secrets: %s", err.Error())
+	}
+
+	return manifest, vms, secretsMap, nil
+}
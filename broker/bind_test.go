@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -20,6 +21,8 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	brokerfakes "github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
 	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/noopservicescontroller"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
@@ -113,7 +116,7 @@ var _ = Describe("Bind", func() {
 
 			bindResult, bindErr = b.Bind(context.Background(), instanceID, bindingID, bindRequest, false)
 			Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
-			passedBindingID, passedVms, passedManifest, passedRequestParameters, _, passedDNSAddresses, _ := serviceAdapter.CreateBindingArgsForCall(0)
+			_, passedBindingID, passedVms, passedManifest, passedRequestParameters, _, passedDNSAddresses, _ := serviceAdapter.CreateBindingArgsForCall(0)
 			Expect(passedBindingID).To(Equal(bindingID))
 			Expect(passedVms).To(Equal(boshVms))
 			Expect(passedManifest).To(Equal(actualManifest))
@@ -151,7 +154,7 @@ var _ = Describe("Bind", func() {
 
 		It("creates the binding using the bosh topology, admin credentials and bosh dns addresses", func() {
 			Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
-			passedBindingID, passedVms, passedManifest, passedRequestParameters, _, passedDNSAddresses, _ := serviceAdapter.CreateBindingArgsForCall(0)
+			_, passedBindingID, passedVms, passedManifest, passedRequestParameters, _, passedDNSAddresses, _ := serviceAdapter.CreateBindingArgsForCall(0)
 			Expect(passedBindingID).To(Equal(bindingID))
 			Expect(passedVms).To(Equal(boshVms))
 			Expect(passedManifest).To(Equal(actualManifest))
@@ -394,6 +397,16 @@ var _ = Describe("Bind", func() {
 					Expect(bindErr).To(Equal(brokerapi.ErrAppGuidNotProvided))
 				})
 			})
+
+			Context("when the service adapter times out", func() {
+				BeforeEach(func() {
+					serviceAdapter.CreateBindingReturns(sdk.Binding{}, serviceadapter.NewTimeoutError("/adapter", "create-binding", time.Minute, nil, nil))
+				})
+
+				It("tells the user the service adapter did not respond in time", func() {
+					Expect(bindErr).To(MatchError(broker.AdapterTimeoutMessage))
+				})
+			})
 		})
 	})
 
@@ -480,6 +493,51 @@ var _ = Describe("Bind", func() {
 			Expect(fakeAdapter.GeneratePlanSchemaCallCount()).To(Equal(1))
 		})
 
+		It("generates the plan schemas once for all binds", func() {
+			fakeAdapter := new(brokerfakes.FakeServiceAdapterClient)
+			fakeAdapter.GeneratePlanSchemaReturns(schemaFixture, nil)
+			b = createBrokerWithAdapter(fakeAdapter)
+
+			bindRequest := generateBindRequestWithParams(map[string]interface{}{})
+
+			_, bindErr = b.Bind(context.Background(), instanceID, bindingID, bindRequest, false)
+			Expect(bindErr).NotTo(HaveOccurred())
+			_, bindErr = b.Bind(context.Background(), instanceID, "another-binding", bindRequest, false)
+			Expect(bindErr).NotTo(HaveOccurred())
+
+			Expect(fakeAdapter.GeneratePlanSchemaCallCount()).To(Equal(1))
+		})
+
+		It("reuses the plan schemas generated for the catalog", func() {
+			fakeAdapter := new(brokerfakes.FakeServiceAdapterClient)
+			fakeAdapter.GeneratePlanSchemaReturns(schemaFixture, nil)
+			b = createBrokerWithAdapter(fakeAdapter)
+
+			services, err := b.Services(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeAdapter.GeneratePlanSchemaCallCount()).To(Equal(len(services[0].Plans)))
+
+			_, bindErr = b.Bind(context.Background(), instanceID, bindingID, generateBindRequestWithParams(map[string]interface{}{}), false)
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(fakeAdapter.GeneratePlanSchemaCallCount()).To(Equal(len(services[0].Plans)))
+		})
+
+		It("generates the plan schemas again after the service adapter fails", func() {
+			fakeAdapter := new(brokerfakes.FakeServiceAdapterClient)
+			fakeAdapter.GeneratePlanSchemaReturnsOnCall(0, brokerapi.ServiceSchemas{}, errors.New("oops"))
+			fakeAdapter.GeneratePlanSchemaReturnsOnCall(1, schemaFixture, nil)
+			b = createBrokerWithAdapter(fakeAdapter)
+
+			bindRequest := generateBindRequestWithParams(map[string]interface{}{})
+
+			_, bindErr = b.Bind(context.Background(), instanceID, bindingID, bindRequest, false)
+			Expect(bindErr).To(MatchError(ContainSubstring("oops")))
+			_, bindErr = b.Bind(context.Background(), instanceID, bindingID, bindRequest, false)
+			Expect(bindErr).NotTo(HaveOccurred())
+
+			Expect(fakeAdapter.GeneratePlanSchemaCallCount()).To(Equal(2))
+		})
+
 		It("returns an error if the service adapter fails", func() {
 			fakeAdapter := new(brokerfakes.FakeServiceAdapterClient)
 			fakeAdapter.GeneratePlanSchemaReturns(schemaFixture, errors.New("oops"))
@@ -567,6 +625,103 @@ var _ = Describe("Bind", func() {
 			Expect(logBuffer.String()).To(ContainSubstring("secrets needed"))
 		})
 	})
+
+	Context("when the service offering is shareable", func() {
+		const ownerSpaceGUID = "owner-space-guid"
+
+		var sharingPlan config.Plan
+
+		BeforeEach(func() {
+			sharingPlan = config.Plan{ID: "sharing-plan", Name: "sharing-plan-name"}
+			cfClient.GetInstanceStateReturns(cf.InstanceState{SpaceGUID: ownerSpaceGUID}, nil)
+		})
+
+		bindFromSpace := func(spaceGUID string) {
+			serviceCatalog.Metadata.Shareable = true
+			serviceCatalog.Plans = append(serviceCatalog.Plans, sharingPlan)
+			b = createDefaultBroker()
+
+			bindRequest.PlanID = sharingPlan.ID
+			bindRequest.RawContext = json.RawMessage(fmt.Sprintf(`{"platform": "cloudfoundry", "organization_guid": "consumer-org-guid", "space_guid": %q}`, spaceGUID))
+			bindResult, bindErr = b.Bind(context.Background(), instanceID, bindingID, bindRequest, false)
+		}
+
+		passedSharing := func() interface{} {
+			Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
+			_, _, _, _, passedRequestParameters, _, _, _ := serviceAdapter.CreateBindingArgsForCall(0)
+			return passedRequestParameters["sharing"]
+		}
+
+		It("tells the adapter when the binding is made from the owning space", func() {
+			bindFromSpace(ownerSpaceGUID)
+
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(passedSharing()).To(Equal(&broker.BindingSharing{
+				Shared:                   false,
+				OwnerSpaceGUID:           ownerSpaceGUID,
+				ConsumerSpaceGUID:        ownerSpaceGUID,
+				ConsumerOrganizationGUID: "consumer-org-guid",
+				Access:                   config.SharedBindingAccessReadWrite,
+			}))
+			instanceGUID, _ := cfClient.GetInstanceStateArgsForCall(0)
+			Expect(instanceGUID).To(Equal(instanceID))
+		})
+
+		It("tells the adapter when the binding is made from a space the instance is shared with", func() {
+			bindFromSpace("consumer-space-guid")
+
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(passedSharing()).To(Equal(&broker.BindingSharing{
+				Shared:                   true,
+				OwnerSpaceGUID:           ownerSpaceGUID,
+				ConsumerSpaceGUID:        "consumer-space-guid",
+				ConsumerOrganizationGUID: "consumer-org-guid",
+				Access:                   config.SharedBindingAccessReadWrite,
+			}))
+			Expect(logBuffer.String()).To(ContainSubstring("binding binding-id is made from space consumer-space-guid, which instance a-very-impressive-instance has been shared with, granting read-write access"))
+		})
+
+		It("grants shared bindings the access configured for the plan", func() {
+			sharingPlan.SharedBindingAccess = config.SharedBindingAccessReadOnly
+			bindFromSpace("consumer-space-guid")
+
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(passedSharing().(*broker.BindingSharing).Access).To(Equal(config.SharedBindingAccessReadOnly))
+		})
+
+		It("refuses shared bindings when the plan denies them", func() {
+			sharingPlan.SharedBindingAccess = config.SharedBindingAccessDenied
+			bindFromSpace("consumer-space-guid")
+
+			Expect(bindErr).To(MatchError("plan sharing-plan-name does not allow bindings from spaces the instance has been shared with"))
+			Expect(serviceAdapter.CreateBindingCallCount()).To(BeZero())
+		})
+
+		It("still allows bindings from the owning space when the plan denies shared bindings", func() {
+			sharingPlan.SharedBindingAccess = config.SharedBindingAccessDenied
+			bindFromSpace(ownerSpaceGUID)
+
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(passedSharing().(*broker.BindingSharing).Access).To(Equal(config.SharedBindingAccessReadWrite))
+		})
+
+		It("fails when the space of the instance cannot be retrieved", func() {
+			cfClient.GetInstanceStateReturns(cf.InstanceState{}, errors.New("cf unavailable"))
+			bindFromSpace("consumer-space-guid")
+
+			Expect(bindErr).To(HaveOccurred())
+			Expect(serviceAdapter.CreateBindingCallCount()).To(BeZero())
+			Expect(logBuffer.String()).To(ContainSubstring("error getting the space of instance a-very-impressive-instance from Cloud Foundry: cf unavailable"))
+		})
+
+		It("does not treat bindings as shared when the space of the instance is unknown", func() {
+			cfClient.GetInstanceStateReturns(cf.InstanceState{}, nil)
+			bindFromSpace("consumer-space-guid")
+
+			Expect(bindErr).NotTo(HaveOccurred())
+			Expect(passedSharing()).To(BeNil())
+		})
+	})
 })
 
 func generateBindRequestWithParams(params map[string]interface{}) brokerapi.BindDetails {
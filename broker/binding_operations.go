@@ -0,0 +1,127 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+)
+
+// finishedBindingOperationTTL is how long the outcome of a binding operation,
+// including the credentials of a created binding, is kept in memory.
+const finishedBindingOperationTTL = 10 * time.Minute
+
+// bindingOperationDeadline is how long after it started a binding operation
+// whose outcome the broker does not know is reported as failed. It is well
+// beyond the time the service adapter is given to create or delete a binding.
+const bindingOperationDeadline = time.Hour
+
+type bindingOperation struct {
+	operationType OperationType
+	state         brokerapi.LastOperationState
+	binding       brokerapi.Binding
+	err           error
+	finishedAt    time.Time
+}
+
+// bindingOperations tracks asynchronous bind and unbind requests that are
+// being processed by the service adapter in the background, in the broker
+// process. Running create-binding and delete-binding as a BOSH errand instead
+// is not supported, as an errand cannot hand the credentials it creates back
+// to the broker. The state is held in memory, so operations in flight are
+// lost when the broker restarts and asynchronous bindings require the broker
+// to run as a single instance. Their outcomes are also recorded in the
+// operation journal, when it is enabled, for LastBindingOperation to report
+// once they are no longer held here, and created bindings are kept in the
+// binding store, when there is one, before they are reported as created.
+//
+// Failures and unbinds are forgotten once LastBindingOperation has reported
+// them. Created bindings are served by GetBinding, however often it is
+// called, until they are forgotten after finishedBindingOperationTTL so that
+// credentials do not stay in memory.
+type bindingOperations struct {
+	lock       sync.Mutex
+	operations map[string]bindingOperation
+}
+
+// UseBindingStore makes the broker keep the binding created by each
+// asynchronous bind in store as soon as the service adapter has created it,
+// and remove it once an asynchronous unbind has succeeded.
+func (b *Broker) UseBindingStore(store BindingStore) {
+	b.bindingStore = store
+}
+
+func newBindingOperations() *bindingOperations {
+	return &bindingOperations{operations: map[string]bindingOperation{}}
+}
+
+func bindingOperationKey(instanceID, bindingID string) string {
+	return instanceID + "/" + bindingID
+}
+
+// start records a new in-progress operation for a binding. It returns false
+// when an operation for the same binding is still in progress.
+func (o *bindingOperations) start(instanceID, bindingID string, operationType OperationType) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.forgetExpired()
+
+	key := bindingOperationKey(instanceID, bindingID)
+	if operation, found := o.operations[key]; found && operation.state == brokerapi.InProgress {
+		return false
+	}
+
+	o.operations[key] = bindingOperation{operationType: operationType, state: brokerapi.InProgress}
+	return true
+}
+
+func (o *bindingOperations) finish(instanceID, bindingID string, binding brokerapi.Binding, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	key := bindingOperationKey(instanceID, bindingID)
+	operation, found := o.operations[key]
+	if !found {
+		return
+	}
+	operation.binding = binding
+	operation.err = err
+	operation.finishedAt = time.Now()
+	operation.state = brokerapi.Succeeded
+	if err != nil {
+		operation.state = brokerapi.Failed
+	}
+	o.operations[key] = operation
+}
+
+func (o *bindingOperations) get(instanceID, bindingID string) (bindingOperation, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.forgetExpired()
+
+	operation, found := o.operations[bindingOperationKey(instanceID, bindingID)]
+	return operation, found
+}
+
+func (o *bindingOperations) forget(instanceID, bindingID string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.operations, bindingOperationKey(instanceID, bindingID))
+}
+
+func (o *bindingOperations) forgetExpired() {
+	for key, operation := range o.operations {
+		if operation.state != brokerapi.InProgress && time.Since(operation.finishedAt) > finishedBindingOperationTTL {
+			delete(o.operations, key)
+		}
+	}
+}
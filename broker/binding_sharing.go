@@ -0,0 +1,77 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+)
+
+// sharingRequestParam is the key under which the sharing of a binding is
+// passed to the service adapter's create-binding, alongside the request's
+// parameters and context.
+const sharingRequestParam = "sharing"
+
+// BindingSharing describes where a binding is made from, relative to the space
+// that owns the instance, and what access the plan grants it.
+type BindingSharing struct {
+	Shared                   bool                       `json:"shared"`
+	OwnerSpaceGUID           string                     `json:"owner_space_guid"`
+	ConsumerSpaceGUID        string                     `json:"consumer_space_guid"`
+	ConsumerOrganizationGUID string                     `json:"consumer_organization_guid,omitempty"`
+	Access                   config.SharedBindingAccess `json:"access"`
+}
+
+type cloudFoundryBindContext struct {
+	Platform         string `json:"platform"`
+	OrganizationGUID string `json:"organization_guid"`
+	SpaceGUID        string `json:"space_guid"`
+}
+
+// bindingSharing works out whether a binding is made from a space the
+// instance has been shared with. Instances can only be shared when the
+// offering is shareable, and only Cloud Foundry sends the consumer's space, so
+// it returns nil otherwise. It also returns nil when the owning space is
+// unknown, as it is when Cloud Foundry integration is disabled. It fails when
+// Cloud Foundry cannot report the owning space, so that a binding from a space
+// the instance is shared with is never granted owner access.
+func (b *Broker) bindingSharing(ctx context.Context, instanceID string, details brokerapi.BindDetails, plan config.Plan, logger *log.Logger) (*BindingSharing, error) {
+	if !b.serviceOffering.Metadata.Shareable || len(details.RawContext) == 0 {
+		return nil, nil
+	}
+
+	var bindContext cloudFoundryBindContext
+	if err := json.Unmarshal(details.RawContext, &bindContext); err != nil {
+		return nil, NewGenericError(ctx, fmt.Errorf("bind context cannot be parsed: %s", err))
+	}
+	if bindContext.Platform != "cloudfoundry" || bindContext.SpaceGUID == "" {
+		return nil, nil
+	}
+
+	instanceState, err := b.cfClient.GetInstanceState(instanceID, logger)
+	if err != nil {
+		return nil, NewGenericError(ctx, fmt.Errorf("error getting the space of instance %s from Cloud Foundry: %s", instanceID, err))
+	}
+	if instanceState.SpaceGUID == "" {
+		logger.Printf("the space of instance %s is unknown, not treating the binding from space %s as shared\n", instanceID, bindContext.SpaceGUID)
+		return nil, nil
+	}
+
+	shared := instanceState.SpaceGUID != bindContext.SpaceGUID
+	return &BindingSharing{
+		Shared:                   shared,
+		OwnerSpaceGUID:           instanceState.SpaceGUID,
+		ConsumerSpaceGUID:        bindContext.SpaceGUID,
+		ConsumerOrganizationGUID: bindContext.OrganizationGUID,
+		Access:                   plan.BindingAccess(shared),
+	}, nil
+}
@@ -7,6 +7,7 @@
 package broker
 
 import (
+	"context"
 	"log"
 	"strings"
 	"sync"
@@ -18,30 +19,47 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
 type Broker struct {
-	boshClient     BoshClient
-	cfClient       CloudFoundryClient
-	adapterClient  ServiceAdapterClient
-	deployer       Deployer
-	secretManager  ManifestSecretManager
-	instanceLister service.InstanceLister
-	hasher         Hasher
-	deploymentLock *sync.Mutex
+	boshClient       BoshClient
+	cfClient         CloudFoundryClient
+	adapterClient    ServiceAdapterClient
+	deployer         Deployer
+	secretManager    ManifestSecretManager
+	instanceLister   service.InstanceLister
+	hasher           Hasher
+	operationJournal OperationJournal
+	parameterStore   ParameterStore
+	bindingStore     BindingStore
+	instanceLocks    *instanceLocks
+	pollLocks        *instanceLocks
+	quotaLock        sync.Mutex
+	quotaReserved    map[string]quotaReservation
 
 	serviceOffering         config.ServiceOffering
 	ExposeOperationalErrors bool
 	EnablePlanSchemas       bool
 	EnableSecureManifests   bool
 	DisableBoshConfigs      bool
+	EnableAsyncBindings     bool
+	RollbackFailedUpgrades  bool
+	Platform                string
 
-	loggerFactory *loggerfactory.LoggerFactory
-	catalogLock   sync.Mutex
-	cachedCatalog []brokerapi.Service
+	loggerFactory     *loggerfactory.LoggerFactory
+	catalogLock       sync.Mutex
+	cachedCatalog     []brokerapi.Service
+	planSchemasLock   sync.Mutex
+	cachedPlanSchemas map[string]brokerapi.ServiceSchemas
+	// planSchemasGeneration changes whenever the cached plan schemas are
+	// invalidated, so that schemas generated before are not cached.
+	planSchemasGeneration int
+	bindingOperations     *bindingOperations
+	operationWatcher      *operationWatcher
 }
 
 func New(
@@ -55,6 +73,7 @@ func New(
 	manifestSecretManager ManifestSecretManager,
 	instanceLister service.InstanceLister,
 	hasher Hasher,
+	operationJournal OperationJournal,
 	loggerFactory *loggerfactory.LoggerFactory,
 ) (*Broker, error) {
 	b := &Broker{
@@ -62,16 +81,24 @@ func New(
 		cfClient:                cfClient,
 		adapterClient:           serviceAdapter,
 		deployer:                deployer,
-		deploymentLock:          &sync.Mutex{},
+		instanceLocks:           newInstanceLocks(),
+		pollLocks:               newInstanceLocks(),
+		quotaReserved:           map[string]quotaReservation{},
 		serviceOffering:         serviceOffering,
 		ExposeOperationalErrors: brokerConfig.ExposeOperationalErrors,
 		EnablePlanSchemas:       brokerConfig.EnablePlanSchemas,
 		EnableSecureManifests:   brokerConfig.EnableSecureManifests,
 		DisableBoshConfigs:      brokerConfig.DisableBoshConfigs,
+		EnableAsyncBindings:     brokerConfig.EnableAsyncBindings,
+		RollbackFailedUpgrades:  brokerConfig.RollbackFailedUpgrades,
+		Platform:                brokerConfig.Platform,
 		secretManager:           manifestSecretManager,
 		instanceLister:          instanceLister,
 		hasher:                  hasher,
+		operationJournal:        operationJournal,
 		loggerFactory:           loggerFactory,
+		bindingOperations:       newBindingOperations(),
+		operationWatcher:        newOperationWatcher(),
 	}
 
 	var startupCheckErrMessages []string
@@ -90,7 +117,7 @@ func New(
 }
 
 func (b *Broker) processError(err error, logger *log.Logger) error {
-	logger.Println(err)
+	loggerfactory.Errorf(logger, "%s", err)
 	switch processedError := err.(type) {
 	case DisplayableError:
 		if b.ExposeOperationalErrors {
@@ -112,6 +139,8 @@ const (
 	OperationTypeDelete   = OperationType("delete")
 	OperationTypeBind     = OperationType("bind")
 	OperationTypeUnbind   = OperationType("unbind")
+	OperationTypeBackup   = OperationType("backup")
+	OperationTypeRestore  = OperationType("restore")
 
 	MinimumCFVersion                                     = "2.57.0"
 	MinimumMajorStemcellDirectorVersionForODB            = 3262
@@ -128,6 +157,70 @@ type OperationData struct {
 	PostDeployErrand PostDeployErrand // DEPRECATED: only needed for compatibility with ODB 0.20.x
 	PreDeleteErrand  PreDeleteErrand  // DEPRECATED: only needed for compatibility with ODB 0.20.x
 	Errands          []config.Errand  `json:",omitempty"`
+
+	// PreOperationErrandCount is how many of the Errands run before the
+	// upgrade or recreate is deployed. The rest run after it.
+	PreOperationErrandCount int `json:",omitempty"`
+
+	// PreviousPlanID is the plan an upgrade that waits for its pre-operation
+	// errands moves the deployment from, when it is not PlanID.
+	PreviousPlanID string `json:",omitempty"`
+
+	// RollbackOnFailure is set on upgrades the broker rolls back, under their
+	// BoshContextID, if they fail.
+	RollbackOnFailure bool `json:",omitempty"`
+
+	// StartedAt is when an asynchronous bind or unbind started, in seconds
+	// since the epoch.
+	StartedAt int64 `json:",omitempty"`
+}
+
+// DeploymentPreview holds unified diffs between what is deployed for an
+// instance and what an operation would deploy. ConfigDiffs is keyed by BOSH
+// config type.
+type DeploymentPreview struct {
+	ManifestDiff string            `json:"manifest_diff"`
+	ConfigDiffs  map[string]string `json:"config_diffs,omitempty"`
+}
+
+// DeploymentSnapshot is the manifest and BOSH configs, keyed by config type,
+// that are deployed for an instance. A failed upgrade is rolled back to the
+// snapshot taken before it started.
+type DeploymentSnapshot struct {
+	Manifest []byte
+	Configs  map[string]string
+}
+
+const (
+	PendingChangeAdded   = "added"
+	PendingChangeRemoved = "removed"
+	PendingChangeChanged = "changed"
+)
+
+// PendingChange is a single difference between what is deployed for an
+// instance and what the broker generates for it. Path is a slash separated
+// path into the manifest or, under configs/<type>, into a BOSH config, with
+// list entries identified by their name.
+type PendingChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// PendingChanges holds the differences between what is deployed for an
+// instance and what the broker would deploy for it on its current plan with
+// no new parameters. Only changes to the manifest block an update.
+type PendingChanges struct {
+	Changes      []PendingChange   `json:"changes"`
+	ManifestDiff string            `json:"manifest_diff"`
+	ConfigDiffs  map[string]string `json:"config_diffs,omitempty"`
+}
+
+func (p PendingChanges) String() string {
+	var changes []string
+	for _, change := range p.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s", change.Path, change.Change))
+	}
+	return strings.Join(changes, ", ")
 }
 
 type Errand struct {
@@ -151,14 +244,22 @@ type ManifestSecret struct {
 	Value interface{}
 }
 
-const InstancePrefix = "service-instance_"
+// InstancePrefix names the deployments of service offerings that do not set
+// their own deployment prefix.
+const InstancePrefix = config.DefaultDeploymentPrefix
 
-func deploymentName(instanceID string) string {
-	return InstancePrefix + instanceID
+func (b *Broker) deploymentName(instanceID string) string {
+	return b.serviceOffering.DeploymentNamePrefix() + instanceID
 }
 
-func instanceID(deploymentName string) string {
-	return strings.TrimPrefix(deploymentName, InstancePrefix)
+func (b *Broker) instanceID(deploymentName string) string {
+	return strings.TrimPrefix(deploymentName, b.serviceOffering.DeploymentNamePrefix())
+}
+
+// ownsDeployment reports whether a deployment is named like the instances of
+// the broker's service offering.
+func (b *Broker) ownsDeployment(deploymentName string) bool {
+	return strings.HasPrefix(deploymentName, b.serviceOffering.DeploymentNamePrefix())
 }
 
 //go:generate counterfeiter -o fakes/fake_startup_checker.go . StartupChecker
@@ -168,18 +269,24 @@ type StartupChecker interface {
 
 //go:generate counterfeiter -o fakes/fake_deployer.go . Deployer
 type Deployer interface {
-	Create(deploymentName, planID string, requestParams map[string]interface{}, boshContextID string, logger *log.Logger) (int, []byte, error)
-	Update(deploymentName, planID string, requestParams map[string]interface{}, previousPlanID *string, boshContextID string, secretsMap map[string]string, logger *log.Logger) (int, []byte, error)
-	Upgrade(deploymentName, planID string, previousPlanID *string, boshContextID string, logger *log.Logger) (int, []byte, error)
-	Recreate(deploymentName, planID, boshContextID string, logger *log.Logger) (int, error)
+	Create(ctx context.Context, deploymentName, planID string, requestParams map[string]interface{}, boshContextID string, logger *log.Logger) (int, []byte, error)
+	Update(ctx context.Context, deploymentName, planID string, requestParams map[string]interface{}, previousPlanID *string, boshContextID string, secretsMap map[string]string, logger *log.Logger) (int, []byte, error)
+	Upgrade(ctx context.Context, deploymentName, planID string, previousPlanID *string, boshContextID string, logger *log.Logger) (int, []byte, error)
+	Recreate(ctx context.Context, deploymentName, planID, boshContextID string, logger *log.Logger) (int, error)
+	RunErrand(ctx context.Context, deploymentName, errandName string, errandInstances []string, boshContextID string, logger *log.Logger) (int, error)
+	PreviewUpgrade(ctx context.Context, deploymentName, planID string, previousPlanID *string, logger *log.Logger) (DeploymentPreview, error)
+	PreviewUpdate(ctx context.Context, deploymentName, planID string, requestParams map[string]interface{}, previousPlanID *string, secretsMap map[string]string, logger *log.Logger) (DeploymentPreview, error)
+	PendingChanges(ctx context.Context, deploymentName, planID string, secretsMap map[string]string, logger *log.Logger) (PendingChanges, error)
+	Snapshot(deploymentName string, logger *log.Logger) (DeploymentSnapshot, error)
+	Rollback(ctx context.Context, deploymentName, boshContextID string, snapshot DeploymentSnapshot, logger *log.Logger) (int, error)
 }
 
 //go:generate counterfeiter -o fakes/fake_service_adapter_client.go . ServiceAdapterClient
 type ServiceAdapterClient interface {
-	CreateBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest []byte, requestParams map[string]interface{}, secretsMap, dnsAddresses map[string]string, logger *log.Logger) (serviceadapter.Binding, error)
-	DeleteBinding(bindingID string, deploymentTopology bosh.BoshVMs, manifest []byte, requestParams map[string]interface{}, secretsMap map[string]string, dnsAddresses map[string]string, logger *log.Logger) error
-	GenerateDashboardUrl(instanceID string, plan serviceadapter.Plan, manifest []byte, logger *log.Logger) (string, error)
-	GeneratePlanSchema(plan serviceadapter.Plan, logger *log.Logger) (brokerapi.ServiceSchemas, error)
+	CreateBinding(ctx context.Context, bindingID string, deploymentTopology bosh.BoshVMs, manifest []byte, requestParams map[string]interface{}, secretsMap, dnsAddresses map[string]string, logger *log.Logger) (serviceadapter.Binding, error)
+	DeleteBinding(ctx context.Context, bindingID string, deploymentTopology bosh.BoshVMs, manifest []byte, requestParams map[string]interface{}, secretsMap map[string]string, dnsAddresses map[string]string, logger *log.Logger) error
+	GenerateDashboardUrl(ctx context.Context, instanceID string, plan serviceadapter.Plan, manifest []byte, logger *log.Logger) (string, error)
+	GeneratePlanSchema(ctx context.Context, plan serviceadapter.Plan, logger *log.Logger) (brokerapi.ServiceSchemas, error)
 }
 
 //go:generate counterfeiter -o fakes/fake_bosh_client.go . BoshClient
@@ -201,6 +308,7 @@ type BoshClient interface {
 	GetConfigs(configName string, logger *log.Logger) ([]boshdirector.BoshConfig, error)
 	DeleteConfig(configType, configName string, logger *log.Logger) (bool, error)
 	DeleteConfigs(configName string, logger *log.Logger) error
+	GetTaskOutput(taskID int, logger *log.Logger) (boshdirector.BoshTaskOutput, error)
 }
 
 //go:generate counterfeiter -o fakes/fake_cloud_foundry_client.go . CloudFoundryClient
@@ -217,3 +325,41 @@ type CloudFoundryClient interface {
 type Hasher interface {
 	Hash(m map[string]string) string
 }
+
+//go:generate counterfeiter -o fakes/fake_operation_journal.go . OperationJournal
+type OperationJournal interface {
+	Record(operation operationjournal.Operation) error
+	Operations(instanceID string) ([]operationjournal.Operation, error)
+	AllOperations() ([]operationjournal.Operation, error)
+}
+
+// ParameterStore holds the arbitrary parameters of service instances, such as
+// the runtime CredHub, so that they are not written to the operation journal.
+//
+//go:generate counterfeiter -o fakes/fake_parameter_store.go . ParameterStore
+type ParameterStore interface {
+	Set(key string, value interface{}) error
+	Get(key string) (interface{}, error)
+	Delete(key string) error
+}
+
+// BindingStore keeps the bindings created by asynchronous binds, such as in
+// the runtime CredHub, so that they outlive the broker process.
+//
+//go:generate counterfeiter -o fakes/fake_binding_store.go . BindingStore
+type BindingStore interface {
+	StoreBinding(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, binding brokerapi.Binding) error
+	DeleteBinding(ctx context.Context, instanceID, bindingID, serviceID string) error
+}
+
+// InstanceRegistry is an instance lister the broker keeps up to date itself.
+// When the broker's instance lister is one, instances are registered as they
+// are provisioned and deregistered once their deployment has been deleted.
+//
+//go:generate counterfeiter -o fakes/fake_instance_registry.go . InstanceRegistry
+type InstanceRegistry interface {
+	service.InstanceLister
+	Register(instance service.Instance) error
+	Deregister(instanceID string) error
+	Reconcile(deployed []service.Instance) (added, removed []string, err error)
+}
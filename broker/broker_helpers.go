@@ -18,7 +18,7 @@ import (
 )
 
 func (b *Broker) getDeploymentInfo(instanceID string, ctx context.Context, action string, logger *log.Logger) ([]byte, bosh.BoshVMs, BrokerError) {
-	manifest, found, err := b.boshClient.GetDeployment(deploymentName(instanceID), logger)
+	manifest, found, err := b.boshClient.GetDeployment(b.deploymentName(instanceID), logger)
 	if err != nil {
 		return nil, nil, NewGenericError(ctx, fmt.Errorf("gathering deployment list %s", err))
 	}
@@ -26,7 +26,7 @@ func (b *Broker) getDeploymentInfo(instanceID string, ctx context.Context, actio
 		return nil, nil, NewDisplayableError(brokerapi.ErrInstanceDoesNotExist, fmt.Errorf("error %sing: instance %s, not found", action, instanceID))
 	}
 
-	vms, err := b.boshClient.VMs(deploymentName(instanceID), logger)
+	vms, err := b.boshClient.VMs(b.deploymentName(instanceID), logger)
 	if err != nil {
 		return nil, nil, NewGenericError(ctx, fmt.Errorf("gathering %sing info %s", action, err))
 	}
@@ -53,6 +53,14 @@ func convertDetailsToMap(details brokerapi.DetailsWithRawParameters) (map[string
 	return requestParams, nil
 }
 
+// requestPlatform is the platform named in the context of a request, as
+// converted by convertDetailsToMap.
+func requestPlatform(requestParams map[string]interface{}) string {
+	requestContext, _ := requestParams["context"].(map[string]interface{})
+	platform, _ := requestContext["platform"].(string)
+	return platform
+}
+
 func convertToMap(object interface{}) (map[string]interface{}, error) {
 	data, err := json.Marshal(object)
 	if err != nil {
@@ -46,19 +46,20 @@ const (
 )
 
 var (
-	b                  *broker.Broker
-	brokerCreationErr  error
-	boshClient         *fakes.FakeBoshClient
-	cfClient           *fakes.FakeCloudFoundryClient
-	serviceAdapter     *fakes.FakeServiceAdapterClient
-	fakeDeployer       *fakes.FakeDeployer
-	fakeInstanceLister *servicefakes.FakeInstanceLister
-	serviceCatalog     config.ServiceOffering
-	logBuffer          *bytes.Buffer
-	loggerFactory      *loggerfactory.LoggerFactory
-	brokerConfig       config.Broker
-	fakeSecretManager  *fakes.FakeManifestSecretManager
-	fakeMapHasher      *fakes.FakeHasher
+	b                    *broker.Broker
+	brokerCreationErr    error
+	boshClient           *fakes.FakeBoshClient
+	cfClient             *fakes.FakeCloudFoundryClient
+	serviceAdapter       *fakes.FakeServiceAdapterClient
+	fakeDeployer         *fakes.FakeDeployer
+	fakeInstanceLister   *servicefakes.FakeInstanceLister
+	serviceCatalog       config.ServiceOffering
+	logBuffer            *bytes.Buffer
+	loggerFactory        *loggerfactory.LoggerFactory
+	brokerConfig         config.Broker
+	fakeSecretManager    *fakes.FakeManifestSecretManager
+	fakeMapHasher        *fakes.FakeHasher
+	fakeOperationJournal *fakes.FakeOperationJournal
 
 	existingPlanServiceInstanceLimit    = 3
 	serviceOfferingServiceInstanceLimit = 5
@@ -294,6 +295,7 @@ var _ = BeforeEach(func() {
 	cfClient = new(fakes.FakeCloudFoundryClient)
 	fakeMapHasher = new(fakes.FakeHasher)
 	fakeMapHasher.HashStub = ReturnSameValueHasher
+	fakeOperationJournal = new(fakes.FakeOperationJournal)
 	cfClient.GetAPIVersionReturns("2.57.0", nil)
 
 	serviceCatalog = config.ServiceOffering{
@@ -363,6 +365,7 @@ func createBrokerWithAdapter(serviceAdapter *fakes.FakeServiceAdapterClient) *br
 		fakeSecretManager,
 		fakeInstanceLister,
 		fakeMapHasher,
+		fakeOperationJournal,
 		loggerFactory,
 	)
 
@@ -384,6 +387,7 @@ func createBrokerWithServiceCatalog(catalog config.ServiceOffering) *broker.Brok
 		fakeSecretManager,
 		fakeInstanceLister,
 		fakeMapHasher,
+		fakeOperationJournal,
 		loggerFactory,
 	)
 
@@ -407,6 +411,7 @@ func createBroker(startupCheckers []broker.StartupChecker, overrideClient ...bro
 		fakeSecretManager,
 		fakeInstanceLister,
 		fakeMapHasher,
+		fakeOperationJournal,
 		loggerFactory,
 	)
 }
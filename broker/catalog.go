@@ -9,6 +9,7 @@ package broker
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
@@ -58,7 +59,7 @@ func (b *Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 		}
 
 		if b.EnablePlanSchemas {
-			planSchema, err := b.adapterClient.GeneratePlanSchema(plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+			planSchema, err := b.planSchemas(ctx, plan, logger)
 			if err != nil {
 				if _, ok := err.(serviceadapter.NotImplementedError); !ok {
 					return []brokerapi.Service{}, err
@@ -90,12 +91,13 @@ func (b *Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 
 	b.cachedCatalog = []brokerapi.Service{
 		{
-			ID:            b.serviceOffering.ID,
-			Name:          b.serviceOffering.Name,
-			Description:   b.serviceOffering.Description,
-			Bindable:      b.serviceOffering.Bindable,
-			PlanUpdatable: b.serviceOffering.PlanUpdatable,
-			Plans:         servicePlans,
+			ID:                   b.serviceOffering.ID,
+			Name:                 b.serviceOffering.Name,
+			Description:          b.serviceOffering.Description,
+			Bindable:             b.serviceOffering.Bindable,
+			PlanUpdatable:        b.serviceOffering.PlanUpdatable,
+			Plans:                servicePlans,
+			InstancesRetrievable: b.instancesRetrievable(),
 			Metadata: &brokerapi.ServiceMetadata{
 				DisplayName:         b.serviceOffering.Metadata.DisplayName,
 				ImageUrl:            b.serviceOffering.Metadata.ImageURL,
@@ -114,6 +116,60 @@ func (b *Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 	return b.cachedCatalog, nil
 }
 
+// instancesRetrievable is whether GetInstance can report the parameters of
+// instances, which are only known when each create and update records them in
+// the parameter store and the operation journal.
+func (b *Broker) instancesRetrievable() bool {
+	return b.operationJournal != nil && b.parameterStore != nil
+}
+
+// InvalidateCatalog clears the cached catalog and plan schemas, so that they
+// are generated afresh the next time they are needed. The broker calls it
+// when it receives SIGHUP.
+func (b *Broker) InvalidateCatalog() {
+	b.catalogLock.Lock()
+	defer b.catalogLock.Unlock()
+	b.planSchemasLock.Lock()
+	defer b.planSchemasLock.Unlock()
+
+	b.cachedCatalog = nil
+	b.cachedPlanSchemas = nil
+	b.planSchemasGeneration++
+}
+
+// planSchemas returns the schemas the service adapter generates for the plan.
+// They only depend on the plan's config, so are generated once per plan and
+// cached until InvalidateCatalog is called. The adapter is run without holding
+// the lock, so requests for the same plan that miss the cache at the same time
+// may each generate its schemas.
+func (b *Broker) planSchemas(ctx context.Context, plan config.Plan, logger *log.Logger) (brokerapi.ServiceSchemas, error) {
+	b.planSchemasLock.Lock()
+	schemas, found := b.cachedPlanSchemas[plan.ID]
+	generation := b.planSchemasGeneration
+	b.planSchemasLock.Unlock()
+
+	if found {
+		return schemas, nil
+	}
+
+	schemas, err := b.adapterClient.GeneratePlanSchema(ctx, plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+	if err != nil {
+		return brokerapi.ServiceSchemas{}, err
+	}
+
+	b.planSchemasLock.Lock()
+	defer b.planSchemasLock.Unlock()
+
+	if generation != b.planSchemasGeneration {
+		return schemas, nil
+	}
+	if b.cachedPlanSchemas == nil {
+		b.cachedPlanSchemas = map[string]brokerapi.ServiceSchemas{}
+	}
+	b.cachedPlanSchemas[plan.ID] = schemas
+	return schemas, nil
+}
+
 func copyMap(dst, src map[string]string) {
 	for key, value := range src {
 		dst[key] = value
@@ -23,6 +23,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/noopservicescontroller"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
@@ -125,6 +126,7 @@ var _ = Describe("Catalog", func() {
 		serviceAdapter.GeneratePlanSchemaReturns(brokerapi.ServiceSchemas{}, serviceadapter.NewNotImplementedError("not implemented"))
 		b, brokerCreationErr = createBroker([]broker.StartupChecker{}, noopservicescontroller.New())
 		Expect(brokerCreationErr).NotTo(HaveOccurred())
+		b.UseParameterStore(new(fakes.FakeParameterStore))
 
 		contextWithoutRequestID := context.Background()
 		services, err := b.Services(contextWithoutRequestID)
@@ -134,11 +136,12 @@ var _ = Describe("Catalog", func() {
 
 		Expect(services).To(Equal([]brokerapi.Service{
 			{
-				ID:            serviceCatalog.ID,
-				Name:          serviceCatalog.Name,
-				Description:   serviceCatalog.Description,
-				Bindable:      serviceCatalog.Bindable,
-				PlanUpdatable: serviceCatalog.PlanUpdatable,
+				ID:                   serviceCatalog.ID,
+				Name:                 serviceCatalog.Name,
+				Description:          serviceCatalog.Description,
+				Bindable:             serviceCatalog.Bindable,
+				PlanUpdatable:        serviceCatalog.PlanUpdatable,
+				InstancesRetrievable: true,
 				Metadata: &brokerapi.ServiceMetadata{
 					DisplayName:         serviceCatalog.Metadata.DisplayName,
 					ImageUrl:            serviceCatalog.Metadata.DisplayName,
@@ -157,6 +160,16 @@ var _ = Describe("Catalog", func() {
 		Expect(serviceAdapter.GeneratePlanSchemaCallCount()).To(BeZero())
 	})
 
+	It("does not advertise instances as retrievable when their parameters are not stored", func() {
+		serviceAdapter.GeneratePlanSchemaReturns(brokerapi.ServiceSchemas{}, serviceadapter.NewNotImplementedError("not implemented"))
+		b, brokerCreationErr = createBroker([]broker.StartupChecker{}, noopservicescontroller.New())
+		Expect(brokerCreationErr).NotTo(HaveOccurred())
+
+		services, err := b.Services(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(services[0].InstancesRetrievable).To(BeFalse())
+	})
+
 	It("includes the plan cost", func() {
 		serviceCatalog.Plans[0].Metadata.Costs = []config.PlanCost{
 			{Unit: "dogecoins", Amount: map[string]float64{"value": 1.65}},
@@ -361,6 +374,32 @@ var _ = Describe("Catalog", func() {
 
 	})
 
+	It("generates the catalog and plan schemas again once the catalog is invalidated", func() {
+		serviceAdapter.GeneratePlanSchemaReturns(brokerapi.ServiceSchemas{
+			Instance: brokerapi.ServiceInstanceSchema{
+				Create: createSchema,
+				Update: updateSchema,
+			},
+			Binding: brokerapi.ServiceBindingSchema{
+				Create: bindingSchema,
+			},
+		}, nil)
+		b, brokerCreationErr = createBroker([]broker.StartupChecker{}, noopservicescontroller.New())
+		Expect(brokerCreationErr).NotTo(HaveOccurred())
+		b.EnablePlanSchemas = true
+
+		services, err := b.Services(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serviceAdapter.GeneratePlanSchemaCallCount()).To(Equal(len(services[0].Plans)))
+
+		b.InvalidateCatalog()
+
+		servicesII, err := b.Services(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(servicesII).To(Equal(services))
+		Expect(serviceAdapter.GeneratePlanSchemaCallCount()).To(Equal(2 * len(services[0].Plans)))
+	})
+
 	DescribeTable("when the generated schema is invalid",
 		func(create, update, binding brokerapi.Schema, errorLabel string) {
 			planSchema := brokerapi.ServiceSchemas{
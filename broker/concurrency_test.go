@@ -0,0 +1,250 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+)
+
+var _ = Describe("Concurrent requests", func() {
+	var (
+		releaseFirstDeploy chan struct{}
+		firstDeployStarted chan struct{}
+	)
+
+	provision := func(instanceID string) error {
+		_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+			PlanID:    existingPlanID,
+			ServiceID: serviceOfferingID,
+		}, true)
+		return err
+	}
+
+	provisionInBackground := func(instanceID string) chan error {
+		result := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			result <- provision(instanceID)
+		}()
+		return result
+	}
+
+	BeforeEach(func() {
+		releaseFirstDeploy = make(chan struct{})
+		firstDeployStarted = make(chan struct{})
+
+		boshClient.GetDeploymentReturns(nil, false, nil)
+		cfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+			cfServicePlan("1234", existingPlanID, "url", "name"): 0,
+		}, nil)
+
+		fakeDeployer.CreateStub = func(ctx context.Context, deploymentName, planID string, requestParams map[string]interface{}, boshContextID string, logger *log.Logger) (int, []byte, error) {
+			if deploymentName == "service-instance_first" {
+				close(firstDeployStarted)
+				<-releaseFirstDeploy
+			}
+			return 42, []byte("name: " + deploymentName), nil
+		}
+	})
+
+	AfterEach(func() {
+		fakeDeployer.CreateStub = nil
+	})
+
+	It("provisions different instances concurrently", func() {
+		b = createDefaultBroker()
+
+		first := provisionInBackground("first")
+		Eventually(firstDeployStarted).Should(BeClosed())
+
+		Expect(provision("second")).To(Succeed())
+
+		close(releaseFirstDeploy)
+		Eventually(first).Should(Receive(BeNil()))
+	})
+
+	It("serialises operations on the same instance", func() {
+		b = createDefaultBroker()
+
+		first := provisionInBackground("first")
+		Eventually(firstDeployStarted).Should(BeClosed())
+
+		deprovisioned := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			_, err := b.Deprovision(context.Background(), "first", brokerapi.DeprovisionDetails{PlanID: existingPlanID}, true)
+			deprovisioned <- err
+		}()
+		Consistently(deprovisioned).ShouldNot(Receive())
+
+		close(releaseFirstDeploy)
+		Eventually(first).Should(Receive(BeNil()))
+		Eventually(deprovisioned).Should(Receive())
+	})
+
+	It("serialises the deploy that follows pre-operation errands with other operations on the instance", func() {
+		b = createDefaultBroker()
+		boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{
+			{ID: 1, State: boshdirector.TaskDone, Description: "run errand backup from deployment service-instance_first"},
+		}, nil)
+		fakeDeployer.UpgradeReturns(2, nil, nil)
+
+		first := provisionInBackground("first")
+		Eventually(firstDeployStarted).Should(BeClosed())
+
+		polled := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			_, err := b.LastOperation(context.Background(), "first", brokerapi.PollDetails{
+				OperationData: fmt.Sprintf(`{"BoshTaskID": 1, "OperationType": "upgrade", "BoshContextID": "some-context-id", "PlanID": %q, "Errands": [{"Name": "backup"}], "PreOperationErrandCount": 1}`, existingPlanID),
+			})
+			polled <- err
+		}()
+		Consistently(fakeDeployer.UpgradeCallCount).Should(BeZero())
+
+		close(releaseFirstDeploy)
+		Eventually(first).Should(Receive(BeNil()))
+		Eventually(polled).Should(Receive(BeNil()))
+		Expect(fakeDeployer.UpgradeCallCount()).To(Equal(1))
+	})
+
+	It("counts instances still being provisioned against the quotas", func() {
+		limit := 2
+		plan := existingPlan
+		plan.Quotas = config.Quotas{ServiceInstanceLimit: &limit}
+		catalog := serviceCatalog
+		catalog.Plans = config.Plans{plan, secondPlan}
+		b = createBrokerWithServiceCatalog(catalog)
+		cfClient.GetInstanceStateReturns(cf.InstanceState{}, errors.New("instance not found"))
+
+		first := provisionInBackground("first")
+		Eventually(firstDeployStarted).Should(BeClosed())
+
+		Expect(provision("second")).To(Succeed())
+
+		err := provision("third")
+		Expect(err).To(HaveOccurred())
+		Expect(logBuffer.String()).To(ContainSubstring("plan instance limit exceeded for service ID: %s. Total instances: 2", serviceOfferingID))
+
+		close(releaseFirstDeploy)
+		Eventually(first).Should(Receive(BeNil()))
+
+		By("holding the reservations until the platform counts the instances")
+		logBuffer.Reset()
+		Expect(provision("third")).NotTo(Succeed())
+		Expect(logBuffer.String()).To(ContainSubstring("Total instances: 2"))
+
+		By("not counting an instance twice once the platform reports it")
+		cfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+			cfServicePlan("1234", existingPlanID, "url", "name"): 1,
+		}, nil)
+		logBuffer.Reset()
+		Expect(provision("third")).NotTo(Succeed())
+		Expect(logBuffer.String()).To(ContainSubstring("Total instances: 2"))
+		Expect(cfClient.GetInstanceStateCallCount()).To(BeZero())
+
+		cfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+			cfServicePlan("1234", existingPlanID, "url", "name"): 2,
+		}, nil)
+		logBuffer.Reset()
+		Expect(provision("third")).NotTo(Succeed())
+		Expect(logBuffer.String()).To(ContainSubstring("Total instances: 2"))
+
+		By("making room once the platform counts fewer instances")
+		cfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+			cfServicePlan("1234", existingPlanID, "url", "name"): 1,
+		}, nil)
+		Expect(provision("third")).To(Succeed())
+	})
+
+	It("does not hold up other quota checks while it asks the platform for its count", func() {
+		limit := 3
+		plan := existingPlan
+		plan.Quotas = config.Quotas{ServiceInstanceLimit: &limit}
+		catalog := serviceCatalog
+		catalog.Plans = config.Plans{plan, secondPlan}
+		b = createBrokerWithServiceCatalog(catalog)
+		fakeDeployer.CreateStub = nil
+		fakeDeployer.CreateReturns(42, []byte("name: service-instance"), nil)
+		Expect(provision("first")).To(Succeed())
+
+		countingInstances := make(chan struct{})
+		releaseCount := make(chan struct{})
+		firstCount := make(chan bool, 1)
+		firstCount <- true
+		cfClient.CountInstancesOfServiceOfferingStub = func(serviceOfferingID string, logger *log.Logger) (map[cf.ServicePlan]int, error) {
+			select {
+			case <-firstCount:
+				close(countingInstances)
+				<-releaseCount
+			default:
+			}
+			return map[cf.ServicePlan]int{}, nil
+		}
+
+		second := provisionInBackground("second")
+		Eventually(countingInstances).Should(BeClosed())
+
+		third := provisionInBackground("third")
+		Eventually(third).Should(Receive(BeNil()))
+
+		close(releaseCount)
+		Eventually(second).Should(Receive(BeNil()))
+	})
+
+	It("releases the reservation when the provision fails", func() {
+		limit := 1
+		plan := existingPlan
+		plan.Quotas = config.Quotas{ServiceInstanceLimit: &limit}
+		catalog := serviceCatalog
+		catalog.Plans = config.Plans{plan, secondPlan}
+		b = createBrokerWithServiceCatalog(catalog)
+		cfClient.GetInstanceStateReturns(cf.InstanceState{}, errors.New("instance not found"))
+		fakeDeployer.CreateStub = nil
+		fakeDeployer.CreateReturnsOnCall(0, 0, nil, errors.New("generate-manifest failed"))
+		fakeDeployer.CreateReturnsOnCall(1, 42, []byte("name: service-instance_second"), nil)
+
+		Expect(provision("first")).NotTo(Succeed())
+		Expect(provision("second")).To(Succeed())
+	})
+
+	It("releases the reservation when the platform is told the create failed", func() {
+		limit := 1
+		plan := existingPlan
+		plan.Quotas = config.Quotas{ServiceInstanceLimit: &limit}
+		catalog := serviceCatalog
+		catalog.Plans = config.Plans{plan, secondPlan}
+		b = createBrokerWithServiceCatalog(catalog)
+		cfClient.GetInstanceStateReturns(cf.InstanceState{}, errors.New("instance not found"))
+		fakeDeployer.CreateStub = nil
+		fakeDeployer.CreateReturns(42, []byte("name: service-instance_first"), nil)
+
+		Expect(provision("first")).To(Succeed())
+		Expect(provision("second")).NotTo(Succeed())
+
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskError}, nil)
+		operationData, err := json.Marshal(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeCreate})
+		Expect(err).NotTo(HaveOccurred())
+		lastOperation, err := b.LastOperation(context.Background(), "first", brokerapi.PollDetails{OperationData: string(operationData)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastOperation.State).To(Equal(brokerapi.Failed))
+
+		Expect(provision("second")).To(Succeed())
+	})
+})
@@ -10,8 +10,60 @@ import (
 	"log"
 
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
 )
 
+// CountInstancesOfPlans counts the instances of each plan of the service
+// offering. When the broker serves another platform than Cloud Foundry they
+// are counted with the instance lister, and every plan in the catalog is
+// included, even those with no instances.
 func (b *Broker) CountInstancesOfPlans(logger *log.Logger) (map[cf.ServicePlan]int, error) {
-	return b.cfClient.CountInstancesOfServiceOffering(b.serviceOffering.ID, logger)
+	if !b.listsInstances("") {
+		return b.cfClient.CountInstancesOfServiceOffering(b.serviceOffering.ID, logger)
+	}
+
+	planCounts, err := b.countListedInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[cf.ServicePlan]int{}
+	for _, plan := range b.serviceOffering.Plans {
+		servicePlan := cf.ServicePlan{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: plan.ID, Name: plan.Name}}
+		counts[servicePlan] = planCounts[plan.ID]
+	}
+	return counts, nil
+}
+
+// listsInstances reports whether instances are counted with the instance
+// lister rather than Cloud Foundry, which is when the broker or the request
+// comes from another platform. Cloud Foundry does not know about instances
+// provisioned from kubernetes.
+func (b *Broker) listsInstances(requestPlatform string) bool {
+	return (b.Platform != "" && b.Platform != config.PlatformCloudFoundry) || requestPlatform == config.PlatformKubernetes
+}
+
+func (b *Broker) countInstancesByPlanID(listed bool, logger *log.Logger) (map[string]int, error) {
+	if listed {
+		return b.countListedInstances()
+	}
+
+	cfPlanCounts, err := b.cfClient.CountInstancesOfServiceOffering(b.serviceOffering.ID, logger)
+	if err != nil {
+		return nil, err
+	}
+	return convertCfPlanCounts(cfPlanCounts), nil
+}
+
+func (b *Broker) countListedInstances() (map[string]int, error) {
+	instances, err := b.instanceLister.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	planCounts := map[string]int{}
+	for _, instance := range instances {
+		planCounts[instance.PlanUniqueID]++
+	}
+	return planCounts, nil
 }
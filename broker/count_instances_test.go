@@ -12,6 +12,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
 )
 
 var _ = Describe("counting instances of a service offering by plan", func() {
@@ -37,4 +39,38 @@ var _ = Describe("counting instances of a service offering by plan", func() {
 		_, err := b.CountInstancesOfPlans(logger)
 		Expect(err).To(MatchError("Something bad happened"))
 	})
+
+	Context("when the broker serves kubernetes", func() {
+		BeforeEach(func() {
+			brokerConfig.Platform = config.PlatformKubernetes
+			b = createDefaultBroker()
+		})
+
+		It("counts the instances of every plan with the instance lister", func() {
+			fakeInstanceLister.InstancesReturns([]service.Instance{
+				{GUID: "instance-1", PlanUniqueID: existingPlanID},
+				{GUID: "instance-2", PlanUniqueID: existingPlanID},
+			}, nil)
+
+			counts, err := b.CountInstancesOfPlans(loggerFactory.NewWithRequestID())
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(counts).To(HaveLen(len(serviceCatalog.Plans)))
+			Expect(counts).To(HaveKeyWithValue(
+				cf.ServicePlan{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: existingPlanID, Name: existingPlanName}}, 2,
+			))
+			Expect(counts).To(HaveKeyWithValue(
+				cf.ServicePlan{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: secondPlanID, Name: secondPlan.Name}}, 0,
+			))
+			Expect(cfClient.CountInstancesOfServiceOfferingCallCount()).To(BeZero())
+		})
+
+		It("returns an error when the instances cannot be listed", func() {
+			fakeInstanceLister.InstancesReturns(nil, errors.New("instances unavailable"))
+
+			_, err := b.CountInstancesOfPlans(loggerFactory.NewWithRequestID())
+
+			Expect(err).To(MatchError("instances unavailable"))
+		})
+	})
 })
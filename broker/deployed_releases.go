@@ -0,0 +1,33 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"log"
+
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+)
+
+// DeployedReleases returns the releases the currently deployed manifest of
+// each instance uses, by instance ID. The director lists them along with the
+// deployments, so all instances are covered by a single request.
+func (b *Broker) DeployedReleases(logger *log.Logger) (map[string][]bosh.Release, error) {
+	deployments, err := b.boshClient.GetDeployments(logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error getting deployments: %s", err)
+		return nil, b.processError(err, logger)
+	}
+
+	releases := map[string][]bosh.Release{}
+	for _, deployment := range deployments {
+		if b.ownsDeployment(deployment.Name) {
+			releases[b.instanceID(deployment.Name)] = deployment.Releases
+		}
+	}
+	return releases, nil
+}
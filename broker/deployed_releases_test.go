@@ -0,0 +1,53 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+)
+
+var _ = Describe("Deployed Releases", func() {
+	var logger *log.Logger
+
+	BeforeEach(func() {
+		logger = loggerFactory.NewWithRequestID()
+		b = createDefaultBroker()
+	})
+
+	It("returns the releases of each instance's deployment", func() {
+		boshClient.GetDeploymentsReturns([]boshdirector.Deployment{
+			{Name: "service-instance_one", Releases: []bosh.Release{{Name: "redis", Version: "1.2.3"}, {Name: "syslog", Version: "11"}}},
+			{Name: "service-instance_two", Releases: []bosh.Release{{Name: "redis", Version: "1.2.4"}}},
+			{Name: "cf", Releases: []bosh.Release{{Name: "capi", Version: "1.0.0"}}},
+		}, nil)
+
+		releases, err := b.DeployedReleases(logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases).To(Equal(map[string][]bosh.Release{
+			"one": {{Name: "redis", Version: "1.2.3"}, {Name: "syslog", Version: "11"}},
+			"two": {{Name: "redis", Version: "1.2.4"}},
+		}))
+		Expect(boshClient.GetDeploymentsCallCount()).To(Equal(1))
+		Expect(boshClient.GetDeploymentCallCount()).To(BeZero())
+	})
+
+	It("returns an error when the deployments cannot be listed", func() {
+		boshClient.GetDeploymentsReturns(nil, errors.New("bosh is down"))
+
+		_, err := b.DeployedReleases(logger)
+
+		Expect(err).To(HaveOccurred())
+		Expect(logBuffer.String()).To(ContainSubstring("error getting deployments: bosh is down"))
+	})
+})
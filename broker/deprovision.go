@@ -27,10 +27,10 @@ func (b *Broker) Deprovision(
 	asyncAllowed bool,
 ) (brokerapi.DeprovisionServiceSpec, error) {
 
-	b.deploymentLock.Lock()
-	defer b.deploymentLock.Unlock()
+	defer b.instanceLocks.acquire(instanceID)()
 	requestID := uuid.New()
 	ctx = brokercontext.New(ctx, string(OperationTypeDelete), requestID, b.serviceOffering.Name, instanceID)
+	ctx = brokercontext.WithPlanID(ctx, deprovisionDetails.PlanID)
 	logger := b.loggerFactory.NewWithContext(ctx)
 
 	if !asyncAllowed {
@@ -66,17 +66,20 @@ func (b *Broker) Deprovision(
 			err = secretsErr
 		}
 
+		b.deregisterInstance(instanceID, logger)
+
 		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, b.processError(err, logger)
 	}
 
 	if err := b.assertNoOperationsInProgress(ctx, instanceID, logger); err != nil {
 		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, b.processError(err, logger)
 	}
+	b.releaseQuota(instanceID)
 
 	plan, found := b.serviceOffering.FindPlanByID(deprovisionDetails.PlanID)
 	if found {
 		if errands := plan.PreDeleteErrands(); len(errands) != 0 {
-			serviceSpec, err := b.runPreDeleteErrands(ctx, instanceID, errands, logger)
+			serviceSpec, err := b.runPreDeleteErrands(ctx, instanceID, plan.ID, errands, logger)
 			return serviceSpec, b.processError(err, logger)
 		}
 	}
@@ -86,10 +89,10 @@ func (b *Broker) Deprovision(
 }
 
 func (b *Broker) deleteConfigsForNotFoundInstance(ctx context.Context, instanceID string, logger *log.Logger) error {
-	if err := b.boshClient.DeleteConfigs(deploymentName(instanceID), logger); err != nil {
+	if err := b.boshClient.DeleteConfigs(b.deploymentName(instanceID), logger); err != nil {
 		operatorError := NewGenericError(
 			ctx,
-			fmt.Errorf("error deprovisioning: failed to delete configs for instance %s: %s", deploymentName(instanceID), err),
+			fmt.Errorf("error deprovisioning: failed to delete configs for instance %s: %s", b.deploymentName(instanceID), err),
 		)
 		return NewDisplayableError(errors.New("Unable to delete service. Please try again later or contact your operator."), operatorError)
 	}
@@ -102,7 +105,7 @@ func (b *Broker) clearSecretsForNotFoundInstance(ctx context.Context, instanceID
 		userError := errors.New("Unable to delete service. Please try again later or contact your operator.")
 		operatorError := NewGenericError(
 			ctx,
-			fmt.Errorf("error deprovisioning: failed to delete secrets for instance %s: %s", deploymentName(instanceID), err),
+			fmt.Errorf("error deprovisioning: failed to delete secrets for instance %s: %s", b.deploymentName(instanceID), err),
 		)
 		return NewDisplayableError(userError, operatorError)
 	}
@@ -110,7 +113,7 @@ func (b *Broker) clearSecretsForNotFoundInstance(ctx context.Context, instanceID
 }
 
 func (b *Broker) assertDeploymentExists(ctx context.Context, instanceID string, logger *log.Logger) (bool, error) {
-	_, deploymentFound, err := b.boshClient.GetDeployment(deploymentName(instanceID), logger)
+	_, deploymentFound, err := b.boshClient.GetDeployment(b.deploymentName(instanceID), logger)
 
 	switch err.(type) {
 	case boshdirector.RequestError:
@@ -118,7 +121,7 @@ func (b *Broker) assertDeploymentExists(ctx context.Context, instanceID string,
 	case error:
 		return false, NewGenericError(
 			ctx,
-			fmt.Errorf("error deprovisioning: cannot get deployment %s: %s", deploymentName(instanceID), err),
+			fmt.Errorf("error deprovisioning: cannot get deployment %s: %s", b.deploymentName(instanceID), err),
 		)
 	}
 
@@ -127,14 +130,14 @@ func (b *Broker) assertDeploymentExists(ctx context.Context, instanceID string,
 
 func (b *Broker) assertNoOperationsInProgress(ctx context.Context, instanceID string, logger *log.Logger) error {
 
-	tasks, err := b.boshClient.GetTasks(deploymentName(instanceID), logger)
+	tasks, err := b.boshClient.GetTasks(b.deploymentName(instanceID), logger)
 	switch err.(type) {
 	case boshdirector.RequestError:
 		return NewBoshRequestError("delete", err)
 	case error:
 		return NewGenericError(
 			ctx,
-			fmt.Errorf("error deprovisioning: cannot get tasks for deployment %s: %s\n", deploymentName(instanceID), err),
+			fmt.Errorf("error deprovisioning: cannot get tasks for deployment %s: %s\n", b.deploymentName(instanceID), err),
 		)
 	}
 
@@ -143,7 +146,7 @@ func (b *Broker) assertNoOperationsInProgress(ctx context.Context, instanceID st
 		userError := errors.New("An operation is in progress for your service instance. Please try again later.")
 		operatorError := NewOperationInProgressError(
 			fmt.Errorf("error deprovisioning: deployment %s is still in progress: tasks %s\n",
-				deploymentName(instanceID),
+				b.deploymentName(instanceID),
 				incompleteTasks.ToLog()),
 		)
 		return NewDisplayableError(userError, operatorError)
@@ -155,15 +158,33 @@ func (b *Broker) assertNoOperationsInProgress(ctx context.Context, instanceID st
 func (b *Broker) runPreDeleteErrands(
 	ctx context.Context,
 	instanceID string,
+	planID string,
 	preDeleteErrands []config.Errand,
 	logger *log.Logger,
 ) (brokerapi.DeprovisionServiceSpec, error) {
+	operationData, err := b.startPreDeleteErrands(ctx, instanceID, planID, preDeleteErrands, logger)
+	if err != nil {
+		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, err
+	}
+
+	operationDataJSON, err := json.Marshal(operationData)
+	if err != nil {
+		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(ctx, err)
+	}
+
+	return brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: string(operationDataJSON)}, nil
+}
+
+// startPreDeleteErrands runs the first pre-delete errand, leaving the lifecycle
+// runner to run the rest and then delete the deployment as LastOperation is
+// polled.
+func (b *Broker) startPreDeleteErrands(ctx context.Context, instanceID, planID string, preDeleteErrands []config.Errand, logger *log.Logger) (OperationData, error) {
 	logger.Printf("running pre-delete errand for instance %s\n", instanceID)
 
 	boshContextID := uuid.New()
 
 	taskID, err := b.boshClient.RunErrand(
-		deploymentName(instanceID),
+		b.deploymentName(instanceID),
 		preDeleteErrands[0].Name,
 		preDeleteErrands[0].Instances,
 		boshContextID,
@@ -171,21 +192,18 @@ func (b *Broker) runPreDeleteErrands(
 		boshdirector.NewAsyncTaskReporter(),
 	)
 	if err != nil {
-		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(ctx, err)
+		return OperationData{}, NewGenericError(ctx, err)
 	}
 
-	operationData, err := json.Marshal(OperationData{
+	operationData := OperationData{
 		OperationType: OperationTypeDelete,
 		BoshTaskID:    taskID,
 		BoshContextID: boshContextID,
 		Errands:       preDeleteErrands,
-	})
-
-	if err != nil {
-		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(ctx, err)
 	}
+	b.recordStartedOperation(ctx, instanceID, planID, operationData, logger)
 
-	return brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: string(operationData)}, nil
+	return operationData, nil
 }
 
 func (b *Broker) deleteInstance(
@@ -194,13 +212,31 @@ func (b *Broker) deleteInstance(
 	planConfig config.Plan,
 	logger *log.Logger,
 ) (brokerapi.DeprovisionServiceSpec, error) {
+	operationData, err := b.startDeleteDeployment(ctx, instanceID, planConfig.ID, logger)
+	if err != nil {
+		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, err
+	}
+
+	operationDataJSON, err := json.Marshal(operationData)
+
+	if err != nil {
+		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(brokercontext.WithBoshTaskID(ctx, operationData.BoshTaskID), err)
+	}
+
+	return brokerapi.DeprovisionServiceSpec{
+		IsAsync:       true,
+		OperationData: string(operationDataJSON),
+	}, nil
+}
+
+func (b *Broker) startDeleteDeployment(ctx context.Context, instanceID, planID string, logger *log.Logger) (OperationData, error) {
 	logger.Printf("deleting deployment for instance %s\n", instanceID)
-	taskID, err := b.boshClient.DeleteDeployment(deploymentName(instanceID), fmt.Sprintf("delete-%s", instanceID), logger, boshdirector.NewAsyncTaskReporter())
+	taskID, err := b.boshClient.DeleteDeployment(b.deploymentName(instanceID), fmt.Sprintf("delete-%s", instanceID), logger, boshdirector.NewAsyncTaskReporter())
 	switch err.(type) {
 	case boshdirector.RequestError:
-		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewBoshRequestError("delete", err)
+		return OperationData{}, NewBoshRequestError("delete", err)
 	case error:
-		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(
+		return OperationData{}, NewGenericError(
 			ctx,
 			fmt.Errorf("error deprovisioning: deleting bosh deployment: %s", err),
 		)
@@ -208,18 +244,13 @@ func (b *Broker) deleteInstance(
 
 	logger.Printf("Bosh task id for Delete instance %s was %d\n", instanceID, taskID)
 	ctx = brokercontext.WithBoshTaskID(ctx, taskID)
+	logger = b.loggerFactory.NewWithContext(ctx)
 
-	operationData, err := json.Marshal(OperationData{
+	operationData := OperationData{
 		OperationType: OperationTypeDelete,
 		BoshTaskID:    taskID,
-	})
-
-	if err != nil {
-		return brokerapi.DeprovisionServiceSpec{IsAsync: true}, NewGenericError(ctx, err)
 	}
+	b.recordStartedOperation(ctx, instanceID, planID, operationData, logger)
 
-	return brokerapi.DeprovisionServiceSpec{
-		IsAsync:       true,
-		OperationData: string(operationData),
-	}, nil
+	return operationData, nil
 }
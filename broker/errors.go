@@ -20,6 +20,7 @@ const (
 	GenericErrorPrefix         = "There was a problem completing your request. Please contact your operations team providing the following information:"
 	PendingChangesErrorMessage = "The service broker has been updated, and this service instance is out of date. Please contact your operator."
 	OperationInProgressMessage = "An operation is in progress for your service instance. Please try again later."
+	AdapterTimeoutMessage      = "The service adapter did not respond in time. Please try again later, or contact your operations team if the problem persists."
 
 	UpdateLoggerAction = ""
 )
@@ -114,6 +115,8 @@ func adapterToAPIError(ctx context.Context, err error) error {
 		return brokerapi.ErrBindingDoesNotExist
 	case serviceadapter.AppGuidNotProvidedError:
 		return brokerapi.ErrAppGuidNotProvided
+	case serviceadapter.TimeoutError:
+		return errors.New(AdapterTimeoutMessage)
 	case serviceadapter.UnknownFailureError:
 		if err.Error() == "" {
 			//Adapter returns an unknown error with no message
@@ -142,6 +145,14 @@ func NewDeploymentNotFoundError(e error) error {
 	return DeploymentNotFoundError{e}
 }
 
+type NoRollbackAvailableError struct {
+	error
+}
+
+func NewNoRollbackAvailableError(e error) error {
+	return NoRollbackAvailableError{e}
+}
+
 type TaskInProgressError struct {
 	Message string
 }
@@ -158,10 +169,51 @@ func NewServiceError(e error) error {
 	return ServiceError{error: e}
 }
 
+type OperationJournalDisabledError struct {
+	error
+}
+
+func NewOperationJournalDisabledError(e error) error {
+	return OperationJournalDisabledError{error: e}
+}
+
+type BackupNotConfiguredError struct {
+	error
+}
+
+func NewBackupNotConfiguredError(e error) error {
+	return BackupNotConfiguredError{error: e}
+}
+
+type BackupNotFoundError struct {
+	error
+}
+
+func NewBackupNotFoundError(e error) error {
+	return BackupNotFoundError{error: e}
+}
+
+type BackupNotRestorableError struct {
+	error
+}
+
+func NewBackupNotRestorableError(e error) error {
+	return BackupNotRestorableError{error: e}
+}
+
+type DeploymentNotOrphanedError struct {
+	error
+}
+
+func NewDeploymentNotOrphanedError(e error) error {
+	return DeploymentNotOrphanedError{error: e}
+}
+
 type PendingChangesNotAppliedError struct {
 	error
+	Changes PendingChanges
 }
 
-func NewPendingChangesNotAppliedError(e error) error {
-	return PendingChangesNotAppliedError{error: e}
+func NewPendingChangesNotAppliedError(e error, changes PendingChanges) error {
+	return PendingChangesNotAppliedError{error: e, Changes: changes}
 }
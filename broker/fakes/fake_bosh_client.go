@@ -170,6 +170,20 @@ type FakeBoshClient struct {
 		result1 boshdirector.BoshTask
 		result2 error
 	}
+	GetTaskOutputStub        func(int, *log.Logger) (boshdirector.BoshTaskOutput, error)
+	getTaskOutputMutex       sync.RWMutex
+	getTaskOutputArgsForCall []struct {
+		arg1 int
+		arg2 *log.Logger
+	}
+	getTaskOutputReturns struct {
+		result1 boshdirector.BoshTaskOutput
+		result2 error
+	}
+	getTaskOutputReturnsOnCall map[int]struct {
+		result1 boshdirector.BoshTaskOutput
+		result2 error
+	}
 	GetTasksStub        func(string, *log.Logger) (boshdirector.BoshTasks, error)
 	getTasksMutex       sync.RWMutex
 	getTasksArgsForCall []struct {
@@ -979,6 +993,70 @@ func (fake *FakeBoshClient) GetTaskReturnsOnCall(i int, result1 boshdirector.Bos
 	}{result1, result2}
 }
 
+func (fake *FakeBoshClient) GetTaskOutput(arg1 int, arg2 *log.Logger) (boshdirector.BoshTaskOutput, error) {
+	fake.getTaskOutputMutex.Lock()
+	ret, specificReturn := fake.getTaskOutputReturnsOnCall[len(fake.getTaskOutputArgsForCall)]
+	fake.getTaskOutputArgsForCall = append(fake.getTaskOutputArgsForCall, struct {
+		arg1 int
+		arg2 *log.Logger
+	}{arg1, arg2})
+	fake.recordInvocation("GetTaskOutput", []interface{}{arg1, arg2})
+	fake.getTaskOutputMutex.Unlock()
+	if fake.GetTaskOutputStub != nil {
+		return fake.GetTaskOutputStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getTaskOutputReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBoshClient) GetTaskOutputCallCount() int {
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
+	return len(fake.getTaskOutputArgsForCall)
+}
+
+func (fake *FakeBoshClient) GetTaskOutputCalls(stub func(int, *log.Logger) (boshdirector.BoshTaskOutput, error)) {
+	fake.getTaskOutputMutex.Lock()
+	defer fake.getTaskOutputMutex.Unlock()
+	fake.GetTaskOutputStub = stub
+}
+
+func (fake *FakeBoshClient) GetTaskOutputArgsForCall(i int) (int, *log.Logger) {
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
+	argsForCall := fake.getTaskOutputArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeBoshClient) GetTaskOutputReturns(result1 boshdirector.BoshTaskOutput, result2 error) {
+	fake.getTaskOutputMutex.Lock()
+	defer fake.getTaskOutputMutex.Unlock()
+	fake.GetTaskOutputStub = nil
+	fake.getTaskOutputReturns = struct {
+		result1 boshdirector.BoshTaskOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBoshClient) GetTaskOutputReturnsOnCall(i int, result1 boshdirector.BoshTaskOutput, result2 error) {
+	fake.getTaskOutputMutex.Lock()
+	defer fake.getTaskOutputMutex.Unlock()
+	fake.GetTaskOutputStub = nil
+	if fake.getTaskOutputReturnsOnCall == nil {
+		fake.getTaskOutputReturnsOnCall = make(map[int]struct {
+			result1 boshdirector.BoshTaskOutput
+			result2 error
+		})
+	}
+	fake.getTaskOutputReturnsOnCall[i] = struct {
+		result1 boshdirector.BoshTaskOutput
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBoshClient) GetTasks(arg1 string, arg2 *log.Logger) (boshdirector.BoshTasks, error) {
 	fake.getTasksMutex.Lock()
 	ret, specificReturn := fake.getTasksReturnsOnCall[len(fake.getTasksArgsForCall)]
@@ -1395,6 +1473,8 @@ func (fake *FakeBoshClient) Invocations() map[string][][]interface{} {
 	defer fake.getNormalisedTasksByContextMutex.RUnlock()
 	fake.getTaskMutex.RLock()
 	defer fake.getTaskMutex.RUnlock()
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
 	fake.getTasksMutex.RLock()
 	defer fake.getTasksMutex.RUnlock()
 	fake.recreateMutex.RLock()
@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	"context"
 	"log"
 	"sync"
 
@@ -9,14 +10,15 @@ import (
 )
 
 type FakeDeployer struct {
-	CreateStub        func(string, string, map[string]interface{}, string, *log.Logger) (int, []byte, error)
+	CreateStub        func(context.Context, string, string, map[string]interface{}, string, *log.Logger) (int, []byte, error)
 	createMutex       sync.RWMutex
 	createArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 string
-		arg5 *log.Logger
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 string
+		arg6 *log.Logger
 	}
 	createReturns struct {
 		result1 int
@@ -28,13 +30,67 @@ type FakeDeployer struct {
 		result2 []byte
 		result3 error
 	}
-	RecreateStub        func(string, string, string, *log.Logger) (int, error)
+	PendingChangesStub        func(context.Context, string, string, map[string]string, *log.Logger) (broker.PendingChanges, error)
+	pendingChangesMutex       sync.RWMutex
+	pendingChangesArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 map[string]string
+		arg5 *log.Logger
+	}
+	pendingChangesReturns struct {
+		result1 broker.PendingChanges
+		result2 error
+	}
+	pendingChangesReturnsOnCall map[int]struct {
+		result1 broker.PendingChanges
+		result2 error
+	}
+	PreviewUpdateStub        func(context.Context, string, string, map[string]interface{}, *string, map[string]string, *log.Logger) (broker.DeploymentPreview, error)
+	previewUpdateMutex       sync.RWMutex
+	previewUpdateArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 *string
+		arg6 map[string]string
+		arg7 *log.Logger
+	}
+	previewUpdateReturns struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	previewUpdateReturnsOnCall map[int]struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	PreviewUpgradeStub        func(context.Context, string, string, *string, *log.Logger) (broker.DeploymentPreview, error)
+	previewUpgradeMutex       sync.RWMutex
+	previewUpgradeArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 *string
+		arg5 *log.Logger
+	}
+	previewUpgradeReturns struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	previewUpgradeReturnsOnCall map[int]struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	RecreateStub        func(context.Context, string, string, string, *log.Logger) (int, error)
 	recreateMutex       sync.RWMutex
 	recreateArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
 		arg3 string
-		arg4 *log.Logger
+		arg4 string
+		arg5 *log.Logger
 	}
 	recreateReturns struct {
 		result1 int
@@ -44,16 +100,66 @@ type FakeDeployer struct {
 		result1 int
 		result2 error
 	}
-	UpdateStub        func(string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) (int, []byte, error)
+	RollbackStub        func(context.Context, string, string, broker.DeploymentSnapshot, *log.Logger) (int, error)
+	rollbackMutex       sync.RWMutex
+	rollbackArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 broker.DeploymentSnapshot
+		arg5 *log.Logger
+	}
+	rollbackReturns struct {
+		result1 int
+		result2 error
+	}
+	rollbackReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	RunErrandStub        func(context.Context, string, string, []string, string, *log.Logger) (int, error)
+	runErrandMutex       sync.RWMutex
+	runErrandArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 []string
+		arg5 string
+		arg6 *log.Logger
+	}
+	runErrandReturns struct {
+		result1 int
+		result2 error
+	}
+	runErrandReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	SnapshotStub        func(string, *log.Logger) (broker.DeploymentSnapshot, error)
+	snapshotMutex       sync.RWMutex
+	snapshotArgsForCall []struct {
+		arg1 string
+		arg2 *log.Logger
+	}
+	snapshotReturns struct {
+		result1 broker.DeploymentSnapshot
+		result2 error
+	}
+	snapshotReturnsOnCall map[int]struct {
+		result1 broker.DeploymentSnapshot
+		result2 error
+	}
+	UpdateStub        func(context.Context, string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) (int, []byte, error)
 	updateMutex       sync.RWMutex
 	updateArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 *string
-		arg5 string
-		arg6 map[string]string
-		arg7 *log.Logger
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 *string
+		arg6 string
+		arg7 map[string]string
+		arg8 *log.Logger
 	}
 	updateReturns struct {
 		result1 int
@@ -65,14 +171,15 @@ type FakeDeployer struct {
 		result2 []byte
 		result3 error
 	}
-	UpgradeStub        func(string, string, *string, string, *log.Logger) (int, []byte, error)
+	UpgradeStub        func(context.Context, string, string, *string, string, *log.Logger) (int, []byte, error)
 	upgradeMutex       sync.RWMutex
 	upgradeArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 *string
-		arg4 string
-		arg5 *log.Logger
+		arg3 string
+		arg4 *string
+		arg5 string
+		arg6 *log.Logger
 	}
 	upgradeReturns struct {
 		result1 int
@@ -88,20 +195,21 @@ type FakeDeployer struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeDeployer) Create(arg1 string, arg2 string, arg3 map[string]interface{}, arg4 string, arg5 *log.Logger) (int, []byte, error) {
+func (fake *FakeDeployer) Create(arg1 context.Context, arg2 string, arg3 string, arg4 map[string]interface{}, arg5 string, arg6 *log.Logger) (int, []byte, error) {
 	fake.createMutex.Lock()
 	ret, specificReturn := fake.createReturnsOnCall[len(fake.createArgsForCall)]
 	fake.createArgsForCall = append(fake.createArgsForCall, struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 string
-		arg5 *log.Logger
-	}{arg1, arg2, arg3, arg4, arg5})
-	fake.recordInvocation("Create", []interface{}{arg1, arg2, arg3, arg4, arg5})
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 string
+		arg6 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5, arg6})
+	fake.recordInvocation("Create", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6})
 	fake.createMutex.Unlock()
 	if fake.CreateStub != nil {
-		return fake.CreateStub(arg1, arg2, arg3, arg4, arg5)
+		return fake.CreateStub(arg1, arg2, arg3, arg4, arg5, arg6)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -116,17 +224,17 @@ func (fake *FakeDeployer) CreateCallCount() int {
 	return len(fake.createArgsForCall)
 }
 
-func (fake *FakeDeployer) CreateCalls(stub func(string, string, map[string]interface{}, string, *log.Logger) (int, []byte, error)) {
+func (fake *FakeDeployer) CreateCalls(stub func(context.Context, string, string, map[string]interface{}, string, *log.Logger) (int, []byte, error)) {
 	fake.createMutex.Lock()
 	defer fake.createMutex.Unlock()
 	fake.CreateStub = stub
 }
 
-func (fake *FakeDeployer) CreateArgsForCall(i int) (string, string, map[string]interface{}, string, *log.Logger) {
+func (fake *FakeDeployer) CreateArgsForCall(i int) (context.Context, string, string, map[string]interface{}, string, *log.Logger) {
 	fake.createMutex.RLock()
 	defer fake.createMutex.RUnlock()
 	argsForCall := fake.createArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
 }
 
 func (fake *FakeDeployer) CreateReturns(result1 int, result2 []byte, result3 error) {
@@ -158,19 +266,223 @@ func (fake *FakeDeployer) CreateReturnsOnCall(i int, result1 int, result2 []byte
 	}{result1, result2, result3}
 }
 
-func (fake *FakeDeployer) Recreate(arg1 string, arg2 string, arg3 string, arg4 *log.Logger) (int, error) {
+func (fake *FakeDeployer) PendingChanges(arg1 context.Context, arg2 string, arg3 string, arg4 map[string]string, arg5 *log.Logger) (broker.PendingChanges, error) {
+	fake.pendingChangesMutex.Lock()
+	ret, specificReturn := fake.pendingChangesReturnsOnCall[len(fake.pendingChangesArgsForCall)]
+	fake.pendingChangesArgsForCall = append(fake.pendingChangesArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 map[string]string
+		arg5 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5})
+	fake.recordInvocation("PendingChanges", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.pendingChangesMutex.Unlock()
+	if fake.PendingChangesStub != nil {
+		return fake.PendingChangesStub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.pendingChangesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PendingChangesCallCount() int {
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	return len(fake.pendingChangesArgsForCall)
+}
+
+func (fake *FakeDeployer) PendingChangesCalls(stub func(context.Context, string, string, map[string]string, *log.Logger) (broker.PendingChanges, error)) {
+	fake.pendingChangesMutex.Lock()
+	defer fake.pendingChangesMutex.Unlock()
+	fake.PendingChangesStub = stub
+}
+
+func (fake *FakeDeployer) PendingChangesArgsForCall(i int) (context.Context, string, string, map[string]string, *log.Logger) {
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	argsForCall := fake.pendingChangesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeDeployer) PendingChangesReturns(result1 broker.PendingChanges, result2 error) {
+	fake.pendingChangesMutex.Lock()
+	defer fake.pendingChangesMutex.Unlock()
+	fake.PendingChangesStub = nil
+	fake.pendingChangesReturns = struct {
+		result1 broker.PendingChanges
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PendingChangesReturnsOnCall(i int, result1 broker.PendingChanges, result2 error) {
+	fake.pendingChangesMutex.Lock()
+	defer fake.pendingChangesMutex.Unlock()
+	fake.PendingChangesStub = nil
+	if fake.pendingChangesReturnsOnCall == nil {
+		fake.pendingChangesReturnsOnCall = make(map[int]struct {
+			result1 broker.PendingChanges
+			result2 error
+		})
+	}
+	fake.pendingChangesReturnsOnCall[i] = struct {
+		result1 broker.PendingChanges
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PreviewUpdate(arg1 context.Context, arg2 string, arg3 string, arg4 map[string]interface{}, arg5 *string, arg6 map[string]string, arg7 *log.Logger) (broker.DeploymentPreview, error) {
+	fake.previewUpdateMutex.Lock()
+	ret, specificReturn := fake.previewUpdateReturnsOnCall[len(fake.previewUpdateArgsForCall)]
+	fake.previewUpdateArgsForCall = append(fake.previewUpdateArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 *string
+		arg6 map[string]string
+		arg7 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5, arg6, arg7})
+	fake.recordInvocation("PreviewUpdate", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6, arg7})
+	fake.previewUpdateMutex.Unlock()
+	if fake.PreviewUpdateStub != nil {
+		return fake.PreviewUpdateStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.previewUpdateReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PreviewUpdateCallCount() int {
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	return len(fake.previewUpdateArgsForCall)
+}
+
+func (fake *FakeDeployer) PreviewUpdateCalls(stub func(context.Context, string, string, map[string]interface{}, *string, map[string]string, *log.Logger) (broker.DeploymentPreview, error)) {
+	fake.previewUpdateMutex.Lock()
+	defer fake.previewUpdateMutex.Unlock()
+	fake.PreviewUpdateStub = stub
+}
+
+func (fake *FakeDeployer) PreviewUpdateArgsForCall(i int) (context.Context, string, string, map[string]interface{}, *string, map[string]string, *log.Logger) {
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	argsForCall := fake.previewUpdateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7
+}
+
+func (fake *FakeDeployer) PreviewUpdateReturns(result1 broker.DeploymentPreview, result2 error) {
+	fake.previewUpdateMutex.Lock()
+	defer fake.previewUpdateMutex.Unlock()
+	fake.PreviewUpdateStub = nil
+	fake.previewUpdateReturns = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PreviewUpdateReturnsOnCall(i int, result1 broker.DeploymentPreview, result2 error) {
+	fake.previewUpdateMutex.Lock()
+	defer fake.previewUpdateMutex.Unlock()
+	fake.PreviewUpdateStub = nil
+	if fake.previewUpdateReturnsOnCall == nil {
+		fake.previewUpdateReturnsOnCall = make(map[int]struct {
+			result1 broker.DeploymentPreview
+			result2 error
+		})
+	}
+	fake.previewUpdateReturnsOnCall[i] = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PreviewUpgrade(arg1 context.Context, arg2 string, arg3 string, arg4 *string, arg5 *log.Logger) (broker.DeploymentPreview, error) {
+	fake.previewUpgradeMutex.Lock()
+	ret, specificReturn := fake.previewUpgradeReturnsOnCall[len(fake.previewUpgradeArgsForCall)]
+	fake.previewUpgradeArgsForCall = append(fake.previewUpgradeArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 *string
+		arg5 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5})
+	fake.recordInvocation("PreviewUpgrade", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.previewUpgradeMutex.Unlock()
+	if fake.PreviewUpgradeStub != nil {
+		return fake.PreviewUpgradeStub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.previewUpgradeReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PreviewUpgradeCallCount() int {
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
+	return len(fake.previewUpgradeArgsForCall)
+}
+
+func (fake *FakeDeployer) PreviewUpgradeCalls(stub func(context.Context, string, string, *string, *log.Logger) (broker.DeploymentPreview, error)) {
+	fake.previewUpgradeMutex.Lock()
+	defer fake.previewUpgradeMutex.Unlock()
+	fake.PreviewUpgradeStub = stub
+}
+
+func (fake *FakeDeployer) PreviewUpgradeArgsForCall(i int) (context.Context, string, string, *string, *log.Logger) {
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
+	argsForCall := fake.previewUpgradeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeDeployer) PreviewUpgradeReturns(result1 broker.DeploymentPreview, result2 error) {
+	fake.previewUpgradeMutex.Lock()
+	defer fake.previewUpgradeMutex.Unlock()
+	fake.PreviewUpgradeStub = nil
+	fake.previewUpgradeReturns = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PreviewUpgradeReturnsOnCall(i int, result1 broker.DeploymentPreview, result2 error) {
+	fake.previewUpgradeMutex.Lock()
+	defer fake.previewUpgradeMutex.Unlock()
+	fake.PreviewUpgradeStub = nil
+	if fake.previewUpgradeReturnsOnCall == nil {
+		fake.previewUpgradeReturnsOnCall = make(map[int]struct {
+			result1 broker.DeploymentPreview
+			result2 error
+		})
+	}
+	fake.previewUpgradeReturnsOnCall[i] = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Recreate(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 *log.Logger) (int, error) {
 	fake.recreateMutex.Lock()
 	ret, specificReturn := fake.recreateReturnsOnCall[len(fake.recreateArgsForCall)]
 	fake.recreateArgsForCall = append(fake.recreateArgsForCall, struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
 		arg3 string
-		arg4 *log.Logger
-	}{arg1, arg2, arg3, arg4})
-	fake.recordInvocation("Recreate", []interface{}{arg1, arg2, arg3, arg4})
+		arg4 string
+		arg5 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5})
+	fake.recordInvocation("Recreate", []interface{}{arg1, arg2, arg3, arg4, arg5})
 	fake.recreateMutex.Unlock()
 	if fake.RecreateStub != nil {
-		return fake.RecreateStub(arg1, arg2, arg3, arg4)
+		return fake.RecreateStub(arg1, arg2, arg3, arg4, arg5)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -185,17 +497,17 @@ func (fake *FakeDeployer) RecreateCallCount() int {
 	return len(fake.recreateArgsForCall)
 }
 
-func (fake *FakeDeployer) RecreateCalls(stub func(string, string, string, *log.Logger) (int, error)) {
+func (fake *FakeDeployer) RecreateCalls(stub func(context.Context, string, string, string, *log.Logger) (int, error)) {
 	fake.recreateMutex.Lock()
 	defer fake.recreateMutex.Unlock()
 	fake.RecreateStub = stub
 }
 
-func (fake *FakeDeployer) RecreateArgsForCall(i int) (string, string, string, *log.Logger) {
+func (fake *FakeDeployer) RecreateArgsForCall(i int) (context.Context, string, string, string, *log.Logger) {
 	fake.recreateMutex.RLock()
 	defer fake.recreateMutex.RUnlock()
 	argsForCall := fake.recreateArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
 }
 
 func (fake *FakeDeployer) RecreateReturns(result1 int, result2 error) {
@@ -224,22 +536,227 @@ func (fake *FakeDeployer) RecreateReturnsOnCall(i int, result1 int, result2 erro
 	}{result1, result2}
 }
 
-func (fake *FakeDeployer) Update(arg1 string, arg2 string, arg3 map[string]interface{}, arg4 *string, arg5 string, arg6 map[string]string, arg7 *log.Logger) (int, []byte, error) {
+func (fake *FakeDeployer) Rollback(arg1 context.Context, arg2 string, arg3 string, arg4 broker.DeploymentSnapshot, arg5 *log.Logger) (int, error) {
+	fake.rollbackMutex.Lock()
+	ret, specificReturn := fake.rollbackReturnsOnCall[len(fake.rollbackArgsForCall)]
+	fake.rollbackArgsForCall = append(fake.rollbackArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 broker.DeploymentSnapshot
+		arg5 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5})
+	fake.recordInvocation("Rollback", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.rollbackMutex.Unlock()
+	if fake.RollbackStub != nil {
+		return fake.RollbackStub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.rollbackReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) RollbackCallCount() int {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	return len(fake.rollbackArgsForCall)
+}
+
+func (fake *FakeDeployer) RollbackCalls(stub func(context.Context, string, string, broker.DeploymentSnapshot, *log.Logger) (int, error)) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = stub
+}
+
+func (fake *FakeDeployer) RollbackArgsForCall(i int) (context.Context, string, string, broker.DeploymentSnapshot, *log.Logger) {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	argsForCall := fake.rollbackArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeDeployer) RollbackReturns(result1 int, result2 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	fake.rollbackReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) RollbackReturnsOnCall(i int, result1 int, result2 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	if fake.rollbackReturnsOnCall == nil {
+		fake.rollbackReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.rollbackReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) RunErrand(arg1 context.Context, arg2 string, arg3 string, arg4 []string, arg5 string, arg6 *log.Logger) (int, error) {
+	var arg4Copy []string
+	if arg4 != nil {
+		arg4Copy = make([]string, len(arg4))
+		copy(arg4Copy, arg4)
+	}
+	fake.runErrandMutex.Lock()
+	ret, specificReturn := fake.runErrandReturnsOnCall[len(fake.runErrandArgsForCall)]
+	fake.runErrandArgsForCall = append(fake.runErrandArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 []string
+		arg5 string
+		arg6 *log.Logger
+	}{arg1, arg2, arg3, arg4Copy, arg5, arg6})
+	fake.recordInvocation("RunErrand", []interface{}{arg1, arg2, arg3, arg4Copy, arg5, arg6})
+	fake.runErrandMutex.Unlock()
+	if fake.RunErrandStub != nil {
+		return fake.RunErrandStub(arg1, arg2, arg3, arg4, arg5, arg6)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.runErrandReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) RunErrandCallCount() int {
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
+	return len(fake.runErrandArgsForCall)
+}
+
+func (fake *FakeDeployer) RunErrandCalls(stub func(context.Context, string, string, []string, string, *log.Logger) (int, error)) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = stub
+}
+
+func (fake *FakeDeployer) RunErrandArgsForCall(i int) (context.Context, string, string, []string, string, *log.Logger) {
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
+	argsForCall := fake.runErrandArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
+}
+
+func (fake *FakeDeployer) RunErrandReturns(result1 int, result2 error) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = nil
+	fake.runErrandReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) RunErrandReturnsOnCall(i int, result1 int, result2 error) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = nil
+	if fake.runErrandReturnsOnCall == nil {
+		fake.runErrandReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.runErrandReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Snapshot(arg1 string, arg2 *log.Logger) (broker.DeploymentSnapshot, error) {
+	fake.snapshotMutex.Lock()
+	ret, specificReturn := fake.snapshotReturnsOnCall[len(fake.snapshotArgsForCall)]
+	fake.snapshotArgsForCall = append(fake.snapshotArgsForCall, struct {
+		arg1 string
+		arg2 *log.Logger
+	}{arg1, arg2})
+	fake.recordInvocation("Snapshot", []interface{}{arg1, arg2})
+	fake.snapshotMutex.Unlock()
+	if fake.SnapshotStub != nil {
+		return fake.SnapshotStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.snapshotReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) SnapshotCallCount() int {
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
+	return len(fake.snapshotArgsForCall)
+}
+
+func (fake *FakeDeployer) SnapshotCalls(stub func(string, *log.Logger) (broker.DeploymentSnapshot, error)) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = stub
+}
+
+func (fake *FakeDeployer) SnapshotArgsForCall(i int) (string, *log.Logger) {
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
+	argsForCall := fake.snapshotArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeDeployer) SnapshotReturns(result1 broker.DeploymentSnapshot, result2 error) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = nil
+	fake.snapshotReturns = struct {
+		result1 broker.DeploymentSnapshot
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) SnapshotReturnsOnCall(i int, result1 broker.DeploymentSnapshot, result2 error) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = nil
+	if fake.snapshotReturnsOnCall == nil {
+		fake.snapshotReturnsOnCall = make(map[int]struct {
+			result1 broker.DeploymentSnapshot
+			result2 error
+		})
+	}
+	fake.snapshotReturnsOnCall[i] = struct {
+		result1 broker.DeploymentSnapshot
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Update(arg1 context.Context, arg2 string, arg3 string, arg4 map[string]interface{}, arg5 *string, arg6 string, arg7 map[string]string, arg8 *log.Logger) (int, []byte, error) {
 	fake.updateMutex.Lock()
 	ret, specificReturn := fake.updateReturnsOnCall[len(fake.updateArgsForCall)]
 	fake.updateArgsForCall = append(fake.updateArgsForCall, struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 *string
-		arg5 string
-		arg6 map[string]string
-		arg7 *log.Logger
-	}{arg1, arg2, arg3, arg4, arg5, arg6, arg7})
-	fake.recordInvocation("Update", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6, arg7})
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 *string
+		arg6 string
+		arg7 map[string]string
+		arg8 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8})
+	fake.recordInvocation("Update", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8})
 	fake.updateMutex.Unlock()
 	if fake.UpdateStub != nil {
-		return fake.UpdateStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+		return fake.UpdateStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -254,17 +771,17 @@ func (fake *FakeDeployer) UpdateCallCount() int {
 	return len(fake.updateArgsForCall)
 }
 
-func (fake *FakeDeployer) UpdateCalls(stub func(string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) (int, []byte, error)) {
+func (fake *FakeDeployer) UpdateCalls(stub func(context.Context, string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) (int, []byte, error)) {
 	fake.updateMutex.Lock()
 	defer fake.updateMutex.Unlock()
 	fake.UpdateStub = stub
 }
 
-func (fake *FakeDeployer) UpdateArgsForCall(i int) (string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) {
+func (fake *FakeDeployer) UpdateArgsForCall(i int) (context.Context, string, string, map[string]interface{}, *string, string, map[string]string, *log.Logger) {
 	fake.updateMutex.RLock()
 	defer fake.updateMutex.RUnlock()
 	argsForCall := fake.updateArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8
 }
 
 func (fake *FakeDeployer) UpdateReturns(result1 int, result2 []byte, result3 error) {
@@ -296,20 +813,21 @@ func (fake *FakeDeployer) UpdateReturnsOnCall(i int, result1 int, result2 []byte
 	}{result1, result2, result3}
 }
 
-func (fake *FakeDeployer) Upgrade(arg1 string, arg2 string, arg3 *string, arg4 string, arg5 *log.Logger) (int, []byte, error) {
+func (fake *FakeDeployer) Upgrade(arg1 context.Context, arg2 string, arg3 string, arg4 *string, arg5 string, arg6 *log.Logger) (int, []byte, error) {
 	fake.upgradeMutex.Lock()
 	ret, specificReturn := fake.upgradeReturnsOnCall[len(fake.upgradeArgsForCall)]
 	fake.upgradeArgsForCall = append(fake.upgradeArgsForCall, struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 *string
-		arg4 string
-		arg5 *log.Logger
-	}{arg1, arg2, arg3, arg4, arg5})
-	fake.recordInvocation("Upgrade", []interface{}{arg1, arg2, arg3, arg4, arg5})
+		arg3 string
+		arg4 *string
+		arg5 string
+		arg6 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5, arg6})
+	fake.recordInvocation("Upgrade", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6})
 	fake.upgradeMutex.Unlock()
 	if fake.UpgradeStub != nil {
-		return fake.UpgradeStub(arg1, arg2, arg3, arg4, arg5)
+		return fake.UpgradeStub(arg1, arg2, arg3, arg4, arg5, arg6)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -324,17 +842,17 @@ func (fake *FakeDeployer) UpgradeCallCount() int {
 	return len(fake.upgradeArgsForCall)
 }
 
-func (fake *FakeDeployer) UpgradeCalls(stub func(string, string, *string, string, *log.Logger) (int, []byte, error)) {
+func (fake *FakeDeployer) UpgradeCalls(stub func(context.Context, string, string, *string, string, *log.Logger) (int, []byte, error)) {
 	fake.upgradeMutex.Lock()
 	defer fake.upgradeMutex.Unlock()
 	fake.UpgradeStub = stub
 }
 
-func (fake *FakeDeployer) UpgradeArgsForCall(i int) (string, string, *string, string, *log.Logger) {
+func (fake *FakeDeployer) UpgradeArgsForCall(i int) (context.Context, string, string, *string, string, *log.Logger) {
 	fake.upgradeMutex.RLock()
 	defer fake.upgradeMutex.RUnlock()
 	argsForCall := fake.upgradeArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
 }
 
 func (fake *FakeDeployer) UpgradeReturns(result1 int, result2 []byte, result3 error) {
@@ -371,8 +889,20 @@ func (fake *FakeDeployer) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.createMutex.RLock()
 	defer fake.createMutex.RUnlock()
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
 	fake.recreateMutex.RLock()
 	defer fake.recreateMutex.RUnlock()
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
 	fake.updateMutex.RLock()
 	defer fake.updateMutex.RUnlock()
 	fake.upgradeMutex.RLock()
@@ -1,13 +1,57 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
 package broker
 
 import (
 	"context"
-	"errors"
 
+	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
 )
 
+const getBindingLoggerAction = "get-binding"
+
+// GetBinding returns the result of an asynchronous bind while this broker
+// still holds it. The broker does not store the credentials of synchronous
+// bindings, and the service adapter's create-binding is never run again to
+// serve a read, as it may mint new credentials each time, so any other binding
+// is reported as not found. As results are only held for a while, the broker
+// does not advertise bindings as retrievable itself: when runtime CredHub is
+// configured, which asynchronous bindings require, the credhubbroker serves
+// this request from the credential store, where every binding is stored.
 func (b *Broker) GetBinding(ctx context.Context, instanceID, bindingID string) (brokerapi.GetBindingSpec, error) {
-	err := brokerapi.NewFailureResponse(errors.New("GetBinding Not Implemented"), 404, "")
-	return brokerapi.GetBindingSpec{}, err
+	requestID := uuid.New()
+	if len(brokercontext.GetReqID(ctx)) > 0 {
+		requestID = brokercontext.GetReqID(ctx)
+	}
+
+	ctx = brokercontext.New(ctx, getBindingLoggerAction, requestID, b.serviceOffering.Name, instanceID)
+	logger := b.loggerFactory.NewWithContext(ctx)
+
+	binding, found := b.HeldBinding(instanceID, bindingID)
+	if !found {
+		logger.Printf("no stored result for binding %s of instance %s\n", bindingID, instanceID)
+		return brokerapi.GetBindingSpec{}, b.processError(brokerapi.ErrBindingNotFound, logger)
+	}
+
+	return brokerapi.GetBindingSpec{
+		Credentials:     binding.Credentials,
+		SyslogDrainURL:  binding.SyslogDrainURL,
+		RouteServiceURL: binding.RouteServiceURL,
+	}, nil
+}
+
+// HeldBinding returns the binding created by a successful asynchronous bind,
+// while this broker still holds it.
+func (b *Broker) HeldBinding(instanceID, bindingID string) (brokerapi.Binding, bool) {
+	operation, found := b.bindingOperations.get(instanceID, bindingID)
+	if !found || operation.operationType != OperationTypeBind || operation.state != brokerapi.Succeeded {
+		return brokerapi.Binding{}, false
+	}
+	return operation.binding, true
 }
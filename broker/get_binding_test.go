@@ -2,19 +2,49 @@ package broker_test
 
 import (
 	"context"
+	"net/http"
 
-	"code.cloudfoundry.org/lager"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
 var _ = Describe("GetBinding", func() {
-	It("returns an error", func() {
-		_, err := b.GetBinding(context.Background(), "instanceID", "bID")
-		fresp, ok := err.(*brokerapi.FailureResponse)
-		Expect(ok).To(BeTrue(), "err wasn't a FailureResponse")
-		logger := lager.NewLogger("test")
-		Expect(fresp.ValidatedStatusCode(logger)).To(Equal(404))
+	const (
+		instanceID = "some-instance-id"
+		bindingID  = "some-binding-id"
+	)
+
+	BeforeEach(func() {
+		boshClient.GetDeploymentReturns([]byte("name: service-instance_some-instance-id"), true, nil)
+		boshClient.VMsReturns(bosh.BoshVMs{"redis-server": []string{"an.ip"}}, nil)
+		serviceAdapter.CreateBindingReturns(sdk.Binding{
+			Credentials: map[string]interface{}{"password": "secret"},
+		}, nil)
+
+		b = createBrokerWithServiceCatalog(serviceCatalog)
+	})
+
+	It("returns a 404 for a synchronous binding without running create-binding again", func() {
+		_, err := b.Bind(context.Background(), instanceID, bindingID, brokerapi.BindDetails{PlanID: existingPlanID, ServiceID: serviceOfferingID}, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = b.GetBinding(context.Background(), instanceID, bindingID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+		Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
+	})
+
+	It("returns a 404 for a binding the broker knows nothing about", func() {
+		_, err := b.GetBinding(context.Background(), instanceID, bindingID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+		Expect(serviceAdapter.CreateBindingCallCount()).To(BeZero())
+	})
+
+	It("does not advertise bindings as retrievable when asynchronous bindings are disabled", func() {
+		services, err := b.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services[0].BindingsRetrievable).To(BeFalse())
 	})
 })
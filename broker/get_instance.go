@@ -1,13 +1,206 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
 package broker
 
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
 
+	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"gopkg.in/yaml.v2"
 )
 
+const getInstanceLoggerAction = "get-instance"
+
 func (b *Broker) GetInstance(ctx context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
-	err := brokerapi.NewFailureResponse(errors.New("GetInstance Not Implemented"), 404, "")
-	return brokerapi.GetInstanceDetailsSpec{}, err
+	requestID := uuid.New()
+	if len(brokercontext.GetReqID(ctx)) > 0 {
+		requestID = brokercontext.GetReqID(ctx)
+	}
+
+	ctx = brokercontext.New(ctx, getInstanceLoggerAction, requestID, b.serviceOffering.Name, instanceID)
+	logger := b.loggerFactory.NewWithContext(ctx)
+
+	manifest, found, err := b.boshClient.GetDeployment(b.deploymentName(instanceID), logger)
+	if err != nil {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewGenericError(ctx, fmt.Errorf("error getting deployment %s: %s", b.deploymentName(instanceID), err)), logger)
+	}
+	if !found {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewDisplayableError(
+			instanceNotFoundFailure(),
+			fmt.Errorf("error getting instance: instance %s, not found", instanceID),
+		), logger)
+	}
+
+	tasks, err := b.boshClient.GetTasks(b.deploymentName(instanceID), logger)
+	if err != nil {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewGenericError(ctx, fmt.Errorf("error getting tasks for deployment %s: %s", b.deploymentName(instanceID), err)), logger)
+	}
+	if incompleteTasks := tasks.IncompleteTasks(); len(incompleteTasks) != 0 {
+		logger.Printf("deployment %s is still in progress: tasks %s\n", b.deploymentName(instanceID), incompleteTasks.ToLog())
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(brokerapi.ErrConcurrentInstanceAccess.Build(), logger)
+	}
+
+	planID, parameters, err := b.deployedPlanAndParameters(instanceID, manifest, logger)
+	if err != nil {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewGenericError(ctx, fmt.Errorf("error listing service instances: %s", err)), logger)
+	}
+	if planID == "" {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewDisplayableError(
+			instanceNotFoundFailure(),
+			fmt.Errorf("error getting instance: instance %s is not known to the platform", instanceID),
+		), logger)
+	}
+
+	plan, found := b.serviceOffering.FindPlanByID(planID)
+	if !found {
+		return brokerapi.GetInstanceDetailsSpec{}, b.processError(NewGenericError(ctx, fmt.Errorf("plan %s not found", planID)), logger)
+	}
+
+	dashboardURL, err := b.adapterClient.GenerateDashboardUrl(ctx, instanceID, plan.AdapterPlan(b.serviceOffering.GlobalProperties), manifest, logger)
+	if err != nil {
+		if _, ok := err.(serviceadapter.NotImplementedError); !ok {
+			logger.Printf("generating dashboard: %v\n", err)
+			return brokerapi.GetInstanceDetailsSpec{}, b.processError(adapterToAPIError(ctx, err), logger)
+		}
+	}
+
+	spec := brokerapi.GetInstanceDetailsSpec{
+		ServiceID:    b.serviceOffering.ID,
+		PlanID:       planID,
+		DashboardURL: dashboardURL,
+	}
+	if parameters != nil {
+		spec.Parameters = parameters
+	}
+	return spec, nil
+}
+
+// deployedPlanAndParameters reads the plan an instance is deployed with and
+// its arbitrary parameters from the latest successful create or update
+// recorded in the operation journal. Each create and update stores the
+// parameters of its request merged over the ones before it, so the latest one
+// is enough. The parameters are nil, and not reported, when the broker has no
+// parameter store or did not know the instance's parameters before its latest
+// update; the deployed manifest does not record them. When the journal
+// records no deployment of the instance, the plan is worked out from the
+// deployed manifest, and failing that comes from the instance lister.
+func (b *Broker) deployedPlanAndParameters(instanceID string, manifest []byte, logger *log.Logger) (string, map[string]interface{}, error) {
+	if deployment, found := b.journalledDeployment(instanceID, logger); found {
+		return deployment.PlanID, b.storedParameters(deployment, logger), nil
+	}
+
+	if planID, found := b.manifestPlanID(manifest); found {
+		return planID, nil, nil
+	}
+
+	planID, err := b.currentPlanID(instanceID)
+	return planID, nil, err
+}
+
+// manifestPlanID works out the plan a deployment was made with from its
+// manifest, which does not name it: it is the plan whose instance groups the
+// manifest deploys with the same VM types and instance counts, provided no
+// other plan matches as well.
+func (b *Broker) manifestPlanID(manifest []byte) (string, bool) {
+	var deployed bosh.BoshManifest
+	if err := yaml.Unmarshal(manifest, &deployed); err != nil {
+		return "", false
+	}
+
+	deployedGroups := map[string]bosh.InstanceGroup{}
+	for _, group := range deployed.InstanceGroups {
+		deployedGroups[group.Name] = group
+	}
+
+	var planID string
+	matches := 0
+	for _, plan := range b.serviceOffering.Plans {
+		if len(plan.InstanceGroups) == 0 || len(plan.InstanceGroups) != len(deployedGroups) {
+			continue
+		}
+		match := true
+		for _, group := range plan.InstanceGroups {
+			deployedGroup, found := deployedGroups[group.Name]
+			if !found || deployedGroup.VMType != group.VMType || deployedGroup.Instances != group.Instances {
+				match = false
+				break
+			}
+		}
+		if match {
+			planID = plan.ID
+			matches++
+		}
+	}
+	return planID, matches == 1
+}
+
+// deployedPlanID returns the plan an instance is deployed with, like
+// deployedPlanAndParameters, without reading its parameters.
+func (b *Broker) deployedPlanID(instanceID string, logger *log.Logger) (string, error) {
+	if deployment, found := b.journalledDeployment(instanceID, logger); found {
+		return deployment.PlanID, nil
+	}
+	return b.currentPlanID(instanceID)
+}
+
+// journalledDeployment returns the latest successful create or update of an
+// instance recorded in the operation journal, unless the instance has been
+// deleted since. Creates and updates in progress or that failed are skipped,
+// as the instance may not be deployed with their plan.
+func (b *Broker) journalledDeployment(instanceID string, logger *log.Logger) (operationjournal.Operation, bool) {
+	if b.operationJournal == nil {
+		return operationjournal.Operation{}, false
+	}
+
+	operations, err := b.operationJournal.Operations(instanceID)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reading the operations of instance %s from the operation journal: %s\n", instanceID, err)
+		return operationjournal.Operation{}, false
+	}
+
+	for index := len(operations) - 1; index >= 0; index-- {
+		operation := operations[index]
+		if operation.State != string(brokerapi.Succeeded) {
+			continue
+		}
+		if OperationType(operation.Type) == OperationTypeDelete {
+			break
+		}
+		if isDeployment(operation) {
+			return operation, operation.PlanID != ""
+		}
+	}
+	return operationjournal.Operation{}, false
+}
+
+func (b *Broker) currentPlanID(instanceID string) (string, error) {
+	instances, err := b.instanceLister.Instances()
+	if err != nil {
+		return "", err
+	}
+
+	for _, instance := range instances {
+		if instance.GUID == instanceID {
+			return instance.PlanUniqueID, nil
+		}
+	}
+	return "", nil
+}
+
+func instanceNotFoundFailure() error {
+	return brokerapi.NewFailureResponse(errors.New("instance does not exist"), http.StatusNotFound, getInstanceLoggerAction)
 }
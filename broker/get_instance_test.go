@@ -2,20 +2,218 @@ package broker_test
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"code.cloudfoundry.org/lager"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 )
 
 var _ = Describe("GetInstance", func() {
-	It("returns an appropriate error", func() {
-		_, err := b.GetInstance(context.Background(), "some-instance-id")
-		fresp, ok := err.(*brokerapi.FailureResponse)
-		Expect(ok).To(BeTrue(), "err wasn't a FailureResponse")
-		logger := lager.NewLogger("test")
-		Expect(fresp.ValidatedStatusCode(logger)).To(Equal(404))
+	const instanceID = "some-instance-id"
+
+	var manifest = []byte("name: service-instance_some-instance-id")
+
+	BeforeEach(func() {
+		boshClient.GetDeploymentReturns(manifest, true, nil)
+		boshClient.GetTasksReturns(boshdirector.BoshTasks{{State: boshdirector.TaskDone}}, nil)
+		fakeInstanceLister.InstancesReturns([]service.Instance{
+			{GUID: "some-other-instance-id", PlanUniqueID: secondPlanID},
+			{GUID: instanceID, PlanUniqueID: existingPlanID},
+		}, nil)
+		serviceAdapter.GenerateDashboardUrlReturns("http://dashboard.example.com", nil)
+
+		b = createBrokerWithServiceCatalog(serviceCatalog)
+	})
+
+	It("returns the plan and dashboard url of the instance", func() {
+		spec, err := b.GetInstance(context.Background(), instanceID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(spec).To(Equal(brokerapi.GetInstanceDetailsSpec{
+			ServiceID:    serviceOfferingID,
+			PlanID:       existingPlanID,
+			DashboardURL: "http://dashboard.example.com",
+		}))
+
+		Expect(boshClient.GetDeploymentCallCount()).To(Equal(1))
+		deploymentName, _ := boshClient.GetDeploymentArgsForCall(0)
+		Expect(deploymentName).To(Equal("service-instance_" + instanceID))
+
+		Expect(serviceAdapter.GenerateDashboardUrlCallCount()).To(Equal(1))
+		_, actualInstanceID, actualPlan, actualManifest, _ := serviceAdapter.GenerateDashboardUrlArgsForCall(0)
+		Expect(actualInstanceID).To(Equal(instanceID))
+		Expect(actualPlan).To(Equal(existingPlan.AdapterPlan(serviceCatalog.GlobalProperties)))
+		Expect(actualManifest).To(Equal(manifest))
+	})
+
+	Describe("reading the plan and parameters from the operation journal", func() {
+		var parameterStore *fakes.FakeParameterStore
+
+		BeforeEach(func() {
+			parameterStore = new(fakes.FakeParameterStore)
+			parameterStore.GetReturns(map[string]interface{}{"size": "large", "tls": true}, nil)
+			b.UseParameterStore(parameterStore)
+		})
+
+		It("reads them from the latest successful deployment", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", PlanID: existingPlanID, State: "succeeded", ParametersRef: "/c/1/parameters"},
+				{ID: "2", Type: "update", PlanID: secondPlanID, State: "succeeded", ParametersRef: "/c/2/parameters"},
+				{ID: "3", Type: "upgrade", PlanID: existingPlanID, State: "succeeded"},
+				{ID: "4", Type: "update", PlanID: existingPlanID, State: "failed", ParametersRef: "/c/4/parameters"},
+				{ID: "5", Type: "update", PlanID: existingPlanID, State: "in progress", ParametersRef: "/c/5/parameters"},
+			}, nil)
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spec.PlanID).To(Equal(secondPlanID))
+			Expect(spec.Parameters).To(Equal(map[string]interface{}{"size": "large", "tls": true}))
+			Expect(parameterStore.GetCallCount()).To(Equal(1))
+			Expect(parameterStore.GetArgsForCall(0)).To(Equal("/c/2/parameters"))
+			Expect(fakeInstanceLister.InstancesCallCount()).To(BeZero())
+		})
+
+		It("does not report parameters when none were stored for the latest deployment", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "upgrade", PlanID: existingPlanID, State: "succeeded"},
+				{ID: "2", Type: "update", PlanID: secondPlanID, State: "succeeded"},
+			}, nil)
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spec.PlanID).To(Equal(secondPlanID))
+			Expect(spec.Parameters).To(BeNil())
+			Expect(parameterStore.GetCallCount()).To(BeZero())
+		})
+
+		It("does not report parameters when they cannot be read", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", InstanceID: instanceID, Type: "create", PlanID: existingPlanID, State: "succeeded", ParametersRef: "/c/1/parameters"},
+			}, nil)
+			parameterStore.GetReturns(nil, errors.New("credhub is down"))
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spec.PlanID).To(Equal(existingPlanID))
+			Expect(spec.Parameters).To(BeNil())
+			Expect(logBuffer.String()).To(ContainSubstring("error reading the parameters of operation 1 of instance some-instance-id: credhub is down"))
+		})
+
+		It("does not read the plan of a deleted instance from the journal", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", PlanID: secondPlanID, State: "succeeded"},
+				{ID: "2", Type: "delete", State: "succeeded"},
+			}, nil)
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.PlanID).To(Equal(existingPlanID))
+			Expect(fakeInstanceLister.InstancesCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("working out the plan from the deployed manifest", func() {
+		It("reports the plan whose instance groups the manifest deploys", func() {
+			boshClient.GetDeploymentReturns([]byte(`---
+name: service-instance_some-instance-id
+instance_groups:
+- name: instance-group-name
+  vm_type: vm-type1
+  instances: 44
+`), true, nil)
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.PlanID).To(Equal(secondPlanID))
+			Expect(spec.Parameters).To(BeNil())
+			Expect(fakeInstanceLister.InstancesCallCount()).To(BeZero())
+		})
+
+		It("reads the plan from the instance lister when no plan matches the manifest", func() {
+			boshClient.GetDeploymentReturns([]byte(`---
+name: service-instance_some-instance-id
+instance_groups:
+- name: instance-group-name
+  vm_type: vm-type1
+  instances: 3
+`), true, nil)
+
+			spec, err := b.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.PlanID).To(Equal(existingPlanID))
+			Expect(fakeInstanceLister.InstancesCallCount()).To(Equal(1))
+		})
+	})
+
+	It("returns an empty dashboard url when the adapter does not implement it", func() {
+		serviceAdapter.GenerateDashboardUrlReturns("", serviceadapter.NewNotImplementedError("not implemented"))
+
+		spec, err := b.GetInstance(context.Background(), instanceID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.DashboardURL).To(BeEmpty())
+		Expect(spec.PlanID).To(Equal(existingPlanID))
+	})
+
+	It("returns a 404 when the deployment does not exist", func() {
+		boshClient.GetDeploymentReturns(nil, false, nil)
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+	})
+
+	It("returns a 404 when the platform does not know about the instance", func() {
+		fakeInstanceLister.InstancesReturns([]service.Instance{}, nil)
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+	})
+
+	It("returns a 422 when an operation is in progress", func() {
+		boshClient.GetTasksReturns(boshdirector.BoshTasks{{State: boshdirector.TaskProcessing}}, nil)
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		expectFailureResponseWithStatus(err, http.StatusUnprocessableEntity)
+		Expect(serviceAdapter.GenerateDashboardUrlCallCount()).To(BeZero())
+	})
+
+	It("returns a generic error when bosh cannot be reached", func() {
+		boshClient.GetDeploymentReturns(nil, false, errors.New("bosh is down"))
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		Expect(err).To(MatchError(ContainSubstring("There was a problem completing your request")))
+		Expect(logBuffer.String()).To(ContainSubstring("error getting deployment service-instance_some-instance-id: bosh is down"))
+	})
+
+	It("returns a generic error when the instances cannot be listed", func() {
+		fakeInstanceLister.InstancesReturns(nil, errors.New("cf is down"))
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		Expect(err).To(MatchError(ContainSubstring("There was a problem completing your request")))
+		Expect(logBuffer.String()).To(ContainSubstring("cf is down"))
+	})
+
+	It("returns an error when the adapter fails to generate the dashboard url", func() {
+		serviceAdapter.GenerateDashboardUrlReturns("", serviceadapter.NewUnknownFailureError("adapter says no"))
+
+		_, err := b.GetInstance(context.Background(), instanceID)
+		Expect(err).To(MatchError("adapter says no"))
 	})
 })
+
+func expectFailureResponseWithStatus(err error, statusCode int) {
+	fresp, ok := err.(*brokerapi.FailureResponse)
+	Expect(ok).To(BeTrue(), "err wasn't a FailureResponse")
+	Expect(fresp.ValidatedStatusCode(lager.NewLogger("test"))).To(Equal(statusCode))
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import "sync"
+
+// instanceLocks serialises the deployment operations on each service
+// instance, while operations on different instances run concurrently.
+type instanceLocks struct {
+	lock  sync.Mutex
+	locks map[string]*instanceLock
+}
+
+type instanceLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func newInstanceLocks() *instanceLocks {
+	return &instanceLocks{locks: map[string]*instanceLock{}}
+}
+
+// acquire blocks until no other operation holds the lock for the instance.
+// The returned function releases it.
+func (l *instanceLocks) acquire(instanceID string) func() {
+	l.lock.Lock()
+	lock, found := l.locks[instanceID]
+	if !found {
+		lock = &instanceLock{}
+		l.locks[instanceID] = lock
+	}
+	lock.waiters++
+	l.lock.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, instanceID)
+		}
+	}
+}
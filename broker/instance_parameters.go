@@ -0,0 +1,163 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+)
+
+// UseParameterStore makes the broker keep the arbitrary parameters each
+// create and update deploys an instance with in store, and record a reference
+// to them in the operation journal, so that GetInstance can report them. They
+// are not recorded without a store, as the journal is a plaintext file.
+func (b *Broker) UseParameterStore(store ParameterStore) {
+	b.parameterStore = store
+}
+
+func parametersKey(serviceID, instanceID, operationID string) string {
+	return fmt.Sprintf("/c/%s/%s/%s/parameters", serviceID, instanceID, operationID)
+}
+
+// storeParameters stores the parameters an operation deploys an instance
+// with and returns the reference to record in the operation journal, which is
+// empty when they are not known or could not be stored.
+func (b *Broker) storeParameters(instanceID, operationID string, parameters map[string]interface{}, logger *log.Logger) string {
+	if parameters == nil {
+		return ""
+	}
+
+	key := parametersKey(b.serviceOffering.ID, instanceID, operationID)
+	if err := b.parameterStore.Set(key, parameters); err != nil {
+		loggerfactory.Errorf(logger, "error storing the parameters of operation %s of instance %s, they will not be reported: %s\n", operationID, instanceID, err)
+		return ""
+	}
+	return key
+}
+
+// storedParameters returns the parameters recorded for an operation, or nil
+// when they are not known.
+func (b *Broker) storedParameters(operation operationjournal.Operation, logger *log.Logger) map[string]interface{} {
+	if b.parameterStore == nil || operation.ParametersRef == "" {
+		return nil
+	}
+
+	value, err := b.parameterStore.Get(operation.ParametersRef)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reading the parameters of operation %s of instance %s: %s\n", operation.ID, operation.InstanceID, err)
+		return nil
+	}
+
+	parameters, ok := value.(map[string]interface{})
+	if !ok {
+		loggerfactory.Errorf(logger, "the parameters of operation %s of instance %s are not a JSON object\n", operation.ID, operation.InstanceID)
+		return nil
+	}
+	return parameters
+}
+
+// mergedParameters returns the arbitrary parameters of a create or update
+// request merged over the parameters the instance is deployed with, as the
+// service adapter applies them to the previous manifest. It returns nil when
+// the instance's parameters are not known.
+func (b *Broker) mergedParameters(instanceID string, operationType OperationType, parameters map[string]interface{}, logger *log.Logger) map[string]interface{} {
+	merged := map[string]interface{}{}
+	switch operationType {
+	case OperationTypeCreate:
+	case OperationTypeUpdate:
+		previous, found := b.journalledDeployment(instanceID, logger)
+		if !found {
+			return nil
+		}
+		previousParameters := b.storedParameters(previous, logger)
+		if previousParameters == nil {
+			return nil
+		}
+		for name, value := range previousParameters {
+			merged[name] = value
+		}
+	default:
+		return nil
+	}
+
+	for name, value := range parameters {
+		merged[name] = value
+	}
+	return merged
+}
+
+// deleteSupersededParameters deletes the stored parameters that an instance
+// is no longer deployed with once an operation has finished: those of a
+// failed create or update, those a successful create or update replaces, and
+// all of them once the instance has been deleted.
+func (b *Broker) deleteSupersededParameters(instanceID string, finished operationjournal.Operation, logger *log.Logger) {
+	if b.parameterStore == nil {
+		return
+	}
+
+	operations, err := b.operationJournal.Operations(instanceID)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reading the operations of instance %s from the operation journal: %s\n", instanceID, err)
+		return
+	}
+
+	index := len(operations)
+	for i, operation := range operations {
+		if operation.ID == finished.ID {
+			index = i
+		}
+	}
+
+	var superseded []operationjournal.Operation
+	switch {
+	case isDeployment(finished) && finished.State == string(brokerapi.Failed) && index < len(operations):
+		superseded = operations[index : index+1]
+	case isDeployment(finished) && finished.State == string(brokerapi.Succeeded):
+		superseded = liveDeployments(operations[:index])
+	case OperationType(finished.Type) == OperationTypeDelete && finished.State == string(brokerapi.Succeeded):
+		superseded = liveDeployments(operations)
+	}
+
+	for _, operation := range superseded {
+		if operation.ParametersRef == "" {
+			continue
+		}
+		if err := b.parameterStore.Delete(operation.ParametersRef); err != nil {
+			loggerfactory.Errorf(logger, "WARNING: failed to remove the parameters of operation %s of instance %s: %s\n", operation.ID, instanceID, err)
+		}
+	}
+}
+
+// liveDeployments returns the creates and updates whose parameters may still
+// be stored: those that have not failed, back to the latest that succeeded,
+// as the ones before it were deleted when it succeeded.
+func liveDeployments(operations []operationjournal.Operation) []operationjournal.Operation {
+	var live []operationjournal.Operation
+	for index := len(operations) - 1; index >= 0; index-- {
+		operation := operations[index]
+		if !isDeployment(operation) || operation.State == string(brokerapi.Failed) {
+			continue
+		}
+		live = append(live, operation)
+		if operation.State == string(brokerapi.Succeeded) {
+			break
+		}
+	}
+	return live
+}
+
+func isDeployment(operation operationjournal.Operation) bool {
+	switch OperationType(operation.Type) {
+	case OperationTypeCreate, OperationTypeUpdate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"errors"
+	"log"
+
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+// ReconcileInstanceRegistry brings the instance registry in line with the
+// service instance deployments in BOSH. Instances missing from the registry
+// are registered with the plan the operation journal records them as
+// deployed with. Those the journal has no plan for are left out, as the
+// registry would otherwise count them against no plan.
+func (b *Broker) ReconcileInstanceRegistry(logger *log.Logger) (added, removed []string, err error) {
+	registry, ok := b.instanceLister.(InstanceRegistry)
+	if !ok {
+		return nil, nil, errors.New("the instance registry is not enabled for this broker")
+	}
+	if b.operationJournal == nil {
+		return nil, nil, errors.New("the operation journal must be enabled to reconcile the instance registry, as it records the plans of the instances")
+	}
+
+	deployments, err := b.boshClient.GetDeployments(logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error getting deployments: %s", err)
+		return nil, nil, err
+	}
+
+	var deployed []service.Instance
+	for _, deployment := range deployments {
+		if !b.ownsDeployment(deployment.Name) {
+			continue
+		}
+		id := b.instanceID(deployment.Name)
+		planID, err := b.deployedPlanID(id, logger)
+		if err != nil {
+			loggerfactory.Errorf(logger, "error getting the plan of instance %s: %s", id, err)
+			return nil, nil, err
+		}
+		if planID == "" {
+			loggerfactory.Errorf(logger, "not registering instance %s in the instance registry, as the plan it was deployed with is unknown", id)
+			continue
+		}
+		deployed = append(deployed, service.Instance{GUID: id, PlanUniqueID: planID})
+	}
+
+	added, removed, err = registry.Reconcile(deployed)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reconciling the instance registry: %s", err)
+		return nil, nil, err
+	}
+
+	logger.Printf("reconciled the instance registry with BOSH, registered instances %v and deregistered instances %v\n", added, removed)
+	return added, removed, nil
+}
+
+func (b *Broker) registerInstance(instanceID, planID string, logger *log.Logger) {
+	registry, ok := b.instanceLister.(InstanceRegistry)
+	if !ok {
+		return
+	}
+
+	if err := registry.Register(service.Instance{GUID: instanceID, PlanUniqueID: planID}); err != nil {
+		loggerfactory.Errorf(logger, "error registering instance %s in the instance registry: %s\n", instanceID, err)
+	}
+}
+
+func (b *Broker) deregisterInstance(instanceID string, logger *log.Logger) {
+	registry, ok := b.instanceLister.(InstanceRegistry)
+	if !ok {
+		return
+	}
+
+	if err := registry.Deregister(instanceID); err != nil {
+		loggerfactory.Errorf(logger, "error deregistering instance %s from the instance registry: %s\n", instanceID, err)
+	}
+}
+
+func (b *Broker) lastRecordedPlanID(instanceID string, logger *log.Logger) string {
+	if b.operationJournal == nil {
+		return ""
+	}
+
+	operations, err := b.operationJournal.Operations(instanceID)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reading the operations of instance %s from the operation journal: %s\n", instanceID, err)
+		return ""
+	}
+
+	for i := len(operations) - 1; i >= 0; i-- {
+		if operations[i].PlanID != "" {
+			return operations[i].PlanID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	brokerfakes "github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+var _ = Describe("Instance registry", func() {
+	const instanceID = "some-instance"
+
+	var registry *brokerfakes.FakeInstanceRegistry
+
+	BeforeEach(func() {
+		registry = new(brokerfakes.FakeInstanceRegistry)
+
+		var err error
+		b, err = broker.New(boshClient, cfClient, serviceCatalog, brokerConfig, nil, serviceAdapter, fakeDeployer, fakeSecretManager, registry, fakeMapHasher, fakeOperationJournal, loggerFactory)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("registers provisioned instances", func() {
+		boshClient.GetDeploymentReturns(nil, false, nil)
+		fakeDeployer.CreateReturns(42, []byte("manifest"), nil)
+
+		_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{PlanID: existingPlanID}, true)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.RegisterCallCount()).To(Equal(1))
+		Expect(registry.RegisterArgsForCall(0)).To(Equal(service.Instance{GUID: instanceID, PlanUniqueID: existingPlanID}))
+	})
+
+	It("does not register instances that fail to provision", func() {
+		boshClient.GetDeploymentReturns(nil, false, nil)
+		fakeDeployer.CreateReturns(0, nil, errors.New("bosh unavailable"))
+
+		_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{PlanID: existingPlanID}, true)
+
+		Expect(err).To(HaveOccurred())
+		Expect(registry.RegisterCallCount()).To(BeZero())
+	})
+
+	It("does not register the new plan of instances while they are being updated", func() {
+		fakeDeployer.UpdateReturns(42, []byte("manifest"), nil)
+
+		_, err := b.Update(context.Background(), instanceID, brokerapi.UpdateDetails{
+			PlanID:         secondPlanID,
+			PreviousValues: brokerapi.PreviousValues{PlanID: existingPlanID},
+		}, true)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.RegisterCallCount()).To(BeZero())
+	})
+
+	It("registers the new plan of instances once their update has succeeded", func() {
+		operationData, err := json.Marshal(broker.OperationData{OperationType: broker.OperationTypeUpdate, BoshTaskID: 42, PlanID: secondPlanID})
+		Expect(err).NotTo(HaveOccurred())
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskDone}, nil)
+
+		_, err = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.RegisterCallCount()).To(Equal(1))
+		Expect(registry.RegisterArgsForCall(0)).To(Equal(service.Instance{GUID: instanceID, PlanUniqueID: secondPlanID}))
+	})
+
+	It("keeps the previous plan of instances whose update failed", func() {
+		operationData, err := json.Marshal(broker.OperationData{OperationType: broker.OperationTypeUpdate, BoshTaskID: 42, PlanID: secondPlanID})
+		Expect(err).NotTo(HaveOccurred())
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskError}, nil)
+
+		_, err = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.RegisterCallCount()).To(BeZero())
+	})
+
+	It("deregisters instances once their deployment has been deleted", func() {
+		operationData, err := json.Marshal(broker.OperationData{OperationType: broker.OperationTypeDelete, BoshTaskID: 42})
+		Expect(err).NotTo(HaveOccurred())
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskDone}, nil)
+
+		_, err = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.DeregisterCallCount()).To(Equal(1))
+		Expect(registry.DeregisterArgsForCall(0)).To(Equal(instanceID))
+	})
+
+	It("keeps instances registered while their deployment is being deleted", func() {
+		operationData, err := json.Marshal(broker.OperationData{OperationType: broker.OperationTypeDelete, BoshTaskID: 42})
+		Expect(err).NotTo(HaveOccurred())
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskProcessing}, nil)
+
+		_, err = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.DeregisterCallCount()).To(BeZero())
+	})
+
+	It("deregisters instances whose deployment is not found when deprovisioning", func() {
+		boshClient.GetDeploymentReturns(nil, false, nil)
+
+		_, err := b.Deprovision(context.Background(), instanceID, brokerapi.DeprovisionDetails{PlanID: existingPlanID}, true)
+
+		Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+		Expect(registry.DeregisterCallCount()).To(Equal(1))
+		Expect(registry.DeregisterArgsForCall(0)).To(Equal(instanceID))
+	})
+
+	Describe("ReconcileInstanceRegistry", func() {
+		It("reconciles the registry with the service instance deployments", func() {
+			boshClient.GetDeploymentsReturns([]boshdirector.Deployment{
+				{Name: broker.InstancePrefix + "instance-a"},
+				{Name: broker.InstancePrefix + "instance-b"},
+				{Name: "some-other-deployment"},
+			}, nil)
+			fakeOperationJournal.OperationsStub = func(instanceID string) ([]operationjournal.Operation, error) {
+				if instanceID != "instance-a" {
+					return nil, nil
+				}
+				return []operationjournal.Operation{
+					{ID: "1", InstanceID: instanceID, Type: "create", PlanID: existingPlanID, State: string(brokerapi.Succeeded)},
+					{ID: "2", InstanceID: instanceID, Type: "update", PlanID: secondPlanID, State: string(brokerapi.Succeeded)},
+					{ID: "3", InstanceID: instanceID, Type: "update", PlanID: existingPlanID, State: string(brokerapi.Failed)},
+					{ID: "4", InstanceID: instanceID, Type: "backup", State: string(brokerapi.Succeeded)},
+				}, nil
+			}
+			registry.InstancesReturns([]service.Instance{{GUID: "instance-b", PlanUniqueID: existingPlanID}}, nil)
+			registry.ReconcileReturns([]string{"instance-a"}, []string{"instance-c"}, nil)
+
+			added, removed, err := b.ReconcileInstanceRegistry(loggerFactory.NewWithRequestID())
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added).To(Equal([]string{"instance-a"}))
+			Expect(removed).To(Equal([]string{"instance-c"}))
+			Expect(registry.ReconcileArgsForCall(0)).To(Equal([]service.Instance{
+				{GUID: "instance-a", PlanUniqueID: secondPlanID},
+				{GUID: "instance-b", PlanUniqueID: existingPlanID},
+			}))
+		})
+
+		It("does not register instances whose plan is unknown", func() {
+			boshClient.GetDeploymentsReturns([]boshdirector.Deployment{{Name: broker.InstancePrefix + "instance-a"}}, nil)
+			fakeOperationJournal.OperationsReturns(nil, nil)
+			registry.InstancesReturns(nil, nil)
+
+			_, _, err := b.ReconcileInstanceRegistry(loggerFactory.NewWithRequestID())
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(registry.ReconcileArgsForCall(0)).To(BeEmpty())
+			Expect(logBuffer.String()).To(ContainSubstring("not registering instance instance-a in the instance registry, as the plan it was deployed with is unknown"))
+		})
+
+		It("fails when the operation journal is not enabled", func() {
+			var err error
+			b, err = broker.New(boshClient, cfClient, serviceCatalog, brokerConfig, nil, serviceAdapter, fakeDeployer, fakeSecretManager, registry, fakeMapHasher, nil, loggerFactory)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = b.ReconcileInstanceRegistry(loggerFactory.NewWithRequestID())
+
+			Expect(err).To(MatchError("the operation journal must be enabled to reconcile the instance registry, as it records the plans of the instances"))
+			Expect(registry.ReconcileCallCount()).To(BeZero())
+		})
+
+		It("fails when the deployments cannot be listed", func() {
+			boshClient.GetDeploymentsReturns(nil, errors.New("bosh unavailable"))
+
+			_, _, err := b.ReconcileInstanceRegistry(loggerFactory.NewWithRequestID())
+
+			Expect(err).To(MatchError("bosh unavailable"))
+			Expect(registry.ReconcileCallCount()).To(BeZero())
+		})
+
+		It("fails when the instance registry is not enabled", func() {
+			b = createDefaultBroker()
+
+			_, _, err := b.ReconcileInstanceRegistry(loggerFactory.NewWithRequestID())
+
+			Expect(err).To(MatchError("the instance registry is not enabled for this broker"))
+		})
+	})
+})
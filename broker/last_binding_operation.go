@@ -1,13 +1,113 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
 package broker
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
 
+	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
 )
 
 func (b *Broker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
-	err := brokerapi.NewFailureResponse(errors.New("LastBindingOperation Not Implemented"), 404, "")
-	return brokerapi.LastOperation{}, err
+	requestID := uuid.New()
+	if len(brokercontext.GetReqID(ctx)) > 0 {
+		requestID = brokercontext.GetReqID(ctx)
+	}
+
+	ctx = brokercontext.New(ctx, "", requestID, b.serviceOffering.Name, instanceID)
+	logger := b.loggerFactory.NewWithContext(ctx)
+
+	var operationData OperationData
+	if details.OperationData != "" {
+		if err := json.Unmarshal([]byte(details.OperationData), &operationData); err != nil {
+			return brokerapi.LastOperation{}, b.processError(NewGenericError(ctx, fmt.Errorf("operation data cannot be parsed: %s", err)), logger)
+		}
+		ctx = brokercontext.WithOperation(ctx, string(operationData.OperationType))
+	}
+
+	operation, found := b.bindingOperations.get(instanceID, bindingID)
+	if !found {
+		return b.journalledBindingOperation(ctx, instanceID, bindingID, operationData, logger)
+	}
+
+	ctx = brokercontext.WithOperation(ctx, string(operation.operationType))
+	lastOperation := b.bindingOperationOutcome(ctx, operation.operationType, operation.state, operation.err)
+
+	switch operation.state {
+	case brokerapi.Failed:
+		b.bindingOperations.forget(instanceID, bindingID)
+	case brokerapi.Succeeded:
+		if operation.operationType == OperationTypeUnbind {
+			b.bindingOperations.forget(instanceID, bindingID)
+		}
+	}
+
+	logger.Printf("%s operation for binding %s of instance %s: %s\n", operation.operationType, bindingID, instanceID, operation.state)
+	return lastOperation, nil
+}
+
+// bindingOperationOutcome describes the state of an asynchronous binding
+// operation as it is reported to the platform and recorded in the operation
+// journal.
+func (b *Broker) bindingOperationOutcome(ctx context.Context, operationType OperationType, state brokerapi.LastOperationState, err error) brokerapi.LastOperation {
+	description := descriptions[state][operationType]
+	if state == brokerapi.Failed {
+		description = fmt.Sprintf("%s: %s", description, adapterToAPIError(ctx, err))
+		if b.ExposeOperationalErrors {
+			description = fmt.Sprintf("%s, error-message: %s", description, err)
+		}
+	}
+	return brokerapi.LastOperation{State: state, Description: description}
+}
+
+// journalledBindingOperation reports a binding operation this broker no longer
+// holds, because its outcome has already been collected, it has expired, or
+// the broker restarted while it ran. Its outcome is read from the operation
+// journal. When no outcome is known, the operation is reported as in progress
+// rather than failed, as the service adapter may still have created or deleted
+// the binding, until bindingOperationDeadline has passed since it started.
+func (b *Broker) journalledBindingOperation(ctx context.Context, instanceID, bindingID string, operationData OperationData, logger *log.Logger) (brokerapi.LastOperation, error) {
+	operationType := operationData.OperationType
+	if b.operationJournal != nil && operationType != "" {
+		operations, err := b.operationJournal.Operations(instanceID)
+		if err != nil {
+			return brokerapi.LastOperation{}, b.processError(NewGenericError(ctx, fmt.Errorf("error reading the operations of instance %s from the operation journal: %s", instanceID, err)), logger)
+		}
+
+		id := bindingOperationID(operationType, bindingID)
+		for _, operation := range operations {
+			if operation.ID == id && operation.State != string(brokerapi.InProgress) {
+				logger.Printf("%s operation for binding %s of instance %s: %s, as recorded in the operation journal\n", operationType, bindingID, instanceID, operation.State)
+				return brokerapi.LastOperation{State: brokerapi.LastOperationState(operation.State), Description: operation.Description}, nil
+			}
+		}
+	}
+
+	if time.Since(time.Unix(operationData.StartedAt, 0)) > bindingOperationDeadline {
+		logger.Printf("no outcome is known for the %s operation for binding %s of instance %s, reporting it as failed as it started more than %s ago\n", operationType, bindingID, instanceID, bindingOperationDeadline)
+		lastOperation := brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: fmt.Sprintf("%s: the outcome is unknown, the broker may have restarted while it ran", descriptions[brokerapi.Failed][operationType]),
+		}
+		if operationType != "" {
+			b.recordBindingOperation(ctx, instanceID, bindingID, operationType, lastOperation, logger)
+		}
+		return lastOperation, nil
+	}
+
+	logger.Printf("no outcome is known for the %s operation for binding %s of instance %s, reporting it as in progress\n", operationType, bindingID, instanceID)
+	return brokerapi.LastOperation{
+		State:       brokerapi.InProgress,
+		Description: descriptions[brokerapi.InProgress][operationType],
+	}, nil
 }
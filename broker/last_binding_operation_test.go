@@ -2,20 +2,341 @@ package broker_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
 
-	"code.cloudfoundry.org/lager"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
 var _ = Describe("LastBindingOperation", func() {
-	It("returns an appropriate error", func() {
-		_, err := b.LastBindingOperation(context.Background(), "instanceID", "bID", brokerapi.PollDetails{})
-		fresp, ok := err.(*brokerapi.FailureResponse)
-		Expect(ok).To(BeTrue(), "err wasn't a FailureResponse")
-		logger := lager.NewLogger("test")
-		Expect(fresp.ValidatedStatusCode(logger)).To(Equal(404))
+	const (
+		instanceID = "some-instance-id"
+		bindingID  = "some-binding-id"
+	)
+
+	var (
+		bindDetails   = brokerapi.BindDetails{PlanID: existingPlanID, ServiceID: serviceOfferingID, AppGUID: "app-guid"}
+		unbindDetails = brokerapi.UnbindDetails{PlanID: existingPlanID, ServiceID: serviceOfferingID}
+
+		adapterDone chan struct{}
+	)
+
+	pollBinding := func(operationData string) brokerapi.LastOperation {
+		lastOperation, err := b.LastBindingOperation(context.Background(), instanceID, bindingID, brokerapi.PollDetails{OperationData: operationData})
+		Expect(err).NotTo(HaveOccurred())
+		return lastOperation
+	}
+
+	BeforeEach(func() {
+		adapterDone = make(chan struct{})
+		done := adapterDone
+
+		boshClient.GetDeploymentReturns([]byte("name: service-instance_some-instance-id"), true, nil)
+		boshClient.VMsReturns(bosh.BoshVMs{"redis-server": []string{"an.ip"}}, nil)
+		fakeSecretManager.ResolveManifestSecretsReturns(map[string]string{}, nil)
+		serviceAdapter.CreateBindingStub = func(context.Context, string, bosh.BoshVMs, []byte, map[string]interface{}, map[string]string, map[string]string, *log.Logger) (sdk.Binding, error) {
+			<-done
+			return sdk.Binding{
+				Credentials:    map[string]interface{}{"password": "secret"},
+				SyslogDrainURL: "syslog://drain",
+			}, nil
+		}
+		serviceAdapter.DeleteBindingStub = func(context.Context, string, bosh.BoshVMs, []byte, map[string]interface{}, map[string]string, map[string]string, *log.Logger) error {
+			<-done
+			return nil
+		}
+
+		brokerConfig.EnableAsyncBindings = true
+		b = createBrokerWithServiceCatalog(serviceCatalog)
+	})
+
+	It("reports the progress of an asynchronous bind", func() {
+		binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.IsAsync).To(BeTrue())
+		Expect(binding.Credentials).To(BeNil())
+
+		var operationData broker.OperationData
+		Expect(json.Unmarshal([]byte(binding.OperationData), &operationData)).To(Succeed())
+		Expect(operationData.OperationType).To(Equal(broker.OperationTypeBind))
+
+		Expect(pollBinding(binding.OperationData)).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: "Binding in progress",
+		}))
+
+		By("not returning the binding while it is being created")
+		_, err = b.GetBinding(context.Background(), instanceID, bindingID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+
+		close(adapterDone)
+
+		Eventually(func() brokerapi.LastOperationState {
+			return pollBinding(binding.OperationData).State
+		}).Should(Equal(brokerapi.Succeeded))
+		Expect(pollBinding(binding.OperationData).Description).To(Equal("Binding completed"))
+
+		By("returning the created binding without calling the adapter again")
+		spec, err := b.GetBinding(context.Background(), instanceID, bindingID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(brokerapi.GetBindingSpec{
+			Credentials:    map[string]interface{}{"password": "secret"},
+			SyslogDrainURL: "syslog://drain",
+		}))
+		Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
+
+		By("returning the binding again when it is read again")
+		spec, err = b.GetBinding(context.Background(), instanceID, bindingID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Credentials).To(Equal(map[string]interface{}{"password": "secret"}))
+		Expect(serviceAdapter.CreateBindingCallCount()).To(Equal(1))
+	})
+
+	It("reports a failed asynchronous bind", func() {
+		serviceAdapter.CreateBindingStub = nil
+		serviceAdapter.CreateBindingReturns(sdk.Binding{}, serviceadapter.NewUnknownFailureError("the database is full"))
+
+		binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		var lastOperation brokerapi.LastOperation
+		Eventually(func() brokerapi.LastOperationState {
+			lastOperation = pollBinding(binding.OperationData)
+			return lastOperation.State
+		}).Should(Equal(brokerapi.Failed))
+		Expect(lastOperation.Description).To(Equal("Binding failed: the database is full"))
+
+		By("recording the failure in the operation journal")
+		recorded := fakeOperationJournal.RecordArgsForCall(fakeOperationJournal.RecordCallCount() - 1)
+		Expect(recorded.State).To(Equal(string(brokerapi.Failed)))
+		Expect(recorded.Description).To(Equal("Binding failed: the database is full"))
+
+		By("reporting the journalled failure once it has been forgotten")
+		fakeOperationJournal.OperationsReturns([]operationjournal.Operation{recorded}, nil)
+		Expect(pollBinding(binding.OperationData)).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: "Binding failed: the database is full",
+		}))
+	})
+
+	It("records the outcome of an asynchronous bind when it finishes, without waiting to be polled", func() {
+		_, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		Expect(fakeOperationJournal.RecordArgsForCall(0).State).To(Equal(string(brokerapi.InProgress)))
+
+		close(adapterDone)
+
+		Eventually(fakeOperationJournal.RecordCallCount).Should(Equal(2))
+		recorded := fakeOperationJournal.RecordArgsForCall(1)
+		Expect(recorded.ID).To(Equal("bind-" + bindingID))
+		Expect(recorded.State).To(Equal(string(brokerapi.Succeeded)))
+		Expect(recorded.Description).To(Equal("Binding completed"))
+	})
+
+	Context("when the broker keeps bindings in a binding store", func() {
+		var bindingStore *fakes.FakeBindingStore
+
+		BeforeEach(func() {
+			bindingStore = new(fakes.FakeBindingStore)
+			b.UseBindingStore(bindingStore)
+		})
+
+		It("stores the created binding before reporting the bind as succeeded", func() {
+			binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bindingStore.StoreBindingCallCount()).To(BeZero())
+
+			close(adapterDone)
+
+			Eventually(func() brokerapi.LastOperationState {
+				return pollBinding(binding.OperationData).State
+			}).Should(Equal(brokerapi.Succeeded))
+			Expect(bindingStore.StoreBindingCallCount()).To(Equal(1))
+			_, storedInstanceID, storedBindingID, details, stored := bindingStore.StoreBindingArgsForCall(0)
+			Expect(storedInstanceID).To(Equal(instanceID))
+			Expect(storedBindingID).To(Equal(bindingID))
+			Expect(details).To(Equal(bindDetails))
+			Expect(stored).To(Equal(brokerapi.Binding{
+				Credentials:    map[string]interface{}{"password": "secret"},
+				SyslogDrainURL: "syslog://drain",
+			}))
+		})
+
+		It("reports the bind as failed when the binding cannot be stored", func() {
+			bindingStore.StoreBindingReturns(errors.New("credhub unavailable"))
+
+			binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			close(adapterDone)
+
+			Eventually(func() brokerapi.LastOperationState {
+				return pollBinding(binding.OperationData).State
+			}).Should(Equal(brokerapi.Failed))
+			Expect(logBuffer.String()).To(ContainSubstring("storing binding: credhub unavailable"))
+
+			_, err = b.GetBinding(context.Background(), instanceID, bindingID)
+			expectFailureResponseWithStatus(err, http.StatusNotFound)
+		})
+
+		It("removes the binding from the store once an asynchronous unbind has succeeded", func() {
+			unbindSpec, err := b.Unbind(context.Background(), instanceID, bindingID, unbindDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bindingStore.DeleteBindingCallCount()).To(BeZero())
+
+			close(adapterDone)
+
+			Eventually(func() brokerapi.LastOperationState {
+				return pollBinding(unbindSpec.OperationData).State
+			}).Should(Equal(brokerapi.Succeeded))
+			Expect(bindingStore.DeleteBindingCallCount()).To(Equal(1))
+			_, deletedInstanceID, deletedBindingID, serviceID := bindingStore.DeleteBindingArgsForCall(0)
+			Expect(deletedInstanceID).To(Equal(instanceID))
+			Expect(deletedBindingID).To(Equal(bindingID))
+			Expect(serviceID).To(Equal(serviceOfferingID))
+		})
+
+		It("keeps the binding in the store when an asynchronous unbind fails", func() {
+			serviceAdapter.DeleteBindingStub = nil
+			serviceAdapter.DeleteBindingReturns(errors.New("the database is down"))
+
+			unbindSpec, err := b.Unbind(context.Background(), instanceID, bindingID, unbindDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			close(adapterDone)
+
+			Eventually(func() brokerapi.LastOperationState {
+				return pollBinding(unbindSpec.OperationData).State
+			}).Should(Equal(brokerapi.Failed))
+			Expect(bindingStore.DeleteBindingCallCount()).To(BeZero())
+		})
+	})
+
+	It("rejects a second bind while the first one is in progress", func() {
+		_, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		expectFailureResponseWithStatus(err, http.StatusUnprocessableEntity)
+
+		close(adapterDone)
+	})
+
+	It("binds synchronously when the platform does not accept incomplete operations", func() {
+		close(adapterDone)
+
+		binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.IsAsync).To(BeFalse())
+		Expect(binding.Credentials).To(Equal(map[string]interface{}{"password": "secret"}))
+	})
+
+	It("reports the progress of an asynchronous unbind", func() {
+		unbindSpec, err := b.Unbind(context.Background(), instanceID, bindingID, unbindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbindSpec.IsAsync).To(BeTrue())
+
+		Expect(pollBinding(unbindSpec.OperationData)).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: "Unbinding in progress",
+		}))
+
+		close(adapterDone)
+
+		Eventually(func() brokerapi.LastOperationState {
+			return pollBinding(unbindSpec.OperationData).State
+		}).Should(Equal(brokerapi.Succeeded))
+		Expect(serviceAdapter.DeleteBindingCallCount()).To(Equal(1))
+	})
+
+	It("reports the outcome recorded in the operation journal when the broker no longer holds the operation", func() {
+		fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+			{ID: "unbind-" + bindingID, InstanceID: instanceID, BindingID: bindingID, Type: "unbind", State: "succeeded", Description: "Unbinding completed"},
+		}, nil)
+
+		Expect(pollBinding(`{"OperationType":"unbind"}`)).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.Succeeded,
+			Description: "Unbinding completed",
+		}))
+		Expect(fakeOperationJournal.OperationsArgsForCall(0)).To(Equal(instanceID))
+	})
+
+	It("reports an operation whose outcome is not known as in progress rather than failed while it may still be running", func() {
+		fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+			{ID: "bind-" + bindingID, InstanceID: instanceID, BindingID: bindingID, Type: "bind", State: "in progress"},
+		}, nil)
+
+		operationData := fmt.Sprintf(`{"OperationType":"bind","StartedAt":%d}`, time.Now().Add(-time.Minute).Unix())
+		Expect(pollBinding(operationData)).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: "Binding in progress",
+		}))
+		Expect(fakeOperationJournal.RecordCallCount()).To(BeZero())
+	})
+
+	It("reports an operation whose outcome is not known as failed once its deadline has passed", func() {
+		fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+			{ID: "bind-" + bindingID, InstanceID: instanceID, BindingID: bindingID, Type: "bind", State: "in progress"},
+		}, nil)
+
+		operationData := fmt.Sprintf(`{"OperationType":"bind","StartedAt":%d}`, time.Now().Add(-2*time.Hour).Unix())
+		lastOperation := pollBinding(operationData)
+		Expect(lastOperation).To(Equal(brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: "Binding failed: the outcome is unknown, the broker may have restarted while it ran",
+		}))
+
+		By("recording the failure in the operation journal")
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		recorded := fakeOperationJournal.RecordArgsForCall(0)
+		Expect(recorded.ID).To(Equal("bind-" + bindingID))
+		Expect(recorded.State).To(Equal(string(brokerapi.Failed)))
+		Expect(recorded.Description).To(Equal(lastOperation.Description))
+	})
+
+	It("returns an error when the operation journal cannot be read", func() {
+		fakeOperationJournal.OperationsReturns(nil, errors.New("disk on fire"))
+
+		_, err := b.LastBindingOperation(context.Background(), instanceID, bindingID, brokerapi.PollDetails{OperationData: `{"OperationType":"bind"}`})
+		Expect(err).To(MatchError(ContainSubstring("There was a problem completing your request")))
+		Expect(logBuffer.String()).To(ContainSubstring("error reading the operations of instance some-instance-id from the operation journal: disk on fire"))
+	})
+
+	It("stops returning a created binding once it has been unbound synchronously", func() {
+		binding, err := b.Bind(context.Background(), instanceID, bindingID, bindDetails, true)
+		Expect(err).NotTo(HaveOccurred())
+		close(adapterDone)
+		Eventually(func() brokerapi.LastOperationState {
+			return pollBinding(binding.OperationData).State
+		}).Should(Equal(brokerapi.Succeeded))
+
+		_, err = b.Unbind(context.Background(), instanceID, bindingID, unbindDetails, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = b.GetBinding(context.Background(), instanceID, bindingID)
+		expectFailureResponseWithStatus(err, http.StatusNotFound)
+	})
+
+	It("returns an error when the operation data cannot be parsed", func() {
+		_, err := b.LastBindingOperation(context.Background(), instanceID, bindingID, brokerapi.PollDetails{OperationData: "{not-json"})
+		Expect(err).To(MatchError(ContainSubstring("There was a problem completing your request")))
+		Expect(logBuffer.String()).To(ContainSubstring("operation data cannot be parsed"))
+	})
+
+	It("does not advertise bindings as retrievable, as it only holds their results for a while", func() {
+		services, err := b.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services[0].BindingsRetrievable).To(BeFalse())
 	})
 })
@@ -17,6 +17,7 @@ import (
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 )
 
 var descriptions = map[brokerapi.LastOperationState]map[OperationType]string{
@@ -26,6 +27,10 @@ var descriptions = map[brokerapi.LastOperationState]map[OperationType]string{
 		OperationTypeUpgrade:  "Instance upgrade in progress",
 		OperationTypeDelete:   "Instance deletion in progress",
 		OperationTypeRecreate: "Instance recreate in progress",
+		OperationTypeBind:     "Binding in progress",
+		OperationTypeUnbind:   "Unbinding in progress",
+		OperationTypeBackup:   "Instance backup in progress",
+		OperationTypeRestore:  "Instance restore in progress",
 	},
 	brokerapi.Succeeded: {
 		OperationTypeCreate:   "Instance provisioning completed",
@@ -33,6 +38,10 @@ var descriptions = map[brokerapi.LastOperationState]map[OperationType]string{
 		OperationTypeUpgrade:  "Instance upgrade completed",
 		OperationTypeDelete:   "Instance deletion completed",
 		OperationTypeRecreate: "Instance recreate completed",
+		OperationTypeBind:     "Binding completed",
+		OperationTypeUnbind:   "Unbinding completed",
+		OperationTypeBackup:   "Instance backup completed",
+		OperationTypeRestore:  "Instance restore completed",
 	},
 	brokerapi.Failed: {
 		OperationTypeCreate:   "Instance provisioning failed",
@@ -40,6 +49,10 @@ var descriptions = map[brokerapi.LastOperationState]map[OperationType]string{
 		OperationTypeUpgrade:  "Failed for bosh task",
 		OperationTypeDelete:   "Instance deletion failed",
 		OperationTypeRecreate: "Instance recreate failed",
+		OperationTypeBind:     "Binding failed",
+		OperationTypeUnbind:   "Unbinding failed",
+		OperationTypeBackup:   "Instance backup failed",
+		OperationTypeRestore:  "Instance restore failed",
 	},
 }
 
@@ -63,51 +76,93 @@ func (b *Broker) LastOperation(ctx context.Context, instanceID string, pollDetai
 
 	ctx = brokercontext.WithOperation(ctx, string(operationData.OperationType))
 
+	// The platform, the instance iterator and the broker's own operation
+	// watcher can poll the same instance, and a poll can start its next
+	// lifecycle errand, so polls of an instance are serialised.
+	defer b.pollLocks.acquire(instanceID)()
+
 	if operationData.BoshTaskID == 0 {
 		return brokerapi.LastOperation{}, b.processError(NewGenericError(ctx, errors.New("no task ID found in operation data")), logger)
 	}
 
 	ctx = brokercontext.WithBoshTaskID(ctx, operationData.BoshTaskID)
+	ctx = brokercontext.WithPlanID(ctx, operationData.PlanID)
+	logger = b.loggerFactory.NewWithContext(ctx)
+
+	rollbackOperation, rollingBack, err := b.upgradeRollback(ctx, instanceID, operationData, logger)
+	if err != nil {
+		return brokerapi.LastOperation{}, b.processError(
+			NewGenericError(ctx, fmt.Errorf("error retrieving tasks from bosh, for deployment '%s': %s", b.deploymentName(instanceID), err)),
+			logger,
+		)
+	}
+	if rollingBack {
+		return rollbackOperation, nil
+	}
 
 	lifeCycleRunner := NewLifeCycleRunner(b.boshClient, b.serviceOffering.Plans)
+	lifeCycleRunner.StartOperation = func(deploymentName string, operationData OperationData, logger *log.Logger) (int, error) {
+		return b.startOperationAfterErrands(ctx, deploymentName, operationData, logger)
+	}
 
 	// if the errand isn't already running, or delete deployment wasn't triggered, GetTask will start it!
-	lastBoshTask, err := lifeCycleRunner.GetTask(deploymentName(instanceID), operationData, logger)
+	lastBoshTask, errandAttempt, err := lifeCycleRunner.GetTaskAndErrandAttempt(b.deploymentName(instanceID), operationData, logger)
 	if err != nil {
 		return brokerapi.LastOperation{}, b.processError(
-			NewGenericError(ctx, fmt.Errorf("error retrieving tasks from bosh, for deployment '%s': %s", deploymentName(instanceID), err)),
+			NewGenericError(ctx, fmt.Errorf("error retrieving tasks from bosh, for deployment '%s': %s", b.deploymentName(instanceID), err)),
 			logger,
 		)
 	}
 
 	if operationData.OperationType == OperationTypeDelete && lastBoshTask.StateType() == boshdirector.TaskComplete {
 		if !b.DisableBoshConfigs {
-			if err = b.boshClient.DeleteConfigs(deploymentName(instanceID), logger); err != nil {
+			if err = b.boshClient.DeleteConfigs(b.deploymentName(instanceID), logger); err != nil {
 				ctx = brokercontext.WithBoshTaskID(ctx, 0)
-				lastOperation := constructLastOperation(ctx, brokerapi.Failed, lastBoshTask, operationData, b.ExposeOperationalErrors)
-				logger.Printf("Failed to delete configs for service instance %s: %s\n", instanceID, err.Error())
+				lastOperation := constructLastOperation(ctx, brokerapi.Failed, lastBoshTask, errandAttempt, operationData, b.ExposeOperationalErrors)
+				loggerfactory.Errorf(logger, "Failed to delete configs for service instance %s: %s\n", instanceID, err.Error())
+				b.recordFinishedOperation(ctx, instanceID, operationData, lastBoshTask, lastOperation, logger)
 				return lastOperation, nil
 			}
 		}
 
 		if err = b.secretManager.DeleteSecretsForInstance(instanceID, logger); err != nil {
 			ctx = brokercontext.WithBoshTaskID(ctx, 0)
-			lastOperation := constructLastOperation(ctx, brokerapi.Failed, lastBoshTask, operationData, b.ExposeOperationalErrors)
-			logger.Printf("Failed to delete credhub secrets for service instance %s. Credhub error: %s\n", instanceID, err.Error())
+			lastOperation := constructLastOperation(ctx, brokerapi.Failed, lastBoshTask, errandAttempt, operationData, b.ExposeOperationalErrors)
+			loggerfactory.Errorf(logger, "Failed to delete credhub secrets for service instance %s. Credhub error: %s\n", instanceID, err.Error())
+			b.recordFinishedOperation(ctx, instanceID, operationData, lastBoshTask, lastOperation, logger)
 			return lastOperation, nil
 		}
+
+		b.deregisterInstance(instanceID, logger)
 	}
 
 	ctx = brokercontext.WithBoshTaskID(ctx, lastBoshTask.ID)
+	logger = b.loggerFactory.NewWithContext(ctx)
 
 	taskState := lastOperationState(lastBoshTask, logger)
-	lastOperation := constructLastOperation(ctx, taskState, lastBoshTask, operationData, b.ExposeOperationalErrors)
+	if errandAttempt.Waiting {
+		taskState = brokerapi.InProgress
+	}
+	lastOperation := constructLastOperation(ctx, taskState, lastBoshTask, errandAttempt, operationData, b.ExposeOperationalErrors)
 	logLastOperation(instanceID, lastBoshTask, operationData, logger)
+	switch lastOperation.State {
+	case brokerapi.InProgress:
+		b.renewQuotaReservation(instanceID)
+	case brokerapi.Failed:
+		// The platform keeps counting an instance whose create failed, and keeps
+		// the old plan of one whose update failed.
+		b.releaseQuota(instanceID)
+	}
+	if lastOperation.State == brokerapi.Succeeded && operationData.OperationType == OperationTypeUpdate && operationData.PlanID != "" {
+		// The instance is only on the new plan once the update has succeeded.
+		b.registerInstance(instanceID, operationData.PlanID, logger)
+	}
+	b.recordFinishedOperation(ctx, instanceID, operationData, lastBoshTask, lastOperation, logger)
 
 	return lastOperation, nil
 }
 
-func constructLastOperation(ctx context.Context, taskState brokerapi.LastOperationState, lastBoshTask boshdirector.BoshTask, operationData OperationData, exposeError bool) brokerapi.LastOperation {
+func constructLastOperation(ctx context.Context, taskState brokerapi.LastOperationState, lastBoshTask boshdirector.BoshTask, errandAttempt ErrandAttempt, operationData OperationData, exposeError bool) brokerapi.LastOperation {
 	description := descriptions[taskState][operationData.OperationType]
 	if taskState == brokerapi.Failed {
 		if operationData.OperationType == OperationTypeUpgrade {
@@ -115,11 +170,14 @@ func constructLastOperation(ctx context.Context, taskState brokerapi.LastOperati
 		} else {
 			description = fmt.Sprintf(description+": %s", NewGenericError(ctx, nil).ErrorForCFUser())
 		}
+	}
 
-		if exposeError {
-			description = fmt.Sprintf("%s, error-message: %s", description, lastBoshTask.Result)
-		}
+	if errandAttempt.Attempts > 1 && taskState != brokerapi.Succeeded {
+		description = fmt.Sprintf("%s, errand %s attempt %d/%d", description, errandAttempt.Name, errandAttempt.Attempt, errandAttempt.Attempts)
+	}
 
+	if taskState == brokerapi.Failed && exposeError {
+		description = fmt.Sprintf("%s, error-message: %s", description, lastBoshTask.Result)
 	}
 	return brokerapi.LastOperation{State: taskState, Description: description}
 
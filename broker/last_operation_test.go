@@ -11,12 +11,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 )
 
 var _ = Describe("LastOperation", func() {
@@ -1147,4 +1152,365 @@ var _ = Describe("LastOperation", func() {
 			)
 		})
 	})
+
+	Context("when rolling back failed upgrades is enabled", func() {
+		const (
+			instanceID     = "an-upgraded-instance"
+			upgradeTaskID  = 199
+			rollbackTaskID = 200
+		)
+
+		var (
+			operationData  string
+			upgradeTasks   boshdirector.BoshTasks
+			rollbackTasks  boshdirector.BoshTasks
+			parameterStore *fakes.FakeParameterStore
+			opResult       brokerapi.LastOperation
+			lastOpErr      error
+		)
+
+		BeforeEach(func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			operationData = fmt.Sprintf(`{"BoshTaskID": %d, "OperationType": "upgrade", "BoshContextID": "some-context-id", "RollbackOnFailure": true}`, upgradeTaskID)
+			upgradeTasks = nil
+			rollbackTasks = nil
+			boshClient.GetNormalisedTasksByContextStub = func(deployment, contextID string, logger *log.Logger) (boshdirector.BoshTasks, error) {
+				return append(append(boshdirector.BoshTasks{}, rollbackTasks...), upgradeTasks...), nil
+			}
+			fakeDeployer.RollbackReturns(rollbackTaskID, nil)
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{{
+				ID:                  "199",
+				InstanceID:          instanceID,
+				Type:                "upgrade",
+				State:               "in progress",
+				RollbackSnapshotRef: "/c/some-snapshot-ref",
+			}}, nil)
+			parameterStore = new(fakes.FakeParameterStore)
+			parameterStore.GetReturns(map[string]interface{}{
+				"manifest": "name: previous-deployment",
+				"configs":  map[string]interface{}{"cloud": "previous-cloud-config"},
+			}, nil)
+		})
+
+		JustBeforeEach(func() {
+			b = createDefaultBroker()
+			b.UseParameterStore(parameterStore)
+			opResult, lastOpErr = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: operationData})
+		})
+
+		Context("when the upgrade task fails", func() {
+			BeforeEach(func() {
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskError, Result: "it broke"}}
+			})
+
+			It("rolls back to the stored snapshot of the previous deployment under the upgrade's context ID", func() {
+				Expect(lastOpErr).NotTo(HaveOccurred())
+				Expect(parameterStore.GetArgsForCall(0)).To(Equal("/c/some-snapshot-ref"))
+				Expect(fakeDeployer.RollbackCallCount()).To(Equal(1))
+				_, deployment, contextID, snapshot, _ := fakeDeployer.RollbackArgsForCall(0)
+				Expect(deployment).To(Equal("service-instance_" + instanceID))
+				Expect(contextID).To(Equal("some-context-id"))
+				Expect(snapshot).To(Equal(broker.DeploymentSnapshot{
+					Manifest: []byte("name: previous-deployment"),
+					Configs:  map[string]string{"cloud": "previous-cloud-config"},
+				}))
+			})
+
+			It("reports the rollback in progress", func() {
+				Expect(opResult).To(Equal(brokerapi.LastOperation{
+					State:       brokerapi.InProgress,
+					Description: "Instance upgrade failed, rolling back to the previous deployment",
+				}))
+			})
+
+			It("keeps the snapshot until the rollback finishes", func() {
+				Expect(parameterStore.DeleteCallCount()).To(Equal(0))
+			})
+
+			Context("and no snapshot of the previous deployment is recorded in the operation journal", func() {
+				BeforeEach(func() {
+					fakeOperationJournal.OperationsReturns([]operationjournal.Operation{{ID: "199", InstanceID: instanceID, Type: "upgrade", State: "in progress"}}, nil)
+				})
+
+				It("reports the upgrade as failed", func() {
+					Expect(lastOpErr).NotTo(HaveOccurred())
+					Expect(fakeDeployer.RollbackCallCount()).To(Equal(0))
+					Expect(opResult).To(Equal(brokerapi.LastOperation{State: brokerapi.Failed, Description: "Failed for bosh task: 199"}))
+					Expect(logBuffer.String()).To(ContainSubstring("not rolling back failed upgrade of instance an-upgraded-instance: no snapshot of the deployment upgrade 199 replaced is recorded"))
+				})
+			})
+
+			Context("and the snapshot cannot be read", func() {
+				BeforeEach(func() {
+					parameterStore.GetReturns(nil, errors.New("credhub unavailable"))
+				})
+
+				It("reports the upgrade as failed", func() {
+					Expect(lastOpErr).NotTo(HaveOccurred())
+					Expect(fakeDeployer.RollbackCallCount()).To(Equal(0))
+					Expect(opResult).To(Equal(brokerapi.LastOperation{State: brokerapi.Failed, Description: "Failed for bosh task: 199"}))
+					Expect(logBuffer.String()).To(ContainSubstring("not rolling back failed upgrade of instance an-upgraded-instance: error reading the snapshot of the deployment: credhub unavailable"))
+				})
+			})
+
+			Context("and the rollback cannot be started", func() {
+				BeforeEach(func() {
+					fakeDeployer.RollbackReturns(0, errors.New("director unavailable"))
+				})
+
+				It("reports the upgrade as failed", func() {
+					Expect(lastOpErr).NotTo(HaveOccurred())
+					Expect(opResult).To(Equal(brokerapi.LastOperation{State: brokerapi.Failed, Description: "Failed for bosh task: 199"}))
+					Expect(logBuffer.String()).To(ContainSubstring("not rolling back failed upgrade of instance an-upgraded-instance: director unavailable"))
+				})
+			})
+		})
+
+		Context("when the rollback is in progress", func() {
+			BeforeEach(func() {
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskError}}
+				rollbackTasks = boshdirector.BoshTasks{{ID: rollbackTaskID, State: boshdirector.TaskProcessing}}
+			})
+
+			It("does not roll back again", func() {
+				Expect(fakeDeployer.RollbackCallCount()).To(Equal(0))
+				Expect(opResult.State).To(Equal(brokerapi.InProgress))
+				Expect(opResult.Description).To(Equal("Instance upgrade failed, rolling back to the previous deployment"))
+			})
+		})
+
+		Context("when the rollback succeeds", func() {
+			BeforeEach(func() {
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskError, Result: "it broke"}}
+				rollbackTasks = boshdirector.BoshTasks{{ID: rollbackTaskID, State: boshdirector.TaskDone}}
+			})
+
+			It("reports the upgrade as failed and rolled back", func() {
+				Expect(opResult).To(Equal(brokerapi.LastOperation{
+					State:       brokerapi.Failed,
+					Description: "Failed for bosh task: 199, rolled back to the previous deployment",
+				}))
+			})
+
+			It("records the rollback in the operation journal", func() {
+				Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+				operation := fakeOperationJournal.RecordArgsForCall(0)
+				Expect(operation.BoshTaskIDs).To(Equal([]int{upgradeTaskID, rollbackTaskID}))
+				Expect(operation.RolledBack).To(BeTrue())
+			})
+
+			It("deletes the stored snapshot", func() {
+				Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+				Expect(parameterStore.DeleteArgsForCall(0)).To(Equal("/c/some-snapshot-ref"))
+			})
+
+			Context("and the broker is configured to expose operational errors", func() {
+				BeforeEach(func() {
+					brokerConfig.ExposeOperationalErrors = true
+				})
+
+				It("includes the upgrade error", func() {
+					Expect(opResult.Description).To(Equal("Failed for bosh task: 199, rolled back to the previous deployment, error-message: it broke"))
+				})
+			})
+		})
+
+		Context("when the rollback fails", func() {
+			BeforeEach(func() {
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskError}}
+				rollbackTasks = boshdirector.BoshTasks{{ID: rollbackTaskID, State: boshdirector.TaskError}}
+			})
+
+			It("reports both failures", func() {
+				Expect(opResult).To(Equal(brokerapi.LastOperation{
+					State:       brokerapi.Failed,
+					Description: "Failed for bosh task: 199, rollback failed for bosh task: 200",
+				}))
+			})
+
+			It("does not record a rollback in the operation journal", func() {
+				Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+				Expect(fakeOperationJournal.RecordArgsForCall(0).RolledBack).To(BeFalse())
+			})
+
+			It("deletes the stored snapshot", func() {
+				Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the upgrade was started with rollbacks disabled", func() {
+			BeforeEach(func() {
+				operationData = fmt.Sprintf(`{"BoshTaskID": %d, "OperationType": "upgrade", "BoshContextID": "some-context-id"}`, upgradeTaskID)
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskError}}
+			})
+
+			It("does not roll back", func() {
+				Expect(fakeDeployer.RollbackCallCount()).To(Equal(0))
+				Expect(opResult.State).To(Equal(brokerapi.Failed))
+			})
+		})
+
+		Context("when the upgrade ran pre-upgrade errands", func() {
+			BeforeEach(func() {
+				operationData = fmt.Sprintf(`{"BoshTaskID": %d, "OperationType": "upgrade", "BoshContextID": "some-context-id", "RollbackOnFailure": true, "Errands": [{"Name": "pre-upgrade-errand"}], "PreOperationErrandCount": 1}`, upgradeTaskID)
+				upgradeTasks = boshdirector.BoshTasks{
+					{ID: upgradeTaskID + 1, State: boshdirector.TaskError},
+					{ID: upgradeTaskID, State: boshdirector.TaskDone, Description: "run errand pre-upgrade-errand from deployment service-instance_" + instanceID},
+				}
+			})
+
+			It("rolls back when the upgrade after the errands fails", func() {
+				Expect(fakeDeployer.RollbackCallCount()).To(Equal(1))
+				Expect(opResult.State).To(Equal(brokerapi.InProgress))
+			})
+		})
+
+		Context("when the upgrade task succeeds", func() {
+			BeforeEach(func() {
+				upgradeTasks = boshdirector.BoshTasks{{ID: upgradeTaskID, State: boshdirector.TaskDone}}
+			})
+
+			It("does not roll back", func() {
+				Expect(opResult.State).To(Equal(brokerapi.Succeeded))
+				Expect(fakeDeployer.RollbackCallCount()).To(Equal(0))
+			})
+
+			It("deletes the stored snapshot", func() {
+				Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+				Expect(parameterStore.DeleteArgsForCall(0)).To(Equal("/c/some-snapshot-ref"))
+			})
+		})
+
+		Context("when the tasks cannot be retrieved from BOSH", func() {
+			BeforeEach(func() {
+				boshClient.GetNormalisedTasksByContextStub = nil
+				boshClient.GetNormalisedTasksByContextReturns(nil, errors.New("director unavailable"))
+			})
+
+			It("returns an error", func() {
+				Expect(lastOpErr).To(HaveOccurred())
+				Expect(logBuffer.String()).To(ContainSubstring("error retrieving tasks from bosh, for deployment 'service-instance_an-upgraded-instance': director unavailable"))
+			})
+		})
+	})
+
+	Context("when a lifecycle errand has retries", func() {
+		const instanceID = "an-instance"
+
+		var (
+			opResult  brokerapi.LastOperation
+			lastOpErr error
+		)
+
+		JustBeforeEach(func() {
+			operationData, err := json.Marshal(broker.OperationData{
+				BoshTaskID:    1,
+				BoshContextID: "some-context-id",
+				OperationType: broker.OperationTypeCreate,
+				Errands:       []config.Errand{{Name: "smoke-tests", Retries: 2, Backoff: 60}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			b = createDefaultBroker()
+			opResult, lastOpErr = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+		})
+
+		Context("and an attempt is running", func() {
+			BeforeEach(func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{
+					{ID: 3, State: boshdirector.TaskProcessing},
+					{ID: 2, State: boshdirector.TaskError, LastActivityAt: time.Now().Add(-time.Hour)},
+					{ID: 1, State: boshdirector.TaskDone},
+				}, nil)
+			})
+
+			It("reports the attempt", func() {
+				Expect(lastOpErr).NotTo(HaveOccurred())
+				Expect(opResult).To(Equal(brokerapi.LastOperation{
+					State:       brokerapi.InProgress,
+					Description: "Instance provisioning in progress, errand smoke-tests attempt 2/3",
+				}))
+			})
+		})
+
+		Context("and a failed attempt is backing off", func() {
+			BeforeEach(func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{
+					{ID: 2, State: boshdirector.TaskError, LastActivityAt: time.Now()},
+					{ID: 1, State: boshdirector.TaskDone},
+				}, nil)
+			})
+
+			It("reports the operation in progress with the next attempt", func() {
+				Expect(lastOpErr).NotTo(HaveOccurred())
+				Expect(opResult).To(Equal(brokerapi.LastOperation{
+					State:       brokerapi.InProgress,
+					Description: "Instance provisioning in progress, errand smoke-tests attempt 2/3",
+				}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("and every attempt has failed", func() {
+			BeforeEach(func() {
+				failedAttempt := boshdirector.BoshTask{ID: 2, State: boshdirector.TaskError}
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{
+					failedAttempt, failedAttempt, failedAttempt,
+					{ID: 1, State: boshdirector.TaskDone},
+				}, nil)
+			})
+
+			It("reports the failure with the final attempt", func() {
+				Expect(lastOpErr).NotTo(HaveOccurred())
+				Expect(opResult.State).To(Equal(brokerapi.Failed))
+				Expect(opResult.Description).To(HavePrefix("Instance provisioning failed: "))
+				Expect(opResult.Description).To(HaveSuffix(", errand smoke-tests attempt 3/3"))
+			})
+		})
+	})
+
+	Context("when pre-upgrade errands have run", func() {
+		const instanceID = "an-instance"
+
+		var (
+			opResult  brokerapi.LastOperation
+			lastOpErr error
+		)
+
+		BeforeEach(func() {
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{
+				{ID: 1, State: boshdirector.TaskDone},
+			}, nil)
+			fakeDeployer.UpgradeReturns(2, nil, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 2, State: boshdirector.TaskQueued}, nil)
+
+			operationData, err := json.Marshal(broker.OperationData{
+				BoshTaskID:              1,
+				BoshContextID:           "some-context-id",
+				OperationType:           broker.OperationTypeUpgrade,
+				PlanID:                  existingPlanID,
+				Errands:                 []config.Errand{{Name: "backup"}},
+				PreOperationErrandCount: 1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			b = createDefaultBroker()
+			opResult, lastOpErr = b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: string(operationData)})
+		})
+
+		It("upgrades the deployment under the same context", func() {
+			Expect(lastOpErr).NotTo(HaveOccurred())
+			Expect(fakeDeployer.UpgradeCallCount()).To(Equal(1))
+			_, deployment, planID, previousPlanID, contextID, _ := fakeDeployer.UpgradeArgsForCall(0)
+			Expect(deployment).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(planID).To(Equal(existingPlanID))
+			Expect(*previousPlanID).To(Equal(existingPlanID))
+			Expect(contextID).To(Equal("some-context-id"))
+
+			Expect(opResult).To(Equal(brokerapi.LastOperation{
+				State:       brokerapi.InProgress,
+				Description: "Instance upgrade in progress",
+			}))
+		})
+	})
 })
@@ -9,6 +9,7 @@ package broker
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
@@ -17,6 +18,10 @@ import (
 type LifeCycleRunner struct {
 	boshClient BoshClient
 	plans      config.Plans
+
+	// StartOperation deploys the upgrade or recreate of an operation once its
+	// pre-operation errands have succeeded, returning the BOSH task ID.
+	StartOperation func(deploymentName string, operationData OperationData, logger *log.Logger) (int, error)
 }
 
 func NewLifeCycleRunner(
@@ -24,22 +29,44 @@ func NewLifeCycleRunner(
 	plans config.Plans,
 ) LifeCycleRunner {
 	return LifeCycleRunner{
-		boshClient,
-		plans,
+		boshClient: boshClient,
+		plans:      plans,
 	}
 }
 
+// ErrandAttempt identifies the run of a lifecycle errand that an operation's
+// latest BOSH task belongs to. Waiting is set while a failed errand backs off
+// before its next attempt.
+type ErrandAttempt struct {
+	Name     string
+	Attempt  int
+	Attempts int
+	Waiting  bool
+}
+
 func (l LifeCycleRunner) GetTask(deploymentName string, operationData OperationData, logger *log.Logger,
 ) (boshdirector.BoshTask, error) {
+	task, _, err := l.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+	return task, err
+}
+
+// GetTaskAndErrandAttempt returns the latest BOSH task of the operation,
+// running its next lifecycle errand, or retrying a failed one, when the
+// previous task allows. The errand attempt is zero when the latest task is
+// not an errand.
+func (l LifeCycleRunner) GetTaskAndErrandAttempt(deploymentName string, operationData OperationData, logger *log.Logger,
+) (boshdirector.BoshTask, ErrandAttempt, error) {
 	switch {
 	case operationData.BoshContextID == "":
-		return l.boshClient.GetTask(operationData.BoshTaskID, logger)
+		task, err := l.boshClient.GetTask(operationData.BoshTaskID, logger)
+		return task, ErrandAttempt{}, err
 	case validPostDeployOpType(operationData.OperationType):
 		return l.processPostDeployment(deploymentName, operationData, logger)
 	case validPreDeleteOpType(operationData.OperationType):
 		return l.processPreDelete(deploymentName, operationData, logger)
 	default:
-		return l.boshClient.GetTask(operationData.BoshTaskID, logger)
+		task, err := l.boshClient.GetTask(operationData.BoshTaskID, logger)
+		return task, ErrandAttempt{}, err
 	}
 }
 
@@ -58,74 +85,180 @@ func (l LifeCycleRunner) processPostDeployment(
 	deploymentName string,
 	operationData OperationData,
 	logger *log.Logger,
-) (boshdirector.BoshTask, error) {
+) (boshdirector.BoshTask, ErrandAttempt, error) {
 
 	boshTasks, err := l.boshClient.GetNormalisedTasksByContext(deploymentName, operationData.BoshContextID, logger)
 	if err != nil {
-		return boshdirector.BoshTask{}, err
+		return boshdirector.BoshTask{}, ErrandAttempt{}, err
 	}
 
 	if len(boshTasks) == 0 {
-		return boshdirector.BoshTask{}, fmt.Errorf("no tasks found for context id: %s", operationData.BoshContextID)
+		return boshdirector.BoshTask{}, ErrandAttempt{}, fmt.Errorf("no tasks found for context id: %s", operationData.BoshContextID)
 	}
 
 	task := boshTasks[0]
 
-	if task.StateType() != boshdirector.TaskComplete {
-		return task, nil
+	if isOldStylePostDeployOperationData(boshTasks, operationData) && task.StateType() == boshdirector.TaskComplete {
+		task, err := l.runErrand(deploymentName, operationData.PostDeployErrand.Name, operationData.PostDeployErrand.Instances, operationData.BoshContextID, logger)
+		return task, ErrandAttempt{}, err
 	}
 
-	if isOldStylePostDeployOperationData(boshTasks, operationData) {
-		return l.runErrand(deploymentName, operationData.PostDeployErrand.Name, operationData.PostDeployErrand.Instances, operationData.BoshContextID, logger)
-	}
+	postDeployErrands := operationData.Errands
+	if count := operationData.PreOperationErrandCount; count > 0 && count <= len(operationData.Errands) {
+		preOperationErrands := operationData.Errands[:count]
+		postDeployErrands = operationData.Errands[count:]
 
-	nextErrandIndex := len(boshTasks) - 1
-	if nextErrandIndex < len(operationData.Errands) {
-		errand := operationData.Errands[nextErrandIndex].Name
-		instances := operationData.Errands[nextErrandIndex].Instances
-		return l.runErrand(deploymentName, errand, instances, operationData.BoshContextID, logger)
+		consumed, _, _ := errandProgress(boshTasks, preOperationErrands)
+		if consumed == len(boshTasks) {
+			startOperation := func() (boshdirector.BoshTask, error) {
+				return l.startOperation(deploymentName, operationData, logger)
+			}
+			return l.nextErrandTask(deploymentName, boshTasks, preOperationErrands, operationData.BoshContextID, startOperation, logger)
+		}
+		boshTasks = boshTasks[:len(boshTasks)-consumed]
+		task = boshTasks[0]
 	}
 
-	if len(operationData.Errands) == 0 && operationData.PostDeployErrand.Name == "" {
-		logger.Println("can't determine lifecycle errands, neither PlanID nor PostDeployErrand.Name is present")
+	// The oldest remaining task deployed the instance, every later one ran a
+	// post-deploy errand.
+	if len(boshTasks) == 1 {
+		if task.StateType() != boshdirector.TaskComplete {
+			return task, ErrandAttempt{}, nil
+		}
+		if len(postDeployErrands) == 0 {
+			if len(operationData.Errands) == 0 && operationData.PostDeployErrand.Name == "" {
+				logger.Println("can't determine lifecycle errands, neither PlanID nor PostDeployErrand.Name is present")
+			}
+			return task, ErrandAttempt{}, nil
+		}
+		return l.runErrandAttempt(deploymentName, postDeployErrands, 0, 1, operationData.BoshContextID, logger)
 	}
-	return task, nil
+
+	return l.nextErrandTask(deploymentName, boshTasks[:len(boshTasks)-1], postDeployErrands, operationData.BoshContextID, nil, logger)
 }
 
 func (l LifeCycleRunner) processPreDelete(
 	deploymentName string,
 	operationData OperationData,
 	logger *log.Logger,
-) (boshdirector.BoshTask, error) {
+) (boshdirector.BoshTask, ErrandAttempt, error) {
 	boshTasks, err := l.boshClient.GetNormalisedTasksByContext(deploymentName, operationData.BoshContextID, logger)
 
 	if err != nil {
-		return boshdirector.BoshTask{}, err
+		return boshdirector.BoshTask{}, ErrandAttempt{}, err
 	}
 
 	if len(boshTasks) == 0 {
-		return boshdirector.BoshTask{}, fmt.Errorf("no tasks found for context id: %s", operationData.BoshContextID)
+		return boshdirector.BoshTask{}, ErrandAttempt{}, fmt.Errorf("no tasks found for context id: %s", operationData.BoshContextID)
 	}
 
 	task := boshTasks[0]
-	if task.StateType() != boshdirector.TaskComplete {
-		return task, nil
+	if isOldStylePreDeleteOperationData(boshTasks, operationData) {
+		if task.StateType() != boshdirector.TaskComplete {
+			return task, ErrandAttempt{}, nil
+		}
+		task, err := l.deleteDeployment(deploymentName, operationData.BoshContextID, logger)
+		return task, ErrandAttempt{}, err
 	}
 
-	if len(boshTasks) == len(operationData.Errands) || isOldStylePreDeleteOperationData(boshTasks, operationData) {
-		taskID, err := l.boshClient.DeleteDeployment(deploymentName, operationData.BoshContextID, logger, boshdirector.NewAsyncTaskReporter())
-		if err != nil {
-			return boshdirector.BoshTask{}, err
+	deleteDeployment := func() (boshdirector.BoshTask, error) {
+		return l.deleteDeployment(deploymentName, operationData.BoshContextID, logger)
+	}
+	return l.nextErrandTask(deploymentName, boshTasks, operationData.Errands, operationData.BoshContextID, deleteDeployment, logger)
+}
+
+// errandProgress walks the tasks, oldest first, that ran the errands in order,
+// moving on to the next errand each time one succeeds. It returns how many of
+// the tasks ran errands, and which errand and attempt at it the last of those
+// was.
+func errandProgress(boshTasks boshdirector.BoshTasks, errands []config.Errand) (consumed, errandIndex, attempt int) {
+	next, nextAttempt := 0, 1
+	for i := len(boshTasks) - 1; i >= 0 && next < len(errands); i-- {
+		consumed++
+		errandIndex, attempt = next, nextAttempt
+		if boshTasks[i].StateType() == boshdirector.TaskComplete {
+			next, nextAttempt = next+1, 1
+		} else {
+			nextAttempt++
 		}
-		return l.boshClient.GetTask(taskID, logger)
 	}
+	return consumed, errandIndex, attempt
+}
 
-	if len(boshTasks) > len(operationData.Errands) {
-		return task, nil
+// nextErrandTask moves a chain of errands on from its latest task: retrying a
+// failed errand that has attempts left, running the next errand once one
+// succeeds, and calling afterLastErrand, if set, once the last one succeeds.
+func (l LifeCycleRunner) nextErrandTask(
+	deploymentName string,
+	errandTasks boshdirector.BoshTasks,
+	errands []config.Errand,
+	contextID string,
+	afterLastErrand func() (boshdirector.BoshTask, error),
+	logger *log.Logger,
+) (boshdirector.BoshTask, ErrandAttempt, error) {
+	task := errandTasks[0]
+
+	consumed, errandIndex, attempt := errandProgress(errandTasks, errands)
+	if consumed < len(errandTasks) {
+		return task, ErrandAttempt{}, nil
 	}
+	errand := errands[errandIndex]
+	errandAttempt := ErrandAttempt{Name: errand.Name, Attempt: attempt, Attempts: errand.Attempts()}
 
-	errand := operationData.Errands[len(boshTasks)]
-	return l.runErrand(deploymentName, errand.Name, errand.Instances, operationData.BoshContextID, logger)
+	switch task.StateType() {
+	case boshdirector.TaskIncomplete:
+		return task, errandAttempt, nil
+	case boshdirector.TaskComplete:
+		switch {
+		case errandIndex+1 < len(errands):
+			return l.runErrandAttempt(deploymentName, errands, errandIndex+1, 1, contextID, logger)
+		case afterLastErrand != nil:
+			task, err := afterLastErrand()
+			return task, ErrandAttempt{}, err
+		default:
+			return task, errandAttempt, nil
+		}
+	default:
+		if attempt >= errand.Attempts() {
+			return task, errandAttempt, nil
+		}
+		if time.Since(task.LastActivityAt) < errand.RetryBackoff(attempt) {
+			errandAttempt.Attempt++
+			errandAttempt.Waiting = true
+			return task, errandAttempt, nil
+		}
+		logger.Printf("errand %s failed in BOSH task ID %d, retrying attempt %d of %d\n", errand.Name, task.ID, attempt+1, errand.Attempts())
+		return l.runErrandAttempt(deploymentName, errands, errandIndex, attempt+1, contextID, logger)
+	}
+}
+
+func (l LifeCycleRunner) startOperation(deploymentName string, operationData OperationData, logger *log.Logger) (boshdirector.BoshTask, error) {
+	if l.StartOperation == nil {
+		return boshdirector.BoshTask{}, fmt.Errorf("cannot start %s of deployment %s after its pre-operation errands", operationData.OperationType, deploymentName)
+	}
+	taskID, err := l.StartOperation(deploymentName, operationData, logger)
+	if err != nil {
+		return boshdirector.BoshTask{}, err
+	}
+	return l.boshClient.GetTask(taskID, logger)
+}
+
+func (l LifeCycleRunner) runErrandAttempt(deploymentName string, errands []config.Errand, errandIndex, attempt int, contextID string, logger *log.Logger,
+) (boshdirector.BoshTask, ErrandAttempt, error) {
+	errand := errands[errandIndex]
+	task, err := l.runErrand(deploymentName, errand.Name, errand.Instances, contextID, logger)
+	if err != nil {
+		return boshdirector.BoshTask{}, ErrandAttempt{}, err
+	}
+	return task, ErrandAttempt{Name: errand.Name, Attempt: attempt, Attempts: errand.Attempts()}, nil
+}
+
+func (l LifeCycleRunner) deleteDeployment(deploymentName, contextID string, logger *log.Logger) (boshdirector.BoshTask, error) {
+	taskID, err := l.boshClient.DeleteDeployment(deploymentName, contextID, logger, boshdirector.NewAsyncTaskReporter())
+	if err != nil {
+		return boshdirector.BoshTask{}, err
+	}
+	return l.boshClient.GetTask(taskID, logger)
 }
 
 func isOldStylePreDeleteOperationData(boshTasks boshdirector.BoshTasks, operationData OperationData) bool {
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -273,6 +274,215 @@ var _ = Describe("Lifecycle runner", func() {
 			})
 		})
 
+		Context("when an errand has retries", func() {
+			var (
+				failedAttempt boshdirector.BoshTask
+				retriedErrand boshdirector.BoshTask
+			)
+
+			BeforeEach(func() {
+				operationData = broker.OperationData{
+					BoshContextID: contextID,
+					OperationType: broker.OperationTypeCreate,
+					Errands:       []config.Errand{{Name: "smoke-tests", Retries: 2, Backoff: 30}, {Name: "register"}},
+				}
+				failedAttempt = boshdirector.BoshTask{ID: 4, State: boshdirector.TaskError, ContextID: contextID, LastActivityAt: time.Now().Add(-time.Minute)}
+				retriedErrand = boshdirector.BoshTask{ID: 5, State: boshdirector.TaskProcessing, ContextID: contextID}
+				boshClient.RunErrandReturns(retriedErrand.ID, nil)
+				boshClient.GetTaskReturns(retriedErrand, nil)
+			})
+
+			It("reports the attempt of a running errand", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{retriedErrand, failedAttempt, taskComplete}, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(retriedErrand))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "smoke-tests", Attempt: 2, Attempts: 3}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+			})
+
+			It("runs the errand again once the backoff has passed", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{failedAttempt, taskComplete}, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(retriedErrand))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "smoke-tests", Attempt: 2, Attempts: 3}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(1))
+				_, errandName, _, ctxID, _, _ := boshClient.RunErrandArgsForCall(0)
+				Expect(errandName).To(Equal("smoke-tests"))
+				Expect(ctxID).To(Equal(contextID))
+				Expect(logBuffer.String()).To(ContainSubstring("errand smoke-tests failed in BOSH task ID 4, retrying attempt 2 of 3"))
+			})
+
+			It("waits for the backoff to pass before running the errand again", func() {
+				failedAttempt.LastActivityAt = time.Now()
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{failedAttempt, taskComplete}, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(failedAttempt))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "smoke-tests", Attempt: 2, Attempts: 3, Waiting: true}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+			})
+
+			It("doubles the backoff before each further attempt", func() {
+				secondFailedAttempt := failedAttempt
+				secondFailedAttempt.ID = 5
+				secondFailedAttempt.LastActivityAt = time.Now().Add(-45 * time.Second)
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{secondFailedAttempt, failedAttempt, taskComplete}, nil)
+
+				_, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "smoke-tests", Attempt: 3, Attempts: 3, Waiting: true}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+			})
+
+			It("returns the failed task once all attempts have failed", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{failedAttempt, failedAttempt, failedAttempt, taskComplete}, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(failedAttempt))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "smoke-tests", Attempt: 3, Attempts: 3}))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+			})
+
+			It("runs the next errand once a retried errand succeeds", func() {
+				succeededAttempt := boshdirector.BoshTask{ID: 5, State: boshdirector.TaskDone, ContextID: contextID}
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{succeededAttempt, failedAttempt, taskComplete}, nil)
+
+				_, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "register", Attempt: 1, Attempts: 1}))
+				_, errandName, _, _, _, _ := boshClient.RunErrandArgsForCall(0)
+				Expect(errandName).To(Equal("register"))
+			})
+		})
+	})
+
+	Describe("pre-operation errands", func() {
+		var (
+			preErrandDone       boshdirector.BoshTask
+			startedOperation    boshdirector.BoshTask
+			startOperationCalls int
+			startOperationErr   error
+		)
+
+		BeforeEach(func() {
+			operationData = broker.OperationData{
+				BoshContextID:           contextID,
+				OperationType:           broker.OperationTypeUpgrade,
+				PlanID:                  planID,
+				Errands:                 []config.Errand{{Name: "backup"}, {Name: "drain", Retries: 1}, {Name: "health-check"}},
+				PreOperationErrandCount: 2,
+			}
+			preErrandDone = boshdirector.BoshTask{ID: 10, State: boshdirector.TaskDone, ContextID: contextID}
+			startedOperation = boshdirector.BoshTask{ID: 12, State: boshdirector.TaskProcessing, ContextID: contextID}
+			startOperationCalls = 0
+			startOperationErr = nil
+			deployRunner.StartOperation = func(name string, data broker.OperationData, l *log.Logger) (int, error) {
+				startOperationCalls++
+				Expect(name).To(Equal(deploymentName))
+				Expect(data).To(Equal(operationData))
+				return startedOperation.ID, startOperationErr
+			}
+			boshClient.GetTaskReturns(startedOperation, nil)
+		})
+
+		It("runs the next pre-operation errand", func() {
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{preErrandDone}, nil)
+			boshClient.RunErrandReturns(11, nil)
+
+			_, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "drain", Attempt: 1, Attempts: 2}))
+			_, errandName, _, ctxID, _, _ := boshClient.RunErrandArgsForCall(0)
+			Expect(errandName).To(Equal("drain"))
+			Expect(ctxID).To(Equal(contextID))
+			Expect(startOperationCalls).To(BeZero())
+		})
+
+		It("starts the operation once every pre-operation errand has succeeded", func() {
+			secondErrandDone := boshdirector.BoshTask{ID: 11, State: boshdirector.TaskDone, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{secondErrandDone, preErrandDone}, nil)
+
+			task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(startOperationCalls).To(Equal(1))
+			Expect(task).To(Equal(startedOperation))
+			Expect(attempt).To(Equal(broker.ErrandAttempt{}))
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("retries a failed pre-operation errand that has attempts left", func() {
+			failedErrand := boshdirector.BoshTask{ID: 11, State: boshdirector.TaskError, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{failedErrand, preErrandDone}, nil)
+			boshClient.RunErrandReturns(13, nil)
+
+			_, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "drain", Attempt: 2, Attempts: 2}))
+			Expect(startOperationCalls).To(BeZero())
+		})
+
+		It("abandons the operation when a pre-operation errand fails", func() {
+			failedErrand := boshdirector.BoshTask{ID: 10, State: boshdirector.TaskError, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{failedErrand}, nil)
+
+			task, _, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task).To(Equal(failedErrand))
+			Expect(startOperationCalls).To(BeZero())
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("returns an error when the operation cannot be started", func() {
+			startOperationErr = errors.New("adapter failed")
+			secondErrandDone := boshdirector.BoshTask{ID: 11, State: boshdirector.TaskDone, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{secondErrandDone, preErrandDone}, nil)
+
+			_, _, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).To(MatchError("adapter failed"))
+		})
+
+		It("returns the operation's task while it runs", func() {
+			secondErrandDone := boshdirector.BoshTask{ID: 11, State: boshdirector.TaskDone, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{startedOperation, secondErrandDone, preErrandDone}, nil)
+
+			task, _, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task).To(Equal(startedOperation))
+			Expect(startOperationCalls).To(BeZero())
+		})
+
+		It("runs the post-deploy errands once the operation completes", func() {
+			secondErrandDone := boshdirector.BoshTask{ID: 11, State: boshdirector.TaskDone, ContextID: contextID}
+			operationDone := boshdirector.BoshTask{ID: 12, State: boshdirector.TaskDone, ContextID: contextID}
+			boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{operationDone, secondErrandDone, preErrandDone}, nil)
+			boshClient.RunErrandReturns(14, nil)
+
+			_, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "health-check", Attempt: 1, Attempts: 1}))
+			_, errandName, _, _, _, _ := boshClient.RunErrandArgsForCall(0)
+			Expect(errandName).To(Equal("health-check"))
+			Expect(startOperationCalls).To(BeZero())
+		})
 	})
 
 	Describe("pre-delete errand", func() {
@@ -426,6 +636,53 @@ var _ = Describe("Lifecycle runner", func() {
 			})
 		})
 
+		Context("when an errand has retries", func() {
+			BeforeEach(func() {
+				operationData = broker.OperationData{
+					BoshContextID: contextID,
+					OperationType: broker.OperationTypeDelete,
+					Errands:       []config.Errand{{Name: "cleanup", Retries: 1}},
+				}
+			})
+
+			It("runs the errand again when it fails", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{taskErrored}, nil)
+				boshClient.RunErrandReturns(taskProcessing.ID, nil)
+				boshClient.GetTaskReturns(taskProcessing, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(taskProcessing))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{Name: "cleanup", Attempt: 2, Attempts: 2}))
+				Expect(boshClient.DeleteDeploymentCallCount()).To(Equal(0))
+			})
+
+			It("deletes the deployment once a retried errand succeeds", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{taskComplete, taskErrored}, nil)
+				boshClient.DeleteDeploymentReturns(taskProcessing.ID, nil)
+				boshClient.GetTaskReturns(taskProcessing, nil)
+
+				task, attempt, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(taskProcessing))
+				Expect(attempt).To(Equal(broker.ErrandAttempt{}))
+				Expect(boshClient.DeleteDeploymentCallCount()).To(Equal(1))
+			})
+
+			It("does not delete the deployment once all attempts have failed", func() {
+				boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{taskErrored, taskErrored}, nil)
+
+				task, _, err := deployRunner.GetTaskAndErrandAttempt(deploymentName, operationData, logger)
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(task).To(Equal(taskErrored))
+				Expect(boshClient.RunErrandCallCount()).To(Equal(0))
+				Expect(boshClient.DeleteDeploymentCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the broker receives old-style operation data (without Errands field)", func() {
 			It("runs the pre-delete errand and deletes the deployment", func() {
 				operationData = broker.OperationData{
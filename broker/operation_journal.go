@@ -0,0 +1,255 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+)
+
+// originatingIdentityKey is the context key under which the brokerapi
+// originating identity middleware stores the X-Broker-API-Originating-Identity
+// header.
+const originatingIdentityKey = "originatingIdentity"
+
+func (b *Broker) Operations(instanceID string, logger *log.Logger) ([]operationjournal.Operation, error) {
+	if b.operationJournal == nil {
+		return nil, NewOperationJournalDisabledError(errors.New("the operation journal is not enabled for this broker"))
+	}
+	return b.operationJournal.Operations(instanceID)
+}
+
+func (b *Broker) recordOperation(ctx context.Context, operation operationjournal.Operation, logger *log.Logger) {
+	if b.operationJournal == nil {
+		return
+	}
+
+	operation.ServiceID = b.serviceOffering.ID
+	operation.RequestID = brokercontext.GetReqID(ctx)
+	operation.Requester = requester(ctx)
+
+	if err := b.operationJournal.Record(operation); err != nil {
+		loggerfactory.Errorf(logger, "error recording %s operation %s for instance %s in the operation journal: %s\n", operation.Type, operation.ID, operation.InstanceID, err)
+	}
+}
+
+func (b *Broker) recordStartedOperation(ctx context.Context, instanceID, planID string, operationData OperationData, logger *log.Logger) {
+	b.recordStarted(ctx, startedOperation(instanceID, planID, operationData), logger)
+}
+
+// recordStartedDeployment records a create or update along with a reference
+// to the arbitrary parameters the instance is deployed with once it succeeds,
+// which GetInstance reports, when the broker has a parameter store.
+func (b *Broker) recordStartedDeployment(ctx context.Context, instanceID, planID string, parameters map[string]interface{}, operationData OperationData, logger *log.Logger) {
+	if b.operationJournal == nil {
+		return
+	}
+
+	operation := startedOperation(instanceID, planID, operationData)
+	if b.parameterStore != nil {
+		merged := b.mergedParameters(instanceID, operationData.OperationType, parameters, logger)
+		operation.ParametersRef = b.storeParameters(instanceID, operation.ID, merged, logger)
+	}
+	b.recordStarted(ctx, operation, logger)
+}
+
+// recordStartedUpgrade records an upgrade along with a reference to the
+// stored snapshot of the deployment it replaces, which it is rolled back to if
+// it fails.
+func (b *Broker) recordStartedUpgrade(ctx context.Context, instanceID, planID, snapshotRef string, operationData OperationData, logger *log.Logger) {
+	operation := startedOperation(instanceID, planID, operationData)
+	operation.RollbackSnapshotRef = snapshotRef
+	b.recordStarted(ctx, operation, logger)
+}
+
+// recordStarted records an operation that has just been started and, when
+// the broker watches operations, polls it until it finishes.
+func (b *Broker) recordStarted(ctx context.Context, operation operationjournal.Operation, logger *log.Logger) {
+	b.recordOperation(ctx, operation, logger)
+	b.watchOperation(operation.InstanceID, operation.OperationData)
+}
+
+// journalledOperation returns the operation of an instance with the given ID
+// recorded in the operation journal.
+func (b *Broker) journalledOperation(instanceID, operationID string, logger *log.Logger) (operationjournal.Operation, bool) {
+	if b.operationJournal == nil {
+		return operationjournal.Operation{}, false
+	}
+
+	operations, err := b.operationJournal.Operations(instanceID)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error reading the operations of instance %s from the operation journal: %s\n", instanceID, err)
+		return operationjournal.Operation{}, false
+	}
+
+	for _, operation := range operations {
+		if operation.ID == operationID {
+			return operation, true
+		}
+	}
+	return operationjournal.Operation{}, false
+}
+
+func startedOperation(instanceID, planID string, operationData OperationData) operationjournal.Operation {
+	operationDataJSON, _ := json.Marshal(operationData)
+	return operationjournal.Operation{
+		ID:            JournalOperationID(operationData),
+		InstanceID:    instanceID,
+		Type:          string(operationData.OperationType),
+		PlanID:        planID,
+		BoshTaskIDs:   []int{operationData.BoshTaskID},
+		Errands:       errandNames(operationData.Errands),
+		State:         string(brokerapi.InProgress),
+		OperationData: string(operationDataJSON),
+	}
+}
+
+// WatchOperations makes the broker poll each operation it starts every
+// interval until the operation finishes, so that its outcome is recorded in
+// the operation journal, and a failed upgrade is rolled back, even when
+// nothing else polls it. The operations of this service offering that the
+// journal records as in progress, for example because the broker restarted
+// while they ran, are watched as well. Watching stops when ctx is cancelled.
+func (b *Broker) WatchOperations(ctx context.Context, interval time.Duration, logger *log.Logger) error {
+	if b.operationJournal == nil {
+		return nil
+	}
+
+	operations, err := b.operationJournal.AllOperations()
+	if err != nil {
+		return fmt.Errorf("error reading the operation journal: %s", err)
+	}
+
+	b.operationWatcher.start()
+	for _, operation := range operations {
+		if operation.ServiceID == b.serviceOffering.ID && operation.State == string(brokerapi.InProgress) {
+			b.watchOperation(operation.InstanceID, operation.OperationData)
+		}
+	}
+	go b.pollWatchedOperations(ctx, interval, logger)
+	return nil
+}
+
+func (b *Broker) watchOperation(instanceID, operationData string) {
+	if operationData == "" {
+		return
+	}
+	b.operationWatcher.watch(instanceID, operationData)
+}
+
+// recordFinishedOperation records the outcome of an instance operation once
+// LastOperation observes that it is no longer in progress.
+func (b *Broker) recordFinishedOperation(ctx context.Context, instanceID string, operationData OperationData, lastBoshTask boshdirector.BoshTask, lastOperation brokerapi.LastOperation, logger *log.Logger) {
+	if lastOperation.State == brokerapi.InProgress {
+		return
+	}
+
+	operation := b.finishedOperation(instanceID, operationData, lastBoshTask, lastOperation, logger)
+	b.recordOperation(ctx, operation, logger)
+	if b.operationJournal != nil {
+		b.deleteSupersededParameters(instanceID, operation, logger)
+		b.deleteRollbackSnapshot(instanceID, operationData, logger)
+	}
+}
+
+func (b *Broker) finishedOperation(instanceID string, operationData OperationData, lastBoshTask boshdirector.BoshTask, lastOperation brokerapi.LastOperation, logger *log.Logger) operationjournal.Operation {
+	taskIDs := []int{operationData.BoshTaskID}
+	if lastBoshTask.ID != 0 {
+		taskIDs = append(taskIDs, lastBoshTask.ID)
+	}
+
+	operation := operationjournal.Operation{
+		ID:          JournalOperationID(operationData),
+		InstanceID:  instanceID,
+		Type:        string(operationData.OperationType),
+		PlanID:      operationData.PlanID,
+		BoshTaskIDs: taskIDs,
+		Errands:     errandNames(operationData.Errands),
+		State:       string(lastOperation.State),
+		Description: lastOperation.Description,
+	}
+	if b.operationJournal != nil && operationData.OperationType == OperationTypeBackup && lastOperation.State == brokerapi.Succeeded {
+		operation.Artefact = b.backupArtefact(lastBoshTask.ID, logger)
+	}
+	return operation
+}
+
+func (b *Broker) recordBindingOperation(ctx context.Context, instanceID, bindingID string, operationType OperationType, lastOperation brokerapi.LastOperation, logger *log.Logger) {
+	b.recordOperation(ctx, bindingOperationEntry(instanceID, bindingID, operationType, lastOperation), logger)
+}
+
+// recordFinishedBindingOperation records the outcome of an asynchronous bind
+// or unbind as soon as the service adapter has finished, so that it can be
+// reported however late the platform polls for it.
+func (b *Broker) recordFinishedBindingOperation(ctx context.Context, instanceID, bindingID string, operationType OperationType, err error, logger *log.Logger) {
+	state := brokerapi.Succeeded
+	if err != nil {
+		state = brokerapi.Failed
+	}
+	b.recordBindingOperation(ctx, instanceID, bindingID, operationType, b.bindingOperationOutcome(ctx, operationType, state, err), logger)
+}
+
+func bindingOperationEntry(instanceID, bindingID string, operationType OperationType, lastOperation brokerapi.LastOperation) operationjournal.Operation {
+	return operationjournal.Operation{
+		ID:          bindingOperationID(operationType, bindingID),
+		InstanceID:  instanceID,
+		BindingID:   bindingID,
+		Type:        string(operationType),
+		State:       string(lastOperation.State),
+		Description: lastOperation.Description,
+	}
+}
+
+// bindingOperationID identifies a bind or unbind by its binding, as the
+// operation data of binding operations does not name a BOSH task.
+func bindingOperationID(operationType OperationType, bindingID string) string {
+	return fmt.Sprintf("%s-%s", operationType, bindingID)
+}
+
+// JournalOperationID identifies an instance operation by the BOSH task that
+// started it, which CF echoes back in the operation data of every poll.
+func JournalOperationID(operationData OperationData) string {
+	return strconv.Itoa(operationData.BoshTaskID)
+}
+
+func errandNames(errands []config.Errand) []string {
+	var names []string
+	for _, errand := range errands {
+		names = append(names, errand.Name)
+	}
+	return names
+}
+
+// requester decodes the originating identity sent by the platform, which has
+// the form "<platform> <base64 encoded JSON>".
+func requester(ctx context.Context) string {
+	identity, _ := ctx.Value(originatingIdentityKey).(string)
+	parts := strings.SplitN(identity, " ", 2)
+	if len(parts) != 2 {
+		return identity
+	}
+
+	value, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return identity
+	}
+	return parts[0] + " " + string(value)
+}
@@ -0,0 +1,354 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Operation journal", func() {
+	const instanceID = "some-instance-id"
+
+	BeforeEach(func() {
+		b = createDefaultBroker()
+	})
+
+	It("records an operation when it is started", func() {
+		fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+
+		identity := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"some-user"}`))
+		ctx := context.WithValue(context.Background(), "originatingIdentity", "cloudfoundry "+identity)
+
+		_, err := b.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+			PlanID:        existingPlanID,
+			ServiceID:     serviceOfferingID,
+			RawParameters: []byte(`{"size":"small"}`),
+		}, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		operation := fakeOperationJournal.RecordArgsForCall(0)
+		Expect(operation.ID).To(Equal("42"))
+		Expect(operation.InstanceID).To(Equal(instanceID))
+		Expect(operation.Type).To(Equal(string(broker.OperationTypeCreate)))
+		Expect(operation.PlanID).To(Equal(existingPlanID))
+		Expect(operation.BoshTaskIDs).To(Equal([]int{42}))
+		Expect(operation.State).To(Equal(string(brokerapi.InProgress)))
+		Expect(operation.Requester).To(Equal(`cloudfoundry {"user_id":"some-user"}`))
+		Expect(operation.RequestID).NotTo(BeEmpty())
+	})
+
+	Describe("storing the parameters of deployments", func() {
+		var parameterStore *fakes.FakeParameterStore
+
+		BeforeEach(func() {
+			parameterStore = new(fakes.FakeParameterStore)
+			b.UseParameterStore(parameterStore)
+		})
+
+		It("stores the parameters of an update merged over the ones the instance is deployed with", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", PlanID: existingPlanID, State: "succeeded", ParametersRef: "/c/1/parameters"},
+				{ID: "2", Type: "update", PlanID: existingPlanID, State: "failed", ParametersRef: "/c/2/parameters"},
+			}, nil)
+			parameterStore.GetReturns(map[string]interface{}{"size": "small", "tls": true}, nil)
+			boshClient.GetDeploymentReturns([]byte("name: service-instance_some-instance-id"), true, nil)
+			fakeDeployer.UpdateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+
+			_, err := b.Update(context.Background(), instanceID, brokerapi.UpdateDetails{
+				PlanID:         existingPlanID,
+				ServiceID:      serviceOfferingID,
+				PreviousValues: brokerapi.PreviousValues{PlanID: existingPlanID},
+				RawParameters:  []byte(`{"size":"large"}`),
+			}, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(parameterStore.GetArgsForCall(0)).To(Equal("/c/1/parameters"))
+			Expect(parameterStore.SetCallCount()).To(Equal(1))
+			key, value := parameterStore.SetArgsForCall(0)
+			Expect(key).To(Equal("/c/" + serviceOfferingID + "/" + instanceID + "/42/parameters"))
+			Expect(value).To(Equal(map[string]interface{}{"size": "large", "tls": true}))
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			Expect(fakeOperationJournal.RecordArgsForCall(0).ParametersRef).To(Equal(key))
+		})
+
+		It("does not record a reference when the parameters cannot be stored", func() {
+			parameterStore.SetReturns(errors.New("credhub is down"))
+			fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+
+			_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+				PlanID:        existingPlanID,
+				ServiceID:     serviceOfferingID,
+				RawParameters: []byte(`{"size":"small"}`),
+			}, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeOperationJournal.RecordArgsForCall(0).ParametersRef).To(BeEmpty())
+			Expect(logBuffer.String()).To(ContainSubstring("error storing the parameters of operation 42 of instance some-instance-id, they will not be reported: credhub is down"))
+		})
+
+		It("deletes the parameters the instance is no longer deployed with once an update succeeds", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", State: "succeeded", ParametersRef: "/c/1/parameters"},
+				{ID: "42", Type: "update", State: "in progress", ParametersRef: "/c/42/parameters"},
+			}, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskDone}, nil)
+
+			_, err := b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: `{"BoshTaskID": 42, "OperationType": "update"}`})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+			Expect(parameterStore.DeleteArgsForCall(0)).To(Equal("/c/1/parameters"))
+		})
+
+		It("deletes the parameters of an update once it fails", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", State: "succeeded", ParametersRef: "/c/1/parameters"},
+				{ID: "42", Type: "update", State: "in progress", ParametersRef: "/c/42/parameters"},
+			}, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskError}, nil)
+
+			_, err := b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: `{"BoshTaskID": 42, "OperationType": "update"}`})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+			Expect(parameterStore.DeleteArgsForCall(0)).To(Equal("/c/42/parameters"))
+		})
+
+		It("deletes the parameters of an instance once it has been deleted", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", Type: "create", State: "succeeded", ParametersRef: "/c/1/parameters"},
+				{ID: "2", Type: "update", State: "failed", ParametersRef: "/c/2/parameters"},
+				{ID: "3", Type: "update", State: "succeeded", ParametersRef: "/c/3/parameters"},
+				{ID: "42", Type: "delete", State: "in progress"},
+			}, nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskDone}, nil)
+
+			_, err := b.LastOperation(context.Background(), instanceID, brokerapi.PollDetails{OperationData: `{"BoshTaskID": 42, "OperationType": "delete"}`})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+			Expect(parameterStore.DeleteArgsForCall(0)).To(Equal("/c/3/parameters"))
+		})
+	})
+
+	It("does not record parameters without a parameter store", func() {
+		fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+
+		_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+			PlanID:        existingPlanID,
+			ServiceID:     serviceOfferingID,
+			RawParameters: []byte(`{"size":"small"}`),
+		}, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeOperationJournal.RecordArgsForCall(0).ParametersRef).To(BeEmpty())
+	})
+
+	It("records the outcome of an operation once it has finished", func() {
+		pollDetails := brokerapi.PollDetails{OperationData: `{"BoshTaskID": 42, "OperationType": "update", "Errands": [{"Name": "health-check"}]}`}
+
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskProcessing}, nil)
+		_, err := b.LastOperation(context.Background(), instanceID, pollDetails)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeOperationJournal.RecordCallCount()).To(BeZero())
+
+		boshClient.GetNormalisedTasksByContextReturns(boshdirector.BoshTasks{{ID: 43, State: boshdirector.TaskDone}}, nil)
+		boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 43, State: boshdirector.TaskDone}, nil)
+		_, err = b.LastOperation(context.Background(), instanceID, pollDetails)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		operation := fakeOperationJournal.RecordArgsForCall(0)
+		Expect(operation.ID).To(Equal("42"))
+		Expect(operation.Type).To(Equal(string(broker.OperationTypeUpdate)))
+		Expect(operation.BoshTaskIDs).To(ContainElement(42))
+		Expect(operation.Errands).To(Equal([]string{"health-check"}))
+		Expect(operation.State).To(Equal(string(brokerapi.Succeeded)))
+		Expect(operation.Description).To(Equal("Instance update completed"))
+	})
+
+	It("records synchronous bindings", func() {
+		boshClient.GetDeploymentReturns([]byte("name: service-instance_some-instance-id"), true, nil)
+		boshClient.VMsReturns(bosh.BoshVMs{}, nil)
+		serviceAdapter.CreateBindingReturns(sdk.Binding{}, nil)
+
+		ctx := brokercontext.New(context.Background(), "", "some-request-id", "", instanceID)
+		_, err := b.Bind(ctx, instanceID, "some-binding-id", brokerapi.BindDetails{PlanID: existingPlanID, ServiceID: serviceOfferingID}, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		Expect(fakeOperationJournal.RecordArgsForCall(0)).To(Equal(operationjournal.Operation{
+			ID:          "bind-some-binding-id",
+			InstanceID:  instanceID,
+			BindingID:   "some-binding-id",
+			ServiceID:   serviceOfferingID,
+			Type:        string(broker.OperationTypeBind),
+			RequestID:   "some-request-id",
+			State:       string(brokerapi.Succeeded),
+			Description: "Binding completed",
+		}))
+	})
+
+	It("does not fail the operation when it cannot be recorded", func() {
+		fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+		fakeOperationJournal.RecordReturns(errors.New("disk full"))
+
+		_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+			PlanID:    existingPlanID,
+			ServiceID: serviceOfferingID,
+		}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logBuffer.String()).To(ContainSubstring("error recording create operation 42 for instance some-instance-id in the operation journal: disk full"))
+	})
+
+	Describe("watching operations", func() {
+		var (
+			watchCtx     context.Context
+			stopWatching context.CancelFunc
+		)
+
+		BeforeEach(func() {
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskDone}, nil)
+			watchCtx, stopWatching = context.WithCancel(context.Background())
+		})
+
+		AfterEach(func() {
+			stopWatching()
+		})
+
+		It("records the outcome of an operation it started without being polled", func() {
+			fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+			Expect(b.WatchOperations(watchCtx, time.Millisecond, loggerFactory.NewWithRequestID())).To(Succeed())
+
+			_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+				PlanID:    existingPlanID,
+				ServiceID: serviceOfferingID,
+			}, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fakeOperationJournal.RecordCallCount).Should(Equal(2))
+			started := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(started.ServiceID).To(Equal(serviceOfferingID))
+			Expect(started.OperationData).To(ContainSubstring(`"BoshTaskID":42`))
+			Expect(fakeOperationJournal.RecordArgsForCall(1).State).To(Equal(string(brokerapi.Succeeded)))
+		})
+
+		It("resumes watching the operations of the offering that are in progress", func() {
+			fakeOperationJournal.AllOperationsReturns([]operationjournal.Operation{
+				{ID: "42", InstanceID: instanceID, ServiceID: serviceOfferingID, State: "in progress", OperationData: `{"BoshTaskID": 42, "OperationType": "update"}`},
+				{ID: "43", InstanceID: instanceID, ServiceID: serviceOfferingID, State: "succeeded", OperationData: `{"BoshTaskID": 43, "OperationType": "update"}`},
+				{ID: "44", InstanceID: "other-instance", ServiceID: "other-offering", State: "in progress", OperationData: `{"BoshTaskID": 44, "OperationType": "update"}`},
+			}, nil)
+
+			Expect(b.WatchOperations(watchCtx, time.Millisecond, loggerFactory.NewWithRequestID())).To(Succeed())
+
+			Eventually(fakeOperationJournal.RecordCallCount).Should(Equal(1))
+			Consistently(fakeOperationJournal.RecordCallCount).Should(Equal(1))
+			operation := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(operation.ID).To(Equal("42"))
+			Expect(operation.State).To(Equal(string(brokerapi.Succeeded)))
+		})
+
+		It("stops watching operations once its context is cancelled", func() {
+			fakeDeployer.CreateReturns(42, []byte("name: service-instance_some-instance-id"), nil)
+			boshClient.GetTaskReturns(boshdirector.BoshTask{ID: 42, State: boshdirector.TaskProcessing}, nil)
+			Expect(b.WatchOperations(watchCtx, time.Millisecond, loggerFactory.NewWithRequestID())).To(Succeed())
+			stopWatching()
+
+			_, err := b.Provision(context.Background(), instanceID, brokerapi.ProvisionDetails{
+				PlanID:    existingPlanID,
+				ServiceID: serviceOfferingID,
+			}, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(boshClient.GetTaskCallCount).Should(Equal(0))
+		})
+
+		It("polls a limited number of operations at once", func() {
+			var operations []operationjournal.Operation
+			for taskID := 1; taskID <= 20; taskID++ {
+				operations = append(operations, operationjournal.Operation{
+					ID:            strconv.Itoa(taskID),
+					InstanceID:    fmt.Sprintf("instance-%d", taskID),
+					ServiceID:     serviceOfferingID,
+					State:         "in progress",
+					OperationData: fmt.Sprintf(`{"BoshTaskID": %d, "OperationType": "update"}`, taskID),
+				})
+			}
+			fakeOperationJournal.AllOperationsReturns(operations, nil)
+
+			var lock sync.Mutex
+			polling, mostPolling := 0, 0
+			release := make(chan struct{})
+			boshClient.GetTaskStub = func(taskID int, logger *log.Logger) (boshdirector.BoshTask, error) {
+				lock.Lock()
+				polling++
+				if polling > mostPolling {
+					mostPolling = polling
+				}
+				lock.Unlock()
+
+				<-release
+
+				lock.Lock()
+				polling--
+				lock.Unlock()
+				return boshdirector.BoshTask{ID: taskID, State: boshdirector.TaskProcessing}, nil
+			}
+			defer close(release)
+
+			Expect(b.WatchOperations(watchCtx, time.Millisecond, loggerFactory.NewWithRequestID())).To(Succeed())
+
+			currentlyPolling := func() int {
+				lock.Lock()
+				defer lock.Unlock()
+				return polling
+			}
+			Eventually(currentlyPolling).Should(Equal(10))
+			Consistently(currentlyPolling).Should(Equal(10))
+		})
+	})
+
+	Describe("listing operations", func() {
+		It("returns the operations recorded for the instance", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{{ID: "42", InstanceID: instanceID}}, nil)
+
+			operations, err := b.Operations(instanceID, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operations).To(Equal([]operationjournal.Operation{{ID: "42", InstanceID: instanceID}}))
+			Expect(fakeOperationJournal.OperationsArgsForCall(0)).To(Equal(instanceID))
+		})
+
+		It("returns an error when the journal is not enabled", func() {
+			b, err := broker.New(boshClient, cfClient, serviceCatalog, brokerConfig, nil, serviceAdapter, fakeDeployer, fakeSecretManager, fakeInstanceLister, fakeMapHasher, nil, loggerFactory)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = b.Operations(instanceID, nil)
+			Expect(err).To(BeAssignableToTypeOf(broker.OperationJournalDisabledError{}))
+		})
+	})
+})
@@ -0,0 +1,148 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+)
+
+// maxOperationWatchErrors is how many times in a row polling a watched
+// operation may fail before the broker stops watching it.
+const maxOperationWatchErrors = 10
+
+// maxConcurrentOperationPolls is how many watched operations are polled at
+// once.
+const maxConcurrentOperationPolls = 10
+
+type watchedOperation struct {
+	instanceID    string
+	operationData string
+	failedPolls   int
+}
+
+// operationWatcher holds the operations the broker polls until they finish,
+// so that their outcome is recorded in the operation journal even when
+// nothing else polls them. They are all polled from the goroutine started by
+// WatchOperations, at most maxConcurrentOperationPolls at a time, until the
+// context it was given is cancelled. Operations are only held while that
+// goroutine runs.
+type operationWatcher struct {
+	lock       sync.Mutex
+	running    bool
+	operations map[string]watchedOperation
+}
+
+func newOperationWatcher() *operationWatcher {
+	return &operationWatcher{operations: map[string]watchedOperation{}}
+}
+
+func (w *operationWatcher) start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.running = true
+}
+
+func (w *operationWatcher) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.running = false
+	w.operations = map[string]watchedOperation{}
+}
+
+// watch adds an operation to be polled, keyed by its operation data, which
+// identifies it for LastOperation.
+func (w *operationWatcher) watch(instanceID, operationData string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.running {
+		return
+	}
+	if _, found := w.operations[operationData]; !found {
+		w.operations[operationData] = watchedOperation{instanceID: instanceID, operationData: operationData}
+	}
+}
+
+func (w *operationWatcher) watched() []watchedOperation {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var operations []watchedOperation
+	for _, operation := range w.operations {
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// polled stops watching an operation once it has finished or could not be
+// polled maxOperationWatchErrors times in a row.
+func (w *operationWatcher) polled(operation watchedOperation, lastOperation brokerapi.LastOperation, err error, logger *log.Logger) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	current, found := w.operations[operation.operationData]
+	if !found {
+		return
+	}
+
+	switch {
+	case err != nil:
+		current.failedPolls++
+		if current.failedPolls >= maxOperationWatchErrors {
+			logger.Printf("stopped watching operation %s of instance %s after %d failed polls\n", operation.operationData, operation.instanceID, maxOperationWatchErrors)
+			delete(w.operations, operation.operationData)
+			return
+		}
+	case lastOperation.State != brokerapi.InProgress:
+		delete(w.operations, operation.operationData)
+		return
+	default:
+		current.failedPolls = 0
+	}
+	w.operations[operation.operationData] = current
+}
+
+// pollWatchedOperations polls the watched operations every interval until ctx
+// is cancelled.
+func (b *Broker) pollWatchedOperations(ctx context.Context, interval time.Duration, logger *log.Logger) {
+	defer b.operationWatcher.stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.pollOperations(ctx, b.operationWatcher.watched(), logger)
+		}
+	}
+}
+
+func (b *Broker) pollOperations(ctx context.Context, operations []watchedOperation, logger *log.Logger) {
+	polls := make(chan struct{}, maxConcurrentOperationPolls)
+	var wg sync.WaitGroup
+	for _, operation := range operations {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case polls <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(operation watchedOperation) {
+			defer wg.Done()
+			defer func() { <-polls }()
+			lastOperation, err := b.LastOperation(ctx, operation.instanceID, brokerapi.PollDetails{OperationData: operation.operationData})
+			b.operationWatcher.polled(operation, lastOperation, err, logger)
+		}(operation)
+	}
+	wg.Wait()
+}
@@ -7,14 +7,19 @@
 package broker
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"strings"
+
+	"github.com/pborman/uuid"
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 )
 
 func (b *Broker) OrphanDeployments(logger *log.Logger) ([]string, error) {
 	rawInstances, err := b.Instances(logger)
 	if err != nil {
-		logger.Printf("error listing instances: %s", err)
+		loggerfactory.Errorf(logger, "error listing instances: %s", err)
 		return nil, b.processError(err, logger)
 	}
 
@@ -25,20 +30,72 @@ func (b *Broker) OrphanDeployments(logger *log.Logger) ([]string, error) {
 
 	deployments, err := b.boshClient.GetDeployments(logger)
 	if err != nil {
-		logger.Printf("error getting deployments: %s", err)
+		loggerfactory.Errorf(logger, "error getting deployments: %s", err)
 		return nil, b.processError(err, logger)
 	}
 
 	var orphanDeploymentNames []string
 	for _, deployment := range deployments {
-		if !strings.HasPrefix(deployment.Name, InstancePrefix) {
+		if !b.ownsDeployment(deployment.Name) {
 			continue
 		}
 
-		if !instanceIDs[instanceID(deployment.Name)] {
+		if !instanceIDs[b.instanceID(deployment.Name)] {
 			orphanDeploymentNames = append(orphanDeploymentNames, deployment.Name)
 		}
 	}
 
 	return orphanDeploymentNames, nil
 }
+
+// DeleteOrphanDeployment deletes a deployment that is still an orphan, first
+// running the pre-delete errands of the plan it was last deployed with when
+// asked to. Its BOSH configs and CredHub secrets are deleted once
+// LastOperation observes that the deployment has gone, as on deprovision.
+func (b *Broker) DeleteOrphanDeployment(ctx context.Context, name string, runPreDeleteErrands bool, logger *log.Logger) (OperationData, error) {
+	id := b.instanceID(name)
+	defer b.instanceLocks.acquire(id)()
+
+	ctx = brokercontext.New(ctx, string(OperationTypeDelete), uuid.New(), b.serviceOffering.Name, id)
+
+	orphans, err := b.OrphanDeployments(logger)
+	if err != nil {
+		return OperationData{}, err
+	}
+	if !b.ownsDeployment(name) || !containsString(orphans, name) {
+		return OperationData{}, b.processError(NewDeploymentNotOrphanedError(fmt.Errorf("deployment %s is not an orphan deployment", name)), logger)
+	}
+
+	tasks, err := b.boshClient.GetTasks(name, logger)
+	if err != nil {
+		return OperationData{}, b.processError(fmt.Errorf("error getting tasks for deployment %s: %s", name, err), logger)
+	}
+	if incompleteTasks := tasks.IncompleteTasks(); len(incompleteTasks) > 0 {
+		return OperationData{}, b.processError(NewOperationInProgressError(
+			fmt.Errorf("deployment %s is still in progress: tasks %s", name, incompleteTasks.ToLog()),
+		), logger)
+	}
+
+	planID := b.lastRecordedPlanID(id, logger)
+	logger.Printf("deleting orphan deployment %s\n", name)
+
+	if runPreDeleteErrands {
+		plan, found := b.serviceOffering.FindPlanByID(planID)
+		if !found {
+			return OperationData{}, b.processError(fmt.Errorf("cannot run the pre-delete errands of orphan deployment %s as the plan it was deployed with is unknown", name), logger)
+		}
+		if errands := plan.PreDeleteErrands(); len(errands) > 0 {
+			operationData, err := b.startPreDeleteErrands(ctx, id, planID, errands, logger)
+			if err != nil {
+				return OperationData{}, b.processError(err, logger)
+			}
+			return operationData, nil
+		}
+	}
+
+	operationData, err := b.startDeleteDeployment(ctx, id, planID, logger)
+	if err != nil {
+		return OperationData{}, b.processError(err, logger)
+	}
+	return operationData, nil
+}
@@ -7,13 +7,19 @@
 package broker_test
 
 import (
+	"context"
 	"errors"
 	"log"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
 var _ = Describe("Orphan Deployments", func() {
@@ -64,6 +70,18 @@ var _ = Describe("Orphan Deployments", func() {
 		Expect(orphans).To(BeEmpty())
 	})
 
+	It("only considers the deployments with the deployment prefix of the service offering", func() {
+		serviceCatalog.DeploymentPrefix = "kafka-instance_"
+		b = createDefaultBroker()
+		deployments := []boshdirector.Deployment{{Name: "service-instance_one"}, {Name: "kafka-instance_two"}}
+		boshClient.GetDeploymentsReturns(deployments, nil)
+
+		orphans, orphanDeploymentsErr = b.OrphanDeployments(logger)
+
+		Expect(orphanDeploymentsErr).NotTo(HaveOccurred())
+		Expect(orphans).To(ConsistOf("kafka-instance_two"))
+	})
+
 	It("logs an error when getting the list of instances fails", func() {
 		fakeInstanceLister.InstancesReturns([]service.Instance{}, errors.New("error listing instances: listing error"))
 
@@ -81,4 +99,114 @@ var _ = Describe("Orphan Deployments", func() {
 		Expect(orphanDeploymentsErr).To(HaveOccurred())
 		Expect(logBuffer.String()).To(ContainSubstring("error getting deployments: get deployment error"))
 	})
+
+	Describe("deleting an orphan deployment", func() {
+		const (
+			orphanName       = "service-instance_orphan"
+			preDeletePlanID  = "pre-delete-plan"
+			preDeleteErrand  = "cleanup"
+			deleteBoshTaskID = 42
+			errandBoshTaskID = 43
+		)
+
+		BeforeEach(func() {
+			serviceCatalog.Plans = append(serviceCatalog.Plans, config.Plan{
+				ID: preDeletePlanID,
+				LifecycleErrands: &sdk.LifecycleErrands{
+					PreDelete: []sdk.Errand{{Name: preDeleteErrand}},
+				},
+			})
+			b = createDefaultBroker()
+
+			boshClient.GetDeploymentsReturns([]boshdirector.Deployment{{Name: orphanName}}, nil)
+			boshClient.DeleteDeploymentReturns(deleteBoshTaskID, nil)
+			boshClient.RunErrandReturns(errandBoshTaskID, nil)
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{ID: "1", InstanceID: "orphan", Type: "create", PlanID: preDeletePlanID, State: string(brokerapi.Succeeded)},
+			}, nil)
+		})
+
+		It("deletes the deployment and records the deletion", func() {
+			operationData, err := b.DeleteOrphanDeployment(context.Background(), orphanName, false, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operationData).To(Equal(broker.OperationData{
+				BoshTaskID:    deleteBoshTaskID,
+				OperationType: broker.OperationTypeDelete,
+			}))
+
+			Expect(boshClient.DeleteDeploymentCallCount()).To(Equal(1))
+			deployment, contextID, _, _ := boshClient.DeleteDeploymentArgsForCall(0)
+			Expect(deployment).To(Equal(orphanName))
+			Expect(contextID).To(Equal("delete-orphan"))
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			operation := fakeOperationJournal.RecordArgsForCall(0)
+			Expect(operation.InstanceID).To(Equal("orphan"))
+			Expect(operation.Type).To(Equal("delete"))
+			Expect(operation.PlanID).To(Equal(preDeletePlanID))
+		})
+
+		It("runs the pre-delete errands of the plan the deployment was last deployed with when asked to", func() {
+			operationData, err := b.DeleteOrphanDeployment(context.Background(), orphanName, true, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operationData.BoshTaskID).To(Equal(errandBoshTaskID))
+			Expect(operationData.Errands).To(HaveLen(1))
+
+			Expect(boshClient.RunErrandCallCount()).To(Equal(1))
+			deployment, errandName, _, _, _, _ := boshClient.RunErrandArgsForCall(0)
+			Expect(deployment).To(Equal(orphanName))
+			Expect(errandName).To(Equal(preDeleteErrand))
+			Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		})
+
+		It("fails to run the pre-delete errands when the plan the deployment was deployed with is unknown", func() {
+			fakeOperationJournal.OperationsReturns(nil, nil)
+
+			_, err := b.DeleteOrphanDeployment(context.Background(), orphanName, true, logger)
+
+			Expect(err).To(MatchError(ContainSubstring("the plan it was deployed with is unknown")))
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+			Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		})
+
+		It("refuses to delete a deployment that has an instance", func() {
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: "orphan"}}, nil)
+
+			_, err := b.DeleteOrphanDeployment(context.Background(), orphanName, false, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotOrphanedError{}))
+			Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		})
+
+		It("refuses to delete a deployment that is not a service instance", func() {
+			boshClient.GetDeploymentsReturns([]boshdirector.Deployment{{Name: "acme-deployment"}}, nil)
+
+			_, err := b.DeleteOrphanDeployment(context.Background(), "acme-deployment", false, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotOrphanedError{}))
+			Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		})
+
+		It("returns an OperationInProgressError when a task is in progress on the deployment", func() {
+			boshClient.GetTasksReturns(boshdirector.BoshTasks{{ID: 41, State: boshdirector.TaskProcessing}}, nil)
+
+			_, err := b.DeleteOrphanDeployment(context.Background(), orphanName, false, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(broker.OperationInProgressError{}))
+			Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		})
+
+		It("fails when BOSH cannot delete the deployment", func() {
+			boshClient.DeleteDeploymentReturns(0, errors.New("director unavailable"))
+
+			_, err := b.DeleteOrphanDeployment(context.Background(), orphanName, false, logger)
+
+			Expect(err).To(HaveOccurred())
+			Expect(logBuffer.String()).To(ContainSubstring("director unavailable"))
+			Expect(fakeOperationJournal.RecordCallCount()).To(BeZero())
+		})
+	})
 })
@@ -0,0 +1,60 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+)
+
+// startPreOperationErrands runs the first of the errands that must succeed
+// before an upgrade or recreate of the deployment goes ahead. LastOperation
+// runs the rest of them, then the operation itself. The deployer checks that
+// the deployment exists and that no other operation is in progress before
+// the first errand runs, rather than once the errands have changed the
+// deployment.
+func (b *Broker) startPreOperationErrands(ctx context.Context, deploymentName string, errands []config.Errand, contextID string, logger *log.Logger) (int, error) {
+	errand := errands[0]
+	taskID, err := b.deployer.RunErrand(ctx, deploymentName, errand.Name, errand.Instances, contextID, logger)
+	if err != nil {
+		return 0, err
+	}
+	logger.Printf("Bosh task ID for errand %s of deployment %s is %d\n", errand.Name, deploymentName, taskID)
+
+	return taskID, nil
+}
+
+// startOperationAfterErrands deploys an upgrade or recreate whose
+// pre-operation errands have all succeeded, under the same BOSH context ID.
+// It is called while polling, so it takes the instance lock like any other
+// operation that deploys.
+func (b *Broker) startOperationAfterErrands(ctx context.Context, deploymentName string, operationData OperationData, logger *log.Logger) (int, error) {
+	defer b.instanceLocks.acquire(b.instanceID(deploymentName))()
+
+	switch operationData.OperationType {
+	case OperationTypeUpgrade:
+		previousPlanID := operationData.PlanID
+		if operationData.PreviousPlanID != "" {
+			previousPlanID = operationData.PreviousPlanID
+		}
+		taskID, _, err := b.deployer.Upgrade(ctx, deploymentName, operationData.PlanID, &previousPlanID, operationData.BoshContextID, logger)
+		return taskID, err
+	case OperationTypeRecreate:
+		return b.deployer.Recreate(ctx, deploymentName, operationData.PlanID, operationData.BoshContextID, logger)
+	default:
+		return 0, fmt.Errorf("cannot start %s of deployment %s after its pre-operation errands", operationData.OperationType, deploymentName)
+	}
+}
+
+func preOperationThenPostDeployErrands(preOperationErrands, postDeployErrands []config.Errand) []config.Errand {
+	errands := make([]config.Errand, 0, len(preOperationErrands)+len(postDeployErrands))
+	errands = append(errands, preOperationErrands...)
+	return append(errands, postDeployErrands...)
+}
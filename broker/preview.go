@@ -0,0 +1,116 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+)
+
+func (b *Broker) PreviewUpgrade(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, logger *log.Logger) (DeploymentPreview, error) {
+	logger.Printf("previewing upgrade of instance %s", instanceID)
+
+	if details.PlanID == "" {
+		return DeploymentPreview{}, b.processError(errors.New("no plan ID provided in upgrade request body"), logger)
+	}
+
+	if _, found := b.serviceOffering.FindPlanByID(details.PlanID); !found {
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", details.PlanID)
+		return DeploymentPreview{}, b.processError(fmt.Errorf("plan %s not found", details.PlanID), logger)
+	}
+
+	preview, err := b.deployer.PreviewUpgrade(ctx, b.deploymentName(instanceID), details.PlanID, &details.PlanID, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error previewing upgrade of instance %s: %s", instanceID, err)
+		return DeploymentPreview{}, b.processPreviewError(ctx, err, logger)
+	}
+
+	return preview, nil
+}
+
+func (b *Broker) PreviewUpdate(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, logger *log.Logger) (DeploymentPreview, error) {
+	logger.Printf("previewing update of instance %s", instanceID)
+
+	if details.PlanID == "" {
+		return DeploymentPreview{}, b.processError(errors.New("no plan ID provided in update request body"), logger)
+	}
+
+	if _, found := b.serviceOffering.FindPlanByID(details.PlanID); !found {
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", details.PlanID)
+		return DeploymentPreview{}, b.processError(fmt.Errorf("plan %s not found", details.PlanID), logger)
+	}
+
+	previousPlanID := details.PreviousValues.PlanID
+	if previousPlanID == "" {
+		previousPlanID = details.PlanID
+	}
+
+	requestParams, err := convertDetailsToMap(brokerapi.DetailsWithRawParameters(details))
+	if err != nil {
+		return DeploymentPreview{}, b.processError(err, logger)
+	}
+
+	secretsMap, err := b.getSecretMap(instanceID, logger)
+	if err != nil {
+		return DeploymentPreview{}, b.processError(err, logger)
+	}
+
+	preview, err := b.deployer.PreviewUpdate(ctx, b.deploymentName(instanceID), details.PlanID, requestParams, &previousPlanID, secretsMap, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error previewing update of instance %s: %s", instanceID, err)
+		return DeploymentPreview{}, b.processPreviewError(ctx, err, logger)
+	}
+
+	return preview, nil
+}
+
+// PendingChanges returns the changes that the broker would make to an
+// instance on the plan it is deployed with, which block it from being updated.
+func (b *Broker) PendingChanges(ctx context.Context, instanceID string, logger *log.Logger) (PendingChanges, error) {
+	logger.Printf("checking instance %s for pending changes", instanceID)
+
+	planID, err := b.deployedPlanID(instanceID, logger)
+	if err != nil {
+		return PendingChanges{}, b.processError(fmt.Errorf("error finding the plan of instance %s: %s", instanceID, err), logger)
+	}
+	if planID == "" {
+		return PendingChanges{}, b.processError(NewDeploymentNotFoundError(fmt.Errorf("the plan of instance %s could not be found", instanceID)), logger)
+	}
+
+	if _, found := b.serviceOffering.FindPlanByID(planID); !found {
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", planID)
+		return PendingChanges{}, b.processError(fmt.Errorf("plan %s not found", planID), logger)
+	}
+
+	secretsMap, err := b.getSecretMap(instanceID, logger)
+	if err != nil {
+		return PendingChanges{}, b.processError(err, logger)
+	}
+
+	pendingChanges, err := b.deployer.PendingChanges(ctx, b.deploymentName(instanceID), planID, secretsMap, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error checking instance %s for pending changes: %s", instanceID, err)
+		return PendingChanges{}, b.processPreviewError(ctx, err, logger)
+	}
+
+	return pendingChanges, nil
+}
+
+func (b *Broker) processPreviewError(ctx context.Context, err error, logger *log.Logger) error {
+	switch err := err.(type) {
+	case serviceadapter.UnknownFailureError, serviceadapter.TimeoutError:
+		return b.processError(adapterToAPIError(ctx, err), logger)
+	default:
+		return b.processError(err, logger)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+)
+
+var _ = Describe("Preview", func() {
+	const instanceID = "some-instance"
+
+	var (
+		logger  *log.Logger
+		preview = broker.DeploymentPreview{ManifestDiff: "--- deployed/manifest.yml\n+++ generated/manifest.yml\n"}
+	)
+
+	BeforeEach(func() {
+		logger = loggerFactory.NewWithRequestID()
+		b = createDefaultBroker()
+	})
+
+	Describe("PreviewUpgrade", func() {
+		BeforeEach(func() {
+			fakeDeployer.PreviewUpgradeReturns(preview, nil)
+		})
+
+		It("previews the upgrade of the instance to its current plan", func() {
+			actualPreview, err := b.PreviewUpgrade(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: existingPlanID}, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualPreview).To(Equal(preview))
+
+			Expect(fakeDeployer.PreviewUpgradeCallCount()).To(Equal(1))
+			_, actualDeploymentName, actualPlanID, actualPreviousPlanID, _ := fakeDeployer.PreviewUpgradeArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(actualPlanID).To(Equal(existingPlanID))
+			Expect(*actualPreviousPlanID).To(Equal(existingPlanID))
+
+			Expect(fakeDeployer.UpgradeCallCount()).To(BeZero())
+		})
+
+		It("fails when no plan ID is provided", func() {
+			_, err := b.PreviewUpgrade(context.Background(), instanceID, brokerapi.UpdateDetails{}, logger)
+			Expect(err).To(MatchError("no plan ID provided in upgrade request body"))
+		})
+
+		It("fails when the plan cannot be found", func() {
+			_, err := b.PreviewUpgrade(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: "not-a-plan"}, logger)
+			Expect(err).To(MatchError("plan not-a-plan not found"))
+		})
+
+		It("returns deployment not found errors as they are", func() {
+			fakeDeployer.PreviewUpgradeReturns(broker.DeploymentPreview{}, broker.NewDeploymentNotFoundError(errors.New("not found")))
+
+			_, err := b.PreviewUpgrade(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: existingPlanID}, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+		})
+
+		It("returns the adapter error when manifest generation fails", func() {
+			fakeDeployer.PreviewUpgradeReturns(broker.DeploymentPreview{}, serviceadapter.NewUnknownFailureError("adapter says no"))
+
+			_, err := b.PreviewUpgrade(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: existingPlanID}, logger)
+			Expect(err).To(MatchError("adapter says no"))
+		})
+	})
+
+	Describe("PreviewUpdate", func() {
+		BeforeEach(func() {
+			fakeDeployer.PreviewUpdateReturns(preview, nil)
+			fakeSecretManager.ResolveManifestSecretsReturns(map[string]string{"((secret))": "value"}, nil)
+		})
+
+		It("previews the update with the request parameters and current secrets", func() {
+			details := brokerapi.UpdateDetails{
+				PlanID:         secondPlanID,
+				RawParameters:  json.RawMessage(`{"maxclients": 200}`),
+				PreviousValues: brokerapi.PreviousValues{PlanID: existingPlanID},
+			}
+
+			actualPreview, err := b.PreviewUpdate(context.Background(), instanceID, details, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualPreview).To(Equal(preview))
+
+			Expect(fakeDeployer.PreviewUpdateCallCount()).To(Equal(1))
+			_, actualDeploymentName, actualPlanID, actualRequestParams, actualPreviousPlanID, actualSecretsMap, _ := fakeDeployer.PreviewUpdateArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(actualPlanID).To(Equal(secondPlanID))
+			Expect(actualRequestParams).To(HaveKeyWithValue("parameters", map[string]interface{}{"maxclients": float64(200)}))
+			Expect(*actualPreviousPlanID).To(Equal(existingPlanID))
+			Expect(actualSecretsMap).To(Equal(map[string]string{"((secret))": "value"}))
+
+			Expect(fakeDeployer.UpdateCallCount()).To(BeZero())
+		})
+
+		It("uses the requested plan as the previous plan when none is provided", func() {
+			_, err := b.PreviewUpdate(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: existingPlanID}, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, _, actualPreviousPlanID, _, _ := fakeDeployer.PreviewUpdateArgsForCall(0)
+			Expect(*actualPreviousPlanID).To(Equal(existingPlanID))
+		})
+
+		It("fails when the current secrets cannot be resolved", func() {
+			fakeSecretManager.ResolveManifestSecretsReturns(nil, errors.New("credhub is down"))
+
+			_, err := b.PreviewUpdate(context.Background(), instanceID, brokerapi.UpdateDetails{PlanID: existingPlanID}, logger)
+			Expect(err).To(MatchError("credhub is down"))
+			Expect(fakeDeployer.PreviewUpdateCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("PendingChanges", func() {
+		pendingChanges := broker.PendingChanges{
+			Changes: []broker.PendingChange{
+				{Path: "releases/redis/version", Change: broker.PendingChangeChanged},
+			},
+			ManifestDiff: "--- deployed/manifest.yml\n+++ generated/manifest.yml\n",
+		}
+
+		BeforeEach(func() {
+			fakeDeployer.PendingChangesReturns(pendingChanges, nil)
+			fakeSecretManager.ResolveManifestSecretsReturns(map[string]string{"((secret))": "value"}, nil)
+		})
+
+		It("returns the pending changes of the instance on the plan it is deployed with", func() {
+			fakeOperationJournal.OperationsReturns([]operationjournal.Operation{
+				{Type: string(broker.OperationTypeCreate), PlanID: existingPlanID, State: string(brokerapi.Succeeded)},
+			}, nil)
+
+			actualPendingChanges, err := b.PendingChanges(context.Background(), instanceID, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualPendingChanges).To(Equal(pendingChanges))
+
+			Expect(fakeDeployer.PendingChangesCallCount()).To(Equal(1))
+			_, actualDeploymentName, actualPlanID, actualSecretsMap, _ := fakeDeployer.PendingChangesArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(actualPlanID).To(Equal(existingPlanID))
+			Expect(actualSecretsMap).To(Equal(map[string]string{"((secret))": "value"}))
+		})
+
+		It("uses the plan the platform reports when the operation journal does not know it", func() {
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: instanceID, PlanUniqueID: secondPlanID}}, nil)
+
+			_, err := b.PendingChanges(context.Background(), instanceID, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, actualPlanID, _, _ := fakeDeployer.PendingChangesArgsForCall(0)
+			Expect(actualPlanID).To(Equal(secondPlanID))
+		})
+
+		It("fails when the plan of the instance cannot be found", func() {
+			_, err := b.PendingChanges(context.Background(), instanceID, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+			Expect(fakeDeployer.PendingChangesCallCount()).To(BeZero())
+		})
+
+		It("fails when the instance is deployed with a plan that is no longer configured", func() {
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: instanceID, PlanUniqueID: "not-a-plan"}}, nil)
+
+			_, err := b.PendingChanges(context.Background(), instanceID, logger)
+			Expect(err).To(MatchError("plan not-a-plan not found"))
+		})
+
+		It("returns deployment not found errors as they are", func() {
+			fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: instanceID, PlanUniqueID: existingPlanID}}, nil)
+			fakeDeployer.PendingChangesReturns(broker.PendingChanges{}, broker.NewDeploymentNotFoundError(errors.New("not found")))
+
+			_, err := b.PendingChanges(context.Background(), instanceID, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+		})
+	})
+})
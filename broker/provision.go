@@ -34,11 +34,11 @@ import (
 func (b *Broker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails,
 	asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
 
-	b.deploymentLock.Lock()
-	defer b.deploymentLock.Unlock()
+	defer b.instanceLocks.acquire(instanceID)()
 
 	requestID := uuid.New()
 	ctx = brokercontext.New(ctx, string(OperationTypeCreate), requestID, b.serviceOffering.Name, instanceID)
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
 	logger := b.loggerFactory.NewWithContext(ctx)
 
 	if !asyncAllowed {
@@ -88,6 +88,10 @@ func (b *Broker) Provision(ctx context.Context, instanceID string, details broke
 		return brokerapi.ProvisionedServiceSpec{}, b.processError(err, logger)
 	}
 
+	parameters, _ := requestParams["parameters"].(map[string]interface{})
+	b.recordStartedDeployment(ctx, instanceID, details.PlanID, parameters, operationData, logger)
+	b.registerInstance(instanceID, details.PlanID, logger)
+
 	return brokerapi.ProvisionedServiceSpec{
 		IsAsync:       true,
 		DashboardURL:  dashboardURL,
@@ -110,7 +114,7 @@ func (b *Broker) provisionInstance(ctx context.Context, instanceID string, planI
 		))
 	}
 
-	_, found, err := b.boshClient.GetDeployment(deploymentName(instanceID), logger)
+	_, found, err := b.boshClient.GetDeployment(b.deploymentName(instanceID), logger)
 	switch err := err.(type) {
 	case boshdirector.RequestError:
 		return errs(NewBoshRequestError("create", fmt.Errorf("could not get manifest: %s", err)))
@@ -125,14 +129,12 @@ func (b *Broker) provisionInstance(ctx context.Context, instanceID string, planI
 		))
 	}
 
-	cfPlanCounts, err := b.cfClient.CountInstancesOfServiceOffering(b.serviceOffering.ID, logger)
-	if err != nil {
-		return errs(NewGenericError(ctx, err))
+	if err := b.reserveQuota(ctx, instanceID, plan, "", requestPlatform(requestParams), logger); err != nil {
+		return errs(err)
 	}
-
-	quotasErrors, ok := b.checkQuotas(ctx, plan, cfPlanCounts, b.serviceOffering.ID, logger)
-	if !ok {
-		return errs(quotasErrors)
+	errs = func(err error) (OperationData, string, error) {
+		b.releaseQuota(instanceID)
+		return OperationData{}, "", err
 	}
 
 	if err := b.checkPlanSchemas(ctx, requestParams, plan, logger); err != nil {
@@ -145,23 +147,24 @@ func (b *Broker) provisionInstance(ctx context.Context, instanceID string, planI
 		boshContextID = uuid.New()
 	}
 
-	boshTaskID, manifest, err := b.deployer.Create(deploymentName(instanceID), plan.ID, requestParams, boshContextID, logger)
+	boshTaskID, manifest, err := b.deployer.Create(ctx, b.deploymentName(instanceID), plan.ID, requestParams, boshContextID, logger)
 	switch err := err.(type) {
 	case boshdirector.RequestError:
 		return errs(NewBoshRequestError("create", err))
 	case DisplayableError:
 		return errs(err)
-	case serviceadapter.UnknownFailureError:
+	case serviceadapter.UnknownFailureError, serviceadapter.TimeoutError:
 		return errs(adapterToAPIError(ctx, err))
 	case error:
 		return errs(NewGenericError(ctx, err))
 	}
 
 	ctx = brokercontext.WithBoshTaskID(ctx, boshTaskID)
+	logger = b.loggerFactory.NewWithContext(ctx)
 
 	abridgedPlan := plan.AdapterPlan(b.serviceOffering.GlobalProperties)
 
-	dashboardUrl, err := b.adapterClient.GenerateDashboardUrl(instanceID, abridgedPlan, manifest, logger)
+	dashboardUrl, err := b.adapterClient.GenerateDashboardUrl(ctx, instanceID, abridgedPlan, manifest, logger)
 	if err != nil {
 		logger.Printf("generating dashboard: %v\n", err)
 	}
@@ -179,6 +182,7 @@ func (b *Broker) provisionInstance(ctx context.Context, instanceID string, planI
 	}
 
 	if err := adapterToAPIError(ctx, err); err != nil {
+		b.releaseQuota(instanceID)
 		return operationData, dashboardUrl, err
 	}
 
@@ -188,7 +192,7 @@ func (b *Broker) provisionInstance(ctx context.Context, instanceID string, planI
 func (b *Broker) checkPlanSchemas(ctx context.Context, requestParams map[string]interface{}, plan config.Plan, logger *log.Logger) error {
 	if b.EnablePlanSchemas {
 		var schemas brokerapi.ServiceSchemas
-		schemas, err := b.adapterClient.GeneratePlanSchema(plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+		schemas, err := b.planSchemas(ctx, plan, logger)
 		if err != nil {
 			if _, ok := err.(serviceadapter.NotImplementedError); !ok {
 				return err
@@ -24,6 +24,7 @@ import (
 	brokerfakes "github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/noopservicescontroller"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
@@ -109,7 +110,7 @@ var _ = Describe("Provisioning", func() {
 
 		It("invokes the deployer", func() {
 			Expect(fakeDeployer.CreateCallCount()).To(Equal(1))
-			actualDeploymentName, actualPlan, actualRequestParams, actualBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
+			_, actualDeploymentName, actualPlan, actualRequestParams, actualBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
 			Expect(actualRequestParams).To(Equal(map[string]interface{}{
 				"plan_id":           planID,
 				"context":           arbContext,
@@ -140,7 +141,7 @@ var _ = Describe("Provisioning", func() {
 
 		It("invokes the adapter for the dashboard url, merging global and plan properties", func() {
 			Expect(serviceAdapter.GenerateDashboardUrlCallCount()).To(Equal(1))
-			instanceID, plan, boshManifest, _ := serviceAdapter.GenerateDashboardUrlArgsForCall(0)
+			_, instanceID, plan, boshManifest, _ := serviceAdapter.GenerateDashboardUrlArgsForCall(0)
 			Expect(instanceID).To(Equal(instanceID))
 			expectedProperties := sdk.Properties{"super": "no", "a_global_property": "global_value", "some_other_global_property": "other_global_value"}
 			Expect(plan).To(Equal(sdk.Plan{
@@ -302,7 +303,7 @@ var _ = Describe("Provisioning", func() {
 
 		It("calls the deployer with a bosh context id", func() {
 			Expect(fakeDeployer.CreateCallCount()).To(Equal(1))
-			_, _, _, actualBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
+			_, _, _, _, actualBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
 			Expect(actualBoshContextID).NotTo(BeEmpty())
 		})
 
@@ -332,10 +333,10 @@ var _ = Describe("Provisioning", func() {
 
 			It("calls the deployer with a different bosh context id", func() {
 				Expect(fakeDeployer.CreateCallCount()).To(Equal(2))
-				_, _, _, firstBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
+				_, _, _, _, firstBoshContextID, _ := fakeDeployer.CreateArgsForCall(0)
 				Expect(firstBoshContextID).NotTo(BeNil())
 
-				_, _, _, secondBoshContextID, _ := fakeDeployer.CreateArgsForCall(1)
+				_, _, _, _, secondBoshContextID, _ := fakeDeployer.CreateArgsForCall(1)
 				Expect(secondBoshContextID).NotTo(Equal(firstBoshContextID))
 			})
 		})
@@ -404,7 +405,7 @@ var _ = Describe("Provisioning", func() {
 		})
 
 		It("no arbitrary params are passed to the adapter", func() {
-			_, _, actualRequestParams, _, _ := fakeDeployer.CreateArgsForCall(0)
+			_, _, _, actualRequestParams, _, _ := fakeDeployer.CreateArgsForCall(0)
 			Expect(actualRequestParams["parameters"]).To(HaveLen(0))
 		})
 
@@ -950,6 +951,37 @@ var _ = Describe("Provisioning", func() {
 				Expect(provisionErr.Error()).To(ContainSubstring("global quotas [ips: (limit 5, used 5, requires 1)] would be exceeded by this deployment"))
 			})
 
+			It("deploy fails when instances of another plan have used up the global resource limit", func() {
+				cfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+					cfServicePlan("1234", existingPlanID, "url", "name"): 5,
+				}, nil)
+
+				plan := existingPlan
+				plan.ResourceCosts = map[string]int{"ips": 1}
+				planToDeploy := secondPlan
+				planToDeploy.ResourceCosts = map[string]int{"ips": 1}
+				catalogWithResourceQuotas := serviceCatalog
+				catalogWithResourceQuotas.GlobalQuotas.ResourceLimits = map[string]int{"ips": 5}
+				catalogWithResourceQuotas.Plans = config.Plans{plan, planToDeploy}
+				b = createBrokerWithServiceCatalog(catalogWithResourceQuotas)
+
+				_, provisionErr = b.Provision(
+					context.Background(),
+					instanceID,
+					brokerapi.ProvisionDetails{
+						PlanID:           secondPlanID,
+						RawContext:       jsonContext,
+						RawParameters:    jsonParams,
+						OrganizationGUID: organizationGUID,
+						SpaceGUID:        spaceGUID,
+						ServiceID:        serviceOfferingID,
+					},
+					asyncAllowed,
+				)
+
+				Expect(provisionErr).To(MatchError(ContainSubstring("global quotas [ips: (limit 5, used 5, requires 1)] would be exceeded by this deployment")))
+			})
+
 			It("succeeds when plan resource quota is set and has been reached but there is no instance count limit", func() {
 				planResourceLimits := map[string]int{"ips": 5} // plan costs 1 IP per instance
 				provisionErr = deployWithQuotas(
@@ -1001,6 +1033,66 @@ var _ = Describe("Provisioning", func() {
 				Expect(provisionErr).NotTo(HaveOccurred())
 			})
 		})
+
+		It("does not count the instances when no quotas are configured", func() {
+			catalogWithoutQuotas := serviceCatalog
+			catalogWithoutQuotas.GlobalQuotas = config.Quotas{}
+			catalogWithoutQuotas.Plans = config.Plans{secondPlan}
+			b = createBrokerWithServiceCatalog(catalogWithoutQuotas)
+			countsAtStartup := cfClient.CountInstancesOfServiceOfferingCallCount()
+
+			_, provisionErr = b.Provision(
+				context.Background(),
+				instanceID,
+				brokerapi.ProvisionDetails{PlanID: secondPlanID, RawContext: jsonContext, RawParameters: jsonParams},
+				true,
+			)
+
+			Expect(provisionErr).NotTo(HaveOccurred())
+			Expect(cfClient.CountInstancesOfServiceOfferingCallCount()).To(Equal(countsAtStartup))
+		})
+
+		Context("when the request comes from kubernetes", func() {
+			BeforeEach(func() {
+				jsonContext, _ = json.Marshal(map[string]interface{}{"platform": "kubernetes", "namespace": "some-namespace"})
+			})
+
+			It("counts the instances with the instance lister", func() {
+				fakeInstanceLister.InstancesReturns([]service.Instance{
+					{GUID: "instance-1", PlanUniqueID: existingPlanID},
+					{GUID: "instance-2", PlanUniqueID: secondPlanID},
+				}, nil)
+				planLimit := 1
+
+				provisionErr = deployWithQuotas(quotaCase{PlanInstanceLimit: &planLimit}, existingPlanID, 0)
+
+				Expect(provisionErr).To(MatchError(ContainSubstring("plan instance limit exceeded for service ID: service-id. Total instances: 1")))
+				Expect(cfClient.CountInstancesOfServiceOfferingCallCount()).To(BeZero())
+			})
+
+			It("provisions with the kubernetes context when the quotas allow it", func() {
+				fakeInstanceLister.InstancesReturns([]service.Instance{
+					{GUID: "instance-1", PlanUniqueID: secondPlanID},
+				}, nil)
+				planLimit := 1
+
+				provisionErr = deployWithQuotas(quotaCase{PlanInstanceLimit: &planLimit}, existingPlanID, 0)
+
+				Expect(provisionErr).NotTo(HaveOccurred())
+				_, _, _, requestParams, _, _ := fakeDeployer.CreateArgsForCall(0)
+				Expect(requestParams["context"]).To(Equal(map[string]interface{}{"platform": "kubernetes", "namespace": "some-namespace"}))
+			})
+
+			It("fails when the instances cannot be listed", func() {
+				fakeInstanceLister.InstancesReturns(nil, errors.New("instances unavailable"))
+				planLimit := 1
+
+				provisionErr = deployWithQuotas(quotaCase{PlanInstanceLimit: &planLimit}, existingPlanID, 0)
+
+				Expect(provisionErr).To(HaveOccurred())
+				Expect(fakeDeployer.CreateCallCount()).To(BeZero())
+			})
+		})
 	})
 
 	Context("when maintenance info is passed", func() {
@@ -5,16 +5,165 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 )
 
-func (b *Broker) checkQuotas(ctx context.Context, plan config.Plan, cfPlanCounts map[cf.ServicePlan]int, serviceOffering string, logger *log.Logger) (error, bool) {
-	var quotasErrors []error
+// quotaReservationTimeout bounds how long a reservation is kept for an
+// instance the platform does not start counting, e.g. when the platform did
+// not receive the broker's response. CF counts a new instance as soon as it
+// has the broker's response, and the reservation of an operation in progress
+// is renewed whenever it is polled, so this need only be a little longer than
+// the platform waits for the broker to respond.
+const quotaReservationTimeout = 15 * time.Minute
+
+// quotaReservation is an instance the quotas have allowed but which the
+// platform may not count yet. platformCount is how many instances the
+// platform counted on the plan when the reservation was made, or when the
+// reservations on the plan were last found to be counted.
+type quotaReservation struct {
+	planID         string
+	previousPlanID string
+	listed         bool
+	platformCount  int
+	reservedAt     time.Time
+	expires        time.Time
+}
+
+// reserveQuota checks the quotas allow the instance on the plan and, if so,
+// reserves its place. The platform only counts an instance once the broker
+// has responded, and only counts a plan change once it has succeeded, so the
+// reservation is kept until the platform's count of the plan reflects it.
+// previousPlanID is the plan an update moves the instance from, which frees
+// its place there; a plan change never adds an instance to the global count.
+// platform is the platform named in the request's context. Nothing is counted
+// when neither the plan nor the offering has quotas.
+func (b *Broker) reserveQuota(ctx context.Context, instanceID string, plan config.Plan, previousPlanID, platform string, logger *log.Logger) error {
+	if !b.hasQuotas(plan) {
+		return nil
+	}
 
-	planCounts := convertCfPlanCounts(cfPlanCounts)
+	listed := b.listsInstances(platform)
+	planCounts, err := b.countInstancesByPlanID(listed, logger)
+	if err != nil {
+		return NewGenericError(ctx, err)
+	}
+
+	b.quotaLock.Lock()
+	defer b.quotaLock.Unlock()
+
+	b.dropCountedReservations(listed, planCounts)
+
+	reservedCounts := map[string]int{}
+	for planID, count := range planCounts {
+		reservedCounts[planID] = count
+	}
+	for reservedInstanceID, reservation := range b.quotaReserved {
+		if reservedInstanceID == instanceID {
+			continue
+		}
+		reservedCounts[reservation.planID]++
+		if reservation.previousPlanID != "" {
+			reservedCounts[reservation.previousPlanID]--
+		}
+	}
+	if previousPlanID != "" {
+		reservedCounts[previousPlanID]--
+	}
+
+	quotasErrors, ok := b.checkQuotas(ctx, plan, reservedCounts, b.serviceOffering.ID, logger)
+	if !ok {
+		return quotasErrors
+	}
+
+	now := time.Now()
+	b.quotaReserved[instanceID] = quotaReservation{
+		planID:         plan.ID,
+		previousPlanID: previousPlanID,
+		listed:         listed,
+		platformCount:  planCounts[plan.ID],
+		reservedAt:     now,
+		expires:        now.Add(quotaReservationTimeout),
+	}
+	return nil
+}
+
+// renewQuotaReservation keeps the reservation for an instance whose operation
+// is still in progress, as the platform may only count it once the operation
+// has succeeded.
+func (b *Broker) renewQuotaReservation(instanceID string) {
+	b.quotaLock.Lock()
+	defer b.quotaLock.Unlock()
+	if reservation, found := b.quotaReserved[instanceID]; found {
+		reservation.expires = time.Now().Add(quotaReservationTimeout)
+		b.quotaReserved[instanceID] = reservation
+	}
+}
+
+// releaseQuota drops the reservation for an instance whose operation the
+// platform will not count: the request failed, the operation failed, or the
+// instance is being deleted.
+func (b *Broker) releaseQuota(instanceID string) {
+	b.quotaLock.Lock()
+	defer b.quotaLock.Unlock()
+	delete(b.quotaReserved, instanceID)
+}
+
+// dropCountedReservations drops the reservations that have expired and those
+// that planCounts, the platform's count of instances on each plan, now
+// reflects. It must be called with the quota lock held, before the
+// reservations are added to the count, so that an instance is never missing
+// from both. The platform's counts do not say which instances they include,
+// so the reservations on a plan are taken as counted oldest first, by how
+// many more instances the platform counts on the plan than when the oldest
+// was made. Only the reservations of the kind of platform counted are checked.
+func (b *Broker) dropCountedReservations(listed bool, planCounts map[string]int) {
+	now := time.Now()
+	reservedByPlan := map[string][]string{}
+	for instanceID, reservation := range b.quotaReserved {
+		if now.After(reservation.expires) {
+			delete(b.quotaReserved, instanceID)
+			continue
+		}
+		if reservation.listed == listed {
+			reservedByPlan[reservation.planID] = append(reservedByPlan[reservation.planID], instanceID)
+		}
+	}
+
+	for planID, instanceIDs := range reservedByPlan {
+		sort.Slice(instanceIDs, func(i, j int) bool {
+			return b.quotaReserved[instanceIDs[i]].reservedAt.Before(b.quotaReserved[instanceIDs[j]].reservedAt)
+		})
+
+		counted := planCounts[planID] - b.quotaReserved[instanceIDs[0]].platformCount
+		if counted <= 0 {
+			continue
+		}
+		for index, instanceID := range instanceIDs {
+			if index < counted {
+				delete(b.quotaReserved, instanceID)
+				continue
+			}
+			reservation := b.quotaReserved[instanceID]
+			reservation.platformCount = planCounts[planID]
+			b.quotaReserved[instanceID] = reservation
+		}
+	}
+}
+
+func (b *Broker) hasQuotas(plan config.Plan) bool {
+	return plan.Quotas.ServiceInstanceLimit != nil ||
+		plan.Quotas.ResourceLimits != nil ||
+		b.serviceOffering.GlobalQuotas.ServiceInstanceLimit != nil ||
+		b.serviceOffering.GlobalQuotas.ResourceLimits != nil
+}
+
+func (b *Broker) checkQuotas(ctx context.Context, plan config.Plan, planCounts map[string]int, serviceOffering string, logger *log.Logger) (error, bool) {
+	var quotasErrors []error
 
 	if instanceLimit := plan.Quotas.ServiceInstanceLimit; instanceLimit != nil {
 		if err := checkPlanServiceCount(plan, planCounts, *instanceLimit, serviceOffering); err != nil {
@@ -97,7 +246,7 @@ func checkGlobalResourceQuotaNotExceeded(plan config.Plan, plans []config.Plan,
 		var currentUsage int
 
 		for _, p := range plans {
-			instanceCount := planCounts[plan.ID]
+			instanceCount := planCounts[p.ID]
 			cost, ok := p.ResourceCosts[kind]
 			if ok {
 				currentUsage += cost * instanceCount
@@ -0,0 +1,232 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+const (
+	RepairRedeploy     = "redeploy"
+	RepairDeleteOrphan = "delete-orphan"
+)
+
+// DriftReport describes where the service instances known to the platform and
+// their BOSH deployments have drifted apart.
+type DriftReport struct {
+	OrphanDeployments  []string           `json:"orphan_deployments"`
+	MissingDeployments []string           `json:"missing_deployments"`
+	PlanMismatches     []PlanMismatch     `json:"plan_mismatches"`
+	FailedDeployments  []FailedDeployment `json:"failed_deployments"`
+	Repairs            []Repair           `json:"repairs,omitempty"`
+}
+
+// PlanMismatch is an instance whose plan on the platform differs from the plan
+// it was last deployed with, as recorded in the operation journal.
+type PlanMismatch struct {
+	InstanceID     string `json:"service_instance_id"`
+	PlanID         string `json:"plan_id"`
+	DeployedPlanID string `json:"deployed_plan_id"`
+}
+
+// FailedDeployment is an instance whose most recent BOSH task failed.
+type FailedDeployment struct {
+	InstanceID  string `json:"service_instance_id"`
+	BoshTaskID  int    `json:"bosh_task_id"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+}
+
+// ReconcileRepairs are the repairs to make once drift has been detected.
+// Orphan deployments are only deleted when named, so that an operator
+// confirms each one, and their pre-delete errands run when asked to.
+type ReconcileRepairs struct {
+	RedeployPlanMismatches bool     `json:"redeploy_plan_mismatches"`
+	DeleteOrphans          []string `json:"delete_orphans"`
+	RunPreDeleteErrands    bool     `json:"run_pre_delete_errands"`
+}
+
+// Repair is a repair made while reconciling. Error is set when the repair
+// could not be started.
+type Repair struct {
+	Action     string `json:"action"`
+	Target     string `json:"target"`
+	BoshTaskID int    `json:"bosh_task_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r DriftReport) HasDrift() bool {
+	return len(r.OrphanDeployments) > 0 || len(r.MissingDeployments) > 0 || len(r.PlanMismatches) > 0 || len(r.FailedDeployments) > 0
+}
+
+// Reconcile reports the drift between the platform's service instances and
+// their BOSH deployments, then makes the requested repairs. Plan mismatches
+// can only be detected when the operation journal is enabled.
+func (b *Broker) Reconcile(ctx context.Context, repairs ReconcileRepairs, logger *log.Logger) (DriftReport, error) {
+	report, err := b.driftReport(logger)
+	if err != nil {
+		return DriftReport{}, err
+	}
+
+	if repairs.RedeployPlanMismatches {
+		for _, mismatch := range report.PlanMismatches {
+			report.Repairs = append(report.Repairs, b.redeployPlanMismatch(ctx, mismatch, logger))
+		}
+	}
+
+	for _, name := range repairs.DeleteOrphans {
+		report.Repairs = append(report.Repairs, b.deleteConfirmedOrphan(ctx, name, repairs.RunPreDeleteErrands, logger))
+	}
+
+	return report, nil
+}
+
+func (b *Broker) driftReport(logger *log.Logger) (DriftReport, error) {
+	instances, err := b.Instances(logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error listing instances: %s", err)
+		return DriftReport{}, err
+	}
+
+	deployments, err := b.boshClient.GetDeployments(logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error getting deployments: %s", err)
+		return DriftReport{}, b.processError(err, logger)
+	}
+
+	deployed := map[string]bool{}
+	for _, deployment := range deployments {
+		if b.ownsDeployment(deployment.Name) {
+			deployed[b.instanceID(deployment.Name)] = true
+		}
+	}
+
+	report := DriftReport{
+		OrphanDeployments:  []string{},
+		MissingDeployments: []string{},
+		PlanMismatches:     []PlanMismatch{},
+		FailedDeployments:  []FailedDeployment{},
+	}
+
+	known := map[string]bool{}
+	for _, instance := range instances {
+		known[instance.GUID] = true
+
+		if !deployed[instance.GUID] {
+			report.MissingDeployments = append(report.MissingDeployments, instance.GUID)
+			continue
+		}
+
+		if mismatch, found := b.planMismatch(instance, logger); found {
+			report.PlanMismatches = append(report.PlanMismatches, mismatch)
+		}
+
+		failed, found, err := b.failedDeployment(instance.GUID, logger)
+		if err != nil {
+			return DriftReport{}, b.processError(err, logger)
+		}
+		if found {
+			report.FailedDeployments = append(report.FailedDeployments, failed)
+		}
+	}
+
+	for _, deployment := range deployments {
+		if b.ownsDeployment(deployment.Name) && !known[b.instanceID(deployment.Name)] {
+			report.OrphanDeployments = append(report.OrphanDeployments, deployment.Name)
+		}
+	}
+
+	return report, nil
+}
+
+func (b *Broker) planMismatch(instance service.Instance, logger *log.Logger) (PlanMismatch, bool) {
+	deployedPlanID := b.lastRecordedPlanID(instance.GUID, logger)
+	if instance.PlanUniqueID == "" || deployedPlanID == "" || instance.PlanUniqueID == deployedPlanID {
+		return PlanMismatch{}, false
+	}
+
+	return PlanMismatch{
+		InstanceID:     instance.GUID,
+		PlanID:         instance.PlanUniqueID,
+		DeployedPlanID: deployedPlanID,
+	}, true
+}
+
+func (b *Broker) failedDeployment(instanceID string, logger *log.Logger) (FailedDeployment, bool, error) {
+	tasks, err := b.boshClient.GetTasks(b.deploymentName(instanceID), logger)
+	if err != nil {
+		return FailedDeployment{}, false, fmt.Errorf("error getting tasks for deployment %s: %s", b.deploymentName(instanceID), err)
+	}
+	if len(tasks) == 0 {
+		return FailedDeployment{}, false, nil
+	}
+
+	latest := tasks[0]
+	for _, task := range tasks {
+		if task.ID > latest.ID {
+			latest = task
+		}
+	}
+	if latest.StateType() != boshdirector.TaskFailed {
+		return FailedDeployment{}, false, nil
+	}
+
+	return FailedDeployment{
+		InstanceID:  instanceID,
+		BoshTaskID:  latest.ID,
+		State:       latest.State,
+		Description: latest.Description,
+	}, true, nil
+}
+
+func (b *Broker) redeployPlanMismatch(ctx context.Context, mismatch PlanMismatch, logger *log.Logger) Repair {
+	repair := Repair{Action: RepairRedeploy, Target: mismatch.InstanceID}
+
+	logger.Printf("redeploying instance %s with plan %s, it was last deployed with plan %s\n", mismatch.InstanceID, mismatch.PlanID, mismatch.DeployedPlanID)
+	operationData, err := b.Upgrade(ctx, mismatch.InstanceID, brokerapi.UpdateDetails{
+		PlanID:         mismatch.PlanID,
+		PreviousValues: brokerapi.PreviousValues{PlanID: mismatch.DeployedPlanID},
+	}, logger)
+	if err != nil {
+		repair.Error = err.Error()
+		return repair
+	}
+
+	repair.BoshTaskID = operationData.BoshTaskID
+	return repair
+}
+
+// deleteConfirmedOrphan deletes an orphan deployment the operator has named,
+// as the orphan deployment cleanup does.
+func (b *Broker) deleteConfirmedOrphan(ctx context.Context, name string, runPreDeleteErrands bool, logger *log.Logger) Repair {
+	repair := Repair{Action: RepairDeleteOrphan, Target: name}
+
+	operationData, err := b.DeleteOrphanDeployment(ctx, name, runPreDeleteErrands, logger)
+	if err != nil {
+		repair.Error = err.Error()
+		return repair
+	}
+
+	repair.BoshTaskID = operationData.BoshTaskID
+	return repair
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
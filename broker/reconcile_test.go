@@ -0,0 +1,184 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+var _ = Describe("Reconcile", func() {
+	var logger *log.Logger
+
+	BeforeEach(func() {
+		logger = loggerFactory.NewWithRequestID()
+		fakeInstanceLister.InstancesReturns([]service.Instance{
+			{GUID: "healthy", PlanUniqueID: existingPlanID},
+			{GUID: "changed-plan", PlanUniqueID: secondPlanID},
+			{GUID: "failed", PlanUniqueID: existingPlanID},
+			{GUID: "missing", PlanUniqueID: existingPlanID},
+		}, nil)
+		boshClient.GetDeploymentsReturns([]boshdirector.Deployment{
+			{Name: broker.InstancePrefix + "healthy"},
+			{Name: broker.InstancePrefix + "changed-plan"},
+			{Name: broker.InstancePrefix + "failed"},
+			{Name: broker.InstancePrefix + "orphan"},
+			{Name: "not-a-service-instance"},
+		}, nil)
+		boshClient.GetTasksStub = func(deployment string, _ *log.Logger) (boshdirector.BoshTasks, error) {
+			if deployment == broker.InstancePrefix+"failed" {
+				return boshdirector.BoshTasks{
+					{ID: 12, State: boshdirector.TaskError, Description: "create deployment"},
+					{ID: 11, State: boshdirector.TaskDone},
+				}, nil
+			}
+			return boshdirector.BoshTasks{{ID: 10, State: boshdirector.TaskDone}}, nil
+		}
+		fakeOperationJournal.OperationsStub = func(instanceID string) ([]operationjournal.Operation, error) {
+			if instanceID == "changed-plan" {
+				return []operationjournal.Operation{{ID: "1", InstanceID: instanceID, PlanID: existingPlanID}}, nil
+			}
+			return nil, nil
+		}
+		b = createDefaultBroker()
+	})
+
+	It("reports the drift between the instances and their deployments", func() {
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(broker.DriftReport{
+			OrphanDeployments:  []string{broker.InstancePrefix + "orphan"},
+			MissingDeployments: []string{"missing"},
+			PlanMismatches: []broker.PlanMismatch{
+				{InstanceID: "changed-plan", PlanID: secondPlanID, DeployedPlanID: existingPlanID},
+			},
+			FailedDeployments: []broker.FailedDeployment{
+				{InstanceID: "failed", BoshTaskID: 12, State: boshdirector.TaskError, Description: "create deployment"},
+			},
+		}))
+		Expect(report.HasDrift()).To(BeTrue())
+		Expect(fakeDeployer.UpgradeCallCount()).To(BeZero())
+		Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+	})
+
+	It("reports no drift when the instances and deployments agree", func() {
+		fakeInstanceLister.InstancesReturns([]service.Instance{{GUID: "healthy", PlanUniqueID: existingPlanID}}, nil)
+		boshClient.GetDeploymentsReturns([]boshdirector.Deployment{{Name: broker.InstancePrefix + "healthy"}}, nil)
+
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.HasDrift()).To(BeFalse())
+	})
+
+	It("redeploys instances with the plan they have on the platform", func() {
+		fakeDeployer.UpgradeReturns(43, nil, nil)
+
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{RedeployPlanMismatches: true}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeDeployer.UpgradeCallCount()).To(Equal(1))
+		_, deployment, planID, previousPlanID, _, _ := fakeDeployer.UpgradeArgsForCall(0)
+		Expect(deployment).To(Equal(broker.InstancePrefix + "changed-plan"))
+		Expect(planID).To(Equal(secondPlanID))
+		Expect(*previousPlanID).To(Equal(existingPlanID))
+		Expect(report.Repairs).To(Equal([]broker.Repair{
+			{Action: broker.RepairRedeploy, Target: "changed-plan", BoshTaskID: 43},
+		}))
+	})
+
+	It("deletes the orphan deployments it is asked to", func() {
+		boshClient.DeleteDeploymentReturns(44, nil)
+
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{
+			DeleteOrphans: []string{broker.InstancePrefix + "orphan", broker.InstancePrefix + "healthy"},
+		}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(boshClient.DeleteDeploymentCallCount()).To(Equal(1))
+		deployment, contextID, _, _ := boshClient.DeleteDeploymentArgsForCall(0)
+		Expect(deployment).To(Equal(broker.InstancePrefix + "orphan"))
+		Expect(contextID).To(Equal("delete-orphan"))
+		Expect(report.Repairs).To(Equal([]broker.Repair{
+			{Action: broker.RepairDeleteOrphan, Target: broker.InstancePrefix + "orphan", BoshTaskID: 44},
+			{
+				Action: broker.RepairDeleteOrphan,
+				Target: broker.InstancePrefix + "healthy",
+				Error:  "deployment " + broker.InstancePrefix + "healthy is not an orphan deployment",
+			},
+		}))
+
+		Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+		operation := fakeOperationJournal.RecordArgsForCall(0)
+		Expect(operation.InstanceID).To(Equal("orphan"))
+		Expect(operation.Type).To(Equal("delete"))
+	})
+
+	It("reports a repair that cannot be started", func() {
+		boshClient.DeleteDeploymentReturns(0, errors.New("bosh unavailable"))
+
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{DeleteOrphans: []string{broker.InstancePrefix + "orphan"}}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Repairs).To(HaveLen(1))
+		Expect(report.Repairs[0].Target).To(Equal(broker.InstancePrefix + "orphan"))
+		Expect(report.Repairs[0].BoshTaskID).To(BeZero())
+		Expect(report.Repairs[0].Error).To(ContainSubstring("service-instance-guid: orphan"))
+		Expect(logBuffer.String()).To(ContainSubstring("bosh unavailable"))
+	})
+
+	It("runs the pre-delete errands of the orphan deployments it deletes when asked to", func() {
+		fakeOperationJournal.OperationsStub = func(instanceID string) ([]operationjournal.Operation, error) {
+			return []operationjournal.Operation{
+				{ID: "1", InstanceID: instanceID, Type: "create", PlanID: preDeleteErrandPlanID, State: string(brokerapi.Succeeded)},
+			}, nil
+		}
+		boshClient.RunErrandReturns(45, nil)
+
+		report, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{
+			DeleteOrphans:       []string{broker.InstancePrefix + "orphan"},
+			RunPreDeleteErrands: true,
+		}, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(boshClient.RunErrandCallCount()).To(Equal(1))
+		deployment, errandName, _, _, _, _ := boshClient.RunErrandArgsForCall(0)
+		Expect(deployment).To(Equal(broker.InstancePrefix + "orphan"))
+		Expect(errandName).To(Equal("cleanup-resources"))
+		Expect(boshClient.DeleteDeploymentCallCount()).To(BeZero())
+		Expect(report.Repairs).To(Equal([]broker.Repair{
+			{Action: broker.RepairDeleteOrphan, Target: broker.InstancePrefix + "orphan", BoshTaskID: 45},
+		}))
+	})
+
+	It("fails when the instances cannot be listed", func() {
+		fakeInstanceLister.InstancesReturns(nil, errors.New("instances unavailable"))
+
+		_, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{}, logger)
+
+		Expect(err).To(MatchError("instances unavailable"))
+	})
+
+	It("fails when the tasks of a deployment cannot be listed", func() {
+		boshClient.GetTasksStub = nil
+		boshClient.GetTasksReturns(nil, errors.New("bosh unavailable"))
+
+		_, err := b.Reconcile(context.Background(), broker.ReconcileRepairs{}, logger)
+
+		Expect(err).To(MatchError(ContainSubstring("bosh unavailable")))
+	})
+})
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 
 	"github.com/pivotal-cf/brokerapi"
@@ -14,8 +15,7 @@ import (
 )
 
 func (b *Broker) Recreate(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, logger *log.Logger) (OperationData, error) {
-	b.deploymentLock.Lock()
-	defer b.deploymentLock.Unlock()
+	defer b.instanceLocks.acquire(instanceID)()
 
 	logger.Printf("recreating instance %s", instanceID)
 
@@ -27,21 +27,28 @@ func (b *Broker) Recreate(ctx context.Context, instanceID string, details broker
 
 	plan, found := b.serviceOffering.FindPlanByID(details.PlanID)
 	if !found {
-		logger.Printf("error: finding plan ID %s", details.PlanID)
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", details.PlanID)
 		return OperationData{}, b.processError(fmt.Errorf("plan %s not found", details.PlanID), logger)
 	}
 
-	if plan.LifecycleErrands != nil {
+	preRecreateErrands := plan.PreRecreateErrands()
+	if plan.LifecycleErrands != nil || len(preRecreateErrands) > 0 {
 		boshContextID = uuid.New()
 	}
 
-	taskID, err := b.deployer.Recreate(deploymentName(instanceID), details.PlanID, boshContextID, logger)
+	var taskID int
+	var err error
+	if len(preRecreateErrands) > 0 {
+		taskID, err = b.startPreOperationErrands(ctx, b.deploymentName(instanceID), preRecreateErrands, boshContextID, logger)
+	} else {
+		taskID, err = b.deployer.Recreate(ctx, b.deploymentName(instanceID), details.PlanID, boshContextID, logger)
+	}
 
 	if err != nil {
-		logger.Printf("error recreating instance %s: %s", instanceID, err)
+		loggerfactory.Errorf(logger, "error recreating instance %s: %s", instanceID, err)
 
 		switch err := err.(type) {
-		case serviceadapter.UnknownFailureError:
+		case serviceadapter.UnknownFailureError, serviceadapter.TimeoutError:
 			return OperationData{}, b.processError(adapterToAPIError(ctx, err), logger)
 		case TaskInProgressError:
 			return OperationData{}, b.processError(NewOperationInProgressError(err), logger)
@@ -50,10 +57,18 @@ func (b *Broker) Recreate(ctx context.Context, instanceID string, details broker
 		}
 	}
 
-	return OperationData{
+	operationData := OperationData{
 		BoshContextID: boshContextID,
 		BoshTaskID:    taskID,
 		OperationType: OperationTypeRecreate,
 		Errands:       plan.PostDeployErrands(),
-	}, nil
+	}
+	if len(preRecreateErrands) > 0 {
+		operationData.PlanID = details.PlanID
+		operationData.Errands = preOperationThenPostDeployErrands(preRecreateErrands, plan.PostDeployErrands())
+		operationData.PreOperationErrandCount = len(preRecreateErrands)
+	}
+	b.recordStartedOperation(ctx, instanceID, details.PlanID, operationData, logger)
+
+	return operationData, nil
 }
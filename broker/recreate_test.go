@@ -42,13 +42,24 @@ var _ = Describe("Recreate", func() {
 		fakeDeployer.RecreateReturns(boshTaskID, nil)
 	})
 
+	It("passes the request context to the deployer", func() {
+		type ctxKey string
+		ctx := context.WithValue(context.Background(), ctxKey("request"), "some-request")
+
+		_, err := b.Recreate(ctx, instanceID, details, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		actualCtx, _, _, _, _ := fakeDeployer.RecreateArgsForCall(0)
+		Expect(actualCtx.Value(ctxKey("request"))).To(Equal("some-request"))
+	})
+
 	It("asks the deployer to perform a recreate", func() {
 		operationData, err := b.Recreate(context.Background(), instanceID, details, logger)
 
 		Expect(err).NotTo(HaveOccurred())
 		Expect(fakeDeployer.RecreateCallCount()).To(Equal(1), "expected the deployer to be called once")
 
-		actualDeploymentName, _, actualBoshContextID, _ := fakeDeployer.RecreateArgsForCall(0)
+		_, actualDeploymentName, _, actualBoshContextID, _ := fakeDeployer.RecreateArgsForCall(0)
 
 		Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
 		Expect(actualBoshContextID).To(BeEmpty())
@@ -68,7 +79,7 @@ var _ = Describe("Recreate", func() {
 
 		operationData, _ = b.Recreate(context.Background(), instanceID, details, logger)
 
-		_, _, contextID, _ := fakeDeployer.RecreateArgsForCall(0)
+		_, _, _, contextID, _ := fakeDeployer.RecreateArgsForCall(0)
 		Expect(contextID).NotTo(BeEmpty())
 		Expect(operationData.BoshContextID).NotTo(BeEmpty())
 		Expect(contextID).To(Equal(operationData.BoshContextID))
@@ -88,6 +99,36 @@ var _ = Describe("Recreate", func() {
 		Expect(logBuffer.String()).To(MatchRegexp(`\[[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\] \d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} recreating instance`))
 	})
 
+	It("when pre-recreate errands are configured, it runs the first errand instead of recreating", func() {
+		serviceCatalog.Plans = append(serviceCatalog.Plans, config.Plan{
+			ID: "pre-recreate-errand-plan",
+			PreOperationErrands: config.PreOperationErrands{
+				PreRecreate: []config.Errand{{Name: "drain"}},
+			},
+		})
+		b = createDefaultBroker()
+		details = brokerapi.UpdateDetails{PlanID: "pre-recreate-errand-plan"}
+		fakeDeployer.RunErrandReturns(boshTaskID, nil)
+
+		operationData, err := b.Recreate(context.Background(), instanceID, details, logger)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeDeployer.RecreateCallCount()).To(BeZero())
+		Expect(fakeDeployer.RunErrandCallCount()).To(Equal(1))
+		_, _, errandName, _, contextID, _ := fakeDeployer.RunErrandArgsForCall(0)
+		Expect(errandName).To(Equal("drain"))
+		Expect(contextID).NotTo(BeEmpty())
+
+		Expect(operationData).To(Equal(broker.OperationData{
+			BoshTaskID:              boshTaskID,
+			BoshContextID:           contextID,
+			OperationType:           broker.OperationTypeRecreate,
+			PlanID:                  "pre-recreate-errand-plan",
+			Errands:                 []config.Errand{{Name: "drain"}},
+			PreOperationErrandCount: 1,
+		}))
+	})
+
 	It("when no update details are provided returns an error", func() {
 		details = brokerapi.UpdateDetails{}
 		_, err := b.Recreate(context.Background(), instanceID, details, logger)
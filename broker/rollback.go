@@ -0,0 +1,182 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+)
+
+const (
+	rollbackInProgressDescription = "Instance upgrade failed, rolling back to the previous deployment"
+	rolledBackDescription         = "rolled back to the previous deployment"
+)
+
+// upgradeRollback rolls back an upgrade whose deployment task failed and
+// reports on the progress of the rollback. The rollback is deployed under the
+// upgrade's BOSH context ID. Post-deploy errands only run once the upgrade
+// has succeeded, so a task started after a failed upgrade is its rollback. It
+// returns false when there is nothing to roll back, leaving LastOperation to
+// report on the operation as usual.
+func (b *Broker) upgradeRollback(ctx context.Context, instanceID string, operationData OperationData, logger *log.Logger,
+) (brokerapi.LastOperation, bool, error) {
+	if operationData.OperationType != OperationTypeUpgrade || !operationData.RollbackOnFailure {
+		return brokerapi.LastOperation{}, false, nil
+	}
+
+	deployment := b.deploymentName(instanceID)
+	tasks, err := b.boshClient.GetNormalisedTasksByContext(deployment, operationData.BoshContextID, logger)
+	if err != nil {
+		return brokerapi.LastOperation{}, false, err
+	}
+	if count := operationData.PreOperationErrandCount; count > 0 && count <= len(operationData.Errands) {
+		consumed, _, _ := errandProgress(tasks, operationData.Errands[:count])
+		tasks = tasks[:len(tasks)-consumed]
+	}
+	if len(tasks) == 0 {
+		return brokerapi.LastOperation{}, false, nil
+	}
+
+	// The tasks are ordered newest first, so the upgrade is the last task
+	// after the pre-upgrade errands.
+	upgradeTask := tasks[len(tasks)-1]
+	switch upgradeTask.StateType() {
+	case boshdirector.TaskIncomplete:
+		return brokerapi.LastOperation{}, false, nil
+	case boshdirector.TaskComplete:
+		return brokerapi.LastOperation{}, false, nil
+	}
+
+	if len(tasks) == 1 {
+		snapshot, err := b.rollbackSnapshot(instanceID, operationData, logger)
+		if err != nil {
+			logger.Printf("not rolling back failed upgrade of instance %s: %s", instanceID, err)
+			return brokerapi.LastOperation{}, false, nil
+		}
+		rollbackTaskID, err := b.deployer.Rollback(ctx, deployment, operationData.BoshContextID, snapshot, logger)
+		if err != nil {
+			logger.Printf("not rolling back failed upgrade of instance %s: %s", instanceID, err)
+			return brokerapi.LastOperation{}, false, nil
+		}
+		logger.Printf("upgrade of instance %s failed in BOSH task ID %d, rolling back in BOSH task ID %d", instanceID, upgradeTask.ID, rollbackTaskID)
+		return brokerapi.LastOperation{State: brokerapi.InProgress, Description: rollbackInProgressDescription}, true, nil
+	}
+
+	rollbackTask := tasks[len(tasks)-2]
+	logLastOperation(instanceID, rollbackTask, operationData, logger)
+
+	var lastOperation brokerapi.LastOperation
+	rolledBack := false
+	switch rollbackTask.StateType() {
+	case boshdirector.TaskIncomplete:
+		return brokerapi.LastOperation{State: brokerapi.InProgress, Description: rollbackInProgressDescription}, true, nil
+	case boshdirector.TaskComplete:
+		rolledBack = true
+		lastOperation = brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: fmt.Sprintf("%s: %d, %s", descriptions[brokerapi.Failed][OperationTypeUpgrade], upgradeTask.ID, rolledBackDescription),
+		}
+	default:
+		lastOperation = brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: fmt.Sprintf("%s: %d, rollback failed for bosh task: %d", descriptions[brokerapi.Failed][OperationTypeUpgrade], upgradeTask.ID, rollbackTask.ID),
+		}
+	}
+
+	if b.ExposeOperationalErrors {
+		lastOperation.Description = fmt.Sprintf("%s, error-message: %s", lastOperation.Description, upgradeTask.Result)
+	}
+
+	operation := b.finishedOperation(instanceID, operationData, rollbackTask, lastOperation, logger)
+	operation.RolledBack = rolledBack
+	b.recordOperation(ctx, operation, logger)
+	b.deleteRollbackSnapshot(instanceID, operationData, logger)
+
+	return lastOperation, true, nil
+}
+
+func rollbackSnapshotKey(serviceID, instanceID, boshContextID string) string {
+	return fmt.Sprintf("/c/%s/%s/%s/rollback", serviceID, instanceID, boshContextID)
+}
+
+// storeRollbackSnapshot stores the snapshot of the deployment an upgrade
+// replaces, under the upgrade's BOSH context ID so that it is stored before
+// the upgrade starts, and returns the reference to record with the upgrade in
+// the operation journal. Like the parameters of instances, snapshots are kept
+// in the parameter store rather than the journal, as manifests and BOSH
+// configs can hold credentials and the journal is a plaintext file.
+func (b *Broker) storeRollbackSnapshot(instanceID, boshContextID string, snapshot DeploymentSnapshot) (string, error) {
+	if b.parameterStore == nil {
+		return "", errors.New("the broker has no credential store to keep the snapshot of the deployment in")
+	}
+
+	configs := map[string]interface{}{}
+	for configType, content := range snapshot.Configs {
+		configs[configType] = content
+	}
+
+	key := rollbackSnapshotKey(b.serviceOffering.ID, instanceID, boshContextID)
+	if err := b.parameterStore.Set(key, map[string]interface{}{
+		"manifest": string(snapshot.Manifest),
+		"configs":  configs,
+	}); err != nil {
+		return "", fmt.Errorf("error storing the snapshot of the deployment: %s", err)
+	}
+	return key, nil
+}
+
+// rollbackSnapshot reads back the snapshot of the deployment an upgrade
+// replaced, which the operation journal records a reference to, so that a
+// failed upgrade can be rolled back after a broker restart.
+func (b *Broker) rollbackSnapshot(instanceID string, operationData OperationData, logger *log.Logger) (DeploymentSnapshot, error) {
+	operation, found := b.journalledOperation(instanceID, JournalOperationID(operationData), logger)
+	if !found || operation.RollbackSnapshotRef == "" || b.parameterStore == nil {
+		return DeploymentSnapshot{}, NewNoRollbackAvailableError(fmt.Errorf("no snapshot of the deployment upgrade %s replaced is recorded", JournalOperationID(operationData)))
+	}
+
+	value, err := b.parameterStore.Get(operation.RollbackSnapshotRef)
+	if err != nil {
+		return DeploymentSnapshot{}, fmt.Errorf("error reading the snapshot of the deployment: %s", err)
+	}
+
+	stored, _ := value.(map[string]interface{})
+	manifest, _ := stored["manifest"].(string)
+	if manifest == "" {
+		return DeploymentSnapshot{}, NewNoRollbackAvailableError(fmt.Errorf("the snapshot of the deployment upgrade %s replaced holds no manifest", JournalOperationID(operationData)))
+	}
+
+	snapshot := DeploymentSnapshot{Manifest: []byte(manifest)}
+	if configs, ok := stored["configs"].(map[string]interface{}); ok {
+		snapshot.Configs = map[string]string{}
+		for configType, content := range configs {
+			snapshot.Configs[configType], _ = content.(string)
+		}
+	}
+	return snapshot, nil
+}
+
+// deleteRollbackSnapshot deletes the snapshot stored for an upgrade once the
+// upgrade has finished, whether or not it was rolled back.
+func (b *Broker) deleteRollbackSnapshot(instanceID string, operationData OperationData, logger *log.Logger) {
+	if operationData.OperationType != OperationTypeUpgrade || !operationData.RollbackOnFailure || b.parameterStore == nil {
+		return
+	}
+
+	operation, found := b.journalledOperation(instanceID, JournalOperationID(operationData), logger)
+	if !found || operation.RollbackSnapshotRef == "" {
+		return
+	}
+	if err := b.parameterStore.Delete(operation.RollbackSnapshotRef); err != nil {
+		loggerfactory.Errorf(logger, "WARNING: failed to remove the snapshot of the deployment upgrade %s of instance %s replaced: %s\n", operation.ID, instanceID, err)
+	}
+}
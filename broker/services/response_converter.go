@@ -15,6 +15,7 @@ import (
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 )
 
 type BOSHOperation struct {
@@ -33,6 +34,8 @@ const (
 	OperationInProgress BOSHOperationType = "busy"
 	OperationPending    BOSHOperationType = "not-started"
 	OperationSucceeded  BOSHOperationType = "succeeded"
+
+	OutsideMaintenanceWindow BOSHOperationType = "outside-maintenance-window"
 )
 
 type ResponseConverter struct{}
@@ -93,6 +96,36 @@ func (r ResponseConverter) OrphanDeploymentsFrom(response *http.Response) ([]mgm
 	return orphans, nil
 }
 
+func (r ResponseConverter) DriftReportFrom(response *http.Response) (broker.DriftReport, error) {
+	var report broker.DriftReport
+	err := decodeBodyInto(response, &report)
+	if err != nil {
+		return broker.DriftReport{}, err
+	}
+
+	return report, nil
+}
+
+func (r ResponseConverter) DeployedReleasesFrom(response *http.Response) (map[string][]mgmtapi.Release, error) {
+	var releases map[string][]mgmtapi.Release
+	err := decodeBodyInto(response, &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+func (r ResponseConverter) OperationsFrom(response *http.Response) ([]operationjournal.Operation, error) {
+	var operations []operationjournal.Operation
+	err := decodeBodyInto(response, &operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
 func decodeBodyInto(response *http.Response, contents interface{}) error {
 	defer response.Body.Close()
 
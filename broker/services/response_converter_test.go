@@ -19,6 +19,7 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 )
 
 var _ = Describe("Response Converter", func() {
@@ -230,6 +231,68 @@ var _ = Describe("Response Converter", func() {
 			))
 		})
 	})
+
+	Context("deployed releases", func() {
+		It("returns the deployed releases by instance", func() {
+			response := http.Response{
+				StatusCode: http.StatusOK,
+				Body:       asBody(`{"one":[{"name":"redis","version":"1.2.3"},{"name":"syslog","version":"11"}],"two":[]}`),
+			}
+
+			releases, err := converter.DeployedReleasesFrom(&response)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releases).To(Equal(map[string][]mgmtapi.Release{
+				"one": {{Name: "redis", Version: "1.2.3"}, {Name: "syslog", Version: "11"}},
+				"two": {},
+			}))
+		})
+
+		It("returns an error when the response status is not OK", func() {
+			response := http.Response{
+				Status:     "500 Internal Server Error",
+				StatusCode: 500,
+				Body:       asBody(""),
+			}
+
+			_, err := converter.DeployedReleasesFrom(&response)
+
+			Expect(err).To(MatchError(
+				ContainSubstring("HTTP response status: 500 Internal Server Error"),
+			))
+		})
+	})
+
+	Describe("OperationsFrom", func() {
+		It("returns the operations", func() {
+			response := http.Response{
+				StatusCode: http.StatusOK,
+				Body:       asBody(`[{"id":"42","operation_type":"upgrade","rolled_back":true},{"id":"41","operation_type":"update"}]`),
+			}
+
+			operations, err := converter.OperationsFrom(&response)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operations).To(Equal([]operationjournal.Operation{
+				{ID: "42", Type: "upgrade", RolledBack: true},
+				{ID: "41", Type: "update"},
+			}))
+		})
+
+		It("returns an error when the operation journal is disabled", func() {
+			response := http.Response{
+				Status:     "501 Not Implemented",
+				StatusCode: http.StatusNotImplemented,
+				Body:       asBody(`{"description":"the operation journal is disabled"}`),
+			}
+
+			_, err := converter.OperationsFrom(&response)
+
+			Expect(err).To(MatchError(
+				ContainSubstring("HTTP response status: 501 Not Implemented"),
+			))
+		})
+	})
 })
 
 func upgradeOperationJSON() string {
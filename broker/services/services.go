@@ -7,6 +7,7 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +20,7 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/authorizationheader"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 )
 
@@ -84,6 +86,53 @@ func (b *BrokerServices) OrphanDeployments() ([]mgmtapi.Deployment, error) {
 	return b.converter.OrphanDeploymentsFrom(response)
 }
 
+func (b *BrokerServices) DeleteOrphanDeployment(deploymentName string, runPreDeleteErrands bool) (BOSHOperation, error) {
+	path := fmt.Sprintf("/mgmt/orphan_deployments/%s", deploymentName)
+	if runPreDeleteErrands {
+		path = appendQuery(path, map[string]string{"run_pre_delete_errands": "true"})
+	}
+
+	response, err := b.doRequest(http.MethodDelete, path, nil)
+	if err != nil {
+		return BOSHOperation{}, err
+	}
+	return b.converter.ExtractOperationFrom(response)
+}
+
+func (b *BrokerServices) Reconcile(repairs broker.ReconcileRepairs) (broker.DriftReport, error) {
+	body, err := json.Marshal(repairs)
+	if err != nil {
+		return broker.DriftReport{}, err
+	}
+
+	response, err := b.doRequest(http.MethodPost, "/mgmt/reconcile", bytes.NewReader(body))
+	if err != nil {
+		return broker.DriftReport{}, err
+	}
+
+	return b.converter.DriftReportFrom(response)
+}
+
+// DeployedReleases returns the releases each instance's deployment uses, by
+// instance ID.
+func (b *BrokerServices) DeployedReleases() (map[string][]mgmtapi.Release, error) {
+	response, err := b.doRequest(http.MethodGet, "/mgmt/deployed_releases", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.converter.DeployedReleasesFrom(response)
+}
+
+func (b *BrokerServices) Operations(instanceGUID string) ([]operationjournal.Operation, error) {
+	response, err := b.doRequest(http.MethodGet, fmt.Sprintf("/mgmt/service_instances/%s/operations", instanceGUID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.converter.OperationsFrom(response)
+}
+
 func (b *BrokerServices) doRequest(method, path string, body io.Reader) (*http.Response, error) {
 	request, err := http.NewRequest(method, b.buildURL(path), body)
 	if err != nil {
@@ -24,6 +24,7 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 
 	fakeclients "github.com/pivotal-cf/on-demand-service-broker/broker/services/fakes"
@@ -248,6 +249,146 @@ var _ = Describe("Broker Services", func() {
 			})
 		})
 	})
+
+	Describe("DeleteOrphanDeployment", func() {
+		BeforeEach(func() {
+			brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+		})
+
+		It("deletes the deployment and returns the operation", func() {
+			client.DoReturns(response(http.StatusAccepted, `{"BoshTaskID": 42, "OperationType": "delete"}`), nil)
+
+			operation, err := brokerServices.DeleteOrphanDeployment("service-instance_orphan", false)
+
+			Expect(err).NotTo(HaveOccurred())
+			request := client.DoArgsForCall(0)
+			Expect(request.Method).To(Equal(http.MethodDelete))
+			Expect(request.URL.Path).To(Equal("/mgmt/orphan_deployments/service-instance_orphan"))
+			Expect(request.URL.RawQuery).To(BeEmpty())
+			Expect(operation).To(Equal(services.BOSHOperation{
+				Type: services.OperationAccepted,
+				Data: broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeDelete},
+			}))
+		})
+
+		It("asks for the pre-delete errands to be run", func() {
+			client.DoReturns(response(http.StatusAccepted, `{"BoshTaskID": 42, "OperationType": "delete"}`), nil)
+
+			_, err := brokerServices.DeleteOrphanDeployment("service-instance_orphan", true)
+
+			Expect(err).NotTo(HaveOccurred())
+			request := client.DoArgsForCall(0)
+			Expect(request.URL.Query().Get("run_pre_delete_errands")).To(Equal("true"))
+		})
+
+		It("reports an operation in progress on the deployment", func() {
+			client.DoReturns(response(http.StatusConflict, ""), nil)
+
+			operation, err := brokerServices.DeleteOrphanDeployment("service-instance_orphan", false)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operation.Type).To(Equal(services.OperationInProgress))
+		})
+
+		It("returns an error when the deployment is not an orphan", func() {
+			client.DoReturns(response(http.StatusUnprocessableEntity, `{"description": "not an orphan"}`), nil)
+
+			_, err := brokerServices.DeleteOrphanDeployment("service-instance_orphan", false)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Reconcile", func() {
+		It("posts the repairs and returns the drift report", func() {
+			brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+			client.DoReturns(response(http.StatusOK, `{
+				"orphan_deployments": ["service-instance_one"],
+				"missing_deployments": [],
+				"plan_mismatches": [],
+				"failed_deployments": [],
+				"repairs": [{"action": "delete-orphan", "target": "service-instance_one", "bosh_task_id": 42}]
+			}`), nil)
+
+			report, err := brokerServices.Reconcile(broker.ReconcileRepairs{DeleteOrphans: []string{"service-instance_one"}})
+
+			Expect(err).NotTo(HaveOccurred())
+			request := client.DoArgsForCall(0)
+			Expect(request.Method).To(Equal(http.MethodPost))
+			Expect(request.URL.Path).To(Equal("/mgmt/reconcile"))
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(MatchJSON(`{"redeploy_plan_mismatches": false, "delete_orphans": ["service-instance_one"], "run_pre_delete_errands": false}`))
+
+			Expect(report.OrphanDeployments).To(Equal([]string{"service-instance_one"}))
+			Expect(report.Repairs).To(Equal([]broker.Repair{
+				{Action: broker.RepairDeleteOrphan, Target: "service-instance_one", BoshTaskID: 42},
+			}))
+		})
+
+		It("returns an error when the broker responds with an error", func() {
+			brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+			client.DoReturns(response(http.StatusInternalServerError, ""), nil)
+
+			_, err := brokerServices.Reconcile(broker.ReconcileRepairs{})
+
+			Expect(err).To(MatchError(ContainSubstring("HTTP response status")))
+		})
+	})
+
+	Describe("DeployedReleases", func() {
+		It("returns the releases deployed for every instance", func() {
+			brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+			client.DoReturns(response(http.StatusOK, `{"some-instance":[{"name":"redis","version":"1.2.3"}]}`), nil)
+
+			releases, err := brokerServices.DeployedReleases()
+
+			Expect(err).NotTo(HaveOccurred())
+			request := client.DoArgsForCall(0)
+			Expect(request.Method).To(Equal(http.MethodGet))
+			Expect(request.URL.Path).To(Equal("/mgmt/deployed_releases"))
+			Expect(releases).To(Equal(map[string][]mgmtapi.Release{
+				"some-instance": {{Name: "redis", Version: "1.2.3"}},
+			}))
+		})
+
+		Context("when the request fails", func() {
+			It("returns an error", func() {
+				brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+				client.DoReturns(nil, errors.New("connection error"))
+
+				_, err := brokerServices.DeployedReleases()
+
+				Expect(err).To(MatchError("connection error"))
+			})
+		})
+	})
+
+	Describe("Operations", func() {
+		It("returns the journalled operations of the instance", func() {
+			brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+			client.DoReturns(response(http.StatusOK, `[{"id":"42","rolled_back":true}]`), nil)
+
+			operations, err := brokerServices.Operations("some-instance")
+
+			Expect(err).NotTo(HaveOccurred())
+			request := client.DoArgsForCall(0)
+			Expect(request.Method).To(Equal(http.MethodGet))
+			Expect(request.URL.Path).To(Equal("/mgmt/service_instances/some-instance/operations"))
+			Expect(operations).To(ConsistOf(operationjournal.Operation{ID: "42", RolledBack: true}))
+		})
+
+		Context("when the request fails", func() {
+			It("returns an error", func() {
+				brokerServices = services.NewBrokerServices(client, authHeaderBuilder, "http://test.test", logger)
+				client.DoReturns(nil, errors.New("connection error"))
+
+				_, err := brokerServices.Operations("some-instance")
+
+				Expect(err).To(MatchError("connection error"))
+			})
+		})
+	})
 })
 
 func response(statusCode int, body string) *http.Response {
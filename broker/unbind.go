@@ -8,11 +8,14 @@ package broker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 )
 
 func (b *Broker) Unbind(
@@ -30,6 +33,7 @@ func (b *Broker) Unbind(
 	}
 
 	ctx = brokercontext.New(ctx, string(OperationTypeUnbind), requestID, b.serviceOffering.Name, instanceID)
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
 	logger := b.loggerFactory.NewWithContext(ctx)
 
 	manifest, vms, deploymentErr := b.getDeploymentInfo(instanceID, ctx, "unbind", logger)
@@ -42,14 +46,14 @@ func (b *Broker) Unbind(
 		"service_id": details.ServiceID,
 	}
 
-	deploymentVariables, err := b.boshClient.Variables(deploymentName(instanceID), logger)
+	deploymentVariables, err := b.boshClient.Variables(b.deploymentName(instanceID), logger)
 	if err != nil {
-		logger.Printf("failed to retrieve deployment variables for deployment '%s': %s", deploymentName(instanceID), err)
+		loggerfactory.Errorf(logger, "failed to retrieve deployment variables for deployment '%s': %s", b.deploymentName(instanceID), err)
 	}
 
 	secretsMap, err := b.secretManager.ResolveManifestSecrets(manifest, deploymentVariables, logger)
 	if err != nil {
-		logger.Printf("failed to resolve manifest secrets: %s", err.Error())
+		loggerfactory.Errorf(logger, "failed to resolve manifest secrets: %s", err.Error())
 	}
 
 	plan, found := b.serviceOffering.FindPlanByID(details.PlanID)
@@ -60,21 +64,66 @@ func (b *Broker) Unbind(
 		), logger)
 	}
 
-	dnsAddresses, err := b.boshClient.GetDNSAddresses(deploymentName(instanceID), plan.BindingWithDNS)
+	dnsAddresses, err := b.boshClient.GetDNSAddresses(b.deploymentName(instanceID), plan.BindingWithDNS)
 	if err != nil {
 		return emptyUnbindSpec, b.processError(NewGenericError(ctx, fmt.Errorf("failed to get required DNS info: %s", err)), logger)
 	}
 
 	logger.Printf("service adapter will delete binding with ID %s for instance %s\n", bindingID, instanceID)
-	err = b.adapterClient.DeleteBinding(bindingID, vms, manifest, requestParams, secretsMap, dnsAddresses, logger)
+
+	if asyncAllowed && b.EnableAsyncBindings {
+		if !b.bindingOperations.start(instanceID, bindingID, OperationTypeUnbind) {
+			return emptyUnbindSpec, b.processError(brokerapi.ErrConcurrentInstanceAccess.Build(), logger)
+		}
+
+		operationData, err := json.Marshal(OperationData{OperationType: OperationTypeUnbind, StartedAt: time.Now().Unix()})
+		if err != nil {
+			b.bindingOperations.forget(instanceID, bindingID)
+			return emptyUnbindSpec, b.processError(NewGenericError(ctx, err), logger)
+		}
+
+		b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeUnbind, brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: descriptions[brokerapi.InProgress][OperationTypeUnbind],
+		}, logger)
+
+		// The operation outlives the request, so it is only stopped by the
+		// adapter command's timeout.
+		go func() {
+			err := b.adapterClient.DeleteBinding(context.Background(), bindingID, vms, manifest, requestParams, secretsMap, dnsAddresses, logger)
+			if err != nil {
+				logger.Printf("delete binding: %v\n", err)
+			}
+			if err == nil && b.bindingStore != nil {
+				if storeErr := b.bindingStore.DeleteBinding(ctx, instanceID, bindingID, details.ServiceID); storeErr != nil {
+					logger.Printf("WARNING: failed to remove binding %s from the binding store: %v\n", bindingID, storeErr)
+				}
+			}
+			b.bindingOperations.finish(instanceID, bindingID, brokerapi.Binding{}, err)
+			b.recordFinishedBindingOperation(ctx, instanceID, bindingID, OperationTypeUnbind, err, logger)
+		}()
+
+		return brokerapi.UnbindSpec{IsAsync: true, OperationData: string(operationData)}, nil
+	}
+
+	err = b.adapterClient.DeleteBinding(ctx, bindingID, vms, manifest, requestParams, secretsMap, dnsAddresses, logger)
 
 	if err != nil {
 		logger.Printf("delete binding: %v\n", err)
 	}
 
 	if err := adapterToAPIError(ctx, err); err != nil {
+		b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeUnbind, brokerapi.LastOperation{
+			State:       brokerapi.Failed,
+			Description: fmt.Sprintf("%s: %s", descriptions[brokerapi.Failed][OperationTypeUnbind], err),
+		}, logger)
 		return emptyUnbindSpec, b.processError(err, logger)
 	}
 
+	b.bindingOperations.forget(instanceID, bindingID)
+	b.recordBindingOperation(ctx, instanceID, bindingID, OperationTypeUnbind, brokerapi.LastOperation{
+		State:       brokerapi.Succeeded,
+		Description: descriptions[brokerapi.Succeeded][OperationTypeUnbind],
+	}, logger)
 	return emptyUnbindSpec, nil
 }
@@ -65,7 +65,7 @@ var _ = Describe("Unbind", func() {
 		Expect(actualDeploymentName).To(Equal(deploymentName))
 
 		Expect(serviceAdapter.DeleteBindingCallCount()).To(Equal(1))
-		passedBindingID, passedVms, passedManifest, passedRequestParams, passedSecretsMap, dnsAddresses, _ := serviceAdapter.DeleteBindingArgsForCall(0)
+		_, passedBindingID, passedVms, passedManifest, passedRequestParams, passedSecretsMap, dnsAddresses, _ := serviceAdapter.DeleteBindingArgsForCall(0)
 		Expect(passedBindingID).To(Equal(bindingID))
 		Expect(passedVms).To(Equal(boshVms))
 		Expect(passedManifest).To(Equal(actualManifest))
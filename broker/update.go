@@ -20,6 +20,7 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 )
 
@@ -29,11 +30,11 @@ func (b *Broker) Update(
 	details brokerapi.UpdateDetails,
 	asyncAllowed bool,
 ) (brokerapi.UpdateServiceSpec, error) {
-	b.deploymentLock.Lock()
-	defer b.deploymentLock.Unlock()
+	defer b.instanceLocks.acquire(instanceID)()
 
 	requestID := uuid.New()
 	ctx = brokercontext.New(ctx, string(OperationTypeUpdate), requestID, b.serviceOffering.Name, instanceID)
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
 	logger := b.loggerFactory.NewWithContext(ctx)
 
 	if !asyncAllowed {
@@ -52,6 +53,7 @@ func (b *Broker) Update(
 
 	var boshTaskID int
 	var operationType OperationType
+	var updateStarted bool
 
 	err = b.validateMaintenanceInfo(details, ctx)
 	if err != nil {
@@ -69,19 +71,27 @@ func (b *Broker) Update(
 
 		operationType = OperationTypeUpgrade
 		boshTaskID, _, err = b.deployer.Upgrade(
-			deploymentName(instanceID),
+			ctx,
+			b.deploymentName(instanceID),
 			details.PlanID,
 			&details.PreviousValues.PlanID,
 			boshContextID,
 			logger,
 		)
 	} else {
-		err = b.validateQuotasForUpdate(plan, details, logger, ctx)
+		err = b.validateQuotasForUpdate(instanceID, plan, details, requestPlatform(detailsMap), logger, ctx)
 		if err != nil {
 			return brokerapi.UpdateServiceSpec{}, b.processError(err, logger)
 		}
+		// The reservation is kept once the update has started, until the
+		// platform reports the new plan.
+		defer func() {
+			if !updateStarted && details.PreviousValues.PlanID != plan.ID {
+				b.releaseQuota(instanceID)
+			}
+		}()
 
-		err = b.validatePlanSchemas(plan, details, logger)
+		err = b.validatePlanSchemas(ctx, plan, details, logger)
 		if err != nil {
 			return brokerapi.UpdateServiceSpec{}, b.processError(err, logger)
 		}
@@ -96,7 +106,8 @@ func (b *Broker) Update(
 
 		operationType = OperationTypeUpdate
 		boshTaskID, _, err = b.deployer.Update(
-			deploymentName(instanceID),
+			ctx,
+			b.deploymentName(instanceID),
 			details.PlanID,
 			detailsMap,
 			&details.PreviousValues.PlanID,
@@ -110,17 +121,26 @@ func (b *Broker) Update(
 		return b.handleUpdateError(err, logger, ctx)
 	}
 
-	operationData, err := json.Marshal(OperationData{
+	operationData := OperationData{
 		BoshTaskID:    boshTaskID,
 		OperationType: operationType,
 		BoshContextID: boshContextID,
 		Errands:       plan.PostDeployErrands(),
-	})
+	}
+	if operationType == OperationTypeUpdate {
+		// LastOperation registers the new plan once the update has succeeded.
+		operationData.PlanID = details.PlanID
+	}
+	operationDataJSON, err := json.Marshal(operationData)
 	if err != nil {
 		return brokerapi.UpdateServiceSpec{}, b.processError(NewGenericError(brokercontext.WithBoshTaskID(ctx, boshTaskID), err), logger)
 	}
 
-	return brokerapi.UpdateServiceSpec{IsAsync: true, OperationData: string(operationData)}, nil
+	updateStarted = true
+	parameters, _ := detailsMap["parameters"].(map[string]interface{})
+	b.recordStartedDeployment(ctx, instanceID, details.PlanID, parameters, operationData, logger)
+
+	return brokerapi.UpdateServiceSpec{IsAsync: true, OperationData: string(operationDataJSON)}, nil
 }
 
 func (b *Broker) handleUpdateError(err error, logger *log.Logger, ctx context.Context) (brokerapi.UpdateServiceSpec, error) {
@@ -128,8 +148,15 @@ func (b *Broker) handleUpdateError(err error, logger *log.Logger, ctx context.Co
 	case ServiceError:
 		return brokerapi.UpdateServiceSpec{}, b.processError(NewBoshRequestError("update", fmt.Errorf("error deploying instance: %s", err)), logger)
 	case PendingChangesNotAppliedError:
+		message := PendingChangesErrorMessage
+		if len(err.Changes.Changes) > 0 {
+			loggerfactory.Errorf(logger, "%s: %s", err, err.Changes)
+			if b.ExposeOperationalErrors {
+				message = fmt.Sprintf("%s Pending changes: %s", PendingChangesErrorMessage, err.Changes)
+			}
+		}
 		return brokerapi.UpdateServiceSpec{}, b.processError(brokerapi.NewFailureResponse(
-			errors.New(PendingChangesErrorMessage),
+			errors.New(message),
 			http.StatusUnprocessableEntity,
 			UpdateLoggerAction,
 		), logger)
@@ -137,7 +164,7 @@ func (b *Broker) handleUpdateError(err error, logger *log.Logger, ctx context.Co
 		return brokerapi.UpdateServiceSpec{}, b.processError(errors.New(OperationInProgressMessage), logger)
 	case PlanNotFoundError:
 		return brokerapi.UpdateServiceSpec{}, b.processError(err, logger)
-	case serviceadapter.UnknownFailureError:
+	case serviceadapter.UnknownFailureError, serviceadapter.TimeoutError:
 		return brokerapi.UpdateServiceSpec{}, b.processError(adapterToAPIError(ctx, err), logger)
 	case error:
 		return brokerapi.UpdateServiceSpec{}, b.processError(NewGenericError(ctx, fmt.Errorf("error deploying instance: %s", err)), logger)
@@ -195,27 +222,19 @@ func (b *Broker) validateMaintenanceInfo(details brokerapi.UpdateDetails, ctx co
 	return nil
 }
 
-func (b *Broker) validateQuotasForUpdate(plan config.Plan, details brokerapi.UpdateDetails, logger *log.Logger, ctx context.Context) error {
+func (b *Broker) validateQuotasForUpdate(instanceID string, plan config.Plan, details brokerapi.UpdateDetails, platform string, logger *log.Logger, ctx context.Context) error {
 	if details.PreviousValues.PlanID != plan.ID {
-		cfPlanCounts, err := b.cfClient.CountInstancesOfServiceOffering(b.serviceOffering.ID, logger)
-		if err != nil {
-			return NewGenericError(ctx, err)
-		}
-
-		quotasErrors, ok := b.checkQuotas(ctx, plan, cfPlanCounts, b.serviceOffering.ID, logger)
-		if !ok {
-			return quotasErrors
-		}
+		return b.reserveQuota(ctx, instanceID, plan, details.PreviousValues.PlanID, platform, logger)
 	}
 
 	return nil
 }
 
-func (b *Broker) validatePlanSchemas(plan config.Plan, details brokerapi.UpdateDetails, logger *log.Logger) error {
+func (b *Broker) validatePlanSchemas(ctx context.Context, plan config.Plan, details brokerapi.UpdateDetails, logger *log.Logger) error {
 
 	if b.EnablePlanSchemas {
 		var schemas brokerapi.ServiceSchemas
-		schemas, err := b.adapterClient.GeneratePlanSchema(plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+		schemas, err := b.planSchemas(ctx, plan, logger)
 		if err != nil {
 			if _, ok := err.(serviceadapter.NotImplementedError); !ok {
 				return err
@@ -248,12 +267,12 @@ func (b *Broker) validatePlanSchemas(plan config.Plan, details brokerapi.UpdateD
 }
 
 func (b *Broker) getSecretMap(instanceID string, logger *log.Logger) (map[string]string, error) {
-	manifest, _, err := b.boshClient.GetDeployment(deploymentName(instanceID), logger)
+	manifest, _, err := b.boshClient.GetDeployment(b.deploymentName(instanceID), logger)
 	if err != nil {
 		return nil, err
 	}
 
-	deploymentVariables, err := b.boshClient.Variables(deploymentName(instanceID), logger)
+	deploymentVariables, err := b.boshClient.Variables(b.deploymentName(instanceID), logger)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +97,7 @@ var _ = Describe("Update", func() {
 
 		It("invokes the deployer with the correct arguments", func() {
 			Expect(fakeDeployer.UpdateCallCount()).To(Equal(1))
-			_, planID, actualRequestParams, _, _, actualSecretsMap, _ := fakeDeployer.UpdateArgsForCall(0)
+			_, _, planID, actualRequestParams, _, _, actualSecretsMap, _ := fakeDeployer.UpdateArgsForCall(0)
 
 			Expect(actualRequestParams).To(Equal(map[string]interface{}{
 				"plan_id":    planID,
@@ -124,7 +124,7 @@ var _ = Describe("Update", func() {
 
 				It("calls the deployer without a bosh context id", func() {
 					Expect(fakeDeployer.UpdateCallCount()).To(Equal(1))
-					_, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
+					_, _, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
 					Expect(actualBoshContextID).To(BeEmpty())
 				})
 
@@ -134,7 +134,7 @@ var _ = Describe("Update", func() {
 
 				It("returns the bosh task ID and operation type", func() {
 					data := unmarshalOperationData(updateSpec)
-					Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate}))
+					Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate, PlanID: newPlanID}))
 				})
 
 				It("logs with a request ID", func() {
@@ -154,7 +154,7 @@ var _ = Describe("Update", func() {
 
 				It("returns the bosh task ID and operation type", func() {
 					data := unmarshalOperationData(updateSpec)
-					Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate}))
+					Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate, PlanID: newPlanID}))
 				})
 			})
 
@@ -180,7 +180,7 @@ var _ = Describe("Update", func() {
 
 				It("calls the deployer with a bosh context id", func() {
 					Expect(fakeDeployer.UpdateCallCount()).To(Equal(1))
-					_, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
+					_, _, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
 					Expect(actualBoshContextID).NotTo(BeEmpty())
 				})
 			})
@@ -213,6 +213,35 @@ var _ = Describe("Update", func() {
 					)
 					Expect(updateError).To(Equal(expectedFailureResponse))
 				})
+
+				Context("and the changes are known", func() {
+					BeforeEach(func() {
+						fakeDeployer.UpdateReturns(boshTaskID, nil, broker.NewPendingChangesNotAppliedError(
+							errors.New("There are pending changes"),
+							broker.PendingChanges{Changes: []broker.PendingChange{
+								{Path: "releases/redis/version", Change: broker.PendingChangeChanged},
+							}},
+						))
+					})
+
+					It("logs the pending changes without showing them to the user", func() {
+						Expect(updateError).To(MatchError(broker.PendingChangesErrorMessage))
+						Expect(logBuffer.String()).To(ContainSubstring("There are pending changes: releases/redis/version changed"))
+					})
+
+					Context("and the broker is configured to expose operational errors", func() {
+						BeforeEach(func() {
+							brokerConfig.ExposeOperationalErrors = true
+							b = createDefaultBroker()
+						})
+
+						It("shows the pending changes to the user", func() {
+							Expect(updateError).To(MatchError(ContainSubstring(broker.PendingChangesErrorMessage)))
+							Expect(updateError).To(MatchError(broker.PendingChangesErrorMessage + " Pending changes: releases/redis/version changed"))
+							Expect(updateError.(*brokerapi.FailureResponse).ValidatedStatusCode(nil)).To(Equal(http.StatusUnprocessableEntity))
+						})
+					})
+				})
 			})
 		})
 
@@ -240,7 +269,7 @@ var _ = Describe("Update", func() {
 
 					It("returns the bosh task ID and operation type", func() {
 						data := unmarshalOperationData(updateSpec)
-						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate}))
+						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate, PlanID: newPlanID}))
 					})
 				})
 
@@ -262,7 +291,7 @@ var _ = Describe("Update", func() {
 
 					It("returns the bosh task ID and operation type", func() {
 						data := unmarshalOperationData(updateSpec)
-						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate}))
+						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate, PlanID: newPlanID}))
 					})
 				})
 
@@ -281,7 +310,7 @@ var _ = Describe("Update", func() {
 
 					It("returns the bosh task ID and operation type", func() {
 						data := unmarshalOperationData(updateSpec)
-						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate}))
+						Expect(data).To(Equal(broker.OperationData{BoshTaskID: boshTaskID, OperationType: broker.OperationTypeUpdate, PlanID: newPlanID}))
 					})
 				})
 
@@ -309,7 +338,7 @@ var _ = Describe("Update", func() {
 
 					It("calls the deployer with a bosh context id", func() {
 						Expect(fakeDeployer.UpdateCallCount()).To(Equal(1))
-						_, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
+						_, _, _, _, _, actualBoshContextID, _, _ := fakeDeployer.UpdateArgsForCall(0)
 						Expect(actualBoshContextID).NotTo(BeEmpty())
 					})
 				})
@@ -660,6 +689,17 @@ var _ = Describe("Update", func() {
 				Expect(updateErr).To(MatchError("plan instance limit exceeded for service ID: service-id. Total instances: 4"))
 			})
 
+			It("does not count a plan change against the global instance limit", func() {
+				globalLimit := 5
+				updateErr := updateWithQuotas(
+					quotaCase{nil, nil, &globalLimit, nil},
+					1,
+					4,
+					map[string]interface{}{}, map[string]interface{}{},
+				)
+				Expect(updateErr).NotTo(HaveOccurred())
+			})
+
 			It("fails and output multiple errors when more than one quotas is exceeded", func() {
 				count := 4
 				updateErr := updateWithQuotas(
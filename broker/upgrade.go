@@ -16,12 +16,12 @@ import (
 
 	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 )
 
 func (b *Broker) Upgrade(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, logger *log.Logger) (OperationData, error) {
-	b.deploymentLock.Lock()
-	defer b.deploymentLock.Unlock()
+	defer b.instanceLocks.acquire(instanceID)()
 
 	logger.Printf("upgrading instance %s", instanceID)
 
@@ -29,20 +29,28 @@ func (b *Broker) Upgrade(ctx context.Context, instanceID string, details brokera
 		return OperationData{}, b.processError(errors.New("no plan ID provided in upgrade request body"), logger)
 	}
 
+	// The deployment is upgraded on its own plan unless the previous plan is
+	// given, as it is when reconciling an instance deployed with another plan.
+	previousPlanID := details.PlanID
+	if details.PreviousValues.PlanID != "" {
+		previousPlanID = details.PreviousValues.PlanID
+	}
+
 	plan, found := b.serviceOffering.FindPlanByID(details.PlanID)
 	if !found {
-		logger.Printf("error: finding plan ID %s", details.PlanID)
+		loggerfactory.Errorf(logger, "error: finding plan ID %s", details.PlanID)
 		return OperationData{}, b.processError(fmt.Errorf("plan %s not found", details.PlanID), logger)
 	}
 
 	var boshContextID string
 
-	if plan.LifecycleErrands != nil {
+	preUpgradeErrands := plan.PreUpgradeErrands()
+	if plan.LifecycleErrands != nil || len(preUpgradeErrands) > 0 || b.RollbackFailedUpgrades {
 		boshContextID = uuid.New()
 	}
 
 	if b.EnablePlanSchemas {
-		schemas, _ := b.adapterClient.GeneratePlanSchema(plan.AdapterPlan(b.serviceOffering.GlobalProperties), logger)
+		schemas, _ := b.planSchemas(ctx, plan, logger)
 		instanceUpgradeSchema := schemas.Instance.Update
 
 		validator := NewValidator(instanceUpgradeSchema.Parameters)
@@ -63,19 +71,50 @@ func (b *Broker) Upgrade(ctx context.Context, instanceID string, details brokera
 		}
 	}
 
-	taskID, _, err := b.deployer.Upgrade(
-		deploymentName(instanceID),
-		details.PlanID,
-		&details.PlanID,
-		boshContextID,
-		logger,
-	)
+	// The snapshot of what is deployed is taken and stored up front, as
+	// without it a failed upgrade could not be rolled back.
+	var snapshotRef string
+	if b.RollbackFailedUpgrades {
+		snapshot, err := b.deployer.Snapshot(b.deploymentName(instanceID), logger)
+		if err == nil {
+			snapshotRef, err = b.storeRollbackSnapshot(instanceID, boshContextID, snapshot)
+		}
+		if err != nil {
+			loggerfactory.Errorf(logger, "error upgrading instance %s: %s", instanceID, err)
+			return OperationData{}, b.processError(err, logger)
+		}
+	}
+
+	var taskID int
+	var err error
+	if len(preUpgradeErrands) > 0 {
+		// The upgrade only starts once the errands have run, so check up front
+		// that its manifest can be generated.
+		_, err = b.deployer.PreviewUpgrade(ctx, b.deploymentName(instanceID), details.PlanID, &previousPlanID, logger)
+		if err == nil {
+			taskID, err = b.startPreOperationErrands(ctx, b.deploymentName(instanceID), preUpgradeErrands, boshContextID, logger)
+		}
+	} else {
+		taskID, _, err = b.deployer.Upgrade(
+			ctx,
+			b.deploymentName(instanceID),
+			details.PlanID,
+			&previousPlanID,
+			boshContextID,
+			logger,
+		)
+	}
 
 	if err != nil {
-		logger.Printf("error upgrading instance %s: %s", instanceID, err)
+		loggerfactory.Errorf(logger, "error upgrading instance %s: %s", instanceID, err)
+		if snapshotRef != "" {
+			if err := b.parameterStore.Delete(snapshotRef); err != nil {
+				loggerfactory.Errorf(logger, "WARNING: failed to remove the snapshot of the deployment of instance %s: %s\n", instanceID, err)
+			}
+		}
 
 		switch err := err.(type) {
-		case serviceadapter.UnknownFailureError:
+		case serviceadapter.UnknownFailureError, serviceadapter.TimeoutError:
 			return OperationData{}, b.processError(adapterToAPIError(ctx, err), logger)
 		case TaskInProgressError:
 			return OperationData{}, b.processError(NewOperationInProgressError(err), logger)
@@ -84,10 +123,22 @@ func (b *Broker) Upgrade(ctx context.Context, instanceID string, details brokera
 		}
 	}
 
-	return OperationData{
-		BoshContextID: boshContextID,
-		BoshTaskID:    taskID,
-		OperationType: OperationTypeUpgrade,
-		Errands:       plan.PostDeployErrands(),
-	}, nil
+	operationData := OperationData{
+		BoshContextID:     boshContextID,
+		BoshTaskID:        taskID,
+		OperationType:     OperationTypeUpgrade,
+		Errands:           plan.PostDeployErrands(),
+		RollbackOnFailure: b.RollbackFailedUpgrades,
+	}
+	if len(preUpgradeErrands) > 0 {
+		operationData.PlanID = details.PlanID
+		operationData.Errands = preOperationThenPostDeployErrands(preUpgradeErrands, plan.PostDeployErrands())
+		operationData.PreOperationErrandCount = len(preUpgradeErrands)
+		if previousPlanID != details.PlanID {
+			operationData.PreviousPlanID = previousPlanID
+		}
+	}
+	b.recordStartedUpgrade(ctx, instanceID, details.PlanID, snapshotRef, operationData, logger)
+
+	return operationData, nil
 }
@@ -8,6 +8,7 @@ package broker_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -18,6 +19,7 @@ import (
 	brokerfakes "github.com/pivotal-cf/on-demand-service-broker/broker/fakes"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
 var _ = Describe("Upgrade", func() {
@@ -48,7 +50,7 @@ var _ = Describe("Upgrade", func() {
 		Expect(fakeDeployer.CreateCallCount()).To(Equal(0))
 		Expect(fakeDeployer.UpgradeCallCount()).To(Equal(1))
 		Expect(fakeDeployer.UpdateCallCount()).To(Equal(0))
-		actualDeploymentName, actualPlanID, actualPreviousPlanID, actualBoshContextID, _ := fakeDeployer.UpgradeArgsForCall(0)
+		_, actualDeploymentName, actualPlanID, actualPreviousPlanID, actualBoshContextID, _ := fakeDeployer.UpgradeArgsForCall(0)
 		Expect(actualPlanID).To(Equal(existingPlanID))
 		Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
 		oldPlanIDCopy := existingPlanID
@@ -56,6 +58,17 @@ var _ = Describe("Upgrade", func() {
 		Expect(actualBoshContextID).To(BeEmpty())
 	})
 
+	It("deploys from the previous plan when one is given", func() {
+		details.PreviousValues = brokerapi.PreviousValues{PlanID: secondPlanID}
+
+		_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+		Expect(redeployErr).NotTo(HaveOccurred())
+		_, _, actualPlanID, actualPreviousPlanID, _, _ := fakeDeployer.UpgradeArgsForCall(0)
+		Expect(actualPlanID).To(Equal(existingPlanID))
+		Expect(*actualPreviousPlanID).To(Equal(secondPlanID))
+	})
+
 	Context("when there is a previous deployment for the service instance", func() {
 		It("responds with the correct upgradeOperationData", func() {
 			upgradeOperationData, _ = b.Upgrade(context.Background(), instanceID, details, logger)
@@ -77,7 +90,7 @@ var _ = Describe("Upgrade", func() {
 
 			upgradeOperationData, _ = b.Upgrade(context.Background(), instanceID, details, logger)
 
-			_, _, _, contextID, _ := fakeDeployer.UpgradeArgsForCall(0)
+			_, _, _, _, contextID, _ := fakeDeployer.UpgradeArgsForCall(0)
 			Expect(contextID).NotTo(BeEmpty())
 			Expect(upgradeOperationData.BoshContextID).NotTo(BeEmpty())
 			Expect(upgradeOperationData).To(Equal(
@@ -93,6 +106,105 @@ var _ = Describe("Upgrade", func() {
 			))
 		})
 
+		It("and rolling back failed upgrades is enabled deploys with a context id and marks the upgrade to be rolled back on failure", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			b = createDefaultBroker()
+			b.UseParameterStore(new(brokerfakes.FakeParameterStore))
+
+			upgradeOperationData, _ = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			_, _, _, _, contextID, _ := fakeDeployer.UpgradeArgsForCall(0)
+			Expect(contextID).NotTo(BeEmpty())
+			Expect(upgradeOperationData.BoshContextID).To(Equal(contextID))
+			Expect(upgradeOperationData.RollbackOnFailure).To(BeTrue())
+		})
+
+		It("and rolling back failed upgrades is enabled stores the snapshot of the deployment it replaces and records a reference to it", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			fakeDeployer.SnapshotReturns(broker.DeploymentSnapshot{
+				Manifest: []byte("name: previous-deployment"),
+				Configs:  map[string]string{"cloud": "previous-cloud-config"},
+			}, nil)
+			parameterStore := new(brokerfakes.FakeParameterStore)
+			b = createDefaultBroker()
+			b.UseParameterStore(parameterStore)
+
+			upgradeOperationData, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).NotTo(HaveOccurred())
+			deploymentName, _ := fakeDeployer.SnapshotArgsForCall(0)
+			Expect(deploymentName).To(Equal(broker.InstancePrefix + instanceID))
+
+			Expect(parameterStore.SetCallCount()).To(Equal(1))
+			key, value := parameterStore.SetArgsForCall(0)
+			Expect(key).To(Equal(fmt.Sprintf("/c/%s/%s/%s/rollback", serviceOfferingID, instanceID, upgradeOperationData.BoshContextID)))
+			Expect(value).To(Equal(map[string]interface{}{
+				"manifest": "name: previous-deployment",
+				"configs":  map[string]interface{}{"cloud": "previous-cloud-config"},
+			}))
+
+			Expect(fakeOperationJournal.RecordCallCount()).To(Equal(1))
+			Expect(fakeOperationJournal.RecordArgsForCall(0).RollbackSnapshotRef).To(Equal(key))
+		})
+
+		It("and rolling back failed upgrades is enabled but the deployment cannot be read does not upgrade", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			fakeDeployer.SnapshotReturns(broker.DeploymentSnapshot{}, errors.New("director unavailable"))
+			b = createDefaultBroker()
+			b.UseParameterStore(new(brokerfakes.FakeParameterStore))
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(HaveOccurred())
+			Expect(fakeDeployer.UpgradeCallCount()).To(Equal(0))
+		})
+
+		It("and rolling back failed upgrades is enabled but the snapshot cannot be stored does not upgrade", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			parameterStore := new(brokerfakes.FakeParameterStore)
+			parameterStore.SetReturns(errors.New("credhub unavailable"))
+			b = createDefaultBroker()
+			b.UseParameterStore(parameterStore)
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(HaveOccurred())
+			Expect(fakeDeployer.UpgradeCallCount()).To(Equal(0))
+			Expect(logBuffer.String()).To(ContainSubstring("error storing the snapshot of the deployment: credhub unavailable"))
+		})
+
+		It("and rolling back failed upgrades is enabled but there is no credential store does not upgrade", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			b = createDefaultBroker()
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(HaveOccurred())
+			Expect(fakeDeployer.UpgradeCallCount()).To(Equal(0))
+		})
+
+		It("and rolling back failed upgrades is enabled but the upgrade fails to start deletes the stored snapshot", func() {
+			brokerConfig.RollbackFailedUpgrades = true
+			fakeDeployer.UpgradeReturns(0, nil, errors.New("director unavailable"))
+			parameterStore := new(brokerfakes.FakeParameterStore)
+			b = createDefaultBroker()
+			b.UseParameterStore(parameterStore)
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(HaveOccurred())
+			Expect(parameterStore.DeleteCallCount()).To(Equal(1))
+			key, _ := parameterStore.SetArgsForCall(0)
+			Expect(parameterStore.DeleteArgsForCall(0)).To(Equal(key))
+		})
+
+		It("and rolling back failed upgrades is not enabled records no snapshot", func() {
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(fakeDeployer.SnapshotCallCount()).To(Equal(0))
+			Expect(fakeOperationJournal.RecordArgsForCall(0).RollbackSnapshotRef).To(BeEmpty())
+		})
+
 		It("and the service adapter returns a UnknownFailureError with a user message returns the error for the user", func() {
 			err := serviceadapter.NewUnknownFailureError("error for cf user")
 			fakeDeployer.UpgradeReturns(boshTaskID, nil, err)
@@ -109,6 +221,96 @@ var _ = Describe("Upgrade", func() {
 		})
 	})
 
+	Context("when pre-upgrade errands are configured", func() {
+		BeforeEach(func() {
+			serviceCatalog.Plans = append(serviceCatalog.Plans, config.Plan{
+				ID: "pre-upgrade-errand-plan",
+				PreOperationErrands: config.PreOperationErrands{
+					PreUpgrade: []config.Errand{{Name: "backup", Instances: []string{"redis-server/0"}}, {Name: "drain"}},
+				},
+				LifecycleErrands: &sdk.LifecycleErrands{
+					PostDeploy: []sdk.Errand{{Name: "health-check"}},
+				},
+			})
+			b = createDefaultBroker()
+			details = brokerapi.UpdateDetails{PlanID: "pre-upgrade-errand-plan"}
+			fakeDeployer.RunErrandReturns(boshTaskID, nil)
+		})
+
+		It("runs the first errand instead of deploying", func() {
+			upgradeOperationData, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).NotTo(HaveOccurred())
+			Expect(fakeDeployer.UpgradeCallCount()).To(BeZero())
+			Expect(fakeDeployer.RunErrandCallCount()).To(Equal(1))
+			_, actualDeploymentName, errandName, errandInstances, contextID, _ := fakeDeployer.RunErrandArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(errandName).To(Equal("backup"))
+			Expect(errandInstances).To(Equal([]string{"redis-server/0"}))
+			Expect(contextID).NotTo(BeEmpty())
+
+			Expect(upgradeOperationData).To(Equal(broker.OperationData{
+				BoshTaskID:    boshTaskID,
+				BoshContextID: contextID,
+				OperationType: broker.OperationTypeUpgrade,
+				PlanID:        "pre-upgrade-errand-plan",
+				Errands: []config.Errand{
+					{Name: "backup", Instances: []string{"redis-server/0"}},
+					{Name: "drain"},
+					{Name: "health-check"},
+				},
+				PreOperationErrandCount: 2,
+			}))
+		})
+
+		It("checks that the upgraded manifest can be generated before running the errands", func() {
+			upgradeOperationData, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).NotTo(HaveOccurred())
+			Expect(fakeDeployer.PreviewUpgradeCallCount()).To(Equal(1))
+			_, actualDeploymentName, planID, previousPlanID, _ := fakeDeployer.PreviewUpgradeArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(broker.InstancePrefix + instanceID))
+			Expect(planID).To(Equal("pre-upgrade-errand-plan"))
+			Expect(*previousPlanID).To(Equal("pre-upgrade-errand-plan"))
+		})
+
+		It("keeps the previous plan for the deploy that follows the errands when one is given", func() {
+			details.PreviousValues = brokerapi.PreviousValues{PlanID: existingPlanID}
+
+			upgradeOperationData, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).NotTo(HaveOccurred())
+			_, _, _, previousPlanID, _ := fakeDeployer.PreviewUpgradeArgsForCall(0)
+			Expect(*previousPlanID).To(Equal(existingPlanID))
+			Expect(upgradeOperationData.PreviousPlanID).To(Equal(existingPlanID))
+		})
+
+		It("does not run the errands when the upgraded manifest cannot be generated", func() {
+			fakeDeployer.PreviewUpgradeReturns(broker.DeploymentPreview{}, serviceadapter.NewUnknownFailureError("adapter failed"))
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(MatchError(ContainSubstring("adapter failed")))
+			Expect(fakeDeployer.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("returns an OperationInProgressError when there is a task in progress on the instance", func() {
+			fakeDeployer.RunErrandReturns(0, broker.TaskInProgressError{Message: "task in progress"})
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(BeAssignableToTypeOf(broker.OperationInProgressError{}))
+		})
+
+		It("fails when the deployment does not exist", func() {
+			fakeDeployer.RunErrandReturns(0, broker.NewDeploymentNotFoundError(errors.New("bosh deployment not found")))
+
+			_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
+
+			Expect(redeployErr).To(HaveOccurred())
+		})
+	})
+
 	It("when no update details are provided returns an error", func() {
 		details = brokerapi.UpdateDetails{}
 		_, redeployErr = b.Upgrade(context.Background(), instanceID, details, logger)
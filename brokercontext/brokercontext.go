@@ -16,6 +16,7 @@ const (
 	serviceNameKey correlationIDType = iota
 	instanceIDKey  correlationIDType = iota
 	boshTaskIDKey  correlationIDType = iota
+	planIDKey      correlationIDType = iota
 )
 
 func New(ctx context.Context, operation, requestID, serviceName, instanceID string) context.Context {
@@ -70,3 +71,12 @@ func GetBoshTaskID(ctx context.Context) int {
 	boshTaskID, _ := ctx.Value(boshTaskIDKey).(int)
 	return boshTaskID
 }
+
+func WithPlanID(ctx context.Context, planID string) context.Context {
+	return context.WithValue(ctx, planIDKey, planID)
+}
+
+func GetPlanID(ctx context.Context) string {
+	planID, _ := ctx.Value(planIDKey).(string)
+	return planID
+}
@@ -77,6 +77,14 @@ var _ = Describe("BrokerContext", func() {
 		})
 	})
 
+	Describe("Plan ID", func() {
+		It("can be set and retrieved", func() {
+			planID := "the-biggest-plan-id"
+			ctx = WithPlanID(ctx, planID)
+			Expect(GetPlanID(ctx)).To(Equal(planID))
+		})
+	})
+
 	Context("with multiple attributes", func() {
 		It("can set and retrieve all of them", func() {
 			operation := "create"
@@ -1,23 +1,32 @@
 package brokerinitiator
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/pivotal-cf/on-demand-service-broker/hasher"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 
 	credhub2 "code.cloudfoundry.org/credhub-cli/credhub"
 	"code.cloudfoundry.org/credhub-cli/credhub/auth"
+	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/on-demand-service-broker/apiserver"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/credhub"
 	"github.com/pivotal-cf/on-demand-service-broker/credhubbroker"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceregistry"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/manifestsecrets"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/multioffering"
 	"github.com/pivotal-cf/on-demand-service-broker/network"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 	"github.com/pivotal-cf/on-demand-service-broker/startupchecker"
 	"github.com/pivotal-cf/on-demand-service-broker/task"
@@ -27,18 +36,91 @@ func Initiate(conf config.Config,
 	brokerBoshClient broker.BoshClient,
 	taskBoshClient task.BoshClient,
 	cfClient broker.CloudFoundryClient,
-	commandRunner serviceadapter.CommandRunner,
+	newCommandRunner func(config.ServiceAdapter) serviceadapter.CommandRunner,
 	stopServer chan os.Signal,
 	loggerFactory *loggerfactory.LoggerFactory) {
 
 	logger := loggerFactory.New()
-	var err error
+	brokerMetrics := metrics.New(conf.ServiceCatalog)
+	operationJournal := buildOperationJournal(conf, logger)
+
+	// The operations the brokers watch are polled for as long as the server
+	// runs.
+	serverCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+
+	var offeringBrokers []apiserver.ServiceOfferingBroker
+	var odbs []*broker.Broker
+	for _, offeringConf := range conf.ServiceOfferings() {
+		offeringMetrics := brokerMetrics.ForServiceOffering(offeringConf.ServiceCatalog)
+		offeringBroker, odb := buildServiceOfferingBroker(
+			serverCtx,
+			offeringConf,
+			brokerBoshClient,
+			taskBoshClient,
+			cfClient,
+			offeringMetrics.InstrumentCommandRunner(newCommandRunner(offeringConf.ServiceAdapter)),
+			operationJournal,
+			offeringMetrics,
+			loggerFactory,
+		)
+		offeringBrokers = append(offeringBrokers, apiserver.ServiceOfferingBroker{
+			ServiceOffering: offeringConf.ServiceCatalog,
+			Broker:          offeringBroker,
+		})
+		odbs = append(odbs, odb)
+	}
+	invalidateCatalogsOnHangup(odbs, logger)
+
+	var onDemandBroker brokerapi.ServiceBroker = offeringBrokers[0].Broker
+	if len(offeringBrokers) > 1 {
+		onDemandBroker = multioffering.New(offeringBrokers)
+	}
+
+	server := apiserver.New(
+		conf,
+		onDemandBroker,
+		offeringBrokers,
+		broker.ComponentName,
+		loggerFactory,
+		brokerMetrics,
+		logger,
+	)
+
+	displayBanner(conf)
+	apiserver.StartAndWait(conf, server, logger, stopServer)
+}
+
+// operationWatchInterval is how often the broker polls the operations it has
+// started, to record their outcome in the operation journal.
+const operationWatchInterval = 30 * time.Second
+
+// fleetMetricsCacheTTL is how long instance counts fetched from the platform
+// are reused by metrics scrapes.
+const fleetMetricsCacheTTL = 30 * time.Second
+
+// buildServiceOfferingBroker builds the broker for the service offering in
+// conf, as returned by config.Config.ServiceOfferings. It returns the broker
+// to serve and the on-demand broker it wraps.
+func buildServiceOfferingBroker(
+	ctx context.Context,
+	conf config.Config,
+	brokerBoshClient broker.BoshClient,
+	taskBoshClient task.BoshClient,
+	cfClient broker.CloudFoundryClient,
+	commandRunner serviceadapter.CommandRunner,
+	operationJournal broker.OperationJournal,
+	brokerMetrics *metrics.Metrics,
+	loggerFactory *loggerfactory.LoggerFactory,
+) (apiserver.CombinedBroker, *broker.Broker) {
+	logger := loggerFactory.New()
 	startupChecks := buildStartupChecks(conf, cfClient, logger, brokerBoshClient)
 
 	serviceAdapter := &serviceadapter.Client{
-		ExternalBinPath: conf.ServiceAdapter.Path,
+		ExternalBinPath: conf.ServiceAdapter.Location(),
 		CommandRunner:   commandRunner,
 		UsingStdin:      conf.Broker.UsingStdin,
+		Timeouts:        conf.ServiceAdapter.Timeouts.ByCommand(),
 	}
 
 	manifestGenerator := task.NewManifestGenerator(
@@ -55,13 +137,9 @@ func Initiate(conf config.Config,
 
 	manifestSecretManager := manifestsecrets.BuildManager(conf.Broker.EnableSecureManifests, new(manifestsecrets.CredHubPathMatcher), boshCredhubStore)
 
-	instanceLister, err := service.BuildInstanceLister(cfClient, conf.ServiceCatalog.ID, conf.ServiceInstancesAPI, logger)
-	if err != nil {
-		logger.Fatalf("error building instance lister: %s", err)
-	}
+	instanceLister := buildInstanceLister(conf, cfClient, logger)
 
-	var onDemandBroker apiserver.CombinedBroker
-	onDemandBroker, err = broker.New(
+	odb, err := broker.New(
 		brokerBoshClient,
 		cfClient,
 		conf.ServiceCatalog,
@@ -72,28 +150,59 @@ func Initiate(conf config.Config,
 		manifestSecretManager,
 		instanceLister,
 		&hasher.MapHasher{},
+		operationJournal,
 		loggerFactory,
 	)
 	if err != nil {
 		logger.Fatalf("error starting broker: %s", err)
 	}
+
+	var runtimeCredentialStore *credhub.Store
 	if conf.HasRuntimeCredHub() {
-		onDemandBroker = wrapWithCredHubBroker(conf, logger, onDemandBroker, loggerFactory)
+		runtimeCredentialStore = buildRuntimeCredentialStore(conf, logger)
+		odb.UseParameterStore(runtimeCredentialStore)
+	}
+	if err := odb.WatchOperations(ctx, operationWatchInterval, logger); err != nil {
+		loggerfactory.Errorf(logger, "error resuming the operations in progress, they will be recorded when next polled: %s", err)
+	}
+	if conf.Broker.InstanceRegistryPath != "" {
+		if _, _, err := odb.ReconcileInstanceRegistry(logger); err != nil {
+			loggerfactory.Errorf(logger, "error reconciling the instance registry with BOSH, continuing with the registered instances: %s", err)
+		}
 	}
 
-	server := apiserver.New(
-		conf,
-		onDemandBroker,
-		broker.ComponentName,
-		loggerFactory,
-		logger,
-	)
+	var onDemandBroker apiserver.CombinedBroker = odb
+	if runtimeCredentialStore != nil {
+		credhubBroker := credhubbroker.New(onDemandBroker, runtimeCredentialStore, conf.ServiceCatalog.Name, loggerFactory)
+		odb.UseBindingStore(credhubBroker)
+		onDemandBroker = credhubBroker
+	}
+	onDemandBroker = brokerMetrics.WrapBroker(onDemandBroker)
+	brokerMetrics.CollectFleet(onDemandBroker, fleetMetricsCacheTTL, conf.Broker.CloudFoundryPlatform(), loggerFactory)
 
-	displayBanner(conf)
-	apiserver.StartAndWait(conf, server, logger, stopServer)
+	return onDemandBroker, odb
+}
+
+// invalidateCatalogsOnHangup clears the cached catalog and plan schemas of
+// every service offering when the broker receives SIGHUP, so that an operator
+// who has replaced the service adapter can have the schemas generated afresh
+// without restarting the broker.
+func invalidateCatalogsOnHangup(odbs []*broker.Broker, logger *log.Logger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			for _, odb := range odbs {
+				odb.InvalidateCatalog()
+			}
+			logger.Println("Cleared the cached catalog and plan schemas on SIGHUP")
+		}
+	}()
 }
 
-func wrapWithCredHubBroker(conf config.Config, logger *log.Logger, onDemandBroker apiserver.CombinedBroker, loggerFactory *loggerfactory.LoggerFactory) apiserver.CombinedBroker {
+// buildRuntimeCredentialStore connects to the runtime CredHub, which holds the
+// credentials of bindings and the parameters of service instances.
+func buildRuntimeCredentialStore(conf config.Config, logger *log.Logger) *credhub.Store {
 	err := network.NewHostWaiter().Wait(conf.CredHub.APIURL, 16, 10)
 	if err != nil {
 		logger.Fatalf("error connecting to runtime credhub: %s", err)
@@ -108,7 +217,38 @@ func wrapWithCredHubBroker(conf config.Config, logger *log.Logger, onDemandBroke
 	if err != nil {
 		logger.Fatalf("error creating runtime credhub client: %s", err)
 	}
-	return credhubbroker.New(onDemandBroker, runtimeCredentialStore, conf.ServiceCatalog.Name, loggerFactory)
+	return runtimeCredentialStore
+}
+
+// buildInstanceLister lists instances with the instance registry when one is
+// configured, which the broker then keeps up to date, and otherwise with the
+// service instances API or Cloud Foundry.
+func buildInstanceLister(conf config.Config, cfClient broker.CloudFoundryClient, logger *log.Logger) service.InstanceLister {
+	if conf.Broker.InstanceRegistryPath != "" {
+		registry, err := instanceregistry.New(conf.Broker.InstanceRegistryPath)
+		if err != nil {
+			logger.Fatalf("error starting broker: %s", err)
+		}
+		return registry
+	}
+
+	instanceLister, err := service.BuildInstanceLister(cfClient, conf.ServiceCatalog.ID, conf.ServiceInstancesAPI, logger)
+	if err != nil {
+		logger.Fatalf("error building instance lister: %s", err)
+	}
+	return instanceLister
+}
+
+func buildOperationJournal(conf config.Config, logger *log.Logger) broker.OperationJournal {
+	if conf.Broker.OperationJournalPath == "" {
+		return nil
+	}
+
+	journal, err := operationjournal.New(conf.Broker.OperationJournalPath)
+	if err != nil {
+		logger.Fatalf("error starting broker: %s", err)
+	}
+	return journal
 }
 
 func buildCredhubStore(conf config.Config, logger *log.Logger) *credhub.Store {
@@ -132,7 +272,7 @@ func buildCredhubStore(conf config.Config, logger *log.Logger) *credhub.Store {
 
 func buildStartupChecks(conf config.Config, cfClient broker.CloudFoundryClient, logger *log.Logger, boshClient broker.BoshClient) []broker.StartupChecker {
 	var startupChecks []broker.StartupChecker
-	if !conf.Broker.DisableCFStartupChecks {
+	if conf.Broker.CloudFoundryPlatform() && !conf.Broker.DisableCFStartupChecks {
 		startupChecks = append(
 			startupChecks,
 			startupchecker.NewCFAPIVersionChecker(cfClient, broker.MinimumCFVersion, logger),
@@ -70,6 +70,7 @@ func (c Client) GetInstanceState(serviceInstanceGUID string, logger *log.Logger)
 	return InstanceState{
 		PlanID:              plan.ServicePlanEntity.UniqueID,
 		OperationInProgress: instance.Entity.LastOperation.State == OperationStateInProgress,
+		SpaceGUID:           instance.Entity.SpaceGUID,
 	}, nil
 }
 
@@ -119,6 +120,11 @@ func (c Client) GetInstancesOfServiceOfferingByOrgSpace(serviceOfferingID, orgNa
 		return []s.Instance{}, nil
 	}
 
+	if spaceName == "" {
+		query := fmt.Sprintf("&q=organization_guid:%s", orgResponse.Resources[0].Metadata["guid"])
+		return c.getInstances(plans, query, logger)
+	}
+
 	spaceURL := fmt.Sprintf("%s%s?q=name:%s",
 		c.url,
 		orgResponse.Resources[0].Entity["spaces_url"],
@@ -649,6 +649,7 @@ var _ = Describe("Client", func() {
 			state, err := client.GetInstanceState("783f8645-1ded-4161-b457-73f59423f9eb", testLogger)
 			Expect(state.PlanID).To(Equal("11789210-D743-4C65-9D38-C80B29F4D9C8"))
 			Expect(state.OperationInProgress).To(BeFalse())
+			Expect(state.SpaceGUID).To(Equal("a157c861-92bb-4f57-9108-f791260f66ab"))
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -966,6 +967,32 @@ var _ = Describe("Client", func() {
 			))
 		})
 
+		It("returns a list of instances filtered by org when no space is given", func() {
+			offeringID := "8F3E8998-5FD0-4F32-924A-5478DC390A5F"
+			server.VerifyAndMock(
+				mockcfapi.ListServiceOfferings().WithAuthorizationHeader(cfAuthorizationHeader).RespondsOKWith(fixture("list_services_response.json")),
+				mockcfapi.ListServicePlans("34c08156-5b5d-4cc1-9af1-29cda9ec056f").WithAuthorizationHeader(cfAuthorizationHeader).RespondsOKWith(fixture("list_service_plans_response.json")),
+				mockcfapi.ListOrg(orgName).WithAuthorizationHeader(cfAuthorizationHeader).RespondsOKWith(fixture("org_response.json")),
+				mockcfapi.ListServiceInstancesByOrg("ff717e7c-afd5-4d0a-bafe-16c7eff546ec", orgGuid).WithAuthorizationHeader(cfAuthorizationHeader).RespondsOKWith(
+					fixture("list_service_instances_for_plan_1_response.json"),
+				),
+				mockcfapi.ListServiceInstancesByOrg("2777ad05-8114-4169-8188-2ef5f39e0c6b", orgGuid).WithAuthorizationHeader(cfAuthorizationHeader).RespondsOKWith(
+					fixture("list_service_instances_for_plan_2_response.json"),
+				),
+			)
+
+			client, err := cf.New(server.URL, authHeaderBuilder, nil, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			instances, err := client.GetInstancesOfServiceOfferingByOrgSpace(offeringID, orgName, "", testLogger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(ConsistOf(
+				service.Instance{GUID: "520f8566-b727-4c67-8be8-d9285645e936", PlanUniqueID: "11789210-D743-4C65-9D38-C80B29F4D9C8"},
+				service.Instance{GUID: "f897f40d-0b2d-474a-a5c9-98426a2cb4b8", PlanUniqueID: "22789210-D743-4C65-9D38-C80B29F4D9C8"},
+				service.Instance{GUID: "2f759033-04a4-426b-bccd-01722036c152", PlanUniqueID: "22789210-D743-4C65-9D38-C80B29F4D9C8"},
+			))
+		})
+
 		It("returns a list of instance IDs when the list of services spans multiple pages", func() {
 			offeringID := "D94A086D-203D-4966-A6F1-60A9E2300F72"
 
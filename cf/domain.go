@@ -97,6 +97,7 @@ func (o LastOperation) IsDelete() bool {
 
 type serviceInstanceEntity struct {
 	ServicePlanURL string        `json:"service_plan_url"`
+	SpaceGUID      string        `json:"space_guid"`
 	LastOperation  LastOperation `json:"last_operation"`
 }
 
@@ -116,6 +117,10 @@ func (i Instance) OperationFailed() bool {
 type InstanceState struct {
 	PlanID              string
 	OperationInProgress bool
+
+	// SpaceGUID is the space that owns the instance, which may differ from
+	// the spaces the instance has been shared with.
+	SpaceGUID string
 }
 
 type Binding struct {
@@ -0,0 +1,80 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/craigfurman/herottp"
+	"github.com/pivotal-cf/on-demand-service-broker/authorizationheader"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/orphancleanup"
+	"github.com/pivotal-cf/on-demand-service-broker/tools"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const DeletionFailedMessage = "One or more orphan deployments could not be deleted."
+
+func main() {
+	loggerFactory := loggerfactory.New(os.Stderr, "cleanup-orphan-deployments", loggerfactory.Flags)
+	logger := loggerFactory.New()
+
+	var configPath string
+	flag.StringVar(&configPath, "configPath", "", "path to cleanup-orphan-deployments errand config")
+	flag.Parse()
+
+	if configPath == "" {
+		logger.Fatalln("-configPath must be given as argument")
+	}
+
+	contents, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		logger.Fatalln(err.Error())
+	}
+
+	var errandConfig config.CleanupOrphanDeploymentsErrandConfig
+	if err := yaml.Unmarshal(contents, &errandConfig); err != nil {
+		logger.Fatalf("failed to unmarshal errand config: %s\n", err.Error())
+	}
+	if err := errandConfig.Validate(); err != nil {
+		logger.Fatalf("invalid errand config: %s\n", err.Error())
+	}
+
+	httpClient := herottp.New(herottp.Config{
+		Timeout: 5 * time.Minute,
+	})
+
+	brokerUsername := errandConfig.BrokerAPI.Authentication.Basic.Username
+	brokerPassword := errandConfig.BrokerAPI.Authentication.Basic.Password
+
+	authHeaderBuilder := authorizationheader.NewBasicAuthHeaderBuilder(brokerUsername, brokerPassword)
+	brokerServices := services.NewBrokerServices(httpClient, authHeaderBuilder, errandConfig.BrokerAPI.ServiceOfferingURL(), logger)
+
+	cleaner := orphancleanup.New(brokerServices, tools.RealSleeper{}, errandConfig, logger)
+	result, err := cleaner.Run(time.Now())
+	if err != nil {
+		logger.Fatalf("error cleaning up orphan deployments: %s", err)
+	}
+
+	rawJSON, err := json.Marshal(result)
+	if err != nil {
+		logger.Fatalf("error marshalling cleanup result: %s", err)
+	}
+
+	fmt.Fprintln(os.Stdout, string(rawJSON))
+
+	if len(result.Failed) > 0 {
+		logger.Fatalln(DeletionFailedMessage)
+	}
+}
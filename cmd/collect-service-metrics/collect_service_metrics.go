@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/craigfurman/herottp"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
 )
 
 const (
@@ -27,9 +28,11 @@ func main() {
 	brokerUsername := flag.String("brokerUsername", "", "username for the broker")
 	brokerPassword := flag.String("brokerPassword", "", "password for the broker")
 	brokerUrl := flag.String("brokerUrl", "", "url of the broker")
+	serviceOfferingID := flag.String("serviceOfferingID", "", "id of the service offering to collect metrics for, when the broker serves several")
 	flag.Parse()
 
-	brokerMetricsUrl := *brokerUrl + "/mgmt/metrics"
+	brokerAPI := config.BrokerAPI{URL: *brokerUrl, ServiceOfferingID: *serviceOfferingID}
+	brokerMetricsUrl := brokerAPI.ServiceOfferingURL() + "/mgmt/metrics"
 	client := herottp.New(herottp.Config{
 		Timeout: 30 * time.Second,
 	})
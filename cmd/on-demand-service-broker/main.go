@@ -33,12 +33,16 @@ func main() {
 	logger.Println("Starting broker")
 
 	config := configParser(logger)
+	if config.Broker.LogFormat == loggerfactory.JSONFormat {
+		loggerFactory = loggerfactory.NewJSON(os.Stdout, broker.ComponentName)
+		logger = loggerFactory.New()
+	}
+
 	boshClient := createBoshClient(logger, config)
-	commandRunner := serviceadapter.NewCommandRunner()
 	stopServer := make(chan os.Signal, 1)
 	cfClient := createCfClient(config, logger)
 
-	brokerinitiator.Initiate(config, boshClient, boshClient, cfClient, commandRunner, stopServer, loggerFactory)
+	brokerinitiator.Initiate(config, boshClient, boshClient, cfClient, commandRunnerCreator(logger), stopServer, loggerFactory)
 }
 
 func configParser(logger *log.Logger) config.Config {
@@ -54,9 +58,18 @@ func configParser(logger *log.Logger) config.Config {
 	return config
 }
 
+func commandRunnerCreator(logger *log.Logger) func(config.ServiceAdapter) serviceadapter.CommandRunner {
+	return func(adapter config.ServiceAdapter) serviceadapter.CommandRunner {
+		if adapter.Socket != "" {
+			return serviceadapter.NewSocketCommandRunner(adapter.Socket)
+		}
+		return serviceadapter.NewCommandRunner(logger)
+	}
+}
+
 func createCfClient(conf config.Config, logger *log.Logger) broker.CloudFoundryClient {
 	var cfClient broker.CloudFoundryClient
-	if !conf.Broker.DisableCFStartupChecks {
+	if conf.Broker.CloudFoundryPlatform() && !conf.Broker.DisableCFStartupChecks {
 		cfClient = createRealCfClient(conf, logger, cfClient)
 	} else {
 		cfClient = noopservicescontroller.New()
@@ -57,7 +57,7 @@ func main() {
 	brokerPassword := errandConfig.BrokerAPI.Authentication.Basic.Password
 
 	authHeaderBuilder := authorizationheader.NewBasicAuthHeaderBuilder(brokerUsername, brokerPassword)
-	brokerServices := services.NewBrokerServices(httpClient, authHeaderBuilder, errandConfig.BrokerAPI.URL, logger)
+	brokerServices := services.NewBrokerServices(httpClient, authHeaderBuilder, errandConfig.BrokerAPI.ServiceOfferingURL(), logger)
 
 	orphans, err := brokerServices.OrphanDeployments()
 	if err != nil {
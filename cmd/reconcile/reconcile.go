@@ -0,0 +1,95 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/craigfurman/herottp"
+	"github.com/pivotal-cf/on-demand-service-broker/authorizationheader"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	DriftDetectedMessage  = "Drift detected between the service instances and their BOSH deployments. Review the report before repairing any instance or deleting any deployment."
+	RepairFailedMessage   = "One or more repairs could not be started."
+	DriftDetectedExitCode = 10
+)
+
+func main() {
+	loggerFactory := loggerfactory.New(os.Stderr, "reconcile", loggerfactory.Flags)
+	logger := loggerFactory.New()
+
+	var configPath string
+	flag.StringVar(&configPath, "configPath", "", "path to reconcile errand config")
+	flag.Parse()
+
+	if configPath == "" {
+		logger.Fatalln("-configPath must be given as argument")
+	}
+
+	contents, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		logger.Fatalln(err.Error())
+	}
+
+	var errandConfig config.ReconcileErrandConfig
+	if err := yaml.Unmarshal(contents, &errandConfig); err != nil {
+		logger.Fatalf("failed to unmarshal errand config: %s\n", err.Error())
+	}
+
+	httpClient := herottp.New(herottp.Config{
+		Timeout: 5 * time.Minute,
+	})
+
+	brokerUsername := errandConfig.BrokerAPI.Authentication.Basic.Username
+	brokerPassword := errandConfig.BrokerAPI.Authentication.Basic.Password
+
+	authHeaderBuilder := authorizationheader.NewBasicAuthHeaderBuilder(brokerUsername, brokerPassword)
+	brokerServices := services.NewBrokerServices(httpClient, authHeaderBuilder, errandConfig.BrokerAPI.ServiceOfferingURL(), logger)
+
+	report, err := brokerServices.Reconcile(broker.ReconcileRepairs{
+		RedeployPlanMismatches: errandConfig.Repairs.RedeployPlanMismatches,
+		DeleteOrphans:          errandConfig.Repairs.DeleteOrphans,
+		RunPreDeleteErrands:    errandConfig.Repairs.RunPreDeleteErrands,
+	})
+	if err != nil {
+		logger.Fatalf("error reconciling service instances: %s", err)
+	}
+
+	rawJSON, err := json.Marshal(report)
+	if err != nil {
+		logger.Fatalf("error marshalling drift report: %s", err)
+	}
+
+	fmt.Fprintln(os.Stdout, string(rawJSON))
+
+	repairFailed := false
+	for _, repair := range report.Repairs {
+		if repair.Error != "" {
+			logger.Printf("%s of %s failed: %s", repair.Action, repair.Target, repair.Error)
+			repairFailed = true
+		}
+	}
+	if repairFailed {
+		logger.Fatalln(RepairFailedMessage)
+	}
+
+	if report.HasDrift() {
+		logger.Println(DriftDetectedMessage)
+		os.Exit(DriftDetectedExitCode)
+	}
+}
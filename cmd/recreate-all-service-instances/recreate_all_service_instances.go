@@ -10,6 +10,7 @@ import (
 	"flag"
 	"io/ioutil"
 	"os"
+	"syscall"
 
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
@@ -45,9 +46,14 @@ func main() {
 		logger.Fatalln(err.Error())
 	}
 	builder.SetRecreateTriggerer()
+	builder.AbortOnSignals(os.Interrupt, syscall.SIGTERM)
 	upgradeTool := instanceiterator.New(builder)
 
 	err = upgradeTool.Iterate()
+	if _, skipped := err.(instanceiterator.SkippedInstancesError); skipped {
+		logger.Println(err.Error())
+		os.Exit(instanceiterator.SkippedInstancesExitCode)
+	}
 	if err != nil {
 		logger.Fatalln(err.Error())
 	}
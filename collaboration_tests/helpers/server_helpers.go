@@ -29,6 +29,8 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/credhubbroker"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 	"github.com/pivotal-cf/on-demand-service-broker/task"
@@ -60,8 +62,10 @@ func StartServer(
 		conf.Broker.ShutdownTimeoutSecs = 2
 	}
 
+	brokerMetrics := metrics.New(conf.ServiceCatalog)
+
 	serviceAdapterClient := &odbserviceadapter.Client{
-		CommandRunner: fakeCommandRunner,
+		CommandRunner: brokerMetrics.InstrumentCommandRunner(fakeCommandRunner),
 		UsingStdin:    true,
 	}
 
@@ -79,6 +83,12 @@ func StartServer(
 	credhubPathMatcher := new(manifestsecrets.CredHubPathMatcher)
 	secretManager := manifestsecrets.BuildManager(true, credhubPathMatcher, fakeCredhubOperator)
 
+	var operationJournal broker.OperationJournal
+	if conf.Broker.OperationJournalPath != "" {
+		operationJournal, err = operationjournal.New(conf.Broker.OperationJournalPath)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
 	fakeOnDemandBroker, err := broker.New(
 		fakeBoshClient,
 		fakeCfClient,
@@ -90,6 +100,7 @@ func StartServer(
 		secretManager,
 		instanceLister,
 		fakeMapHasher,
+		operationJournal,
 		loggerFactory,
 	)
 	Expect(err).NotTo(HaveOccurred())
@@ -99,11 +110,15 @@ func StartServer(
 	} else {
 		fakeBroker = fakeOnDemandBroker
 	}
+	fakeBroker = brokerMetrics.WrapBroker(fakeBroker)
+	brokerMetrics.CollectFleet(fakeBroker, 0, conf.Broker.CloudFoundryPlatform(), loggerFactory)
 	server := apiserver.New(
 		conf,
 		fakeBroker,
+		nil,
 		"collaboration-tests",
 		loggerFactory,
+		brokerMetrics,
 		logger,
 	)
 
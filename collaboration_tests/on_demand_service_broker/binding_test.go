@@ -407,7 +407,7 @@ func doBindRequest(instanceID, bindingID string, bindDetails brokerapi.BindDetai
 
 func getBindInputParams() sdk.CreateBindingJSONParams {
 	Expect(fakeCommandRunner.RunWithInputParamsCallCount()).To(Equal(1))
-	input, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+	_, input, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 	Expect(varArgs).To(HaveLen(2))
 	Expect(varArgs[1]).To(Equal("create-binding"))
 	inputParams, ok := input.(sdk.InputParams)
@@ -17,7 +17,10 @@ package on_demand_service_broker_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"encoding/json"
 
@@ -32,6 +35,7 @@ import (
 	brokerConfig "github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 	"github.com/pkg/errors"
 )
@@ -294,6 +298,104 @@ var _ = Describe("Management API", func() {
 		})
 	})
 
+	Describe("GET /mgmt/service_instances/:id/operations", func() {
+		const instanceID = "some-instance-id"
+
+		It("responds with 501 when the operation journal is not enabled", func() {
+			response, _ := doGetRequest(fmt.Sprintf("service_instances/%s/operations", instanceID))
+			Expect(response.StatusCode).To(Equal(http.StatusNotImplemented))
+		})
+
+		Context("when the operation journal is enabled", func() {
+			var journalDir string
+
+			BeforeEach(func() {
+				var err error
+				journalDir, err = ioutil.TempDir("", "operation-journal")
+				Expect(err).NotTo(HaveOccurred())
+				conf.Broker.OperationJournalPath = filepath.Join(journalDir, "operations.log")
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(journalDir)).To(Succeed())
+			})
+
+			It("responds with the operations recorded for the instance", func() {
+				fakeTaskBoshClient.GetDeploymentReturns(nil, true, nil)
+				fakeTaskBoshClient.DeployReturns(123, nil)
+				setupFakeGenerateManifestOutput()
+
+				response, _ := doProcessRequest(instanceID, fmt.Sprintf(`{"plan_id": "%s"}`, dedicatedPlanID), "upgrade")
+				Expect(response.StatusCode).To(Equal(http.StatusAccepted))
+
+				response, bodyContent := doGetRequest(fmt.Sprintf("service_instances/%s/operations", instanceID))
+				Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+				var operations []map[string]interface{}
+				Expect(json.Unmarshal(bodyContent, &operations)).To(Succeed())
+				Expect(operations).To(HaveLen(1))
+				Expect(operations[0]).To(SatisfyAll(
+					HaveKeyWithValue("id", "123"),
+					HaveKeyWithValue("service_instance_id", instanceID),
+					HaveKeyWithValue("operation_type", "upgrade"),
+					HaveKeyWithValue("plan_id", dedicatedPlanID),
+					HaveKeyWithValue("bosh_task_ids", []interface{}{123.0}),
+					HaveKeyWithValue("errands", []interface{}{"post-deploy-errand"}),
+					HaveKeyWithValue("state", "in progress"),
+				))
+			})
+		})
+	})
+
+	Describe("POST /mgmt/service_instances/:id/preview?operation_type=", func() {
+		const instanceID = "some-instance-id"
+
+		It("responds with the diff of the manifest and configs an upgrade would deploy", func() {
+			fakeTaskBoshClient.GetDeploymentReturns([]byte("name: service-instance_some-instance-id\nreleases: []\n"), true, nil)
+			setupFakeGenerateManifestOutput()
+
+			response, bodyContent := doRequest(
+				http.MethodPost,
+				fmt.Sprintf("http://%s/mgmt/service_instances/%s/preview?operation_type=upgrade", serverURL, instanceID),
+				strings.NewReader(fmt.Sprintf(`{"plan_id": "%s"}`, dedicatedPlanID)),
+			)
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+			var preview broker.DeploymentPreview
+			Expect(json.Unmarshal(bodyContent, &preview)).To(Succeed())
+			Expect(preview.ManifestDiff).To(Equal("--- deployed/manifest.yml\n+++ generated/manifest.yml\n@@ -1,2 +1,1 @@\n name: service-instance_some-instance-id\n-releases: []\n"))
+			Expect(preview.ConfigDiffs).To(HaveKeyWithValue("cloud", ContainSubstring("+foo: bar")))
+
+			By("not deploying anything")
+			Expect(fakeTaskBoshClient.DeployCallCount()).To(BeZero())
+			Expect(fakeTaskBoshClient.UpdateConfigCallCount()).To(BeZero())
+			Expect(fakeTaskBulkSetter.BulkSetCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("GET /mgmt/deployed_releases", func() {
+		It("responds with the releases deployed for each instance", func() {
+			fakeBoshClient.GetDeploymentsReturns([]boshdirector.Deployment{
+				{Name: "service-instance_one", Releases: []bosh.Release{{Name: "redis", Version: "1.2.3"}}},
+				{Name: "service-instance_two"},
+				{Name: "cf", Releases: []bosh.Release{{Name: "capi", Version: "1.0.0"}}},
+			}, nil)
+
+			response, bodyContent := doGetRequest("deployed_releases")
+
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(bodyContent).To(MatchJSON(`{"one": [{"name": "redis", "version": "1.2.3"}], "two": []}`))
+		})
+
+		It("responds with 500 when the deployments cannot be listed", func() {
+			fakeBoshClient.GetDeploymentsReturns(nil, errors.New("some bosh error"))
+
+			response, _ := doGetRequest("deployed_releases")
+
+			Expect(response.StatusCode).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
 	Describe("PATCH /mgmt/service_instances/:id?operation_type=", func() {
 		const (
 			instanceID = "some-instance-id"
@@ -315,7 +417,7 @@ var _ = Describe("Management API", func() {
 				Expect(response.StatusCode).To(Equal(http.StatusAccepted))
 
 				By("upgrades the correct instance")
-				input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+				_, input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 				actualInput, ok := input.(sdk.InputParams)
 				Expect(ok).To(BeTrue(), "command runner takes a sdk.inputparams obj")
 				Expect(actualOthers[1]).To(Equal("generate-manifest"))
@@ -358,7 +460,7 @@ var _ = Describe("Management API", func() {
 					Expect(response.StatusCode).To(Equal(http.StatusAccepted))
 
 					By("upgrades the correct instance")
-					input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+					_, input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 					actualInput, ok := input.(sdk.InputParams)
 					Expect(ok).To(BeTrue(), "command runner takes a sdk.inputparams obj")
 					Expect(actualOthers[1]).To(Equal("generate-manifest"))
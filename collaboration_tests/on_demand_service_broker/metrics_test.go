@@ -0,0 +1,86 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package on_demand_service_broker_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	brokerConfig "github.com/pivotal-cf/on-demand-service-broker/config"
+)
+
+var _ = Describe("GET /metrics", func() {
+	var conf brokerConfig.Config
+
+	BeforeEach(func() {
+		quota := 12
+		conf = brokerConfig.Config{
+			Broker: brokerConfig.Broker{
+				Port: serverPort, Username: brokerUsername, Password: brokerPassword,
+			},
+			ServiceCatalog: brokerConfig.ServiceOffering{
+				Name:         serviceName,
+				GlobalQuotas: brokerConfig.Quotas{ServiceInstanceLimit: &quota},
+				Plans: brokerConfig.Plans{
+					{
+						Name:   dedicatedPlanName,
+						ID:     dedicatedPlanID,
+						Quotas: brokerConfig.Quotas{ServiceInstanceLimit: &dedicatedPlanQuota},
+					},
+					{
+						Name: highMemoryPlanName,
+						ID:   highMemoryPlanID,
+					},
+				},
+			},
+		}
+
+		fakeCfClient.CountInstancesOfServiceOfferingReturns(map[cf.ServicePlan]int{
+			{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: dedicatedPlanID}}:  1,
+			{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: highMemoryPlanID}}: 4,
+		}, nil)
+	})
+
+	JustBeforeEach(func() {
+		StartServer(conf)
+	})
+
+	It("exposes fleet and request metrics in the Prometheus text format", func() {
+		catalogResp, _ := doRequest(http.MethodGet, fmt.Sprintf("http://%s/v2/catalog", serverURL), nil)
+		Expect(catalogResp.StatusCode).To(Equal(http.StatusOK))
+
+		response, bodyContent := doRequest(http.MethodGet, fmt.Sprintf("http://%s/metrics", serverURL), nil)
+
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+
+		body := string(bodyContent)
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="dedicated-plan-name",service="service-name"} 1`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="high-memory-plan-name",service="service-name"} 4`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances_quota_remaining{plan="dedicated-plan-name",service="service-name"} 0`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_total_instances{service="service-name"} 5`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_total_instances_quota_remaining{service="service-name"} 7`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_requests_total{code="200",method="GET",route="/v2/catalog"} 1`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_request_duration_seconds_count{method="GET",route="/v2/catalog"} 1`))
+	})
+
+	It("requires basic auth", func() {
+		response, err := http.Get(fmt.Sprintf("http://%s/metrics", serverURL))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+})
@@ -213,7 +213,7 @@ password: ((odb_secret:foo))`,
 
 			By("calling the deployer with the correct parameters")
 
-			input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+			_, input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 			actualInput, ok := input.(sdk.InputParams)
 			Expect(ok).To(BeTrue(), "command runner takes a sdk.inputparams obj")
 			Expect(actualOthers[1]).To(Equal("generate-manifest"))
@@ -656,7 +656,7 @@ func setupFakeGenerateManifestOutput() {
 }
 
 func getProvisionArgs() sdk.DashboardUrlJSONParams {
-	input, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(1)
+	_, input, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(1)
 	inputParams, ok := input.(sdk.InputParams)
 	Expect(ok).To(BeTrue(), "couldn't cast dashboard input to sdk.InputParams")
 	Expect(varArgs).To(HaveLen(2))
@@ -27,8 +27,9 @@ var _ = Describe("Secure Binding", func() {
 	)
 
 	var (
-		bindDetails brokerapi.BindDetails
-		expectedRef string
+		bindDetails     brokerapi.BindDetails
+		expectedRef     string
+		expectedURLsRef string
 	)
 
 	BeforeEach(func() {
@@ -63,6 +64,7 @@ var _ = Describe("Secure Binding", func() {
 		}
 
 		expectedRef = "/c/service-id/some-instance-id/some-binding-id/credentials"
+		expectedURLsRef = "/c/service-id/some-instance-id/some-binding-id/urls"
 
 		StartServer(conf)
 	})
@@ -87,15 +89,18 @@ var _ = Describe("Secure Binding", func() {
 
 			By("calling bind on the adapter")
 			Expect(fakeCommandRunner.RunWithInputParamsCallCount()).To(Equal(1))
-			_, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+			_, _, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 			Expect(varArgs).To(HaveLen(2))
 			Expect(varArgs[1]).To(Equal("create-binding"))
 
 			By("calling credhub")
-			Expect(fakeCredentialStore.SetCallCount()).To(Equal(1))
+			Expect(fakeCredentialStore.SetCallCount()).To(Equal(2))
 			key, credentials := fakeCredentialStore.SetArgsForCall(0)
 			Expect(key).To(Equal(expectedRef))
 			Expect(credentials).To(Equal(bindings.Credentials))
+			key, urls := fakeCredentialStore.SetArgsForCall(1)
+			Expect(key).To(Equal(expectedURLsRef))
+			Expect(urls).To(Equal(map[string]interface{}{"syslog_drain_url": "other.fqdn", "route_service_url": "some.fqdn"}))
 
 			Expect(fakeCredentialStore.AddPermissionCallCount()).To(Equal(1))
 			key, actor, ops := fakeCredentialStore.AddPermissionArgsForCall(0)
@@ -148,14 +153,14 @@ var _ = Describe("Secure Binding", func() {
 
 			By("calling bind on the adapter")
 			Expect(fakeCommandRunner.RunWithInputParamsCallCount()).To(Equal(1))
-			_, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+			_, _, varArgs := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 			Expect(varArgs).To(HaveLen(2))
 			Expect(varArgs[1]).To(Equal("delete-binding"))
 
 			By("calling credhub")
-			Expect(fakeCredentialStore.DeleteCallCount()).To(Equal(1))
-			key := fakeCredentialStore.DeleteArgsForCall(0)
-			Expect(key).To(Equal(expectedRef))
+			Expect(fakeCredentialStore.DeleteCallCount()).To(Equal(2))
+			Expect(fakeCredentialStore.DeleteArgsForCall(0)).To(Equal(expectedRef))
+			Expect(fakeCredentialStore.DeleteArgsForCall(1)).To(Equal(expectedURLsRef))
 
 			By("logging the bind request")
 			Eventually(loggerBuffer).Should(gbytes.Say(`removing credentials for instance ID`))
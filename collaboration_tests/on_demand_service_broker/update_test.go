@@ -169,6 +169,7 @@ var _ = Describe("Update a service instance", func() {
 			Expect(operationData).To(Equal(broker.OperationData{
 				OperationType: broker.OperationTypeUpdate,
 				BoshTaskID:    updateTaskID,
+				PlanID:        requestBody.PlanID,
 			}))
 
 			By("logging the update request")
@@ -189,6 +190,7 @@ var _ = Describe("Update a service instance", func() {
 			Expect(operationData).To(Equal(broker.OperationData{
 				OperationType: broker.OperationTypeUpdate,
 				BoshTaskID:    updateTaskID,
+				PlanID:        requestBody.PlanID,
 				BoshContextID: boshContextId,
 				Errands:       []brokerConfig.Errand{{Name: "health-check"}},
 			}))
@@ -216,6 +218,7 @@ var _ = Describe("Update a service instance", func() {
 			Expect(operationData).To(Equal(broker.OperationData{
 				OperationType: broker.OperationTypeUpdate,
 				BoshTaskID:    updateTaskID,
+				PlanID:        requestBody.PlanID,
 			}))
 
 			By("logging the update request")
@@ -288,7 +291,7 @@ var _ = Describe("Update a service instance", func() {
 			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
 
 			By("calling the adapter with the correct arguments", func() {
-				input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(1)
+				_, input, actualOthers := fakeCommandRunner.RunWithInputParamsArgsForCall(1)
 				actualInput, ok := input.(sdk.InputParams)
 				Expect(ok).To(BeTrue(), "command runner takes a sdk.inputparams obj")
 				Expect(actualOthers[1]).To(Equal("generate-manifest"))
@@ -326,6 +329,7 @@ var _ = Describe("Update a service instance", func() {
 			Expect(operationData).To(Equal(broker.OperationData{
 				OperationType: broker.OperationTypeUpdate,
 				BoshTaskID:    updateTaskID,
+				PlanID:        requestBody.PlanID,
 			}))
 
 			By("logging the update request")
@@ -461,7 +465,7 @@ properties:
 			It("sends the adapter previous BOSH configs", func() {
 				doUpdateRequest(requestBody, instanceID)
 
-				generateManifestInput, _ := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
+				_, generateManifestInput, _ := fakeCommandRunner.RunWithInputParamsArgsForCall(0)
 				actualInput, ok := generateManifestInput.(sdk.InputParams)
 				Expect(ok).To(BeTrue(), "command runner takes a sdk.inputparams obj")
 				Expect(actualInput.GenerateManifest.PreviousConfigs).To(Equal(`{"cloud":"{cloud_properties: { foo: bar }}"}`))
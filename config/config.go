@@ -12,10 +12,12 @@ import (
 	"io/ioutil"
 	"log"
 	"strings"
+	"time"
 
 	"net/http"
 
 	"github.com/pivotal-cf/on-demand-service-broker/authorizationheader"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 	"gopkg.in/yaml.v2"
 )
@@ -30,24 +32,46 @@ type Config struct {
 	ServiceDeployment   ServiceDeployment   `yaml:"service_deployment"`
 	ServiceCatalog      ServiceOffering     `yaml:"service_catalog"`
 	BoshCredhub         BoshCredhub         `yaml:"bosh_credhub"`
+
+	AdditionalServiceOfferings []AdditionalServiceOffering `yaml:"additional_service_offerings,omitempty"`
+}
+
+// AdditionalServiceOffering is a service offering the broker serves alongside
+// the one in service_catalog. Its service adapter defaults to the broker's
+// service_adapter.
+type AdditionalServiceOffering struct {
+	ServiceAdapter    *ServiceAdapter   `yaml:"service_adapter"`
+	ServiceDeployment ServiceDeployment `yaml:"service_deployment"`
+	ServiceCatalog    ServiceOffering   `yaml:"service_catalog"`
 }
 
 type Broker struct {
 	Port                       int
 	Username                   string
 	Password                   string
-	DisableSSLCertVerification bool `yaml:"disable_ssl_cert_verification"`
-	DisableBoshConfigs         bool `yaml:"disable_bosh_configs"`
-	StartUpBanner              bool `yaml:"startup_banner"`
-	ShutdownTimeoutSecs        int  `yaml:"shutdown_timeout_in_seconds"`
-	DisableCFStartupChecks     bool `yaml:"disable_cf_startup_checks"`
-	ExposeOperationalErrors    bool `yaml:"expose_operational_errors"`
-	EnablePlanSchemas          bool `yaml:"enable_plan_schemas"`
-	UsingStdin                 bool `yaml:"use_stdin"`
-	EnableSecureManifests      bool `yaml:"enable_secure_manifests"`
+	DisableSSLCertVerification bool   `yaml:"disable_ssl_cert_verification"`
+	DisableBoshConfigs         bool   `yaml:"disable_bosh_configs"`
+	StartUpBanner              bool   `yaml:"startup_banner"`
+	ShutdownTimeoutSecs        int    `yaml:"shutdown_timeout_in_seconds"`
+	DisableCFStartupChecks     bool   `yaml:"disable_cf_startup_checks"`
+	ExposeOperationalErrors    bool   `yaml:"expose_operational_errors"`
+	EnablePlanSchemas          bool   `yaml:"enable_plan_schemas"`
+	UsingStdin                 bool   `yaml:"use_stdin"`
+	EnableSecureManifests      bool   `yaml:"enable_secure_manifests"`
+	EnableAsyncBindings        bool   `yaml:"enable_async_bindings"`
+	OperationJournalPath       string `yaml:"operation_journal_path"`
+	InstanceRegistryPath       string `yaml:"instance_registry_path"`
+	LogFormat                  string `yaml:"log_format"`
+	RollbackFailedUpgrades     bool   `yaml:"rollback_failed_upgrades"`
+	Platform                   string `yaml:"platform"`
 	TLS                        TLSConfig
 }
 
+const (
+	PlatformCloudFoundry = "cloudfoundry"
+	PlatformKubernetes   = "kubernetes"
+)
+
 type BoshCredhub struct {
 	URL            string `yaml:"url"`
 	RootCACert     string `yaml:"root_ca_cert"`
@@ -97,17 +121,48 @@ func (c Config) Validate() error {
 		return err
 	}
 
+	if c.Broker.RollbackFailedUpgrades && (c.Broker.OperationJournalPath == "" || !c.HasRuntimeCredHub()) {
+		return errors.New("broker.rollback_failed_upgrades requires broker.operation_journal_path and credhub, where what an upgrade replaces is kept")
+	}
+
+	if c.Broker.EnableAsyncBindings && !c.HasRuntimeCredHub() {
+		return errors.New("broker.enable_async_bindings requires credhub, where the bindings are kept for the platform to fetch")
+	}
+
 	if err := c.Bosh.Validate(); err != nil {
 		return fmt.Errorf("BOSH configuration error: %s", err)
 	}
-	if !c.Broker.DisableCFStartupChecks {
+	if c.Broker.CloudFoundryPlatform() && !c.Broker.DisableCFStartupChecks {
 		if err := c.CF.Validate(); err != nil {
 			return fmt.Errorf("CF configuration error: %s", err.Error())
 		}
 	}
 
-	if err := checkIsExecutableFile(c.ServiceAdapter.Path); err != nil {
-		return fmt.Errorf("checking for executable service adapter file: %s", err)
+	for i, offering := range c.ServiceOfferings() {
+		if err := offering.validateServiceOffering(); err != nil {
+			if i > 0 {
+				return fmt.Errorf("additional_service_offerings[%d]: %s", i-1, err)
+			}
+			return err
+		}
+	}
+
+	return c.validateAdditionalServiceOfferings()
+}
+
+func (c Config) validateServiceOffering() error {
+	if !c.Broker.CloudFoundryPlatform() && c.ServiceInstancesAPI.URL == "" && c.Broker.InstanceRegistryPath == "" {
+		return fmt.Errorf("service_instances_api or instance_registry_path must be configured when broker.platform is %s", c.Broker.Platform)
+	}
+
+	if err := c.ServiceAdapter.Timeouts.Validate(); err != nil {
+		return err
+	}
+
+	if c.ServiceAdapter.Socket == "" {
+		if err := checkIsExecutableFile(c.ServiceAdapter.Path); err != nil {
+			return fmt.Errorf("checking for executable service adapter file: %s", err)
+		}
 	}
 
 	if err := c.ServiceDeployment.Validate(); err != nil {
@@ -121,6 +176,80 @@ func (c Config) Validate() error {
 	return nil
 }
 
+// validateAdditionalServiceOfferings checks that the service offerings can be
+// told apart. Each broker treats every deployment whose name starts with its
+// deployment prefix as one of its instances, so no prefix can start with
+// another.
+func (c Config) validateAdditionalServiceOfferings() error {
+	if len(c.AdditionalServiceOfferings) == 0 {
+		return nil
+	}
+
+	for i, additional := range c.AdditionalServiceOfferings {
+		if additional.ServiceCatalog.DeploymentPrefix == "" {
+			return fmt.Errorf("additional_service_offerings[%d]: service_catalog.deployment_prefix must be set", i)
+		}
+	}
+
+	offerings := c.ServiceOfferings()
+	serviceIDs := map[string]bool{}
+	serviceNames := map[string]bool{}
+	planIDs := map[string]bool{}
+	for i, offering := range offerings {
+		catalog := offering.ServiceCatalog
+		if serviceIDs[catalog.ID] {
+			return fmt.Errorf("service offering ID %s is used by more than one service offering", catalog.ID)
+		}
+		serviceIDs[catalog.ID] = true
+
+		if serviceNames[catalog.Name] {
+			return fmt.Errorf("service name %s is used by more than one service offering", catalog.Name)
+		}
+		serviceNames[catalog.Name] = true
+
+		for _, plan := range catalog.Plans {
+			if planIDs[plan.ID] {
+				return fmt.Errorf("plan ID %s is used by more than one plan", plan.ID)
+			}
+			planIDs[plan.ID] = true
+		}
+
+		for _, other := range offerings[:i] {
+			prefix, otherPrefix := catalog.DeploymentNamePrefix(), other.ServiceCatalog.DeploymentNamePrefix()
+			if strings.HasPrefix(prefix, otherPrefix) || strings.HasPrefix(otherPrefix, prefix) {
+				return fmt.Errorf(
+					"the deployment prefixes %q and %q of service offerings %s and %s overlap",
+					otherPrefix, prefix, other.ServiceCatalog.Name, catalog.Name,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ServiceOfferings returns the configuration of each service offering the
+// broker serves, starting with the one in service_catalog. Additional
+// offerings get their own service catalog, deployment and adapter, and their
+// own instance registry file when the instance registry is enabled.
+func (c Config) ServiceOfferings() []Config {
+	offerings := []Config{c}
+	for _, additional := range c.AdditionalServiceOfferings {
+		offering := c
+		offering.AdditionalServiceOfferings = nil
+		offering.ServiceCatalog = additional.ServiceCatalog
+		offering.ServiceDeployment = additional.ServiceDeployment
+		if additional.ServiceAdapter != nil {
+			offering.ServiceAdapter = *additional.ServiceAdapter
+		}
+		if c.Broker.InstanceRegistryPath != "" {
+			offering.Broker.InstanceRegistryPath = c.Broker.InstanceRegistryPath + "." + additional.ServiceCatalog.ID
+		}
+		offerings = append(offerings, offering)
+	}
+	return offerings
+}
+
 func (c Config) HasRuntimeCredHub() bool {
 	return c.CredHub != CredHub{}
 }
@@ -148,10 +277,24 @@ func (b Broker) Validate() error {
 	if b.Password == "" {
 		return errors.New("broker.password can't be empty")
 	}
+	if b.LogFormat != "" && b.LogFormat != loggerfactory.TextFormat && b.LogFormat != loggerfactory.JSONFormat {
+		return fmt.Errorf("broker.log_format must be %s or %s, got %q", loggerfactory.TextFormat, loggerfactory.JSONFormat, b.LogFormat)
+	}
+	if b.Platform != "" && b.Platform != PlatformCloudFoundry && b.Platform != PlatformKubernetes {
+		return fmt.Errorf("broker.platform must be %s or %s, got %q", PlatformCloudFoundry, PlatformKubernetes, b.Platform)
+	}
 
 	return nil
 }
 
+// CloudFoundryPlatform reports whether the broker serves Cloud Foundry, which
+// it does unless another platform is configured. Instances of other platforms
+// are listed and counted with the service instances API or the instance
+// registry.
+func (b Broker) CloudFoundryPlatform() bool {
+	return b.Platform == "" || b.Platform == PlatformCloudFoundry
+}
+
 type ServiceDeployment struct {
 	Releases serviceadapter.ServiceReleases
 	Stemcell serviceadapter.Stemcell
@@ -347,8 +490,66 @@ type CredHub struct {
 	InternalUAACaCert string `yaml:"internal_uaa_ca_cert"`
 }
 
+// ServiceAdapter configures how the broker runs the service adapter. When
+// Socket is set, the broker sends commands to a long-running adapter listening
+// for HTTP on that Unix socket instead of executing the adapter at Path.
 type ServiceAdapter struct {
-	Path string
+	Path     string
+	Socket   string                 `yaml:"socket"`
+	Timeouts ServiceAdapterTimeouts `yaml:"timeouts"`
+}
+
+// ServiceAdapterTimeouts are how many seconds each service adapter command may
+// run for before it is stopped. Commands without a configured timeout are given
+// a default one, and all commands are also stopped when the request they are
+// run for is cancelled.
+type ServiceAdapterTimeouts struct {
+	GenerateManifest    int `yaml:"generate_manifest"`
+	CreateBinding       int `yaml:"create_binding"`
+	DeleteBinding       int `yaml:"delete_binding"`
+	DashboardURL        int `yaml:"dashboard_url"`
+	GeneratePlanSchemas int `yaml:"generate_plan_schemas"`
+}
+
+const (
+	DefaultGenerateManifestTimeout = 10 * time.Minute
+	DefaultServiceAdapterTimeout   = time.Minute
+)
+
+// ByCommand returns the timeouts keyed by the adapter command they apply to.
+func (t ServiceAdapterTimeouts) ByCommand() map[string]time.Duration {
+	return map[string]time.Duration{
+		"generate-manifest":     timeoutOrDefault(t.GenerateManifest, DefaultGenerateManifestTimeout),
+		"create-binding":        timeoutOrDefault(t.CreateBinding, DefaultServiceAdapterTimeout),
+		"delete-binding":        timeoutOrDefault(t.DeleteBinding, DefaultServiceAdapterTimeout),
+		"dashboard-url":         timeoutOrDefault(t.DashboardURL, DefaultServiceAdapterTimeout),
+		"generate-plan-schemas": timeoutOrDefault(t.GeneratePlanSchemas, DefaultServiceAdapterTimeout),
+	}
+}
+
+func timeoutOrDefault(seconds int, defaultTimeout time.Duration) time.Duration {
+	if seconds == 0 {
+		return defaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (t ServiceAdapterTimeouts) Validate() error {
+	for command, timeout := range t.ByCommand() {
+		if timeout < 0 {
+			return fmt.Errorf("service_adapter.timeouts: the timeout of %s cannot be negative", command)
+		}
+	}
+	return nil
+}
+
+// Location is where the broker reaches the service adapter, as reported in
+// adapter errors.
+func (s ServiceAdapter) Location() string {
+	if s.Socket != "" {
+		return s.Socket
+	}
+	return s.Path
 }
 
 func Parse(configFilePath string) (Config, error) {
@@ -388,6 +589,21 @@ type ServiceOffering struct {
 	GlobalQuotas     Quotas                    `yaml:"global_quotas"`
 	Plans            Plans
 	MaintenanceInfo  *MaintenanceInfo `yaml:"maintenance_info,omitempty"`
+
+	// DeploymentPrefix is prepended to the instance ID to name the BOSH
+	// deployment of each instance of the offering.
+	DeploymentPrefix string `yaml:"deployment_prefix,omitempty"`
+}
+
+// DefaultDeploymentPrefix names the deployments of a service offering that
+// does not set its own deployment prefix.
+const DefaultDeploymentPrefix = "service-instance_"
+
+func (s ServiceOffering) DeploymentNamePrefix() string {
+	if s.DeploymentPrefix != "" {
+		return s.DeploymentPrefix
+	}
+	return DefaultDeploymentPrefix
 }
 
 func (s ServiceOffering) FindPlanByID(id string) (Plan, bool) {
@@ -401,6 +617,9 @@ func (s ServiceOffering) HasLifecycleErrands() bool {
 				return true
 			}
 		}
+		if len(plan.PreUpgradeErrands()) > 0 || len(plan.PreRecreateErrands()) > 0 {
+			return true
+		}
 	}
 
 	return false
@@ -408,6 +627,39 @@ func (s ServiceOffering) HasLifecycleErrands() bool {
 
 func (s ServiceOffering) Validate() error {
 	for _, plan := range s.Plans {
+		for _, errands := range [][]Errand{plan.PostDeployErrands(), plan.PreDeleteErrands()} {
+			for _, errand := range errands {
+				if errand.Retries < 0 || errand.Backoff < 0 {
+					return fmt.Errorf("Lifecycle errand '%s' must not have negative retries or backoff", errand.Name)
+				}
+			}
+		}
+		for _, errands := range [][]Errand{plan.PreUpgradeErrands(), plan.PreRecreateErrands()} {
+			for _, errand := range errands {
+				if errand.Retries < 0 || errand.Backoff < 0 {
+					return fmt.Errorf("Lifecycle errand '%s' must not have negative retries or backoff", errand.Name)
+				}
+				if err := s.validateLifecycleErrands(serviceadapter.Errand{Name: errand.Name, Instances: errand.Instances}); err != nil {
+					return err
+				}
+			}
+		}
+		switch plan.SharedBindingAccess {
+		case "", SharedBindingAccessReadWrite, SharedBindingAccessReadOnly, SharedBindingAccessDenied:
+		default:
+			return fmt.Errorf("Plan '%s' has invalid shared_binding_access '%s', must be one of %s, %s or %s",
+				plan.Name, plan.SharedBindingAccess, SharedBindingAccessReadWrite, SharedBindingAccessReadOnly, SharedBindingAccessDenied)
+		}
+		if plan.BackupErrands != nil {
+			for _, errand := range []Errand{plan.BackupErrands.Backup, plan.BackupErrands.Restore} {
+				if errand.Name == "" {
+					return fmt.Errorf("Plan '%s' must name both a backup and a restore errand", plan.Name)
+				}
+				if err := s.validateLifecycleErrands(serviceadapter.Errand{Name: errand.Name, Instances: errand.Instances}); err != nil {
+					return err
+				}
+			}
+		}
 		if plan.LifecycleErrands != nil {
 			for _, errand := range plan.LifecycleErrands.PostDeploy {
 				if err := s.validateLifecycleErrands(errand); err != nil {
@@ -471,6 +723,121 @@ type Plan struct {
 	ResourceCosts    map[string]int                   `yaml:"resource_costs,omitempty"`
 	BindingWithDNS   []BindingDNS                     `yaml:"binding_with_dns"`
 	MaintenanceInfo  *MaintenanceInfo                 `yaml:"maintenance_info,omitempty"`
+	BackupErrands    *BackupErrands                   `yaml:"backup_errands,omitempty"`
+
+	// SharedBindingAccess is the access granted to bindings made from a space
+	// the instance has been shared with. It defaults to read-write.
+	SharedBindingAccess SharedBindingAccess `yaml:"shared_binding_access,omitempty"`
+
+	// LifecycleErrandRetries is read from the lifecycle_errands entries
+	// alongside each errand's name and instances.
+	LifecycleErrandRetries LifecycleErrandRetries `yaml:"-"`
+
+	// PreOperationErrands is read from the pre_upgrade and pre_recreate
+	// entries of lifecycle_errands, which the adapter SDK does not know about.
+	PreOperationErrands PreOperationErrands `yaml:"-"`
+}
+
+type SharedBindingAccess string
+
+const (
+	SharedBindingAccessReadWrite = SharedBindingAccess("read-write")
+	SharedBindingAccessReadOnly  = SharedBindingAccess("read-only")
+	SharedBindingAccessDenied    = SharedBindingAccess("denied")
+)
+
+// BindingAccess is the access granted to a binding, depending on whether it
+// is made from a space the instance has been shared with.
+func (p Plan) BindingAccess(shared bool) SharedBindingAccess {
+	if !shared || p.SharedBindingAccess == "" {
+		return SharedBindingAccessReadWrite
+	}
+	return p.SharedBindingAccess
+}
+
+// BackupErrands are run on demand through the management API to back up and
+// restore an instance. The backup errand must print a reference to the
+// artefact it produced as the last line of its standard output. BOSH errands
+// take no arguments, so the broker keeps the artefact to restore in CredHub
+// under /c/<service ID>/<instance ID>/restore_artefact for the restore errand
+// to read.
+type BackupErrands struct {
+	Backup  Errand `yaml:"backup"`
+	Restore Errand `yaml:"restore"`
+}
+
+// PreOperationErrands run, in order, before an upgrade or recreate of a
+// deployment. If one of them fails the operation is abandoned.
+type PreOperationErrands struct {
+	PreUpgrade  []Errand
+	PreRecreate []Errand
+}
+
+// LifecycleErrandRetries holds the retry settings of a plan's lifecycle
+// errands, in the same order as the errands, or nil if none of them has
+// retries. An errand can be listed more than once, so the settings are matched
+// by position rather than by name.
+type LifecycleErrandRetries struct {
+	PostDeploy []ErrandRetries
+	PreDelete  []ErrandRetries
+}
+
+// ErrandRetries configures how often a failed lifecycle errand is run again.
+// Backoff is the number of seconds to wait before the first retry, and is
+// doubled before each subsequent retry, up to MaxErrandRetryBackoff.
+type ErrandRetries struct {
+	Retries int `yaml:"retries"`
+	Backoff int `yaml:"backoff"`
+}
+
+func (p *Plan) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plan Plan
+	if err := unmarshal((*plan)(p)); err != nil {
+		return err
+	}
+
+	var errands struct {
+		LifecycleErrands struct {
+			PostDeploy  []namedErrandRetries `yaml:"post_deploy"`
+			PreDelete   []namedErrandRetries `yaml:"pre_delete"`
+			PreUpgrade  []Errand             `yaml:"pre_upgrade"`
+			PreRecreate []Errand             `yaml:"pre_recreate"`
+		} `yaml:"lifecycle_errands"`
+	}
+	if err := unmarshal(&errands); err != nil {
+		return err
+	}
+
+	p.PreOperationErrands = PreOperationErrands{
+		PreUpgrade:  errands.LifecycleErrands.PreUpgrade,
+		PreRecreate: errands.LifecycleErrands.PreRecreate,
+	}
+
+	p.LifecycleErrandRetries = LifecycleErrandRetries{
+		PostDeploy: errandRetries(errands.LifecycleErrands.PostDeploy),
+		PreDelete:  errandRetries(errands.LifecycleErrands.PreDelete),
+	}
+
+	return nil
+}
+
+type namedErrandRetries struct {
+	Name          string `yaml:"name"`
+	ErrandRetries `yaml:",inline"`
+}
+
+func errandRetries(errands []namedErrandRetries) []ErrandRetries {
+	var retries []ErrandRetries
+	for i, errand := range errands {
+		if errand.ErrandRetries == (ErrandRetries{}) {
+			continue
+		}
+		if retries == nil {
+			retries = make([]ErrandRetries, len(errands))
+		}
+		retries[i] = errand.ErrandRetries
+	}
+	return retries
 }
 
 func (p Plan) AdapterPlan(globalProperties serviceadapter.Properties) serviceadapter.Plan {
@@ -502,8 +869,8 @@ func (p Plan) PostDeployErrands() []Errand {
 	var errands []Errand
 
 	if p.LifecycleErrands != nil {
-		for _, errand := range p.LifecycleErrands.PostDeploy {
-			errands = append(errands, Errand(errand))
+		for i, errand := range p.LifecycleErrands.PostDeploy {
+			errands = append(errands, newErrand(errand, retriesAt(p.LifecycleErrandRetries.PostDeploy, i)))
 		}
 	}
 
@@ -511,22 +878,74 @@ func (p Plan) PostDeployErrands() []Errand {
 }
 
 type Errand struct {
-	Name      string
-	Instances []string
+	Name      string   `yaml:"name"`
+	Instances []string `yaml:"instances,omitempty"`
+	Retries   int      `json:",omitempty" yaml:"retries,omitempty"`
+	Backoff   int      `json:",omitempty" yaml:"backoff,omitempty"`
+}
+
+func retriesAt(retries []ErrandRetries, i int) ErrandRetries {
+	if i < len(retries) {
+		return retries[i]
+	}
+	return ErrandRetries{}
+}
+
+func newErrand(errand serviceadapter.Errand, retries ErrandRetries) Errand {
+	return Errand{
+		Name:      errand.Name,
+		Instances: errand.Instances,
+		Retries:   retries.Retries,
+		Backoff:   retries.Backoff,
+	}
+}
+
+// Attempts is the number of times the errand is run before it is reported as
+// failed.
+func (e Errand) Attempts() int {
+	return e.Retries + 1
+}
+
+// MaxErrandRetryBackoff caps the wait between attempts of an errand, however
+// many times its backoff has been doubled.
+const MaxErrandRetryBackoff = time.Hour
+
+// RetryBackoff is how long to wait after the given failed attempt before
+// running the errand again.
+func (e Errand) RetryBackoff(failedAttempt int) time.Duration {
+	if e.Backoff >= int(MaxErrandRetryBackoff/time.Second) {
+		return MaxErrandRetryBackoff
+	}
+	backoff := time.Duration(e.Backoff) * time.Second
+	for i := 1; i < failedAttempt && backoff < MaxErrandRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxErrandRetryBackoff {
+		return MaxErrandRetryBackoff
+	}
+	return backoff
 }
 
 func (p Plan) PreDeleteErrands() []Errand {
 	var errands []Errand
 
 	if p.LifecycleErrands != nil {
-		for _, errand := range p.LifecycleErrands.PreDelete {
-			errands = append(errands, Errand(errand))
+		for i, errand := range p.LifecycleErrands.PreDelete {
+			errands = append(errands, newErrand(errand, retriesAt(p.LifecycleErrandRetries.PreDelete, i)))
 		}
 	}
 
 	return errands
 }
 
+func (p Plan) PreUpgradeErrands() []Errand {
+	return p.PreOperationErrands.PreUpgrade
+}
+
+func (p Plan) PreRecreateErrands() []Errand {
+	return p.PreOperationErrands.PreRecreate
+}
+
 type PlanMetadata struct {
 	DisplayName        string                 `yaml:"display_name"`
 	Bullets            []string               `yaml:"bullets,omitempty"`
@@ -571,21 +990,144 @@ func (filter CanarySelectionParams) String() string {
 	return strings.Join(filters, ", ")
 }
 
+// InstanceSelectionFilters restricts which service instances an instance
+// iterator processes. An instance is selected when it matches every filter
+// that is set and none of the exclusions.
+type InstanceSelectionFilters struct {
+	PlanIDs              []string        `yaml:"plan_ids"`
+	CFOrg                string          `yaml:"cf_org"`
+	CFSpace              string          `yaml:"cf_space"`
+	InstanceGUIDs        []string        `yaml:"instance_guids"`
+	Releases             []ReleaseFilter `yaml:"releases"`
+	ExcludeInstanceGUIDs []string        `yaml:"exclude_instance_guids"`
+	ExcludePlanIDs       []string        `yaml:"exclude_plan_ids"`
+}
+
+// ReleaseFilter matches instances whose deployed manifest contains the named
+// release at the given version.
+type ReleaseFilter struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+func (f InstanceSelectionFilters) IsEmpty() bool {
+	return len(f.PlanIDs) == 0 &&
+		f.CFOrg == "" &&
+		f.CFSpace == "" &&
+		len(f.InstanceGUIDs) == 0 &&
+		len(f.Releases) == 0 &&
+		len(f.ExcludeInstanceGUIDs) == 0 &&
+		len(f.ExcludePlanIDs) == 0
+}
+
+func (f InstanceSelectionFilters) String() string {
+	filters := []string{}
+	if len(f.PlanIDs) > 0 {
+		filters = append(filters, fmt.Sprintf("plan_ids: [%s]", strings.Join(f.PlanIDs, ", ")))
+	}
+	if f.CFOrg != "" {
+		filters = append(filters, fmt.Sprintf("cf_org: %s", f.CFOrg))
+	}
+	if f.CFSpace != "" {
+		filters = append(filters, fmt.Sprintf("cf_space: %s", f.CFSpace))
+	}
+	if len(f.InstanceGUIDs) > 0 {
+		filters = append(filters, fmt.Sprintf("instance_guids: [%s]", strings.Join(f.InstanceGUIDs, ", ")))
+	}
+	if len(f.Releases) > 0 {
+		releases := []string{}
+		for _, release := range f.Releases {
+			releases = append(releases, fmt.Sprintf("%s/%s", release.Name, release.Version))
+		}
+		filters = append(filters, fmt.Sprintf("releases: [%s]", strings.Join(releases, ", ")))
+	}
+	if len(f.ExcludeInstanceGUIDs) > 0 {
+		filters = append(filters, fmt.Sprintf("exclude_instance_guids: [%s]", strings.Join(f.ExcludeInstanceGUIDs, ", ")))
+	}
+	if len(f.ExcludePlanIDs) > 0 {
+		filters = append(filters, fmt.Sprintf("exclude_plan_ids: [%s]", strings.Join(f.ExcludePlanIDs, ", ")))
+	}
+	return strings.Join(filters, ", ")
+}
+
+// MaintenanceWindows restricts when an instance iterator may operate on a
+// service instance. The most specific window applies: an instance window
+// overrides an org and space window, which overrides an org window, which
+// overrides the default. Instances without a window may be processed at any
+// time.
+type MaintenanceWindows struct {
+	Default   *MaintenanceWindow          `yaml:"default"`
+	Orgs      []OrgMaintenanceWindow      `yaml:"orgs"`
+	Instances []InstanceMaintenanceWindow `yaml:"instances"`
+}
+
+// MaintenanceWindow opens at Start (HH:MM) on each of Days, or every day when
+// Days is empty, and stays open for Duration.
+type MaintenanceWindow struct {
+	Days     []string `yaml:"days"`
+	Start    string   `yaml:"start"`
+	Duration string   `yaml:"duration"`
+	Timezone string   `yaml:"timezone"`
+}
+
+type OrgMaintenanceWindow struct {
+	CFOrg             string `yaml:"cf_org"`
+	CFSpace           string `yaml:"cf_space"`
+	MaintenanceWindow `yaml:",inline"`
+}
+
+type InstanceMaintenanceWindow struct {
+	InstanceGUID      string `yaml:"instance_guid"`
+	MaintenanceWindow `yaml:",inline"`
+}
+
+func (w MaintenanceWindows) IsEmpty() bool {
+	return w.Default == nil && len(w.Orgs) == 0 && len(w.Instances) == 0
+}
+
+func (w MaintenanceWindow) String() string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ", ")
+	}
+	timezone := w.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return fmt.Sprintf("%s at %s %s for %s", days, w.Start, timezone, w.Duration)
+}
+
 type InstanceIteratorConfig struct {
-	BrokerAPI             BrokerAPI             `yaml:"broker_api"`
-	ServiceInstancesAPI   ServiceInstancesAPI   `yaml:"service_instances_api"`
-	PollingInterval       int                   `yaml:"polling_interval"`
-	AttemptInterval       int                   `yaml:"attempt_interval"`
-	AttemptLimit          int                   `yaml:"attempt_limit"`
-	RequestTimeout        int                   `yaml:"request_timeout"`
-	MaxInFlight           int                   `yaml:"max_in_flight"`
-	Canaries              int                   `yaml:"canaries"`
-	CanarySelectionParams CanarySelectionParams `yaml:"canary_selection_params"`
+	BrokerAPI             BrokerAPI                `yaml:"broker_api"`
+	ServiceInstancesAPI   ServiceInstancesAPI      `yaml:"service_instances_api"`
+	PollingInterval       int                      `yaml:"polling_interval"`
+	AttemptInterval       int                      `yaml:"attempt_interval"`
+	AttemptLimit          int                      `yaml:"attempt_limit"`
+	RequestTimeout        int                      `yaml:"request_timeout"`
+	MaxInFlight           int                      `yaml:"max_in_flight"`
+	Canaries              int                      `yaml:"canaries"`
+	CanarySelectionParams CanarySelectionParams    `yaml:"canary_selection_params"`
+	CheckpointPath        string                   `yaml:"checkpoint_path"`
+	ControlFilePath       string                   `yaml:"control_file_path"`
+	SelectionFilters      InstanceSelectionFilters `yaml:"selection_filters"`
+	MaintenanceWindows    MaintenanceWindows       `yaml:"maintenance_windows"`
 }
 
+// BrokerAPI is how errands reach the broker. When the broker serves several
+// service offerings, ServiceOfferingID is the offering the errand is for.
 type BrokerAPI struct {
-	URL            string         `yaml:"url"`
-	Authentication Authentication `yaml:"authentication"`
+	URL               string         `yaml:"url"`
+	Authentication    Authentication `yaml:"authentication"`
+	ServiceOfferingID string         `yaml:"service_offering_id"`
+}
+
+// ServiceOfferingURL is the URL errands send requests to: that of the
+// broker's routes for ServiceOfferingID when it is set, or else URL.
+func (b BrokerAPI) ServiceOfferingURL() string {
+	if b.ServiceOfferingID == "" {
+		return b.URL
+	}
+	return strings.TrimSuffix(b.URL, "/") + "/service_offerings/" + b.ServiceOfferingID
 }
 
 type ServiceInstancesAPI struct {
@@ -610,3 +1152,84 @@ type BindingDNSProperties struct {
 type OrphanDeploymentsErrandConfig struct {
 	BrokerAPI BrokerAPI `yaml:"broker_api"`
 }
+
+// CleanupOrphanDeploymentsErrandConfig configures the errand that deletes
+// orphan deployments. An orphan is only deleted once it has been seen in
+// MinimumSightings consecutive runs or was first seen at least
+// MinimumAgeInSeconds ago, which the errand tracks in the file at StatePath.
+// DeploymentPrefix is that of the service offering broker_api is for, and
+// defaults to DefaultDeploymentPrefix.
+type CleanupOrphanDeploymentsErrandConfig struct {
+	BrokerAPI           BrokerAPI `yaml:"broker_api"`
+	StatePath           string    `yaml:"state_path"`
+	MinimumSightings    int       `yaml:"minimum_sightings"`
+	MinimumAgeInSeconds int       `yaml:"minimum_age_in_seconds"`
+	RunPreDeleteErrands bool      `yaml:"run_pre_delete_errands"`
+	PollingInterval     int       `yaml:"polling_interval"`
+	DeploymentPrefix    string    `yaml:"deployment_prefix"`
+
+	// DeletionTimeoutInSeconds bounds how long the errand waits for each
+	// deletion. It defaults to DefaultOrphanDeletionTimeout.
+	DeletionTimeoutInSeconds int `yaml:"deletion_timeout_in_seconds"`
+
+	// RunTimeoutInSeconds bounds how long the errand waits for deletions in
+	// total. It defaults to DefaultOrphanCleanupRunTimeout.
+	RunTimeoutInSeconds int `yaml:"run_timeout_in_seconds"`
+}
+
+const (
+	DefaultOrphanDeletionTimeout   = time.Hour
+	DefaultOrphanCleanupRunTimeout = 4 * time.Hour
+)
+
+// DeletionTimeout is how long the errand waits for each deletion to finish.
+func (c CleanupOrphanDeploymentsErrandConfig) DeletionTimeout() time.Duration {
+	if c.DeletionTimeoutInSeconds == 0 {
+		return DefaultOrphanDeletionTimeout
+	}
+	return time.Duration(c.DeletionTimeoutInSeconds) * time.Second
+}
+
+// RunTimeout is how long the errand waits for deletions in total.
+func (c CleanupOrphanDeploymentsErrandConfig) RunTimeout() time.Duration {
+	if c.RunTimeoutInSeconds == 0 {
+		return DefaultOrphanCleanupRunTimeout
+	}
+	return time.Duration(c.RunTimeoutInSeconds) * time.Second
+}
+
+func (c CleanupOrphanDeploymentsErrandConfig) Validate() error {
+	if c.StatePath == "" {
+		return errors.New("state_path must be set")
+	}
+	if c.MinimumSightings < 0 || c.MinimumAgeInSeconds < 0 {
+		return errors.New("minimum_sightings and minimum_age_in_seconds cannot be negative")
+	}
+	if c.MinimumSightings == 0 && c.MinimumAgeInSeconds == 0 {
+		return errors.New("at least one of minimum_sightings or minimum_age_in_seconds must be set")
+	}
+	if c.PollingInterval <= 0 {
+		return errors.New("polling_interval must be greater than zero")
+	}
+	if c.DeletionTimeoutInSeconds < 0 {
+		return errors.New("deletion_timeout_in_seconds cannot be negative")
+	}
+	if c.RunTimeoutInSeconds < 0 {
+		return errors.New("run_timeout_in_seconds cannot be negative")
+	}
+	return nil
+}
+
+type ReconcileErrandConfig struct {
+	BrokerAPI BrokerAPI        `yaml:"broker_api"`
+	Repairs   ReconcileRepairs `yaml:"repairs"`
+}
+
+// ReconcileRepairs are the repairs the reconcile errand asks the broker to
+// make. Orphan deployments are only deleted when named, so that an operator
+// has confirmed each one, and their pre-delete errands run when asked to.
+type ReconcileRepairs struct {
+	RedeployPlanMismatches bool     `yaml:"redeploy_plan_mismatches"`
+	DeleteOrphans          []string `yaml:"delete_orphans"`
+	RunPreDeleteErrands    bool     `yaml:"run_pre_delete_errands"`
+}
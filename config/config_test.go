@@ -10,8 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"time"
 
 	"net/http"
 
@@ -226,6 +228,10 @@ var _ = Describe("Broker Config", func() {
 				Expect(conf.Broker.ExposeOperationalErrors).To(BeTrue())
 				Expect(conf.Broker.EnablePlanSchemas).To(BeTrue())
 				Expect(conf.Broker.EnableSecureManifests).To(BeTrue())
+				Expect(conf.Broker.EnableAsyncBindings).To(BeTrue())
+				Expect(conf.CredHub.APIURL).To(Equal("https://credhub:8844"))
+				Expect(conf.Broker.OperationJournalPath).To(Equal("/var/vcap/store/broker/operations.log"))
+				Expect(conf.Broker.LogFormat).To(Equal("json"))
 				Expect(conf.BoshCredhub.URL).To(Equal("https://bosh-credhub:8844/api/"))
 				Expect(conf.BoshCredhub.RootCACert).To(Equal("CERT"))
 				Expect(conf.BoshCredhub.Authentication.UAA.ClientCredentials.ID).To(Equal("credhub_id"))
@@ -367,6 +373,85 @@ var _ = Describe("Broker Config", func() {
 			})
 		})
 
+		Context("when the configuration contains a service adapter socket", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_adapter_socket.yml"
+			})
+
+			It("does not require an executable service adapter", func() {
+				Expect(parseErr).NotTo(HaveOccurred())
+				Expect(conf.ServiceAdapter.Socket).To(Equal("/var/vcap/sys/run/service-adapter/adapter.sock"))
+				Expect(conf.ServiceAdapter.Location()).To(Equal("/var/vcap/sys/run/service-adapter/adapter.sock"))
+			})
+		})
+
+		Context("when the configuration contains additional service offerings", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_additional_service_offerings.yml"
+			})
+
+			It("returns the configuration of each service offering", func() {
+				Expect(parseErr).NotTo(HaveOccurred())
+
+				offerings := conf.ServiceOfferings()
+				Expect(offerings).To(HaveLen(3))
+
+				Expect(offerings[0].ServiceCatalog.ID).To(Equal("some-id"))
+				Expect(offerings[0].ServiceCatalog.DeploymentNamePrefix()).To(Equal("service-instance_"))
+				Expect(offerings[0].Broker.InstanceRegistryPath).To(Equal("/var/vcap/store/broker/instances.json"))
+
+				Expect(offerings[1].ServiceCatalog.ID).To(Equal("kafka-id"))
+				Expect(offerings[1].ServiceCatalog.DeploymentNamePrefix()).To(Equal("kafka-instance_"))
+				Expect(offerings[1].ServiceDeployment.Releases[0].Name).To(Equal("kafka"))
+				Expect(offerings[1].ServiceAdapter.Socket).To(Equal("/var/vcap/sys/run/kafka-adapter/adapter.sock"))
+				Expect(offerings[1].Broker.InstanceRegistryPath).To(Equal("/var/vcap/store/broker/instances.json.kafka-id"))
+				Expect(offerings[1].AdditionalServiceOfferings).To(BeEmpty())
+
+				Expect(offerings[2].ServiceCatalog.ID).To(Equal("rabbitmq-id"))
+				Expect(offerings[2].ServiceAdapter.Socket).To(Equal("/var/vcap/sys/run/service-adapter/adapter.sock"))
+			})
+		})
+
+		Context("when the configuration contains an unknown log format", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_invalid_log_format.yml"
+			})
+
+			It("returns an error", func() {
+				Expect(parseErr).To(MatchError(`broker.log_format must be text or json, got "xml"`))
+			})
+		})
+
+		Context("when the configuration contains an unknown platform", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_invalid_platform.yml"
+			})
+
+			It("returns an error", func() {
+				Expect(parseErr).To(MatchError(`broker.platform must be cloudfoundry or kubernetes, got "openstack"`))
+			})
+		})
+
+		Context("when upgrades are rolled back without an operation journal or credhub", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_rollback_and_no_operation_journal.yml"
+			})
+
+			It("returns an error", func() {
+				Expect(parseErr).To(MatchError("broker.rollback_failed_upgrades requires broker.operation_journal_path and credhub, where what an upgrade replaces is kept"))
+			})
+		})
+
+		Context("when bindings are asynchronous without credhub", func() {
+			BeforeEach(func() {
+				configFileName = "config_with_async_bindings_and_no_credhub.yml"
+			})
+
+			It("returns an error", func() {
+				Expect(parseErr).To(MatchError("broker.enable_async_bindings requires credhub, where the bindings are kept for the platform to fetch"))
+			})
+		})
+
 		Context("BOSH configuration", func() {
 			Context("when the configuration does not specify a BOSH url", func() {
 				BeforeEach(func() {
@@ -509,6 +594,39 @@ var _ = Describe("Broker Config", func() {
 					Expect(parseErr).NotTo(HaveOccurred())
 				})
 			})
+
+			Context("and the platform is kubernetes", func() {
+				BeforeEach(func() {
+					configFileName = "kubernetes_platform_config.yml"
+				})
+
+				It("returns an error as instances cannot be listed", func() {
+					Expect(parseErr).To(MatchError("service_instances_api or instance_registry_path must be configured when broker.platform is kubernetes"))
+				})
+
+				Context("and the service instances API is configured", func() {
+					BeforeEach(func() {
+						configFileName = "kubernetes_platform_with_siapi_config.yml"
+					})
+
+					It("succeeds", func() {
+						Expect(parseErr).NotTo(HaveOccurred())
+						Expect(conf.Broker.Platform).To(Equal(config.PlatformKubernetes))
+						Expect(conf.Broker.CloudFoundryPlatform()).To(BeFalse())
+					})
+				})
+
+				Context("and the instance registry is configured", func() {
+					BeforeEach(func() {
+						configFileName = "kubernetes_platform_with_registry_config.yml"
+					})
+
+					It("succeeds", func() {
+						Expect(parseErr).NotTo(HaveOccurred())
+						Expect(conf.Broker.InstanceRegistryPath).To(Equal("/var/vcap/store/broker/instances.json"))
+					})
+				})
+			})
 		})
 
 		Context("free flag is not specified", func() {
@@ -908,6 +1026,158 @@ var _ = Describe("ServiceOffering", func() {
 		})
 
 	})
+
+	Context("lifecycle errand retries", func() {
+		var plan config.Plan
+
+		BeforeEach(func() {
+			plan = config.Plan{}
+			err := yaml.Unmarshal([]byte(`
+plan_id: some-plan
+lifecycle_errands:
+  post_deploy:
+  - name: smoke-tests
+    instances: [redis-server/0]
+    retries: 2
+    backoff: 30
+  - name: register
+  - name: smoke-tests
+    retries: 1
+  pre_delete:
+  - name: smoke-tests
+    retries: 1
+`), &plan)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads the retries alongside each errand", func() {
+			Expect(plan.PostDeployErrands()).To(Equal([]config.Errand{
+				{Name: "smoke-tests", Instances: []string{"redis-server/0"}, Retries: 2, Backoff: 30},
+				{Name: "register"},
+				{Name: "smoke-tests", Retries: 1},
+			}))
+			Expect(plan.PreDeleteErrands()).To(Equal([]config.Errand{
+				{Name: "smoke-tests", Retries: 1},
+			}))
+		})
+
+		It("still passes the errands to the adapter", func() {
+			Expect(plan.LifecycleErrands.PostDeploy).To(Equal([]serviceadapter.Errand{
+				{Name: "smoke-tests", Instances: []string{"redis-server/0"}},
+				{Name: "register"},
+				{Name: "smoke-tests"},
+			}))
+		})
+
+		It("doubles the backoff before each retry", func() {
+			errand := plan.PostDeployErrands()[0]
+			Expect(errand.Attempts()).To(Equal(3))
+			Expect(errand.RetryBackoff(1)).To(Equal(30 * time.Second))
+			Expect(errand.RetryBackoff(2)).To(Equal(60 * time.Second))
+		})
+
+		It("caps the backoff", func() {
+			errand := config.Errand{Name: "smoke-tests", Retries: 100, Backoff: 30}
+			Expect(errand.RetryBackoff(8)).To(Equal(config.MaxErrandRetryBackoff))
+			Expect(errand.RetryBackoff(100)).To(Equal(config.MaxErrandRetryBackoff))
+
+			errand.Backoff = math.MaxInt32
+			Expect(errand.RetryBackoff(1)).To(Equal(config.MaxErrandRetryBackoff))
+		})
+
+		It("rejects negative retries", func() {
+			plan.LifecycleErrandRetries.PreDelete[0] = config.ErrandRetries{Retries: -1}
+			offering := config.ServiceOffering{Plans: []config.Plan{plan}}
+			Expect(offering.Validate()).To(MatchError("Lifecycle errand 'smoke-tests' must not have negative retries or backoff"))
+		})
+	})
+
+	Context("pre-operation errands", func() {
+		var plan config.Plan
+
+		BeforeEach(func() {
+			plan = config.Plan{}
+			err := yaml.Unmarshal([]byte(`
+plan_id: some-plan
+lifecycle_errands:
+  pre_upgrade:
+  - name: backup
+    instances: [redis-server/0]
+    retries: 1
+  pre_recreate:
+  - name: drain
+  post_deploy:
+  - name: smoke-tests
+`), &plan)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads the pre-upgrade and pre-recreate errands", func() {
+			Expect(plan.PreUpgradeErrands()).To(Equal([]config.Errand{
+				{Name: "backup", Instances: []string{"redis-server/0"}, Retries: 1},
+			}))
+			Expect(plan.PreRecreateErrands()).To(Equal([]config.Errand{{Name: "drain"}}))
+			Expect(plan.PostDeployErrands()).To(Equal([]config.Errand{{Name: "smoke-tests"}}))
+		})
+
+		It("rejects errands with a negative backoff", func() {
+			plan.PreOperationErrands.PreUpgrade[0].Backoff = -1
+			offering := config.ServiceOffering{Plans: []config.Plan{plan}}
+			Expect(offering.Validate()).To(MatchError("Lifecycle errand 'backup' must not have negative retries or backoff"))
+		})
+	})
+
+	Context("backup errands", func() {
+		var plan config.Plan
+
+		BeforeEach(func() {
+			plan = config.Plan{}
+			err := yaml.Unmarshal([]byte(`
+plan_id: some-plan
+name: some-plan-name
+backup_errands:
+  backup:
+    name: backup
+    instances: [redis-server/0]
+  restore:
+    name: restore
+`), &plan)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads the backup and restore errands", func() {
+			Expect(plan.BackupErrands).To(Equal(&config.BackupErrands{
+				Backup:  config.Errand{Name: "backup", Instances: []string{"redis-server/0"}},
+				Restore: config.Errand{Name: "restore"},
+			}))
+			offering := config.ServiceOffering{Plans: []config.Plan{plan}}
+			Expect(offering.Validate()).To(Succeed())
+		})
+
+		It("requires both errands to be named", func() {
+			plan.BackupErrands.Restore = config.Errand{}
+			offering := config.ServiceOffering{Plans: []config.Plan{plan}}
+			Expect(offering.Validate()).To(MatchError("Plan 'some-plan-name' must name both a backup and a restore errand"))
+		})
+	})
+
+	Context("shared binding access", func() {
+		It("defaults to read-write", func() {
+			plan := config.Plan{}
+			Expect(plan.BindingAccess(true)).To(Equal(config.SharedBindingAccessReadWrite))
+		})
+
+		It("only applies to bindings from spaces the instance is shared with", func() {
+			plan := config.Plan{SharedBindingAccess: config.SharedBindingAccessReadOnly}
+			Expect(plan.BindingAccess(true)).To(Equal(config.SharedBindingAccessReadOnly))
+			Expect(plan.BindingAccess(false)).To(Equal(config.SharedBindingAccessReadWrite))
+		})
+
+		It("rejects unknown access levels", func() {
+			offering := config.ServiceOffering{Plans: []config.Plan{{Name: "some-plan", SharedBindingAccess: "write-only"}}}
+			Expect(offering.Validate()).To(MatchError("Plan 'some-plan' has invalid shared_binding_access 'write-only', must be one of read-write, read-only or denied"))
+		})
+	})
 })
 
 var _ = Describe("CF#NewAuthHeaderBuilder", func() {
@@ -1170,6 +1440,156 @@ var _ = Describe("Config validation", func() {
 		Entry("fails when client_secret is empty", clientCredsAuthBlock("id", ""), errors.New("client_secret can't be empty")),
 	)
 
+	Describe("Broker API", func() {
+		It("sends requests to the broker URL when no service offering is given", func() {
+			brokerAPI := config.BrokerAPI{URL: "http://broker.example.com"}
+			Expect(brokerAPI.ServiceOfferingURL()).To(Equal("http://broker.example.com"))
+		})
+
+		It("sends requests to the routes of the service offering when one is given", func() {
+			brokerAPI := config.BrokerAPI{URL: "http://broker.example.com/", ServiceOfferingID: "kafka-id"}
+			Expect(brokerAPI.ServiceOfferingURL()).To(Equal("http://broker.example.com/service_offerings/kafka-id"))
+		})
+	})
+
+	Describe("Service adapter timeouts", func() {
+		It("keys the timeouts by adapter command", func() {
+			timeouts := config.ServiceAdapterTimeouts{GenerateManifest: 120, CreateBinding: 30}
+
+			Expect(timeouts.Validate()).To(Succeed())
+			Expect(timeouts.ByCommand()).To(Equal(map[string]time.Duration{
+				"generate-manifest":     2 * time.Minute,
+				"create-binding":        30 * time.Second,
+				"delete-binding":        time.Minute,
+				"dashboard-url":         time.Minute,
+				"generate-plan-schemas": time.Minute,
+			}))
+		})
+
+		It("defaults the timeouts that are not configured", func() {
+			Expect(config.ServiceAdapterTimeouts{}.ByCommand()).To(Equal(map[string]time.Duration{
+				"generate-manifest":     10 * time.Minute,
+				"create-binding":        time.Minute,
+				"delete-binding":        time.Minute,
+				"dashboard-url":         time.Minute,
+				"generate-plan-schemas": time.Minute,
+			}))
+		})
+
+		It("fails when a timeout is negative", func() {
+			timeouts := config.ServiceAdapterTimeouts{DeleteBinding: -1}
+
+			Expect(timeouts.Validate()).To(MatchError("service_adapter.timeouts: the timeout of delete-binding cannot be negative"))
+		})
+	})
+
+	Describe("Additional service offerings", func() {
+		var conf config.Config
+
+		BeforeEach(func() {
+			cwd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			conf, err = config.Parse(filepath.Join(cwd, "test_assets", "config_with_additional_service_offerings.yml"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails when an additional offering has no deployment prefix", func() {
+			conf.AdditionalServiceOfferings[1].ServiceCatalog.DeploymentPrefix = ""
+
+			Expect(conf.Validate()).To(MatchError("additional_service_offerings[1]: service_catalog.deployment_prefix must be set"))
+		})
+
+		It("fails when the deployment prefixes overlap", func() {
+			conf.AdditionalServiceOfferings[1].ServiceCatalog.DeploymentPrefix = "kafka-instance_rabbitmq_"
+
+			Expect(conf.Validate()).To(MatchError(`the deployment prefixes "kafka-instance_" and "kafka-instance_rabbitmq_" of service offerings kafka and rabbitmq overlap`))
+		})
+
+		It("fails when two offerings have the same ID", func() {
+			conf.AdditionalServiceOfferings[0].ServiceCatalog.ID = "some-id"
+
+			Expect(conf.Validate()).To(MatchError("service offering ID some-id is used by more than one service offering"))
+		})
+
+		It("fails when two offerings have the same name", func() {
+			conf.AdditionalServiceOfferings[1].ServiceCatalog.Name = "kafka"
+
+			Expect(conf.Validate()).To(MatchError("service name kafka is used by more than one service offering"))
+		})
+
+		It("fails when two plans have the same ID", func() {
+			conf.AdditionalServiceOfferings[1].ServiceCatalog.Plans[0].ID = "kafka-small-plan-id"
+
+			Expect(conf.Validate()).To(MatchError("plan ID kafka-small-plan-id is used by more than one plan"))
+		})
+
+		It("prefixes the errors of an additional offering with its index", func() {
+			conf.AdditionalServiceOfferings[0].ServiceAdapter = &config.ServiceAdapter{}
+
+			Expect(conf.Validate()).To(MatchError("additional_service_offerings[0]: checking for executable service adapter file: path is empty"))
+		})
+	})
+
+	DescribeTable("Cleanup orphan deployments errand",
+		func(errandConfig config.CleanupOrphanDeploymentsErrandConfig, expectedErr error) {
+			err := errandConfig.Validate()
+			if expectedErr != nil {
+				Expect(err).To(MatchError(expectedErr.Error()))
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		},
+		Entry(
+			"succeeds with a minimum number of sightings",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumSightings: 2, PollingInterval: 10},
+			nil,
+		),
+		Entry(
+			"succeeds with a minimum age",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumAgeInSeconds: 3600, PollingInterval: 10},
+			nil,
+		),
+		Entry(
+			"fails without a state path",
+			config.CleanupOrphanDeploymentsErrandConfig{MinimumSightings: 2, PollingInterval: 10},
+			errors.New("state_path must be set"),
+		),
+		Entry(
+			"fails with a negative minimum",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumSightings: 2, MinimumAgeInSeconds: -1, PollingInterval: 10},
+			errors.New("minimum_sightings and minimum_age_in_seconds cannot be negative"),
+		),
+		Entry(
+			"fails without a grace period",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", PollingInterval: 10},
+			errors.New("at least one of minimum_sightings or minimum_age_in_seconds must be set"),
+		),
+		Entry(
+			"fails without a polling interval",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumSightings: 2},
+			errors.New("polling_interval must be greater than zero"),
+		),
+		Entry(
+			"fails with a negative deletion timeout",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumSightings: 2, PollingInterval: 10, DeletionTimeoutInSeconds: -1},
+			errors.New("deletion_timeout_in_seconds cannot be negative"),
+		),
+		Entry(
+			"fails with a negative run timeout",
+			config.CleanupOrphanDeploymentsErrandConfig{StatePath: "/state", MinimumSightings: 2, PollingInterval: 10, RunTimeoutInSeconds: -1},
+			errors.New("run_timeout_in_seconds cannot be negative"),
+		),
+	)
+
+	It("waits an hour for each orphan deletion by default", func() {
+		Expect(config.CleanupOrphanDeploymentsErrandConfig{}.DeletionTimeout()).To(Equal(time.Hour))
+		Expect(config.CleanupOrphanDeploymentsErrandConfig{DeletionTimeoutInSeconds: 60}.DeletionTimeout()).To(Equal(time.Minute))
+	})
+
+	It("waits four hours for all orphan deletions by default", func() {
+		Expect(config.CleanupOrphanDeploymentsErrandConfig{}.RunTimeout()).To(Equal(4 * time.Hour))
+		Expect(config.CleanupOrphanDeploymentsErrandConfig{RunTimeoutInSeconds: 60}.RunTimeout()).To(Equal(time.Minute))
+	})
 })
 
 func authBlock(basic config.UserCredentials, uaa config.UAAAuthentication) config.Authentication {
@@ -29,6 +29,7 @@ import (
 	"code.cloudfoundry.org/credhub-cli/credhub/permissions"
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 )
 
 type Store struct {
@@ -74,6 +75,14 @@ func (c *Store) Set(key string, value interface{}) error {
 	return err
 }
 
+func (c *Store) Get(key string) (interface{}, error) {
+	cred, err := c.credhubClient.GetLatestVersion(key)
+	if err != nil {
+		return nil, err
+	}
+	return cred.Value, nil
+}
+
 func (c *Store) AddPermission(credName string, actor string, ops []string) (*permissions.Permission, error) {
 	return c.credhubClient.AddPermission(credName, actor, ops)
 }
@@ -81,7 +90,7 @@ func (c *Store) AddPermission(credName string, actor string, ops []string) (*per
 func (c *Store) BulkDelete(paths []string, logger *log.Logger) error {
 	for _, path := range paths {
 		if err := c.Delete(path); err != nil {
-			logger.Printf("could not delete secret '%s': %s", path, err.Error())
+			loggerfactory.Errorf(logger, "could not delete secret '%s': %s", path, err.Error())
 			return err
 		}
 	}
@@ -118,7 +127,7 @@ func (c *Store) BulkGet(secretsToFetch map[string]boshdirector.Variable, logger
 			cred, err = c.credhubClient.GetLatestVersion(deploymentVar.Path)
 		}
 		if err != nil {
-			logger.Printf("Could not resolve %s: %s", name, err)
+			loggerfactory.Errorf(logger, "Could not resolve %s: %s", name, err)
 			continue
 		}
 
@@ -20,6 +20,7 @@ import "code.cloudfoundry.org/credhub-cli/credhub/permissions"
 //go:generate counterfeiter -o fakes/credentialstore.go . CredentialStore
 type CredentialStore interface {
 	Set(key string, value interface{}) error
+	Get(key string) (interface{}, error)
 	Delete(key string) error
 	AddPermission(credentialName string, actor string, ops []string) (*permissions.Permission, error)
 }
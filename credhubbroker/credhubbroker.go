@@ -19,6 +19,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 
 	"github.com/pborman/uuid"
 	"github.com/pivotal-cf/brokerapi"
@@ -49,17 +50,14 @@ func New(broker apiserver.CombinedBroker,
 	}
 }
 
+// Bind stores the credentials of a synchronous binding once the wrapped
+// broker has created it. The credentials of an asynchronous binding are
+// stored by the wrapped broker, through StoreBinding, as soon as it has been
+// created, so that they do not depend on anything held in memory.
 func (b *CredHubBroker) Bind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokerapi.Binding, error) {
-	var actor string
-	switch {
-	case details.AppGUID != "":
-		actor = fmt.Sprintf("mtls-app:%s", details.AppGUID)
-	case details.BindResource != nil && details.BindResource.AppGuid != "":
-		actor = fmt.Sprintf("mtls-app:%s", details.BindResource.AppGuid)
-	case details.BindResource != nil && details.BindResource.CredentialClientID != "":
-		actor = fmt.Sprintf("uaa-client:%s", details.BindResource.CredentialClientID)
-	default:
-		return brokerapi.Binding{}, errors.New("No app-guid or credential client ID were provided in the binding request, you must configure one of these")
+	actor, err := bindingActor(details)
+	if err != nil {
+		return brokerapi.Binding{}, err
 	}
 
 	requestID := uuid.New()
@@ -71,22 +69,91 @@ func (b *CredHubBroker) Bind(ctx context.Context, instanceID, bindingID string,
 		return brokerapi.Binding{}, err
 	}
 
-	key := constructKey(details.ServiceID, instanceID, bindingID)
+	if binding.IsAsync {
+		logger.Printf("credentials for instance ID: %s, with binding ID: %s will be stored when the binding is created", instanceID, bindingID)
+		return binding, nil
+	}
+
+	if err := b.storeBinding(ctx, requestID, details.ServiceID, instanceID, bindingID, actor, binding.Credentials, binding.SyslogDrainURL, binding.RouteServiceURL); err != nil {
+		return brokerapi.Binding{}, err
+	}
+
+	binding.Credentials = map[string]string{"credhub-ref": constructKey(details.ServiceID, instanceID, bindingID)}
+	return binding, nil
+}
+
+// bindingActor is who the credentials of a binding are made readable by.
+func bindingActor(details brokerapi.BindDetails) (string, error) {
+	switch {
+	case details.AppGUID != "":
+		return fmt.Sprintf("mtls-app:%s", details.AppGUID), nil
+	case details.BindResource != nil && details.BindResource.AppGuid != "":
+		return fmt.Sprintf("mtls-app:%s", details.BindResource.AppGuid), nil
+	case details.BindResource != nil && details.BindResource.CredentialClientID != "":
+		return fmt.Sprintf("uaa-client:%s", details.BindResource.CredentialClientID), nil
+	default:
+		return "", errors.New("No app-guid or credential client ID were provided in the binding request, you must configure one of these")
+	}
+}
+
+// StoreBinding stores the credentials of a binding created by an asynchronous
+// bind, before the wrapped broker reports the bind as succeeded.
+func (b *CredHubBroker) StoreBinding(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, binding brokerapi.Binding) error {
+	actor, err := bindingActor(details)
+	if err != nil {
+		return err
+	}
+	return b.storeBinding(ctx, brokercontext.GetReqID(ctx), details.ServiceID, instanceID, bindingID, actor, binding.Credentials, binding.SyslogDrainURL, binding.RouteServiceURL)
+}
+
+// DeleteBinding removes the credentials of a binding once an asynchronous
+// unbind has succeeded.
+func (b *CredHubBroker) DeleteBinding(ctx context.Context, instanceID, bindingID, serviceID string) error {
+	return b.deleteBinding(serviceID, instanceID, bindingID)
+}
+
+// storeBinding stores the credentials of a binding, readable by the actor
+// the binding was made for, and its syslog drain and route service URLs, if
+// it has any, for GetBinding to return.
+func (b *CredHubBroker) storeBinding(ctx context.Context, requestID, serviceID, instanceID, bindingID, actor string, credentials interface{}, syslogDrainURL, routeServiceURL string) error {
+	logger := b.loggerFactory.NewWithContext(ctx)
+
 	logger.Printf("storing credentials for instance ID: %s, with binding ID: %s", instanceID, bindingID)
-	err = b.credStore.Set(key, binding.Credentials)
+	key := constructKey(serviceID, instanceID, bindingID)
+	err := b.credStore.Set(key, credentials)
 	if err != nil {
-		ctx = brokercontext.New(ctx, string(broker.OperationTypeBind), requestID, b.serviceName, instanceID)
-		setErr := broker.NewGenericError(ctx, fmt.Errorf("failed to set credentials in credential store: %v", err))
-		logger.Print(setErr)
-		return brokerapi.Binding{}, setErr.ErrorForCFUser()
+		return b.storeError(ctx, requestID, instanceID, fmt.Errorf("failed to set credentials in credential store: %v", err), logger)
 	}
 
 	b.credStore.AddPermission(key, actor, []string{"read"})
 
-	binding.Credentials = map[string]string{"credhub-ref": key}
-	return binding, nil
+	urls := map[string]interface{}{}
+	if syslogDrainURL != "" {
+		urls[syslogDrainURLKey] = syslogDrainURL
+	}
+	if routeServiceURL != "" {
+		urls[routeServiceURLKey] = routeServiceURL
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	if err := b.credStore.Set(constructURLsKey(serviceID, instanceID, bindingID), urls); err != nil {
+		return b.storeError(ctx, requestID, instanceID, fmt.Errorf("failed to set binding URLs in credential store: %v", err), logger)
+	}
+	return nil
+}
+
+func (b *CredHubBroker) storeError(ctx context.Context, requestID, instanceID string, err error, logger *log.Logger) error {
+	ctx = brokercontext.New(ctx, string(broker.OperationTypeBind), requestID, b.serviceName, instanceID)
+	setErr := broker.NewGenericError(ctx, err)
+	logger.Print(setErr)
+	return setErr.ErrorForCFUser()
 }
 
+// Unbind removes the credentials and URLs of a binding from the credential
+// store once the wrapped broker has unbound it. The wrapped broker removes
+// them, through DeleteBinding, once an asynchronous unbind has succeeded, so
+// that the binding still has its credentials if the unbind fails.
 func (b *CredHubBroker) Unbind(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokerapi.UnbindSpec, error) {
 	requestID := uuid.New()
 	ctx = brokercontext.WithReqID(ctx, requestID)
@@ -98,15 +165,96 @@ func (b *CredHubBroker) Unbind(ctx context.Context, instanceID, bindingID string
 		return brokerapi.UnbindSpec{}, err
 	}
 
-	key := constructKey(details.ServiceID, instanceID, bindingID)
+	if unbind.IsAsync {
+		logger.Printf("credentials for instance ID: %s, with binding ID: %s will be removed when the unbinding completes\n", instanceID, bindingID)
+		return unbind, nil
+	}
+
+	if err := b.deleteBinding(details.ServiceID, instanceID, bindingID); err != nil {
+		logger.Printf("WARNING: %s", err)
+	}
+	return unbind, nil
+}
+
+// deleteBinding removes the credentials and URLs of a binding from the
+// credential store.
+func (b *CredHubBroker) deleteBinding(serviceID, instanceID, bindingID string) error {
+	key := constructKey(serviceID, instanceID, bindingID)
 	chErr := b.credStore.Delete(key)
+	// Bindings without a syslog drain or route service have no URLs stored,
+	// so failing to delete them is expected.
+	b.credStore.Delete(constructURLsKey(serviceID, instanceID, bindingID))
 	if chErr != nil {
-		logger.Printf("WARNING: failed to remove key '%s' from credential store", key)
+		return fmt.Errorf("failed to remove key '%s' from credential store", key)
 	}
+	return nil
+}
 
-	return unbind, nil
+// GetBinding returns the same credhub reference handed out at bind time,
+// after checking that the credentials are still held in the credential store,
+// along with the binding's syslog drain and route service URLs. The keys are
+// derived from the service offering ID, which is not part of the request, so
+// it is looked up in the catalog.
+func (b *CredHubBroker) GetBinding(ctx context.Context, instanceID, bindingID string) (brokerapi.GetBindingSpec, error) {
+	requestID := uuid.New()
+	ctx = brokercontext.WithReqID(ctx, requestID)
+	logger := b.loggerFactory.NewWithContext(ctx)
+
+	services, err := b.CombinedBroker.Services(ctx)
+	if err != nil {
+		return brokerapi.GetBindingSpec{}, err
+	}
+
+	for _, service := range services {
+		key := constructKey(service.ID, instanceID, bindingID)
+		if _, err := b.credStore.Get(key); err != nil {
+			loggerfactory.Errorf(logger, "could not find credentials for instance ID: %s, with binding ID: %s at '%s': %s", instanceID, bindingID, key, err)
+			continue
+		}
+
+		logger.Printf("found credentials for instance ID: %s, with binding ID: %s", instanceID, bindingID)
+		spec := brokerapi.GetBindingSpec{
+			Credentials: map[string]string{"credhub-ref": key},
+		}
+		if urls, err := b.credStore.Get(constructURLsKey(service.ID, instanceID, bindingID)); err == nil {
+			urlsMap, _ := urls.(map[string]interface{})
+			spec.SyslogDrainURL, _ = urlsMap[syslogDrainURLKey].(string)
+			spec.RouteServiceURL, _ = urlsMap[routeServiceURLKey].(string)
+		}
+		return spec, nil
+	}
+
+	return brokerapi.GetBindingSpec{}, brokerapi.ErrBindingNotFound
+}
+
+// Services advertises bindings_retrievable, as every binding, synchronous or
+// asynchronous, is stored in the credential store and can be fetched from it
+// without calling the service adapter.
+func (b *CredHubBroker) Services(ctx context.Context) ([]brokerapi.Service, error) {
+	services, err := b.CombinedBroker.Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	retrievableServices := make([]brokerapi.Service, len(services))
+	for i, service := range services {
+		service.BindingsRetrievable = true
+		retrievableServices[i] = service
+	}
+	return retrievableServices, nil
 }
 
 func constructKey(serviceID, instanceID, bindingID string) string {
 	return fmt.Sprintf("/c/%s/%s/%s/credentials", serviceID, instanceID, bindingID)
 }
+
+// The syslog drain and route service URLs of a binding are stored apart from
+// its credentials, which apps read.
+const (
+	syslogDrainURLKey  = "syslog_drain_url"
+	routeServiceURLKey = "route_service_url"
+)
+
+func constructURLsKey(serviceID, instanceID, bindingID string) string {
+	return fmt.Sprintf("/c/%s/%s/%s/urls", serviceID, instanceID, bindingID)
+}
@@ -197,6 +197,32 @@ var _ = Describe("CredHub broker", func() {
 			Expect(bindErr).To(MatchError("error message from base broker"))
 		})
 
+		It("stores the syslog drain and route service URLs of the binding apart from its credentials", func() {
+			fakeBroker.BindReturns(brokerapi.Binding{
+				Credentials:     "justAString",
+				SyslogDrainURL:  "syslog://drain",
+				RouteServiceURL: "https://route.service",
+			}, nil)
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+			bindDetails.AppGUID = "an-app"
+
+			response, err := credhubBroker.Bind(ctx, instanceID, bindingID, bindDetails, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.SyslogDrainURL).To(Equal("syslog://drain"))
+
+			Expect(fakeCredStore.SetCallCount()).To(Equal(2))
+			key, urls := fakeCredStore.SetArgsForCall(1)
+			Expect(key).To(Equal(constructURLsRef(bindDetails.ServiceID, instanceID, bindingID)))
+			Expect(urls).To(Equal(map[string]interface{}{
+				"syslog_drain_url":  "syslog://drain",
+				"route_service_url": "https://route.service",
+			}))
+
+			By("not granting the app access to the URLs")
+			Expect(fakeCredStore.AddPermissionCallCount()).To(Equal(1))
+		})
+
 		It("produces an error if it cannot store the credential", func() {
 			fakeCredStore := new(credfakes.FakeCredentialStore)
 			bindingResponse := brokerapi.Binding{
@@ -221,6 +247,66 @@ var _ = Describe("CredHub broker", func() {
 		})
 	})
 
+	Describe("asynchronous Bind", func() {
+		var (
+			fakeCredStore *credfakes.FakeCredentialStore
+			credhubBroker *credhubbroker.CredHubBroker
+		)
+
+		BeforeEach(func() {
+			fakeCredStore = new(credfakes.FakeCredentialStore)
+			credhubBroker = credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			fakeBroker.BindReturns(brokerapi.Binding{IsAsync: true, OperationData: `{"OperationType":"bind"}`}, nil)
+			bindDetails.AppGUID = "an-app"
+		})
+
+		It("leaves storing the credentials to the wrapped broker once the binding has been created", func() {
+			binding, err := credhubBroker.Bind(ctx, instanceID, bindingID, bindDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(binding.IsAsync).To(BeTrue())
+			Expect(binding.OperationData).To(Equal(`{"OperationType":"bind"}`))
+			Expect(fakeCredStore.SetCallCount()).To(BeZero())
+		})
+
+		It("stores the credentials of a created binding, readable by the app it was made for", func() {
+			err := credhubBroker.StoreBinding(ctx, instanceID, bindingID, bindDetails, brokerapi.Binding{
+				Credentials:    "justAString",
+				SyslogDrainURL: "syslog://drain",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			credhubRef := constructCredhubRef(bindDetails.ServiceID, instanceID, bindingID)
+			Expect(fakeCredStore.SetCallCount()).To(Equal(2))
+			key, creds := fakeCredStore.SetArgsForCall(0)
+			Expect(key).To(Equal(credhubRef))
+			Expect(creds).To(Equal("justAString"))
+			key, urls := fakeCredStore.SetArgsForCall(1)
+			Expect(key).To(Equal(constructURLsRef(bindDetails.ServiceID, instanceID, bindingID)))
+			Expect(urls).To(Equal(map[string]interface{}{"syslog_drain_url": "syslog://drain"}))
+
+			Expect(fakeCredStore.AddPermissionCallCount()).To(Equal(1))
+			_, actor, _ := fakeCredStore.AddPermissionArgsForCall(0)
+			Expect(actor).To(Equal("mtls-app:an-app"))
+		})
+
+		It("returns an error when the credentials cannot be stored", func() {
+			fakeCredStore.SetReturns(errors.New("credential store unavailable"))
+
+			err := credhubBroker.StoreBinding(ctx, instanceID, bindingID, bindDetails, brokerapi.Binding{Credentials: "justAString"})
+			Expect(err).To(HaveOccurred())
+			Expect(logBuffer.String()).To(ContainSubstring("failed to set credentials in credential store:"))
+		})
+
+		It("returns an error when the binding was made for neither an app nor a credential client", func() {
+			bindDetails.AppGUID = ""
+
+			err := credhubBroker.StoreBinding(ctx, instanceID, bindingID, bindDetails, brokerapi.Binding{Credentials: "justAString"})
+			Expect(err).To(MatchError(ContainSubstring("No app-guid or credential client ID were provided")))
+			Expect(fakeCredStore.SetCallCount()).To(BeZero())
+		})
+	})
+
 	Describe("Unbind", func() {
 		var unbindDetails = brokerapi.UnbindDetails{
 			PlanID:    "asdf",
@@ -237,8 +323,9 @@ var _ = Describe("CredHub broker", func() {
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fakeBroker.UnbindCallCount()).To(Equal(1))
-			Expect(fakeCredStore.DeleteCallCount()).To(Equal(1))
+			Expect(fakeCredStore.DeleteCallCount()).To(Equal(2))
 			Expect(fakeCredStore.DeleteArgsForCall(0)).To(Equal(credhubRef))
+			Expect(fakeCredStore.DeleteArgsForCall(1)).To(Equal(constructURLsRef(unbindDetails.ServiceID, instanceID, bindingID)))
 			Expect(logBuffer.String()).To(MatchRegexp(requestIDRegex))
 			Expect(logBuffer.String()).To(ContainSubstring(
 				fmt.Sprintf("removing credentials for instance ID: %s, with binding ID: %s", instanceID, bindingID)))
@@ -279,9 +366,134 @@ var _ = Describe("CredHub broker", func() {
 			credhubRef := constructCredhubRef(unbindDetails.ServiceID, instanceID, bindingID)
 			Expect(logBuffer.String()).To(ContainSubstring(fmt.Sprintf("WARNING: failed to remove key '%s'", credhubRef)))
 		})
+
+		Context("when the unbind is asynchronous", func() {
+			var (
+				fakeCredStore *credfakes.FakeCredentialStore
+				credhubBroker *credhubbroker.CredHubBroker
+			)
+
+			BeforeEach(func() {
+				fakeCredStore = new(credfakes.FakeCredentialStore)
+				credhubBroker = credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+				fakeBroker.UnbindReturns(brokerapi.UnbindSpec{IsAsync: true, OperationData: `{"OperationType":"unbind"}`}, nil)
+			})
+
+			It("leaves removing the credentials to the wrapped broker once the unbind has succeeded", func() {
+				unbind, err := credhubBroker.Unbind(ctx, instanceID, bindingID, unbindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(unbind.IsAsync).To(BeTrue())
+				Expect(fakeCredStore.DeleteCallCount()).To(BeZero())
+			})
+
+			It("removes the credentials of an unbound binding", func() {
+				err := credhubBroker.DeleteBinding(ctx, instanceID, bindingID, unbindDetails.ServiceID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeCredStore.DeleteCallCount()).To(Equal(2))
+				Expect(fakeCredStore.DeleteArgsForCall(0)).To(Equal(constructCredhubRef(unbindDetails.ServiceID, instanceID, bindingID)))
+				Expect(fakeCredStore.DeleteArgsForCall(1)).To(Equal(constructURLsRef(unbindDetails.ServiceID, instanceID, bindingID)))
+			})
+
+			It("returns an error when the credentials cannot be removed", func() {
+				fakeCredStore.DeleteReturns(errors.New("credential store unavailable"))
+
+				err := credhubBroker.DeleteBinding(ctx, instanceID, bindingID, unbindDetails.ServiceID)
+				Expect(err).To(MatchError(fmt.Sprintf("failed to remove key '%s' from credential store", constructCredhubRef(unbindDetails.ServiceID, instanceID, bindingID))))
+			})
+		})
+	})
+
+	Describe("GetBinding", func() {
+		BeforeEach(func() {
+			fakeBroker.ServicesReturns([]brokerapi.Service{{ID: "big-hybrid-cloud-of-things"}}, nil)
+		})
+
+		It("returns the credhub reference when the credentials are in the credential store", func() {
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			fakeCredStore.GetReturns(map[string]interface{}{"user": "admin"}, nil)
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			binding, err := credhubBroker.GetBinding(ctx, instanceID, bindingID)
+			Expect(err).NotTo(HaveOccurred())
+
+			credhubRef := constructCredhubRef("big-hybrid-cloud-of-things", instanceID, bindingID)
+			Expect(binding.Credentials).To(Equal(map[string]string{"credhub-ref": credhubRef}))
+			Expect(fakeCredStore.GetCallCount()).To(Equal(2))
+			Expect(fakeCredStore.GetArgsForCall(0)).To(Equal(credhubRef))
+
+			By("not calling the wrapped broker's GetBinding")
+			Expect(fakeBroker.GetBindingCallCount()).To(BeZero())
+			Expect(logBuffer.String()).To(MatchRegexp(requestIDRegex))
+		})
+
+		It("returns the syslog drain and route service URLs stored for the binding", func() {
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			fakeCredStore.GetReturnsOnCall(0, map[string]interface{}{"user": "admin"}, nil)
+			fakeCredStore.GetReturnsOnCall(1, map[string]interface{}{
+				"syslog_drain_url":  "syslog://drain",
+				"route_service_url": "https://route.service",
+			}, nil)
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			binding, err := credhubBroker.GetBinding(ctx, instanceID, bindingID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(binding.SyslogDrainURL).To(Equal("syslog://drain"))
+			Expect(binding.RouteServiceURL).To(Equal("https://route.service"))
+			Expect(fakeCredStore.GetArgsForCall(1)).To(Equal(constructURLsRef("big-hybrid-cloud-of-things", instanceID, bindingID)))
+		})
+
+		It("returns no URLs for a binding that has none stored", func() {
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			fakeCredStore.GetReturnsOnCall(0, map[string]interface{}{"user": "admin"}, nil)
+			fakeCredStore.GetReturnsOnCall(1, nil, errors.New("credential does not exist"))
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			binding, err := credhubBroker.GetBinding(ctx, instanceID, bindingID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(binding.SyslogDrainURL).To(BeEmpty())
+			Expect(binding.RouteServiceURL).To(BeEmpty())
+		})
+
+		It("returns binding not found when the credentials are not in the credential store", func() {
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			fakeCredStore.GetReturns(nil, errors.New("credential does not exist"))
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			_, err := credhubBroker.GetBinding(ctx, instanceID, bindingID)
+			Expect(err).To(Equal(brokerapi.ErrBindingNotFound))
+			Expect(logBuffer.String()).To(ContainSubstring("credential does not exist"))
+		})
+
+		It("returns an error when the catalog cannot be retrieved", func() {
+			fakeBroker.ServicesReturns(nil, errors.New("no catalog"))
+			fakeCredStore := new(credfakes.FakeCredentialStore)
+			credhubBroker := credhubbroker.New(fakeBroker, fakeCredStore, serviceName, loggerFactory)
+
+			_, err := credhubBroker.GetBinding(ctx, instanceID, bindingID)
+			Expect(err).To(MatchError("no catalog"))
+		})
+	})
+
+	Describe("Services", func() {
+		It("advertises that bindings are retrievable", func() {
+			fakeBroker.ServicesReturns([]brokerapi.Service{{ID: "a-service"}, {ID: "another-service"}}, nil)
+			credhubBroker := credhubbroker.New(fakeBroker, new(credfakes.FakeCredentialStore), serviceName, loggerFactory)
+
+			services, err := credhubBroker.Services(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(Equal([]brokerapi.Service{
+				{ID: "a-service", BindingsRetrievable: true},
+				{ID: "another-service", BindingsRetrievable: true},
+			}))
+		})
 	})
 })
 
 func constructCredhubRef(serviceID, instanceID, bindingID string) string {
 	return fmt.Sprintf("/c/%s/%s/%s/credentials", serviceID, instanceID, bindingID)
 }
+
+func constructURLsRef(serviceID, instanceID, bindingID string) string {
+	return fmt.Sprintf("/c/%s/%s/%s/urls", serviceID, instanceID, bindingID)
+}
@@ -21,6 +21,19 @@ type FakeCredentialStore struct {
 	setReturnsOnCall map[int]struct {
 		result1 error
 	}
+	GetStub        func(key string) (interface{}, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		key string
+	}
+	getReturns struct {
+		result1 interface{}
+		result2 error
+	}
+	getReturnsOnCall map[int]struct {
+		result1 interface{}
+		result2 error
+	}
 	DeleteStub        func(key string) error
 	deleteMutex       sync.RWMutex
 	deleteArgsForCall []struct {
@@ -100,6 +113,57 @@ func (fake *FakeCredentialStore) SetReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeCredentialStore) Get(key string) (interface{}, error) {
+	fake.getMutex.Lock()
+	ret, specificReturn := fake.getReturnsOnCall[len(fake.getArgsForCall)]
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		key string
+	}{key})
+	fake.recordInvocation("Get", []interface{}{key})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub(key)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getReturns.result1, fake.getReturns.result2
+}
+
+func (fake *FakeCredentialStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeCredentialStore) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return fake.getArgsForCall[i].key
+}
+
+func (fake *FakeCredentialStore) GetReturns(result1 interface{}, result2 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 interface{}
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCredentialStore) GetReturnsOnCall(i int, result1 interface{}, result2 error) {
+	fake.GetStub = nil
+	if fake.getReturnsOnCall == nil {
+		fake.getReturnsOnCall = make(map[int]struct {
+			result1 interface{}
+			result2 error
+		})
+	}
+	fake.getReturnsOnCall[i] = struct {
+		result1 interface{}
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeCredentialStore) Delete(key string) error {
 	fake.deleteMutex.Lock()
 	ret, specificReturn := fake.deleteReturnsOnCall[len(fake.deleteArgsForCall)]
@@ -211,6 +275,8 @@ func (fake *FakeCredentialStore) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.setMutex.RLock()
 	defer fake.setMutex.RUnlock()
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
 	fake.addPermissionMutex.RLock()
@@ -19,9 +19,12 @@ import (
 	"log"
 	"time"
 
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 
 	"strings"
 
@@ -45,6 +48,12 @@ type Builder struct {
 	Sleeper               sleeper
 	Triggerer             Triggerer
 	CanarySelectionParams config.CanarySelectionParams
+	SelectionFilters      config.InstanceSelectionFilters
+	Checkpointer          Checkpointer
+	OperationType         string
+	Fingerprint           string
+	Controller            Controller
+	MaintenanceSchedule   MaintenanceSchedule
 }
 
 func NewBuilder(conf config.InstanceIteratorConfig, logger *log.Logger, logPrefix string) (*Builder, error) {
@@ -89,6 +98,11 @@ func NewBuilder(conf config.InstanceIteratorConfig, logger *log.Logger, logPrefi
 		return nil, err
 	}
 
+	selectionFilters, err := selectionFilters(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	listener := NewLoggingListener(logger, logPrefix)
 
 	b := &Builder{
@@ -102,6 +116,26 @@ func NewBuilder(conf config.InstanceIteratorConfig, logger *log.Logger, logPrefi
 		Listener:              listener,
 		Sleeper:               &tools.RealSleeper{},
 		CanarySelectionParams: canarySelectionParams,
+		SelectionFilters:      selectionFilters,
+	}
+
+	if conf.CheckpointPath != "" {
+		b.Checkpointer = NewFileCheckpointer(conf.CheckpointPath)
+		b.Fingerprint, err = configFingerprint(conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.ControlFilePath != "" {
+		b.Controller = NewFileController(conf.ControlFilePath)
+	}
+
+	if !conf.MaintenanceWindows.IsEmpty() {
+		b.MaintenanceSchedule, err = NewMaintenanceSchedule(conf.MaintenanceWindows, instanceLister)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return b, nil
@@ -112,6 +146,7 @@ func (b *Builder) SetUpgradeTriggerer() error {
 		return errors.New("unable to set triggerer, brokerServices must not be nil")
 	}
 	b.Triggerer = NewUpgradeTriggerer(b.BrokerServices)
+	b.OperationType = "upgrade"
 	return nil
 }
 
@@ -120,9 +155,44 @@ func (b *Builder) SetRecreateTriggerer() error {
 		return errors.New("unable to set triggerer, brokerServices must not be nil")
 	}
 	b.Triggerer = NewRecreateTriggerer(b.BrokerServices)
+	b.OperationType = "recreate"
 	return nil
 }
 
+// AbortOnSignals makes the iterator stop starting new operations, and exit
+// once those in progress have finished, when any of the signals is received.
+func (b *Builder) AbortOnSignals(signals ...os.Signal) {
+	b.Controller = newSignalController(b.Controller, signals...)
+}
+
+// configFingerprint identifies the instances a run operates on and the order
+// it takes them in, so that a checkpoint left by a run which selected other
+// instances, for example one from before the broker was redeployed, is not
+// resumed. Settings which only change how the run proceeds, such as
+// max_in_flight, timeouts or credentials, are left out so that changing them
+// does not discard a checkpoint.
+func configFingerprint(conf config.InstanceIteratorConfig) (string, error) {
+	contents, err := json.Marshal(struct {
+		BrokerURL             string
+		ServiceOfferingID     string
+		ServiceInstancesURL   string
+		Canaries              int
+		CanarySelectionParams config.CanarySelectionParams
+		SelectionFilters      config.InstanceSelectionFilters
+	}{
+		BrokerURL:             conf.BrokerAPI.URL,
+		ServiceOfferingID:     conf.BrokerAPI.ServiceOfferingID,
+		ServiceInstancesURL:   conf.ServiceInstancesAPI.URL,
+		Canaries:              conf.Canaries,
+		CanarySelectionParams: conf.CanarySelectionParams,
+		SelectionFilters:      conf.SelectionFilters,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error computing checkpoint fingerprint: %s", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(contents)), nil
+}
+
 func brokerServices(conf config.InstanceIteratorConfig, logger *log.Logger) (*services.BrokerServices, error) {
 	if conf.BrokerAPI.Authentication.Basic.Username == "" ||
 		conf.BrokerAPI.Authentication.Basic.Password == "" ||
@@ -141,7 +211,7 @@ func brokerServices(conf config.InstanceIteratorConfig, logger *log.Logger) (*se
 			MaxRetries: 5,
 		}),
 		brokerBasicAuthHeaderBuilder,
-		conf.BrokerAPI.URL,
+		conf.BrokerAPI.ServiceOfferingURL(),
 		logger,
 	), nil
 }
@@ -213,3 +283,16 @@ func canaries(conf config.InstanceIteratorConfig) (int, error) {
 func canarySelectionParams(conf config.InstanceIteratorConfig) (config.CanarySelectionParams, error) {
 	return conf.CanarySelectionParams, nil
 }
+
+func selectionFilters(conf config.InstanceIteratorConfig) (config.InstanceSelectionFilters, error) {
+	filters := conf.SelectionFilters
+	if filters.CFSpace != "" && filters.CFOrg == "" {
+		return config.InstanceSelectionFilters{}, errors.New("the cf_space selection filter requires cf_org")
+	}
+	for _, release := range filters.Releases {
+		if release.Name == "" || release.Version == "" {
+			return config.InstanceSelectionFilters{}, errors.New("release selection filters must specify both a name and a version")
+		}
+	}
+	return filters, nil
+}
@@ -209,6 +209,116 @@ var _ = Describe("Builder", func() {
 		})
 	})
 
+	Describe("Selection filters", func() {
+		It("when configured returns the filters", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.SelectionFilters = config.InstanceSelectionFilters{
+				PlanIDs:        []string{"plan-a"},
+				CFOrg:          "the-org",
+				CFSpace:        "the-space",
+				Releases:       []config.ReleaseFilter{{Name: "redis", Version: "1.0.0"}},
+				ExcludePlanIDs: []string{"plan-b"},
+			}
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.SelectionFilters).To(Equal(conf.SelectionFilters))
+		})
+
+		DescribeTable(
+			"config is invalidly set to",
+			func(filters config.InstanceSelectionFilters, expectedErr string) {
+				conf := makeErrandConfig("user", "password", "http://example.org")
+				conf.SelectionFilters = filters
+				_, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+
+				Expect(err).To(MatchError(Equal(expectedErr)))
+			},
+			Entry("a space without an org",
+				config.InstanceSelectionFilters{CFSpace: "the-space"},
+				"the cf_space selection filter requires cf_org"),
+			Entry("a release without a version",
+				config.InstanceSelectionFilters{Releases: []config.ReleaseFilter{{Name: "redis"}}},
+				"release selection filters must specify both a name and a version"),
+		)
+	})
+
+	Describe("Maintenance windows", func() {
+		It("does not use a maintenance schedule by default", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.MaintenanceSchedule).To(BeNil())
+		})
+
+		It("uses the configured maintenance windows", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.MaintenanceWindows = config.MaintenanceWindows{
+				Default: &config.MaintenanceWindow{Start: "22:00", Duration: "4h"},
+			}
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.MaintenanceSchedule).To(BeAssignableToTypeOf(&instanceiterator.ConfiguredMaintenanceSchedule{}))
+		})
+
+		It("returns an error when a maintenance window is invalid", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.MaintenanceWindows = config.MaintenanceWindows{
+				Default: &config.MaintenanceWindow{Start: "25:00", Duration: "4h"},
+			}
+			_, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).To(MatchError(`invalid default maintenance window: start "25:00" must be formatted as HH:MM`))
+		})
+	})
+
+	Describe("Checkpointing and control", func() {
+		It("does not checkpoint or accept commands by default", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Checkpointer).To(BeNil())
+			Expect(builder.Controller).To(BeNil())
+		})
+
+		It("uses the configured checkpoint and control files", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.CheckpointPath = "/var/vcap/store/upgrade-all/checkpoint.json"
+			conf.ControlFilePath = "/var/vcap/data/upgrade-all/control"
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Checkpointer).To(Equal(instanceiterator.NewFileCheckpointer("/var/vcap/store/upgrade-all/checkpoint.json")))
+			Expect(builder.Controller).To(Equal(instanceiterator.NewFileController("/var/vcap/data/upgrade-all/control")))
+		})
+
+		It("fingerprints the configuration so that checkpoints of other runs are not resumed", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.CheckpointPath = "/var/vcap/store/upgrade-all/checkpoint.json"
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+
+			conf.Canaries = 2
+			otherBuilder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(builder.Fingerprint).NotTo(BeEmpty())
+			Expect(otherBuilder.Fingerprint).NotTo(Equal(builder.Fingerprint))
+		})
+
+		It("leaves settings which do not select or order instances out of the fingerprint", func() {
+			conf := makeErrandConfig("user", "password", "http://example.org")
+			conf.CheckpointPath = "/var/vcap/store/upgrade-all/checkpoint.json"
+			builder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+
+			conf.MaxInFlight = 5
+			conf.AttemptLimit = 10
+			conf.BrokerAPI.Authentication.Basic.Password = "rotated-password"
+			otherBuilder, err := instanceiterator.NewBuilder(conf, logger, logPrefix)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(otherBuilder.Fingerprint).To(Equal(builder.Fingerprint))
+		})
+	})
+
 	Describe("SetUpdateTriggerer", func() {
 		It("sets an update triggerer on a properly initiated builder", func() {
 			conf := makeErrandConfig("user", "password", "http://example.org")
@@ -219,6 +329,7 @@ var _ = Describe("Builder", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(builder.Triggerer).ToNot(BeNil())
 			Expect(builder.Triggerer).To(BeAssignableToTypeOf(new(instanceiterator.UpgradeTriggerer)))
+			Expect(builder.OperationType).To(Equal("upgrade"))
 		})
 
 		It("returns an error when builder not properly initialised", func() {
@@ -239,6 +350,7 @@ var _ = Describe("Builder", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(builder.Triggerer).ToNot(BeNil())
 			Expect(builder.Triggerer).To(BeAssignableToTypeOf(new(instanceiterator.RecreateTriggerer)))
+			Expect(builder.OperationType).To(Equal("recreate"))
 		})
 
 		It("returns an error when builder not properly initialised", func() {
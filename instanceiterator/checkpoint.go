@@ -0,0 +1,108 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceiterator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+)
+
+// Checkpoint is the persisted progress of an iteration, so that a rerun can
+// carry on where a previous run stopped. OperationType and Fingerprint
+// identify the run which saved it; a run with a different operation or
+// configuration discards it.
+type Checkpoint struct {
+	OperationType     string                            `json:"operation_type"`
+	Fingerprint       string                            `json:"fingerprint"`
+	CanariesCompleted bool                              `json:"canaries_completed"`
+	Instances         map[string]services.BOSHOperation `json:"instances"`
+}
+
+//go:generate counterfeiter -o fakes/fake_checkpointer.go . Checkpointer
+type Checkpointer interface {
+	Load() (*Checkpoint, error)
+	Save(checkpoint Checkpoint) error
+	Clear() error
+}
+
+type FileCheckpointer struct {
+	path string
+}
+
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Load returns nil when no checkpoint has been saved.
+func (c *FileCheckpointer) Load() (*Checkpoint, error) {
+	contents, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %s", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(contents, &checkpoint); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %s: %s", c.path, err)
+	}
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it, so that a
+// crash while saving leaves the previous checkpoint intact.
+func (c *FileCheckpointer) Save(checkpoint Checkpoint) error {
+	contents, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("error serialising checkpoint: %s", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return fmt.Errorf("error writing checkpoint: %s", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(contents); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing checkpoint: %s", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing checkpoint: %s", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("error writing checkpoint: %s", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), c.path); err != nil {
+		return fmt.Errorf("error writing checkpoint: %s", err)
+	}
+	return nil
+}
+
+func (c *FileCheckpointer) Clear() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing checkpoint: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceiterator_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
+)
+
+var _ = Describe("FileCheckpointer", func() {
+	var (
+		dir          string
+		path         string
+		checkpointer *instanceiterator.FileCheckpointer
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "checkpoint")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "checkpoint.json")
+		checkpointer = instanceiterator.NewFileCheckpointer(path)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("loads nothing when no checkpoint has been saved", func() {
+		checkpoint, err := checkpointer.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint).To(BeNil())
+	})
+
+	It("loads the checkpoint which was saved last", func() {
+		first := instanceiterator.Checkpoint{
+			Instances: map[string]services.BOSHOperation{
+				"instance-1": {Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 1}},
+			},
+		}
+		second := instanceiterator.Checkpoint{
+			CanariesCompleted: true,
+			Instances: map[string]services.BOSHOperation{
+				"instance-1": {Type: services.OperationSucceeded, Data: broker.OperationData{BoshTaskID: 1, OperationType: broker.OperationTypeUpgrade}},
+				"instance-2": {Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 2}},
+			},
+		}
+
+		Expect(checkpointer.Save(first)).To(Succeed())
+		Expect(checkpointer.Save(second)).To(Succeed())
+
+		checkpoint, err := checkpointer.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*checkpoint).To(Equal(second))
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1), "temporary files should be cleaned up")
+	})
+
+	It("clears the checkpoint", func() {
+		Expect(checkpointer.Save(instanceiterator.Checkpoint{})).To(Succeed())
+
+		Expect(checkpointer.Clear()).To(Succeed())
+
+		checkpoint, err := checkpointer.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint).To(BeNil())
+	})
+
+	It("does not fail to clear a missing checkpoint", func() {
+		Expect(checkpointer.Clear()).To(Succeed())
+	})
+
+	It("fails to load a corrupt checkpoint", func() {
+		Expect(ioutil.WriteFile(path, []byte("{not json"), 0600)).To(Succeed())
+
+		_, err := checkpointer.Load()
+		Expect(err).To(MatchError(ContainSubstring("error parsing checkpoint " + path)))
+	})
+
+	It("fails to save when the directory does not exist", func() {
+		checkpointer = instanceiterator.NewFileCheckpointer(filepath.Join(dir, "missing", "checkpoint.json"))
+
+		Expect(checkpointer.Save(instanceiterator.Checkpoint{})).To(MatchError(ContainSubstring("error writing checkpoint")))
+	})
+})
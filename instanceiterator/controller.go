@@ -0,0 +1,97 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceiterator
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+)
+
+type Command string
+
+const (
+	CommandContinue Command = "continue"
+	CommandPause    Command = "pause"
+	CommandAbort    Command = "abort"
+)
+
+// Controller is asked on every pass of the iterator whether it should carry
+// on, stop triggering new operations for now, or stop altogether. Operations
+// which have already been triggered are always left to finish.
+//
+//go:generate counterfeiter -o fakes/fake_controller.go . Controller
+type Controller interface {
+	Command() Command
+}
+
+// FileController reads the command from a control file containing "pause"
+// or "abort". A missing or empty file means continue.
+type FileController struct {
+	path string
+}
+
+func NewFileController(path string) *FileController {
+	return &FileController{path: path}
+}
+
+func (c *FileController) Command() Command {
+	contents, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return CommandContinue
+	}
+
+	switch Command(strings.TrimSpace(string(contents))) {
+	case CommandPause:
+		return CommandPause
+	case CommandAbort:
+		return CommandAbort
+	default:
+		return CommandContinue
+	}
+}
+
+type signalController struct {
+	controller     Controller
+	abortRequested int32
+}
+
+// newSignalController returns a Controller which aborts once any of the
+// signals is received, and otherwise defers to controller, if any.
+func newSignalController(controller Controller, signals ...os.Signal) *signalController {
+	c := &signalController{controller: controller}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, signals...)
+	go func() {
+		<-signalChan
+		atomic.StoreInt32(&c.abortRequested, 1)
+	}()
+
+	return c
+}
+
+func (c *signalController) Command() Command {
+	if atomic.LoadInt32(&c.abortRequested) == 1 {
+		return CommandAbort
+	}
+	if c.controller == nil {
+		return CommandContinue
+	}
+	return c.controller.Command()
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceiterator_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator/fakes"
+)
+
+var _ = Describe("Controllers", func() {
+	Describe("FileController", func() {
+		var (
+			dir  string
+			path string
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "control")
+			Expect(err).NotTo(HaveOccurred())
+			path = filepath.Join(dir, "control")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("continues when there is no control file", func() {
+			Expect(instanceiterator.NewFileController(path).Command()).To(Equal(instanceiterator.CommandContinue))
+		})
+
+		DescribeTable("reads the command from the control file",
+			func(contents string, expectedCommand instanceiterator.Command) {
+				Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+				Expect(instanceiterator.NewFileController(path).Command()).To(Equal(expectedCommand))
+			},
+			Entry("pause", "pause\n", instanceiterator.CommandPause),
+			Entry("abort", " abort ", instanceiterator.CommandAbort),
+			Entry("empty", "", instanceiterator.CommandContinue),
+			Entry("anything else", "resume", instanceiterator.CommandContinue),
+		)
+	})
+
+	Describe("aborting on signals", func() {
+		It("aborts once a signal is received and otherwise defers to the controller", func() {
+			fakeController := new(fakes.FakeController)
+			fakeController.CommandReturns(instanceiterator.CommandPause)
+			builder := &instanceiterator.Builder{Controller: fakeController}
+
+			builder.AbortOnSignals(syscall.SIGUSR1)
+			Expect(builder.Controller.Command()).To(Equal(instanceiterator.CommandPause))
+
+			Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR1)).To(Succeed())
+			Eventually(builder.Controller.Command).Should(Equal(instanceiterator.CommandAbort))
+		})
+
+		It("continues until a signal is received when there is no controller", func() {
+			builder := &instanceiterator.Builder{}
+
+			builder.AbortOnSignals(syscall.SIGUSR2)
+			Expect(builder.Controller.Command()).To(Equal(instanceiterator.CommandContinue))
+		})
+	})
+})
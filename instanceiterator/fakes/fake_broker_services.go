@@ -8,6 +8,8 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 )
 
@@ -40,6 +42,30 @@ type FakeBrokerServices struct {
 		result1 brokerapi.LastOperation
 		result2 error
 	}
+	DeployedReleasesStub        func() (map[string][]mgmtapi.Release, error)
+	deployedReleasesMutex       sync.RWMutex
+	deployedReleasesArgsForCall []struct{}
+	deployedReleasesReturns     struct {
+		result1 map[string][]mgmtapi.Release
+		result2 error
+	}
+	deployedReleasesReturnsOnCall map[int]struct {
+		result1 map[string][]mgmtapi.Release
+		result2 error
+	}
+	OperationsStub        func(instance string) ([]operationjournal.Operation, error)
+	operationsMutex       sync.RWMutex
+	operationsArgsForCall []struct {
+		instance string
+	}
+	operationsReturns struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}
+	operationsReturnsOnCall map[int]struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -148,6 +174,100 @@ func (fake *FakeBrokerServices) LastOperationReturnsOnCall(i int, result1 broker
 	}{result1, result2}
 }
 
+func (fake *FakeBrokerServices) DeployedReleases() (map[string][]mgmtapi.Release, error) {
+	fake.deployedReleasesMutex.Lock()
+	ret, specificReturn := fake.deployedReleasesReturnsOnCall[len(fake.deployedReleasesArgsForCall)]
+	fake.deployedReleasesArgsForCall = append(fake.deployedReleasesArgsForCall, struct{}{})
+	fake.recordInvocation("DeployedReleases", []interface{}{})
+	fake.deployedReleasesMutex.Unlock()
+	if fake.DeployedReleasesStub != nil {
+		return fake.DeployedReleasesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.deployedReleasesReturns.result1, fake.deployedReleasesReturns.result2
+}
+
+func (fake *FakeBrokerServices) DeployedReleasesCallCount() int {
+	fake.deployedReleasesMutex.RLock()
+	defer fake.deployedReleasesMutex.RUnlock()
+	return len(fake.deployedReleasesArgsForCall)
+}
+
+func (fake *FakeBrokerServices) DeployedReleasesReturns(result1 map[string][]mgmtapi.Release, result2 error) {
+	fake.DeployedReleasesStub = nil
+	fake.deployedReleasesReturns = struct {
+		result1 map[string][]mgmtapi.Release
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBrokerServices) DeployedReleasesReturnsOnCall(i int, result1 map[string][]mgmtapi.Release, result2 error) {
+	fake.DeployedReleasesStub = nil
+	if fake.deployedReleasesReturnsOnCall == nil {
+		fake.deployedReleasesReturnsOnCall = make(map[int]struct {
+			result1 map[string][]mgmtapi.Release
+			result2 error
+		})
+	}
+	fake.deployedReleasesReturnsOnCall[i] = struct {
+		result1 map[string][]mgmtapi.Release
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBrokerServices) Operations(instance string) ([]operationjournal.Operation, error) {
+	fake.operationsMutex.Lock()
+	ret, specificReturn := fake.operationsReturnsOnCall[len(fake.operationsArgsForCall)]
+	fake.operationsArgsForCall = append(fake.operationsArgsForCall, struct {
+		instance string
+	}{instance})
+	fake.recordInvocation("Operations", []interface{}{instance})
+	fake.operationsMutex.Unlock()
+	if fake.OperationsStub != nil {
+		return fake.OperationsStub(instance)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.operationsReturns.result1, fake.operationsReturns.result2
+}
+
+func (fake *FakeBrokerServices) OperationsCallCount() int {
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	return len(fake.operationsArgsForCall)
+}
+
+func (fake *FakeBrokerServices) OperationsArgsForCall(i int) string {
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	return fake.operationsArgsForCall[i].instance
+}
+
+func (fake *FakeBrokerServices) OperationsReturns(result1 []operationjournal.Operation, result2 error) {
+	fake.OperationsStub = nil
+	fake.operationsReturns = struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBrokerServices) OperationsReturnsOnCall(i int, result1 []operationjournal.Operation, result2 error) {
+	fake.OperationsStub = nil
+	if fake.operationsReturnsOnCall == nil {
+		fake.operationsReturnsOnCall = make(map[int]struct {
+			result1 []operationjournal.Operation
+			result2 error
+		})
+	}
+	fake.operationsReturnsOnCall[i] = struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBrokerServices) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -155,6 +275,10 @@ func (fake *FakeBrokerServices) Invocations() map[string][][]interface{} {
 	defer fake.processInstanceMutex.RUnlock()
 	fake.lastOperationMutex.RLock()
 	defer fake.lastOperationMutex.RUnlock()
+	fake.deployedReleasesMutex.RLock()
+	defer fake.deployedReleasesMutex.RUnlock()
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
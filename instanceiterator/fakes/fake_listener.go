@@ -75,14 +75,15 @@ type FakeListener struct {
 		toRetryCount    int
 		deletedCount    int
 	}
-	FinishedStub        func(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances []string)
+	FinishedStub        func(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances, skippedInstances []string)
 	finishedMutex       sync.RWMutex
 	finishedArgsForCall []struct {
-		orphanCount     int
-		finishedCount   int
-		deletedCount    int
-		busyInstances   []string
-		failedInstances []string
+		orphanCount      int
+		finishedCount    int
+		deletedCount     int
+		busyInstances    []string
+		failedInstances  []string
+		skippedInstances []string
 	}
 	CanariesStartingStub        func(canaries int, filter config.CanarySelectionParams)
 	canariesStartingMutex       sync.RWMutex
@@ -90,11 +91,58 @@ type FakeListener struct {
 		canaries int
 		filter   config.CanarySelectionParams
 	}
-	CanariesFinishedStub        func()
-	canariesFinishedMutex       sync.RWMutex
-	canariesFinishedArgsForCall []struct{}
-	invocations                 map[string][][]interface{}
-	invocationsMutex            sync.RWMutex
+	CanariesFinishedStub          func()
+	canariesFinishedMutex         sync.RWMutex
+	canariesFinishedArgsForCall   []struct{}
+	CheckpointRestoredStub        func(processedCount, inFlightCount int)
+	checkpointRestoredMutex       sync.RWMutex
+	checkpointRestoredArgsForCall []struct {
+		processedCount int
+		inFlightCount  int
+	}
+	CheckpointDiscardedStub        func(operationType, fingerprint string)
+	checkpointDiscardedMutex       sync.RWMutex
+	checkpointDiscardedArgsForCall []struct {
+		operationType string
+		fingerprint   string
+	}
+	FailedToSaveCheckpointStub        func(err error)
+	failedToSaveCheckpointMutex       sync.RWMutex
+	failedToSaveCheckpointArgsForCall []struct {
+		err error
+	}
+	PausedStub        func(inFlightCount int)
+	pausedMutex       sync.RWMutex
+	pausedArgsForCall []struct {
+		inFlightCount int
+	}
+	ResumedStub         func()
+	resumedMutex        sync.RWMutex
+	resumedArgsForCall  []struct{}
+	AbortingStub        func(inFlightCount int)
+	abortingMutex       sync.RWMutex
+	abortingArgsForCall []struct {
+		inFlightCount int
+	}
+	InstancesSelectedStub        func(selectedCount, totalCount int, filters config.InstanceSelectionFilters)
+	instancesSelectedMutex       sync.RWMutex
+	instancesSelectedArgsForCall []struct {
+		selectedCount int
+		totalCount    int
+		filters       config.InstanceSelectionFilters
+	}
+	OutsideMaintenanceWindowStub        func(instance string)
+	outsideMaintenanceWindowMutex       sync.RWMutex
+	outsideMaintenanceWindowArgsForCall []struct {
+		instance string
+	}
+	InstanceRolledBackStub        func(instance string)
+	instanceRolledBackMutex       sync.RWMutex
+	instanceRolledBackArgsForCall []struct {
+		instance string
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
 }
 
 func (fake *FakeListener) FailedToRefreshInstanceInfo(instance string) {
@@ -355,7 +403,7 @@ func (fake *FakeListener) ProgressArgsForCall(i int) (time.Duration, int, int, i
 	return fake.progressArgsForCall[i].pollingInterval, fake.progressArgsForCall[i].orphanCount, fake.progressArgsForCall[i].processedCount, fake.progressArgsForCall[i].toRetryCount, fake.progressArgsForCall[i].deletedCount
 }
 
-func (fake *FakeListener) Finished(orphanCount int, finishedCount int, deletedCount int, busyInstances []string, failedInstances []string) {
+func (fake *FakeListener) Finished(orphanCount int, finishedCount int, deletedCount int, busyInstances []string, failedInstances []string, skippedInstances []string) {
 	var busyInstancesCopy []string
 	if busyInstances != nil {
 		busyInstancesCopy = make([]string, len(busyInstances))
@@ -366,18 +414,24 @@ func (fake *FakeListener) Finished(orphanCount int, finishedCount int, deletedCo
 		failedInstancesCopy = make([]string, len(failedInstances))
 		copy(failedInstancesCopy, failedInstances)
 	}
+	var skippedInstancesCopy []string
+	if skippedInstances != nil {
+		skippedInstancesCopy = make([]string, len(skippedInstances))
+		copy(skippedInstancesCopy, skippedInstances)
+	}
 	fake.finishedMutex.Lock()
 	fake.finishedArgsForCall = append(fake.finishedArgsForCall, struct {
-		orphanCount     int
-		finishedCount   int
-		deletedCount    int
-		busyInstances   []string
-		failedInstances []string
-	}{orphanCount, finishedCount, deletedCount, busyInstancesCopy, failedInstancesCopy})
-	fake.recordInvocation("Finished", []interface{}{orphanCount, finishedCount, deletedCount, busyInstancesCopy, failedInstancesCopy})
+		orphanCount      int
+		finishedCount    int
+		deletedCount     int
+		busyInstances    []string
+		failedInstances  []string
+		skippedInstances []string
+	}{orphanCount, finishedCount, deletedCount, busyInstancesCopy, failedInstancesCopy, skippedInstancesCopy})
+	fake.recordInvocation("Finished", []interface{}{orphanCount, finishedCount, deletedCount, busyInstancesCopy, failedInstancesCopy, skippedInstancesCopy})
 	fake.finishedMutex.Unlock()
 	if fake.FinishedStub != nil {
-		fake.FinishedStub(orphanCount, finishedCount, deletedCount, busyInstances, failedInstances)
+		fake.FinishedStub(orphanCount, finishedCount, deletedCount, busyInstances, failedInstances, skippedInstances)
 	}
 }
 
@@ -387,10 +441,10 @@ func (fake *FakeListener) FinishedCallCount() int {
 	return len(fake.finishedArgsForCall)
 }
 
-func (fake *FakeListener) FinishedArgsForCall(i int) (int, int, int, []string, []string) {
+func (fake *FakeListener) FinishedArgsForCall(i int) (int, int, int, []string, []string, []string) {
 	fake.finishedMutex.RLock()
 	defer fake.finishedMutex.RUnlock()
-	return fake.finishedArgsForCall[i].orphanCount, fake.finishedArgsForCall[i].finishedCount, fake.finishedArgsForCall[i].deletedCount, fake.finishedArgsForCall[i].busyInstances, fake.finishedArgsForCall[i].failedInstances
+	return fake.finishedArgsForCall[i].orphanCount, fake.finishedArgsForCall[i].finishedCount, fake.finishedArgsForCall[i].deletedCount, fake.finishedArgsForCall[i].busyInstances, fake.finishedArgsForCall[i].failedInstances, fake.finishedArgsForCall[i].skippedInstances
 }
 
 func (fake *FakeListener) CanariesStarting(canaries int, filter config.CanarySelectionParams) {
@@ -434,6 +488,218 @@ func (fake *FakeListener) CanariesFinishedCallCount() int {
 	return len(fake.canariesFinishedArgsForCall)
 }
 
+func (fake *FakeListener) CheckpointRestored(processedCount int, inFlightCount int) {
+	fake.checkpointRestoredMutex.Lock()
+	fake.checkpointRestoredArgsForCall = append(fake.checkpointRestoredArgsForCall, struct {
+		processedCount int
+		inFlightCount  int
+	}{processedCount, inFlightCount})
+	fake.recordInvocation("CheckpointRestored", []interface{}{processedCount, inFlightCount})
+	fake.checkpointRestoredMutex.Unlock()
+	if fake.CheckpointRestoredStub != nil {
+		fake.CheckpointRestoredStub(processedCount, inFlightCount)
+	}
+}
+
+func (fake *FakeListener) CheckpointRestoredCallCount() int {
+	fake.checkpointRestoredMutex.RLock()
+	defer fake.checkpointRestoredMutex.RUnlock()
+	return len(fake.checkpointRestoredArgsForCall)
+}
+
+func (fake *FakeListener) CheckpointRestoredArgsForCall(i int) (int, int) {
+	fake.checkpointRestoredMutex.RLock()
+	defer fake.checkpointRestoredMutex.RUnlock()
+	return fake.checkpointRestoredArgsForCall[i].processedCount, fake.checkpointRestoredArgsForCall[i].inFlightCount
+}
+
+func (fake *FakeListener) CheckpointDiscarded(operationType string, fingerprint string) {
+	fake.checkpointDiscardedMutex.Lock()
+	fake.checkpointDiscardedArgsForCall = append(fake.checkpointDiscardedArgsForCall, struct {
+		operationType string
+		fingerprint   string
+	}{operationType, fingerprint})
+	fake.recordInvocation("CheckpointDiscarded", []interface{}{operationType, fingerprint})
+	fake.checkpointDiscardedMutex.Unlock()
+	if fake.CheckpointDiscardedStub != nil {
+		fake.CheckpointDiscardedStub(operationType, fingerprint)
+	}
+}
+
+func (fake *FakeListener) CheckpointDiscardedCallCount() int {
+	fake.checkpointDiscardedMutex.RLock()
+	defer fake.checkpointDiscardedMutex.RUnlock()
+	return len(fake.checkpointDiscardedArgsForCall)
+}
+
+func (fake *FakeListener) CheckpointDiscardedArgsForCall(i int) (string, string) {
+	fake.checkpointDiscardedMutex.RLock()
+	defer fake.checkpointDiscardedMutex.RUnlock()
+	return fake.checkpointDiscardedArgsForCall[i].operationType, fake.checkpointDiscardedArgsForCall[i].fingerprint
+}
+
+func (fake *FakeListener) FailedToSaveCheckpoint(err error) {
+	fake.failedToSaveCheckpointMutex.Lock()
+	fake.failedToSaveCheckpointArgsForCall = append(fake.failedToSaveCheckpointArgsForCall, struct {
+		err error
+	}{err})
+	fake.recordInvocation("FailedToSaveCheckpoint", []interface{}{err})
+	fake.failedToSaveCheckpointMutex.Unlock()
+	if fake.FailedToSaveCheckpointStub != nil {
+		fake.FailedToSaveCheckpointStub(err)
+	}
+}
+
+func (fake *FakeListener) FailedToSaveCheckpointCallCount() int {
+	fake.failedToSaveCheckpointMutex.RLock()
+	defer fake.failedToSaveCheckpointMutex.RUnlock()
+	return len(fake.failedToSaveCheckpointArgsForCall)
+}
+
+func (fake *FakeListener) FailedToSaveCheckpointArgsForCall(i int) error {
+	fake.failedToSaveCheckpointMutex.RLock()
+	defer fake.failedToSaveCheckpointMutex.RUnlock()
+	return fake.failedToSaveCheckpointArgsForCall[i].err
+}
+
+func (fake *FakeListener) Paused(inFlightCount int) {
+	fake.pausedMutex.Lock()
+	fake.pausedArgsForCall = append(fake.pausedArgsForCall, struct {
+		inFlightCount int
+	}{inFlightCount})
+	fake.recordInvocation("Paused", []interface{}{inFlightCount})
+	fake.pausedMutex.Unlock()
+	if fake.PausedStub != nil {
+		fake.PausedStub(inFlightCount)
+	}
+}
+
+func (fake *FakeListener) PausedCallCount() int {
+	fake.pausedMutex.RLock()
+	defer fake.pausedMutex.RUnlock()
+	return len(fake.pausedArgsForCall)
+}
+
+func (fake *FakeListener) PausedArgsForCall(i int) int {
+	fake.pausedMutex.RLock()
+	defer fake.pausedMutex.RUnlock()
+	return fake.pausedArgsForCall[i].inFlightCount
+}
+
+func (fake *FakeListener) Resumed() {
+	fake.resumedMutex.Lock()
+	fake.resumedArgsForCall = append(fake.resumedArgsForCall, struct{}{})
+	fake.recordInvocation("Resumed", []interface{}{})
+	fake.resumedMutex.Unlock()
+	if fake.ResumedStub != nil {
+		fake.ResumedStub()
+	}
+}
+
+func (fake *FakeListener) ResumedCallCount() int {
+	fake.resumedMutex.RLock()
+	defer fake.resumedMutex.RUnlock()
+	return len(fake.resumedArgsForCall)
+}
+
+func (fake *FakeListener) Aborting(inFlightCount int) {
+	fake.abortingMutex.Lock()
+	fake.abortingArgsForCall = append(fake.abortingArgsForCall, struct {
+		inFlightCount int
+	}{inFlightCount})
+	fake.recordInvocation("Aborting", []interface{}{inFlightCount})
+	fake.abortingMutex.Unlock()
+	if fake.AbortingStub != nil {
+		fake.AbortingStub(inFlightCount)
+	}
+}
+
+func (fake *FakeListener) AbortingCallCount() int {
+	fake.abortingMutex.RLock()
+	defer fake.abortingMutex.RUnlock()
+	return len(fake.abortingArgsForCall)
+}
+
+func (fake *FakeListener) AbortingArgsForCall(i int) int {
+	fake.abortingMutex.RLock()
+	defer fake.abortingMutex.RUnlock()
+	return fake.abortingArgsForCall[i].inFlightCount
+}
+
+func (fake *FakeListener) InstancesSelected(selectedCount int, totalCount int, filters config.InstanceSelectionFilters) {
+	fake.instancesSelectedMutex.Lock()
+	fake.instancesSelectedArgsForCall = append(fake.instancesSelectedArgsForCall, struct {
+		selectedCount int
+		totalCount    int
+		filters       config.InstanceSelectionFilters
+	}{selectedCount, totalCount, filters})
+	fake.recordInvocation("InstancesSelected", []interface{}{selectedCount, totalCount, filters})
+	fake.instancesSelectedMutex.Unlock()
+	if fake.InstancesSelectedStub != nil {
+		fake.InstancesSelectedStub(selectedCount, totalCount, filters)
+	}
+}
+
+func (fake *FakeListener) InstancesSelectedCallCount() int {
+	fake.instancesSelectedMutex.RLock()
+	defer fake.instancesSelectedMutex.RUnlock()
+	return len(fake.instancesSelectedArgsForCall)
+}
+
+func (fake *FakeListener) InstancesSelectedArgsForCall(i int) (int, int, config.InstanceSelectionFilters) {
+	fake.instancesSelectedMutex.RLock()
+	defer fake.instancesSelectedMutex.RUnlock()
+	return fake.instancesSelectedArgsForCall[i].selectedCount, fake.instancesSelectedArgsForCall[i].totalCount, fake.instancesSelectedArgsForCall[i].filters
+}
+
+func (fake *FakeListener) OutsideMaintenanceWindow(instance string) {
+	fake.outsideMaintenanceWindowMutex.Lock()
+	fake.outsideMaintenanceWindowArgsForCall = append(fake.outsideMaintenanceWindowArgsForCall, struct {
+		instance string
+	}{instance})
+	fake.recordInvocation("OutsideMaintenanceWindow", []interface{}{instance})
+	fake.outsideMaintenanceWindowMutex.Unlock()
+	if fake.OutsideMaintenanceWindowStub != nil {
+		fake.OutsideMaintenanceWindowStub(instance)
+	}
+}
+
+func (fake *FakeListener) OutsideMaintenanceWindowCallCount() int {
+	fake.outsideMaintenanceWindowMutex.RLock()
+	defer fake.outsideMaintenanceWindowMutex.RUnlock()
+	return len(fake.outsideMaintenanceWindowArgsForCall)
+}
+
+func (fake *FakeListener) OutsideMaintenanceWindowArgsForCall(i int) string {
+	fake.outsideMaintenanceWindowMutex.RLock()
+	defer fake.outsideMaintenanceWindowMutex.RUnlock()
+	return fake.outsideMaintenanceWindowArgsForCall[i].instance
+}
+
+func (fake *FakeListener) InstanceRolledBack(instance string) {
+	fake.instanceRolledBackMutex.Lock()
+	fake.instanceRolledBackArgsForCall = append(fake.instanceRolledBackArgsForCall, struct {
+		instance string
+	}{instance})
+	fake.recordInvocation("InstanceRolledBack", []interface{}{instance})
+	fake.instanceRolledBackMutex.Unlock()
+	if fake.InstanceRolledBackStub != nil {
+		fake.InstanceRolledBackStub(instance)
+	}
+}
+
+func (fake *FakeListener) InstanceRolledBackCallCount() int {
+	fake.instanceRolledBackMutex.RLock()
+	defer fake.instanceRolledBackMutex.RUnlock()
+	return len(fake.instanceRolledBackArgsForCall)
+}
+
+func (fake *FakeListener) InstanceRolledBackArgsForCall(i int) string {
+	fake.instanceRolledBackMutex.RLock()
+	defer fake.instanceRolledBackMutex.RUnlock()
+	return fake.instanceRolledBackArgsForCall[i].instance
+}
+
 func (fake *FakeListener) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -463,6 +729,24 @@ func (fake *FakeListener) Invocations() map[string][][]interface{} {
 	defer fake.canariesStartingMutex.RUnlock()
 	fake.canariesFinishedMutex.RLock()
 	defer fake.canariesFinishedMutex.RUnlock()
+	fake.checkpointRestoredMutex.RLock()
+	defer fake.checkpointRestoredMutex.RUnlock()
+	fake.checkpointDiscardedMutex.RLock()
+	defer fake.checkpointDiscardedMutex.RUnlock()
+	fake.failedToSaveCheckpointMutex.RLock()
+	defer fake.failedToSaveCheckpointMutex.RUnlock()
+	fake.pausedMutex.RLock()
+	defer fake.pausedMutex.RUnlock()
+	fake.resumedMutex.RLock()
+	defer fake.resumedMutex.RUnlock()
+	fake.abortingMutex.RLock()
+	defer fake.abortingMutex.RUnlock()
+	fake.instancesSelectedMutex.RLock()
+	defer fake.instancesSelectedMutex.RUnlock()
+	fake.outsideMaintenanceWindowMutex.RLock()
+	defer fake.outsideMaintenanceWindowMutex.RUnlock()
+	fake.instanceRolledBackMutex.RLock()
+	defer fake.instanceRolledBackMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -18,6 +18,8 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 )
 
@@ -33,15 +35,26 @@ type Listener interface {
 	InstanceOperationFinished(instance string, result string)
 	WaitingFor(instance string, boshTaskId int)
 	Progress(pollingInterval time.Duration, orphanCount, processedCount, toRetryCount, deletedCount int)
-	Finished(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances []string)
+	Finished(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances, skippedInstances []string)
 	CanariesStarting(canaries int, filter config.CanarySelectionParams)
 	CanariesFinished()
+	CheckpointRestored(processedCount, inFlightCount int)
+	CheckpointDiscarded(operationType, fingerprint string)
+	FailedToSaveCheckpoint(err error)
+	Paused(inFlightCount int)
+	Resumed()
+	Aborting(inFlightCount int)
+	InstancesSelected(selectedCount, totalCount int, filters config.InstanceSelectionFilters)
+	OutsideMaintenanceWindow(instance string)
+	InstanceRolledBack(instance string)
 }
 
 //go:generate counterfeiter -o fakes/fake_broker_services.go . BrokerServices
 type BrokerServices interface {
 	ProcessInstance(instance service.Instance, operationType string) (services.BOSHOperation, error)
 	LastOperation(instance string, operationData broker.OperationData) (brokerapi.LastOperation, error)
+	DeployedReleases() (map[string][]mgmtapi.Release, error)
+	Operations(instance string) ([]operationjournal.Operation, error)
 }
 
 //go:generate counterfeiter -o fakes/fake_instance_lister.go . InstanceLister
@@ -83,9 +96,17 @@ type Iterator struct {
 	failures              []instanceFailure
 	canaries              int
 	canarySelectionParams config.CanarySelectionParams
+	selectionFilters      config.InstanceSelectionFilters
 	iteratorState         *iteratorState
 	triggerer             Triggerer
 	stateChecker          StateChecker
+	checkpointer          Checkpointer
+	operationType         string
+	fingerprint           string
+	controller            Controller
+	maintenanceSchedule   MaintenanceSchedule
+	paused                bool
+	aborting              bool
 }
 
 func New(builder *Builder) *Iterator {
@@ -100,8 +121,14 @@ func New(builder *Builder) *Iterator {
 		sleeper:               builder.Sleeper,
 		canaries:              builder.Canaries,
 		canarySelectionParams: builder.CanarySelectionParams,
+		selectionFilters:      builder.SelectionFilters,
 		triggerer:             builder.Triggerer,
 		stateChecker:          NewStateChecker(builder.BrokerServices),
+		checkpointer:          builder.Checkpointer,
+		operationType:         builder.OperationType,
+		fingerprint:           builder.Fingerprint,
+		controller:            builder.Controller,
+		maintenanceSchedule:   builder.MaintenanceSchedule,
 	}
 }
 
@@ -114,6 +141,10 @@ func (it *Iterator) Iterate() error {
 
 	it.listener.InstancesToProcess(it.iteratorState.AllInstances())
 
+	if err := it.restoreCheckpoint(); err != nil {
+		return err
+	}
+
 	if it.iteratorState.IsProcessingCanaries() {
 		it.listener.CanariesStarting(it.iteratorState.OutstandingCanaryCount(), it.canarySelectionParams)
 		if err := it.IterateInstancesWithAttempts(); err != nil {
@@ -121,6 +152,7 @@ func (it *Iterator) Iterate() error {
 			return err
 		}
 		it.iteratorState.MarkCanariesCompleted()
+		it.saveCheckpoint()
 		it.listener.CanariesFinished()
 	}
 
@@ -129,6 +161,10 @@ func (it *Iterator) Iterate() error {
 		return err
 	}
 	it.printSummary()
+	if skippedInstances := it.iteratorState.GetGUIDsInStates(services.OutsideMaintenanceWindow); len(skippedInstances) > 0 {
+		return SkippedInstancesError{Instances: skippedInstances, Checkpointed: it.checkpointer != nil}
+	}
+	it.clearCheckpoint()
 	return nil
 }
 
@@ -138,12 +174,18 @@ func (it *Iterator) IterateInstancesWithAttempts() error {
 		it.logRetryAttempt(attempt)
 
 		for it.iteratorState.HasInstancesToProcess() {
-			if !it.iteratorState.HasFailures() {
+			command := it.command()
+			if !it.iteratorState.HasFailures() && command == CommandContinue {
 				it.triggerOperation()
 			}
 			it.pollRunningTasks()
+			it.saveCheckpoint()
 
-			if it.iteratorState.HasInstancesProcessing() {
+			if command == CommandAbort && !it.iteratorState.HasInstancesProcessing() {
+				return it.abortError()
+			}
+
+			if it.iteratorState.HasInstancesProcessing() || command == CommandPause {
 				it.sleeper.Sleep(it.pollingInterval)
 				continue
 			}
@@ -168,12 +210,108 @@ func (it *Iterator) IterateInstancesWithAttempts() error {
 	return it.checkStillBusyInstances()
 }
 
+func (it *Iterator) restoreCheckpoint() error {
+	if it.checkpointer == nil {
+		return nil
+	}
+
+	checkpoint, err := it.checkpointer.Load()
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		return nil
+	}
+
+	if checkpoint.OperationType != it.operationType || checkpoint.Fingerprint != it.fingerprint {
+		it.listener.CheckpointDiscarded(checkpoint.OperationType, checkpoint.Fingerprint)
+		it.clearCheckpoint()
+		return nil
+	}
+
+	processedCount, inFlightCount := it.iteratorState.Restore(*checkpoint)
+	it.listener.CheckpointRestored(processedCount, inFlightCount)
+	return nil
+}
+
+func (it *Iterator) saveCheckpoint() {
+	if it.checkpointer == nil {
+		return
+	}
+
+	checkpoint := it.iteratorState.Checkpoint()
+	checkpoint.OperationType = it.operationType
+	checkpoint.Fingerprint = it.fingerprint
+	if err := it.checkpointer.Save(checkpoint); err != nil {
+		it.listener.FailedToSaveCheckpoint(err)
+	}
+}
+
+func (it *Iterator) clearCheckpoint() {
+	if it.checkpointer == nil {
+		return
+	}
+
+	if err := it.checkpointer.Clear(); err != nil {
+		it.listener.FailedToSaveCheckpoint(err)
+	}
+}
+
+// command asks the controller what to do next and reports when the iterator
+// pauses, resumes or starts aborting.
+func (it *Iterator) command() Command {
+	if it.controller == nil {
+		return CommandContinue
+	}
+
+	command := it.controller.Command()
+	inFlightCount := it.iteratorState.CountInProgressInstances()
+	switch {
+	case command == CommandAbort && !it.aborting:
+		it.aborting = true
+		it.listener.Aborting(inFlightCount)
+	case command == CommandPause && !it.paused:
+		it.paused = true
+		it.listener.Paused(inFlightCount)
+	case command == CommandContinue && it.paused:
+		it.paused = false
+		it.listener.Resumed()
+	}
+	return command
+}
+
+// SkippedInstancesExitCode is the exit code of an errand which processed every
+// instance it could, but left some outside their maintenance window.
+const SkippedInstancesExitCode = 3
+
+// SkippedInstancesError is returned by Iterate when instances were still
+// outside their maintenance window once all attempts were used up.
+type SkippedInstancesError struct {
+	Instances    []string
+	Checkpointed bool
+}
+
+func (e SkippedInstancesError) Error() string {
+	message := fmt.Sprintf("the following instances were outside their maintenance window and have not been processed: %s", strings.Join(e.Instances, ", "))
+	if !e.Checkpointed {
+		return message + "; no checkpoint is configured, so the next run will process every instance again"
+	}
+	return message + "; the next run will only process these instances"
+}
+
+func (it *Iterator) abortError() error {
+	if it.checkpointer == nil {
+		return errors.New("operation aborted")
+	}
+	return errors.New("operation aborted: progress has been saved and will be resumed on the next run")
+}
+
 func (it *Iterator) registerInstancesAndCanaries() error {
 	var canaryInstances []service.Instance
 
-	allInstances, err := it.instanceLister.Instances()
+	allInstances, err := it.selectInstances()
 	if err != nil {
-		return fmt.Errorf("error listing service instances: %s", err)
+		return err
 	}
 
 	if len(it.canarySelectionParams) > 0 {
@@ -181,6 +319,7 @@ func (it *Iterator) registerInstancesAndCanaries() error {
 		if err != nil {
 			return fmt.Errorf("error listing service instances: %s", err)
 		}
+		canaryInstances = intersectInstances(canaryInstances, allInstances)
 		if len(canaryInstances) == 0 && len(allInstances) > 0 {
 			return fmt.Errorf("Failed to find a match to the canary selection criteria: %s. "+
 				"Please ensure these selection criteria will match one or more service instances, "+
@@ -200,6 +339,12 @@ func (it *Iterator) registerInstancesAndCanaries() error {
 	if err != nil {
 		return fmt.Errorf("error with canary instance listing: %s", err)
 	}
+
+	if it.maintenanceSchedule != nil {
+		if err := it.maintenanceSchedule.Load(); err != nil {
+			return fmt.Errorf("error loading maintenance windows: %s", err)
+		}
+	}
 	return nil
 }
 
@@ -237,6 +382,9 @@ func (it *Iterator) triggerOperation() {
 		if err != nil {
 			break
 		}
+		if !it.inMaintenanceWindow(instance) {
+			continue
+		}
 		it.listener.InstanceOperationStarting(instance.GUID, it.iteratorState.GetIteratorIndex(), totalInstances, it.iteratorState.IsProcessingCanaries())
 
 		var operation services.BOSHOperation
@@ -268,6 +416,18 @@ func (it *Iterator) triggerOperation() {
 	}
 }
 
+// inMaintenanceWindow reports whether the instance can be processed now. When
+// it cannot, the instance is set aside to be revisited on the next attempt.
+func (it *Iterator) inMaintenanceWindow(instance service.Instance) bool {
+	if it.maintenanceSchedule == nil || it.maintenanceSchedule.IsOpen(instance.GUID, time.Now()) {
+		return true
+	}
+
+	it.iteratorState.SetState(instance.GUID, services.OutsideMaintenanceWindow)
+	it.listener.OutsideMaintenanceWindow(instance.GUID)
+	return false
+}
+
 func (it *Iterator) pollRunningTasks() {
 	for _, inst := range it.iteratorState.InProgressInstances() {
 		guid := inst.GUID
@@ -284,12 +444,31 @@ func (it *Iterator) pollRunningTasks() {
 			it.listener.InstanceOperationFinished(guid, "success")
 		case services.OperationFailed:
 			it.listener.InstanceOperationFinished(guid, "failure")
+			if state.Data.RollbackOnFailure && it.rolledBack(guid, state.Data) {
+				it.listener.InstanceRolledBack(guid)
+			}
 			err := fmt.Errorf("[%s] Operation failed: bosh task id %d: %s", guid, state.Data.BoshTaskID, state.Description)
 			it.failures = append(it.failures, instanceFailure{guid: guid, err: err})
 		}
 	}
 }
 
+// rolledBack reports whether the broker rolled back the failed upgrade. Only
+// the operation journal records a rollback, so when the journal is disabled or
+// cannot be read no rollback is reported.
+func (it *Iterator) rolledBack(guid string, operationData broker.OperationData) bool {
+	operations, err := it.brokerServices.Operations(guid)
+	if err != nil {
+		return false
+	}
+	for _, operation := range operations {
+		if operation.ID == broker.JournalOperationID(operationData) {
+			return operation.RolledBack
+		}
+	}
+	return false
+}
+
 func (it *Iterator) reportProgress() {
 	summary := it.iteratorState.Summary()
 	it.listener.Progress(it.attemptInterval, summary.orphaned, summary.succeeded, summary.busy, summary.deleted)
@@ -305,7 +484,9 @@ func (it *Iterator) printSummary() {
 		failedInstances = append(failedInstances, failure.guid)
 	}
 
-	it.listener.Finished(summary.orphaned, summary.succeeded, summary.deleted, busyInstances, failedInstances)
+	skippedInstances := it.iteratorState.GetGUIDsInStates(services.OutsideMaintenanceWindow)
+
+	it.listener.Finished(summary.orphaned, summary.succeeded, summary.deleted, busyInstances, failedInstances, skippedInstances)
 }
 
 func (it *Iterator) checkStillBusyInstances() error {
@@ -313,6 +494,13 @@ func (it *Iterator) checkStillBusyInstances() error {
 	busyInstancesCount := len(busyInstances)
 
 	if busyInstancesCount == 0 {
+		skippedCanaries := it.iteratorState.GetGUIDsInStates(services.OutsideMaintenanceWindow)
+		if it.iteratorState.IsProcessingCanaries() && !it.iteratorState.canariesCompleted() && len(skippedCanaries) > 0 {
+			return fmt.Errorf(
+				"canaries didn't process successfully: the following canary instances are outside their maintenance window: %s",
+				strings.Join(skippedCanaries, ", "),
+			)
+		}
 		return nil
 	}
 
@@ -347,7 +535,7 @@ func (it *Iterator) errorFromList() error {
 		for _, e := range failureList {
 			out += "\n* " + e.err.Error()
 		}
-		return fmt.Errorf(out)
+		return errors.New(out)
 	}
 	return nil
 }
@@ -80,7 +80,7 @@ func (is *iteratorState) IsProcessingCanaries() bool {
 func (is *iteratorState) RewindAndResetBusyInstances() {
 	is.pos = 0
 	for k, v := range is.states {
-		if v.status == services.OperationInProgress {
+		if v.status == services.OperationInProgress || v.status == services.OutsideMaintenanceWindow {
 			v.status = services.OperationPending
 			is.states[k] = v
 		}
@@ -192,7 +192,7 @@ func (is *iteratorState) OutstandingCanaryCount() int {
 		if !info.couldBeCanary {
 			continue
 		}
-		if info.status == services.OperationPending {
+		if info.status == services.OperationPending || info.status == services.OutsideMaintenanceWindow {
 			pending++
 		} else {
 			triggered++
@@ -270,9 +270,58 @@ func (is *iteratorState) notDoingCanariesAndPendingInstance(guid string) bool {
 		is.states[guid].status == services.OperationPending
 }
 
+// Checkpoint records the instances which have been processed or have an
+// operation in flight. Instances still to be processed are left out.
+func (is *iteratorState) Checkpoint() Checkpoint {
+	checkpoint := Checkpoint{
+		CanariesCompleted: !is.processCanaries,
+		Instances:         map[string]services.BOSHOperation{},
+	}
+	for guid, info := range is.states {
+		if info.status == services.OperationPending {
+			continue
+		}
+		checkpoint.Instances[guid] = services.BOSHOperation{
+			Type:        info.status,
+			Data:        info.operation.Data,
+			Description: info.operation.Description,
+		}
+	}
+	return checkpoint
+}
+
+// Restore marks the instances which a previous run processed as done, and
+// the ones it left with an operation in flight as accepted, so that they are
+// polled rather than triggered again. Instances which failed or were busy are
+// processed again.
+func (is *iteratorState) Restore(checkpoint Checkpoint) (processedCount, inFlightCount int) {
+	for guid, operation := range checkpoint.Instances {
+		info, found := is.states[guid]
+		if !found {
+			continue
+		}
+
+		switch operation.Type {
+		case services.OperationSucceeded, services.InstanceNotFound, services.OrphanDeployment:
+			info.status = operation.Type
+			processedCount++
+		case services.OperationAccepted:
+			info.status = operation.Type
+			info.operation = operation
+			inFlightCount++
+		}
+		is.states[guid] = info
+	}
+
+	if checkpoint.CanariesCompleted && is.processCanaries {
+		is.MarkCanariesCompleted()
+	}
+	return processedCount, inFlightCount
+}
+
 func isFinalState(status services.BOSHOperationType) bool {
 	// TODO:
 	// * add tests
 	// * add missing states
-	return status != services.OperationInProgress && status != services.OperationPending && status != services.OperationAccepted //status == services.OperationSucceeded || status == services.OperationFailed
+	return status != services.OperationInProgress && status != services.OperationPending && status != services.OperationAccepted && status != services.OutsideMaintenanceWindow //status == services.OperationSucceeded || status == services.OperationFailed
 }
@@ -21,6 +21,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
@@ -184,6 +185,69 @@ var _ = Describe("Iterate State", func() {
 		Expect(next.GUID).To(Equal("guid_3"))
 	})
 
+	Describe("checkpointing", func() {
+		It("records instances which are no longer pending", func() {
+			canaries, all := instances(func(i int) bool { return i == 0 }, 4)
+			us, err := instanceiterator.NewIteratorState(canaries, all, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			operation := services.BOSHOperation{Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 3}}
+			us.SetOperation("guid_0", operation)
+			us.SetState("guid_0", services.OperationSucceeded)
+			us.SetOperation("guid_1", operation)
+			us.SetState("guid_1", services.OperationAccepted)
+
+			checkpoint := us.Checkpoint()
+			Expect(checkpoint.CanariesCompleted).To(BeFalse())
+			Expect(checkpoint.Instances).To(Equal(map[string]services.BOSHOperation{
+				"guid_0": {Type: services.OperationSucceeded, Data: broker.OperationData{BoshTaskID: 3}},
+				"guid_1": {Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 3}},
+			}))
+
+			us.MarkCanariesCompleted()
+			Expect(us.Checkpoint().CanariesCompleted).To(BeTrue())
+		})
+
+		It("restores processed and in flight instances, and retries the others", func() {
+			canaries, all := instances(func(i int) bool { return i == 0 }, 6)
+			us, err := instanceiterator.NewIteratorState(canaries, all, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			inFlight := services.BOSHOperation{Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 42}}
+			processedCount, inFlightCount := us.Restore(instanceiterator.Checkpoint{
+				CanariesCompleted: true,
+				Instances: map[string]services.BOSHOperation{
+					"guid_0":          {Type: services.OperationSucceeded},
+					"guid_1":          {Type: services.OrphanDeployment},
+					"guid_2":          inFlight,
+					"guid_3":          {Type: services.OperationFailed},
+					"guid_4":          {Type: services.OperationInProgress},
+					"unknown-guid_99": {Type: services.OperationSucceeded},
+				},
+			})
+
+			Expect(processedCount).To(Equal(2))
+			Expect(inFlightCount).To(Equal(1))
+			Expect(us.IsProcessingCanaries()).To(BeFalse())
+			Expect(us.GetGUIDsInStates(services.OperationSucceeded, services.OrphanDeployment)).To(ConsistOf("guid_0", "guid_1"))
+			Expect(us.InProgressInstances()).To(ConsistOf(service.Instance{GUID: "guid_2", PlanUniqueID: "plan"}))
+			Expect(us.GetOperation("guid_2")).To(Equal(inFlight))
+			Expect(us.GetGUIDsInStates(services.OperationPending)).To(ConsistOf("guid_3", "guid_4", "guid_5"))
+		})
+
+		It("keeps processing canaries when the checkpoint was saved before they completed", func() {
+			canaries, all := instances(func(i int) bool { return i < 2 }, 4)
+			us, err := instanceiterator.NewIteratorState(canaries, all, 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			us.Restore(instanceiterator.Checkpoint{
+				Instances: map[string]services.BOSHOperation{"guid_0": {Type: services.OperationSucceeded}},
+			})
+
+			Expect(us.IsProcessingCanaries()).To(BeTrue())
+			Expect(us.OutstandingCanaryCount()).To(Equal(1))
+		})
+	})
 })
 
 func instances(isCanary func(int) bool, total int) (canaries []service.Instance, all []service.Instance) {
@@ -34,6 +34,7 @@ type testState struct {
 	lastOperationOutput    []brokerapi.LastOperationState
 	lastOperationCallCount int
 	taskID                 int
+	rollbackOnFailure      bool
 	controller             *processController
 }
 
@@ -55,7 +56,7 @@ func setupTest(states []*testState, instanceLister *fakes.FakeInstanceLister, br
 				s.iteratorCallCount++
 				return services.BOSHOperation{
 					Type: s.iteratorOutput[s.iteratorCallCount-1],
-					Data: broker.OperationData{BoshTaskID: s.taskID, OperationType: broker.OperationTypeUpgrade},
+					Data: broker.OperationData{BoshTaskID: s.taskID, OperationType: broker.OperationTypeUpgrade, RollbackOnFailure: s.rollbackOnFailure},
 				}, nil
 			}
 		}
@@ -78,7 +79,7 @@ func setupTest(states []*testState, instanceLister *fakes.FakeInstanceLister, br
 
 func hasReportedFinished(fakeListener *fakes.FakeListener, expectedOrphans, expectedProcessed, expectedDeleted int, expectedBusyInstances []string, expectedFailedInstances []string) {
 	Expect(fakeListener.FinishedCallCount()).To(Equal(1), "Finished call count")
-	orphanCount, processedCount, deletedCount, busyInstances, failedInstances := fakeListener.FinishedArgsForCall(0)
+	orphanCount, processedCount, deletedCount, busyInstances, failedInstances, _ := fakeListener.FinishedArgsForCall(0)
 	Expect(orphanCount).To(Equal(expectedOrphans), "orphans")
 	Expect(processedCount).To(Equal(expectedProcessed), "processed")
 	Expect(deletedCount).To(Equal(expectedDeleted), "deleted")
@@ -11,15 +11,20 @@ import (
 	"fmt"
 	"time"
 
+	"strconv"
 	"sync"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
 	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
 )
 
@@ -428,6 +433,72 @@ var _ = Describe("Iterator", func() {
 			hasReportedFinished(fakeListener, 0, 1, 0, []string{}, []string{states[1].instance.GUID})
 		})
 
+		Context("when an upgrade that can be rolled back fails", func() {
+			BeforeEach(func() {
+				states := []*testState{
+					{instance: service.Instance{GUID: "1"}, iteratorOutput: []services.BOSHOperationType{services.OperationAccepted}, taskID: 1, rollbackOnFailure: true},
+				}
+				setupTest(states, instanceLister, brokerServicesClient)
+				brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{
+					State:       brokerapi.Failed,
+					Description: "Failed for bosh task: 1, rolled back to the previous deployment",
+				}, nil)
+				brokerServicesClient.LastOperationStub = nil
+
+				builder.AttemptLimit = 1
+			})
+
+			It("reports the instance as rolled back when the operation journal records the rollback", func() {
+				brokerServicesClient.OperationsReturns([]operationjournal.Operation{
+					{ID: "2", RolledBack: false},
+					{ID: "1", RolledBack: true},
+				}, nil)
+
+				iteratorError = instanceiterator.New(&builder).Iterate()
+
+				Expect(iteratorError).To(MatchError(ContainSubstring("[1] Operation failed: bosh task id 1: Failed for bosh task: 1, rolled back to the previous deployment")))
+				Expect(brokerServicesClient.OperationsCallCount()).To(Equal(1))
+				Expect(brokerServicesClient.OperationsArgsForCall(0)).To(Equal("1"))
+				Expect(fakeListener.InstanceRolledBackCallCount()).To(Equal(1))
+				Expect(fakeListener.InstanceRolledBackArgsForCall(0)).To(Equal("1"))
+				hasReportedFinished(fakeListener, 0, 0, 0, []string{}, []string{"1"})
+			})
+
+			It("does not report a rollback the operation journal does not record", func() {
+				brokerServicesClient.OperationsReturns([]operationjournal.Operation{{ID: "1"}}, nil)
+
+				iteratorError = instanceiterator.New(&builder).Iterate()
+
+				Expect(iteratorError).To(HaveOccurred())
+				Expect(fakeListener.InstanceRolledBackCallCount()).To(Equal(0))
+			})
+
+			It("does not report a rollback when the operation journal cannot be read", func() {
+				brokerServicesClient.OperationsReturns(nil, errors.New("HTTP response status: 501 Not Implemented"))
+
+				iteratorError = instanceiterator.New(&builder).Iterate()
+
+				Expect(iteratorError).To(HaveOccurred())
+				Expect(fakeListener.InstanceRolledBackCallCount()).To(Equal(0))
+			})
+		})
+
+		It("does not look for a rollback of an upgrade that cannot be rolled back", func() {
+			states := []*testState{
+				{instance: service.Instance{GUID: "1"}, iteratorOutput: []services.BOSHOperationType{services.OperationAccepted}, taskID: 1},
+			}
+			setupTest(states, instanceLister, brokerServicesClient)
+			brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed, Description: "Failed for bosh task: 1"}, nil)
+			brokerServicesClient.LastOperationStub = nil
+
+			builder.AttemptLimit = 1
+			iteratorError = instanceiterator.New(&builder).Iterate()
+
+			Expect(iteratorError).To(HaveOccurred())
+			Expect(brokerServicesClient.OperationsCallCount()).To(Equal(0))
+			Expect(fakeListener.InstanceRolledBackCallCount()).To(Equal(0))
+		})
+
 		It("retries until a deleted instance is detected", func() {
 			states := []*testState{
 				{instance: service.Instance{GUID: "1"}, iteratorOutput: []services.BOSHOperationType{services.OperationAccepted}, lastOperationOutput: []brokerapi.LastOperationState{brokerapi.Succeeded}, taskID: 1},
@@ -1357,4 +1428,448 @@ var _ = Describe("Iterator", func() {
 			})
 		})
 	})
+
+	Context("checkpointing and control", func() {
+		var (
+			fakeCheckpointer *fakes.FakeCheckpointer
+			fakeController   *fakes.FakeController
+		)
+
+		BeforeEach(func() {
+			fakeCheckpointer = new(fakes.FakeCheckpointer)
+			fakeController = new(fakes.FakeController)
+			fakeController.CommandReturns(instanceiterator.CommandContinue)
+			builder.Checkpointer = fakeCheckpointer
+			builder.OperationType = "upgrade"
+			builder.Fingerprint = "some-fingerprint"
+			builder.Controller = fakeController
+
+			instanceLister.InstancesReturns([]service.Instance{{GUID: "1"}, {GUID: "2"}, {GUID: "3"}}, nil)
+			instanceLister.LatestInstanceInfoStub = func(inst service.Instance) (service.Instance, error) {
+				return inst, nil
+			}
+			brokerServicesClient.ProcessInstanceStub = func(inst service.Instance, operationType string) (services.BOSHOperation, error) {
+				taskID, _ := strconv.Atoi(inst.GUID)
+				return services.BOSHOperation{Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: taskID}}, nil
+			}
+			brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+		})
+
+		It("saves a checkpoint as it goes and clears it once all instances are processed", func() {
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeCheckpointer.SaveCallCount()).To(Equal(3))
+			firstCheckpoint := fakeCheckpointer.SaveArgsForCall(0)
+			Expect(firstCheckpoint.Instances).To(Equal(map[string]services.BOSHOperation{
+				"1": {Type: services.OperationSucceeded, Data: broker.OperationData{BoshTaskID: 1}},
+			}))
+			Expect(firstCheckpoint.OperationType).To(Equal("upgrade"))
+			Expect(firstCheckpoint.Fingerprint).To(Equal("some-fingerprint"))
+			lastCheckpoint := fakeCheckpointer.SaveArgsForCall(2)
+			Expect(lastCheckpoint.Instances).To(HaveLen(3))
+			Expect(fakeCheckpointer.ClearCallCount()).To(Equal(1))
+		})
+
+		It("resumes from a saved checkpoint", func() {
+			fakeCheckpointer.LoadReturns(&instanceiterator.Checkpoint{
+				OperationType:     "upgrade",
+				Fingerprint:       "some-fingerprint",
+				CanariesCompleted: true,
+				Instances: map[string]services.BOSHOperation{
+					"1": {Type: services.OperationSucceeded, Data: broker.OperationData{BoshTaskID: 1}},
+					"2": {Type: services.OperationAccepted, Data: broker.OperationData{BoshTaskID: 22}},
+				},
+			}, nil)
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeListener.CheckpointRestoredCallCount()).To(Equal(1))
+			processedCount, inFlightCount := fakeListener.CheckpointRestoredArgsForCall(0)
+			Expect(processedCount).To(Equal(1))
+			Expect(inFlightCount).To(Equal(1))
+
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(1))
+			Expect(fakeTriggerer.TriggerOperationArgsForCall(0).GUID).To(Equal("3"))
+
+			guid, operationData := brokerServicesClient.LastOperationArgsForCall(0)
+			Expect(guid).To(Equal("2"))
+			Expect(operationData.BoshTaskID).To(Equal(22))
+
+			hasReportedFinished(fakeListener, 0, 3, 0, emptyBusyList, emptyFailedList)
+		})
+
+		DescribeTable("discards a checkpoint saved by another run",
+			func(operationType, fingerprint string) {
+				fakeCheckpointer.LoadReturns(&instanceiterator.Checkpoint{
+					OperationType:     operationType,
+					Fingerprint:       fingerprint,
+					CanariesCompleted: true,
+					Instances: map[string]services.BOSHOperation{
+						"1": {Type: services.OperationSucceeded, Data: broker.OperationData{BoshTaskID: 1}},
+					},
+				}, nil)
+
+				err := instanceiterator.New(&builder).Iterate()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeListener.CheckpointRestoredCallCount()).To(Equal(0))
+				Expect(fakeListener.CheckpointDiscardedCallCount()).To(Equal(1))
+				discardedOperationType, discardedFingerprint := fakeListener.CheckpointDiscardedArgsForCall(0)
+				Expect(discardedOperationType).To(Equal(operationType))
+				Expect(discardedFingerprint).To(Equal(fingerprint))
+				Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(3))
+			},
+			Entry("with another operation type", "recreate", "some-fingerprint"),
+			Entry("with another configuration", "upgrade", "another-fingerprint"),
+			Entry("without an identity", "", ""),
+		)
+
+		It("fails when the checkpoint cannot be loaded", func() {
+			fakeCheckpointer.LoadReturns(nil, errors.New("error parsing checkpoint"))
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("error parsing checkpoint"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(0))
+		})
+
+		It("keeps the checkpoint when processing fails", func() {
+			brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed}, nil)
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(HaveOccurred())
+			Expect(fakeCheckpointer.SaveCallCount()).To(BeNumerically(">", 0))
+			Expect(fakeCheckpointer.ClearCallCount()).To(Equal(0))
+		})
+
+		It("reports when the checkpoint cannot be saved and carries on", func() {
+			fakeCheckpointer.SaveReturns(errors.New("disk full"))
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeListener.FailedToSaveCheckpointCallCount()).To(Equal(3))
+			Expect(fakeListener.FailedToSaveCheckpointArgsForCall(0)).To(MatchError("disk full"))
+		})
+
+		It("does not start new operations while paused", func() {
+			fakeController.CommandReturnsOnCall(0, instanceiterator.CommandPause)
+			fakeController.CommandReturnsOnCall(1, instanceiterator.CommandPause)
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeListener.PausedCallCount()).To(Equal(1))
+			Expect(fakeListener.ResumedCallCount()).To(Equal(1))
+			Expect(fakeSleeper.SleepCallCount()).To(Equal(2))
+			hasSlept(fakeSleeper, 0, builder.PollingInterval)
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(3))
+		})
+
+		It("waits for operations in progress and stops when aborted", func() {
+			brokerServicesClient.LastOperationReturnsOnCall(0, brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+			fakeController.CommandReturnsOnCall(1, instanceiterator.CommandAbort)
+			fakeController.CommandReturnsOnCall(2, instanceiterator.CommandAbort)
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("operation aborted: progress has been saved and will be resumed on the next run"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(1))
+			Expect(brokerServicesClient.LastOperationCallCount()).To(Equal(2))
+			Expect(fakeListener.AbortingCallCount()).To(Equal(1))
+			Expect(fakeListener.AbortingArgsForCall(0)).To(Equal(1))
+			Expect(fakeCheckpointer.ClearCallCount()).To(Equal(0))
+			hasReportedFinished(fakeListener, 0, 1, 0, emptyBusyList, emptyFailedList)
+		})
+
+		It("stops when aborted without a checkpoint", func() {
+			builder.Checkpointer = nil
+			fakeController.CommandReturns(instanceiterator.CommandAbort)
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("operation aborted"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("selection filters", func() {
+		var triggeredGUIDs func() []string
+
+		BeforeEach(func() {
+			instanceLister.InstancesReturns([]service.Instance{
+				{GUID: "1", PlanUniqueID: "plan-a"},
+				{GUID: "2", PlanUniqueID: "plan-a"},
+				{GUID: "3", PlanUniqueID: "plan-b"},
+				{GUID: "4", PlanUniqueID: "plan-c"},
+			}, nil)
+			instanceLister.LatestInstanceInfoStub = func(inst service.Instance) (service.Instance, error) {
+				return inst, nil
+			}
+			brokerServicesClient.ProcessInstanceReturns(services.BOSHOperation{Type: services.OperationAccepted}, nil)
+			brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+			triggeredGUIDs = func() []string {
+				guids := []string{}
+				for i := 0; i < fakeTriggerer.TriggerOperationCallCount(); i++ {
+					guids = append(guids, fakeTriggerer.TriggerOperationArgsForCall(i).GUID)
+				}
+				return guids
+			}
+		})
+
+		It("processes all instances when no filters are set", func() {
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(ConsistOf("1", "2", "3", "4"))
+			Expect(fakeListener.InstancesSelectedCallCount()).To(Equal(0))
+		})
+
+		It("only processes instances of the selected plans", func() {
+			builder.SelectionFilters = config.InstanceSelectionFilters{PlanIDs: []string{"plan-a", "plan-c"}}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(ConsistOf("1", "2", "4"))
+			selected, total, filters := fakeListener.InstancesSelectedArgsForCall(0)
+			Expect(selected).To(Equal(3))
+			Expect(total).To(Equal(4))
+			Expect(filters).To(Equal(builder.SelectionFilters))
+		})
+
+		It("only processes the listed instances", func() {
+			builder.SelectionFilters = config.InstanceSelectionFilters{InstanceGUIDs: []string{"2", "3"}}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(ConsistOf("2", "3"))
+		})
+
+		It("skips excluded plans and instances", func() {
+			builder.SelectionFilters = config.InstanceSelectionFilters{
+				ExcludePlanIDs:       []string{"plan-b"},
+				ExcludeInstanceGUIDs: []string{"1"},
+			}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(ConsistOf("2", "4"))
+		})
+
+		It("lists the instances of the selected org and space", func() {
+			instanceLister.FilteredInstancesReturns([]service.Instance{{GUID: "3", PlanUniqueID: "plan-b"}}, nil)
+			builder.SelectionFilters = config.InstanceSelectionFilters{CFOrg: "the-org", CFSpace: "the-space"}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceLister.InstancesCallCount()).To(Equal(0))
+			Expect(instanceLister.FilteredInstancesArgsForCall(0)).To(Equal(map[string]string{
+				"cf_org":   "the-org",
+				"cf_space": "the-space",
+			}))
+			Expect(triggeredGUIDs()).To(ConsistOf("3"))
+		})
+
+		It("lists the instances of the selected org when no space is given", func() {
+			instanceLister.FilteredInstancesReturns([]service.Instance{{GUID: "3", PlanUniqueID: "plan-b"}}, nil)
+			builder.SelectionFilters = config.InstanceSelectionFilters{CFOrg: "the-org"}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceLister.InstancesCallCount()).To(Equal(0))
+			Expect(instanceLister.FilteredInstancesArgsForCall(0)).To(Equal(map[string]string{
+				"cf_org":     "the-org",
+				"all_spaces": "true",
+			}))
+			Expect(triggeredGUIDs()).To(ConsistOf("3"))
+		})
+
+		It("only processes instances with the selected releases deployed", func() {
+			brokerServicesClient.DeployedReleasesReturns(map[string][]mgmtapi.Release{
+				"1": {{Name: "redis", Version: "2.0.0"}, {Name: "syslog", Version: "11"}},
+				"2": {{Name: "redis", Version: "1.0.0"}, {Name: "syslog", Version: "11"}},
+				"3": {{Name: "redis", Version: "1.0.0"}},
+				"4": {{Name: "redis", Version: "1.0.0"}, {Name: "syslog", Version: "11"}},
+			}, nil)
+			builder.SelectionFilters = config.InstanceSelectionFilters{
+				Releases: []config.ReleaseFilter{{Name: "redis", Version: "1.0.0"}, {Name: "syslog", Version: "11"}},
+			}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(ConsistOf("2", "4"))
+		})
+
+		It("fetches the deployed releases once per run", func() {
+			brokerServicesClient.DeployedReleasesReturns(map[string][]mgmtapi.Release{
+				"3": {{Name: "redis", Version: "1.0.0"}},
+			}, nil)
+			builder.SelectionFilters = config.InstanceSelectionFilters{
+				Releases: []config.ReleaseFilter{{Name: "redis", Version: "1.0.0"}},
+			}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(brokerServicesClient.DeployedReleasesCallCount()).To(Equal(1))
+			Expect(triggeredGUIDs()).To(ConsistOf("3"))
+		})
+
+		It("does not fetch the deployed releases when no release is selected", func() {
+			builder.SelectionFilters = config.InstanceSelectionFilters{PlanIDs: []string{"plan-b"}}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(brokerServicesClient.DeployedReleasesCallCount()).To(Equal(0))
+		})
+
+		It("fails when the deployed releases cannot be retrieved", func() {
+			brokerServicesClient.DeployedReleasesReturns(nil, errors.New("broker is down"))
+			builder.SelectionFilters = config.InstanceSelectionFilters{
+				Releases: []config.ReleaseFilter{{Name: "redis", Version: "1.0.0"}},
+			}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("error getting deployed releases of service instances: broker is down"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(0))
+		})
+
+		It("only picks canaries from the selected instances", func() {
+			instanceLister.FilteredInstancesReturns([]service.Instance{
+				{GUID: "1", PlanUniqueID: "plan-a"},
+				{GUID: "3", PlanUniqueID: "plan-b"},
+			}, nil)
+			builder.Canaries = 1
+			builder.CanarySelectionParams = config.CanarySelectionParams{"cf_org": "the-org", "cf_space": "the-space"}
+			builder.SelectionFilters = config.InstanceSelectionFilters{PlanIDs: []string{"plan-b", "plan-c"}}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(triggeredGUIDs()).To(Equal([]string{"3", "4"}))
+			hasReportedCanariesStarting(fakeListener, 1, builder.CanarySelectionParams)
+		})
+	})
+
+	Context("maintenance windows", func() {
+		var (
+			fakeSchedule *fakes.FakeMaintenanceSchedule
+			closedFor    map[string]int
+		)
+
+		BeforeEach(func() {
+			closedFor = map[string]int{}
+			fakeSchedule = new(fakes.FakeMaintenanceSchedule)
+			fakeSchedule.IsOpenStub = func(guid string, _ time.Time) bool {
+				if closedFor[guid] > 0 {
+					closedFor[guid]--
+					return false
+				}
+				return closedFor[guid] == 0
+			}
+			builder.MaintenanceSchedule = fakeSchedule
+			builder.AttemptLimit = 2
+
+			instanceLister.InstancesReturns([]service.Instance{{GUID: "1"}, {GUID: "2"}, {GUID: "3"}}, nil)
+			instanceLister.LatestInstanceInfoStub = func(inst service.Instance) (service.Instance, error) {
+				return inst, nil
+			}
+			brokerServicesClient.ProcessInstanceReturns(services.BOSHOperation{Type: services.OperationAccepted}, nil)
+			brokerServicesClient.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+		})
+
+		It("loads the schedule before processing", func() {
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeSchedule.LoadCallCount()).To(Equal(1))
+		})
+
+		It("fails when the schedule cannot be loaded", func() {
+			fakeSchedule.LoadReturns(errors.New("no CF"))
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("error loading maintenance windows: no CF"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(0))
+		})
+
+		It("revisits instances outside their window on the next attempt", func() {
+			closedFor["2"] = 1
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(3))
+			Expect(fakeTriggerer.TriggerOperationArgsForCall(2).GUID).To(Equal("2"))
+			Expect(fakeListener.OutsideMaintenanceWindowCallCount()).To(Equal(1))
+			Expect(fakeListener.OutsideMaintenanceWindowArgsForCall(0)).To(Equal("2"))
+			hasSlept(fakeSleeper, 0, builder.AttemptInterval)
+
+			_, _, _, _, _, skipped := fakeListener.FinishedArgsForCall(0)
+			Expect(skipped).To(BeEmpty())
+		})
+
+		It("reports the instances still outside their window once attempts are exhausted", func() {
+			closedFor["2"] = -1
+			fakeCheckpointer := new(fakes.FakeCheckpointer)
+			builder.Checkpointer = fakeCheckpointer
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(Equal(instanceiterator.SkippedInstancesError{Instances: []string{"2"}, Checkpointed: true}))
+			Expect(err).To(MatchError("the following instances were outside their maintenance window and have not been processed: 2; the next run will only process these instances"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(2))
+			Expect(fakeListener.OutsideMaintenanceWindowCallCount()).To(Equal(2))
+			hasReportedFinished(fakeListener, 0, 2, 0, emptyBusyList, emptyFailedList)
+			_, _, _, _, _, skipped := fakeListener.FinishedArgsForCall(0)
+			Expect(skipped).To(ConsistOf("2"))
+
+			By("keeping the checkpoint so that the next run only revisits them")
+			Expect(fakeCheckpointer.ClearCallCount()).To(Equal(0))
+		})
+
+		It("reports the skipped instances when no checkpoint is configured", func() {
+			closedFor["2"] = -1
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("the following instances were outside their maintenance window and have not been processed: 2; no checkpoint is configured, so the next run will process every instance again"))
+		})
+
+		It("picks another canary when a canary is outside its window", func() {
+			closedFor["1"] = -1
+			builder.Canaries = 1
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(BeAssignableToTypeOf(instanceiterator.SkippedInstancesError{}))
+			Expect(fakeTriggerer.TriggerOperationArgsForCall(0).GUID).To(Equal("2"))
+			hasReportedCanariesFinished(fakeListener, 1)
+		})
+
+		It("fails the canaries when every canary is outside its window", func() {
+			closedFor["1"] = -1
+			instanceLister.FilteredInstancesReturns([]service.Instance{{GUID: "1"}}, nil)
+			builder.Canaries = 1
+			builder.CanarySelectionParams = config.CanarySelectionParams{"cf_org": "the-org", "cf_space": "the-space"}
+
+			err := instanceiterator.New(&builder).Iterate()
+
+			Expect(err).To(MatchError("canaries didn't process successfully: the following canary instances are outside their maintenance window: 1"))
+			Expect(fakeTriggerer.TriggerOperationCallCount()).To(Equal(0))
+		})
+	})
 })
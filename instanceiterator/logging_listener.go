@@ -108,19 +108,25 @@ func (ll LoggingListener) Progress(pollingInterval time.Duration, orphanCount, p
 	)
 }
 
-func (ll LoggingListener) Finished(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances []string) {
+func (ll LoggingListener) Finished(orphanCount, finishedCount, deletedCount int, busyInstances, failedInstances, skippedInstances []string) {
 	var failedList string
 	var busyList string
+	var skippedList string
 	if len(failedInstances) > 0 {
 		failedList = fmt.Sprintf(" [%s]", strings.Join(failedInstances, ", "))
 	}
 	if len(busyInstances) > 0 {
 		busyList = fmt.Sprintf(" [%s]", strings.Join(busyInstances, ", "))
 	}
+	if len(skippedInstances) > 0 {
+		skippedList = fmt.Sprintf(" [%s]", strings.Join(skippedInstances, ", "))
+	}
 
 	status := "SUCCESS"
 	if len(failedInstances) > 0 || len(busyInstances) > 0 {
 		status = "FAILED"
+	} else if len(skippedInstances) > 0 {
+		status = "INCOMPLETE"
 	}
 
 	ll.printf("FINISHED PROCESSING Status: %s; Summary: "+
@@ -128,7 +134,8 @@ func (ll LoggingListener) Finished(orphanCount, finishedCount, deletedCount int,
 		"Number of service instance orphans detected: %d; "+
 		"Number of deleted instances before operation could happen: %d; "+
 		"Number of busy instances which could not be processed: %d%s; "+
-		"Number of service instances that failed to process: %d%s",
+		"Number of service instances that failed to process: %d%s; "+
+		"Number of service instances skipped outside their maintenance window: %d%s",
 		status,
 		finishedCount,
 		orphanCount,
@@ -137,9 +144,23 @@ func (ll LoggingListener) Finished(orphanCount, finishedCount, deletedCount int,
 		busyList,
 		len(failedInstances),
 		failedList,
+		len(skippedInstances),
+		skippedList,
 	)
 }
 
+func (ll LoggingListener) InstancesSelected(selectedCount, totalCount int, filters config.InstanceSelectionFilters) {
+	ll.printf("Selected %d of %d service instances with selection filters: %s", selectedCount, totalCount, filters)
+}
+
+func (ll LoggingListener) OutsideMaintenanceWindow(instance string) {
+	ll.printf("[%s] Outside its maintenance window, will retry later", instance)
+}
+
+func (ll LoggingListener) InstanceRolledBack(instance string) {
+	ll.printf("[%s] Upgrade failed, rolled back to the previous deployment", instance)
+}
+
 func (ll LoggingListener) CanariesStarting(canaries int, filter config.CanarySelectionParams) {
 	msg := fmt.Sprintf("STARTING CANARIES: %d canaries", canaries)
 	if len(filter) > 0 {
@@ -152,6 +173,30 @@ func (ll LoggingListener) CanariesFinished() {
 	ll.printf("FINISHED CANARIES")
 }
 
+func (ll LoggingListener) CheckpointRestored(processedCount, inFlightCount int) {
+	ll.printf("Resuming from checkpoint: %d instances already processed, %d operations still in progress\n", processedCount, inFlightCount)
+}
+
+func (ll LoggingListener) CheckpointDiscarded(operationType, fingerprint string) {
+	ll.printf("Discarding checkpoint saved by another run (operation %q, fingerprint %q): processing all instances\n", operationType, fingerprint)
+}
+
+func (ll LoggingListener) FailedToSaveCheckpoint(err error) {
+	ll.printf("Failed to save checkpoint: %s\n", err)
+}
+
+func (ll LoggingListener) Paused(inFlightCount int) {
+	ll.printf("PAUSED: no new operations will be started; waiting for %d operations in progress\n", inFlightCount)
+}
+
+func (ll LoggingListener) Resumed() {
+	ll.printf("RESUMED\n")
+}
+
+func (ll LoggingListener) Aborting(inFlightCount int) {
+	ll.printf("ABORTING: no new operations will be started; waiting for %d operations in progress\n", inFlightCount)
+}
+
 func (ll LoggingListener) FailedToRefreshInstanceInfo(instance string) {
 	ll.logger.Printf("[%s] Failed to get refreshed list of instances. Continuing with previously fetched info.\n", instance)
 }
@@ -7,6 +7,7 @@
 package instanceiterator_test
 
 import (
+	"errors"
 	"io"
 	"log"
 	"time"
@@ -54,11 +55,41 @@ var _ = Describe("Logging Listener", func() {
 			To(Say("space: my-space"))
 	})
 
+	It("Shows instances selected message", func() {
+		filters := config.InstanceSelectionFilters{PlanIDs: []string{"plan-a", "plan-b"}, ExcludeInstanceGUIDs: []string{"guid-1"}}
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.InstancesSelected(3, 10, filters) })).
+			To(ContainSubstring("[%s] Selected 3 of 10 service instances with selection filters: plan_ids: [plan-a, plan-b], exclude_instance_guids: [guid-1]", logPrefix))
+	})
+
 	It("Shows canaries finished message", func() {
 		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.CanariesFinished() })).
 			To(ContainSubstring("[%s] FINISHED CANARIES", logPrefix))
 	})
 
+	It("Shows resuming from checkpoint message", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.CheckpointRestored(5, 2) })).
+			To(ContainSubstring("[%s] Resuming from checkpoint: 5 instances already processed, 2 operations still in progress", logPrefix))
+	})
+
+	It("Shows discarded checkpoint message", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.CheckpointDiscarded("recreate", "abc123") })).
+			To(ContainSubstring(`[%s] Discarding checkpoint saved by another run (operation "recreate", fingerprint "abc123"): processing all instances`, logPrefix))
+	})
+
+	It("Shows failed to save checkpoint message", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.FailedToSaveCheckpoint(errors.New("disk full")) })).
+			To(ContainSubstring("[%s] Failed to save checkpoint: disk full", logPrefix))
+	})
+
+	It("Shows paused, resumed and aborting messages", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.Paused(3) })).
+			To(ContainSubstring("[%s] PAUSED: no new operations will be started; waiting for 3 operations in progress", logPrefix))
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.Resumed() })).
+			To(ContainSubstring("[%s] RESUMED", logPrefix))
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.Aborting(1) })).
+			To(ContainSubstring("[%s] ABORTING: no new operations will be started; waiting for 1 operations in progress", logPrefix))
+	})
+
 	It("Shows attempt x of y", func() {
 		Expect(logResultsFrom(processType, retryAttempt(2, 5))).
 			To(Say("Attempt 2/5"))
@@ -200,7 +231,7 @@ var _ = Describe("Logging Listener", func() {
 
 	It("Shows a final summary where we completed successfully", func() {
 		result := logResultsFromAsString(processType, func(listener instanceiterator.Listener) {
-			listener.Finished(23, 34, 45, nil, nil)
+			listener.Finished(23, 34, 45, nil, nil, nil)
 		})
 
 		Expect(result).To(SatisfyAll(
@@ -210,14 +241,36 @@ var _ = Describe("Logging Listener", func() {
 			ContainSubstring("Number of deleted instances before operation could happen: 45"),
 			ContainSubstring("Number of busy instances which could not be processed: 0"),
 			ContainSubstring("Number of service instances that failed to process: 0"),
+			ContainSubstring("Number of service instances skipped outside their maintenance window: 0"),
 			Not(ContainSubstring("[]")),
 		))
 	})
 
+	It("Shows a final summary where instances were outside their maintenance window", func() {
+		result := logResultsFromAsString(processType, func(listener instanceiterator.Listener) {
+			listener.Finished(23, 34, 45, nil, nil, []string{"instance-1", "instance-2"})
+		})
+
+		Expect(result).To(SatisfyAll(
+			ContainSubstring("[%s] FINISHED PROCESSING Status: INCOMPLETE; Summary", logPrefix),
+			ContainSubstring("Number of service instances skipped outside their maintenance window: 2 [instance-1, instance-2]"),
+		))
+	})
+
+	It("Shows an instance is outside its maintenance window", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.OutsideMaintenanceWindow("instance-1") })).
+			To(ContainSubstring("[%s] [instance-1] Outside its maintenance window, will retry later", logPrefix))
+	})
+
+	It("Shows an instance was rolled back after a failed upgrade", func() {
+		Expect(logResultsFromAsString(processType, func(listener instanceiterator.Listener) { listener.InstanceRolledBack("instance-1") })).
+			To(ContainSubstring("[%s] [instance-1] Upgrade failed, rolled back to the previous deployment", logPrefix))
+	})
+
 	It("Shows a final summary where instances could not start", func() {
 		result := logResultsFromAsString(processType, func(listener instanceiterator.Listener) {
 			busyList := make([]string, 56)
-			listener.Finished(23, 34, 45, busyList, nil)
+			listener.Finished(23, 34, 45, busyList, nil, nil)
 		})
 
 		Expect(result).To(SatisfyAll(
@@ -233,7 +286,7 @@ var _ = Describe("Logging Listener", func() {
 
 	It("Shows a final summary where a single service instance failed to process", func() {
 		result := logResultsFromAsString(processType, func(listener instanceiterator.Listener) {
-			listener.Finished(23, 34, 45, []string{"foo"}, []string{"2f9752c3-887b-4ccb-8693-7c15811ffbdd"})
+			listener.Finished(23, 34, 45, []string{"foo"}, []string{"2f9752c3-887b-4ccb-8693-7c15811ffbdd"}, nil)
 		})
 
 		Expect(result).To(SatisfyAll(
@@ -248,7 +301,7 @@ var _ = Describe("Logging Listener", func() {
 
 	It("Shows a final summary where multiple services instances failed the operation", func() {
 		result := logResultsFromAsString(processType, func(listener instanceiterator.Listener) {
-			listener.Finished(23, 34, 45, make([]string, 56), []string{"2f9752c3-887b-4ccb-8693-7c15811ffbdd", "7a2c7adb-1d47-4355-af39-41c5a2892b92"})
+			listener.Finished(23, 34, 45, make([]string, 56), []string{"2f9752c3-887b-4ccb-8693-7c15811ffbdd", "7a2c7adb-1d47-4355-af39-41c5a2892b92"}, nil)
 		})
 
 		Expect(result).To(SatisfyAll(
@@ -0,0 +1,194 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package instanceiterator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+)
+
+//go:generate counterfeiter -o fakes/fake_maintenance_schedule.go . MaintenanceSchedule
+type MaintenanceSchedule interface {
+	Load() error
+	IsOpen(instanceGUID string, at time.Time) bool
+}
+
+// ConfiguredMaintenanceSchedule resolves the maintenance windows from the
+// iterator configuration to the instances they apply to.
+type ConfiguredMaintenanceSchedule struct {
+	instanceLister  InstanceLister
+	defaultWindow   *maintenanceWindow
+	orgWindows      []orgMaintenanceWindow
+	instanceWindows map[string]maintenanceWindow
+	resolvedWindows map[string]maintenanceWindow
+}
+
+type orgMaintenanceWindow struct {
+	org    string
+	space  string
+	window maintenanceWindow
+}
+
+type maintenanceWindow struct {
+	days     map[time.Weekday]bool
+	hour     int
+	minute   int
+	duration time.Duration
+	location *time.Location
+}
+
+func NewMaintenanceSchedule(conf config.MaintenanceWindows, instanceLister InstanceLister) (*ConfiguredMaintenanceSchedule, error) {
+	schedule := &ConfiguredMaintenanceSchedule{
+		instanceLister:  instanceLister,
+		instanceWindows: map[string]maintenanceWindow{},
+	}
+
+	if conf.Default != nil {
+		window, err := parseMaintenanceWindow(*conf.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default maintenance window: %s", err)
+		}
+		schedule.defaultWindow = &window
+	}
+
+	for _, orgConf := range conf.Orgs {
+		if orgConf.CFOrg == "" {
+			return nil, errors.New("invalid maintenance window: cf_org must be set")
+		}
+		window, err := parseMaintenanceWindow(orgConf.MaintenanceWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window for org %s: %s", orgConf.CFOrg, err)
+		}
+		schedule.orgWindows = append(schedule.orgWindows, orgMaintenanceWindow{
+			org:    orgConf.CFOrg,
+			space:  orgConf.CFSpace,
+			window: window,
+		})
+	}
+
+	for _, instanceConf := range conf.Instances {
+		if instanceConf.InstanceGUID == "" {
+			return nil, errors.New("invalid maintenance window: instance_guid must be set")
+		}
+		window, err := parseMaintenanceWindow(instanceConf.MaintenanceWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window for instance %s: %s", instanceConf.InstanceGUID, err)
+		}
+		schedule.instanceWindows[instanceConf.InstanceGUID] = window
+	}
+
+	return schedule, nil
+}
+
+// Load looks up which instances belong to the orgs and spaces that have a
+// maintenance window.
+func (s *ConfiguredMaintenanceSchedule) Load() error {
+	s.resolvedWindows = map[string]maintenanceWindow{}
+
+	// Org windows are applied before org and space windows so that the more
+	// specific window wins.
+	for _, withSpace := range []bool{false, true} {
+		for _, orgWindow := range s.orgWindows {
+			if (orgWindow.space != "") != withSpace {
+				continue
+			}
+
+			filter := map[string]string{"cf_org": orgWindow.org}
+			if withSpace {
+				filter["cf_space"] = orgWindow.space
+			} else {
+				filter["all_spaces"] = "true"
+			}
+
+			instances, err := s.instanceLister.FilteredInstances(filter)
+			if err != nil {
+				return fmt.Errorf("error listing service instances of org %s: %s", orgWindow.org, err)
+			}
+			for _, instance := range instances {
+				s.resolvedWindows[instance.GUID] = orgWindow.window
+			}
+		}
+	}
+
+	for guid, window := range s.instanceWindows {
+		s.resolvedWindows[guid] = window
+	}
+	return nil
+}
+
+// IsOpen reports whether the instance may be operated on at the given time.
+func (s *ConfiguredMaintenanceSchedule) IsOpen(instanceGUID string, at time.Time) bool {
+	if window, found := s.resolvedWindows[instanceGUID]; found {
+		return window.isOpen(at)
+	}
+	if s.defaultWindow != nil {
+		return s.defaultWindow.isOpen(at)
+	}
+	return true
+}
+
+func parseMaintenanceWindow(conf config.MaintenanceWindow) (maintenanceWindow, error) {
+	window := maintenanceWindow{days: map[time.Weekday]bool{}}
+
+	start, err := time.Parse("15:04", conf.Start)
+	if err != nil {
+		return maintenanceWindow{}, fmt.Errorf("start %q must be formatted as HH:MM", conf.Start)
+	}
+	window.hour, window.minute = start.Hour(), start.Minute()
+
+	window.duration, err = time.ParseDuration(conf.Duration)
+	if err != nil || window.duration <= 0 || window.duration > 7*24*time.Hour {
+		return maintenanceWindow{}, fmt.Errorf("duration %q must be a positive duration of at most 168h", conf.Duration)
+	}
+
+	for _, day := range conf.Days {
+		weekday, found := weekdays[strings.ToLower(day)]
+		if !found {
+			return maintenanceWindow{}, fmt.Errorf("unknown day %q", day)
+		}
+		window.days[weekday] = true
+	}
+
+	window.location, err = time.LoadLocation(conf.Timezone)
+	if err != nil {
+		return maintenanceWindow{}, fmt.Errorf("unknown timezone %q", conf.Timezone)
+	}
+
+	return window, nil
+}
+
+func (w maintenanceWindow) isOpen(at time.Time) bool {
+	local := at.In(w.location)
+
+	// A window which opened on an earlier day may still be open.
+	for daysAgo := 0; daysAgo <= 7; daysAgo++ {
+		day := local.AddDate(0, 0, -daysAgo)
+		if len(w.days) > 0 && !w.days[day.Weekday()] {
+			continue
+		}
+
+		opens := time.Date(day.Year(), day.Month(), day.Day(), w.hour, w.minute, 0, 0, w.location)
+		if !local.Before(opens) && local.Before(opens.Add(w.duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
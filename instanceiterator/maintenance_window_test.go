@@ -0,0 +1,158 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceiterator_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+var _ = Describe("ConfiguredMaintenanceSchedule", func() {
+	var (
+		instanceLister *fakes.FakeInstanceLister
+		conf           config.MaintenanceWindows
+	)
+
+	// 2018-03-10 is a Saturday.
+	at := func(value string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", value)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	loadSchedule := func() *instanceiterator.ConfiguredMaintenanceSchedule {
+		schedule, err := instanceiterator.NewMaintenanceSchedule(conf, instanceLister)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Load()).To(Succeed())
+		return schedule
+	}
+
+	BeforeEach(func() {
+		instanceLister = new(fakes.FakeInstanceLister)
+		conf = config.MaintenanceWindows{}
+	})
+
+	It("is always open when no window applies to an instance", func() {
+		schedule := loadSchedule()
+
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 12:00"))).To(BeTrue())
+	})
+
+	It("applies the default window to every instance", func() {
+		conf.Default = &config.MaintenanceWindow{Days: []string{"saturday", "Sun"}, Start: "22:00", Duration: "4h"}
+		schedule := loadSchedule()
+
+		Expect(schedule.IsOpen("instance-1", at("2018-03-10 21:59"))).To(BeFalse())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-10 22:00"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-11 01:59"))).To(BeTrue(), "window spanning midnight")
+		Expect(schedule.IsOpen("instance-1", at("2018-03-11 02:00"))).To(BeFalse())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-11 23:00"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-12 23:00"))).To(BeFalse(), "Monday")
+	})
+
+	It("opens every day when no days are given", func() {
+		conf.Default = &config.MaintenanceWindow{Start: "02:00", Duration: "1h"}
+		schedule := loadSchedule()
+
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 02:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-08 02:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-08 03:30"))).To(BeFalse())
+	})
+
+	It("evaluates the window in its timezone", func() {
+		conf.Default = &config.MaintenanceWindow{Start: "02:00", Duration: "1h", Timezone: "America/New_York"}
+		schedule := loadSchedule()
+
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 02:30"))).To(BeFalse())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 07:30"))).To(BeTrue())
+	})
+
+	It("prefers instance windows over org and space windows over org windows", func() {
+		conf.Default = &config.MaintenanceWindow{Start: "01:00", Duration: "1h"}
+		conf.Orgs = []config.OrgMaintenanceWindow{
+			{CFOrg: "the-org", CFSpace: "the-space", MaintenanceWindow: config.MaintenanceWindow{Start: "03:00", Duration: "1h"}},
+			{CFOrg: "the-org", MaintenanceWindow: config.MaintenanceWindow{Start: "02:00", Duration: "1h"}},
+		}
+		conf.Instances = []config.InstanceMaintenanceWindow{
+			{InstanceGUID: "instance-1", MaintenanceWindow: config.MaintenanceWindow{Start: "04:00", Duration: "1h"}},
+		}
+		instanceLister.FilteredInstancesStub = func(filter map[string]string) ([]service.Instance, error) {
+			if filter["cf_space"] == "the-space" {
+				return []service.Instance{{GUID: "instance-1"}, {GUID: "instance-2"}}, nil
+			}
+			return []service.Instance{{GUID: "instance-1"}, {GUID: "instance-2"}, {GUID: "instance-3"}}, nil
+		}
+		schedule := loadSchedule()
+
+		Expect(instanceLister.FilteredInstancesCallCount()).To(Equal(2))
+		Expect(instanceLister.FilteredInstancesArgsForCall(0)).To(Equal(map[string]string{"cf_org": "the-org", "all_spaces": "true"}))
+		Expect(instanceLister.FilteredInstancesArgsForCall(1)).To(Equal(map[string]string{"cf_org": "the-org", "cf_space": "the-space"}))
+
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 04:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-1", at("2018-03-07 03:30"))).To(BeFalse())
+		Expect(schedule.IsOpen("instance-2", at("2018-03-07 03:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-3", at("2018-03-07 02:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-4", at("2018-03-07 01:30"))).To(BeTrue())
+		Expect(schedule.IsOpen("instance-4", at("2018-03-07 02:30"))).To(BeFalse())
+	})
+
+	It("returns an error when the instances of an org cannot be listed", func() {
+		conf.Orgs = []config.OrgMaintenanceWindow{
+			{CFOrg: "the-org", MaintenanceWindow: config.MaintenanceWindow{Start: "02:00", Duration: "1h"}},
+		}
+		instanceLister.FilteredInstancesReturns(nil, errors.New("network error"))
+		schedule, err := instanceiterator.NewMaintenanceSchedule(conf, instanceLister)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(schedule.Load()).To(MatchError("error listing service instances of org the-org: network error"))
+	})
+
+	DescribeTable("invalid windows",
+		func(windows config.MaintenanceWindows, expectedErr string) {
+			_, err := instanceiterator.NewMaintenanceSchedule(windows, instanceLister)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("bad start",
+			config.MaintenanceWindows{Default: &config.MaintenanceWindow{Start: "10pm", Duration: "1h"}},
+			`invalid default maintenance window: start "10pm" must be formatted as HH:MM`),
+		Entry("bad duration",
+			config.MaintenanceWindows{Default: &config.MaintenanceWindow{Start: "22:00", Duration: "forever"}},
+			`invalid default maintenance window: duration "forever" must be a positive duration of at most 168h`),
+		Entry("too long duration",
+			config.MaintenanceWindows{Default: &config.MaintenanceWindow{Start: "22:00", Duration: "169h"}},
+			`invalid default maintenance window: duration "169h" must be a positive duration of at most 168h`),
+		Entry("bad day",
+			config.MaintenanceWindows{Orgs: []config.OrgMaintenanceWindow{{CFOrg: "the-org", MaintenanceWindow: config.MaintenanceWindow{Days: []string{"caturday"}, Start: "22:00", Duration: "1h"}}}},
+			`invalid maintenance window for org the-org: unknown day "caturday"`),
+		Entry("bad timezone",
+			config.MaintenanceWindows{Instances: []config.InstanceMaintenanceWindow{{InstanceGUID: "instance-1", MaintenanceWindow: config.MaintenanceWindow{Start: "22:00", Duration: "1h", Timezone: "Mars/Olympus_Mons"}}}},
+			`invalid maintenance window for instance instance-1: unknown timezone "Mars/Olympus_Mons"`),
+		Entry("missing org",
+			config.MaintenanceWindows{Orgs: []config.OrgMaintenanceWindow{{MaintenanceWindow: config.MaintenanceWindow{Start: "22:00", Duration: "1h"}}}},
+			"invalid maintenance window: cf_org must be set"),
+		Entry("missing instance",
+			config.MaintenanceWindows{Instances: []config.InstanceMaintenanceWindow{{MaintenanceWindow: config.MaintenanceWindow{Start: "22:00", Duration: "1h"}}}},
+			"invalid maintenance window: instance_guid must be set"),
+	)
+})
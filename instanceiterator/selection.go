@@ -0,0 +1,114 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package instanceiterator
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+func (it *Iterator) selectInstances() ([]service.Instance, error) {
+	var (
+		instances []service.Instance
+		err       error
+	)
+
+	filters := it.selectionFilters
+	if filters.CFOrg != "" {
+		filter := map[string]string{"cf_org": filters.CFOrg}
+		if filters.CFSpace != "" {
+			filter["cf_space"] = filters.CFSpace
+		} else {
+			filter["all_spaces"] = "true"
+		}
+		instances, err = it.instanceLister.FilteredInstances(filter)
+	} else {
+		instances, err = it.instanceLister.Instances()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing service instances: %s", err)
+	}
+
+	if filters.IsEmpty() {
+		return instances, nil
+	}
+
+	var deployedReleases map[string][]mgmtapi.Release
+	if len(filters.Releases) > 0 {
+		deployedReleases, err = it.brokerServices.DeployedReleases()
+		if err != nil {
+			return nil, fmt.Errorf("error getting deployed releases of service instances: %s", err)
+		}
+	}
+
+	planIDs := toSet(filters.PlanIDs)
+	instanceGUIDs := toSet(filters.InstanceGUIDs)
+	excludedPlanIDs := toSet(filters.ExcludePlanIDs)
+	excludedInstanceGUIDs := toSet(filters.ExcludeInstanceGUIDs)
+
+	selected := []service.Instance{}
+	for _, instance := range instances {
+		if len(planIDs) > 0 && !planIDs[instance.PlanUniqueID] {
+			continue
+		}
+		if len(instanceGUIDs) > 0 && !instanceGUIDs[instance.GUID] {
+			continue
+		}
+		if excludedPlanIDs[instance.PlanUniqueID] || excludedInstanceGUIDs[instance.GUID] {
+			continue
+		}
+
+		if len(filters.Releases) > 0 && !it.hasDeployedReleases(deployedReleases[instance.GUID]) {
+			continue
+		}
+
+		selected = append(selected, instance)
+	}
+
+	it.listener.InstancesSelected(len(selected), len(instances), filters)
+	return selected, nil
+}
+
+func (it *Iterator) hasDeployedReleases(releases []mgmtapi.Release) bool {
+	deployed := map[string]string{}
+	for _, release := range releases {
+		deployed[release.Name] = release.Version
+	}
+
+	for _, wanted := range it.selectionFilters.Releases {
+		version, found := deployed[wanted.Name]
+		if !found || version != wanted.Version {
+			return false
+		}
+	}
+	return true
+}
+
+func intersectInstances(instances, within []service.Instance) []service.Instance {
+	guids := map[string]bool{}
+	for _, instance := range within {
+		guids[instance.GUID] = true
+	}
+
+	result := []service.Instance{}
+	for _, instance := range instances {
+		if guids[instance.GUID] {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
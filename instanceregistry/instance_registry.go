@@ -0,0 +1,230 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package instanceregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+const planIDFilter = "plan_id"
+
+// Registry is the broker's own record of the service instances it manages,
+// stored as a JSON document in a file on the broker VM. It lists instances
+// without Cloud Foundry or a service instances API, so it implements both
+// service.InstanceLister and instanceiterator.InstanceLister.
+type Registry struct {
+	path string
+	lock sync.Mutex
+}
+
+func New(path string) (*Registry, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := writeInstances(path, []service.Instance{}); err != nil {
+			return nil, fmt.Errorf("error creating instance registry: %s", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening instance registry: %s", err)
+	}
+
+	return &Registry{path: path}, nil
+}
+
+// Register records an instance, replacing the plan of an instance that is
+// already registered.
+func (r *Registry) Register(instance service.Instance) error {
+	if instance.GUID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	instances, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	for i := range instances {
+		if instances[i].GUID == instance.GUID {
+			instances[i] = instance
+			return r.write(instances)
+		}
+	}
+	return r.write(append(instances, instance))
+}
+
+// Deregister removes an instance. Removing an instance that is not registered
+// is not an error.
+func (r *Registry) Deregister(instanceID string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	instances, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	remaining := []service.Instance{}
+	for _, instance := range instances {
+		if instance.GUID != instanceID {
+			remaining = append(remaining, instance)
+		}
+	}
+	if len(remaining) == len(instances) {
+		return nil
+	}
+	return r.write(remaining)
+}
+
+// Reconcile brings the registry in line with the instances that are deployed,
+// registering those it is missing and deregistering those that are no longer
+// deployed. Instances that are already registered keep their plan, as the
+// registry is updated whenever an instance changes plan.
+func (r *Registry) Reconcile(deployed []service.Instance) (added, removed []string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	instances, err := r.read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deployedIDs := map[string]bool{}
+	for _, instance := range deployed {
+		deployedIDs[instance.GUID] = true
+	}
+
+	registeredIDs := map[string]bool{}
+	reconciled := []service.Instance{}
+	for _, instance := range instances {
+		registeredIDs[instance.GUID] = true
+		if deployedIDs[instance.GUID] {
+			reconciled = append(reconciled, instance)
+		} else {
+			removed = append(removed, instance.GUID)
+		}
+	}
+
+	for _, instance := range deployed {
+		if !registeredIDs[instance.GUID] {
+			reconciled = append(reconciled, instance)
+			added = append(added, instance.GUID)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil, nil, nil
+	}
+	return added, removed, r.write(reconciled)
+}
+
+// Instances returns the registered instances, ordered by instance ID.
+func (r *Registry) Instances() ([]service.Instance, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.read()
+}
+
+// FilteredInstances returns the registered instances matching the filter. The
+// registry only knows the plan of each instance, so plan_id is the only filter
+// it supports.
+func (r *Registry) FilteredInstances(filter map[string]string) ([]service.Instance, error) {
+	for key := range filter {
+		if key != planIDFilter {
+			return nil, fmt.Errorf("the instance registry cannot filter instances by %s", key)
+		}
+	}
+
+	instances, err := r.Instances()
+	if err != nil {
+		return nil, err
+	}
+
+	planID, filtered := filter[planIDFilter]
+	if !filtered {
+		return instances, nil
+	}
+
+	matching := []service.Instance{}
+	for _, instance := range instances {
+		if instance.PlanUniqueID == planID {
+			matching = append(matching, instance)
+		}
+	}
+	return matching, nil
+}
+
+func (r *Registry) LatestInstanceInfo(instance service.Instance) (service.Instance, error) {
+	instances, err := r.Instances()
+	if err != nil {
+		return service.Instance{}, err
+	}
+
+	for _, registered := range instances {
+		if registered.GUID == instance.GUID {
+			return registered, nil
+		}
+	}
+	return service.Instance{}, service.InstanceNotFound
+}
+
+func (r *Registry) read() ([]service.Instance, error) {
+	contents, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading instance registry: %s", err)
+	}
+
+	instances := []service.Instance{}
+	if err := json.Unmarshal(contents, &instances); err != nil {
+		return nil, fmt.Errorf("error parsing instance registry: %s", err)
+	}
+	return instances, nil
+}
+
+func (r *Registry) write(instances []service.Instance) error {
+	if err := writeInstances(r.path, instances); err != nil {
+		return fmt.Errorf("error writing instance registry: %s", err)
+	}
+	return nil
+}
+
+// writeInstances replaces the registry file through a rename, so that a crash
+// part way through leaves either the old or the new registry in place.
+func writeInstances(path string, instances []service.Instance) error {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].GUID < instances[j].GUID })
+
+	contents, err := json.Marshal(instances)
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(contents); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package instanceregistry_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceiterator"
+	"github.com/pivotal-cf/on-demand-service-broker/instanceregistry"
+	"github.com/pivotal-cf/on-demand-service-broker/service"
+)
+
+var (
+	_ service.InstanceLister          = &instanceregistry.Registry{}
+	_ instanceiterator.InstanceLister = &instanceregistry.Registry{}
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		dir          string
+		registryPath string
+		registry     *instanceregistry.Registry
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "instanceregistry")
+		Expect(err).NotTo(HaveOccurred())
+		registryPath = filepath.Join(dir, "instances.json")
+
+		registry, err = instanceregistry.New(registryPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("starts with no instances", func() {
+		instances, err := registry.Instances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(BeEmpty())
+	})
+
+	It("lists registered instances ordered by instance ID", func() {
+		Expect(registry.Register(service.Instance{GUID: "instance-b", PlanUniqueID: "plan-1"})).To(Succeed())
+		Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-2"})).To(Succeed())
+
+		instances, err := registry.Instances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(Equal([]service.Instance{
+			{GUID: "instance-a", PlanUniqueID: "plan-2"},
+			{GUID: "instance-b", PlanUniqueID: "plan-1"},
+		}))
+	})
+
+	It("replaces the plan of an instance that is registered again", func() {
+		Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})).To(Succeed())
+		Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-2"})).To(Succeed())
+
+		instances, err := registry.Instances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(Equal([]service.Instance{{GUID: "instance-a", PlanUniqueID: "plan-2"}}))
+	})
+
+	It("requires an instance ID", func() {
+		Expect(registry.Register(service.Instance{PlanUniqueID: "plan-1"})).To(MatchError("instance ID is required"))
+	})
+
+	It("deregisters instances", func() {
+		Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})).To(Succeed())
+		Expect(registry.Register(service.Instance{GUID: "instance-b", PlanUniqueID: "plan-1"})).To(Succeed())
+
+		Expect(registry.Deregister("instance-a")).To(Succeed())
+		Expect(registry.Deregister("not-registered")).To(Succeed())
+
+		instances, err := registry.Instances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(Equal([]service.Instance{{GUID: "instance-b", PlanUniqueID: "plan-1"}}))
+	})
+
+	It("keeps the instances registered when it is reopened", func() {
+		Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})).To(Succeed())
+
+		reopened, err := instanceregistry.New(registryPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		instances, err := reopened.Instances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(Equal([]service.Instance{{GUID: "instance-a", PlanUniqueID: "plan-1"}}))
+	})
+
+	It("fails to list instances when the registry cannot be parsed", func() {
+		Expect(ioutil.WriteFile(registryPath, []byte("not json"), 0600)).To(Succeed())
+
+		_, err := registry.Instances()
+		Expect(err).To(MatchError(ContainSubstring("error parsing instance registry")))
+	})
+
+	Describe("Reconcile", func() {
+		BeforeEach(func() {
+			Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})).To(Succeed())
+			Expect(registry.Register(service.Instance{GUID: "instance-b", PlanUniqueID: "plan-1"})).To(Succeed())
+		})
+
+		It("registers deployed instances and deregisters those no longer deployed", func() {
+			added, removed, err := registry.Reconcile([]service.Instance{
+				{GUID: "instance-b", PlanUniqueID: "plan-2"},
+				{GUID: "instance-c", PlanUniqueID: "plan-2"},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added).To(Equal([]string{"instance-c"}))
+			Expect(removed).To(Equal([]string{"instance-a"}))
+
+			instances, err := registry.Instances()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(Equal([]service.Instance{
+				{GUID: "instance-b", PlanUniqueID: "plan-1"},
+				{GUID: "instance-c", PlanUniqueID: "plan-2"},
+			}))
+		})
+
+		It("changes nothing when the registry is up to date", func() {
+			added, removed, err := registry.Reconcile([]service.Instance{{GUID: "instance-a"}, {GUID: "instance-b"}})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added).To(BeEmpty())
+			Expect(removed).To(BeEmpty())
+		})
+	})
+
+	Describe("FilteredInstances", func() {
+		BeforeEach(func() {
+			Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})).To(Succeed())
+			Expect(registry.Register(service.Instance{GUID: "instance-b", PlanUniqueID: "plan-2"})).To(Succeed())
+		})
+
+		It("filters instances by plan", func() {
+			instances, err := registry.FilteredInstances(map[string]string{"plan_id": "plan-2"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(Equal([]service.Instance{{GUID: "instance-b", PlanUniqueID: "plan-2"}}))
+		})
+
+		It("returns every instance for an empty filter", func() {
+			instances, err := registry.FilteredInstances(map[string]string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(HaveLen(2))
+		})
+
+		It("fails for filters other than the plan", func() {
+			_, err := registry.FilteredInstances(map[string]string{"cf_org": "some-org"})
+			Expect(err).To(MatchError("the instance registry cannot filter instances by cf_org"))
+		})
+	})
+
+	Describe("LatestInstanceInfo", func() {
+		It("returns the registered instance", func() {
+			Expect(registry.Register(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-2"})).To(Succeed())
+
+			instance, err := registry.LatestInstanceInfo(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instance).To(Equal(service.Instance{GUID: "instance-a", PlanUniqueID: "plan-2"}))
+		})
+
+		It("returns InstanceNotFound for an unregistered instance", func() {
+			_, err := registry.LatestInstanceInfo(service.Instance{GUID: "instance-a"})
+			Expect(err).To(Equal(service.InstanceNotFound))
+		})
+	})
+})
@@ -0,0 +1,85 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package loggerfactory
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-service-broker/brokercontext"
+)
+
+const (
+	infoLevel  = "info"
+	errorLevel = "error"
+)
+
+type contextFields struct {
+	RequestID   string `json:"request_id,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	InstanceID  string `json:"instance_id,omitempty"`
+	Operation   string `json:"operation,omitempty"`
+	BoshTaskID  int    `json:"bosh_task_id,omitempty"`
+	PlanID      string `json:"plan_id,omitempty"`
+}
+
+type jsonLogLine struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+	contextFields
+}
+
+// jsonWriter relies on log.Logger calling Write exactly once per message.
+type jsonWriter struct {
+	out    io.Writer
+	source string
+	fields contextFields
+}
+
+func fieldsFromContext(ctx context.Context) contextFields {
+	return contextFields{
+		RequestID:   brokercontext.GetReqID(ctx),
+		ServiceName: brokercontext.GetServiceName(ctx),
+		InstanceID:  brokercontext.GetInstanceID(ctx),
+		Operation:   brokercontext.GetOperation(ctx),
+		BoshTaskID:  brokercontext.GetBoshTaskID(ctx),
+		PlanID:      brokercontext.GetPlanID(ctx),
+	}
+}
+
+func (l *LoggerFactory) newJSONLogger(fields contextFields) *log.Logger {
+	return log.New(&jsonWriter{out: l.out, source: l.name, fields: fields}, "", 0)
+}
+
+func (w *jsonWriter) Write(p []byte) (int, error) {
+	if err := w.writeLine(infoLevel, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *jsonWriter) writeLine(level, message string) error {
+	line, err := json.Marshal(jsonLogLine{
+		Timestamp:     time.Now().UTC().Format(time.RFC3339Nano),
+		Level:         level,
+		Source:        w.source,
+		Message:       strings.TrimSuffix(message, "\n"),
+		contextFields: w.fields,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.out.Write(append(line, '\n'))
+	return err
+}
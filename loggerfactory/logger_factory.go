@@ -18,17 +18,33 @@ import (
 
 const Flags = log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
 
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
 type LoggerFactory struct {
-	out  io.Writer
-	name string
-	flag int
+	out        io.Writer
+	name       string
+	flag       int
+	jsonFormat bool
 }
 
 func New(out io.Writer, name string, flag int) *LoggerFactory {
 	return &LoggerFactory{out: out, name: name, flag: flag}
 }
 
+// NewJSON returns a factory for loggers which write each line as a JSON
+// object, including the operation fields found in the context.
+func NewJSON(out io.Writer, name string) *LoggerFactory {
+	return &LoggerFactory{out: out, name: name, jsonFormat: true}
+}
+
 func (l *LoggerFactory) NewWithContext(ctx context.Context) *log.Logger {
+	if l.jsonFormat {
+		return l.newJSONLogger(fieldsFromContext(ctx))
+	}
+
 	if brokercontext.GetReqID(ctx) == "" {
 		return l.New()
 	}
@@ -38,11 +54,30 @@ func (l *LoggerFactory) NewWithContext(ctx context.Context) *log.Logger {
 }
 
 func (l *LoggerFactory) NewWithRequestID() *log.Logger {
+	if l.jsonFormat {
+		return l.newJSONLogger(contextFields{RequestID: uuid.New()})
+	}
+
 	prefix := fmt.Sprintf("[%s] [%s] ", l.name, uuid.New())
 	return log.New(l.out, prefix, l.flag)
 }
 
 func (l *LoggerFactory) New() *log.Logger {
+	if l.jsonFormat {
+		return l.newJSONLogger(contextFields{})
+	}
+
 	prefix := fmt.Sprintf("[%s] ", l.name)
 	return log.New(l.out, prefix, l.flag)
 }
+
+// Errorf logs a message at the error level. Text loggers print it as Printf
+// would; JSON loggers report it with the error level instead of info.
+func Errorf(logger *log.Logger, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	if writer, ok := logger.Writer().(*jsonWriter); ok {
+		writer.writeLine(errorLevel, message)
+		return
+	}
+	logger.Output(2, message)
+}
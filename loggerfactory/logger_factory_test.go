@@ -9,7 +9,10 @@ package loggerfactory_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"log"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -43,6 +46,15 @@ var _ = Describe("Logger Factory", func() {
 		Expect(logs.String()).To(MatchRegexp(`\[some-name\] \[([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\] some log message`))
 	})
 
+	It("logs errors from a text logger as Printf would", func() {
+		logs := &bytes.Buffer{}
+		factory := loggerfactory.New(logs, "some-name", 0)
+
+		loggerfactory.Errorf(factory.New(), "error deploying instance: %s", "some bosh error")
+
+		Expect(logs.String()).To(Equal("[some-name] error deploying instance: some bosh error\n"))
+	})
+
 	Context("can create a logger that uses a context", func() {
 		var ctx context.Context
 
@@ -87,4 +99,86 @@ var _ = Describe("Logger Factory", func() {
 			})
 		})
 	})
+
+	Context("when the JSON format is used", func() {
+		var (
+			logs    *bytes.Buffer
+			factory *loggerfactory.LoggerFactory
+		)
+
+		BeforeEach(func() {
+			logs = &bytes.Buffer{}
+			factory = loggerfactory.NewJSON(logs, "some-name")
+		})
+
+		parseLine := func() map[string]interface{} {
+			var line map[string]interface{}
+			Expect(json.Unmarshal(logs.Bytes(), &line)).To(Succeed())
+			return line
+		}
+
+		It("logs one JSON object per line with a timestamp and level", func() {
+			logger := factory.New()
+			logger.Println("some log message")
+
+			Expect(logs.String()).To(HaveSuffix("}\n"))
+			line := parseLine()
+			Expect(line).To(HaveKeyWithValue("source", "some-name"))
+			Expect(line).To(HaveKeyWithValue("message", "some log message"))
+			Expect(line).To(HaveKeyWithValue("level", "info"))
+			Expect(line).NotTo(HaveKey("request_id"))
+
+			timestamp, err := time.Parse(time.RFC3339Nano, line["timestamp"].(string))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+		})
+
+		It("logs messages with the error level when they are logged as errors", func() {
+			logger := factory.New()
+			loggerfactory.Errorf(logger, "deploying instance: %s", "some bosh failure")
+
+			line := parseLine()
+			Expect(line).To(HaveKeyWithValue("level", "error"))
+			Expect(line).To(HaveKeyWithValue("message", "deploying instance: some bosh failure"))
+		})
+
+		It("logs messages which mention errors with the info level", func() {
+			logger := factory.New()
+			logger.Println("retrying after error from BOSH")
+
+			Expect(parseLine()).To(HaveKeyWithValue("level", "info"))
+		})
+
+		It("includes a request ID", func() {
+			logger := factory.NewWithRequestID()
+			logger.Println("some log message")
+
+			Expect(parseLine()["request_id"]).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`))
+		})
+
+		It("includes the operation fields of the context", func() {
+			ctx := brokercontext.New(context.Background(), "update", "some-request-id", "some-service", "some-instance-id")
+			ctx = brokercontext.WithBoshTaskID(ctx, 42)
+			ctx = brokercontext.WithPlanID(ctx, "some-plan-id")
+
+			logger := factory.NewWithContext(ctx)
+			logger.Println("some log message")
+
+			line := parseLine()
+			Expect(line).To(HaveKeyWithValue("request_id", "some-request-id"))
+			Expect(line).To(HaveKeyWithValue("service_name", "some-service"))
+			Expect(line).To(HaveKeyWithValue("instance_id", "some-instance-id"))
+			Expect(line).To(HaveKeyWithValue("operation", "update"))
+			Expect(line).To(HaveKeyWithValue("bosh_task_id", BeNumerically("==", 42)))
+			Expect(line).To(HaveKeyWithValue("plan_id", "some-plan-id"))
+		})
+
+		It("escapes multi-line messages", func() {
+			logger := factory.New()
+			logger.Println("first line\nsecond line")
+
+			Expect(strings.Count(logs.String(), "\n")).To(Equal(1))
+			Expect(parseLine()).To(HaveKeyWithValue("message", "first line\nsecond line"))
+		})
+	})
 })
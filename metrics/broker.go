@@ -0,0 +1,80 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+)
+
+type combinedBroker interface {
+	mgmtapi.ManageableBroker
+	brokerapi.ServiceBroker
+}
+
+// Broker wraps a broker to count the outcomes of the BOSH operations it
+// reports through LastOperation. The platform may poll an operation again
+// after it has finished, so each operation is counted once.
+type Broker struct {
+	combinedBroker
+	metrics *Metrics
+
+	countedLock    sync.Mutex
+	counted        map[string]bool
+	countedInOrder []string
+}
+
+// maxCountedOperations bounds how many counted operations are remembered;
+// the oldest are forgotten first.
+const maxCountedOperations = 10000
+
+func (m *Metrics) WrapBroker(b combinedBroker) *Broker {
+	return &Broker{combinedBroker: b, metrics: m, counted: map[string]bool{}}
+}
+
+func (b *Broker) LastOperation(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	lastOperation, err := b.combinedBroker.LastOperation(ctx, instanceID, details)
+	if err != nil || lastOperation.State == brokerapi.InProgress {
+		return lastOperation, err
+	}
+
+	operationType := "unknown"
+	var operationData broker.OperationData
+	if json.Unmarshal([]byte(details.OperationData), &operationData) == nil && operationData.OperationType != "" {
+		operationType = string(operationData.OperationType)
+		if !b.firstFinalPoll(instanceID + "/" + broker.JournalOperationID(operationData)) {
+			return lastOperation, nil
+		}
+	}
+
+	b.metrics.boshTaskOutcomes.WithLabelValues(b.metrics.serviceOffering.Name, operationType, string(lastOperation.State)).Inc()
+	return lastOperation, nil
+}
+
+// firstFinalPoll reports whether the operation has not been counted yet and
+// remembers it as counted.
+func (b *Broker) firstFinalPoll(operationKey string) bool {
+	b.countedLock.Lock()
+	defer b.countedLock.Unlock()
+
+	if b.counted[operationKey] {
+		return false
+	}
+
+	b.counted[operationKey] = true
+	b.countedInOrder = append(b.countedInOrder, operationKey)
+	if len(b.countedInOrder) > maxCountedOperations {
+		delete(b.counted, b.countedInOrder[0])
+		b.countedInOrder = b.countedInOrder[1:]
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+)
+
+var _ = Describe("Broker", func() {
+	var (
+		brokerMetrics *metrics.Metrics
+		fakeBroker    *fakes.FakeCombinedBroker
+		wrappedBroker *metrics.Broker
+		pollDetails   brokerapi.PollDetails
+	)
+
+	BeforeEach(func() {
+		brokerMetrics = metrics.New(config.ServiceOffering{Name: "a-service"})
+		fakeBroker = new(fakes.FakeCombinedBroker)
+		wrappedBroker = brokerMetrics.WrapBroker(fakeBroker)
+
+		operationData, err := json.Marshal(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeUpgrade})
+		Expect(err).NotTo(HaveOccurred())
+		pollDetails = brokerapi.PollDetails{OperationData: string(operationData)}
+	})
+
+	It("counts final states of operations by operation type", func() {
+		fakeBroker.LastOperationReturnsOnCall(0, brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+		fakeBroker.LastOperationReturnsOnCall(1, brokerapi.LastOperation{State: brokerapi.Failed}, nil)
+
+		_, err := wrappedBroker.LastOperation(context.Background(), "an-instance", pollDetails)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = wrappedBroker.LastOperation(context.Background(), "other-instance", pollDetails)
+		Expect(err).NotTo(HaveOccurred())
+
+		body := scrape(brokerMetrics)
+		Expect(body).To(ContainSubstring(`on_demand_broker_bosh_task_outcomes_total{operation_type="upgrade",service="a-service",state="succeeded"} 1`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_bosh_task_outcomes_total{operation_type="upgrade",service="a-service",state="failed"} 1`))
+	})
+
+	It("counts each operation once however often its final state is polled", func() {
+		fakeBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+		for i := 0; i < 3; i++ {
+			_, err := wrappedBroker.LastOperation(context.Background(), "an-instance", pollDetails)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(scrape(brokerMetrics)).To(ContainSubstring(`on_demand_broker_bosh_task_outcomes_total{operation_type="upgrade",service="a-service",state="succeeded"} 1`))
+	})
+
+	It("does not count operations which are in progress", func() {
+		fakeBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress, Description: "working"}, nil)
+
+		lastOperation, err := wrappedBroker.LastOperation(context.Background(), "an-instance", pollDetails)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastOperation.Description).To(Equal("working"))
+		Expect(scrape(brokerMetrics)).NotTo(ContainSubstring("on_demand_broker_bosh_task_outcomes_total"))
+	})
+
+	It("does not count errors from the wrapped broker", func() {
+		fakeBroker.LastOperationReturns(brokerapi.LastOperation{}, errors.New("bosh unavailable"))
+
+		_, err := wrappedBroker.LastOperation(context.Background(), "an-instance", pollDetails)
+
+		Expect(err).To(MatchError("bosh unavailable"))
+		Expect(scrape(brokerMetrics)).NotTo(ContainSubstring("on_demand_broker_bosh_task_outcomes_total"))
+	})
+
+	It("labels operations without operation data as unknown", func() {
+		fakeBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+		_, err := wrappedBroker.LastOperation(context.Background(), "an-instance", brokerapi.PollDetails{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scrape(brokerMetrics)).To(ContainSubstring(`on_demand_broker_bosh_task_outcomes_total{operation_type="unknown",service="a-service",state="succeeded"} 1`))
+	})
+})
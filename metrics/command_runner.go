@@ -0,0 +1,57 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+)
+
+type instrumentedCommandRunner struct {
+	serviceadapter.CommandRunner
+	metrics *Metrics
+}
+
+// InstrumentCommandRunner records the duration and exit code of every
+// service adapter invocation made through the runner.
+func (m *Metrics) InstrumentCommandRunner(runner serviceadapter.CommandRunner) serviceadapter.CommandRunner {
+	return instrumentedCommandRunner{CommandRunner: runner, metrics: m}
+}
+
+func (r instrumentedCommandRunner) Run(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
+	start := time.Now()
+	stdout, stderr, exitCode, err := r.CommandRunner.Run(ctx, arg...)
+	r.metrics.observeAdapterInvocation(arg, exitCode, err, time.Since(start))
+	return stdout, stderr, exitCode, err
+}
+
+func (r instrumentedCommandRunner) RunWithInputParams(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
+	start := time.Now()
+	stdout, stderr, exitCode, err := r.CommandRunner.RunWithInputParams(ctx, inputParams, arg...)
+	r.metrics.observeAdapterInvocation(arg, exitCode, err, time.Since(start))
+	return stdout, stderr, exitCode, err
+}
+
+func (m *Metrics) observeAdapterInvocation(arg []string, exitCode *int, err error, duration time.Duration) {
+	subcommand := "unknown"
+	if len(arg) > 1 {
+		subcommand = arg[1]
+	}
+
+	exitCodeLabel := "error"
+	if exitCode != nil {
+		exitCodeLabel = strconv.Itoa(*exitCode)
+	} else if err == nil {
+		exitCodeLabel = "0"
+	}
+
+	m.adapterInvocations.WithLabelValues(m.serviceOffering.Name, subcommand, exitCodeLabel).Inc()
+	m.adapterDuration.WithLabelValues(m.serviceOffering.Name, subcommand).Observe(duration.Seconds())
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter/fakes"
+)
+
+var _ = Describe("Instrumented command runner", func() {
+	var (
+		brokerMetrics     *metrics.Metrics
+		fakeCommandRunner *fakes.FakeCommandRunner
+	)
+
+	BeforeEach(func() {
+		brokerMetrics = metrics.New(config.ServiceOffering{Name: "a-service"})
+		fakeCommandRunner = new(fakes.FakeCommandRunner)
+	})
+
+	It("delegates to the wrapped runner", func() {
+		exitCode := 0
+		fakeCommandRunner.RunReturns([]byte("stdout"), []byte("stderr"), &exitCode, nil)
+
+		stdout, stderr, actualExitCode, err := brokerMetrics.InstrumentCommandRunner(fakeCommandRunner).Run(context.Background(), "/adapter", "generate-manifest", "arg")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout).To(Equal([]byte("stdout")))
+		Expect(stderr).To(Equal([]byte("stderr")))
+		Expect(actualExitCode).To(Equal(&exitCode))
+		_, args := fakeCommandRunner.RunArgsForCall(0)
+		Expect(args).To(Equal([]string{"/adapter", "generate-manifest", "arg"}))
+	})
+
+	It("records invocations by subcommand and exit code", func() {
+		exitCode := 10
+		fakeCommandRunner.RunWithInputParamsReturns(nil, nil, &exitCode, nil)
+		runner := brokerMetrics.InstrumentCommandRunner(fakeCommandRunner)
+
+		runner.RunWithInputParams(context.Background(), "params", "/adapter", "create-binding")
+		runner.RunWithInputParams(context.Background(), "params", "/adapter", "create-binding")
+
+		body := scrape(brokerMetrics)
+		Expect(fakeCommandRunner.RunWithInputParamsCallCount()).To(Equal(2))
+		Expect(body).To(ContainSubstring(`on_demand_broker_service_adapter_invocations_total{exit_code="10",service="a-service",subcommand="create-binding"} 2`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_service_adapter_duration_seconds_count{service="a-service",subcommand="create-binding"} 2`))
+	})
+
+	It("labels invocations with the service offering of the metrics that instrumented the runner", func() {
+		exitCode := 0
+		fakeCommandRunner.RunReturns(nil, nil, &exitCode, nil)
+		otherMetrics := brokerMetrics.ForServiceOffering(config.ServiceOffering{Name: "another-service"})
+
+		brokerMetrics.InstrumentCommandRunner(fakeCommandRunner).Run(context.Background(), "/adapter", "generate-manifest")
+		otherMetrics.InstrumentCommandRunner(fakeCommandRunner).Run(context.Background(), "/other-adapter", "generate-manifest")
+
+		body := scrape(brokerMetrics)
+		Expect(body).To(ContainSubstring(`on_demand_broker_service_adapter_invocations_total{exit_code="0",service="a-service",subcommand="generate-manifest"} 1`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_service_adapter_invocations_total{exit_code="0",service="another-service",subcommand="generate-manifest"} 1`))
+	})
+
+	It("records invocations which could not be run", func() {
+		fakeCommandRunner.RunReturns(nil, nil, nil, errors.New("not found"))
+
+		_, _, _, err := brokerMetrics.InstrumentCommandRunner(fakeCommandRunner).Run(context.Background(), "/adapter", "dashboard-url")
+
+		Expect(err).To(MatchError("not found"))
+		Expect(scrape(brokerMetrics)).To(ContainSubstring(`on_demand_broker_service_adapter_invocations_total{exit_code="error",service="a-service",subcommand="dashboard-url"} 1`))
+	})
+})
@@ -0,0 +1,215 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type InstanceCounter interface {
+	CountInstancesOfPlans(logger *log.Logger) (map[cf.ServicePlan]int, error)
+}
+
+// fleetCollector reports instance counts and quota usage for every service
+// offering that called CollectFleet.
+type fleetCollector struct {
+	lock    sync.Mutex
+	sources []*fleetSource
+
+	instances                    *prometheus.Desc
+	instancesQuotaRemaining      *prometheus.Desc
+	totalInstances               *prometheus.Desc
+	totalInstancesQuotaRemaining *prometheus.Desc
+	resourceUsage                *prometheus.Desc
+	resourceLimit                *prometheus.Desc
+	resourceUtilisation          *prometheus.Desc
+}
+
+// fleetSource caches the instance counts of one service offering, so that
+// scrapes do not each query the platform.
+type fleetSource struct {
+	serviceOffering config.ServiceOffering
+	counter         InstanceCounter
+	cacheTTL        time.Duration
+	cloudFoundry    bool
+	loggerFactory   *loggerfactory.LoggerFactory
+
+	lock      sync.Mutex
+	countedAt time.Time
+	counts    map[cf.ServicePlan]int
+	countErr  error
+}
+
+func newFleetCollector() *fleetCollector {
+	newDesc := func(name, help string, labelNames ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labelNames, nil)
+	}
+
+	return &fleetCollector{
+		instances: newDesc(
+			"instances",
+			"Number of service instances of a plan.",
+			"service", "plan",
+		),
+		instancesQuotaRemaining: newDesc(
+			"instances_quota_remaining",
+			"Number of service instances which can still be created for a plan.",
+			"service", "plan",
+		),
+		totalInstances: newDesc(
+			"total_instances",
+			"Number of service instances across all plans.",
+			"service",
+		),
+		totalInstancesQuotaRemaining: newDesc(
+			"total_instances_quota_remaining",
+			"Number of service instances which can still be created across all plans.",
+			"service",
+		),
+		resourceUsage: newDesc(
+			"resource_usage",
+			"Resource units used by service instances. An empty plan label is the global quota.",
+			"service", "plan", "resource",
+		),
+		resourceLimit: newDesc(
+			"resource_limit",
+			"Resource quota limit. An empty plan label is the global quota.",
+			"service", "plan", "resource",
+		),
+		resourceUtilisation: newDesc(
+			"resource_quota_utilisation_ratio",
+			"Ratio of resource usage to resource quota limit. An empty plan label is the global quota.",
+			"service", "plan", "resource",
+		),
+	}
+}
+
+// CollectFleet reports the instance count and quota gauges of this service
+// offering. Counts are fetched from the instance counter when a scrape finds
+// them older than cacheTTL. On Cloud Foundry, which reports no plans until the
+// broker is registered, an empty count is reported as an error.
+func (m *Metrics) CollectFleet(counter InstanceCounter, cacheTTL time.Duration, cloudFoundry bool, loggerFactory *loggerfactory.LoggerFactory) {
+	m.fleet.lock.Lock()
+	defer m.fleet.lock.Unlock()
+
+	m.fleet.sources = append(m.fleet.sources, &fleetSource{
+		serviceOffering: m.serviceOffering,
+		counter:         counter,
+		cacheTTL:        cacheTTL,
+		cloudFoundry:    cloudFoundry,
+		loggerFactory:   loggerFactory,
+	})
+}
+
+func (c *fleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.instances
+	ch <- c.instancesQuotaRemaining
+	ch <- c.totalInstances
+	ch <- c.totalInstancesQuotaRemaining
+	ch <- c.resourceUsage
+	ch <- c.resourceLimit
+	ch <- c.resourceUtilisation
+}
+
+func (c *fleetCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	sources := append([]*fleetSource(nil), c.sources...)
+	c.lock.Unlock()
+
+	for _, source := range sources {
+		instanceCountsByPlan, err := source.instanceCounts()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.totalInstances, err)
+			continue
+		}
+		c.collectOffering(ch, source.serviceOffering, instanceCountsByPlan)
+	}
+}
+
+// instanceCounts returns the cached counts, or counts the instances when the
+// cache has expired. The platform is queried without holding the cache lock,
+// so a slow platform does not block scrapes which can be served from the cache.
+func (s *fleetSource) instanceCounts() (map[cf.ServicePlan]int, error) {
+	s.lock.Lock()
+	counts, countErr := s.counts, s.countErr
+	expired := s.countedAt.IsZero() || time.Since(s.countedAt) >= s.cacheTTL
+	s.lock.Unlock()
+
+	if expired {
+		counts, countErr = s.counter.CountInstancesOfPlans(s.loggerFactory.NewWithRequestID())
+
+		s.lock.Lock()
+		s.counts, s.countErr, s.countedAt = counts, countErr, time.Now()
+		s.lock.Unlock()
+	}
+
+	serviceName := s.serviceOffering.Name
+	if countErr != nil {
+		return nil, fmt.Errorf("error getting instance count for service offering %s: %s", serviceName, countErr)
+	}
+	if len(counts) == 0 && s.cloudFoundry {
+		return nil, fmt.Errorf("the %s service broker must be registered with Cloud Foundry before metrics can be collected", serviceName)
+	}
+	return counts, nil
+}
+
+func (c *fleetCollector) collectOffering(ch chan<- prometheus.Metric, serviceOffering config.ServiceOffering, instanceCountsByPlan map[cf.ServicePlan]int) {
+	serviceName := serviceOffering.Name
+	gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+
+	planCounts := map[string]int{}
+	for plan, count := range instanceCountsByPlan {
+		planCounts[plan.ServicePlanEntity.UniqueID] = count
+	}
+
+	totalInstances := 0
+	globalResourceUsage := map[string]int{}
+	for _, plan := range serviceOffering.Plans {
+		count := planCounts[plan.ID]
+		totalInstances += count
+		gauge(c.instances, float64(count), serviceName, plan.Name)
+
+		if limit := plan.Quotas.ServiceInstanceLimit; limit != nil {
+			gauge(c.instancesQuotaRemaining, float64(*limit-count), serviceName, plan.Name)
+		}
+
+		for kind, cost := range plan.ResourceCosts {
+			globalResourceUsage[kind] += cost * count
+		}
+
+		for kind, limit := range plan.Quotas.ResourceLimits {
+			c.collectResource(ch, serviceName, plan.Name, kind, plan.ResourceCosts[kind]*count, limit)
+		}
+	}
+
+	gauge(c.totalInstances, float64(totalInstances), serviceName)
+	if limit := serviceOffering.GlobalQuotas.ServiceInstanceLimit; limit != nil {
+		gauge(c.totalInstancesQuotaRemaining, float64(*limit-totalInstances), serviceName)
+	}
+
+	for kind, limit := range serviceOffering.GlobalQuotas.ResourceLimits {
+		c.collectResource(ch, serviceName, "", kind, globalResourceUsage[kind], limit)
+	}
+}
+
+func (c *fleetCollector) collectResource(ch chan<- prometheus.Metric, serviceName, planName, kind string, usage, limit int) {
+	ch <- prometheus.MustNewConstMetric(c.resourceUsage, prometheus.GaugeValue, float64(usage), serviceName, planName, kind)
+	ch <- prometheus.MustNewConstMetric(c.resourceLimit, prometheus.GaugeValue, float64(limit), serviceName, planName, kind)
+	if limit > 0 {
+		ch <- prometheus.MustNewConstMetric(c.resourceUtilisation, prometheus.GaugeValue, float64(usage)/float64(limit), serviceName, planName, kind)
+	}
+}
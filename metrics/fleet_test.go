@@ -0,0 +1,186 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/cf"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi/fake_manageable_broker"
+)
+
+var _ = Describe("Fleet metrics", func() {
+	var (
+		brokerMetrics   *metrics.Metrics
+		fakeBroker      *fake_manageable_broker.FakeManageableBroker
+		serviceOffering config.ServiceOffering
+		cacheTTL        time.Duration
+		cloudFoundry    bool
+	)
+
+	BeforeEach(func() {
+		cacheTTL = 0
+		cloudFoundry = true
+		planLimit := 3
+		globalLimit := 10
+		serviceOffering = config.ServiceOffering{
+			Name: "a-service",
+			GlobalQuotas: config.Quotas{
+				ServiceInstanceLimit: &globalLimit,
+				ResourceLimits:       map[string]int{"ips": 20},
+			},
+			Plans: config.Plans{
+				{
+					ID:            "small-id",
+					Name:          "small",
+					ResourceCosts: map[string]int{"ips": 1, "memory": 2},
+					Quotas: config.Quotas{
+						ServiceInstanceLimit: &planLimit,
+						ResourceLimits:       map[string]int{"memory": 8},
+					},
+				},
+				{
+					ID:            "large-id",
+					Name:          "large",
+					ResourceCosts: map[string]int{"ips": 3},
+				},
+			},
+		}
+
+		fakeBroker = new(fake_manageable_broker.FakeManageableBroker)
+		fakeBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{
+			{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: "small-id"}}: 2,
+			{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: "large-id"}}: 4,
+		}, nil)
+	})
+
+	JustBeforeEach(func() {
+		brokerMetrics = metrics.New(serviceOffering)
+		brokerMetrics.CollectFleet(fakeBroker, cacheTTL, cloudFoundry, loggerfactory.New(GinkgoWriter, "metrics-test", loggerfactory.Flags))
+	})
+
+	It("reports instance counts and remaining quotas", func() {
+		body := scrape(brokerMetrics)
+
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="small",service="a-service"} 2`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="large",service="a-service"} 4`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances_quota_remaining{plan="small",service="a-service"} 1`))
+		Expect(body).NotTo(ContainSubstring(`on_demand_broker_instances_quota_remaining{plan="large",service="a-service"}`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_total_instances{service="a-service"} 6`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_total_instances_quota_remaining{service="a-service"} 4`))
+	})
+
+	It("reports resource usage and utilisation for plan and global quotas", func() {
+		body := scrape(brokerMetrics)
+
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_usage{plan="small",resource="memory",service="a-service"} 4`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_limit{plan="small",resource="memory",service="a-service"} 8`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_quota_utilisation_ratio{plan="small",resource="memory",service="a-service"} 0.5`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_usage{plan="",resource="ips",service="a-service"} 14`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_limit{plan="",resource="ips",service="a-service"} 20`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_resource_quota_utilisation_ratio{plan="",resource="ips",service="a-service"} 0.7`))
+	})
+
+	It("refreshes the counts once they are older than the cache TTL", func() {
+		scrape(brokerMetrics)
+		fakeBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{
+			{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: "small-id"}}: 3,
+		}, nil)
+
+		body := scrape(brokerMetrics)
+
+		Expect(fakeBroker.CountInstancesOfPlansCallCount()).To(Equal(2))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="small",service="a-service"} 3`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="large",service="a-service"} 0`))
+	})
+
+	Context("when the counts are within the cache TTL", func() {
+		BeforeEach(func() {
+			cacheTTL = time.Hour
+		})
+
+		It("reuses the counts of the previous scrape", func() {
+			scrape(brokerMetrics)
+			fakeBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{
+				{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: "small-id"}}: 3,
+			}, nil)
+
+			body := scrape(brokerMetrics)
+
+			Expect(fakeBroker.CountInstancesOfPlansCallCount()).To(Equal(1))
+			Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="small",service="a-service"} 2`))
+		})
+	})
+
+	It("drops the fleet metrics when counting instances fails", func() {
+		scrape(brokerMetrics)
+		fakeBroker.CountInstancesOfPlansReturns(nil, errors.New("cf is down"))
+
+		Expect(scrape(brokerMetrics)).NotTo(ContainSubstring("on_demand_broker_instances"))
+	})
+
+	It("drops the fleet metrics when the broker is not registered", func() {
+		fakeBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{}, nil)
+
+		Expect(scrape(brokerMetrics)).NotTo(ContainSubstring("on_demand_broker_total_instances"))
+	})
+
+	Context("when the broker does not serve Cloud Foundry", func() {
+		BeforeEach(func() {
+			cloudFoundry = false
+		})
+
+		It("reports no instances when there are none", func() {
+			fakeBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{}, nil)
+
+			body := scrape(brokerMetrics)
+
+			Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="small",service="a-service"} 0`))
+			Expect(body).To(ContainSubstring(`on_demand_broker_total_instances{service="a-service"} 0`))
+		})
+	})
+
+	Context("when the broker serves another service offering", func() {
+		var otherBroker *fake_manageable_broker.FakeManageableBroker
+
+		JustBeforeEach(func() {
+			otherBroker = new(fake_manageable_broker.FakeManageableBroker)
+			otherBroker.CountInstancesOfPlansReturns(map[cf.ServicePlan]int{
+				{ServicePlanEntity: cf.ServicePlanEntity{UniqueID: "other-plan-id"}}: 5,
+			}, nil)
+
+			otherMetrics := brokerMetrics.ForServiceOffering(config.ServiceOffering{
+				Name:  "another-service",
+				Plans: config.Plans{{ID: "other-plan-id", Name: "other-plan"}},
+			})
+			otherMetrics.CollectFleet(otherBroker, cacheTTL, true, loggerfactory.New(GinkgoWriter, "metrics-test", loggerfactory.Flags))
+		})
+
+		It("reports the instances of each offering in the same registry", func() {
+			body := scrape(brokerMetrics)
+
+			Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="small",service="a-service"} 2`))
+			Expect(body).To(ContainSubstring(`on_demand_broker_instances{plan="other-plan",service="another-service"} 5`))
+			Expect(body).To(ContainSubstring(`on_demand_broker_total_instances{service="another-service"} 5`))
+		})
+
+		It("keeps the metrics of one offering when counting the instances of another fails", func() {
+			otherBroker.CountInstancesOfPlansReturns(nil, errors.New("cf is down"))
+
+			body := scrape(brokerMetrics)
+
+			Expect(body).To(ContainSubstring(`on_demand_broker_total_instances{service="a-service"} 6`))
+			Expect(body).NotTo(ContainSubstring(`service="another-service"`))
+		})
+	})
+})
@@ -0,0 +1,152 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "on_demand_broker"
+
+// DefaultDurationBuckets are the histogram buckets, in seconds, used for
+// request and service adapter durations.
+var DefaultDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Metrics holds the instruments the broker exposes on /metrics. Instruments
+// which concern a service offering are labelled with the offering given to New
+// or ForServiceOffering.
+type Metrics struct {
+	registry        *prometheus.Registry
+	serviceOffering config.ServiceOffering
+	fleet           *fleetCollector
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	boshTaskOutcomes *prometheus.CounterVec
+
+	adapterInvocations *prometheus.CounterVec
+	adapterDuration    *prometheus.HistogramVec
+}
+
+func New(serviceOffering config.ServiceOffering) *Metrics {
+	m := &Metrics{
+		registry:        prometheus.NewRegistry(),
+		serviceOffering: serviceOffering,
+		fleet:           newFleetCollector(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled by the broker.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests.",
+			Buckets:   DefaultDurationBuckets,
+		}, []string{"method", "route"}),
+		boshTaskOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bosh_task_outcomes_total",
+			Help:      "Number of BOSH operations which reached a final state.",
+		}, []string{"service", "operation_type", "state"}),
+		adapterInvocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "service_adapter_invocations_total",
+			Help:      "Number of service adapter invocations.",
+		}, []string{"service", "subcommand", "exit_code"}),
+		adapterDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "service_adapter_duration_seconds",
+			Help:      "Time taken by service adapter invocations.",
+			Buckets:   DefaultDurationBuckets,
+		}, []string{"service", "subcommand"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.fleet,
+		m.requests,
+		m.requestDuration,
+		m.boshTaskOutcomes,
+		m.adapterInvocations,
+		m.adapterDuration,
+	)
+	return m
+}
+
+// ForServiceOffering returns metrics which share this registry and its
+// instruments but label BOSH task outcomes, service adapter invocations and
+// instance counts with another service offering.
+func (m *Metrics) ForServiceOffering(serviceOffering config.ServiceOffering) *Metrics {
+	offeringMetrics := *m
+	offeringMetrics.serviceOffering = serviceOffering
+	return &offeringMetrics
+}
+
+// Handler serves the metrics in the Prometheus exposition format. Metrics
+// which could be collected are still served when others fail; the failures
+// are logged.
+func (m *Metrics) Handler(logger *log.Logger) http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog:      logger,
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// unmatchedRoute labels requests which did not match a route, so that
+// arbitrary paths cannot grow the number of label values.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the count and duration of requests per route. It must
+// be attached with mux.Router.Use so that the matched route is known.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			if template, err := currentRoute.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(body []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(body)
+}
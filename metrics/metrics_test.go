@@ -0,0 +1,100 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package metrics_test
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/metrics"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi/fake_manageable_broker"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		brokerMetrics *metrics.Metrics
+		router        *mux.Router
+	)
+
+	BeforeEach(func() {
+		brokerMetrics = metrics.New(config.ServiceOffering{Name: "a-service"})
+		router = mux.NewRouter()
+		router.Use(brokerMetrics.Middleware)
+		router.HandleFunc("/v2/service_instances/{instance_id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}).Methods("PUT")
+		router.HandleFunc("/v2/catalog", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}).Methods("GET")
+	})
+
+	It("counts requests by method, route template and status code", func() {
+		serve(router, "PUT", "/v2/service_instances/instance-1")
+		serve(router, "PUT", "/v2/service_instances/instance-2")
+		serve(router, "GET", "/v2/catalog")
+
+		body := scrape(brokerMetrics)
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_requests_total{code="202",method="PUT",route="/v2/service_instances/{instance_id}"} 2`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_requests_total{code="200",method="GET",route="/v2/catalog"} 1`))
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_request_duration_seconds_count{method="PUT",route="/v2/service_instances/{instance_id}"} 2`))
+		Expect(body).NotTo(ContainSubstring("instance-1"))
+	})
+
+	It("labels requests without a route template as unmatched", func() {
+		unmatched := brokerMetrics.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		serve(unmatched, "GET", "/some/random/path")
+
+		body := scrape(brokerMetrics)
+		Expect(body).To(ContainSubstring(`on_demand_broker_http_requests_total{code="404",method="GET",route="unmatched"} 1`))
+		Expect(body).NotTo(ContainSubstring("/some/random/path"))
+	})
+
+	It("serves metrics in the Prometheus text format", func() {
+		recorder := httptest.NewRecorder()
+		brokerMetrics.Handler(log.New(GinkgoWriter, "", 0)).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+	})
+
+	It("logs collection errors and still serves the other metrics", func() {
+		fakeBroker := new(fake_manageable_broker.FakeManageableBroker)
+		fakeBroker.CountInstancesOfPlansReturns(nil, errors.New("cf is down"))
+		brokerMetrics.CollectFleet(fakeBroker, 0, true, loggerfactory.New(GinkgoWriter, "metrics-test", loggerfactory.Flags))
+		serve(router, "GET", "/v2/catalog")
+
+		logBuffer := gbytes.NewBuffer()
+		recorder := httptest.NewRecorder()
+		brokerMetrics.Handler(log.New(logBuffer, "", 0)).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring("on_demand_broker_http_requests_total"))
+		Expect(logBuffer).To(gbytes.Say("error gathering metrics"))
+		Expect(logBuffer).To(gbytes.Say("error getting instance count for service offering a-service: cf is down"))
+	})
+})
+
+func serve(handler http.Handler, method, path string) {
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+}
+
+func scrape(brokerMetrics *metrics.Metrics) string {
+	recorder := httptest.NewRecorder()
+	brokerMetrics.Handler(log.New(GinkgoWriter, "", 0)).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	Expect(recorder.Code).To(Equal(http.StatusOK))
+	return recorder.Body.String()
+}
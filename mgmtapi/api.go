@@ -22,7 +22,9 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
 	"github.com/pivotal-cf/on-demand-service-broker/config"
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 type api struct {
@@ -39,12 +41,33 @@ type ManageableBroker interface {
 	Upgrade(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.OperationData, error)
 	Recreate(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.OperationData, error)
 	CountInstancesOfPlans(logger *log.Logger) (map[cf.ServicePlan]int, error)
+	Operations(instanceID string, logger *log.Logger) ([]operationjournal.Operation, error)
+	PreviewUpgrade(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error)
+	PreviewUpdate(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error)
+	PendingChanges(ctx context.Context, instanceID string, logger *log.Logger) (broker.PendingChanges, error)
+	DeployedReleases(logger *log.Logger) (map[string][]bosh.Release, error)
+	Backup(ctx context.Context, instanceID string, logger *log.Logger) (broker.OperationData, error)
+	Backups(ctx context.Context, instanceID string, logger *log.Logger) ([]broker.Backup, error)
+	Restore(ctx context.Context, instanceID, backupID string, logger *log.Logger) (broker.OperationData, error)
+	Reconcile(ctx context.Context, repairs broker.ReconcileRepairs, logger *log.Logger) (broker.DriftReport, error)
+	DeleteOrphanDeployment(ctx context.Context, deploymentName string, runPreDeleteErrands bool, logger *log.Logger) (broker.OperationData, error)
+}
+
+// RestoreDetails is the optional body of a restore request. It names the
+// successful backup to restore; the latest one is restored without it.
+type RestoreDetails struct {
+	BackupID string `json:"backup_id"`
 }
 
 type Deployment struct {
 	Name string `json:"deployment_name"`
 }
 
+type Release struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
 type Metric struct {
 	Key   string  `json:"key"`
 	Value float64 `json:"value"`
@@ -66,8 +89,32 @@ func AttachRoutes(r *mux.Router, manageableBroker ManageableBroker, serviceOffer
 	r.HandleFunc("/mgmt/service_instances/{instance_id}", badRequestHandler()).
 		Methods("PATCH")
 
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/operations", a.listOperations).Methods("GET")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/backups", a.backupInstance).Methods("POST")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/backups", a.listBackups).Methods("GET")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/restore", a.restoreBackup).Methods("POST")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/preview", a.previewInstance(broker.OperationTypeUpgrade)).
+		Methods("POST").
+		Queries("operation_type", "upgrade")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/preview", a.previewInstance(broker.OperationTypeUpdate)).
+		Methods("POST").
+		Queries("operation_type", "update")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/preview", badRequestHandler()).
+		Methods("POST")
+
+	r.HandleFunc("/mgmt/service_instances/{instance_id}/pending_changes", a.pendingChanges).Methods("GET")
+
 	r.HandleFunc("/mgmt/metrics", a.metrics).Methods("GET")
 	r.HandleFunc("/mgmt/orphan_deployments", a.listOrphanDeployments).Methods("GET")
+	r.HandleFunc("/mgmt/deployed_releases", a.listDeployedReleases).Methods("GET")
+	r.HandleFunc("/mgmt/orphan_deployments/{deployment_name}", a.deleteOrphanDeployment).Methods("DELETE")
+	r.HandleFunc("/mgmt/reconcile", a.reconcile).Methods("GET", "POST")
 }
 
 func badRequestHandler() func(w http.ResponseWriter, r *http.Request) {
@@ -81,7 +128,7 @@ func (a *api) listOrphanDeployments(w http.ResponseWriter, r *http.Request) {
 
 	orphanNames, err := a.manageableBroker.OrphanDeployments(logger)
 	if err != nil {
-		logger.Printf("error occurred querying orphan deployments: %s", err)
+		loggerfactory.Errorf(logger, "error occurred querying orphan deployments: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -94,21 +141,72 @@ func (a *api) listOrphanDeployments(w http.ResponseWriter, r *http.Request) {
 	a.writeJson(w, orphanDeployments, logger)
 }
 
+func (a *api) deleteOrphanDeployment(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["deployment_name"]
+	logger := a.loggerFactory.NewWithRequestID()
+
+	runPreDeleteErrands := r.URL.Query().Get("run_pre_delete_errands") == "true"
+	operationData, err := a.manageableBroker.DeleteOrphanDeployment(r.Context(), name, runPreDeleteErrands, logger)
+
+	switch err.(type) {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+		a.writeJson(w, operationData, logger)
+	case broker.DeploymentNotOrphanedError:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	case broker.OperationInProgressError:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		loggerfactory.Errorf(logger, "error occurred deleting orphan deployment %s: %s", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	}
+}
+
+// reconcile reports drift between the platform's instances and their BOSH
+// deployments. A POST may ask for repairs to be made.
+func (a *api) reconcile(w http.ResponseWriter, r *http.Request) {
+	logger := a.loggerFactory.NewWithRequestID()
+
+	var repairs broker.ReconcileRepairs
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&repairs); err != nil {
+			loggerfactory.Errorf(logger, "error occurred parsing requests body: %s", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			a.writeJson(w, brokerapi.ErrorResponse{Description: "Error in request body. Invalid JSON"}, logger)
+			return
+		}
+	}
+
+	report, err := a.manageableBroker.Reconcile(r.Context(), repairs, logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error occurred reconciling service instances: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	a.writeJson(w, report, logger)
+}
+
 func (a *api) listAllInstances(w http.ResponseWriter, r *http.Request) {
 	logger := a.loggerFactory.NewWithRequestID()
 	var instances []service.Instance
 	var err error
 
 	values := r.URL.Query()
-	orgName := values["cf_org"]
-	spaceName := values["cf_space"]
-	if orgName != nil && spaceName != nil {
-		instances, err = a.manageableBroker.FilteredInstances(orgName[0], spaceName[0], logger)
+	orgName := values.Get("cf_org")
+	spaceName := values.Get("cf_space")
+	// An org filters on its own only when all_spaces is set, as cf_org has
+	// always been ignored without cf_space.
+	allSpaces := values.Get("all_spaces") == "true"
+	if orgName != "" && (spaceName != "" || allSpaces) {
+		instances, err = a.manageableBroker.FilteredInstances(orgName, spaceName, logger)
 	} else {
 		instances, err = a.manageableBroker.Instances(logger)
 	}
 	if err != nil {
-		logger.Printf("error occurred querying instances: %s", err)
+		loggerfactory.Errorf(logger, "error occurred querying instances: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -116,6 +214,45 @@ func (a *api) listAllInstances(w http.ResponseWriter, r *http.Request) {
 	a.writeJson(w, instances, logger)
 }
 
+func (a *api) listOperations(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+	logger := a.loggerFactory.NewWithRequestID()
+
+	operations, err := a.manageableBroker.Operations(instanceID, logger)
+
+	switch err.(type) {
+	case nil:
+		a.writeJson(w, operations, logger)
+	case broker.OperationJournalDisabledError:
+		w.WriteHeader(http.StatusNotImplemented)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	default:
+		loggerfactory.Errorf(logger, "error occurred querying operations for instance %s: %s", instanceID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (a *api) listDeployedReleases(w http.ResponseWriter, r *http.Request) {
+	logger := a.loggerFactory.NewWithRequestID()
+
+	deployedReleases, err := a.manageableBroker.DeployedReleases(logger)
+	if err != nil {
+		loggerfactory.Errorf(logger, "error occurred querying deployed releases: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	releasesByInstance := map[string][]Release{}
+	for instanceID, instanceReleases := range deployedReleases {
+		releases := []Release{}
+		for _, release := range instanceReleases {
+			releases = append(releases, Release{Name: release.Name, Version: release.Version})
+		}
+		releasesByInstance[instanceID] = releases
+	}
+	a.writeJson(w, releasesByInstance, logger)
+}
+
 func (a *api) recreateInstance(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["instance_id"]
@@ -127,12 +264,15 @@ func (a *api) recreateInstance(w http.ResponseWriter, r *http.Request) {
 
 	var details brokerapi.UpdateDetails
 	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
-		logger.Printf("error occurred parsing requests body: %s", err)
+		loggerfactory.Errorf(logger, "error occurred parsing requests body: %s", err)
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		a.writeJson(w, brokerapi.ErrorResponse{Description: "Error in request body. Invalid JSON"}, logger)
 		return
 	}
 
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
+	logger = a.loggerFactory.NewWithContext(ctx)
+
 	operationData, err := a.manageableBroker.Recreate(ctx, instanceID, details, logger)
 
 	switch err.(type) {
@@ -146,7 +286,7 @@ func (a *api) recreateInstance(w http.ResponseWriter, r *http.Request) {
 	case broker.OperationInProgressError:
 		w.WriteHeader(http.StatusConflict)
 	case error:
-		logger.Printf("error occurred recreating instance %s: %s", instanceID, err)
+		loggerfactory.Errorf(logger, "error occurred recreating instance %s: %s", instanceID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
 	}
@@ -163,12 +303,15 @@ func (a *api) upgradeInstance(w http.ResponseWriter, r *http.Request) {
 
 	var details brokerapi.UpdateDetails
 	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
-		logger.Printf("error occurred parsing requests body: %s", err)
+		loggerfactory.Errorf(logger, "error occurred parsing requests body: %s", err)
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		a.writeJson(w, brokerapi.ErrorResponse{Description: "Error in request body. Invalid JSON"}, logger)
 		return
 	}
 
+	ctx = brokercontext.WithPlanID(ctx, details.PlanID)
+	logger = a.loggerFactory.NewWithContext(ctx)
+
 	operationData, err := a.manageableBroker.Upgrade(ctx, instanceID, details, logger)
 
 	switch err.(type) {
@@ -182,7 +325,144 @@ func (a *api) upgradeInstance(w http.ResponseWriter, r *http.Request) {
 	case broker.OperationInProgressError:
 		w.WriteHeader(http.StatusConflict)
 	case error:
-		logger.Printf("error occurred upgrading instance %s: %s", instanceID, err)
+		loggerfactory.Errorf(logger, "error occurred upgrading instance %s: %s", instanceID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	}
+}
+
+func (a *api) backupInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+
+	requestID := uuid.New()
+	ctx := brokercontext.New(r.Context(), string(broker.OperationTypeBackup), requestID, a.serviceOffering.Name, instanceID)
+
+	logger := a.loggerFactory.NewWithContext(ctx)
+
+	operationData, err := a.manageableBroker.Backup(ctx, instanceID, logger)
+	a.writeBackupOperation(w, operationData, err, "backing up", instanceID, logger)
+}
+
+func (a *api) restoreBackup(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+
+	requestID := uuid.New()
+	ctx := brokercontext.New(r.Context(), string(broker.OperationTypeRestore), requestID, a.serviceOffering.Name, instanceID)
+
+	logger := a.loggerFactory.NewWithContext(ctx)
+
+	var details RestoreDetails
+	if err := json.NewDecoder(r.Body).Decode(&details); err != nil && err != io.EOF {
+		loggerfactory.Errorf(logger, "error occurred parsing requests body: %s", err)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: "Error in request body. Invalid JSON"}, logger)
+		return
+	}
+
+	operationData, err := a.manageableBroker.Restore(ctx, instanceID, details.BackupID, logger)
+	a.writeBackupOperation(w, operationData, err, "restoring", instanceID, logger)
+}
+
+func (a *api) writeBackupOperation(w http.ResponseWriter, operationData broker.OperationData, err error, action, instanceID string, logger *log.Logger) {
+	switch err.(type) {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+		a.writeJson(w, operationData, logger)
+	case broker.BackupNotFoundError:
+		w.WriteHeader(http.StatusNotFound)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	case broker.DeploymentNotFoundError:
+		w.WriteHeader(http.StatusGone)
+	case broker.OperationInProgressError:
+		w.WriteHeader(http.StatusConflict)
+	case broker.BackupNotConfiguredError, broker.BackupNotRestorableError:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	case broker.OperationJournalDisabledError:
+		w.WriteHeader(http.StatusNotImplemented)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	default:
+		loggerfactory.Errorf(logger, "error occurred %s instance %s: %s", action, instanceID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	}
+}
+
+func (a *api) listBackups(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+	logger := a.loggerFactory.NewWithRequestID()
+
+	backups, err := a.manageableBroker.Backups(r.Context(), instanceID, logger)
+
+	switch err.(type) {
+	case nil:
+		a.writeJson(w, backups, logger)
+	case broker.OperationJournalDisabledError:
+		w.WriteHeader(http.StatusNotImplemented)
+		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+	default:
+		loggerfactory.Errorf(logger, "error occurred querying backups for instance %s: %s", instanceID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (a *api) previewInstance(operationType broker.OperationType) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		instanceID := vars["instance_id"]
+
+		requestID := uuid.New()
+		ctx := brokercontext.New(r.Context(), "preview-"+string(operationType), requestID, a.serviceOffering.Name, instanceID)
+
+		logger := a.loggerFactory.NewWithContext(ctx)
+
+		var details brokerapi.UpdateDetails
+		if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
+			loggerfactory.Errorf(logger, "error occurred parsing requests body: %s", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			a.writeJson(w, brokerapi.ErrorResponse{Description: "Error in request body. Invalid JSON"}, logger)
+			return
+		}
+
+		var preview broker.DeploymentPreview
+		var err error
+		if operationType == broker.OperationTypeUpgrade {
+			preview, err = a.manageableBroker.PreviewUpgrade(ctx, instanceID, details, logger)
+		} else {
+			preview, err = a.manageableBroker.PreviewUpdate(ctx, instanceID, details, logger)
+		}
+
+		switch err.(type) {
+		case nil:
+			a.writeJson(w, preview, logger)
+		case broker.DeploymentNotFoundError:
+			w.WriteHeader(http.StatusGone)
+		case error:
+			loggerfactory.Errorf(logger, "error occurred previewing %s of instance %s: %s", operationType, instanceID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
+		}
+	}
+}
+
+func (a *api) pendingChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instance_id"]
+
+	requestID := uuid.New()
+	ctx := brokercontext.New(r.Context(), "pending-changes", requestID, a.serviceOffering.Name, instanceID)
+
+	logger := a.loggerFactory.NewWithContext(ctx)
+
+	pendingChanges, err := a.manageableBroker.PendingChanges(ctx, instanceID, logger)
+
+	switch err.(type) {
+	case nil:
+		a.writeJson(w, pendingChanges, logger)
+	case broker.DeploymentNotFoundError:
+		w.WriteHeader(http.StatusGone)
+	case error:
+		loggerfactory.Errorf(logger, "error occurred checking instance %s for pending changes: %s", instanceID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		a.writeJson(w, brokerapi.ErrorResponse{Description: err.Error()}, logger)
 	}
@@ -195,7 +475,7 @@ func (a *api) metrics(w http.ResponseWriter, r *http.Request) {
 	instanceCountsByPlan, err := a.manageableBroker.CountInstancesOfPlans(logger)
 
 	if err != nil {
-		logger.Printf("error getting instance count for service offering %s: %s", a.serviceOffering.Name, err)
+		loggerfactory.Errorf(logger, "error getting instance count for service offering %s: %s", a.serviceOffering.Name, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -258,7 +538,7 @@ func (a *api) metrics(w http.ResponseWriter, r *http.Request) {
 
 func (a *api) writeJson(w io.Writer, obj interface{}, logger *log.Logger) {
 	if err := json.NewEncoder(w).Encode(obj); err != nil {
-		logger.Printf("error occurred encoding json: %s", err)
+		loggerfactory.Errorf(logger, "error occurred encoding json: %s", err)
 	}
 }
 
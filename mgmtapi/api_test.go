@@ -15,6 +15,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"strings"
 
@@ -29,7 +30,9 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi/fake_manageable_broker"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 var _ = Describe("Management API", func() {
@@ -132,6 +135,48 @@ var _ = Describe("Management API", func() {
 				Expect(instancesResp).To(ConsistOf(instance1, instance2, instance3))
 			})
 
+			It("lists all instances when only an org is given", func() {
+				manageableBroker.InstancesReturns([]service.Instance{instance1, instance2, instance3}, nil)
+
+				listResp, err := http.Get(fmt.Sprintf("%s/mgmt/service_instances?cf_org=banana", server.URL))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+				Expect(manageableBroker.FilteredInstancesCallCount()).To(BeZero())
+				var instancesResp []service.Instance
+				Expect(json.NewDecoder(listResp.Body).Decode(&instancesResp)).To(Succeed())
+				Expect(instancesResp).To(ConsistOf(instance1, instance2, instance3))
+			})
+
+			It("filters by org alone when all_spaces is set", func() {
+				manageableBroker.FilteredInstancesReturns([]service.Instance{instance1}, nil)
+
+				listResp, err := http.Get(fmt.Sprintf("%s/mgmt/service_instances?cf_org=banana&all_spaces=true", server.URL))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+				Expect(manageableBroker.InstancesCallCount()).To(BeZero())
+				orgName, spaceName, _ := manageableBroker.FilteredInstancesArgsForCall(0)
+				Expect(orgName).To(Equal("banana"))
+				Expect(spaceName).To(BeEmpty())
+				var instancesResp []service.Instance
+				Expect(json.NewDecoder(listResp.Body).Decode(&instancesResp)).To(Succeed())
+				Expect(instancesResp).To(ConsistOf(instance1))
+			})
+
+			It("lists all instances when only a space is given", func() {
+				manageableBroker.InstancesReturns([]service.Instance{instance1, instance2, instance3}, nil)
+
+				listResp, err := http.Get(fmt.Sprintf("%s/mgmt/service_instances?cf_space=latundan", server.URL))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+				Expect(manageableBroker.FilteredInstancesCallCount()).To(BeZero())
+				var instancesResp []service.Instance
+				Expect(json.NewDecoder(listResp.Body).Decode(&instancesResp)).To(Succeed())
+				Expect(instancesResp).To(ConsistOf(instance1, instance2, instance3))
+			})
+
 			It("returns HTTP 500 and logs the error", func() {
 				manageableBroker.FilteredInstancesReturns(nil, errors.New("error getting instances"))
 
@@ -706,6 +751,656 @@ var _ = Describe("Management API", func() {
 			})
 		})
 	})
+
+	Describe("deleting an orphan deployment", func() {
+		deleteOrphan := func(query string) *http.Response {
+			req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/mgmt/orphan_deployments/service-instance_orphan%s", server.URL, query), nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			return resp
+		}
+
+		It("returns HTTP 202 with the operation data", func() {
+			manageableBroker.DeleteOrphanDeploymentReturns(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeDelete}, nil)
+
+			resp := deleteOrphan("")
+
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			var operationData broker.OperationData
+			Expect(json.NewDecoder(resp.Body).Decode(&operationData)).To(Succeed())
+			Expect(operationData).To(Equal(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeDelete}))
+
+			_, deploymentName, runPreDeleteErrands, _ := manageableBroker.DeleteOrphanDeploymentArgsForCall(0)
+			Expect(deploymentName).To(Equal("service-instance_orphan"))
+			Expect(runPreDeleteErrands).To(BeFalse())
+		})
+
+		It("asks for the pre-delete errands to be run", func() {
+			deleteOrphan("?run_pre_delete_errands=true")
+
+			_, _, runPreDeleteErrands, _ := manageableBroker.DeleteOrphanDeploymentArgsForCall(0)
+			Expect(runPreDeleteErrands).To(BeTrue())
+		})
+
+		It("returns HTTP 422 when the deployment is not an orphan", func() {
+			manageableBroker.DeleteOrphanDeploymentReturns(broker.OperationData{}, broker.NewDeploymentNotOrphanedError(errors.New("not an orphan")))
+
+			resp := deleteOrphan("")
+
+			Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+			var errorResponse brokerapi.ErrorResponse
+			Expect(json.NewDecoder(resp.Body).Decode(&errorResponse)).To(Succeed())
+			Expect(errorResponse.Description).To(Equal("not an orphan"))
+		})
+
+		It("returns HTTP 409 when an operation is in progress on the deployment", func() {
+			manageableBroker.DeleteOrphanDeploymentReturns(broker.OperationData{}, broker.NewOperationInProgressError(errors.New("busy")))
+
+			resp := deleteOrphan("")
+
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("returns HTTP 500 when the deployment cannot be deleted", func() {
+			manageableBroker.DeleteOrphanDeploymentReturns(broker.OperationData{}, errors.New("bosh unavailable"))
+
+			resp := deleteOrphan("")
+
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Eventually(logs).Should(gbytes.Say("error occurred deleting orphan deployment service-instance_orphan: bosh unavailable"))
+		})
+	})
+
+	Describe("reconciling service instances", func() {
+		BeforeEach(func() {
+			manageableBroker.ReconcileReturns(broker.DriftReport{
+				OrphanDeployments:  []string{"service-instance_orphan"},
+				MissingDeployments: []string{"missing-instance"},
+				PlanMismatches:     []broker.PlanMismatch{{InstanceID: "some-instance", PlanID: "plan-b", DeployedPlanID: "plan-a"}},
+				FailedDeployments:  []broker.FailedDeployment{},
+			}, nil)
+		})
+
+		It("reports drift without repairing anything", func() {
+			resp, err := http.Get(fmt.Sprintf("%s/mgmt/reconcile", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(MatchJSON(`{
+				"orphan_deployments": ["service-instance_orphan"],
+				"missing_deployments": ["missing-instance"],
+				"plan_mismatches": [{"service_instance_id": "some-instance", "plan_id": "plan-b", "deployed_plan_id": "plan-a"}],
+				"failed_deployments": []
+			}`))
+
+			Expect(manageableBroker.ReconcileCallCount()).To(Equal(1))
+			_, repairs, _ := manageableBroker.ReconcileArgsForCall(0)
+			Expect(repairs).To(Equal(broker.ReconcileRepairs{}))
+		})
+
+		It("passes on the requested repairs", func() {
+			resp, err := http.Post(
+				fmt.Sprintf("%s/mgmt/reconcile", server.URL),
+				"application/json",
+				strings.NewReader(`{"redeploy_plan_mismatches": true, "delete_orphans": ["service-instance_orphan"], "run_pre_delete_errands": true}`),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			_, repairs, _ := manageableBroker.ReconcileArgsForCall(0)
+			Expect(repairs).To(Equal(broker.ReconcileRepairs{
+				RedeployPlanMismatches: true,
+				DeleteOrphans:          []string{"service-instance_orphan"},
+				RunPreDeleteErrands:    true,
+			}))
+		})
+
+		It("returns HTTP 422 when the repairs cannot be parsed", func() {
+			resp, err := http.Post(fmt.Sprintf("%s/mgmt/reconcile", server.URL), "application/json", strings.NewReader("not json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+			Expect(manageableBroker.ReconcileCallCount()).To(BeZero())
+		})
+
+		It("returns HTTP 500 when reconciling fails", func() {
+			manageableBroker.ReconcileReturns(broker.DriftReport{}, errors.New("bosh unavailable"))
+
+			resp, err := http.Get(fmt.Sprintf("%s/mgmt/reconcile", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Eventually(logs).Should(gbytes.Say("error occurred reconciling service instances: bosh unavailable"))
+		})
+	})
+
+	Describe("listing the operations of an instance", func() {
+		var listResp *http.Response
+
+		JustBeforeEach(func() {
+			var err error
+			listResp, err = http.Get(fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/operations", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when operations have been recorded", func() {
+			var startedAt = time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+			BeforeEach(func() {
+				manageableBroker.OperationsReturns([]operationjournal.Operation{
+					{
+						ID:          "42",
+						InstanceID:  "some-instance-id",
+						Type:        "upgrade",
+						PlanID:      "foo_id",
+						BoshTaskIDs: []int{42, 43},
+						Errands:     []string{"health-check"},
+						RequestID:   "some-request-id",
+						State:       "succeeded",
+						Description: "Instance upgrade completed",
+						StartedAt:   startedAt,
+						UpdatedAt:   startedAt.Add(time.Minute),
+					},
+				}, nil)
+			})
+
+			It("returns the operations of the instance", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(listResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(MatchJSON(`[{
+					"id": "42",
+					"service_instance_id": "some-instance-id",
+					"operation_type": "upgrade",
+					"plan_id": "foo_id",
+					"bosh_task_ids": [42, 43],
+					"errands": ["health-check"],
+					"request_id": "some-request-id",
+					"state": "succeeded",
+					"description": "Instance upgrade completed",
+					"started_at": "2018-03-01T10:00:00Z",
+					"updated_at": "2018-03-01T10:01:00Z"
+				}]`))
+
+				Expect(manageableBroker.OperationsCallCount()).To(Equal(1))
+				instanceID, _ := manageableBroker.OperationsArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+			})
+		})
+
+		Context("when the operation journal is not enabled", func() {
+			BeforeEach(func() {
+				manageableBroker.OperationsReturns(nil, broker.NewOperationJournalDisabledError(errors.New("journal disabled")))
+			})
+
+			It("returns HTTP 501", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusNotImplemented))
+
+				var errorResponse brokerapi.ErrorResponse
+				Expect(json.NewDecoder(listResp.Body).Decode(&errorResponse)).To(Succeed())
+				Expect(errorResponse.Description).To(Equal("journal disabled"))
+			})
+		})
+
+		Context("when the journal cannot be read", func() {
+			BeforeEach(func() {
+				manageableBroker.OperationsReturns(nil, errors.New("disk on fire"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusInternalServerError))
+				Eventually(logs).Should(gbytes.Say("error occurred querying operations for instance some-instance-id: disk on fire"))
+			})
+		})
+	})
+
+	Describe("backing up an instance", func() {
+		var backupResp *http.Response
+
+		JustBeforeEach(func() {
+			var err error
+			backupResp, err = http.Post(
+				fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/backups", server.URL),
+				"application/json",
+				nil,
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the backup errand starts", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupReturns(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeBackup, PlanID: "foo_id"}, nil)
+			})
+
+			It("returns HTTP 202 with the operation data", func() {
+				Expect(backupResp.StatusCode).To(Equal(http.StatusAccepted))
+
+				var operationData broker.OperationData
+				Expect(json.NewDecoder(backupResp.Body).Decode(&operationData)).To(Succeed())
+				Expect(operationData).To(Equal(broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeBackup, PlanID: "foo_id"}))
+
+				_, instanceID, _ := manageableBroker.BackupArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+			})
+		})
+
+		Context("when the plan has no backup errands", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupReturns(broker.OperationData{}, broker.NewBackupNotConfiguredError(errors.New("no backup errands")))
+			})
+
+			It("returns HTTP 422", func() {
+				Expect(backupResp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+				var errorResponse brokerapi.ErrorResponse
+				Expect(json.NewDecoder(backupResp.Body).Decode(&errorResponse)).To(Succeed())
+				Expect(errorResponse.Description).To(Equal("no backup errands"))
+			})
+		})
+
+		Context("when another operation is in progress", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupReturns(broker.OperationData{}, broker.NewOperationInProgressError(errors.New("busy")))
+			})
+
+			It("returns HTTP 409", func() {
+				Expect(backupResp.StatusCode).To(Equal(http.StatusConflict))
+			})
+		})
+
+		Context("when the backup fails to start", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupReturns(broker.OperationData{}, errors.New("bosh unavailable"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(backupResp.StatusCode).To(Equal(http.StatusInternalServerError))
+				Eventually(logs).Should(gbytes.Say("error occurred backing up instance some-instance-id: bosh unavailable"))
+			})
+		})
+	})
+
+	Describe("listing the backups of an instance", func() {
+		var listResp *http.Response
+
+		JustBeforeEach(func() {
+			var err error
+			listResp, err = http.Get(fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/backups", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when backups have been made", func() {
+			var startedAt = time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+			BeforeEach(func() {
+				manageableBroker.BackupsReturns([]broker.Backup{{
+					ID:         "42",
+					PlanID:     "foo_id",
+					BoshTaskID: 42,
+					State:      "succeeded",
+					Artefact:   "s3://backups/42",
+					StartedAt:  startedAt,
+					UpdatedAt:  startedAt.Add(time.Minute),
+				}}, nil)
+			})
+
+			It("returns the backups of the instance", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(listResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(MatchJSON(`[{
+					"backup_id": "42",
+					"plan_id": "foo_id",
+					"bosh_task_id": 42,
+					"state": "succeeded",
+					"artefact": "s3://backups/42",
+					"started_at": "2018-03-01T10:00:00Z",
+					"updated_at": "2018-03-01T10:01:00Z"
+				}]`))
+
+				_, instanceID, _ := manageableBroker.BackupsArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+			})
+		})
+
+		Context("when the operation journal is not enabled", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupsReturns(nil, broker.NewOperationJournalDisabledError(errors.New("journal disabled")))
+			})
+
+			It("returns HTTP 501", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusNotImplemented))
+			})
+		})
+
+		Context("when the backups cannot be listed", func() {
+			BeforeEach(func() {
+				manageableBroker.BackupsReturns(nil, errors.New("disk on fire"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusInternalServerError))
+				Eventually(logs).Should(gbytes.Say("error occurred querying backups for instance some-instance-id: disk on fire"))
+			})
+		})
+	})
+
+	Describe("restoring a backup", func() {
+		var (
+			restoreResp *http.Response
+			restoreBody io.Reader
+		)
+
+		BeforeEach(func() {
+			restoreBody = nil
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			restoreResp, err = http.Post(
+				fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/restore", server.URL),
+				"application/json",
+				restoreBody,
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the restore errand starts", func() {
+			BeforeEach(func() {
+				manageableBroker.RestoreReturns(broker.OperationData{BoshTaskID: 43, OperationType: broker.OperationTypeRestore}, nil)
+			})
+
+			It("returns HTTP 202 with the operation data", func() {
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusAccepted))
+
+				_, instanceID, backupID, _ := manageableBroker.RestoreArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+				Expect(backupID).To(BeEmpty())
+			})
+
+			Context("and a backup is named", func() {
+				BeforeEach(func() {
+					restoreBody = strings.NewReader(`{"backup_id": "41"}`)
+				})
+
+				It("asks for that backup to be restored", func() {
+					Expect(restoreResp.StatusCode).To(Equal(http.StatusAccepted))
+
+					_, _, backupID, _ := manageableBroker.RestoreArgsForCall(0)
+					Expect(backupID).To(Equal("41"))
+				})
+			})
+		})
+
+		Context("when the request body is invalid", func() {
+			BeforeEach(func() {
+				restoreBody = strings.NewReader(`{"backup_id":`)
+			})
+
+			It("returns HTTP 422 without restoring", func() {
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+				Expect(manageableBroker.RestoreCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the broker cannot restore backups", func() {
+			BeforeEach(func() {
+				manageableBroker.RestoreReturns(broker.OperationData{}, broker.NewBackupNotRestorableError(errors.New("restores require credhub")))
+			})
+
+			It("returns HTTP 422 with the reason", func() {
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+				body, err := ioutil.ReadAll(restoreResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("restores require credhub"))
+			})
+		})
+
+		Context("when the instance has no successful backup", func() {
+			BeforeEach(func() {
+				manageableBroker.RestoreReturns(broker.OperationData{}, broker.NewBackupNotFoundError(errors.New("not found")))
+			})
+
+			It("returns HTTP 404", func() {
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when the deployment is gone", func() {
+			BeforeEach(func() {
+				manageableBroker.RestoreReturns(broker.OperationData{}, broker.NewDeploymentNotFoundError(errors.New("gone")))
+			})
+
+			It("returns HTTP 410", func() {
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusGone))
+			})
+		})
+	})
+
+	Describe("listing the deployed releases of every instance", func() {
+		var listResp *http.Response
+
+		JustBeforeEach(func() {
+			var err error
+			listResp, err = http.Get(fmt.Sprintf("%s/mgmt/deployed_releases", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the releases can be retrieved", func() {
+			BeforeEach(func() {
+				manageableBroker.DeployedReleasesReturns(map[string][]bosh.Release{
+					"some-instance-id":  {{Name: "redis", Version: "1.2.3"}, {Name: "syslog", Version: "11"}},
+					"other-instance-id": nil,
+				}, nil)
+			})
+
+			It("returns the releases of each instance by instance ID", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(listResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(MatchJSON(`{
+					"some-instance-id": [
+						{"name": "redis", "version": "1.2.3"},
+						{"name": "syslog", "version": "11"}
+					],
+					"other-instance-id": []
+				}`))
+				Expect(manageableBroker.DeployedReleasesCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the releases cannot be retrieved", func() {
+			BeforeEach(func() {
+				manageableBroker.DeployedReleasesReturns(nil, errors.New("bosh is down"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(listResp.StatusCode).To(Equal(http.StatusInternalServerError))
+				Eventually(logs).Should(gbytes.Say("error occurred querying deployed releases: bosh is down"))
+			})
+		})
+	})
+
+	Describe("previewing an operation on an instance", func() {
+		var (
+			previewResp   *http.Response
+			operationType string
+			requestBody   string
+		)
+
+		BeforeEach(func() {
+			operationType = "upgrade"
+			requestBody = `{"plan_id": "foo_id"}`
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			previewResp, err = http.Post(
+				fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/preview?operation_type=%s", server.URL, operationType),
+				"application/json",
+				strings.NewReader(requestBody),
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when previewing an upgrade", func() {
+			BeforeEach(func() {
+				manageableBroker.PreviewUpgradeReturns(broker.DeploymentPreview{
+					ManifestDiff: "--- deployed/manifest.yml\n+++ generated/manifest.yml\n",
+					ConfigDiffs:  map[string]string{"cloud": "--- deployed/cloud-config.yml\n"},
+				}, nil)
+			})
+
+			It("returns the diffs", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(previewResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(MatchJSON(`{
+					"manifest_diff": "--- deployed/manifest.yml\n+++ generated/manifest.yml\n",
+					"config_diffs": {"cloud": "--- deployed/cloud-config.yml\n"}
+				}`))
+
+				Expect(manageableBroker.PreviewUpgradeCallCount()).To(Equal(1))
+				_, instanceID, details, _ := manageableBroker.PreviewUpgradeArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+				Expect(details.PlanID).To(Equal("foo_id"))
+				Expect(manageableBroker.PreviewUpdateCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when previewing an update", func() {
+			BeforeEach(func() {
+				operationType = "update"
+				requestBody = `{"plan_id": "bar_id", "parameters": {"maxclients": 200}, "previous_values": {"plan_id": "foo_id"}}`
+			})
+
+			It("passes the update details to the broker", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusOK))
+
+				Expect(manageableBroker.PreviewUpdateCallCount()).To(Equal(1))
+				_, instanceID, details, _ := manageableBroker.PreviewUpdateArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+				Expect(details.PlanID).To(Equal("bar_id"))
+				Expect(details.PreviousValues.PlanID).To(Equal("foo_id"))
+				Expect(details.RawParameters).To(MatchJSON(`{"maxclients": 200}`))
+				Expect(manageableBroker.PreviewUpgradeCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the operation type is not supported", func() {
+			BeforeEach(func() {
+				operationType = "recreate"
+			})
+
+			It("returns HTTP 400", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when the request body is invalid", func() {
+			BeforeEach(func() {
+				requestBody = "not-json"
+			})
+
+			It("returns HTTP 422", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+			})
+		})
+
+		Context("when the deployment does not exist", func() {
+			BeforeEach(func() {
+				manageableBroker.PreviewUpgradeReturns(broker.DeploymentPreview{}, broker.NewDeploymentNotFoundError(errors.New("not found")))
+			})
+
+			It("returns HTTP 410", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusGone))
+			})
+		})
+
+		Context("when the preview fails", func() {
+			BeforeEach(func() {
+				manageableBroker.PreviewUpgradeReturns(broker.DeploymentPreview{}, errors.New("adapter failed"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(previewResp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+				var errorResponse brokerapi.ErrorResponse
+				Expect(json.NewDecoder(previewResp.Body).Decode(&errorResponse)).To(Succeed())
+				Expect(errorResponse.Description).To(Equal("adapter failed"))
+				Eventually(logs).Should(gbytes.Say("error occurred previewing upgrade of instance some-instance-id: adapter failed"))
+			})
+		})
+	})
+
+	Describe("listing the pending changes of an instance", func() {
+		var pendingChangesResp *http.Response
+
+		JustBeforeEach(func() {
+			var err error
+			pendingChangesResp, err = http.Get(fmt.Sprintf("%s/mgmt/service_instances/some-instance-id/pending_changes", server.URL))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the pending changes can be computed", func() {
+			BeforeEach(func() {
+				manageableBroker.PendingChangesReturns(broker.PendingChanges{
+					Changes: []broker.PendingChange{
+						{Path: "instance_groups/redis-server/jobs/redis/properties/maxclients", Change: broker.PendingChangeChanged},
+					},
+					ManifestDiff: "--- deployed/manifest.yml\n+++ generated/manifest.yml\n",
+				}, nil)
+			})
+
+			It("returns the changes", func() {
+				Expect(pendingChangesResp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := ioutil.ReadAll(pendingChangesResp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(MatchJSON(`{
+					"changes": [{"path": "instance_groups/redis-server/jobs/redis/properties/maxclients", "change": "changed"}],
+					"manifest_diff": "--- deployed/manifest.yml\n+++ generated/manifest.yml\n"
+				}`))
+
+				Expect(manageableBroker.PendingChangesCallCount()).To(Equal(1))
+				_, instanceID, _ := manageableBroker.PendingChangesArgsForCall(0)
+				Expect(instanceID).To(Equal("some-instance-id"))
+			})
+		})
+
+		Context("when the deployment does not exist", func() {
+			BeforeEach(func() {
+				manageableBroker.PendingChangesReturns(broker.PendingChanges{}, broker.NewDeploymentNotFoundError(errors.New("not found")))
+			})
+
+			It("returns HTTP 410", func() {
+				Expect(pendingChangesResp.StatusCode).To(Equal(http.StatusGone))
+			})
+		})
+
+		Context("when the pending changes cannot be computed", func() {
+			BeforeEach(func() {
+				manageableBroker.PendingChangesReturns(broker.PendingChanges{}, errors.New("adapter failed"))
+			})
+
+			It("returns HTTP 500 and logs the error", func() {
+				Expect(pendingChangesResp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+				var errorResponse brokerapi.ErrorResponse
+				Expect(json.NewDecoder(pendingChangesResp.Body).Decode(&errorResponse)).To(Succeed())
+				Expect(errorResponse.Description).To(Equal("adapter failed"))
+				Eventually(logs).Should(gbytes.Say("error occurred checking instance some-instance-id for pending changes: adapter failed"))
+			})
+		})
+	})
 })
 
 func Patch(url, body string) (resp *http.Response, err error) {
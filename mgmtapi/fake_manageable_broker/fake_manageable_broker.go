@@ -10,7 +10,9 @@ import (
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
 	"github.com/pivotal-cf/on-demand-service-broker/cf"
 	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
 	"github.com/pivotal-cf/on-demand-service-broker/service"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 )
 
 type FakeManageableBroker struct {
@@ -100,6 +102,157 @@ type FakeManageableBroker struct {
 		result1 map[cf.ServicePlan]int
 		result2 error
 	}
+	OperationsStub        func(instanceID string, logger *log.Logger) ([]operationjournal.Operation, error)
+	operationsMutex       sync.RWMutex
+	operationsArgsForCall []struct {
+		instanceID string
+		logger     *log.Logger
+	}
+	operationsReturns struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}
+	operationsReturnsOnCall map[int]struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}
+	PreviewUpgradeStub        func(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error)
+	previewUpgradeMutex       sync.RWMutex
+	previewUpgradeArgsForCall []struct {
+		ctx           context.Context
+		instanceID    string
+		updateDetails brokerapi.UpdateDetails
+		logger        *log.Logger
+	}
+	previewUpgradeReturns struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	previewUpgradeReturnsOnCall map[int]struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	PreviewUpdateStub        func(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error)
+	previewUpdateMutex       sync.RWMutex
+	previewUpdateArgsForCall []struct {
+		ctx           context.Context
+		instanceID    string
+		updateDetails brokerapi.UpdateDetails
+		logger        *log.Logger
+	}
+	previewUpdateReturns struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	previewUpdateReturnsOnCall map[int]struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}
+	PendingChangesStub        func(ctx context.Context, instanceID string, logger *log.Logger) (broker.PendingChanges, error)
+	pendingChangesMutex       sync.RWMutex
+	pendingChangesArgsForCall []struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}
+	pendingChangesReturns struct {
+		result1 broker.PendingChanges
+		result2 error
+	}
+	pendingChangesReturnsOnCall map[int]struct {
+		result1 broker.PendingChanges
+		result2 error
+	}
+	DeployedReleasesStub        func(logger *log.Logger) (map[string][]bosh.Release, error)
+	deployedReleasesMutex       sync.RWMutex
+	deployedReleasesArgsForCall []struct {
+		logger *log.Logger
+	}
+	deployedReleasesReturns struct {
+		result1 map[string][]bosh.Release
+		result2 error
+	}
+	deployedReleasesReturnsOnCall map[int]struct {
+		result1 map[string][]bosh.Release
+		result2 error
+	}
+	BackupStub        func(ctx context.Context, instanceID string, logger *log.Logger) (broker.OperationData, error)
+	backupMutex       sync.RWMutex
+	backupArgsForCall []struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}
+	backupReturns struct {
+		result1 broker.OperationData
+		result2 error
+	}
+	backupReturnsOnCall map[int]struct {
+		result1 broker.OperationData
+		result2 error
+	}
+	BackupsStub        func(ctx context.Context, instanceID string, logger *log.Logger) ([]broker.Backup, error)
+	backupsMutex       sync.RWMutex
+	backupsArgsForCall []struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}
+	backupsReturns struct {
+		result1 []broker.Backup
+		result2 error
+	}
+	backupsReturnsOnCall map[int]struct {
+		result1 []broker.Backup
+		result2 error
+	}
+	RestoreStub        func(ctx context.Context, instanceID string, backupID string, logger *log.Logger) (broker.OperationData, error)
+	restoreMutex       sync.RWMutex
+	restoreArgsForCall []struct {
+		ctx        context.Context
+		instanceID string
+		backupID   string
+		logger     *log.Logger
+	}
+	restoreReturns struct {
+		result1 broker.OperationData
+		result2 error
+	}
+	restoreReturnsOnCall map[int]struct {
+		result1 broker.OperationData
+		result2 error
+	}
+	ReconcileStub        func(ctx context.Context, repairs broker.ReconcileRepairs, logger *log.Logger) (broker.DriftReport, error)
+	reconcileMutex       sync.RWMutex
+	reconcileArgsForCall []struct {
+		ctx     context.Context
+		repairs broker.ReconcileRepairs
+		logger  *log.Logger
+	}
+	reconcileReturns struct {
+		result1 broker.DriftReport
+		result2 error
+	}
+	reconcileReturnsOnCall map[int]struct {
+		result1 broker.DriftReport
+		result2 error
+	}
+	DeleteOrphanDeploymentStub        func(ctx context.Context, deploymentName string, runPreDeleteErrands bool, logger *log.Logger) (broker.OperationData, error)
+	deleteOrphanDeploymentMutex       sync.RWMutex
+	deleteOrphanDeploymentArgsForCall []struct {
+		ctx                 context.Context
+		deploymentName      string
+		runPreDeleteErrands bool
+		logger              *log.Logger
+	}
+	deleteOrphanDeploymentReturns struct {
+		result1 broker.OperationData
+		result2 error
+	}
+	deleteOrphanDeploymentReturnsOnCall map[int]struct {
+		result1 broker.OperationData
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -418,6 +571,537 @@ func (fake *FakeManageableBroker) CountInstancesOfPlansReturnsOnCall(i int, resu
 	}{result1, result2}
 }
 
+func (fake *FakeManageableBroker) Operations(instanceID string, logger *log.Logger) ([]operationjournal.Operation, error) {
+	fake.operationsMutex.Lock()
+	ret, specificReturn := fake.operationsReturnsOnCall[len(fake.operationsArgsForCall)]
+	fake.operationsArgsForCall = append(fake.operationsArgsForCall, struct {
+		instanceID string
+		logger     *log.Logger
+	}{instanceID, logger})
+	fake.recordInvocation("Operations", []interface{}{instanceID, logger})
+	fake.operationsMutex.Unlock()
+	if fake.OperationsStub != nil {
+		return fake.OperationsStub(instanceID, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.operationsReturns.result1, fake.operationsReturns.result2
+}
+
+func (fake *FakeManageableBroker) OperationsCallCount() int {
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	return len(fake.operationsArgsForCall)
+}
+
+func (fake *FakeManageableBroker) OperationsArgsForCall(i int) (string, *log.Logger) {
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	return fake.operationsArgsForCall[i].instanceID, fake.operationsArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) OperationsReturns(result1 []operationjournal.Operation, result2 error) {
+	fake.OperationsStub = nil
+	fake.operationsReturns = struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) OperationsReturnsOnCall(i int, result1 []operationjournal.Operation, result2 error) {
+	fake.OperationsStub = nil
+	if fake.operationsReturnsOnCall == nil {
+		fake.operationsReturnsOnCall = make(map[int]struct {
+			result1 []operationjournal.Operation
+			result2 error
+		})
+	}
+	fake.operationsReturnsOnCall[i] = struct {
+		result1 []operationjournal.Operation
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PreviewUpgrade(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error) {
+	fake.previewUpgradeMutex.Lock()
+	ret, specificReturn := fake.previewUpgradeReturnsOnCall[len(fake.previewUpgradeArgsForCall)]
+	fake.previewUpgradeArgsForCall = append(fake.previewUpgradeArgsForCall, struct {
+		ctx           context.Context
+		instanceID    string
+		updateDetails brokerapi.UpdateDetails
+		logger        *log.Logger
+	}{ctx, instanceID, updateDetails, logger})
+	fake.recordInvocation("PreviewUpgrade", []interface{}{ctx, instanceID, updateDetails, logger})
+	fake.previewUpgradeMutex.Unlock()
+	if fake.PreviewUpgradeStub != nil {
+		return fake.PreviewUpgradeStub(ctx, instanceID, updateDetails, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.previewUpgradeReturns.result1, fake.previewUpgradeReturns.result2
+}
+
+func (fake *FakeManageableBroker) PreviewUpgradeCallCount() int {
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
+	return len(fake.previewUpgradeArgsForCall)
+}
+
+func (fake *FakeManageableBroker) PreviewUpgradeArgsForCall(i int) (context.Context, string, brokerapi.UpdateDetails, *log.Logger) {
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
+	return fake.previewUpgradeArgsForCall[i].ctx, fake.previewUpgradeArgsForCall[i].instanceID, fake.previewUpgradeArgsForCall[i].updateDetails, fake.previewUpgradeArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) PreviewUpgradeReturns(result1 broker.DeploymentPreview, result2 error) {
+	fake.PreviewUpgradeStub = nil
+	fake.previewUpgradeReturns = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PreviewUpgradeReturnsOnCall(i int, result1 broker.DeploymentPreview, result2 error) {
+	fake.PreviewUpgradeStub = nil
+	if fake.previewUpgradeReturnsOnCall == nil {
+		fake.previewUpgradeReturnsOnCall = make(map[int]struct {
+			result1 broker.DeploymentPreview
+			result2 error
+		})
+	}
+	fake.previewUpgradeReturnsOnCall[i] = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PreviewUpdate(ctx context.Context, instanceID string, updateDetails brokerapi.UpdateDetails, logger *log.Logger) (broker.DeploymentPreview, error) {
+	fake.previewUpdateMutex.Lock()
+	ret, specificReturn := fake.previewUpdateReturnsOnCall[len(fake.previewUpdateArgsForCall)]
+	fake.previewUpdateArgsForCall = append(fake.previewUpdateArgsForCall, struct {
+		ctx           context.Context
+		instanceID    string
+		updateDetails brokerapi.UpdateDetails
+		logger        *log.Logger
+	}{ctx, instanceID, updateDetails, logger})
+	fake.recordInvocation("PreviewUpdate", []interface{}{ctx, instanceID, updateDetails, logger})
+	fake.previewUpdateMutex.Unlock()
+	if fake.PreviewUpdateStub != nil {
+		return fake.PreviewUpdateStub(ctx, instanceID, updateDetails, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.previewUpdateReturns.result1, fake.previewUpdateReturns.result2
+}
+
+func (fake *FakeManageableBroker) PreviewUpdateCallCount() int {
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	return len(fake.previewUpdateArgsForCall)
+}
+
+func (fake *FakeManageableBroker) PreviewUpdateArgsForCall(i int) (context.Context, string, brokerapi.UpdateDetails, *log.Logger) {
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	return fake.previewUpdateArgsForCall[i].ctx, fake.previewUpdateArgsForCall[i].instanceID, fake.previewUpdateArgsForCall[i].updateDetails, fake.previewUpdateArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) PreviewUpdateReturns(result1 broker.DeploymentPreview, result2 error) {
+	fake.PreviewUpdateStub = nil
+	fake.previewUpdateReturns = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PreviewUpdateReturnsOnCall(i int, result1 broker.DeploymentPreview, result2 error) {
+	fake.PreviewUpdateStub = nil
+	if fake.previewUpdateReturnsOnCall == nil {
+		fake.previewUpdateReturnsOnCall = make(map[int]struct {
+			result1 broker.DeploymentPreview
+			result2 error
+		})
+	}
+	fake.previewUpdateReturnsOnCall[i] = struct {
+		result1 broker.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PendingChanges(ctx context.Context, instanceID string, logger *log.Logger) (broker.PendingChanges, error) {
+	fake.pendingChangesMutex.Lock()
+	ret, specificReturn := fake.pendingChangesReturnsOnCall[len(fake.pendingChangesArgsForCall)]
+	fake.pendingChangesArgsForCall = append(fake.pendingChangesArgsForCall, struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}{ctx, instanceID, logger})
+	fake.recordInvocation("PendingChanges", []interface{}{ctx, instanceID, logger})
+	fake.pendingChangesMutex.Unlock()
+	if fake.PendingChangesStub != nil {
+		return fake.PendingChangesStub(ctx, instanceID, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.pendingChangesReturns.result1, fake.pendingChangesReturns.result2
+}
+
+func (fake *FakeManageableBroker) PendingChangesCallCount() int {
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	return len(fake.pendingChangesArgsForCall)
+}
+
+func (fake *FakeManageableBroker) PendingChangesArgsForCall(i int) (context.Context, string, *log.Logger) {
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	return fake.pendingChangesArgsForCall[i].ctx, fake.pendingChangesArgsForCall[i].instanceID, fake.pendingChangesArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) PendingChangesReturns(result1 broker.PendingChanges, result2 error) {
+	fake.PendingChangesStub = nil
+	fake.pendingChangesReturns = struct {
+		result1 broker.PendingChanges
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) PendingChangesReturnsOnCall(i int, result1 broker.PendingChanges, result2 error) {
+	fake.PendingChangesStub = nil
+	if fake.pendingChangesReturnsOnCall == nil {
+		fake.pendingChangesReturnsOnCall = make(map[int]struct {
+			result1 broker.PendingChanges
+			result2 error
+		})
+	}
+	fake.pendingChangesReturnsOnCall[i] = struct {
+		result1 broker.PendingChanges
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) DeployedReleases(logger *log.Logger) (map[string][]bosh.Release, error) {
+	fake.deployedReleasesMutex.Lock()
+	ret, specificReturn := fake.deployedReleasesReturnsOnCall[len(fake.deployedReleasesArgsForCall)]
+	fake.deployedReleasesArgsForCall = append(fake.deployedReleasesArgsForCall, struct {
+		logger *log.Logger
+	}{logger})
+	fake.recordInvocation("DeployedReleases", []interface{}{logger})
+	fake.deployedReleasesMutex.Unlock()
+	if fake.DeployedReleasesStub != nil {
+		return fake.DeployedReleasesStub(logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.deployedReleasesReturns.result1, fake.deployedReleasesReturns.result2
+}
+
+func (fake *FakeManageableBroker) DeployedReleasesCallCount() int {
+	fake.deployedReleasesMutex.RLock()
+	defer fake.deployedReleasesMutex.RUnlock()
+	return len(fake.deployedReleasesArgsForCall)
+}
+
+func (fake *FakeManageableBroker) DeployedReleasesArgsForCall(i int) *log.Logger {
+	fake.deployedReleasesMutex.RLock()
+	defer fake.deployedReleasesMutex.RUnlock()
+	return fake.deployedReleasesArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) DeployedReleasesReturns(result1 map[string][]bosh.Release, result2 error) {
+	fake.DeployedReleasesStub = nil
+	fake.deployedReleasesReturns = struct {
+		result1 map[string][]bosh.Release
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) DeployedReleasesReturnsOnCall(i int, result1 map[string][]bosh.Release, result2 error) {
+	fake.DeployedReleasesStub = nil
+	if fake.deployedReleasesReturnsOnCall == nil {
+		fake.deployedReleasesReturnsOnCall = make(map[int]struct {
+			result1 map[string][]bosh.Release
+			result2 error
+		})
+	}
+	fake.deployedReleasesReturnsOnCall[i] = struct {
+		result1 map[string][]bosh.Release
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) Backup(ctx context.Context, instanceID string, logger *log.Logger) (broker.OperationData, error) {
+	fake.backupMutex.Lock()
+	ret, specificReturn := fake.backupReturnsOnCall[len(fake.backupArgsForCall)]
+	fake.backupArgsForCall = append(fake.backupArgsForCall, struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}{ctx, instanceID, logger})
+	fake.recordInvocation("Backup", []interface{}{ctx, instanceID, logger})
+	fake.backupMutex.Unlock()
+	if fake.BackupStub != nil {
+		return fake.BackupStub(ctx, instanceID, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.backupReturns.result1, fake.backupReturns.result2
+}
+
+func (fake *FakeManageableBroker) BackupCallCount() int {
+	fake.backupMutex.RLock()
+	defer fake.backupMutex.RUnlock()
+	return len(fake.backupArgsForCall)
+}
+
+func (fake *FakeManageableBroker) BackupArgsForCall(i int) (context.Context, string, *log.Logger) {
+	fake.backupMutex.RLock()
+	defer fake.backupMutex.RUnlock()
+	return fake.backupArgsForCall[i].ctx, fake.backupArgsForCall[i].instanceID, fake.backupArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) BackupReturns(result1 broker.OperationData, result2 error) {
+	fake.BackupStub = nil
+	fake.backupReturns = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) BackupReturnsOnCall(i int, result1 broker.OperationData, result2 error) {
+	fake.BackupStub = nil
+	if fake.backupReturnsOnCall == nil {
+		fake.backupReturnsOnCall = make(map[int]struct {
+			result1 broker.OperationData
+			result2 error
+		})
+	}
+	fake.backupReturnsOnCall[i] = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) Backups(ctx context.Context, instanceID string, logger *log.Logger) ([]broker.Backup, error) {
+	fake.backupsMutex.Lock()
+	ret, specificReturn := fake.backupsReturnsOnCall[len(fake.backupsArgsForCall)]
+	fake.backupsArgsForCall = append(fake.backupsArgsForCall, struct {
+		ctx        context.Context
+		instanceID string
+		logger     *log.Logger
+	}{ctx, instanceID, logger})
+	fake.recordInvocation("Backups", []interface{}{ctx, instanceID, logger})
+	fake.backupsMutex.Unlock()
+	if fake.BackupsStub != nil {
+		return fake.BackupsStub(ctx, instanceID, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.backupsReturns.result1, fake.backupsReturns.result2
+}
+
+func (fake *FakeManageableBroker) BackupsCallCount() int {
+	fake.backupsMutex.RLock()
+	defer fake.backupsMutex.RUnlock()
+	return len(fake.backupsArgsForCall)
+}
+
+func (fake *FakeManageableBroker) BackupsArgsForCall(i int) (context.Context, string, *log.Logger) {
+	fake.backupsMutex.RLock()
+	defer fake.backupsMutex.RUnlock()
+	return fake.backupsArgsForCall[i].ctx, fake.backupsArgsForCall[i].instanceID, fake.backupsArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) BackupsReturns(result1 []broker.Backup, result2 error) {
+	fake.BackupsStub = nil
+	fake.backupsReturns = struct {
+		result1 []broker.Backup
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) BackupsReturnsOnCall(i int, result1 []broker.Backup, result2 error) {
+	fake.BackupsStub = nil
+	if fake.backupsReturnsOnCall == nil {
+		fake.backupsReturnsOnCall = make(map[int]struct {
+			result1 []broker.Backup
+			result2 error
+		})
+	}
+	fake.backupsReturnsOnCall[i] = struct {
+		result1 []broker.Backup
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) Restore(ctx context.Context, instanceID string, backupID string, logger *log.Logger) (broker.OperationData, error) {
+	fake.restoreMutex.Lock()
+	ret, specificReturn := fake.restoreReturnsOnCall[len(fake.restoreArgsForCall)]
+	fake.restoreArgsForCall = append(fake.restoreArgsForCall, struct {
+		ctx        context.Context
+		instanceID string
+		backupID   string
+		logger     *log.Logger
+	}{ctx, instanceID, backupID, logger})
+	fake.recordInvocation("Restore", []interface{}{ctx, instanceID, backupID, logger})
+	fake.restoreMutex.Unlock()
+	if fake.RestoreStub != nil {
+		return fake.RestoreStub(ctx, instanceID, backupID, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.restoreReturns.result1, fake.restoreReturns.result2
+}
+
+func (fake *FakeManageableBroker) RestoreCallCount() int {
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
+	return len(fake.restoreArgsForCall)
+}
+
+func (fake *FakeManageableBroker) RestoreArgsForCall(i int) (context.Context, string, string, *log.Logger) {
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
+	return fake.restoreArgsForCall[i].ctx, fake.restoreArgsForCall[i].instanceID, fake.restoreArgsForCall[i].backupID, fake.restoreArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) RestoreReturns(result1 broker.OperationData, result2 error) {
+	fake.RestoreStub = nil
+	fake.restoreReturns = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) RestoreReturnsOnCall(i int, result1 broker.OperationData, result2 error) {
+	fake.RestoreStub = nil
+	if fake.restoreReturnsOnCall == nil {
+		fake.restoreReturnsOnCall = make(map[int]struct {
+			result1 broker.OperationData
+			result2 error
+		})
+	}
+	fake.restoreReturnsOnCall[i] = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) Reconcile(ctx context.Context, repairs broker.ReconcileRepairs, logger *log.Logger) (broker.DriftReport, error) {
+	fake.reconcileMutex.Lock()
+	ret, specificReturn := fake.reconcileReturnsOnCall[len(fake.reconcileArgsForCall)]
+	fake.reconcileArgsForCall = append(fake.reconcileArgsForCall, struct {
+		ctx     context.Context
+		repairs broker.ReconcileRepairs
+		logger  *log.Logger
+	}{ctx, repairs, logger})
+	fake.recordInvocation("Reconcile", []interface{}{ctx, repairs, logger})
+	fake.reconcileMutex.Unlock()
+	if fake.ReconcileStub != nil {
+		return fake.ReconcileStub(ctx, repairs, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.reconcileReturns.result1, fake.reconcileReturns.result2
+}
+
+func (fake *FakeManageableBroker) ReconcileCallCount() int {
+	fake.reconcileMutex.RLock()
+	defer fake.reconcileMutex.RUnlock()
+	return len(fake.reconcileArgsForCall)
+}
+
+func (fake *FakeManageableBroker) ReconcileArgsForCall(i int) (context.Context, broker.ReconcileRepairs, *log.Logger) {
+	fake.reconcileMutex.RLock()
+	defer fake.reconcileMutex.RUnlock()
+	return fake.reconcileArgsForCall[i].ctx, fake.reconcileArgsForCall[i].repairs, fake.reconcileArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) ReconcileReturns(result1 broker.DriftReport, result2 error) {
+	fake.ReconcileStub = nil
+	fake.reconcileReturns = struct {
+		result1 broker.DriftReport
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) ReconcileReturnsOnCall(i int, result1 broker.DriftReport, result2 error) {
+	fake.ReconcileStub = nil
+	if fake.reconcileReturnsOnCall == nil {
+		fake.reconcileReturnsOnCall = make(map[int]struct {
+			result1 broker.DriftReport
+			result2 error
+		})
+	}
+	fake.reconcileReturnsOnCall[i] = struct {
+		result1 broker.DriftReport
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) DeleteOrphanDeployment(ctx context.Context, deploymentName string, runPreDeleteErrands bool, logger *log.Logger) (broker.OperationData, error) {
+	fake.deleteOrphanDeploymentMutex.Lock()
+	ret, specificReturn := fake.deleteOrphanDeploymentReturnsOnCall[len(fake.deleteOrphanDeploymentArgsForCall)]
+	fake.deleteOrphanDeploymentArgsForCall = append(fake.deleteOrphanDeploymentArgsForCall, struct {
+		ctx                 context.Context
+		deploymentName      string
+		runPreDeleteErrands bool
+		logger              *log.Logger
+	}{ctx, deploymentName, runPreDeleteErrands, logger})
+	fake.recordInvocation("DeleteOrphanDeployment", []interface{}{ctx, deploymentName, runPreDeleteErrands, logger})
+	fake.deleteOrphanDeploymentMutex.Unlock()
+	if fake.DeleteOrphanDeploymentStub != nil {
+		return fake.DeleteOrphanDeploymentStub(ctx, deploymentName, runPreDeleteErrands, logger)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.deleteOrphanDeploymentReturns.result1, fake.deleteOrphanDeploymentReturns.result2
+}
+
+func (fake *FakeManageableBroker) DeleteOrphanDeploymentCallCount() int {
+	fake.deleteOrphanDeploymentMutex.RLock()
+	defer fake.deleteOrphanDeploymentMutex.RUnlock()
+	return len(fake.deleteOrphanDeploymentArgsForCall)
+}
+
+func (fake *FakeManageableBroker) DeleteOrphanDeploymentArgsForCall(i int) (context.Context, string, bool, *log.Logger) {
+	fake.deleteOrphanDeploymentMutex.RLock()
+	defer fake.deleteOrphanDeploymentMutex.RUnlock()
+	return fake.deleteOrphanDeploymentArgsForCall[i].ctx, fake.deleteOrphanDeploymentArgsForCall[i].deploymentName, fake.deleteOrphanDeploymentArgsForCall[i].runPreDeleteErrands, fake.deleteOrphanDeploymentArgsForCall[i].logger
+}
+
+func (fake *FakeManageableBroker) DeleteOrphanDeploymentReturns(result1 broker.OperationData, result2 error) {
+	fake.DeleteOrphanDeploymentStub = nil
+	fake.deleteOrphanDeploymentReturns = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeManageableBroker) DeleteOrphanDeploymentReturnsOnCall(i int, result1 broker.OperationData, result2 error) {
+	fake.DeleteOrphanDeploymentStub = nil
+	if fake.deleteOrphanDeploymentReturnsOnCall == nil {
+		fake.deleteOrphanDeploymentReturnsOnCall = make(map[int]struct {
+			result1 broker.OperationData
+			result2 error
+		})
+	}
+	fake.deleteOrphanDeploymentReturnsOnCall[i] = struct {
+		result1 broker.OperationData
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeManageableBroker) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -433,6 +1117,26 @@ func (fake *FakeManageableBroker) Invocations() map[string][][]interface{} {
 	defer fake.recreateMutex.RUnlock()
 	fake.countInstancesOfPlansMutex.RLock()
 	defer fake.countInstancesOfPlansMutex.RUnlock()
+	fake.operationsMutex.RLock()
+	defer fake.operationsMutex.RUnlock()
+	fake.previewUpgradeMutex.RLock()
+	defer fake.previewUpgradeMutex.RUnlock()
+	fake.previewUpdateMutex.RLock()
+	defer fake.previewUpdateMutex.RUnlock()
+	fake.pendingChangesMutex.RLock()
+	defer fake.pendingChangesMutex.RUnlock()
+	fake.deployedReleasesMutex.RLock()
+	defer fake.deployedReleasesMutex.RUnlock()
+	fake.backupMutex.RLock()
+	defer fake.backupMutex.RUnlock()
+	fake.backupsMutex.RLock()
+	defer fake.backupsMutex.RUnlock()
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
+	fake.reconcileMutex.RLock()
+	defer fake.reconcileMutex.RUnlock()
+	fake.deleteOrphanDeploymentMutex.RLock()
+	defer fake.deleteOrphanDeploymentMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
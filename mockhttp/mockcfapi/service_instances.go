@@ -96,6 +96,15 @@ func ListServiceInstancesForPage(servicePlanGUID string, page int) *listServiceI
 	}
 }
 
+func ListServiceInstancesByOrg(servicePlanGUID, orgGUID string) *listServiceInstancesMock {
+	return &listServiceInstancesMock{
+		mockhttp.NewMockedHttpRequest(
+			"GET",
+			"/v2/service_plans/"+servicePlanGUID+"/service_instances?results-per-page=100&q=organization_guid:"+orgGUID,
+		),
+	}
+}
+
 func ListServiceInstancesBySpaceForPage(servicePlanGUID, spaceGUID string, page int) *listServiceInstancesMock {
 	return &listServiceInstancesMock{
 		mockhttp.NewMockedHttpRequest(
@@ -0,0 +1,175 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package multioffering
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver"
+)
+
+const routeLoggerAction = "route-to-service-offering"
+
+// Broker serves several service offerings, passing each service broker API
+// request on to the broker of the offering it is for. It does not serve the
+// management API, as management requests do not say which offering they are
+// for; they must be sent to /service_offerings/<service ID> instead.
+type Broker struct {
+	offerings []apiserver.ServiceOfferingBroker
+}
+
+func New(offerings []apiserver.ServiceOfferingBroker) *Broker {
+	return &Broker{offerings: offerings}
+}
+
+func (b *Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
+	var services []brokerapi.Service
+	for _, offering := range b.offerings {
+		offeringServices, err := offering.Broker.Services(ctx)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, offeringServices...)
+	}
+	return services, nil
+}
+
+func (b *Broker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.ProvisionedServiceSpec{}, err
+	}
+	return offeringBroker.Provision(ctx, instanceID, details, asyncAllowed)
+}
+
+func (b *Broker) Deprovision(ctx context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.DeprovisionServiceSpec{}, err
+	}
+	return offeringBroker.Deprovision(ctx, instanceID, details, asyncAllowed)
+}
+
+func (b *Broker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (brokerapi.UpdateServiceSpec, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+	return offeringBroker.Update(ctx, instanceID, details, asyncAllowed)
+}
+
+func (b *Broker) LastOperation(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.LastOperation{}, err
+	}
+	return offeringBroker.LastOperation(ctx, instanceID, details)
+}
+
+func (b *Broker) Bind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokerapi.Binding, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.Binding{}, err
+	}
+	return offeringBroker.Bind(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+func (b *Broker) Unbind(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokerapi.UnbindSpec, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.UnbindSpec{}, err
+	}
+	return offeringBroker.Unbind(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+func (b *Broker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	offeringBroker, err := b.brokerFor(details.ServiceID, details.PlanID)
+	if err != nil {
+		return brokerapi.LastOperation{}, err
+	}
+	return offeringBroker.LastBindingOperation(ctx, instanceID, bindingID, details)
+}
+
+// GetInstance asks the broker of each offering in turn, as the request does
+// not say which offering the instance is of.
+func (b *Broker) GetInstance(ctx context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
+	var spec brokerapi.GetInstanceDetailsSpec
+	var err error
+	for _, offering := range b.offerings {
+		spec, err = offering.Broker.GetInstance(ctx, instanceID)
+		if !isNotFound(err) {
+			return spec, err
+		}
+	}
+	return spec, err
+}
+
+// GetBinding asks the broker of each offering in turn, as the request does not
+// say which offering the instance is of.
+func (b *Broker) GetBinding(ctx context.Context, instanceID, bindingID string) (brokerapi.GetBindingSpec, error) {
+	var spec brokerapi.GetBindingSpec
+	var err error
+	for _, offering := range b.offerings {
+		spec, err = offering.Broker.GetBinding(ctx, instanceID, bindingID)
+		if !isNotFound(err) {
+			return spec, err
+		}
+	}
+	return spec, err
+}
+
+// HeldBinding asks the broker of each offering in turn for the binding created
+// by an asynchronous bind, so that the credhubbroker can store it.
+func (b *Broker) HeldBinding(instanceID, bindingID string) (brokerapi.Binding, bool) {
+	for _, offering := range b.offerings {
+		heldBindings, ok := offering.Broker.(heldBindings)
+		if !ok {
+			continue
+		}
+		if binding, found := heldBindings.HeldBinding(instanceID, bindingID); found {
+			return binding, true
+		}
+	}
+	return brokerapi.Binding{}, false
+}
+
+type heldBindings interface {
+	HeldBinding(instanceID, bindingID string) (brokerapi.Binding, bool)
+}
+
+// brokerFor finds the broker of the offering with the service ID or, when the
+// platform has not sent one, of the offering with the plan. Requests for
+// neither cannot be routed, and are rejected.
+func (b *Broker) brokerFor(serviceID, planID string) (apiserver.CombinedBroker, error) {
+	for _, offering := range b.offerings {
+		if serviceID != "" && offering.ServiceOffering.ID == serviceID {
+			return offering.Broker, nil
+		}
+	}
+	for _, offering := range b.offerings {
+		if _, found := offering.ServiceOffering.FindPlanByID(planID); planID != "" && found {
+			return offering.Broker, nil
+		}
+	}
+	return nil, brokerapi.NewFailureResponse(
+		errors.New("the request must include the service_id or plan_id of a service offering of this broker, or be sent to /service_offerings/<service ID>"),
+		http.StatusBadRequest,
+		routeLoggerAction,
+	)
+}
+
+func isNotFound(err error) bool {
+	failure, ok := err.(*brokerapi.FailureResponse)
+	if !ok {
+		return false
+	}
+	statusCode := failure.ValidatedStatusCode(nil)
+	return statusCode == http.StatusNotFound || statusCode == http.StatusGone
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package multioffering_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver"
+	"github.com/pivotal-cf/on-demand-service-broker/apiserver/fakes"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/multioffering"
+)
+
+var _ = Describe("Broker", func() {
+	var (
+		redisBroker *fakes.FakeCombinedBroker
+		kafkaBroker *fakes.FakeCombinedBroker
+		b           *multioffering.Broker
+		ctx         context.Context
+	)
+
+	BeforeEach(func() {
+		redisBroker = new(fakes.FakeCombinedBroker)
+		kafkaBroker = new(fakes.FakeCombinedBroker)
+		ctx = context.Background()
+
+		b = multioffering.New([]apiserver.ServiceOfferingBroker{
+			{
+				ServiceOffering: config.ServiceOffering{ID: "redis-id", Plans: config.Plans{{ID: "redis-plan-id"}}},
+				Broker:          redisBroker,
+			},
+			{
+				ServiceOffering: config.ServiceOffering{ID: "kafka-id", Plans: config.Plans{{ID: "kafka-plan-id"}}},
+				Broker:          kafkaBroker,
+			},
+		})
+	})
+
+	Describe("Services", func() {
+		It("lists the services of every offering", func() {
+			redisBroker.ServicesReturns([]brokerapi.Service{{ID: "redis-id"}}, nil)
+			kafkaBroker.ServicesReturns([]brokerapi.Service{{ID: "kafka-id"}}, nil)
+
+			services, err := b.Services(ctx)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(Equal([]brokerapi.Service{{ID: "redis-id"}, {ID: "kafka-id"}}))
+		})
+
+		It("fails when an offering cannot list its services", func() {
+			kafkaBroker.ServicesReturns(nil, errors.New("no catalog"))
+
+			_, err := b.Services(ctx)
+
+			Expect(err).To(MatchError("no catalog"))
+		})
+	})
+
+	Describe("Provision", func() {
+		It("passes the request to the broker of the offering with the service ID", func() {
+			kafkaBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true}, nil)
+
+			spec, err := b.Provision(ctx, "some-instance", brokerapi.ProvisionDetails{ServiceID: "kafka-id", PlanID: "kafka-plan-id"}, true)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.IsAsync).To(BeTrue())
+			Expect(kafkaBroker.ProvisionCallCount()).To(Equal(1))
+			Expect(redisBroker.ProvisionCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("LastOperation", func() {
+		It("passes the request to the broker of the offering with the plan when there is no service ID", func() {
+			_, err := b.LastOperation(ctx, "some-instance", brokerapi.PollDetails{PlanID: "kafka-plan-id"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kafkaBroker.LastOperationCallCount()).To(Equal(1))
+			Expect(redisBroker.LastOperationCallCount()).To(BeZero())
+		})
+
+		It("rejects the request when neither the service nor the plan is known", func() {
+			_, err := b.LastOperation(ctx, "some-instance", brokerapi.PollDetails{})
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*brokerapi.FailureResponse).ValidatedStatusCode(nil)).To(Equal(http.StatusBadRequest))
+			Expect(redisBroker.LastOperationCallCount()).To(BeZero())
+			Expect(kafkaBroker.LastOperationCallCount()).To(BeZero())
+		})
+
+		It("rejects the request when the service and plan belong to no offering", func() {
+			_, err := b.LastOperation(ctx, "some-instance", brokerapi.PollDetails{ServiceID: "mysql-id", PlanID: "mysql-plan-id"})
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*brokerapi.FailureResponse).ValidatedStatusCode(nil)).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("GetInstance", func() {
+		It("asks the next offering when an offering does not know the instance", func() {
+			redisBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.NewFailureResponse(errors.New("not found"), http.StatusNotFound, "get-instance"))
+			kafkaBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{ServiceID: "kafka-id"}, nil)
+
+			spec, err := b.GetInstance(ctx, "some-instance")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.ServiceID).To(Equal("kafka-id"))
+		})
+
+		It("returns the error of the last offering when no offering knows the instance", func() {
+			redisBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.NewFailureResponse(errors.New("not found"), http.StatusNotFound, "get-instance"))
+			kafkaBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.NewFailureResponse(errors.New("gone"), http.StatusGone, "get-instance"))
+
+			_, err := b.GetInstance(ctx, "some-instance")
+
+			Expect(err).To(MatchError("gone"))
+		})
+
+		It("does not ask the next offering when an offering fails otherwise", func() {
+			redisBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, errors.New("bosh unavailable"))
+
+			_, err := b.GetInstance(ctx, "some-instance")
+
+			Expect(err).To(MatchError("bosh unavailable"))
+			Expect(kafkaBroker.GetInstanceCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("GetBinding", func() {
+		It("asks the next offering when an offering does not know the instance", func() {
+			redisBroker.GetBindingReturns(brokerapi.GetBindingSpec{}, brokerapi.NewFailureResponse(errors.New("not found"), http.StatusNotFound, "get-binding"))
+			kafkaBroker.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: "kafka-creds"}, nil)
+
+			spec, err := b.GetBinding(ctx, "some-instance", "some-binding")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.Credentials).To(Equal("kafka-creds"))
+		})
+	})
+
+	Describe("HeldBinding", func() {
+		It("returns the binding held by the broker of any offering", func() {
+			b = multioffering.New([]apiserver.ServiceOfferingBroker{
+				{ServiceOffering: config.ServiceOffering{ID: "redis-id"}, Broker: redisBroker},
+				{ServiceOffering: config.ServiceOffering{ID: "kafka-id"}, Broker: &holdingBroker{
+					FakeCombinedBroker: kafkaBroker,
+					bindings:           map[string]brokerapi.Binding{"some-binding": {Credentials: "kafka-creds"}},
+				}},
+			})
+
+			binding, found := b.HeldBinding("some-instance", "some-binding")
+
+			Expect(found).To(BeTrue())
+			Expect(binding.Credentials).To(Equal("kafka-creds"))
+		})
+
+		It("reports bindings that no offering holds", func() {
+			_, found := b.HeldBinding("some-instance", "some-binding")
+
+			Expect(found).To(BeFalse())
+		})
+	})
+})
+
+type holdingBroker struct {
+	*fakes.FakeCombinedBroker
+	bindings map[string]brokerapi.Binding
+}
+
+func (b *holdingBroker) HeldBinding(instanceID, bindingID string) (brokerapi.Binding, bool) {
+	binding, found := b.bindings[bindingID]
+	return binding, found
+}
@@ -0,0 +1,455 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package operationjournal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Operation struct {
+	ID                  string    `json:"id"`
+	InstanceID          string    `json:"service_instance_id"`
+	BindingID           string    `json:"binding_id,omitempty"`
+	ServiceID           string    `json:"service_id,omitempty"`
+	Type                string    `json:"operation_type"`
+	PlanID              string    `json:"plan_id,omitempty"`
+	BoshTaskIDs         []int     `json:"bosh_task_ids,omitempty"`
+	Errands             []string  `json:"errands,omitempty"`
+	Requester           string    `json:"requester,omitempty"`
+	RequestID           string    `json:"request_id,omitempty"`
+	State               string    `json:"state"`
+	Description         string    `json:"description,omitempty"`
+	RolledBack          bool      `json:"rolled_back,omitempty"`
+	Artefact            string    `json:"artefact,omitempty"`
+	ParametersRef       string    `json:"parameters_ref,omitempty"`
+	RollbackSnapshotRef string    `json:"rollback_snapshot_ref,omitempty"`
+	OperationData       string    `json:"operation_data,omitempty"`
+	StartedAt           time.Time `json:"started_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Journal is an append-only record of broker operations, stored as one JSON
+// document per line in a file on the broker VM. Each call to Record appends an
+// entry; entries sharing an operation ID are merged, so the progress of an
+// operation can be recorded as it is observed.
+//
+// The merged operations are held in memory, so queries do not read the file.
+// Only the latest maxOperationsPerInstance operations of an instance are kept,
+// and an instance is forgotten deletedInstanceRetention after its deletion
+// succeeds, so that what happened to it can still be looked up for a while.
+// Successful backups are exempt from both, as they record where the backup
+// artefacts, which outlive the instance, are kept.
+// The arbitrary parameters of instances are not recorded, only a reference to
+// where the broker stores them. The successful bind of a binding is kept,
+// however old, until the binding is unbound, so that the bindings of an
+// instance can be read back. Likewise the latest create or update of an
+// instance that has not failed, and any started after it, are kept until a
+// later one succeeds, as they record the plan and parameters the instance is
+// deployed with. Nor are the manifest and configs an upgrade replaces, only a
+// reference to where the broker stores them until the upgrade finishes.
+//
+// The file is compacted to the operations that are kept when the journal is
+// opened and whenever it has grown to more than twice as many entries as
+// there are operations.
+type Journal struct {
+	path string
+	lock sync.Mutex
+
+	operations map[string][]Operation
+	indexByID  map[string]int
+	deletedAt  map[string]time.Time
+	entries    int
+}
+
+// minEntriesBeforeCompaction stops a small journal from being compacted on
+// every few entries.
+const minEntriesBeforeCompaction = 1000
+
+// maxOperationsPerInstance is how many operations the journal keeps for each
+// service instance; older operations are dropped.
+const maxOperationsPerInstance = 100
+
+// deletedInstanceRetention is how long the operations of an instance are kept
+// after its deletion has succeeded.
+const deletedInstanceRetention = 30 * 24 * time.Hour
+
+// The operation types and states the broker records for deployments,
+// deletions, binds, unbinds and backups, which decide how long operations are
+// kept.
+const (
+	createOperationType = "create"
+	updateOperationType = "update"
+	deleteOperationType = "delete"
+	bindOperationType   = "bind"
+	unbindOperationType = "unbind"
+	backupOperationType = "backup"
+	succeededState      = "succeeded"
+	failedState         = "failed"
+)
+
+func New(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening operation journal: %s", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("error opening operation journal: %s", err)
+	}
+
+	j := &Journal{path: path, operations: map[string][]Operation{}, indexByID: map[string]int{}, deletedAt: map[string]time.Time{}}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	j.expire(time.Now().UTC())
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) Record(operation Operation) error {
+	if operation.ID == "" || operation.InstanceID == "" {
+		return fmt.Errorf("operation ID and instance ID are required, got %q and %q", operation.ID, operation.InstanceID)
+	}
+	operation.UpdatedAt = time.Now().UTC()
+
+	entry, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening operation journal: %s", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(entry, '\n')); err != nil {
+		return fmt.Errorf("error writing to operation journal: %s", err)
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	j.add(operation)
+	j.expire(operation.UpdatedAt)
+
+	if j.entries > minEntriesBeforeCompaction && j.entries > 2*len(j.indexByID) {
+		return j.compact()
+	}
+	return nil
+}
+
+// Operations returns the operations recorded for a service instance, oldest
+// first.
+func (j *Journal) Operations(instanceID string) ([]Operation, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	operations := make([]Operation, len(j.operations[instanceID]))
+	copy(operations, j.operations[instanceID])
+	return operations, nil
+}
+
+// AllOperations returns the operations recorded for every service instance,
+// oldest first within each instance.
+func (j *Journal) AllOperations() ([]Operation, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	var operations []Operation
+	for _, instanceOperations := range j.operations {
+		operations = append(operations, instanceOperations...)
+	}
+	return operations, nil
+}
+
+// load reads the journal file into memory. Entries which cannot be parsed,
+// for example a line truncated by a crash, are skipped.
+func (j *Journal) load() error {
+	file, err := os.Open(j.path)
+	if err != nil {
+		return fmt.Errorf("error opening operation journal: %s", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("error reading operation journal: %s", readErr)
+		}
+
+		var entry Operation
+		if len(line) > 0 && json.Unmarshal(line, &entry) == nil && entry.ID != "" && entry.InstanceID != "" {
+			j.add(entry)
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (j *Journal) add(entry Operation) {
+	j.entries++
+
+	key := entry.InstanceID + "/" + entry.ID
+	if index, found := j.indexByID[key]; found {
+		merged := merge(j.operations[entry.InstanceID][index], entry)
+		j.operations[entry.InstanceID][index] = merged
+		j.trackDeletion(merged)
+		if merged.Type == unbindOperationType && merged.State == succeededState {
+			j.forgetBinding(entry.InstanceID, merged.BindingID)
+		}
+		return
+	}
+
+	if entry.StartedAt.IsZero() {
+		entry.StartedAt = entry.UpdatedAt
+	}
+	j.trackDeletion(entry)
+	if entry.Type == unbindOperationType && entry.State == succeededState {
+		j.forgetBinding(entry.InstanceID, entry.BindingID)
+	}
+	j.indexByID[key] = len(j.operations[entry.InstanceID])
+	j.operations[entry.InstanceID] = append(j.operations[entry.InstanceID], entry)
+
+	if len(j.operations[entry.InstanceID]) > maxOperationsPerInstance {
+		j.dropOldest(entry.InstanceID)
+	}
+}
+
+// trackDeletion notes when the deletion of an instance succeeds, from which
+// its operations expire. An instance created again with the same ID no longer
+// expires.
+func (j *Journal) trackDeletion(operation Operation) {
+	switch {
+	case operation.Type == deleteOperationType && operation.State == succeededState:
+		j.deletedAt[operation.InstanceID] = operation.UpdatedAt
+	case operation.Type == createOperationType:
+		delete(j.deletedAt, operation.InstanceID)
+	}
+}
+
+// expire forgets the instances whose deletion succeeded more than
+// deletedInstanceRetention before now, other than their successful backups.
+func (j *Journal) expire(now time.Time) {
+	for instanceID, deletedAt := range j.deletedAt {
+		if now.Sub(deletedAt) > deletedInstanceRetention {
+			j.forget(instanceID)
+		}
+	}
+}
+
+// forget drops every operation of an instance other than its successful
+// backups.
+func (j *Journal) forget(instanceID string) {
+	var kept []Operation
+	for _, operation := range j.operations[instanceID] {
+		delete(j.indexByID, instanceID+"/"+operation.ID)
+		if isSuccessfulBackup(operation) {
+			j.indexByID[instanceID+"/"+operation.ID] = len(kept)
+			kept = append(kept, operation)
+		}
+	}
+
+	if len(kept) > 0 {
+		j.operations[instanceID] = kept
+	} else {
+		delete(j.operations, instanceID)
+	}
+	delete(j.deletedAt, instanceID)
+}
+
+// forgetBinding drops the successful bind of a binding that has been unbound.
+func (j *Journal) forgetBinding(instanceID, bindingID string) {
+	for index, operation := range j.operations[instanceID] {
+		if isLiveBinding(operation) && operation.BindingID == bindingID {
+			j.drop(instanceID, index)
+			return
+		}
+	}
+}
+
+// dropOldest drops the oldest operation of an instance other than the
+// successful binds of its bindings, its current deployments and its
+// successful backups.
+func (j *Journal) dropOldest(instanceID string) {
+	operations := j.operations[instanceID]
+	for index, operation := range operations {
+		if !isLiveBinding(operation) && !isCurrentDeployment(operations, index) && !isSuccessfulBackup(operation) {
+			j.drop(instanceID, index)
+			return
+		}
+	}
+}
+
+func (j *Journal) drop(instanceID string, index int) {
+	operations := j.operations[instanceID]
+	delete(j.indexByID, instanceID+"/"+operations[index].ID)
+
+	operations = append(operations[:index:index], operations[index+1:]...)
+	for index, operation := range operations {
+		j.indexByID[instanceID+"/"+operation.ID] = index
+	}
+	j.operations[instanceID] = operations
+}
+
+func isSuccessfulBackup(operation Operation) bool {
+	return operation.Type == backupOperationType && operation.State == succeededState
+}
+
+func isLiveBinding(operation Operation) bool {
+	return operation.Type == bindOperationType && operation.State == succeededState && operation.BindingID != ""
+}
+
+// isCurrentDeployment reports whether the operation at index is a create or
+// update that has not failed and that no later create or update has succeeded.
+func isCurrentDeployment(operations []Operation, index int) bool {
+	if !isDeployment(operations[index]) || operations[index].State == failedState {
+		return false
+	}
+	for _, operation := range operations[index+1:] {
+		if isDeployment(operation) && operation.State == succeededState {
+			return false
+		}
+	}
+	return true
+}
+
+func isDeployment(operation Operation) bool {
+	return operation.Type == createOperationType || operation.Type == updateOperationType
+}
+
+// compact replaces the journal file with one entry per operation. The new file
+// is written alongside the journal and renamed over it, so a crash leaves
+// either the old or the new file in place.
+func (j *Journal) compact() error {
+	if j.entries == len(j.indexByID) {
+		return nil
+	}
+
+	compactedPath := j.path + ".compacted"
+	file, err := os.OpenFile(compactedPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error compacting operation journal: %s", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, operations := range j.operations {
+		for _, operation := range operations {
+			entry, err := json.Marshal(operation)
+			if err != nil {
+				return fmt.Errorf("error compacting operation journal: %s", err)
+			}
+			if _, err := writer.Write(append(entry, '\n')); err != nil {
+				return fmt.Errorf("error compacting operation journal: %s", err)
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error compacting operation journal: %s", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error compacting operation journal: %s", err)
+	}
+	if err := os.Rename(compactedPath, j.path); err != nil {
+		return fmt.Errorf("error compacting operation journal: %s", err)
+	}
+
+	j.entries = len(j.indexByID)
+	return nil
+}
+
+func merge(operation, entry Operation) Operation {
+	if entry.ServiceID != "" {
+		operation.ServiceID = entry.ServiceID
+	}
+	if entry.BindingID != "" {
+		operation.BindingID = entry.BindingID
+	}
+	if entry.Type != "" {
+		operation.Type = entry.Type
+	}
+	if entry.PlanID != "" {
+		operation.PlanID = entry.PlanID
+	}
+	if entry.Requester != "" {
+		operation.Requester = entry.Requester
+	}
+	if entry.RequestID != "" {
+		operation.RequestID = entry.RequestID
+	}
+	if entry.State != "" {
+		operation.State = entry.State
+	}
+	if entry.Description != "" {
+		operation.Description = entry.Description
+	}
+	if entry.RolledBack {
+		operation.RolledBack = true
+	}
+	if entry.Artefact != "" {
+		operation.Artefact = entry.Artefact
+	}
+	if entry.ParametersRef != "" {
+		operation.ParametersRef = entry.ParametersRef
+	}
+	if entry.OperationData != "" {
+		operation.OperationData = entry.OperationData
+	}
+	if entry.RollbackSnapshotRef != "" {
+		operation.RollbackSnapshotRef = entry.RollbackSnapshotRef
+	}
+	operation.BoshTaskIDs = appendMissingTaskIDs(operation.BoshTaskIDs, entry.BoshTaskIDs)
+	operation.Errands = appendMissingErrands(operation.Errands, entry.Errands)
+	operation.UpdatedAt = entry.UpdatedAt
+	return operation
+}
+
+func appendMissingTaskIDs(existing, additional []int) []int {
+	for _, taskID := range additional {
+		found := false
+		for _, existingID := range existing {
+			if existingID == taskID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, taskID)
+		}
+	}
+	return existing
+}
+
+func appendMissingErrands(existing, additional []string) []string {
+	for _, errand := range additional {
+		found := false
+		for _, existingErrand := range existing {
+			if existingErrand == errand {
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, errand)
+		}
+	}
+	return existing
+}
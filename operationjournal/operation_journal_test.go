@@ -0,0 +1,329 @@
+package operationjournal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/operationjournal"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		dir         string
+		journalPath string
+		journal     *operationjournal.Journal
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "operationjournal")
+		Expect(err).NotTo(HaveOccurred())
+		journalPath = filepath.Join(dir, "operations.log")
+
+		journal, err = operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	journalLines := func() []string {
+		contents, err := ioutil.ReadFile(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Split(strings.TrimSpace(string(contents)), "\n")
+	}
+
+	It("returns no operations for an unknown instance", func() {
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(BeEmpty())
+	})
+
+	It("merges the entries recorded for an operation", func() {
+		Expect(journal.Record(operationjournal.Operation{
+			ID:          "42",
+			InstanceID:  "some-instance",
+			Type:        "create",
+			PlanID:      "some-plan",
+			BoshTaskIDs: []int{42},
+			Requester:   "cloudfoundry",
+			RequestID:   "some-request",
+			State:       "in progress",
+		})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{
+			ID:          "1",
+			InstanceID:  "other-instance",
+			Type:        "delete",
+			BoshTaskIDs: []int{1},
+			State:       "in progress",
+		})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{
+			ID:            "42",
+			InstanceID:    "some-instance",
+			BoshTaskIDs:   []int{42, 43},
+			Errands:       []string{"health-check"},
+			RequestID:     "poll-request",
+			State:         "succeeded",
+			Description:   "Instance provisioning completed",
+			Artefact:      "s3://backups/42.tgz",
+			ParametersRef: "/c/some-service/some-instance/42/parameters",
+		})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{
+			ID:                  "42",
+			InstanceID:          "some-instance",
+			RollbackSnapshotRef: "/c/some-service/some-instance/some-context-id/rollback",
+		})).To(Succeed())
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+
+		operation := operations[0]
+		Expect(operation.ID).To(Equal("42"))
+		Expect(operation.Type).To(Equal("create"))
+		Expect(operation.PlanID).To(Equal("some-plan"))
+		Expect(operation.BoshTaskIDs).To(Equal([]int{42, 43}))
+		Expect(operation.Errands).To(Equal([]string{"health-check"}))
+		Expect(operation.Requester).To(Equal("cloudfoundry"))
+		Expect(operation.RequestID).To(Equal("poll-request"))
+		Expect(operation.State).To(Equal("succeeded"))
+		Expect(operation.Description).To(Equal("Instance provisioning completed"))
+		Expect(operation.Artefact).To(Equal("s3://backups/42.tgz"))
+		Expect(operation.ParametersRef).To(Equal("/c/some-service/some-instance/42/parameters"))
+		Expect(operation.RollbackSnapshotRef).To(Equal("/c/some-service/some-instance/some-context-id/rollback"))
+		Expect(operation.StartedAt).NotTo(BeZero())
+		Expect(operation.UpdatedAt).NotTo(BeTemporally("<", operation.StartedAt))
+	})
+
+	It("returns operations oldest first", func() {
+		for _, id := range []string{"1", "2", "3"} {
+			Expect(journal.Record(operationjournal.Operation{ID: id, InstanceID: "some-instance"})).To(Succeed())
+		}
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(3))
+		Expect(operations[0].ID).To(Equal("1"))
+		Expect(operations[2].ID).To(Equal("3"))
+	})
+
+	It("persists operations across journal instances", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance"})).To(Succeed())
+
+		reopened, err := operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		operations, err := reopened.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+	})
+
+	It("skips entries that cannot be parsed", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance"})).To(Succeed())
+
+		file, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0600)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteString(`{"id":"2","service_instance_id":"some-ins`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		reopened, err := operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		operations, err := reopened.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+	})
+
+	It("compacts the file to one entry per operation when it is opened", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", Type: "create", State: "in progress"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "other-instance", Type: "create"})).To(Succeed())
+		Expect(journalLines()).To(HaveLen(3))
+
+		reopened, err := operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journalLines()).To(HaveLen(2))
+
+		operations, err := reopened.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+		Expect(operations[0].Type).To(Equal("create"))
+		Expect(operations[0].State).To(Equal("succeeded"))
+		Expect(operations[0].StartedAt).To(BeTemporally("<", operations[0].UpdatedAt))
+	})
+
+	It("compacts the file once it has grown to twice the number of operations", func() {
+		for i := 0; i < 1001; i++ {
+			Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", Description: strconv.Itoa(i)})).To(Succeed())
+		}
+
+		Expect(journalLines()).To(HaveLen(1))
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations[0].Description).To(Equal("1000"))
+	})
+
+	It("keeps only the latest 100 operations of an instance", func() {
+		for i := 1; i <= 101; i++ {
+			Expect(journal.Record(operationjournal.Operation{ID: strconv.Itoa(i), InstanceID: "some-instance"})).To(Succeed())
+		}
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "other-instance"})).To(Succeed())
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("2"))
+		Expect(operations[99].ID).To(Equal("101"))
+
+		Expect(journal.Record(operationjournal.Operation{ID: "101", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+		operations, err = journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations[99].State).To(Equal("succeeded"))
+
+		reopened, err := operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journalLines()).To(HaveLen(101))
+		operations, err = reopened.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("2"))
+	})
+
+	It("keeps the operations of a deleted instance until they expire", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", Type: "create", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "some-instance", Type: "delete", State: "in progress"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(2))
+
+		expired := time.Now().UTC().Add(-31 * 24 * time.Hour).Format(time.RFC3339)
+		recent := time.Now().UTC().Add(-29 * 24 * time.Hour).Format(time.RFC3339)
+		Expect(ioutil.WriteFile(journalPath, []byte(strings.Join([]string{
+			`{"id":"1","service_instance_id":"expired-instance","operation_type":"create","state":"succeeded","updated_at":"` + expired + `"}`,
+			`{"id":"2","service_instance_id":"expired-instance","operation_type":"delete","state":"succeeded","updated_at":"` + expired + `"}`,
+			`{"id":"3","service_instance_id":"deleted-instance","operation_type":"delete","state":"succeeded","updated_at":"` + recent + `"}`,
+			`{"id":"4","service_instance_id":"recreated-instance","operation_type":"delete","state":"succeeded","updated_at":"` + expired + `"}`,
+			`{"id":"5","service_instance_id":"recreated-instance","operation_type":"create","state":"succeeded","updated_at":"` + recent + `"}`,
+			`{"id":"6","service_instance_id":"backed-up-instance","operation_type":"backup","state":"succeeded","artefact":"s3://backups/6","updated_at":"` + expired + `"}`,
+			`{"id":"7","service_instance_id":"backed-up-instance","operation_type":"backup","state":"failed","updated_at":"` + expired + `"}`,
+			`{"id":"8","service_instance_id":"backed-up-instance","operation_type":"delete","state":"succeeded","updated_at":"` + expired + `"}`,
+		}, "\n")+"\n"), 0600)).To(Succeed())
+
+		reopened, err := operationjournal.New(journalPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journalLines()).To(HaveLen(4))
+
+		operations, err = reopened.Operations("expired-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(BeEmpty())
+		operations, err = reopened.Operations("backed-up-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+		Expect(operations[0].Artefact).To(Equal("s3://backups/6"))
+		operations, err = reopened.Operations("deleted-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(1))
+		operations, err = reopened.Operations("recreated-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(2))
+	})
+
+	It("keeps the successful binds of an instance's bindings until they are unbound", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "bind-a", InstanceID: "some-instance", BindingID: "a", Type: "bind", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "bind-b", InstanceID: "some-instance", BindingID: "b", Type: "bind", State: "in progress"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "bind-b", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+		for i := 1; i <= 100; i++ {
+			Expect(journal.Record(operationjournal.Operation{ID: strconv.Itoa(i), InstanceID: "some-instance"})).To(Succeed())
+		}
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("bind-a"))
+		Expect(operations[1].ID).To(Equal("bind-b"))
+		Expect(operations[2].ID).To(Equal("3"))
+
+		Expect(journal.Record(operationjournal.Operation{ID: "unbind-a", InstanceID: "some-instance", BindingID: "a", Type: "unbind", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "unbind-b", InstanceID: "some-instance", BindingID: "b", Type: "unbind", State: "in progress"})).To(Succeed())
+
+		operations, err = journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations[0].ID).To(Equal("bind-b"))
+		Expect(operations[len(operations)-1].ID).To(Equal("unbind-b"))
+
+		Expect(journal.Record(operationjournal.Operation{ID: "unbind-b", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+
+		operations, err = journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		for _, operation := range operations {
+			Expect(operation.Type).NotTo(Equal("bind"))
+		}
+	})
+
+	It("keeps the successful backups of an instance however many operations follow them", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", Type: "backup", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "some-instance", Type: "backup", State: "failed"})).To(Succeed())
+		for i := 3; i <= 102; i++ {
+			Expect(journal.Record(operationjournal.Operation{ID: strconv.Itoa(i), InstanceID: "some-instance"})).To(Succeed())
+		}
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("1"))
+		Expect(operations[1].ID).To(Equal("4"))
+	})
+
+	It("keeps the current deployments of an instance until a later one succeeds", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance", Type: "create", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "some-instance", Type: "update", State: "failed"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "3", InstanceID: "some-instance", Type: "update", State: "in progress"})).To(Succeed())
+		for i := 4; i <= 103; i++ {
+			Expect(journal.Record(operationjournal.Operation{ID: strconv.Itoa(i), InstanceID: "some-instance", Type: "upgrade", State: "succeeded"})).To(Succeed())
+		}
+
+		operations, err := journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("1"))
+		Expect(operations[1].ID).To(Equal("3"))
+		Expect(operations[2].ID).To(Equal("6"))
+
+		Expect(journal.Record(operationjournal.Operation{ID: "3", InstanceID: "some-instance", State: "succeeded"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "104", InstanceID: "some-instance", Type: "upgrade", State: "succeeded"})).To(Succeed())
+
+		operations, err = journal.Operations("some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(100))
+		Expect(operations[0].ID).To(Equal("3"))
+	})
+
+	It("returns the operations of every instance", func() {
+		Expect(journal.Record(operationjournal.Operation{ID: "1", InstanceID: "some-instance"})).To(Succeed())
+		Expect(journal.Record(operationjournal.Operation{ID: "2", InstanceID: "other-instance"})).To(Succeed())
+
+		operations, err := journal.AllOperations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operations).To(HaveLen(2))
+	})
+
+	It("requires an operation and instance ID", func() {
+		err := journal.Record(operationjournal.Operation{InstanceID: "some-instance"})
+		Expect(err).To(MatchError(ContainSubstring("operation ID and instance ID are required")))
+	})
+
+	It("fails when the journal file cannot be created", func() {
+		_, err := operationjournal.New(filepath.Join(dir, "missing", "operations.log"))
+		Expect(err).To(MatchError(ContainSubstring("error opening operation journal")))
+	})
+})
@@ -0,0 +1,13 @@
+package operationjournal_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestOperationJournal(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Operation Journal Suite")
+}
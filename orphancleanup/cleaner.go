@@ -0,0 +1,219 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package orphancleanup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+)
+
+//go:generate counterfeiter -o fakes/fake_broker_services.go . BrokerServices
+type BrokerServices interface {
+	OrphanDeployments() ([]mgmtapi.Deployment, error)
+	DeleteOrphanDeployment(deploymentName string, runPreDeleteErrands bool) (services.BOSHOperation, error)
+	LastOperation(instanceGUID string, operationData broker.OperationData) (brokerapi.LastOperation, error)
+}
+
+//go:generate counterfeiter -o fakes/fake_sleeper.go . sleeper
+type sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// Sighting records when an orphan deployment was first seen and in how many
+// consecutive runs it has been seen since.
+type Sighting struct {
+	FirstSeen            time.Time `json:"first_seen"`
+	ConsecutiveSightings int       `json:"consecutive_sightings"`
+}
+
+// Result lists the orphan deployments a run deleted, those still within their
+// grace period or left for a later run, and those that could not be deleted.
+type Result struct {
+	Deleted []string `json:"deleted"`
+	Pending []string `json:"pending"`
+	Failed  []string `json:"failed"`
+}
+
+type Cleaner struct {
+	brokerServices      BrokerServices
+	sleeper             sleeper
+	statePath           string
+	minimumSightings    int
+	minimumAge          time.Duration
+	runPreDeleteErrands bool
+	pollingInterval     time.Duration
+	deletionTimeout     time.Duration
+	runTimeout          time.Duration
+	deploymentPrefix    string
+	logger              *log.Logger
+}
+
+func New(brokerServices BrokerServices, sleeper sleeper, conf config.CleanupOrphanDeploymentsErrandConfig, logger *log.Logger) *Cleaner {
+	return &Cleaner{
+		brokerServices:      brokerServices,
+		sleeper:             sleeper,
+		statePath:           conf.StatePath,
+		minimumSightings:    conf.MinimumSightings,
+		minimumAge:          time.Duration(conf.MinimumAgeInSeconds) * time.Second,
+		runPreDeleteErrands: conf.RunPreDeleteErrands,
+		pollingInterval:     time.Duration(conf.PollingInterval) * time.Second,
+		deletionTimeout:     conf.DeletionTimeout(),
+		runTimeout:          conf.RunTimeout(),
+		deploymentPrefix:    config.ServiceOffering{DeploymentPrefix: conf.DeploymentPrefix}.DeploymentNamePrefix(),
+		logger:              logger,
+	}
+}
+
+// Run deletes the orphan deployments whose grace period has passed, waiting
+// for each deletion to finish, and then records a sighting of each orphan it
+// did not delete. Orphans that are not seen in a run start their grace period
+// again if seen later. Once the run has waited runTimeout for deletions, the
+// remaining orphans are left for a later run.
+func (c *Cleaner) Run(now time.Time) (Result, error) {
+	orphans, err := c.brokerServices.OrphanDeployments()
+	if err != nil {
+		return Result{}, fmt.Errorf("error retrieving orphan deployments: %s", err)
+	}
+
+	previous, err := c.readSightings()
+	if err != nil {
+		return Result{}, err
+	}
+
+	sightings := map[string]Sighting{}
+	for _, orphan := range orphans {
+		sighting, found := previous[orphan.Name]
+		if !found {
+			sighting = Sighting{FirstSeen: now}
+		}
+		sighting.ConsecutiveSightings++
+		sightings[orphan.Name] = sighting
+	}
+
+	result := Result{Deleted: []string{}, Pending: []string{}, Failed: []string{}}
+	var waited time.Duration
+	for _, orphan := range orphans {
+		name := orphan.Name
+		if !c.gracePeriodPassed(sightings[name], now) {
+			c.logger.Printf("orphan deployment %s has been seen in %d consecutive runs since %s, not deleting it yet\n", name, sightings[name].ConsecutiveSightings, sightings[name].FirstSeen.Format(time.RFC3339))
+			result.Pending = append(result.Pending, name)
+			continue
+		}
+
+		if waited >= c.runTimeout {
+			c.logger.Printf("the run did not finish within %s, leaving orphan deployment %s for a later run\n", c.runTimeout, name)
+			result.Pending = append(result.Pending, name)
+			continue
+		}
+
+		deletionWaited, err := c.delete(name, c.runTimeout-waited)
+		waited += deletionWaited
+		if err != nil {
+			c.logger.Printf("error deleting orphan deployment %s: %s\n", name, err)
+			result.Failed = append(result.Failed, name)
+			continue
+		}
+		result.Deleted = append(result.Deleted, name)
+		delete(sightings, name)
+	}
+
+	if err := c.writeSightings(sightings); err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func (c *Cleaner) gracePeriodPassed(sighting Sighting, now time.Time) bool {
+	if c.minimumSightings > 0 && sighting.ConsecutiveSightings >= c.minimumSightings {
+		return true
+	}
+	return c.minimumAge > 0 && now.Sub(sighting.FirstSeen) >= c.minimumAge
+}
+
+// delete deletes an orphan deployment and waits for the deletion to finish,
+// for no longer than the deletion timeout or the time left in the run,
+// returning how long it waited.
+func (c *Cleaner) delete(name string, runTimeLeft time.Duration) (time.Duration, error) {
+	c.logger.Printf("deleting orphan deployment %s\n", name)
+
+	operation, err := c.brokerServices.DeleteOrphanDeployment(name, c.runPreDeleteErrands)
+	if err != nil {
+		return 0, err
+	}
+	switch operation.Type {
+	case services.OperationAccepted:
+	case services.OperationInProgress:
+		return 0, fmt.Errorf("an operation is in progress on deployment %s", name)
+	default:
+		return 0, fmt.Errorf("unexpected response deleting deployment %s: %s", name, operation.Type)
+	}
+
+	instanceID := strings.TrimPrefix(name, c.deploymentPrefix)
+	for waited := time.Duration(0); ; waited += c.pollingInterval {
+		if waited >= c.deletionTimeout {
+			return waited, fmt.Errorf("deletion did not finish within %s", c.deletionTimeout)
+		}
+		if waited >= runTimeLeft {
+			return waited, fmt.Errorf("deletion did not finish before the run timed out after %s", c.runTimeout)
+		}
+
+		lastOperation, err := c.brokerServices.LastOperation(instanceID, operation.Data)
+		if err != nil {
+			return waited, fmt.Errorf("error getting the status of the deletion: %s", err)
+		}
+
+		switch lastOperation.State {
+		case brokerapi.Succeeded:
+			c.logger.Printf("deleted orphan deployment %s\n", name)
+			return waited, nil
+		case brokerapi.Failed:
+			return waited, fmt.Errorf("deletion failed: %s", lastOperation.Description)
+		}
+		c.sleeper.Sleep(c.pollingInterval)
+	}
+}
+
+func (c *Cleaner) readSightings() (map[string]Sighting, error) {
+	sightings := map[string]Sighting{}
+
+	contents, err := ioutil.ReadFile(c.statePath)
+	if os.IsNotExist(err) {
+		return sightings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading orphan deployment sightings: %s", err)
+	}
+
+	if err := json.Unmarshal(contents, &sightings); err != nil {
+		return nil, fmt.Errorf("error parsing orphan deployment sightings: %s", err)
+	}
+	return sightings, nil
+}
+
+func (c *Cleaner) writeSightings(sightings map[string]Sighting) error {
+	contents, err := json.Marshal(sightings)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(c.statePath, contents, 0600); err != nil {
+		return fmt.Errorf("error writing orphan deployment sightings: %s", err)
+	}
+	return nil
+}
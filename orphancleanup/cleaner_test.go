@@ -0,0 +1,283 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package orphancleanup_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/broker/services"
+	"github.com/pivotal-cf/on-demand-service-broker/config"
+	"github.com/pivotal-cf/on-demand-service-broker/mgmtapi"
+	"github.com/pivotal-cf/on-demand-service-broker/orphancleanup"
+	"github.com/pivotal-cf/on-demand-service-broker/orphancleanup/fakes"
+)
+
+var _ = Describe("Cleaner", func() {
+	const orphanName = "service-instance_orphan"
+
+	var (
+		stateDir       string
+		errandConfig   config.CleanupOrphanDeploymentsErrandConfig
+		brokerServices *fakes.FakeBrokerServices
+		sleeper        *fakes.FakeSleeper
+		logBuffer      *gbytes.Buffer
+		now            time.Time
+		operationData  broker.OperationData
+	)
+
+	newCleaner := func() *orphancleanup.Cleaner {
+		return orphancleanup.New(brokerServices, sleeper, errandConfig, log.New(logBuffer, "", 0))
+	}
+
+	BeforeEach(func() {
+		var err error
+		stateDir, err = ioutil.TempDir("", "orphan-cleanup")
+		Expect(err).NotTo(HaveOccurred())
+
+		errandConfig = config.CleanupOrphanDeploymentsErrandConfig{
+			StatePath:        filepath.Join(stateDir, "sightings.json"),
+			MinimumSightings: 2,
+			PollingInterval:  10,
+		}
+		brokerServices = new(fakes.FakeBrokerServices)
+		sleeper = new(fakes.FakeSleeper)
+		logBuffer = gbytes.NewBuffer()
+		now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		operationData = broker.OperationData{BoshTaskID: 42, OperationType: broker.OperationTypeDelete}
+
+		brokerServices.OrphanDeploymentsReturns([]mgmtapi.Deployment{{Name: orphanName}}, nil)
+		brokerServices.DeleteOrphanDeploymentReturns(services.BOSHOperation{Type: services.OperationAccepted, Data: operationData}, nil)
+		brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(stateDir)).To(Succeed())
+	})
+
+	It("does not delete an orphan the first time it is seen", func() {
+		result, err := newCleaner().Run(now)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(orphancleanup.Result{Deleted: []string{}, Pending: []string{orphanName}, Failed: []string{}}))
+		Expect(brokerServices.DeleteOrphanDeploymentCallCount()).To(BeZero())
+		Expect(logBuffer).To(gbytes.Say("orphan deployment service-instance_orphan has been seen in 1 consecutive runs"))
+	})
+
+	It("deletes an orphan once it has been seen in enough consecutive runs and waits for the deletion", func() {
+		brokerServices.LastOperationReturnsOnCall(0, brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+		_, err := newCleaner().Run(now)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := newCleaner().Run(now.Add(time.Minute))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(orphancleanup.Result{Deleted: []string{orphanName}, Pending: []string{}, Failed: []string{}}))
+
+		Expect(brokerServices.DeleteOrphanDeploymentCallCount()).To(Equal(1))
+		name, runPreDeleteErrands := brokerServices.DeleteOrphanDeploymentArgsForCall(0)
+		Expect(name).To(Equal(orphanName))
+		Expect(runPreDeleteErrands).To(BeFalse())
+
+		Expect(brokerServices.LastOperationCallCount()).To(Equal(2))
+		instanceGUID, data := brokerServices.LastOperationArgsForCall(0)
+		Expect(instanceGUID).To(Equal("orphan"))
+		Expect(data).To(Equal(operationData))
+		Expect(sleeper.SleepCallCount()).To(Equal(1))
+		Expect(sleeper.SleepArgsForCall(0)).To(Equal(10 * time.Second))
+	})
+
+	It("runs the pre-delete errands when configured to", func() {
+		errandConfig.MinimumSightings = 1
+		errandConfig.RunPreDeleteErrands = true
+
+		_, err := newCleaner().Run(now)
+
+		Expect(err).NotTo(HaveOccurred())
+		_, runPreDeleteErrands := brokerServices.DeleteOrphanDeploymentArgsForCall(0)
+		Expect(runPreDeleteErrands).To(BeTrue())
+	})
+
+	It("polls the deletion with the instance ID of a deployment with a custom prefix", func() {
+		errandConfig.MinimumSightings = 1
+		errandConfig.DeploymentPrefix = "redis-instance_"
+		brokerServices.OrphanDeploymentsReturns([]mgmtapi.Deployment{{Name: "redis-instance_orphan"}}, nil)
+
+		_, err := newCleaner().Run(now)
+
+		Expect(err).NotTo(HaveOccurred())
+		instanceGUID, _ := brokerServices.LastOperationArgsForCall(0)
+		Expect(instanceGUID).To(Equal("orphan"))
+	})
+
+	It("deletes an orphan once it has been orphaned for long enough", func() {
+		errandConfig.MinimumSightings = 0
+		errandConfig.MinimumAgeInSeconds = 3600
+
+		result, err := newCleaner().Run(now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pending).To(ConsistOf(orphanName))
+
+		result, err = newCleaner().Run(now.Add(59 * time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pending).To(ConsistOf(orphanName))
+
+		result, err = newCleaner().Run(now.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Deleted).To(ConsistOf(orphanName))
+	})
+
+	It("starts the grace period again when a deployment stops being an orphan", func() {
+		_, err := newCleaner().Run(now)
+		Expect(err).NotTo(HaveOccurred())
+
+		brokerServices.OrphanDeploymentsReturns([]mgmtapi.Deployment{}, nil)
+		_, err = newCleaner().Run(now.Add(time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+
+		brokerServices.OrphanDeploymentsReturns([]mgmtapi.Deployment{{Name: orphanName}}, nil)
+		result, err := newCleaner().Run(now.Add(2 * time.Minute))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pending).To(ConsistOf(orphanName))
+		Expect(brokerServices.DeleteOrphanDeploymentCallCount()).To(BeZero())
+	})
+
+	It("records the sightings only once the deletions are done", func() {
+		errandConfig.MinimumSightings = 1
+		brokerServices.DeleteOrphanDeploymentStub = func(string, bool) (services.BOSHOperation, error) {
+			_, err := os.Stat(errandConfig.StatePath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			return services.BOSHOperation{Type: services.OperationAccepted, Data: operationData}, nil
+		}
+
+		result, err := newCleaner().Run(now)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Deleted).To(ConsistOf(orphanName))
+		Expect(errandConfig.StatePath).To(BeAnExistingFile())
+	})
+
+	It("forgets the sightings of deleted orphans", func() {
+		errandConfig.MinimumSightings = 1
+		_, err := newCleaner().Run(now)
+		Expect(err).NotTo(HaveOccurred())
+
+		errandConfig.MinimumSightings = 2
+		result, err := newCleaner().Run(now.Add(time.Minute))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Pending).To(ConsistOf(orphanName))
+		Expect(logBuffer).To(gbytes.Say("orphan deployment service-instance_orphan has been seen in 1 consecutive runs"))
+	})
+
+	It("leaves orphans for a later run once the run has timed out", func() {
+		errandConfig.MinimumSightings = 1
+		errandConfig.RunTimeoutInSeconds = 20
+		brokerServices.OrphanDeploymentsReturns([]mgmtapi.Deployment{{Name: orphanName}, {Name: "service-instance_other"}}, nil)
+		brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+		result, err := newCleaner().Run(now)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(orphancleanup.Result{Deleted: []string{}, Pending: []string{"service-instance_other"}, Failed: []string{orphanName}}))
+		Expect(brokerServices.DeleteOrphanDeploymentCallCount()).To(Equal(1))
+		Expect(logBuffer).To(gbytes.Say("the run did not finish within 20s, leaving orphan deployment service-instance_other for a later run"))
+
+		brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+		result, err = newCleaner().Run(now.Add(time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Deleted).To(ConsistOf(orphanName, "service-instance_other"))
+	})
+
+	Context("when an orphan cannot be deleted", func() {
+		BeforeEach(func() {
+			errandConfig.MinimumSightings = 1
+		})
+
+		It("reports a deployment with an operation in progress as failed", func() {
+			brokerServices.DeleteOrphanDeploymentReturns(services.BOSHOperation{Type: services.OperationInProgress}, nil)
+
+			result, err := newCleaner().Run(now)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Failed).To(ConsistOf(orphanName))
+			Expect(logBuffer).To(gbytes.Say("an operation is in progress on deployment service-instance_orphan"))
+		})
+
+		It("reports a deployment that is no longer orphaned as failed", func() {
+			brokerServices.DeleteOrphanDeploymentReturns(services.BOSHOperation{}, errors.New("unexpected status code: 422"))
+
+			result, err := newCleaner().Run(now)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Failed).To(ConsistOf(orphanName))
+			Expect(brokerServices.LastOperationCallCount()).To(BeZero())
+		})
+
+		It("reports a deletion that fails as failed", func() {
+			brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed, Description: "errand failed"}, nil)
+
+			result, err := newCleaner().Run(now)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Failed).To(ConsistOf(orphanName))
+			Expect(logBuffer).To(gbytes.Say("deletion failed: errand failed"))
+		})
+
+		It("reports a deletion that does not finish in time as failed", func() {
+			errandConfig.DeletionTimeoutInSeconds = 30
+			brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+			result, err := newCleaner().Run(now)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Failed).To(ConsistOf(orphanName))
+			Expect(brokerServices.LastOperationCallCount()).To(Equal(3))
+			Expect(sleeper.SleepCallCount()).To(Equal(3))
+			Expect(logBuffer).To(gbytes.Say("deletion did not finish within 30s"))
+		})
+
+		It("reports a deletion that does not finish before the run times out as failed", func() {
+			errandConfig.RunTimeoutInSeconds = 20
+			brokerServices.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+			result, err := newCleaner().Run(now)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Failed).To(ConsistOf(orphanName))
+			Expect(brokerServices.LastOperationCallCount()).To(Equal(2))
+			Expect(logBuffer).To(gbytes.Say("deletion did not finish before the run timed out after 20s"))
+		})
+	})
+
+	It("fails when the orphan deployments cannot be listed", func() {
+		brokerServices.OrphanDeploymentsReturns(nil, errors.New("broker unavailable"))
+
+		_, err := newCleaner().Run(now)
+
+		Expect(err).To(MatchError("error retrieving orphan deployments: broker unavailable"))
+	})
+
+	It("fails when the sightings cannot be parsed", func() {
+		Expect(ioutil.WriteFile(errandConfig.StatePath, []byte("not json"), 0600)).To(Succeed())
+
+		_, err := newCleaner().Run(now)
+
+		Expect(err).To(MatchError(ContainSubstring("error parsing orphan deployment sightings")))
+		Expect(brokerServices.DeleteOrphanDeploymentCallCount()).To(BeZero())
+	})
+})
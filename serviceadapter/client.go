@@ -7,8 +7,11 @@
 package serviceadapter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
@@ -17,14 +20,55 @@ const SuccessExitCode = 0
 
 //go:generate counterfeiter -o fakes/fake_command_runner.go . CommandRunner
 type CommandRunner interface {
-	Run(arg ...string) ([]byte, []byte, *int, error)
-	RunWithInputParams(inputParams interface{}, arg ...string) ([]byte, []byte, *int, error)
+	Run(ctx context.Context, arg ...string) ([]byte, []byte, *int, error)
+	RunWithInputParams(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error)
 }
 
 type Client struct {
 	ExternalBinPath string
 	CommandRunner   CommandRunner
 	UsingStdin      bool
+
+	// Timeouts limits how long each adapter command, such as
+	// "generate-manifest", may run for. Commands without a timeout can run
+	// until their context is done.
+	Timeouts map[string]time.Duration
+}
+
+type timeoutKey struct{}
+
+// commandContext limits ctx to the command's timeout, which it carries so that
+// the CommandRunner can report it.
+func (c *Client) commandContext(ctx context.Context, command string) (context.Context, context.CancelFunc) {
+	if timeout := c.Timeouts[command]; timeout > 0 {
+		return context.WithTimeout(context.WithValue(ctx, timeoutKey{}, timeout), timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// commandTimeout returns the timeout commandContext limited ctx to, or else
+// how long was left until its deadline when the command started.
+func commandTimeout(ctx context.Context, started time.Time) time.Duration {
+	if timeout, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	deadline, _ := ctx.Deadline()
+	return deadline.Sub(started).Round(time.Millisecond)
+}
+
+// runError describes why an adapter command could not be run, reporting a
+// TimeoutError when the command was stopped for running past its deadline.
+// Command runners that cannot tell report the context's error instead.
+func (c *Client) runError(ctx context.Context, command string, stdout, stderr []byte, err error, logger *log.Logger) error {
+	timeoutErr, isTimeout := err.(TimeoutError)
+	if !isTimeout && ctx.Err() == context.DeadlineExceeded {
+		timeoutErr, isTimeout = NewTimeoutError(c.ExternalBinPath, command, c.Timeouts[command], stdout, stderr), true
+	}
+	if isTimeout {
+		logger.Println(timeoutErr)
+		return timeoutErr
+	}
+	return adapterError(c.ExternalBinPath, stdout, stderr, err)
 }
 
 func SanitiseForJSON(properties sdk.Properties) sdk.Properties {
@@ -97,6 +141,14 @@ type BindingNotFoundError struct {
 	error
 }
 
+// TimeoutError is returned when an adapter command runs past its timeout and
+// is stopped.
+type TimeoutError struct {
+	error
+	Command string
+	Timeout time.Duration
+}
+
 func NewNotImplementedError(msg string) NotImplementedError {
 	return NotImplementedError{errors.New(msg)}
 }
@@ -105,6 +157,14 @@ func NewUnknownFailureError(msg string) UnknownFailureError {
 	return UnknownFailureError{errors.New(msg)}
 }
 
+func NewTimeoutError(adapterPath, command string, timeout time.Duration, stdout, stderr []byte) TimeoutError {
+	return TimeoutError{
+		error:   fmt.Errorf("external service adapter at %s did not complete %s within %s and was stopped. stdout: '%s', stderr: '%s'", adapterPath, command, timeout, string(stdout), string(stderr)),
+		Command: command,
+		Timeout: timeout,
+	}
+}
+
 func invalidJSONError(adapterPath string, stdout, stderr []byte, err error) error {
 	return fmt.Errorf("external service adapter returned invalid JSON at %s: stdout: '%s', stderr: '%s', JSON error: '%s'", adapterPath, string(stdout), string(stderr), err)
 }
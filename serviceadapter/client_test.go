@@ -7,12 +7,19 @@
 package serviceadapter_test
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/types"
 
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter/fakes"
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
@@ -70,4 +77,68 @@ var _ = Describe("Adapter Client", func() {
 			Equal("some other error"),
 		),
 	)
+	Describe("stopping adapter commands", func() {
+		var (
+			cmdRunner *fakes.FakeCommandRunner
+			a         *serviceadapter.Client
+			logger    *log.Logger
+		)
+
+		BeforeEach(func() {
+			cmdRunner = new(fakes.FakeCommandRunner)
+			cmdRunner.RunStub = func(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
+				<-ctx.Done()
+				return nil, nil, nil, ctx.Err()
+			}
+			a = &serviceadapter.Client{
+				ExternalBinPath: "/thing",
+				CommandRunner:   cmdRunner,
+				Timeouts:        map[string]time.Duration{"generate-plan-schemas": 10 * time.Millisecond},
+			}
+			logger = log.New(ioutil.Discard, "", 0)
+		})
+
+		It("returns a TimeoutError when the command runs past its timeout", func() {
+			_, err := a.GeneratePlanSchema(context.Background(), sdk.Plan{}, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(serviceadapter.TimeoutError{}))
+			Expect(err).To(MatchError(ContainSubstring("did not complete generate-plan-schemas within 10ms")))
+			Expect(err.(serviceadapter.TimeoutError).Command).To(Equal("generate-plan-schemas"))
+			Expect(err.(serviceadapter.TimeoutError).Timeout).To(Equal(10 * time.Millisecond))
+		})
+
+		It("passes on a TimeoutError reported by the command runner", func() {
+			cmdRunner.RunStub = func(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
+				<-ctx.Done()
+				return nil, nil, nil, serviceadapter.NewTimeoutError("/thing", "generate-plan-schemas", 10*time.Millisecond, []byte("partial"), nil)
+			}
+
+			_, err := a.GeneratePlanSchema(context.Background(), sdk.Plan{}, logger)
+
+			Expect(err).To(BeAssignableToTypeOf(serviceadapter.TimeoutError{}))
+			Expect(err).To(MatchError(ContainSubstring("stdout: 'partial'")))
+		})
+
+		It("only applies a command's own timeout", func() {
+			cmdRunner.RunReturns([]byte(""), nil, nil, errors.New("adapter not found"))
+			cmdRunner.RunStub = nil
+
+			_, err := a.GenerateDashboardUrl(context.Background(), "some-instance", sdk.Plan{}, nil, logger)
+
+			Expect(err).To(MatchError(ContainSubstring("adapter not found")))
+			ctx, _ := cmdRunner.RunArgsForCall(0)
+			_, hasDeadline := ctx.Deadline()
+			Expect(hasDeadline).To(BeFalse())
+		})
+
+		It("stops the command when the request is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := a.GenerateDashboardUrl(ctx, "some-instance", sdk.Plan{}, nil, logger)
+
+			Expect(err).To(MatchError(ContainSubstring("context canceled")))
+			Expect(err).NotTo(BeAssignableToTypeOf(serviceadapter.TimeoutError{}))
+		})
+	})
 })
@@ -8,28 +8,34 @@ package serviceadapter
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"log"
 	"os/exec"
 	"syscall"
+	"time"
 
 	"encoding/json"
 
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
-func NewCommandRunner() CommandRunner {
-	return commandRunner{}
+func NewCommandRunner(logger *log.Logger) CommandRunner {
+	return commandRunner{logger: logger}
 }
 
 type commandRunner struct {
 	inputParams sdk.InputParams
+	logger      *log.Logger
 }
 
-func (c commandRunner) Run(arg ...string) ([]byte, []byte, *int, error) {
+func (c commandRunner) Run(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
 	cmd := exec.Command(arg[0], arg[1:]...)
-	return c.run(cmd)
+	return c.run(ctx, cmd)
 }
 
-func (c commandRunner) RunWithInputParams(inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
+func (c commandRunner) RunWithInputParams(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
 	cmd := exec.Command(arg[0], arg[1:]...)
 
 	b := bytes.NewBuffer([]byte{})
@@ -40,18 +46,52 @@ func (c commandRunner) RunWithInputParams(inputParams interface{}, arg ...string
 	}
 	cmd.Stdin = b
 
-	return c.run(cmd)
+	return c.run(ctx, cmd)
 }
 
 func intPtr(val int) *int {
 	return &val
 }
 
-func (c commandRunner) run(cmd *exec.Cmd) ([]byte, []byte, *int, error) {
-	var stderr bytes.Buffer
+func subcommand(cmd *exec.Cmd) string {
+	if len(cmd.Args) < 2 {
+		return ""
+	}
+	return cmd.Args[1]
+}
+
+// run runs the adapter in its own process group, so that when ctx is done the
+// adapter and any processes it started can all be killed. It returns a
+// TimeoutError when the adapter ran past the deadline of ctx.
+func (c commandRunner) run(ctx context.Context, cmd *exec.Cmd) ([]byte, []byte, *int, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	stdout, err := cmd.Output()
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			loggerfactory.Errorf(c.logger, "error killing the process group of external service adapter %s %s: %s", cmd.Path, subcommand(cmd), err)
+		}
+		<-done
+		if ctx.Err() == context.DeadlineExceeded {
+			return stdout.Bytes(), stderr.Bytes(), nil, NewTimeoutError(cmd.Path, subcommand(cmd), commandTimeout(ctx, started), stdout.Bytes(), stderr.Bytes())
+		}
+		return stdout.Bytes(), stderr.Bytes(), nil, fmt.Errorf("external service adapter at %s was stopped before it completed %s: %s", cmd.Path, subcommand(cmd), ctx.Err())
+	}
 
 	var exitCode *int
 
@@ -64,5 +104,5 @@ func (c commandRunner) run(cmd *exec.Cmd) ([]byte, []byte, *int, error) {
 		exitCode = intPtr(0)
 	}
 
-	return stdout, stderr.Bytes(), exitCode, err
+	return stdout.Bytes(), stderr.Bytes(), exitCode, err
 }
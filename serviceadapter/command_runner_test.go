@@ -7,8 +7,11 @@
 package serviceadapter_test
 
 import (
+	"context"
 	"io/ioutil"
+	"log"
 	"os"
+	"time"
 
 	"math/rand"
 
@@ -49,9 +52,9 @@ var _ = Describe("CommandRunner", func() {
 
 	Describe("Run", func() {
 		JustBeforeEach(func() {
-			runner := serviceadapter.NewCommandRunner()
+			runner := serviceadapter.NewCommandRunner(log.New(ioutil.Discard, "", 0))
 			var stdoutBytes, stderrBytes []byte
-			stdoutBytes, stderrBytes, actualExitCode, runErr = runner.Run(scriptPath)
+			stdoutBytes, stderrBytes, actualExitCode, runErr = runner.Run(context.Background(), scriptPath)
 			stdout = string(stdoutBytes)
 			stderr = string(stderrBytes)
 		})
@@ -142,9 +145,9 @@ var _ = Describe("CommandRunner", func() {
 		})
 
 		JustBeforeEach(func() {
-			runner := serviceadapter.NewCommandRunner()
+			runner := serviceadapter.NewCommandRunner(log.New(ioutil.Discard, "", 0))
 			var stdoutBytes, stderrBytes []byte
-			stdoutBytes, stderrBytes, actualExitCode, runErr = runner.RunWithInputParams(inputParams, scriptPath)
+			stdoutBytes, stderrBytes, actualExitCode, runErr = runner.RunWithInputParams(context.Background(), inputParams, scriptPath)
 			stdout = string(stdoutBytes)
 			stderr = string(stderrBytes)
 		})
@@ -204,6 +207,42 @@ var _ = Describe("CommandRunner", func() {
 			})
 		})
 	})
+
+	Describe("stopping a command", func() {
+		AfterEach(func() {
+			os.Remove(scriptPath)
+		})
+
+		It("kills the command and the processes it started when the context is done", func() {
+			scriptPath = createScript("(sleep 30; echo late) & wait")
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, _, exitCode, err := serviceadapter.NewCommandRunner(log.New(ioutil.Discard, "", 0)).Run(ctx, scriptPath, "generate-manifest")
+
+			Expect(exitCode).To(BeNil())
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+
+			Expect(err).To(BeAssignableToTypeOf(serviceadapter.TimeoutError{}))
+			timeoutErr := err.(serviceadapter.TimeoutError)
+			Expect(timeoutErr.Command).To(Equal("generate-manifest"))
+			Expect(timeoutErr.Timeout).To(BeNumerically("~", 100*time.Millisecond, 20*time.Millisecond))
+			Expect(err).To(MatchError(ContainSubstring("did not complete generate-manifest within")))
+		})
+
+		It("reports a cancelled command as stopped rather than timed out", func() {
+			scriptPath = createScript("sleep 30")
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			_, _, exitCode, err := serviceadapter.NewCommandRunner(log.New(ioutil.Discard, "", 0)).Run(ctx, scriptPath, "create-binding")
+
+			Expect(exitCode).To(BeNil())
+			Expect(err).NotTo(BeAssignableToTypeOf(serviceadapter.TimeoutError{}))
+			Expect(err).To(MatchError(ContainSubstring("was stopped before it completed create-binding: context canceled")))
+		})
+	})
 })
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
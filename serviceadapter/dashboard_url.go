@@ -7,13 +7,14 @@
 package serviceadapter
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 
 	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
-func (c *Client) GenerateDashboardUrl(instanceID string, plan sdk.Plan, manifest []byte, logger *log.Logger) (string, error) {
+func (c *Client) GenerateDashboardUrl(ctx context.Context, instanceID string, plan sdk.Plan, manifest []byte, logger *log.Logger) (string, error) {
 	plan.Properties = SanitiseForJSON(plan.Properties)
 	planJSON, err := json.Marshal(plan)
 	if err != nil {
@@ -23,6 +24,9 @@ func (c *Client) GenerateDashboardUrl(instanceID string, plan sdk.Plan, manifest
 	var stdout, stderr []byte
 	var exitCode *int
 
+	ctx, cancel := c.commandContext(ctx, "dashboard-url")
+	defer cancel()
+
 	if c.UsingStdin {
 		inputParams := sdk.InputParams{
 			DashboardUrl: sdk.DashboardUrlJSONParams{
@@ -33,12 +37,14 @@ func (c *Client) GenerateDashboardUrl(instanceID string, plan sdk.Plan, manifest
 		}
 
 		stdout, stderr, exitCode, err = c.CommandRunner.RunWithInputParams(
+			ctx,
 			inputParams,
 			c.ExternalBinPath,
 			"dashboard-url",
 		)
 	} else {
 		stdout, stderr, exitCode, err = c.CommandRunner.Run(
+			ctx,
 			c.ExternalBinPath,
 			"dashboard-url",
 			instanceID,
@@ -48,7 +54,7 @@ func (c *Client) GenerateDashboardUrl(instanceID string, plan sdk.Plan, manifest
 	}
 
 	if err != nil {
-		return "", adapterError(c.ExternalBinPath, stdout, stderr, err)
+		return "", c.runError(ctx, "dashboard-url", stdout, stderr, err, logger)
 	}
 
 	if err := ErrorForExitCode(*exitCode, string(stdout)); err != nil {
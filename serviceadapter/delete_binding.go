@@ -7,6 +7,7 @@
 package serviceadapter
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 
@@ -15,6 +16,7 @@ import (
 )
 
 func (c *Client) DeleteBinding(
+	ctx context.Context,
 	bindingID string,
 	deploymentTopology bosh.BoshVMs,
 	manifest []byte,
@@ -47,6 +49,9 @@ func (c *Client) DeleteBinding(
 	var stdout, stderr []byte
 	var exitCode *int
 
+	ctx, cancel := c.commandContext(ctx, "delete-binding")
+	defer cancel()
+
 	if c.UsingStdin {
 		inputParams := sdk.InputParams{
 			DeleteBinding: sdk.DeleteBindingJSONParams{
@@ -58,13 +63,13 @@ func (c *Client) DeleteBinding(
 				DNSAddresses:      string(serialisedDNSAddresses),
 			},
 		}
-		stdout, stderr, exitCode, err = c.CommandRunner.RunWithInputParams(inputParams, c.ExternalBinPath, "delete-binding")
+		stdout, stderr, exitCode, err = c.CommandRunner.RunWithInputParams(ctx, inputParams, c.ExternalBinPath, "delete-binding")
 	} else {
-		stdout, stderr, exitCode, err = c.CommandRunner.Run(c.ExternalBinPath, "delete-binding", bindingID, string(serialisedBoshVMs), string(manifest), string(serialisedRequestParams))
+		stdout, stderr, exitCode, err = c.CommandRunner.Run(ctx, c.ExternalBinPath, "delete-binding", bindingID, string(serialisedBoshVMs), string(manifest), string(serialisedRequestParams))
 	}
 
 	if err != nil {
-		return adapterError(c.ExternalBinPath, stdout, stderr, err)
+		return c.runError(ctx, "delete-binding", stdout, stderr, err, logger)
 	}
 
 	if err := ErrorForExitCode(*exitCode, string(stdout)); err != nil {
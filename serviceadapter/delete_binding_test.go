@@ -7,6 +7,7 @@
 package serviceadapter_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -62,7 +63,7 @@ var _ = Describe("external service adapter", func() {
 	})
 
 	JustBeforeEach(func() {
-		deleteBindingError = a.DeleteBinding(bindingID, deploymentTopology, manifest, requestParams, secrets, dnsAddresses, logger)
+		deleteBindingError = a.DeleteBinding(context.Background(), bindingID, deploymentTopology, manifest, requestParams, secrets, dnsAddresses, logger)
 	})
 
 	When("UsingStdin is set to false", func() {
@@ -74,7 +75,7 @@ var _ = Describe("external service adapter", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(cmdRunner.RunCallCount()).To(Equal(1))
-			argsPassed := cmdRunner.RunArgsForCall(0)
+			_, argsPassed := cmdRunner.RunArgsForCall(0)
 			Expect(argsPassed).To(ConsistOf(externalBinPath, "delete-binding", bindingID, string(serialisedBoshVMs), string(manifest), string(serialisedRequestParams)))
 		})
 
@@ -173,7 +174,7 @@ var _ = Describe("external service adapter", func() {
 
 			Expect(cmdRunner.RunCallCount()).To(Equal(0))
 			Expect(cmdRunner.RunWithInputParamsCallCount()).To(Equal(1))
-			actualInputParams, argsPassed := cmdRunner.RunWithInputParamsArgsForCall(0)
+			_, actualInputParams, argsPassed := cmdRunner.RunWithInputParamsArgsForCall(0)
 			Expect(argsPassed).To(ConsistOf(
 				externalBinPath,
 				"delete-binding",
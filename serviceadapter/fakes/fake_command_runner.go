@@ -2,15 +2,17 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
 	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
 )
 
 type FakeCommandRunner struct {
-	RunStub        func(arg ...string) ([]byte, []byte, *int, error)
+	RunStub        func(ctx context.Context, arg ...string) ([]byte, []byte, *int, error)
 	runMutex       sync.RWMutex
 	runArgsForCall []struct {
+		ctx context.Context
 		arg []string
 	}
 	runReturns struct {
@@ -25,9 +27,10 @@ type FakeCommandRunner struct {
 		result3 *int
 		result4 error
 	}
-	RunWithInputParamsStub        func(inputParams interface{}, arg ...string) ([]byte, []byte, *int, error)
+	RunWithInputParamsStub        func(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error)
 	runWithInputParamsMutex       sync.RWMutex
 	runWithInputParamsArgsForCall []struct {
+		ctx         context.Context
 		inputParams interface{}
 		arg         []string
 	}
@@ -47,16 +50,17 @@ type FakeCommandRunner struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeCommandRunner) Run(arg ...string) ([]byte, []byte, *int, error) {
+func (fake *FakeCommandRunner) Run(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
 	fake.runMutex.Lock()
 	ret, specificReturn := fake.runReturnsOnCall[len(fake.runArgsForCall)]
 	fake.runArgsForCall = append(fake.runArgsForCall, struct {
+		ctx context.Context
 		arg []string
-	}{arg})
-	fake.recordInvocation("Run", []interface{}{arg})
+	}{ctx, arg})
+	fake.recordInvocation("Run", []interface{}{ctx, arg})
 	fake.runMutex.Unlock()
 	if fake.RunStub != nil {
-		return fake.RunStub(arg...)
+		return fake.RunStub(ctx, arg...)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3, ret.result4
@@ -70,10 +74,10 @@ func (fake *FakeCommandRunner) RunCallCount() int {
 	return len(fake.runArgsForCall)
 }
 
-func (fake *FakeCommandRunner) RunArgsForCall(i int) []string {
+func (fake *FakeCommandRunner) RunArgsForCall(i int) (context.Context, []string) {
 	fake.runMutex.RLock()
 	defer fake.runMutex.RUnlock()
-	return fake.runArgsForCall[i].arg
+	return fake.runArgsForCall[i].ctx, fake.runArgsForCall[i].arg
 }
 
 func (fake *FakeCommandRunner) RunReturns(result1 []byte, result2 []byte, result3 *int, result4 error) {
@@ -104,17 +108,18 @@ func (fake *FakeCommandRunner) RunReturnsOnCall(i int, result1 []byte, result2 [
 	}{result1, result2, result3, result4}
 }
 
-func (fake *FakeCommandRunner) RunWithInputParams(inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
+func (fake *FakeCommandRunner) RunWithInputParams(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
 	fake.runWithInputParamsMutex.Lock()
 	ret, specificReturn := fake.runWithInputParamsReturnsOnCall[len(fake.runWithInputParamsArgsForCall)]
 	fake.runWithInputParamsArgsForCall = append(fake.runWithInputParamsArgsForCall, struct {
+		ctx         context.Context
 		inputParams interface{}
 		arg         []string
-	}{inputParams, arg})
-	fake.recordInvocation("RunWithInputParams", []interface{}{inputParams, arg})
+	}{ctx, inputParams, arg})
+	fake.recordInvocation("RunWithInputParams", []interface{}{ctx, inputParams, arg})
 	fake.runWithInputParamsMutex.Unlock()
 	if fake.RunWithInputParamsStub != nil {
-		return fake.RunWithInputParamsStub(inputParams, arg...)
+		return fake.RunWithInputParamsStub(ctx, inputParams, arg...)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3, ret.result4
@@ -128,10 +133,10 @@ func (fake *FakeCommandRunner) RunWithInputParamsCallCount() int {
 	return len(fake.runWithInputParamsArgsForCall)
 }
 
-func (fake *FakeCommandRunner) RunWithInputParamsArgsForCall(i int) (interface{}, []string) {
+func (fake *FakeCommandRunner) RunWithInputParamsArgsForCall(i int) (context.Context, interface{}, []string) {
 	fake.runWithInputParamsMutex.RLock()
 	defer fake.runWithInputParamsMutex.RUnlock()
-	return fake.runWithInputParamsArgsForCall[i].inputParams, fake.runWithInputParamsArgsForCall[i].arg
+	return fake.runWithInputParamsArgsForCall[i].ctx, fake.runWithInputParamsArgsForCall[i].inputParams, fake.runWithInputParamsArgsForCall[i].arg
 }
 
 func (fake *FakeCommandRunner) RunWithInputParamsReturns(result1 []byte, result2 []byte, result3 *int, result4 error) {
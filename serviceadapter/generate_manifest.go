@@ -7,6 +7,7 @@
 package serviceadapter
 
 import (
+	"context"
 	"log"
 	"strings"
 
@@ -31,6 +32,7 @@ type manifestValidator struct {
 }
 
 func (c *Client) GenerateManifest(
+	ctx context.Context,
 	serviceDeployment sdk.ServiceDeployment,
 	plan sdk.Plan,
 	requestParams map[string]interface{},
@@ -79,6 +81,9 @@ func (c *Client) GenerateManifest(
 	var exitCode *int
 	var jsonErr error
 
+	ctx, cancel := c.commandContext(ctx, "generate-manifest")
+	defer cancel()
+
 	if c.UsingStdin {
 		inputParams := sdk.InputParams{
 			GenerateManifest: sdk.GenerateManifestJSONParams{
@@ -92,11 +97,13 @@ func (c *Client) GenerateManifest(
 			},
 		}
 		stdout, stderr, exitCode, err = c.CommandRunner.RunWithInputParams(
+			ctx,
 			inputParams,
 			c.ExternalBinPath, "generate-manifest",
 		)
 	} else {
 		stdout, stderr, exitCode, err = c.CommandRunner.Run(
+			ctx,
 			c.ExternalBinPath, "generate-manifest",
 			string(serialisedServiceDeployment),
 			string(serialisedPlan), string(serialisedRequestParams),
@@ -104,7 +111,7 @@ func (c *Client) GenerateManifest(
 		)
 	}
 	if err != nil {
-		return sdk.MarshalledGenerateManifest{}, adapterError(c.ExternalBinPath, stdout, stderr, err)
+		return sdk.MarshalledGenerateManifest{}, c.runError(ctx, "generate-manifest", stdout, stderr, err, logger)
 	}
 
 	if err := ErrorForExitCode(*exitCode, string(stdout)); err != nil {
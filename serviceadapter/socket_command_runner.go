@@ -0,0 +1,119 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package serviceadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// socketCommandURL is the URL adapter commands are posted to. The host is
+// ignored, as every connection is made to the adapter's socket.
+const socketCommandURL = "http://service-adapter/command"
+
+// SocketCommandRequest is the body of the request the broker sends to a
+// long-running service adapter for each command. InputParams is only set when
+// the broker passes parameters on standard input, otherwise Arguments holds
+// the arguments the adapter would have been executed with.
+type SocketCommandRequest struct {
+	Command     string           `json:"command"`
+	Arguments   []string         `json:"arguments,omitempty"`
+	InputParams *json.RawMessage `json:"input_params,omitempty"`
+}
+
+// SocketCommandResponse is what a long-running service adapter responds with
+// once it has run a command. It has the same meaning as the output and exit
+// code of an executed adapter.
+type SocketCommandResponse struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// NewSocketCommandRunner returns a CommandRunner that sends commands over HTTP
+// to a long-running service adapter listening on the Unix socket at
+// socketPath, instead of executing the adapter for each command. The path of
+// the adapter, the first argument of each command, is ignored.
+func NewSocketCommandRunner(socketPath string) CommandRunner {
+	return socketCommandRunner{
+		socketPath: socketPath,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+type socketCommandRunner struct {
+	socketPath string
+	client     *http.Client
+}
+
+func (s socketCommandRunner) Run(ctx context.Context, arg ...string) ([]byte, []byte, *int, error) {
+	if len(arg) < 2 {
+		return nil, nil, nil, errors.New("no service adapter command given")
+	}
+	return s.send(ctx, SocketCommandRequest{Command: arg[1], Arguments: arg[2:]})
+}
+
+func (s socketCommandRunner) RunWithInputParams(ctx context.Context, inputParams interface{}, arg ...string) ([]byte, []byte, *int, error) {
+	if len(arg) < 2 {
+		return nil, nil, nil, errors.New("no service adapter command given")
+	}
+
+	serialisedInputParams, err := json.Marshal(inputParams)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rawInputParams := json.RawMessage(serialisedInputParams)
+
+	return s.send(ctx, SocketCommandRequest{Command: arg[1], Arguments: arg[2:], InputParams: &rawInputParams})
+}
+
+func (s socketCommandRunner) send(ctx context.Context, request SocketCommandRequest) ([]byte, []byte, *int, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, socketCommandURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error sending %s to the service adapter at %s: %s", request.Command, s.socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading the response of the service adapter at %s: %s", s.socketPath, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, fmt.Errorf("the service adapter at %s responded to %s with HTTP status %d: %s", s.socketPath, request.Command, resp.StatusCode, string(respBody))
+	}
+
+	var response SocketCommandResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, nil, nil, fmt.Errorf("the service adapter at %s responded to %s with invalid JSON: %s", s.socketPath, request.Command, err)
+	}
+
+	return []byte(response.Stdout), []byte(response.Stderr), intPtr(response.ExitCode), nil
+}
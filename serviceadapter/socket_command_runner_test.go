@@ -0,0 +1,159 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package serviceadapter_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/on-demand-service-broker/serviceadapter"
+	sdk "github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("SocketCommandRunner", func() {
+	var (
+		socketDir  string
+		socketPath string
+		listener   net.Listener
+		requests   []serviceadapter.SocketCommandRequest
+		statusCode int
+		response   string
+		runner     serviceadapter.CommandRunner
+	)
+
+	BeforeEach(func() {
+		var err error
+		socketDir, err = ioutil.TempDir("", "adapter-socket")
+		Expect(err).NotTo(HaveOccurred())
+		socketPath = filepath.Join(socketDir, "adapter.sock")
+
+		listener, err = net.Listen("unix", socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		requests = nil
+		statusCode = http.StatusOK
+		response = `{"stdout": "output", "stderr": "error", "exit_code": 0}`
+
+		go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.URL.Path).To(Equal("/command"))
+
+			var request serviceadapter.SocketCommandRequest
+			Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+			requests = append(requests, request)
+
+			w.WriteHeader(statusCode)
+			w.Write([]byte(response))
+		}))
+
+		runner = serviceadapter.NewSocketCommandRunner(socketPath)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		Expect(os.RemoveAll(socketDir)).To(Succeed())
+	})
+
+	It("sends the command and its arguments to the adapter", func() {
+		stdout, stderr, exitCode, err := runner.Run(context.Background(), "/path/to/adapter", "generate-manifest", "arg1", "arg2")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(stdout)).To(Equal("output"))
+		Expect(string(stderr)).To(Equal("error"))
+		Expect(*exitCode).To(Equal(0))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Command).To(Equal("generate-manifest"))
+		Expect(requests[0].Arguments).To(Equal([]string{"arg1", "arg2"}))
+		Expect(requests[0].InputParams).To(BeNil())
+	})
+
+	It("sends the input params to the adapter", func() {
+		inputParams := sdk.InputParams{DashboardUrl: sdk.DashboardUrlJSONParams{InstanceId: "some-instance"}}
+
+		_, _, _, err := runner.RunWithInputParams(context.Background(), inputParams, "/path/to/adapter", "dashboard-url")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Command).To(Equal("dashboard-url"))
+		Expect(requests[0].Arguments).To(BeEmpty())
+
+		var sentInputParams sdk.InputParams
+		Expect(json.Unmarshal(*requests[0].InputParams, &sentInputParams)).To(Succeed())
+		Expect(sentInputParams).To(Equal(inputParams))
+	})
+
+	It("returns the exit code the adapter responds with", func() {
+		response = `{"stdout": "", "stderr": "", "exit_code": 10}`
+
+		_, _, exitCode, err := runner.Run(context.Background(), "/path/to/adapter", "create-binding")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*exitCode).To(Equal(sdk.NotImplementedExitCode))
+		Expect(serviceadapter.ErrorForExitCode(*exitCode, "")).To(BeAssignableToTypeOf(serviceadapter.NotImplementedError{}))
+	})
+
+	It("returns an error when the adapter does not respond with HTTP 200", func() {
+		statusCode = http.StatusInternalServerError
+		response = "adapter crashed"
+
+		_, _, exitCode, err := runner.Run(context.Background(), "/path/to/adapter", "delete-binding")
+
+		Expect(err).To(MatchError(ContainSubstring("responded to delete-binding with HTTP status 500: adapter crashed")))
+		Expect(exitCode).To(BeNil())
+	})
+
+	It("returns an error when the adapter responds with invalid JSON", func() {
+		response = "not json"
+
+		_, _, _, err := runner.Run(context.Background(), "/path/to/adapter", "delete-binding")
+
+		Expect(err).To(MatchError(ContainSubstring("responded to delete-binding with invalid JSON")))
+	})
+
+	It("returns an error when the adapter is not listening", func() {
+		listener.Close()
+
+		_, _, exitCode, err := runner.Run(context.Background(), "/path/to/adapter", "generate-plan-schemas")
+
+		Expect(err).To(MatchError(ContainSubstring("error sending generate-plan-schemas to the service adapter at " + socketPath)))
+		Expect(exitCode).To(BeNil())
+	})
+
+	It("stops waiting for the adapter when the context is done", func() {
+		blockingListener, err := net.Listen("unix", socketPath+".blocking")
+		Expect(err).NotTo(HaveOccurred())
+		defer blockingListener.Close()
+		unblock := make(chan struct{})
+		defer close(unblock)
+		go http.Serve(blockingListener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, _, exitCode, err := serviceadapter.NewSocketCommandRunner(socketPath+".blocking").Run(ctx, "/path/to/adapter", "create-binding")
+
+		Expect(err).To(MatchError(ContainSubstring("context deadline exceeded")))
+		Expect(exitCode).To(BeNil())
+	})
+
+	It("returns an error when no command is given", func() {
+		_, _, _, err := runner.Run(context.Background(), "/path/to/adapter")
+
+		Expect(err).To(MatchError("no service adapter command given"))
+	})
+})
@@ -10,6 +10,21 @@ import (
 )
 
 type FakeBoshClient struct {
+	DeleteConfigStub        func(string, string, *log.Logger) (bool, error)
+	deleteConfigMutex       sync.RWMutex
+	deleteConfigArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 *log.Logger
+	}
+	deleteConfigReturns struct {
+		result1 bool
+		result2 error
+	}
+	deleteConfigReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	DeployStub        func([]byte, string, *log.Logger, *boshdirector.AsyncTaskReporter) (int, error)
 	deployMutex       sync.RWMutex
 	deployArgsForCall []struct {
@@ -86,6 +101,24 @@ type FakeBoshClient struct {
 		result1 int
 		result2 error
 	}
+	RunErrandStub        func(string, string, []string, string, *log.Logger, *boshdirector.AsyncTaskReporter) (int, error)
+	runErrandMutex       sync.RWMutex
+	runErrandArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 []string
+		arg4 string
+		arg5 *log.Logger
+		arg6 *boshdirector.AsyncTaskReporter
+	}
+	runErrandReturns struct {
+		result1 int
+		result2 error
+	}
+	runErrandReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
 	UpdateConfigStub        func(string, string, []byte, *log.Logger) error
 	updateConfigMutex       sync.RWMutex
 	updateConfigArgsForCall []struct {
@@ -104,6 +137,71 @@ type FakeBoshClient struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeBoshClient) DeleteConfig(arg1 string, arg2 string, arg3 *log.Logger) (bool, error) {
+	fake.deleteConfigMutex.Lock()
+	ret, specificReturn := fake.deleteConfigReturnsOnCall[len(fake.deleteConfigArgsForCall)]
+	fake.deleteConfigArgsForCall = append(fake.deleteConfigArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 *log.Logger
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("DeleteConfig", []interface{}{arg1, arg2, arg3})
+	fake.deleteConfigMutex.Unlock()
+	if fake.DeleteConfigStub != nil {
+		return fake.DeleteConfigStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.deleteConfigReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBoshClient) DeleteConfigCallCount() int {
+	fake.deleteConfigMutex.RLock()
+	defer fake.deleteConfigMutex.RUnlock()
+	return len(fake.deleteConfigArgsForCall)
+}
+
+func (fake *FakeBoshClient) DeleteConfigCalls(stub func(string, string, *log.Logger) (bool, error)) {
+	fake.deleteConfigMutex.Lock()
+	defer fake.deleteConfigMutex.Unlock()
+	fake.DeleteConfigStub = stub
+}
+
+func (fake *FakeBoshClient) DeleteConfigArgsForCall(i int) (string, string, *log.Logger) {
+	fake.deleteConfigMutex.RLock()
+	defer fake.deleteConfigMutex.RUnlock()
+	argsForCall := fake.deleteConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeBoshClient) DeleteConfigReturns(result1 bool, result2 error) {
+	fake.deleteConfigMutex.Lock()
+	defer fake.deleteConfigMutex.Unlock()
+	fake.DeleteConfigStub = nil
+	fake.deleteConfigReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBoshClient) DeleteConfigReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.deleteConfigMutex.Lock()
+	defer fake.deleteConfigMutex.Unlock()
+	fake.DeleteConfigStub = nil
+	if fake.deleteConfigReturnsOnCall == nil {
+		fake.deleteConfigReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.deleteConfigReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBoshClient) Deploy(arg1 []byte, arg2 string, arg3 *log.Logger, arg4 *boshdirector.AsyncTaskReporter) (int, error) {
 	var arg1Copy []byte
 	if arg1 != nil {
@@ -436,6 +534,79 @@ func (fake *FakeBoshClient) RecreateReturnsOnCall(i int, result1 int, result2 er
 	}{result1, result2}
 }
 
+func (fake *FakeBoshClient) RunErrand(arg1 string, arg2 string, arg3 []string, arg4 string, arg5 *log.Logger, arg6 *boshdirector.AsyncTaskReporter) (int, error) {
+	var arg3Copy []string
+	if arg3 != nil {
+		arg3Copy = make([]string, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.runErrandMutex.Lock()
+	ret, specificReturn := fake.runErrandReturnsOnCall[len(fake.runErrandArgsForCall)]
+	fake.runErrandArgsForCall = append(fake.runErrandArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 []string
+		arg4 string
+		arg5 *log.Logger
+		arg6 *boshdirector.AsyncTaskReporter
+	}{arg1, arg2, arg3Copy, arg4, arg5, arg6})
+	fake.recordInvocation("RunErrand", []interface{}{arg1, arg2, arg3Copy, arg4, arg5, arg6})
+	fake.runErrandMutex.Unlock()
+	if fake.RunErrandStub != nil {
+		return fake.RunErrandStub(arg1, arg2, arg3, arg4, arg5, arg6)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.runErrandReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBoshClient) RunErrandCallCount() int {
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
+	return len(fake.runErrandArgsForCall)
+}
+
+func (fake *FakeBoshClient) RunErrandCalls(stub func(string, string, []string, string, *log.Logger, *boshdirector.AsyncTaskReporter) (int, error)) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = stub
+}
+
+func (fake *FakeBoshClient) RunErrandArgsForCall(i int) (string, string, []string, string, *log.Logger, *boshdirector.AsyncTaskReporter) {
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
+	argsForCall := fake.runErrandArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
+}
+
+func (fake *FakeBoshClient) RunErrandReturns(result1 int, result2 error) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = nil
+	fake.runErrandReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBoshClient) RunErrandReturnsOnCall(i int, result1 int, result2 error) {
+	fake.runErrandMutex.Lock()
+	defer fake.runErrandMutex.Unlock()
+	fake.RunErrandStub = nil
+	if fake.runErrandReturnsOnCall == nil {
+		fake.runErrandReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.runErrandReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBoshClient) UpdateConfig(arg1 string, arg2 string, arg3 []byte, arg4 *log.Logger) error {
 	var arg3Copy []byte
 	if arg3 != nil {
@@ -507,6 +678,8 @@ func (fake *FakeBoshClient) UpdateConfigReturnsOnCall(i int, result1 error) {
 func (fake *FakeBoshClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.deleteConfigMutex.RLock()
+	defer fake.deleteConfigMutex.RUnlock()
 	fake.deployMutex.RLock()
 	defer fake.deployMutex.RUnlock()
 	fake.getConfigsMutex.RLock()
@@ -517,6 +690,8 @@ func (fake *FakeBoshClient) Invocations() map[string][][]interface{} {
 	defer fake.getTasksMutex.RUnlock()
 	fake.recreateMutex.RLock()
 	defer fake.recreateMutex.RUnlock()
+	fake.runErrandMutex.RLock()
+	defer fake.runErrandMutex.RUnlock()
 	fake.updateConfigMutex.RLock()
 	defer fake.updateConfigMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	context "context"
 	log "log"
 	sync "sync"
 
@@ -10,17 +11,18 @@ import (
 )
 
 type FakeManifestGenerator struct {
-	GenerateManifestStub        func(string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)
+	GenerateManifestStub        func(context.Context, string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)
 	generateManifestMutex       sync.RWMutex
 	generateManifestArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 []byte
-		arg5 *string
-		arg6 map[string]string
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 []byte
+		arg6 *string
 		arg7 map[string]string
-		arg8 *log.Logger
+		arg8 map[string]string
+		arg9 *log.Logger
 	}
 	generateManifestReturns struct {
 		result1 serviceadapter.MarshalledGenerateManifest
@@ -34,28 +36,29 @@ type FakeManifestGenerator struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeManifestGenerator) GenerateManifest(arg1 string, arg2 string, arg3 map[string]interface{}, arg4 []byte, arg5 *string, arg6 map[string]string, arg7 map[string]string, arg8 *log.Logger) (serviceadapter.MarshalledGenerateManifest, error) {
-	var arg4Copy []byte
-	if arg4 != nil {
-		arg4Copy = make([]byte, len(arg4))
-		copy(arg4Copy, arg4)
+func (fake *FakeManifestGenerator) GenerateManifest(arg1 context.Context, arg2 string, arg3 string, arg4 map[string]interface{}, arg5 []byte, arg6 *string, arg7 map[string]string, arg8 map[string]string, arg9 *log.Logger) (serviceadapter.MarshalledGenerateManifest, error) {
+	var arg5Copy []byte
+	if arg5 != nil {
+		arg5Copy = make([]byte, len(arg5))
+		copy(arg5Copy, arg5)
 	}
 	fake.generateManifestMutex.Lock()
 	ret, specificReturn := fake.generateManifestReturnsOnCall[len(fake.generateManifestArgsForCall)]
 	fake.generateManifestArgsForCall = append(fake.generateManifestArgsForCall, struct {
-		arg1 string
+		arg1 context.Context
 		arg2 string
-		arg3 map[string]interface{}
-		arg4 []byte
-		arg5 *string
-		arg6 map[string]string
+		arg3 string
+		arg4 map[string]interface{}
+		arg5 []byte
+		arg6 *string
 		arg7 map[string]string
-		arg8 *log.Logger
-	}{arg1, arg2, arg3, arg4Copy, arg5, arg6, arg7, arg8})
-	fake.recordInvocation("GenerateManifest", []interface{}{arg1, arg2, arg3, arg4Copy, arg5, arg6, arg7, arg8})
+		arg8 map[string]string
+		arg9 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5Copy, arg6, arg7, arg8, arg9})
+	fake.recordInvocation("GenerateManifest", []interface{}{arg1, arg2, arg3, arg4, arg5Copy, arg6, arg7, arg8, arg9})
 	fake.generateManifestMutex.Unlock()
 	if fake.GenerateManifestStub != nil {
-		return fake.GenerateManifestStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+		return fake.GenerateManifestStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -70,17 +73,17 @@ func (fake *FakeManifestGenerator) GenerateManifestCallCount() int {
 	return len(fake.generateManifestArgsForCall)
 }
 
-func (fake *FakeManifestGenerator) GenerateManifestCalls(stub func(string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)) {
+func (fake *FakeManifestGenerator) GenerateManifestCalls(stub func(context.Context, string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)) {
 	fake.generateManifestMutex.Lock()
 	defer fake.generateManifestMutex.Unlock()
 	fake.GenerateManifestStub = stub
 }
 
-func (fake *FakeManifestGenerator) GenerateManifestArgsForCall(i int) (string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) {
+func (fake *FakeManifestGenerator) GenerateManifestArgsForCall(i int) (context.Context, string, string, map[string]interface{}, []byte, *string, map[string]string, map[string]string, *log.Logger) {
 	fake.generateManifestMutex.RLock()
 	defer fake.generateManifestMutex.RUnlock()
 	argsForCall := fake.generateManifestArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9
 }
 
 func (fake *FakeManifestGenerator) GenerateManifestReturns(result1 serviceadapter.MarshalledGenerateManifest, result2 error) {
@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	context "context"
 	log "log"
 	sync "sync"
 
@@ -11,17 +12,18 @@ import (
 )
 
 type FakeServiceAdapterClient struct {
-	GenerateManifestStub        func(serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)
+	GenerateManifestStub        func(context.Context, serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)
 	generateManifestMutex       sync.RWMutex
 	generateManifestArgsForCall []struct {
-		arg1 serviceadapter.ServiceDeployment
-		arg2 serviceadapter.Plan
-		arg3 map[string]interface{}
-		arg4 []byte
-		arg5 *serviceadapter.Plan
-		arg6 map[string]string
+		arg1 context.Context
+		arg2 serviceadapter.ServiceDeployment
+		arg3 serviceadapter.Plan
+		arg4 map[string]interface{}
+		arg5 []byte
+		arg6 *serviceadapter.Plan
 		arg7 map[string]string
-		arg8 *log.Logger
+		arg8 map[string]string
+		arg9 *log.Logger
 	}
 	generateManifestReturns struct {
 		result1 serviceadapter.MarshalledGenerateManifest
@@ -31,11 +33,12 @@ type FakeServiceAdapterClient struct {
 		result1 serviceadapter.MarshalledGenerateManifest
 		result2 error
 	}
-	GeneratePlanSchemaStub        func(serviceadapter.Plan, *log.Logger) (brokerapi.ServiceSchemas, error)
+	GeneratePlanSchemaStub        func(context.Context, serviceadapter.Plan, *log.Logger) (brokerapi.ServiceSchemas, error)
 	generatePlanSchemaMutex       sync.RWMutex
 	generatePlanSchemaArgsForCall []struct {
-		arg1 serviceadapter.Plan
-		arg2 *log.Logger
+		arg1 context.Context
+		arg2 serviceadapter.Plan
+		arg3 *log.Logger
 	}
 	generatePlanSchemaReturns struct {
 		result1 brokerapi.ServiceSchemas
@@ -49,28 +52,29 @@ type FakeServiceAdapterClient struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeServiceAdapterClient) GenerateManifest(arg1 serviceadapter.ServiceDeployment, arg2 serviceadapter.Plan, arg3 map[string]interface{}, arg4 []byte, arg5 *serviceadapter.Plan, arg6 map[string]string, arg7 map[string]string, arg8 *log.Logger) (serviceadapter.MarshalledGenerateManifest, error) {
-	var arg4Copy []byte
-	if arg4 != nil {
-		arg4Copy = make([]byte, len(arg4))
-		copy(arg4Copy, arg4)
+func (fake *FakeServiceAdapterClient) GenerateManifest(arg1 context.Context, arg2 serviceadapter.ServiceDeployment, arg3 serviceadapter.Plan, arg4 map[string]interface{}, arg5 []byte, arg6 *serviceadapter.Plan, arg7 map[string]string, arg8 map[string]string, arg9 *log.Logger) (serviceadapter.MarshalledGenerateManifest, error) {
+	var arg5Copy []byte
+	if arg5 != nil {
+		arg5Copy = make([]byte, len(arg5))
+		copy(arg5Copy, arg5)
 	}
 	fake.generateManifestMutex.Lock()
 	ret, specificReturn := fake.generateManifestReturnsOnCall[len(fake.generateManifestArgsForCall)]
 	fake.generateManifestArgsForCall = append(fake.generateManifestArgsForCall, struct {
-		arg1 serviceadapter.ServiceDeployment
-		arg2 serviceadapter.Plan
-		arg3 map[string]interface{}
-		arg4 []byte
-		arg5 *serviceadapter.Plan
-		arg6 map[string]string
+		arg1 context.Context
+		arg2 serviceadapter.ServiceDeployment
+		arg3 serviceadapter.Plan
+		arg4 map[string]interface{}
+		arg5 []byte
+		arg6 *serviceadapter.Plan
 		arg7 map[string]string
-		arg8 *log.Logger
-	}{arg1, arg2, arg3, arg4Copy, arg5, arg6, arg7, arg8})
-	fake.recordInvocation("GenerateManifest", []interface{}{arg1, arg2, arg3, arg4Copy, arg5, arg6, arg7, arg8})
+		arg8 map[string]string
+		arg9 *log.Logger
+	}{arg1, arg2, arg3, arg4, arg5Copy, arg6, arg7, arg8, arg9})
+	fake.recordInvocation("GenerateManifest", []interface{}{arg1, arg2, arg3, arg4, arg5Copy, arg6, arg7, arg8, arg9})
 	fake.generateManifestMutex.Unlock()
 	if fake.GenerateManifestStub != nil {
-		return fake.GenerateManifestStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+		return fake.GenerateManifestStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -85,17 +89,17 @@ func (fake *FakeServiceAdapterClient) GenerateManifestCallCount() int {
 	return len(fake.generateManifestArgsForCall)
 }
 
-func (fake *FakeServiceAdapterClient) GenerateManifestCalls(stub func(serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)) {
+func (fake *FakeServiceAdapterClient) GenerateManifestCalls(stub func(context.Context, serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) (serviceadapter.MarshalledGenerateManifest, error)) {
 	fake.generateManifestMutex.Lock()
 	defer fake.generateManifestMutex.Unlock()
 	fake.GenerateManifestStub = stub
 }
 
-func (fake *FakeServiceAdapterClient) GenerateManifestArgsForCall(i int) (serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) {
+func (fake *FakeServiceAdapterClient) GenerateManifestArgsForCall(i int) (context.Context, serviceadapter.ServiceDeployment, serviceadapter.Plan, map[string]interface{}, []byte, *serviceadapter.Plan, map[string]string, map[string]string, *log.Logger) {
 	fake.generateManifestMutex.RLock()
 	defer fake.generateManifestMutex.RUnlock()
 	argsForCall := fake.generateManifestArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9
 }
 
 func (fake *FakeServiceAdapterClient) GenerateManifestReturns(result1 serviceadapter.MarshalledGenerateManifest, result2 error) {
@@ -124,17 +128,18 @@ func (fake *FakeServiceAdapterClient) GenerateManifestReturnsOnCall(i int, resul
 	}{result1, result2}
 }
 
-func (fake *FakeServiceAdapterClient) GeneratePlanSchema(arg1 serviceadapter.Plan, arg2 *log.Logger) (brokerapi.ServiceSchemas, error) {
+func (fake *FakeServiceAdapterClient) GeneratePlanSchema(arg1 context.Context, arg2 serviceadapter.Plan, arg3 *log.Logger) (brokerapi.ServiceSchemas, error) {
 	fake.generatePlanSchemaMutex.Lock()
 	ret, specificReturn := fake.generatePlanSchemaReturnsOnCall[len(fake.generatePlanSchemaArgsForCall)]
 	fake.generatePlanSchemaArgsForCall = append(fake.generatePlanSchemaArgsForCall, struct {
-		arg1 serviceadapter.Plan
-		arg2 *log.Logger
-	}{arg1, arg2})
-	fake.recordInvocation("GeneratePlanSchema", []interface{}{arg1, arg2})
+		arg1 context.Context
+		arg2 serviceadapter.Plan
+		arg3 *log.Logger
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("GeneratePlanSchema", []interface{}{arg1, arg2, arg3})
 	fake.generatePlanSchemaMutex.Unlock()
 	if fake.GeneratePlanSchemaStub != nil {
-		return fake.GeneratePlanSchemaStub(arg1, arg2)
+		return fake.GeneratePlanSchemaStub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -149,17 +154,17 @@ func (fake *FakeServiceAdapterClient) GeneratePlanSchemaCallCount() int {
 	return len(fake.generatePlanSchemaArgsForCall)
 }
 
-func (fake *FakeServiceAdapterClient) GeneratePlanSchemaCalls(stub func(serviceadapter.Plan, *log.Logger) (brokerapi.ServiceSchemas, error)) {
+func (fake *FakeServiceAdapterClient) GeneratePlanSchemaCalls(stub func(context.Context, serviceadapter.Plan, *log.Logger) (brokerapi.ServiceSchemas, error)) {
 	fake.generatePlanSchemaMutex.Lock()
 	defer fake.generatePlanSchemaMutex.Unlock()
 	fake.GeneratePlanSchemaStub = stub
 }
 
-func (fake *FakeServiceAdapterClient) GeneratePlanSchemaArgsForCall(i int) (serviceadapter.Plan, *log.Logger) {
+func (fake *FakeServiceAdapterClient) GeneratePlanSchemaArgsForCall(i int) (context.Context, serviceadapter.Plan, *log.Logger) {
 	fake.generatePlanSchemaMutex.RLock()
 	defer fake.generatePlanSchemaMutex.RUnlock()
 	argsForCall := fake.generatePlanSchemaArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeServiceAdapterClient) GeneratePlanSchemaReturns(result1 brokerapi.ServiceSchemas, result2 error) {
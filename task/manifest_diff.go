@@ -0,0 +1,271 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const diffContextLines = 3
+
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind   diffOpKind
+	line   string
+	aIndex int
+	bIndex int
+}
+
+// yamlDiff returns a unified diff between two YAML documents, or an empty
+// string when they are the same. Both documents are normalised first so that
+// differences in key order or formatting are not reported.
+func yamlDiff(fromName, toName string, from, to []byte) string {
+	fromLines := splitLines(normaliseYAML(from))
+	toLines := splitLines(normaliseYAML(to))
+
+	ops := diffLines(fromLines, toLines)
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != diffEqual {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var diff bytes.Buffer
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(changes); {
+		end := start
+		for end+1 < len(changes) && changes[end+1]-changes[end] <= 2*diffContextLines {
+			end++
+		}
+		writeHunk(&diff, ops, changes[start], changes[end])
+		start = end + 1
+	}
+
+	return diff.String()
+}
+
+func writeHunk(diff *bytes.Buffer, ops []diffOp, firstChange, lastChange int) {
+	first := maxInt(firstChange-diffContextLines, 0)
+	last := minInt(lastChange+diffContextLines, len(ops)-1)
+
+	var aCount, bCount int
+	var body bytes.Buffer
+	for _, op := range ops[first : last+1] {
+		switch op.kind {
+		case diffEqual:
+			aCount++
+			bCount++
+			fmt.Fprintf(&body, " %s\n", op.line)
+		case diffDelete:
+			aCount++
+			fmt.Fprintf(&body, "-%s\n", op.line)
+		case diffInsert:
+			bCount++
+			fmt.Fprintf(&body, "+%s\n", op.line)
+		}
+	}
+
+	fmt.Fprintf(diff, "@@ -%s +%s @@\n", hunkRange(ops[first].aIndex, aCount), hunkRange(ops[first].bIndex, bCount))
+	diff.Write(body.Bytes())
+}
+
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+// diffLines computes a shortest edit script between two lists of lines using
+// Myers' linear space algorithm, so large manifests do not need an n*m table.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	diffRange(a, b, 0, 0, &ops)
+	return ops
+}
+
+func diffRange(a, b []string, aOffset, bOffset int, ops *[]diffOp) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for i := 0; i < prefix; i++ {
+		*ops = append(*ops, diffOp{kind: diffEqual, line: a[i], aIndex: aOffset + i, bIndex: bOffset + i})
+	}
+	a, b = a[prefix:], b[prefix:]
+	aOffset, bOffset = aOffset+prefix, bOffset+prefix
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	aMiddle, bMiddle := a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch x, y := middleSnake(aMiddle, bMiddle); {
+	case len(aMiddle) == 0 || len(bMiddle) == 0 || x < 0:
+		for i, line := range aMiddle {
+			*ops = append(*ops, diffOp{kind: diffDelete, line: line, aIndex: aOffset + i, bIndex: bOffset})
+		}
+		for j, line := range bMiddle {
+			*ops = append(*ops, diffOp{kind: diffInsert, line: line, aIndex: aOffset + len(aMiddle), bIndex: bOffset + j})
+		}
+	default:
+		diffRange(aMiddle[:x], bMiddle[:y], aOffset, bOffset, ops)
+		diffRange(aMiddle[x:], bMiddle[y:], aOffset+x, bOffset+y, ops)
+	}
+
+	for i := 0; i < suffix; i++ {
+		aIndex, bIndex := len(aMiddle)+i, len(bMiddle)+i
+		*ops = append(*ops, diffOp{kind: diffEqual, line: a[aIndex], aIndex: aOffset + aIndex, bIndex: bOffset + bIndex})
+	}
+}
+
+// middleSnake finds the point at which a shortest edit script between a and b
+// can be split in two, searching forwards and backwards at the same time. It
+// returns -1, -1 when a and b have nothing in common.
+func middleSnake(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return -1, -1
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	checkForward := delta%2 != 0
+	var kForwardStart, kForwardEnd, kBackwardStart, kBackwardEnd int
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + kForwardStart; k <= d-kForwardEnd; k += 2 {
+			index := offset + k
+			var x int
+			if k == -d || (k != d && forward[index-1] < forward[index+1]) {
+				x = forward[index+1]
+			} else {
+				x = forward[index-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[index] = x
+
+			switch {
+			case x > n:
+				kForwardEnd += 2
+			case y > m:
+				kForwardStart += 2
+			case checkForward:
+				backwardIndex := offset + delta - k
+				if backwardIndex >= 0 && backwardIndex < len(backward) && backward[backwardIndex] != -1 {
+					if x >= n-backward[backwardIndex] {
+						return x, y
+					}
+				}
+			}
+		}
+
+		for k := -d + kBackwardStart; k <= d-kBackwardEnd; k += 2 {
+			index := offset + k
+			var x int
+			if k == -d || (k != d && backward[index-1] < backward[index+1]) {
+				x = backward[index+1]
+			} else {
+				x = backward[index-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[index] = x
+
+			switch {
+			case x > n:
+				kBackwardEnd += 2
+			case y > m:
+				kBackwardStart += 2
+			case !checkForward:
+				forwardIndex := offset + delta - k
+				if forwardIndex >= 0 && forwardIndex < len(forward) && forward[forwardIndex] != -1 {
+					forwardX := forward[forwardIndex]
+					forwardY := forwardX - (forwardIndex - offset)
+					if forwardX >= n-x {
+						return forwardX, forwardY
+					}
+				}
+			}
+		}
+	}
+
+	return -1, -1
+}
+
+func normaliseYAML(document []byte) string {
+	var content interface{}
+	if err := yaml.Unmarshal(document, &content); err != nil {
+		return string(document)
+	}
+	if content == nil {
+		return ""
+	}
+
+	normalised, err := yaml.Marshal(content)
+	if err != nil {
+		return string(document)
+	}
+	return string(normalised)
+}
+
+func splitLines(document string) []string {
+	document = strings.TrimSuffix(document, "\n")
+	if document == "" {
+		return nil
+	}
+	return strings.Split(document, "\n")
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
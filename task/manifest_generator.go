@@ -7,6 +7,7 @@
 package task
 
 import (
+	"context"
 	"log"
 
 	"github.com/pivotal-cf/brokerapi"
@@ -18,6 +19,7 @@ import (
 //go:generate counterfeiter -o fakes/fake_service_adapter_client.go . ServiceAdapterClient
 type ServiceAdapterClient interface {
 	GenerateManifest(
+		ctx context.Context,
 		serviceReleases serviceadapter.ServiceDeployment,
 		plan serviceadapter.Plan,
 		requestParams map[string]interface{},
@@ -27,7 +29,7 @@ type ServiceAdapterClient interface {
 		previousConfigs map[string]string,
 		logger *log.Logger,
 	) (serviceadapter.MarshalledGenerateManifest, error)
-	GeneratePlanSchema(plan serviceadapter.Plan, logger *log.Logger) (brokerapi.ServiceSchemas, error)
+	GeneratePlanSchema(ctx context.Context, plan serviceadapter.Plan, logger *log.Logger) (brokerapi.ServiceSchemas, error)
 }
 
 type manifestGenerator struct {
@@ -54,6 +56,7 @@ func NewManifestGenerator(
 type RawBoshManifest []byte
 
 func (m manifestGenerator) GenerateManifest(
+	ctx context.Context,
 	deploymentName, planID string,
 	requestParams map[string]interface{},
 	oldManifest []byte,
@@ -76,7 +79,7 @@ func (m manifestGenerator) GenerateManifest(
 	}
 	logger.Printf("service adapter will generate manifest for deployment %s\n", deploymentName)
 
-	manifest, err := m.adapterClient.GenerateManifest(serviceDeployment, plan, requestParams, oldManifest, previousPlan, secretsMap, previousConfigs, logger)
+	manifest, err := m.adapterClient.GenerateManifest(ctx, serviceDeployment, plan, requestParams, oldManifest, previousPlan, secretsMap, previousConfigs, logger)
 	if err != nil {
 		logger.Printf("generate manifest: %v\n", err)
 	}
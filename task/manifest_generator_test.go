@@ -7,6 +7,7 @@
 package task_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -150,6 +151,7 @@ var _ = Describe("Manifest Generator", func() {
 
 			err error
 
+			ctx            context.Context
 			planGUID       string
 			previousPlanID *string
 			requestParams  map[string]interface{}
@@ -157,6 +159,7 @@ var _ = Describe("Manifest Generator", func() {
 		)
 
 		BeforeEach(func() {
+			ctx = context.WithValue(context.Background(), "request", "some-request")
 			planGUID = existingPlanID
 			previousPlanID = nil
 
@@ -166,7 +169,7 @@ var _ = Describe("Manifest Generator", func() {
 		})
 
 		JustBeforeEach(func() {
-			generateManifestOutput, err = mg.GenerateManifest(deploymentName, planGUID, requestParams, oldManifest, previousPlanID, oldSecretsMap, oldConfigsMap, logger)
+			generateManifestOutput, err = mg.GenerateManifest(ctx, deploymentName, planGUID, requestParams, oldManifest, previousPlanID, oldSecretsMap, oldConfigsMap, logger)
 			manifest = []byte(generateManifestOutput.Manifest)
 		})
 
@@ -195,8 +198,13 @@ var _ = Describe("Manifest Generator", func() {
 				Expect(logBuffer.String()).To(ContainSubstring(expectedLog))
 			})
 
+			It("calls the service adapter with the context of the request", func() {
+				passedCtx, _, _, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				Expect(passedCtx).To(Equal(ctx))
+			})
+
 			It("calls the service adapter with the service deployment", func() {
-				passedServiceDeployment, _, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, passedServiceDeployment, _, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				expectedServiceDeployment := serviceadapter.ServiceDeployment{
 					DeploymentName: deploymentName,
 					Releases:       serviceReleases,
@@ -206,32 +214,32 @@ var _ = Describe("Manifest Generator", func() {
 			})
 
 			It("calls the service adapter with the plan", func() {
-				_, passedPlan, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, passedPlan, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				Expect(passedPlan.InstanceGroups).To(Equal(existingPlan.InstanceGroups))
 			})
 
 			It("calls the service adapter with the request params", func() {
-				_, _, passedRequestParams, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, _, passedRequestParams, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				Expect(passedRequestParams).To(Equal(requestParams))
 			})
 
 			It("calls the service adapter with the old manifest", func() {
-				_, _, _, passedOldManifest, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, _, _, passedOldManifest, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				Expect(passedOldManifest).To(Equal(oldManifest))
 			})
 
 			It("calls the service adapter with the secrets map", func() {
-				_, _, _, _, _, passedSecretsMap, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, _, _, _, _, passedSecretsMap, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				Expect(passedSecretsMap).To(Equal(oldSecretsMap))
 			})
 
 			It("calls the service adapter with the configs map", func() {
-				_, _, _, _, _, _, passedConfigsMap, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, _, _, _, _, _, passedConfigsMap, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				Expect(passedConfigsMap).To(Equal(oldConfigsMap))
 			})
 
 			It("merges global and plan properties", func() {
-				_, actualPlan, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+				_, _, actualPlan, _, _, _, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 				expectedProperties := serviceadapter.Properties{
 					"a_global_property":          "global_value",
 					"some_other_global_property": "other_global_value",
@@ -247,12 +255,12 @@ var _ = Describe("Manifest Generator", func() {
 				})
 
 				It("calls the service adapter with the previous plan", func() {
-					_, _, _, _, passedPreviousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+					_, _, _, _, _, passedPreviousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 					Expect(passedPreviousPlan.InstanceGroups).To(Equal(secondPlan.InstanceGroups))
 				})
 
 				It("merges global and previous plan properties, overriding global with plan props", func() {
-					_, _, _, _, previousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+					_, _, _, _, _, previousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 					expectedProperties := serviceadapter.Properties{
 						"a_global_property":          "overrides_global_value",
 						"some_other_global_property": "other_global_value",
@@ -269,7 +277,7 @@ var _ = Describe("Manifest Generator", func() {
 				})
 
 				It("calls the service adapter with the nil previous plan", func() {
-					_, _, _, _, passedPreviousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
+					_, _, _, _, _, passedPreviousPlan, _, _, _ := serviceAdapter.GenerateManifestArgsForCall(0)
 					Expect(passedPreviousPlan).To(BeNil())
 				})
 			})
@@ -0,0 +1,280 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	"gopkg.in/yaml.v2"
+)
+
+// PendingChanges regenerates the manifest and BOSH configs of a deployment on
+// the given plan, with no new request parameters, and returns how they differ
+// from what is deployed. These are the changes that would block an update.
+func (d Deployer) PendingChanges(ctx context.Context, deploymentName, planID string, oldSecretsMap map[string]string, logger *log.Logger) (broker.PendingChanges, error) {
+	oldManifest, err := d.getDeploymentManifest(deploymentName, logger)
+	if err != nil {
+		return broker.PendingChanges{}, err
+	}
+
+	var oldConfigs map[string]string
+	if !d.DisableBoshConfigs {
+		oldConfigs, err = d.getConfigMap(deploymentName, logger)
+		if err != nil {
+			return broker.PendingChanges{}, err
+		}
+	}
+
+	regenerated, err := d.regenerate(ctx, deploymentName, &planID, oldManifest, oldSecretsMap, oldConfigs, logger)
+	if err != nil {
+		return broker.PendingChanges{}, err
+	}
+	return d.pendingChanges(regenerated, oldConfigs)
+}
+
+func (d Deployer) checkForPendingChanges(
+	ctx context.Context,
+	deploymentName string,
+	previousPlanID *string,
+	rawOldManifest RawBoshManifest,
+	oldSecretsMap map[string]string,
+	previousConfigs map[string]string,
+	logger *log.Logger,
+) error {
+	regenerated, err := d.regenerate(ctx, deploymentName, previousPlanID, rawOldManifest, oldSecretsMap, previousConfigs, logger)
+	if err != nil {
+		return err
+	}
+
+	// Changes to BOSH configs alone do not block an update, so there is
+	// nothing to report unless the manifest has changed.
+	if !regenerated.manifestChanged() {
+		return nil
+	}
+
+	pendingChanges, err := d.pendingChanges(regenerated, previousConfigs)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("deployment %s has pending changes: %s\n", deploymentName, pendingChanges)
+	return broker.NewPendingChangesNotAppliedError(errors.New("There are pending changes"), pendingChanges)
+}
+
+// regeneratedDeployment is what is deployed alongside what the broker would
+// deploy on the same plan with no new request parameters. The update blocks
+// of the manifests are ignored, as changing them does not change the
+// deployment.
+type regeneratedDeployment struct {
+	deployed  bosh.BoshManifest
+	generated bosh.BoshManifest
+	configs   serviceadapter.BOSHConfigs
+}
+
+func (r regeneratedDeployment) manifestChanged() bool {
+	return !reflect.DeepEqual(r.deployed, r.generated)
+}
+
+func (d Deployer) regenerate(
+	ctx context.Context,
+	deploymentName string,
+	previousPlanID *string,
+	rawOldManifest RawBoshManifest,
+	oldSecretsMap map[string]string,
+	previousConfigs map[string]string,
+	logger *log.Logger,
+) (regeneratedDeployment, error) {
+	regeneratedManifestContent, err := d.manifestGenerator.GenerateManifest(ctx, deploymentName, *previousPlanID, map[string]interface{}{}, rawOldManifest, previousPlanID, oldSecretsMap, previousConfigs, logger)
+	if err != nil {
+		return regeneratedDeployment{}, err
+	}
+
+	regeneratedManifest, err := marshalBoshManifest([]byte(regeneratedManifestContent.Manifest))
+	if err != nil {
+		return regeneratedDeployment{}, err
+	}
+	ignoreUpdateBlock(&regeneratedManifest)
+
+	oldManifest, err := marshalBoshManifest(rawOldManifest)
+	if err != nil {
+		return regeneratedDeployment{}, err
+	}
+	ignoreUpdateBlock(&oldManifest)
+
+	return regeneratedDeployment{
+		deployed:  oldManifest,
+		generated: regeneratedManifest,
+		configs:   regeneratedManifestContent.Configs,
+	}, nil
+}
+
+// pendingChanges describes how a regenerated deployment differs from what is
+// deployed. The manifests are only diffed when they are known to differ.
+func (d Deployer) pendingChanges(regenerated regeneratedDeployment, previousConfigs map[string]string) (broker.PendingChanges, error) {
+	var pendingChanges broker.PendingChanges
+
+	if regenerated.manifestChanged() {
+		deployed, err := yaml.Marshal(regenerated.deployed)
+		if err != nil {
+			return broker.PendingChanges{}, err
+		}
+		generated, err := yaml.Marshal(regenerated.generated)
+		if err != nil {
+			return broker.PendingChanges{}, err
+		}
+
+		pendingChanges.Changes = structuralChanges("", parseYAML(deployed), parseYAML(generated))
+		pendingChanges.ManifestDiff = yamlDiff("deployed/manifest.yml", "generated/manifest.yml", deployed, generated)
+	}
+
+	if !d.DisableBoshConfigs {
+		configTypes := make([]string, 0, len(regenerated.configs))
+		for configType := range regenerated.configs {
+			configTypes = append(configTypes, configType)
+		}
+		sort.Strings(configTypes)
+
+		for _, configType := range configTypes {
+			deployedConfig := []byte(previousConfigs[configType])
+			generatedConfig := []byte(regenerated.configs[configType])
+
+			configDiff := yamlDiff(
+				fmt.Sprintf("deployed/%s-config.yml", configType),
+				fmt.Sprintf("generated/%s-config.yml", configType),
+				deployedConfig,
+				generatedConfig,
+			)
+			if configDiff == "" {
+				continue
+			}
+
+			if pendingChanges.ConfigDiffs == nil {
+				pendingChanges.ConfigDiffs = map[string]string{}
+			}
+			pendingChanges.ConfigDiffs[configType] = configDiff
+			pendingChanges.Changes = append(
+				pendingChanges.Changes,
+				structuralChanges("configs/"+configType, parseYAML(deployedConfig), parseYAML(generatedConfig))...,
+			)
+		}
+	}
+
+	if pendingChanges.Changes == nil {
+		pendingChanges.Changes = []broker.PendingChange{}
+	}
+
+	return pendingChanges, nil
+}
+
+// structuralChanges walks two parsed YAML documents and returns the paths at
+// which they differ. Lists whose entries all have a unique name (or alias,
+// as stemcells do) are compared entry by entry, so that a change to one job
+// of an instance group is reported against that job rather than the whole
+// list.
+func structuralChanges(path string, from, to interface{}) []broker.PendingChange {
+	fromMap, fromIsMap := from.(map[interface{}]interface{})
+	toMap, toIsMap := to.(map[interface{}]interface{})
+	if fromIsMap && toIsMap {
+		fromKeys, fromEntries := mapEntries(fromMap)
+		toKeys, toEntries := mapEntries(toMap)
+		return keyedChanges(path, fromKeys, fromEntries, toKeys, toEntries)
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		fromKeys, fromEntries, fromNamed := namedEntries(fromList)
+		toKeys, toEntries, toNamed := namedEntries(toList)
+		if fromNamed && toNamed {
+			return keyedChanges(path, fromKeys, fromEntries, toKeys, toEntries)
+		}
+	}
+
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []broker.PendingChange{{Path: path, Change: broker.PendingChangeChanged}}
+}
+
+func keyedChanges(path string, fromKeys []string, from map[string]interface{}, toKeys []string, to map[string]interface{}) []broker.PendingChange {
+	var changes []broker.PendingChange
+	for _, key := range fromKeys {
+		toValue, found := to[key]
+		if !found {
+			changes = append(changes, broker.PendingChange{Path: joinPath(path, key), Change: broker.PendingChangeRemoved})
+			continue
+		}
+		changes = append(changes, structuralChanges(joinPath(path, key), from[key], toValue)...)
+	}
+	for _, key := range toKeys {
+		if _, found := from[key]; !found {
+			changes = append(changes, broker.PendingChange{Path: joinPath(path, key), Change: broker.PendingChangeAdded})
+		}
+	}
+	return changes
+}
+
+func mapEntries(m map[interface{}]interface{}) ([]string, map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	entries := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		k := fmt.Sprint(key)
+		keys = append(keys, k)
+		entries[k] = value
+	}
+	sort.Strings(keys)
+	return keys, entries
+}
+
+func namedEntries(list []interface{}) ([]string, map[string]interface{}, bool) {
+	keys := make([]string, 0, len(list))
+	entries := make(map[string]interface{}, len(list))
+	for _, entry := range list {
+		m, ok := entry.(map[interface{}]interface{})
+		if !ok {
+			return nil, nil, false
+		}
+
+		name, ok := m["name"].(string)
+		if !ok {
+			name, ok = m["alias"].(string)
+		}
+		if !ok {
+			return nil, nil, false
+		}
+
+		if _, duplicate := entries[name]; duplicate {
+			return nil, nil, false
+		}
+		keys = append(keys, name)
+		entries[name] = entry
+	}
+	return keys, entries, true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+func parseYAML(document []byte) interface{} {
+	var content interface{}
+	if err := yaml.Unmarshal(document, &content); err != nil {
+		return string(document)
+	}
+	return content
+}
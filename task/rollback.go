@@ -0,0 +1,82 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
+	"github.com/pivotal-cf/on-demand-service-broker/broker"
+)
+
+// Snapshot reads the manifest and, unless BOSH configs are disabled, the
+// configs that are deployed for the deployment, so that an upgrade of it can
+// be rolled back.
+func (d Deployer) Snapshot(deploymentName string, logger *log.Logger) (broker.DeploymentSnapshot, error) {
+	manifest, err := d.getDeploymentManifest(deploymentName, logger)
+	if err != nil {
+		return broker.DeploymentSnapshot{}, err
+	}
+
+	var configs map[string]string
+	if !d.DisableBoshConfigs {
+		configs, err = d.getConfigMap(deploymentName, logger)
+		if err != nil {
+			return broker.DeploymentSnapshot{}, err
+		}
+	}
+
+	return broker.DeploymentSnapshot{Manifest: manifest, Configs: configs}, nil
+}
+
+// Rollback redeploys the manifest and BOSH configs of the snapshot taken
+// before an upgrade of the deployment, under the given BOSH context ID. Config
+// types the upgrade added are deleted.
+//
+// ODB-managed secrets are not part of a snapshot. The upgrade writes them to
+// CredHub at the same paths the previous manifest refers to, so a rolled back
+// deployment uses the values written by the upgrade.
+func (d Deployer) Rollback(ctx context.Context, deploymentName, boshContextID string, snapshot broker.DeploymentSnapshot, logger *log.Logger) (int, error) {
+	if !d.DisableBoshConfigs {
+		if err := d.restoreConfigs(deploymentName, snapshot.Configs, logger); err != nil {
+			return 0, err
+		}
+	}
+
+	boshTaskID, err := d.boshClient.Deploy(snapshot.Manifest, boshContextID, logger, boshdirector.NewAsyncTaskReporter())
+	if err != nil {
+		return 0, fmt.Errorf("error rolling back deployment: %s\n", err)
+	}
+	logger.Printf("Bosh task ID for rollback of deployment %s is %d\n", deploymentName, boshTaskID)
+
+	return boshTaskID, nil
+}
+
+func (d Deployer) restoreConfigs(deploymentName string, configs map[string]string, logger *log.Logger) error {
+	currentConfigs, err := d.getConfigMap(deploymentName, logger)
+	if err != nil {
+		return fmt.Errorf("error getting configs: %s\n", err)
+	}
+
+	for configType := range currentConfigs {
+		if _, found := configs[configType]; found {
+			continue
+		}
+		if _, err := d.boshClient.DeleteConfig(configType, deploymentName, logger); err != nil {
+			return fmt.Errorf("error deleting config: %s\n", err)
+		}
+	}
+
+	for configType, configContent := range configs {
+		if err := d.boshClient.UpdateConfig(configType, deploymentName, []byte(configContent), logger); err != nil {
+			return fmt.Errorf("error restoring config: %s\n", err)
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@
 package task
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"reflect"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/pivotal-cf/on-demand-service-broker/boshdirector"
 	"github.com/pivotal-cf/on-demand-service-broker/broker"
+	"github.com/pivotal-cf/on-demand-service-broker/loggerfactory"
 	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 	"gopkg.in/yaml.v2"
@@ -28,11 +30,14 @@ type BoshClient interface {
 	GetDeployment(name string, logger *log.Logger) ([]byte, bool, error)
 	GetConfigs(configName string, logger *log.Logger) ([]boshdirector.BoshConfig, error)
 	UpdateConfig(configType, configName string, configContent []byte, logger *log.Logger) error
+	DeleteConfig(configType, configName string, logger *log.Logger) (bool, error)
+	RunErrand(deploymentName, errandName string, errandInstances []string, contextID string, logger *log.Logger, taskReporter *boshdirector.AsyncTaskReporter) (int, error)
 }
 
 //go:generate counterfeiter -o fakes/fake_manifest_generator.go . ManifestGenerator
 type ManifestGenerator interface {
 	GenerateManifest(
+		ctx context.Context,
 		deploymentName,
 		planID string,
 		requestParams map[string]interface{},
@@ -74,16 +79,16 @@ func NewDeployer(boshClient BoshClient, manifestGenerator ManifestGenerator, odb
 	}
 }
 
-func (d Deployer) Create(deploymentName, planID string, requestParams map[string]interface{}, boshContextID string, logger *log.Logger) (int, []byte, error) {
+func (d Deployer) Create(ctx context.Context, deploymentName, planID string, requestParams map[string]interface{}, boshContextID string, logger *log.Logger) (int, []byte, error) {
 	err := d.assertNoOperationsInProgress(deploymentName, logger)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	return d.doDeploy(deploymentName, planID, "create", requestParams, nil, nil, boshContextID, nil, nil, logger)
+	return d.doDeploy(ctx, deploymentName, planID, "create", requestParams, nil, nil, boshContextID, nil, nil, logger)
 }
 
-func (d Deployer) Upgrade(deploymentName, planID string, previousPlanID *string, boshContextID string, logger *log.Logger) (int, []byte, error) {
+func (d Deployer) Upgrade(ctx context.Context, deploymentName, planID string, previousPlanID *string, boshContextID string, logger *log.Logger) (int, []byte, error) {
 	err := d.assertNoOperationsInProgress(deploymentName, logger)
 	if err != nil {
 		return 0, nil, err
@@ -102,10 +107,11 @@ func (d Deployer) Upgrade(deploymentName, planID string, previousPlanID *string,
 		}
 	}
 
-	return d.doDeploy(deploymentName, planID, "upgrade", nil, oldManifest, previousPlanID, boshContextID, nil, oldConfigs, logger)
+	return d.doDeploy(ctx, deploymentName, planID, "upgrade", nil, oldManifest, previousPlanID, boshContextID, nil, oldConfigs, logger)
 }
 
 func (d Deployer) Recreate(
+	ctx context.Context,
 	deploymentName,
 	planID,
 	boshContextID string,
@@ -119,14 +125,35 @@ func (d Deployer) Recreate(
 	taskID, err := d.boshClient.Recreate(deploymentName, boshContextID, logger, boshdirector.NewAsyncTaskReporter())
 
 	if err != nil {
-		logger.Printf("failed to recreate deployment %q: %s", deploymentName, err)
+		loggerfactory.Errorf(logger, "failed to recreate deployment %q: %s", deploymentName, err)
 		return 0, err
 	}
 	logger.Printf("Submitted BOSH recreate with task ID %d for deployment %q", taskID, deploymentName)
 	return taskID, nil
 }
 
+// RunErrand runs an errand of an existing deployment, such as a backup or
+// restore, unless another operation on the deployment is in progress.
+func (d Deployer) RunErrand(ctx context.Context, deploymentName, errandName string, errandInstances []string, boshContextID string, logger *log.Logger) (int, error) {
+	if _, err := d.getDeploymentManifest(deploymentName, logger); err != nil {
+		return 0, err
+	}
+
+	if err := d.assertNoOperationsInProgress(deploymentName, logger); err != nil {
+		return 0, err
+	}
+
+	taskID, err := d.boshClient.RunErrand(deploymentName, errandName, errandInstances, boshContextID, logger, boshdirector.NewAsyncTaskReporter())
+	if err != nil {
+		loggerfactory.Errorf(logger, "failed to run errand %s of deployment %q: %s", errandName, deploymentName, err)
+		return 0, err
+	}
+	logger.Printf("Submitted BOSH errand %s with task ID %d for deployment %q", errandName, taskID, deploymentName)
+	return taskID, nil
+}
+
 func (d Deployer) Update(
+	ctx context.Context,
 	deploymentName,
 	planID string,
 	requestParams map[string]interface{},
@@ -152,11 +179,11 @@ func (d Deployer) Update(
 			return 0, nil, err
 		}
 	}
-	if err := d.checkForPendingChanges(deploymentName, previousPlanID, oldManifest, oldSecretsMap, oldConfigs, logger); err != nil {
+	if err := d.checkForPendingChanges(ctx, deploymentName, previousPlanID, oldManifest, oldSecretsMap, oldConfigs, logger); err != nil {
 		return 0, nil, err
 	}
 
-	return d.doDeploy(deploymentName, planID, "update", requestParams, oldManifest, previousPlanID, boshContextID, oldSecretsMap, oldConfigs, logger)
+	return d.doDeploy(ctx, deploymentName, planID, "update", requestParams, oldManifest, previousPlanID, boshContextID, oldSecretsMap, oldConfigs, logger)
 }
 
 func (d Deployer) getDeploymentManifest(deploymentName string, logger *log.Logger) ([]byte, error) {
@@ -200,43 +227,8 @@ func (d Deployer) assertNoOperationsInProgress(deploymentName string, logger *lo
 	return nil
 }
 
-func (d Deployer) checkForPendingChanges(
-	deploymentName string,
-	previousPlanID *string,
-	rawOldManifest RawBoshManifest,
-	oldSecretsMap map[string]string,
-	previousConfigs map[string]string,
-	logger *log.Logger,
-) error {
-	regeneratedManifestContent, err := d.manifestGenerator.GenerateManifest(deploymentName, *previousPlanID, map[string]interface{}{}, rawOldManifest, previousPlanID, oldSecretsMap, previousConfigs, logger)
-	if err != nil {
-		return err
-	}
-
-	regeneratedManifest, err := marshalBoshManifest([]byte(regeneratedManifestContent.Manifest))
-	if err != nil {
-		return err
-	}
-	ignoreUpdateBlock(&regeneratedManifest)
-
-	oldManifest, err := marshalBoshManifest(rawOldManifest)
-	if err != nil {
-		return err
-	}
-	ignoreUpdateBlock(&oldManifest)
-
-	manifestsSame := reflect.DeepEqual(regeneratedManifest, oldManifest)
-
-	pendingChanges := !manifestsSame
-
-	if pendingChanges {
-		return broker.NewPendingChangesNotAppliedError(errors.New("There are pending changes"))
-	}
-
-	return nil
-}
-
 func (d Deployer) doDeploy(
+	ctx context.Context,
 	deploymentName,
 	planID string,
 	operationType string,
@@ -249,13 +241,13 @@ func (d Deployer) doDeploy(
 	logger *log.Logger,
 ) (int, []byte, error) {
 
-	generateManifestOutput, err := d.manifestGenerator.GenerateManifest(deploymentName, planID, requestParams, oldManifest, previousPlanID, oldSecretsMap, previousConfigs, logger)
+	generateManifestOutput, err := d.manifestGenerator.GenerateManifest(ctx, deploymentName, planID, requestParams, oldManifest, previousPlanID, oldSecretsMap, previousConfigs, logger)
 	if err != nil {
 		return 0, nil, err
 	}
 	manifest := generateManifestOutput.Manifest
 
-	if d.bulkSetter != nil && !reflect.ValueOf(d.bulkSetter).IsNil() {
+	if d.odbSecretsEnabled() {
 		secrets := d.odbSecrets.GenerateSecretPaths(deploymentName, manifest, generateManifestOutput.ODBManagedSecrets)
 		if err = d.bulkSetter.BulkSet(secrets); err != nil {
 			return 0, nil, err
@@ -284,6 +276,91 @@ func (d Deployer) doDeploy(
 	return boshTaskID, []byte(manifest), nil
 }
 
+// PreviewUpgrade generates the manifest and BOSH configs that an upgrade
+// would deploy and returns how they differ from what is currently deployed.
+func (d Deployer) PreviewUpgrade(ctx context.Context, deploymentName, planID string, previousPlanID *string, logger *log.Logger) (broker.DeploymentPreview, error) {
+	return d.preview(ctx, deploymentName, planID, nil, previousPlanID, nil, logger)
+}
+
+// PreviewUpdate generates the manifest and BOSH configs that an update would
+// deploy and returns how they differ from what is currently deployed. Unlike
+// Update, it does not check for pending changes.
+func (d Deployer) PreviewUpdate(
+	ctx context.Context,
+	deploymentName,
+	planID string,
+	requestParams map[string]interface{},
+	previousPlanID *string,
+	oldSecretsMap map[string]string,
+	logger *log.Logger,
+) (broker.DeploymentPreview, error) {
+	return d.preview(ctx, deploymentName, planID, requestParams, previousPlanID, oldSecretsMap, logger)
+}
+
+func (d Deployer) preview(
+	ctx context.Context,
+	deploymentName,
+	planID string,
+	requestParams map[string]interface{},
+	previousPlanID *string,
+	oldSecretsMap map[string]string,
+	logger *log.Logger,
+) (broker.DeploymentPreview, error) {
+	oldManifest, err := d.getDeploymentManifest(deploymentName, logger)
+	if err != nil {
+		return broker.DeploymentPreview{}, err
+	}
+
+	var oldConfigs map[string]string
+	if !d.DisableBoshConfigs {
+		oldConfigs, err = d.getConfigMap(deploymentName, logger)
+		if err != nil {
+			return broker.DeploymentPreview{}, err
+		}
+	}
+
+	generateManifestOutput, err := d.manifestGenerator.GenerateManifest(ctx, deploymentName, planID, requestParams, oldManifest, previousPlanID, oldSecretsMap, oldConfigs, logger)
+	if err != nil {
+		return broker.DeploymentPreview{}, err
+	}
+	manifest := generateManifestOutput.Manifest
+
+	if d.odbSecretsEnabled() {
+		secrets := d.odbSecrets.GenerateSecretPaths(deploymentName, manifest, generateManifestOutput.ODBManagedSecrets)
+		manifest = d.odbSecrets.ReplaceODBRefs(manifest, secrets)
+	}
+
+	if d.DisableBoshConfigs && len(generateManifestOutput.Configs) > 0 {
+		return broker.DeploymentPreview{}, errors.New("adapter returned bosh configs but feature is turned off")
+	}
+
+	preview := broker.DeploymentPreview{
+		ManifestDiff: yamlDiff("deployed/manifest.yml", "generated/manifest.yml", oldManifest, []byte(manifest)),
+	}
+
+	for configType, configContent := range generateManifestOutput.Configs {
+		configDiff := yamlDiff(
+			fmt.Sprintf("deployed/%s-config.yml", configType),
+			fmt.Sprintf("generated/%s-config.yml", configType),
+			[]byte(oldConfigs[configType]),
+			[]byte(configContent),
+		)
+		if configDiff != "" {
+			if preview.ConfigDiffs == nil {
+				preview.ConfigDiffs = map[string]string{}
+			}
+			preview.ConfigDiffs[configType] = configDiff
+		}
+	}
+
+	logger.Printf("generated preview of deployment %s\n", deploymentName)
+	return preview, nil
+}
+
+func (d Deployer) odbSecretsEnabled() bool {
+	return d.bulkSetter != nil && !reflect.ValueOf(d.bulkSetter).IsNil()
+}
+
 func marshalBoshManifest(rawManifest []byte) (bosh.BoshManifest, error) {
 	var boshManifest bosh.BoshManifest
 	err := yaml.Unmarshal(rawManifest, &boshManifest)
@@ -7,9 +7,11 @@
 package task_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -87,6 +89,7 @@ var _ = Describe("Deployer", func() {
 	Describe("Create()", func() {
 		JustBeforeEach(func() {
 			returnedTaskID, deployedManifest, deployError = deployer.Create(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -199,7 +202,7 @@ var _ = Describe("Deployer", func() {
 
 			It("errors when fail to store the secret", func() {
 				bulkSetter.BulkSetReturns(errors.New("what is this?"))
-				_, _, deployError = deployer.Create(deploymentName, planID, requestParams, boshContextID, logger)
+				_, _, deployError = deployer.Create(context.Background(), deploymentName, planID, requestParams, boshContextID, logger)
 				Expect(deployError).To(MatchError(ContainSubstring("what is this?")))
 			})
 
@@ -210,7 +213,7 @@ var _ = Describe("Deployer", func() {
 				})
 
 				It("doesn't error", func() {
-					_, _, deployError = deployer.Create(deploymentName, planID, requestParams, boshContextID, logger)
+					_, _, deployError = deployer.Create(context.Background(), deploymentName, planID, requestParams, boshContextID, logger)
 					Expect(deployError).ToNot(HaveOccurred())
 
 					Expect(bulkSetter.BulkSetCallCount()).To(Equal(0))
@@ -351,6 +354,7 @@ var _ = Describe("Deployer", func() {
 	Describe("Upgrade()", func() {
 		JustBeforeEach(func() {
 			returnedTaskID, deployedManifest, deployError = deployer.Upgrade(
+				context.Background(),
 				deploymentName,
 				planID,
 				previousPlanID,
@@ -555,7 +559,7 @@ var _ = Describe("Deployer", func() {
 			})
 
 			It("sends the old configs to service adapter", func() {
-				_, _, _, _, _, _, previousConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+				_, _, _, _, _, _, _, previousConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
 				Expect(previousConfigs).To(Equal(configsMap))
 			})
 		})
@@ -607,6 +611,7 @@ var _ = Describe("Deployer", func() {
 				copyParams[k] = v
 			}
 			_, _, err := deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				params,
@@ -618,13 +623,14 @@ var _ = Describe("Deployer", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(2))
-			_, _, actualRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
+			_, _, _, actualRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
 			Expect(actualRequestParams).To(Equal(copyParams))
 		})
 
 		Context("passing secret map", func() {
 			It("manifest regeneration is passed the secrets map", func() {
 				_, _, err := deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					nil,
@@ -637,7 +643,7 @@ var _ = Describe("Deployer", func() {
 
 				Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(2))
 				for i := 0; i < 2; i++ {
-					_, _, _, _, _, actualSecretsMap, _, _ := manifestGenerator.GenerateManifestArgsForCall(i)
+					_, _, _, _, _, _, actualSecretsMap, _, _ := manifestGenerator.GenerateManifestArgsForCall(i)
 					Expect(actualSecretsMap).To(Equal(secretsMap), fmt.Sprintf("call %d", i+1))
 				}
 			})
@@ -650,6 +656,7 @@ var _ = Describe("Deployer", func() {
 
 			It("wraps the error", func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -668,6 +675,7 @@ var _ = Describe("Deployer", func() {
 				BeforeEach(func() {
 					requestParams = map[string]interface{}{"foo": "bar"}
 					manifestGenerator.GenerateManifestStub = func(
+						_ context.Context,
 						_, _ string,
 						requestParams map[string]interface{},
 						previousManifest []byte,
@@ -687,6 +695,7 @@ var _ = Describe("Deployer", func() {
 
 				It("deploys successfully", func() {
 					returnedTaskID, deployedManifest, deployError = deployer.Update(
+						context.Background(),
 						deploymentName,
 						planID,
 						requestParams,
@@ -706,10 +715,10 @@ var _ = Describe("Deployer", func() {
 
 					Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(2))
 
-					_, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+					_, _, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(0)
 					Expect(passedRequestParams).To(BeEmpty())
 
-					_, _, passedRequestParams, _, _, _, _, _ = manifestGenerator.GenerateManifestArgsForCall(1)
+					_, _, _, passedRequestParams, _, _, _, _, _ = manifestGenerator.GenerateManifestArgsForCall(1)
 					Expect(passedRequestParams).To(Equal(requestParams))
 
 					Expect(boshClient.DeployCallCount()).To(Equal(1))
@@ -724,6 +733,7 @@ var _ = Describe("Deployer", func() {
 						requestParams = map[string]interface{}{}
 
 						returnedTaskID, deployedManifest, deployError = deployer.Update(
+							context.Background(),
 							deploymentName,
 							planID,
 							requestParams,
@@ -741,6 +751,7 @@ var _ = Describe("Deployer", func() {
 			Context("and the manifest generator fails to generate the manifest the second time", func() {
 				BeforeEach(func() {
 					manifestGenerator.GenerateManifestStub = func(
+						_ context.Context,
 						_, _ string,
 						requestParams map[string]interface{},
 						previousManifest []byte,
@@ -758,6 +769,7 @@ var _ = Describe("Deployer", func() {
 
 				It("wraps the error", func() {
 					returnedTaskID, deployedManifest, deployError = deployer.Update(
+						context.Background(),
 						deploymentName,
 						planID,
 						requestParams,
@@ -780,6 +792,7 @@ var _ = Describe("Deployer", func() {
 
 			It("fails without deploying", func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -793,6 +806,28 @@ var _ = Describe("Deployer", func() {
 				Expect(deployError).To(BeAssignableToTypeOf(broker.PendingChangesNotAppliedError{}))
 				Expect(boshClient.DeployCallCount()).To(BeZero())
 			})
+
+			It("reports the pending changes", func() {
+				_, _, deployError = deployer.Update(
+					context.Background(),
+					deploymentName,
+					planID,
+					requestParams,
+					previousPlanID,
+					boshContextID,
+					secretsMap,
+					logger,
+				)
+
+				pendingChangesErr, ok := deployError.(broker.PendingChangesNotAppliedError)
+				Expect(ok).To(BeTrue())
+				Expect(pendingChangesErr.Changes.Changes).To(Equal([]broker.PendingChange{
+					{Path: "name", Change: broker.PendingChangeChanged},
+				}))
+				Expect(pendingChangesErr.Changes.ManifestDiff).To(ContainSubstring("-name: a-manifest\n+name: other-name\n"))
+				Expect(logBuffer.String()).To(ContainSubstring("has pending changes: name changed"))
+				Expect(logBuffer.String()).NotTo(ContainSubstring("+name: other-name"))
+			})
 		})
 
 		It("fails when previous manifest contains odb managed secret syntax", func() {
@@ -813,6 +848,7 @@ var _ = Describe("Deployer", func() {
 			odbSecrets.ReplaceODBRefsReturns(manifestWithInterpolatedSecrets)
 
 			_, deployedManifest, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -832,6 +868,7 @@ var _ = Describe("Deployer", func() {
 
 			It("returns a deployment not found error", func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -853,6 +890,7 @@ var _ = Describe("Deployer", func() {
 
 			It("wraps the error", func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -873,6 +911,7 @@ var _ = Describe("Deployer", func() {
 
 			It("returns a deployment not found error", func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -894,6 +933,7 @@ var _ = Describe("Deployer", func() {
 
 			JustBeforeEach(func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -905,7 +945,7 @@ var _ = Describe("Deployer", func() {
 			})
 
 			It("sends the old configs to service adapter", func() {
-				_, _, _, _, _, _, previousConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+				_, _, _, _, _, _, _, previousConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
 				Expect(previousConfigs).To(Equal(configsMap))
 			})
 		})
@@ -917,6 +957,7 @@ var _ = Describe("Deployer", func() {
 
 			JustBeforeEach(func() {
 				returnedTaskID, deployedManifest, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -940,6 +981,7 @@ var _ = Describe("Deployer", func() {
 
 			It("doesn't call UpdateConfig or GetConfigs", func() {
 				returnedTaskID, _, deployError = deployer.Update(
+					context.Background(),
 					deploymentName,
 					planID,
 					requestParams,
@@ -967,6 +1009,7 @@ var _ = Describe("Deployer", func() {
 			boshClient.DeployReturns(42, nil)
 
 			returnedTaskID, deployedManifest, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -979,7 +1022,7 @@ var _ = Describe("Deployer", func() {
 			Expect(deployError).To(BeNil())
 
 			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(2))
-			_, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
+			_, _, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
 			Expect(passedRequestParams).To(Equal(requestParams))
 
 			manifestToDeploy, _, _, _ := boshClient.DeployArgsForCall(0)
@@ -1013,6 +1056,7 @@ instance_groups:
 			boshClient.DeployReturns(42, nil)
 
 			returnedTaskID, deployedManifest, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1025,7 +1069,7 @@ instance_groups:
 			Expect(deployError).To(BeNil())
 
 			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(2))
-			_, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
+			_, _, _, passedRequestParams, _, _, _, _, _ := manifestGenerator.GenerateManifestArgsForCall(1)
 			Expect(passedRequestParams).To(Equal(requestParams))
 
 			manifestToDeploy, _, _, _ := boshClient.DeployArgsForCall(0)
@@ -1047,6 +1091,7 @@ tags:
 			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: string(generatedManifest)}, nil)
 
 			_, _, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1076,6 +1121,7 @@ features:
 			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: string(generatedManifest)}, nil)
 
 			_, _, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1105,6 +1151,7 @@ features:
 			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: string(generatedManifest)}, nil)
 
 			_, _, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1142,6 +1189,7 @@ instance_groups:
 			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: string(generatedManifest)}, nil)
 
 			_, _, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1169,6 +1217,7 @@ instance_groups:
 			boshClient.DeployReturns(42, nil)
 
 			returnedTaskID, deployedManifest, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1195,6 +1244,7 @@ instance_groups:
 			boshClient.DeployReturns(42, nil)
 
 			returnedTaskID, deployedManifest, deployError = deployer.Update(
+				context.Background(),
 				deploymentName,
 				planID,
 				requestParams,
@@ -1216,7 +1266,7 @@ instance_groups:
 			boshClient.GetTasksReturns([]boshdirector.BoshTask{}, nil)
 			boshClient.RecreateReturns(42, nil)
 
-			returnedTaskID, err = deployer.Recreate(deploymentName, planID, boshContextID, logger)
+			returnedTaskID, err = deployer.Recreate(context.Background(), deploymentName, planID, boshContextID, logger)
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(boshClient.RecreateCallCount()).To(Equal(1), "recreate was not called once")
@@ -1225,7 +1275,7 @@ instance_groups:
 
 		It("fails when it can't get bosh in progess tasks", func() {
 			boshClient.GetTasksReturns([]boshdirector.BoshTask{}, fmt.Errorf("boom!"))
-			_, err = deployer.Recreate(deploymentName, planID, boshContextID, logger)
+			_, err = deployer.Recreate(context.Background(), deploymentName, planID, boshContextID, logger)
 			Expect(err).To(MatchError(ContainSubstring("error getting tasks for deployment")))
 		})
 
@@ -1234,7 +1284,7 @@ instance_groups:
 				State: "processing",
 			}}, nil)
 
-			_, err = deployer.Recreate(deploymentName, planID, boshContextID, logger)
+			_, err = deployer.Recreate(context.Background(), deploymentName, planID, boshContextID, logger)
 			Expect(err).To(MatchError("task in progress"))
 		})
 
@@ -1242,13 +1292,440 @@ instance_groups:
 			boshClient.GetTasksReturns([]boshdirector.BoshTask{}, nil)
 			boshClient.RecreateReturns(0, errors.New("zork"))
 
-			_, err = deployer.Recreate(deploymentName, planID, boshContextID, logger)
+			_, err = deployer.Recreate(context.Background(), deploymentName, planID, boshContextID, logger)
 			Expect(err).To(MatchError(ContainSubstring("zork")))
 
 			Expect(logBuffer.String()).To(ContainSubstring("failed to recreate deployment"))
 		})
 
 	})
+
+	Describe("RunErrand", func() {
+		var err error
+
+		BeforeEach(func() {
+			boshClient.GetDeploymentReturns([]byte("name: a-manifest"), true, nil)
+			boshClient.GetTasksReturns([]boshdirector.BoshTask{}, nil)
+			boshClient.RunErrandReturns(42, nil)
+		})
+
+		It("runs the errand on the deployment", func() {
+			returnedTaskID, err = deployer.RunErrand(context.Background(), deploymentName, "backup", []string{"redis/0"}, boshContextID, logger)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(returnedTaskID).To(Equal(42))
+			Expect(boshClient.RunErrandCallCount()).To(Equal(1))
+			actualDeployment, errandName, errandInstances, actualContextID, _, _ := boshClient.RunErrandArgsForCall(0)
+			Expect(actualDeployment).To(Equal(deploymentName))
+			Expect(errandName).To(Equal("backup"))
+			Expect(errandInstances).To(Equal([]string{"redis/0"}))
+			Expect(actualContextID).To(Equal(boshContextID))
+			Expect(logBuffer.String()).To(ContainSubstring("Submitted BOSH errand backup with task ID 42"))
+		})
+
+		It("fails if the deployment does not exist", func() {
+			boshClient.GetDeploymentReturns(nil, false, nil)
+
+			_, err = deployer.RunErrand(context.Background(), deploymentName, "backup", nil, boshContextID, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("fails if an operation is in progress", func() {
+			boshClient.GetTasksReturns([]boshdirector.BoshTask{{
+				State: "processing",
+			}}, nil)
+
+			_, err = deployer.RunErrand(context.Background(), deploymentName, "backup", nil, boshContextID, logger)
+			Expect(err).To(MatchError("task in progress"))
+			Expect(boshClient.RunErrandCallCount()).To(BeZero())
+		})
+
+		It("fails when bosh fails to run the errand", func() {
+			boshClient.RunErrandReturns(0, errors.New("zork"))
+
+			_, err = deployer.RunErrand(context.Background(), deploymentName, "backup", nil, boshContextID, logger)
+			Expect(err).To(MatchError("zork"))
+			Expect(logBuffer.String()).To(ContainSubstring("failed to run errand backup"))
+		})
+	})
+
+	Describe("Snapshot()", func() {
+		BeforeEach(func() {
+			oldManifest = []byte("---\nold-manifest-fetched-from-bosh: bar")
+			boshClient.GetDeploymentReturns(oldManifest, true, nil)
+			boshClient.GetConfigsReturns(boshConfigs, nil)
+		})
+
+		It("reads the deployed manifest and configs", func() {
+			snapshot, err := deployer.Snapshot(deploymentName, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snapshot).To(Equal(broker.DeploymentSnapshot{
+				Manifest: oldManifest,
+				Configs:  map[string]string{"some-config-type": "some-config-content"},
+			}))
+		})
+
+		It("does not read the configs when BOSH configs are disabled", func() {
+			deployer.DisableBoshConfigs = true
+
+			snapshot, err := deployer.Snapshot(deploymentName, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snapshot.Configs).To(BeNil())
+			Expect(boshClient.GetConfigsCallCount()).To(Equal(0))
+		})
+
+		It("fails when the deployment does not exist", func() {
+			boshClient.GetDeploymentReturns(nil, false, nil)
+
+			_, err := deployer.Snapshot(deploymentName, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+		})
+
+		It("fails when bosh fails to return the configs", func() {
+			boshClient.GetConfigsReturns(nil, errors.New("director unavailable"))
+
+			_, err := deployer.Snapshot(deploymentName, logger)
+			Expect(err).To(MatchError(ContainSubstring("director unavailable")))
+		})
+	})
+
+	Describe("Rollback()", func() {
+		var (
+			snapshot       broker.DeploymentSnapshot
+			rollbackTaskID int
+			rollbackError  error
+		)
+
+		BeforeEach(func() {
+			oldManifest = []byte("---\nold-manifest-fetched-from-bosh: bar")
+			boshContextID = "some-context-id"
+			snapshot = broker.DeploymentSnapshot{
+				Manifest: oldManifest,
+				Configs:  map[string]string{"some-config-type": "some-config-content"},
+			}
+
+			boshClient.GetConfigsReturns(boshConfigs, nil)
+			boshClient.DeployReturns(43, nil)
+		})
+
+		JustBeforeEach(func() {
+			rollbackTaskID, rollbackError = deployer.Rollback(context.Background(), deploymentName, boshContextID, snapshot, logger)
+		})
+
+		It("restores the configs of the snapshot", func() {
+			Expect(rollbackError).NotTo(HaveOccurred())
+			Expect(boshClient.UpdateConfigCallCount()).To(Equal(1))
+			configType, configName, configContent, _ := boshClient.UpdateConfigArgsForCall(0)
+			Expect(configType).To(Equal("some-config-type"))
+			Expect(configName).To(Equal(deploymentName))
+			Expect(string(configContent)).To(Equal("some-config-content"))
+			Expect(boshClient.DeleteConfigCallCount()).To(Equal(0))
+		})
+
+		It("redeploys the manifest of the snapshot under the given context ID", func() {
+			Expect(rollbackError).NotTo(HaveOccurred())
+			Expect(rollbackTaskID).To(Equal(43))
+			Expect(boshClient.DeployCallCount()).To(Equal(1))
+			manifest, contextID, _, _ := boshClient.DeployArgsForCall(0)
+			Expect(manifest).To(Equal(oldManifest))
+			Expect(contextID).To(Equal(boshContextID))
+		})
+
+		Context("when the upgrade added a config type", func() {
+			BeforeEach(func() {
+				boshClient.GetConfigsReturns(append(boshConfigs,
+					boshdirector.BoshConfig{Type: "new-config-type", Name: deploymentName, Content: "new-config-content"},
+				), nil)
+			})
+
+			It("deletes the config added by the upgrade", func() {
+				Expect(rollbackError).NotTo(HaveOccurred())
+				Expect(boshClient.DeleteConfigCallCount()).To(Equal(1))
+				configType, configName, _ := boshClient.DeleteConfigArgsForCall(0)
+				Expect(configType).To(Equal("new-config-type"))
+				Expect(configName).To(Equal(deploymentName))
+			})
+
+			Context("and bosh fails to delete it", func() {
+				BeforeEach(func() {
+					boshClient.DeleteConfigReturns(false, errors.New("director unavailable"))
+				})
+
+				It("does not redeploy", func() {
+					Expect(rollbackError).To(MatchError(ContainSubstring("error deleting config: director unavailable")))
+					Expect(boshClient.DeployCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when BOSH configs are disabled", func() {
+			BeforeEach(func() {
+				deployer.DisableBoshConfigs = true
+			})
+
+			It("only redeploys the manifest", func() {
+				Expect(rollbackError).NotTo(HaveOccurred())
+				Expect(boshClient.UpdateConfigCallCount()).To(Equal(0))
+				Expect(boshClient.DeleteConfigCallCount()).To(Equal(0))
+				Expect(boshClient.DeployCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when bosh fails to deploy the previous manifest", func() {
+			BeforeEach(func() {
+				boshClient.DeployReturns(0, errors.New("director unavailable"))
+			})
+
+			It("returns an error", func() {
+				Expect(rollbackError).To(MatchError(ContainSubstring("error rolling back deployment: director unavailable")))
+			})
+		})
+	})
+
+	Describe("PreviewUpgrade()", func() {
+		var (
+			preview    broker.DeploymentPreview
+			previewErr error
+		)
+
+		BeforeEach(func() {
+			oldManifest = []byte("name: a-manifest\nreleases:\n- name: redis\n  version: 1\nstemcells:\n- os: ubuntu-trusty\n")
+			previousPlanID = stringPointer(existingPlanID)
+
+			boshClient.GetDeploymentReturns(oldManifest, true, nil)
+			boshClient.GetConfigsReturns(boshConfigs, nil)
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{
+				Manifest: "stemcells:\n- os: ubuntu-trusty\nname: a-manifest\nreleases:\n- version: 2\n  name: redis\n",
+				Configs:  map[string]string{"some-config-type": "some-config-content", "cloud": "vm_types: []"},
+			}, nil)
+		})
+
+		JustBeforeEach(func() {
+			preview, previewErr = deployer.PreviewUpgrade(context.Background(), deploymentName, planID, previousPlanID, logger)
+		})
+
+		It("returns a unified diff of the manifest that would be deployed", func() {
+			Expect(previewErr).NotTo(HaveOccurred())
+			Expect(preview.ManifestDiff).To(Equal(`--- deployed/manifest.yml
++++ generated/manifest.yml
+@@ -1,6 +1,6 @@
+ name: a-manifest
+ releases:
+ - name: redis
+-  version: 1
++  version: 2
+ stemcells:
+ - os: ubuntu-trusty
+`))
+		})
+
+		It("returns diffs of the bosh configs that would change", func() {
+			Expect(previewErr).NotTo(HaveOccurred())
+			Expect(preview.ConfigDiffs).To(Equal(map[string]string{
+				"cloud": "--- deployed/cloud-config.yml\n+++ generated/cloud-config.yml\n@@ -0,0 +1,1 @@\n+vm_types: []\n",
+			}))
+		})
+
+		It("generates the manifest as an upgrade would", func() {
+			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(1))
+			_, actualDeploymentName, actualPlanID, actualRequestParams, actualOldManifest, actualPreviousPlanID, _, actualConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+			Expect(actualDeploymentName).To(Equal(deploymentName))
+			Expect(actualPlanID).To(Equal(planID))
+			Expect(actualRequestParams).To(BeNil())
+			Expect(actualOldManifest).To(Equal(oldManifest))
+			Expect(actualPreviousPlanID).To(Equal(previousPlanID))
+			Expect(actualConfigs).To(Equal(configsMap))
+		})
+
+		It("substitutes ODB managed secrets without storing them", func() {
+			Expect(odbSecrets.GenerateSecretPathsCallCount()).To(Equal(1))
+			Expect(odbSecrets.ReplaceODBRefsCallCount()).To(Equal(1))
+			Expect(bulkSetter.BulkSetCallCount()).To(BeZero())
+		})
+
+		It("does not deploy or update configs", func() {
+			Expect(boshClient.DeployCallCount()).To(BeZero())
+			Expect(boshClient.UpdateConfigCallCount()).To(BeZero())
+		})
+
+		Context("when the generated manifest is unchanged", func() {
+			BeforeEach(func() {
+				manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: string(oldManifest)}, nil)
+			})
+
+			It("returns an empty diff", func() {
+				Expect(previewErr).NotTo(HaveOccurred())
+				Expect(preview).To(Equal(broker.DeploymentPreview{}))
+			})
+		})
+
+		Context("when the deployment cannot be found", func() {
+			BeforeEach(func() {
+				boshClient.GetDeploymentReturns(nil, false, nil)
+			})
+
+			It("returns a deployment not found error", func() {
+				Expect(previewErr).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+			})
+		})
+
+		Context("when the manifest cannot be generated", func() {
+			BeforeEach(func() {
+				manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{}, errors.New("adapter failed"))
+			})
+
+			It("returns the error", func() {
+				Expect(previewErr).To(MatchError("adapter failed"))
+			})
+		})
+
+		Context("when bosh configs are disabled but the adapter returns some", func() {
+			BeforeEach(func() {
+				deployer.DisableBoshConfigs = true
+			})
+
+			It("returns an error", func() {
+				Expect(previewErr).To(MatchError("adapter returned bosh configs but feature is turned off"))
+				Expect(boshClient.GetConfigsCallCount()).To(BeZero())
+			})
+		})
+	})
+
+	Describe("PreviewUpdate()", func() {
+		BeforeEach(func() {
+			oldManifest = []byte("name: a-manifest\nproperties:\n  maxclients: 100\n")
+			previousPlanID = stringPointer(existingPlanID)
+
+			boshClient.GetDeploymentReturns(oldManifest, true, nil)
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{
+				Manifest: "name: a-manifest\nproperties:\n  maxclients: 200\n",
+			}, nil)
+		})
+
+		It("passes the request parameters and secrets to the manifest generator and returns the diff", func() {
+			preview, err := deployer.PreviewUpdate(context.Background(), deploymentName, planID, requestParams, previousPlanID, secretsMap, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(preview.ManifestDiff).To(ContainSubstring("-  maxclients: 100\n+  maxclients: 200\n"))
+
+			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(1))
+			_, _, _, actualRequestParams, _, _, actualSecretsMap, _, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+			Expect(actualRequestParams).To(Equal(requestParams))
+			Expect(actualSecretsMap).To(Equal(secretsMap))
+			Expect(boshClient.DeployCallCount()).To(BeZero())
+		})
+	})
+	Describe("PendingChanges()", func() {
+		BeforeEach(func() {
+			oldManifest = []byte(`---
+name: a-manifest
+releases:
+- name: redis
+  version: "1"
+stemcells:
+- alias: default
+  os: ubuntu-xenial
+  version: "1"
+instance_groups:
+- name: redis-server
+  instances: 1
+  jobs:
+  - name: redis
+    release: redis
+    properties:
+      maxclients: 100
+  - name: syslog
+    release: redis
+update:
+  canaries: 1
+  canary_watch_time: 30000-180000
+  update_watch_time: 30000-180000
+  max_in_flight: 4
+`)
+			boshClient.GetDeploymentReturns(oldManifest, true, nil)
+			boshClient.GetConfigsReturns(boshConfigs, nil)
+		})
+
+		It("reports no changes when the regenerated manifest only differs in its update block", func() {
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{
+				Manifest: strings.Replace(string(oldManifest), "canaries: 1", "canaries: 2", 1),
+			}, nil)
+
+			pendingChanges, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pendingChanges.Changes).To(BeEmpty())
+			Expect(pendingChanges.ManifestDiff).To(BeEmpty())
+		})
+
+		It("reports changes to instance groups, jobs, releases and stemcells by name", func() {
+			regeneratedManifest := strings.NewReplacer(
+				"version: \"1\"\nstemcells", "version: \"2\"\nstemcells",
+				"alias: default", "alias: new-default",
+				"instances: 1", "instances: 3",
+				"maxclients: 100", "maxclients: 200",
+				"  - name: syslog\n    release: redis\n", "",
+			).Replace(string(oldManifest))
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{Manifest: regeneratedManifest}, nil)
+
+			pendingChanges, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pendingChanges.Changes).To(ConsistOf(
+				broker.PendingChange{Path: "instance_groups/redis-server/instances", Change: broker.PendingChangeChanged},
+				broker.PendingChange{Path: "instance_groups/redis-server/jobs/redis/properties/maxclients", Change: broker.PendingChangeChanged},
+				broker.PendingChange{Path: "instance_groups/redis-server/jobs/syslog", Change: broker.PendingChangeRemoved},
+				broker.PendingChange{Path: "releases/redis/version", Change: broker.PendingChangeChanged},
+				broker.PendingChange{Path: "stemcells/default", Change: broker.PendingChangeRemoved},
+				broker.PendingChange{Path: "stemcells/new-default", Change: broker.PendingChangeAdded},
+			))
+			Expect(pendingChanges.ManifestDiff).To(ContainSubstring("+      maxclients: 200\n"))
+		})
+
+		It("regenerates the manifest on the given plan with the deployed configs and secrets", func() {
+			_, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifestGenerator.GenerateManifestCallCount()).To(Equal(1))
+			_, _, actualPlanID, actualRequestParams, actualOldManifest, actualPreviousPlanID, actualSecretsMap, actualConfigs, _ := manifestGenerator.GenerateManifestArgsForCall(0)
+			Expect(actualPlanID).To(Equal(planID))
+			Expect(actualRequestParams).To(BeEmpty())
+			Expect(actualOldManifest).To(Equal(oldManifest))
+			Expect(*actualPreviousPlanID).To(Equal(planID))
+			Expect(actualSecretsMap).To(Equal(secretsMap))
+			Expect(actualConfigs).To(Equal(configsMap))
+			Expect(boshClient.DeployCallCount()).To(BeZero())
+		})
+
+		It("reports changes to BOSH configs under their type", func() {
+			boshClient.GetConfigsReturns([]boshdirector.BoshConfig{
+				{Type: "cloud", Name: deploymentName, Content: "vm_types:\n- name: small\n  cpu: 1\n"},
+			}, nil)
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{
+				Manifest: string(oldManifest),
+				Configs:  serviceadapter.BOSHConfigs{"cloud": "vm_types:\n- name: small\n  cpu: 2\n"},
+			}, nil)
+
+			pendingChanges, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pendingChanges.Changes).To(Equal([]broker.PendingChange{
+				{Path: "configs/cloud/vm_types/small/cpu", Change: broker.PendingChangeChanged},
+			}))
+			Expect(pendingChanges.ConfigDiffs).To(HaveKeyWithValue("cloud", ContainSubstring("+- cpu: 2\n")))
+		})
+
+		It("returns an error when the deployment cannot be found", func() {
+			boshClient.GetDeploymentReturns(nil, false, nil)
+
+			_, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).To(BeAssignableToTypeOf(broker.DeploymentNotFoundError{}))
+		})
+
+		It("returns an error when the manifest cannot be generated", func() {
+			manifestGenerator.GenerateManifestReturns(serviceadapter.MarshalledGenerateManifest{}, errors.New("oops"))
+
+			_, err := deployer.PendingChanges(context.Background(), deploymentName, planID, secretsMap, logger)
+			Expect(err).To(MatchError("oops"))
+		})
+	})
 })
 
 func stringPointer(s string) *string {